@@ -0,0 +1,41 @@
+package atomicfile
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestWriteFile(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "RPI_EFI.fd")
+
+	if err := WriteFile(name, []byte("first"), 0o600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	if err := os.Chmod(name, 0o640); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteFile(name, []byte("second"), 0o644); err != nil {
+		t.Fatalf("WriteFile() of an existing file error = %v", err)
+	}
+
+	data, err := os.ReadFile(name)
+	if err != nil || string(data) != "second" {
+		t.Errorf("file = %q, %v; want %q", data, err, "second")
+	}
+	if info, err := os.Stat(name); err != nil {
+		t.Fatal(err)
+	} else if runtime.GOOS != "windows" && info.Mode().Perm() != 0o640 {
+		t.Errorf("mode = %v, want the existing 0640", info.Mode().Perm())
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Errorf("WriteFile() left %d files in the directory, want 1", len(entries))
+	}
+
+	if err := WriteFile(filepath.Join(dir, "missing", "RPI_EFI.fd"), nil, 0o644); err == nil {
+		t.Error("WriteFile() into a missing directory succeeded")
+	}
+}
@@ -0,0 +1,124 @@
+// Package audit records changes made to firmware variables and the
+// firmware served, to a JSON lines file, syslog or a webhook for SIEM
+// ingestion.
+package audit
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// Actions recorded in events.
+const (
+	// ActionSet is a SetVariable call.
+	ActionSet = "set"
+	// ActionDelete is a DeleteVariable call.
+	ActionDelete = "delete"
+	// ActionChange is a variable that differs from the last saved state
+	// when changes are saved, whichever call changed it.
+	ActionChange = "change"
+	// ActionSave is a SaveChanges call.
+	ActionSave = "save"
+	// ActionRead is a GetVariable or ListVariables call.
+	ActionRead = "read"
+	// ActionServe is a firmware image served to a host.
+	ActionServe = "serve"
+	// ActionReplaceBase is a replacement of the base firmware served to
+	// all hosts. Before and After are the hashes of the images.
+	ActionReplaceBase = "replace-base"
+)
+
+// Event is one audited operation.
+type Event struct {
+	Time time.Time `json:"time"`
+	// Actor identifies who made the change.
+	Actor  string `json:"actor,omitempty"`
+	Action string `json:"action"`
+	// Variable is the name, or for ambiguous names the Name-GUID key, of
+	// the variable. It is empty for saves.
+	Variable string `json:"variable,omitempty"`
+	// Before and After are the SHA-256 hashes of the variable data; empty
+	// if the variable did not exist.
+	Before string `json:"before,omitempty"`
+	After  string `json:"after,omitempty"`
+	// MacAddress and RemoteAddr identify the host firmware was served to,
+	// and Status is the HTTP status it was served with.
+	MacAddress string `json:"macAddress,omitempty"`
+	RemoteAddr string `json:"remoteAddr,omitempty"`
+	Status     int    `json:"status,omitempty"`
+	// Error is set if the operation failed.
+	Error string `json:"error,omitempty"`
+}
+
+// Sink receives audit events.
+type Sink interface {
+	Record(Event) error
+}
+
+// SinkFunc adapts a function to a Sink.
+type SinkFunc func(Event) error
+
+// Record calls f.
+func (f SinkFunc) Record(e Event) error {
+	return f(e)
+}
+
+// Multi returns a Sink recording events to all of sinks. Every sink gets
+// every event; their errors are joined.
+func Multi(sinks ...Sink) Sink {
+	return SinkFunc(func(e Event) error {
+		var errs []error
+		for _, s := range sinks {
+			errs = append(errs, s.Record(e))
+		}
+		return errors.Join(errs...)
+	})
+}
+
+// Hash returns the hash of variable data used in events.
+func Hash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// FileSink appends events to a file as JSON lines.
+type FileSink struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// NewFileSink opens path for appending, creating it if needed.
+func NewFileSink(path string) (*FileSink, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+	return &FileSink{file: f}, nil
+}
+
+// Record appends e and syncs the file, so recorded events survive a crash.
+func (s *FileSink) Record(e Event) error {
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.file.Write(line); err != nil {
+		return fmt.Errorf("failed to write audit log: %w", err)
+	}
+	return s.file.Sync()
+}
+
+// Close closes the file.
+func (s *FileSink) Close() error {
+	return s.file.Close()
+}
@@ -0,0 +1,185 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFileSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	events := []Event{
+		{Time: time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC), Actor: "ops", Action: ActionSet,
+			Variable: "Timeout", Before: Hash([]byte{5, 0}), After: Hash([]byte{7, 0})},
+		{Time: time.Date(2025, 1, 2, 3, 4, 6, 0, time.UTC), Actor: "ops", Action: ActionSave},
+	}
+
+	// Events are appended across sinks opened on the same file.
+	for _, e := range events {
+		sink, err := NewFileSink(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := sink.Record(e); err != nil {
+			t.Fatalf("Record() error = %v", err)
+		}
+		if err := sink.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var got []Event
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var e Event
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			t.Fatalf("line %q: %v", scanner.Text(), err)
+		}
+		got = append(got, e)
+	}
+	if !reflect.DeepEqual(got, events) {
+		t.Errorf("log = %+v, want %+v", got, events)
+	}
+}
+
+func TestSyslogSink(t *testing.T) {
+	e := Event{Time: time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC), Action: ActionServe,
+		MacAddress: "d8:3a:dd:61:4d:15", Status: 200}
+
+	udp, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer udp.Close()
+	sink, err := NewSyslogSink("udp", udp.LocalAddr().String(), FacilityAudit, "firmware")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sink.Close()
+	if err := sink.Record(e); err != nil {
+		t.Fatalf("Record() error = %v", err)
+	}
+	buf := make([]byte, 4096)
+	n, _, err := udp.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := string(buf[:n])
+	prefix := "<110>1 2025-01-02T03:04:05Z "
+	if !strings.HasPrefix(msg, prefix) || !strings.Contains(msg, " firmware ") || !strings.Contains(msg, " serve - {") {
+		t.Errorf("message = %q", msg)
+	}
+	var got Event
+	if err := json.Unmarshal([]byte(msg[strings.Index(msg, "{"):]), &got); err != nil || got != e {
+		t.Errorf("message event = %+v, %v", got, err)
+	}
+
+	tcp, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tcp.Close()
+	received := make(chan string, 1)
+	go func() {
+		conn, err := tcp.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		line, _ := bufio.NewReader(conn).ReadString('}')
+		received <- line
+	}()
+	sink, err = NewSyslogSink("tcp", tcp.Addr().String(), FacilityLocal0, "firmware")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sink.Close()
+	e.Error = "Too Many Requests"
+	if err := sink.Record(e); err != nil {
+		t.Fatalf("Record() error = %v", err)
+	}
+	framed := <-received
+	length, msg, _ := strings.Cut(framed, " ")
+	if want := strconv.Itoa(len(msg)); length != want || !strings.HasPrefix(msg, "<132>1 ") {
+		t.Errorf("framed message = %q, want length %s and warning severity", framed, want)
+	}
+}
+
+func TestWebhookSink(t *testing.T) {
+	var got []Event
+	status := http.StatusNoContent
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("request headers = %v", r.Header)
+		}
+		var e Event
+		if err := json.NewDecoder(r.Body).Decode(&e); err != nil {
+			t.Error(err)
+		}
+		got = append(got, e)
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+
+	sink := &WebhookSink{URL: srv.URL, Headers: map[string]string{"Authorization": "Bearer token"}}
+	e := Event{Time: time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC), Action: ActionRead, Variable: "Timeout"}
+	if err := sink.Record(e); err != nil {
+		t.Fatalf("Record() error = %v", err)
+	}
+	if len(got) != 1 || got[0] != e {
+		t.Errorf("posted %+v", got)
+	}
+	status = http.StatusInternalServerError
+	if err := sink.Record(e); err == nil {
+		t.Error("Record() succeeded on a server error")
+	}
+}
+
+func TestWebhookSink_Timeout(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	sink := &WebhookSink{URL: srv.URL, Timeout: 50 * time.Millisecond}
+	start := time.Now()
+	if err := sink.Record(Event{Action: ActionSave}); err == nil {
+		t.Error("Record() to an unresponsive endpoint succeeded")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Record() returned after %v", elapsed)
+	}
+}
+
+func TestMulti(t *testing.T) {
+	var a, b []Event
+	failing := SinkFunc(func(Event) error { return errors.New("disk full") })
+	sink := Multi(
+		SinkFunc(func(e Event) error { a = append(a, e); return nil }),
+		failing,
+		SinkFunc(func(e Event) error { b = append(b, e); return nil }),
+	)
+	if err := sink.Record(Event{Action: ActionSave}); err == nil || err.Error() != "disk full" {
+		t.Errorf("Record() error = %v", err)
+	}
+	if len(a) != 1 || len(b) != 1 {
+		t.Errorf("sinks got %d and %d events", len(a), len(b))
+	}
+}
@@ -0,0 +1,65 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// DefaultWebhookTimeout bounds the requests of a WebhookSink without a
+// Timeout.
+const DefaultWebhookTimeout = 10 * time.Second
+
+// WebhookSink posts every event as a JSON object to an HTTP endpoint.
+type WebhookSink struct {
+	// URL is the endpoint events are posted to.
+	URL string
+	// Headers are added to the requests, for instance an Authorization
+	// header.
+	Headers map[string]string
+	// Client sends the requests; nil uses http.DefaultClient.
+	Client *http.Client
+	// Timeout bounds each request, so that an unresponsive endpoint does
+	// not block operations. Zero uses DefaultWebhookTimeout.
+	Timeout time.Duration
+}
+
+// Record posts e. Responses other than 2xx are errors.
+func (s *WebhookSink) Record(e Event) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	timeout := s.Timeout
+	if timeout <= 0 {
+		timeout = DefaultWebhookTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.URL, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to create webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range s.Headers {
+		req.Header.Set(name, value)
+	}
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to post audit event: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("failed to post audit event: %s: %s", resp.Status, bytes.TrimSpace(body))
+	}
+	return nil
+}
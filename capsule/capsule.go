@@ -0,0 +1,193 @@
+// Package capsule parses UEFI capsules and extracts the firmware images in
+// Firmware Management Protocol (FMP) capsules.
+package capsule
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/metal3-community/uefi-firmware-manager/efi"
+)
+
+const (
+	// capsuleHeaderSize is the size of EFI_CAPSULE_HEADER.
+	capsuleHeaderSize = 16 + 4 + 4 + 4
+	// fmpHeaderSize is the fixed part of EFI_FIRMWARE_MANAGEMENT_CAPSULE_HEADER.
+	fmpHeaderSize = 4 + 2 + 2
+	// winCertTypeEfiGUID is the WIN_CERTIFICATE_UEFI_GUID certificate type.
+	winCertTypeEfiGUID = 0x0ef1
+)
+
+// ErrNotFMP is returned when a capsule does not carry an FMP payload.
+var ErrNotFMP = errors.New("not an FMP capsule")
+
+// Capsule is a parsed EFI_CAPSULE_HEADER and its body.
+type Capsule struct {
+	Guid       efi.GUID
+	HeaderSize uint32
+	Flags      uint32
+	// Body is the capsule image following the header.
+	Body []byte
+}
+
+// ImageHeader is an EFI_FIRMWARE_MANAGEMENT_CAPSULE_IMAGE_HEADER.
+type ImageHeader struct {
+	Version                uint32
+	UpdateImageTypeId      efi.GUID
+	UpdateImageIndex       uint8
+	UpdateImageSize        uint32
+	UpdateVendorCodeSize   uint32
+	UpdateHardwareInstance uint64
+	ImageCapsuleSupport    uint64
+}
+
+// Image is a firmware image embedded in an FMP capsule.
+type Image struct {
+	Header ImageHeader
+	// Data is the update image, including any authentication header.
+	Data []byte
+	// VendorCode is the vendor specific data following the image.
+	VendorCode []byte
+}
+
+// Parse parses the EFI_CAPSULE_HEADER at the start of data.
+func Parse(data []byte) (*Capsule, error) {
+	if len(data) < capsuleHeaderSize {
+		return nil, fmt.Errorf("capsule too short: %d bytes", len(data))
+	}
+	c := &Capsule{
+		Guid:       efi.ParseBinGUID(data, 0),
+		HeaderSize: binary.LittleEndian.Uint32(data[16:20]),
+		Flags:      binary.LittleEndian.Uint32(data[20:24]),
+	}
+	imageSize := binary.LittleEndian.Uint32(data[24:28])
+	if c.HeaderSize < capsuleHeaderSize || c.HeaderSize > imageSize {
+		return nil, fmt.Errorf("invalid capsule header size %d", c.HeaderSize)
+	}
+	if uint64(imageSize) > uint64(len(data)) {
+		return nil, fmt.Errorf("capsule image size %d exceeds the %d bytes available", imageSize, len(data))
+	}
+	c.Body = data[c.HeaderSize:imageSize]
+	return c, nil
+}
+
+// IsFMP reports whether the capsule carries an FMP payload.
+func (c *Capsule) IsFMP() bool {
+	return c.Guid.String() == efi.FwMgrCapsule
+}
+
+// Images returns the firmware images of an FMP capsule. Embedded drivers
+// are skipped.
+func (c *Capsule) Images() ([]Image, error) {
+	if !c.IsFMP() {
+		return nil, fmt.Errorf("%w: capsule guid %s", ErrNotFMP, c.Guid)
+	}
+	body := c.Body
+	if len(body) < fmpHeaderSize {
+		return nil, fmt.Errorf("FMP header too short: %d bytes", len(body))
+	}
+	if version := binary.LittleEndian.Uint32(body[0:4]); version != 1 {
+		return nil, fmt.Errorf("unsupported FMP capsule version %d", version)
+	}
+	drivers := int(binary.LittleEndian.Uint16(body[4:6]))
+	payloads := int(binary.LittleEndian.Uint16(body[6:8]))
+	if len(body) < fmpHeaderSize+8*(drivers+payloads) {
+		return nil, fmt.Errorf("FMP item offset list truncated")
+	}
+
+	images := make([]Image, 0, payloads)
+	for i := drivers; i < drivers+payloads; i++ {
+		offset := binary.LittleEndian.Uint64(body[fmpHeaderSize+8*i:])
+		if offset >= uint64(len(body)) {
+			return nil, fmt.Errorf("FMP payload %d offset 0x%x outside capsule", i-drivers, offset)
+		}
+		image, err := parseImage(body[offset:])
+		if err != nil {
+			return nil, fmt.Errorf("FMP payload %d: %w", i-drivers, err)
+		}
+		images = append(images, *image)
+	}
+	return images, nil
+}
+
+// parseImage parses an EFI_FIRMWARE_MANAGEMENT_CAPSULE_IMAGE_HEADER and the
+// image and vendor code that follow it.
+func parseImage(data []byte) (*Image, error) {
+	if len(data) < 32 {
+		return nil, fmt.Errorf("image header too short: %d bytes", len(data))
+	}
+	h := ImageHeader{
+		Version:              binary.LittleEndian.Uint32(data[0:4]),
+		UpdateImageTypeId:    efi.ParseBinGUID(data, 4),
+		UpdateImageIndex:     data[20],
+		UpdateImageSize:      binary.LittleEndian.Uint32(data[24:28]),
+		UpdateVendorCodeSize: binary.LittleEndian.Uint32(data[28:32]),
+	}
+	headerSize := 32
+	switch {
+	case h.Version == 1:
+	case h.Version == 2 && len(data) >= 40:
+		h.UpdateHardwareInstance = binary.LittleEndian.Uint64(data[32:40])
+		headerSize = 40
+	case h.Version >= 3 && len(data) >= 48:
+		h.UpdateHardwareInstance = binary.LittleEndian.Uint64(data[32:40])
+		h.ImageCapsuleSupport = binary.LittleEndian.Uint64(data[40:48])
+		headerSize = 48
+	default:
+		return nil, fmt.Errorf("invalid image header version %d", h.Version)
+	}
+
+	end := uint64(headerSize) + uint64(h.UpdateImageSize) + uint64(h.UpdateVendorCodeSize)
+	if end > uint64(len(data)) {
+		return nil, fmt.Errorf("image of %d bytes exceeds the %d bytes available",
+			h.UpdateImageSize, len(data)-headerSize)
+	}
+	imageEnd := headerSize + int(h.UpdateImageSize)
+	return &Image{
+		Header:     h,
+		Data:       data[headerSize:imageEnd],
+		VendorCode: data[imageEnd:end],
+	}, nil
+}
+
+// Payload returns the image without its EFI_FIRMWARE_IMAGE_AUTHENTICATION
+// header, if it has one. The signature is not verified.
+func (img *Image) Payload() []byte {
+	data := img.Data
+	// UINT64 MonotonicCount, then WIN_CERTIFICATE_UEFI_GUID.
+	if len(data) < 8+8+16 {
+		return data
+	}
+	length := binary.LittleEndian.Uint32(data[8:12])
+	certType := binary.LittleEndian.Uint16(data[14:16])
+	if certType != winCertTypeEfiGUID || efi.ParseBinGUID(data, 16).String() != efi.EfiCertPkcs7 {
+		return data
+	}
+	if length < 8+16 || uint64(length) > uint64(len(data)-8) {
+		return data
+	}
+	return data[8+length:]
+}
+
+// Updater consumes raw firmware images, e.g. a manager.FirmwareManager.
+type Updater interface {
+	UpdateFirmware(firmwareData []byte) error
+}
+
+// Apply extracts the single firmware image from an FMP capsule and passes
+// it, without its authentication header, to u.
+func Apply(u Updater, data []byte) error {
+	c, err := Parse(data)
+	if err != nil {
+		return err
+	}
+	images, err := c.Images()
+	if err != nil {
+		return err
+	}
+	if len(images) != 1 {
+		return fmt.Errorf("capsule carries %d firmware images, expected 1", len(images))
+	}
+	return u.UpdateFirmware(images[0].Payload())
+}
@@ -0,0 +1,163 @@
+package capsule
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+
+	"github.com/metal3-community/uefi-firmware-manager/efi"
+)
+
+// buildImage returns a version 3 image header followed by image and vendorCode.
+func buildImage(typeID string, image, vendorCode []byte) []byte {
+	buf := binary.LittleEndian.AppendUint32(nil, 3)
+	buf = append(buf, efi.StringToGUID(typeID).Bytes()...)
+	buf = append(buf, 1, 0, 0, 0)
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(len(image)))
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(len(vendorCode)))
+	buf = binary.LittleEndian.AppendUint64(buf, 0)
+	buf = binary.LittleEndian.AppendUint64(buf, 0)
+	buf = append(buf, image...)
+	return append(buf, vendorCode...)
+}
+
+// buildCapsule wraps drivers and images in an FMP capsule.
+func buildCapsule(drivers, images [][]byte) []byte {
+	items := append(append([][]byte{}, drivers...), images...)
+	body := binary.LittleEndian.AppendUint32(nil, 1)
+	body = binary.LittleEndian.AppendUint16(body, uint16(len(drivers)))
+	body = binary.LittleEndian.AppendUint16(body, uint16(len(images)))
+	offset := len(body) + 8*len(items)
+	for _, item := range items {
+		body = binary.LittleEndian.AppendUint64(body, uint64(offset))
+		offset += len(item)
+	}
+	for _, item := range items {
+		body = append(body, item...)
+	}
+
+	buf := append([]byte{}, efi.StringToGUID(efi.FwMgrCapsule).Bytes()...)
+	buf = binary.LittleEndian.AppendUint32(buf, capsuleHeaderSize)
+	buf = binary.LittleEndian.AppendUint32(buf, 0x50000)
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(capsuleHeaderSize+len(body)))
+	return append(buf, body...)
+}
+
+// authenticate prepends an EFI_FIRMWARE_IMAGE_AUTHENTICATION header.
+func authenticate(image []byte) []byte {
+	cert := []byte("signature")
+	buf := binary.LittleEndian.AppendUint64(nil, 1)
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(8+16+len(cert)))
+	buf = binary.LittleEndian.AppendUint16(buf, 0x0200)
+	buf = binary.LittleEndian.AppendUint16(buf, winCertTypeEfiGUID)
+	buf = append(buf, efi.StringToGUID(efi.EfiCertPkcs7).Bytes()...)
+	buf = append(buf, cert...)
+	return append(buf, image...)
+}
+
+type fakeUpdater struct {
+	data []byte
+}
+
+func (f *fakeUpdater) UpdateFirmware(firmwareData []byte) error {
+	f.data = firmwareData
+	return nil
+}
+
+func TestCapsule_Images(t *testing.T) {
+	typeID := "b0e8f0b8-3f1a-4b5c-9a1d-2c3e4f5a6b7c"
+	firmware := []byte("RPI_EFI.fd contents")
+	data := buildCapsule(
+		[][]byte{[]byte("driver")},
+		[][]byte{buildImage(typeID, authenticate(firmware), []byte("vendor"))},
+	)
+
+	c, err := Parse(data)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if !c.IsFMP() {
+		t.Fatalf("IsFMP() = false, want true")
+	}
+	images, err := c.Images()
+	if err != nil {
+		t.Fatalf("Images() error = %v", err)
+	}
+	if len(images) != 1 {
+		t.Fatalf("Images() returned %d images, want 1", len(images))
+	}
+	img := images[0]
+	if img.Header.UpdateImageTypeId.String() != typeID {
+		t.Errorf("UpdateImageTypeId = %s, want %s", img.Header.UpdateImageTypeId, typeID)
+	}
+	if !bytes.Equal(img.VendorCode, []byte("vendor")) {
+		t.Errorf("VendorCode = %q, want %q", img.VendorCode, "vendor")
+	}
+	if !bytes.Equal(img.Payload(), firmware) {
+		t.Errorf("Payload() = %q, want %q", img.Payload(), firmware)
+	}
+
+	u := &fakeUpdater{}
+	if err := Apply(u, data); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if !bytes.Equal(u.data, firmware) {
+		t.Errorf("Apply() passed %q, want %q", u.data, firmware)
+	}
+}
+
+func TestParse_Invalid(t *testing.T) {
+	valid := buildCapsule(nil, [][]byte{buildImage(efi.NotValid, []byte("fw"), nil)})
+
+	truncatedImage := append([]byte{}, valid...)
+	binary.LittleEndian.PutUint32(truncatedImage[24:28], uint32(len(valid)-1))
+
+	badOffset := append([]byte{}, valid...)
+	binary.LittleEndian.PutUint64(badOffset[capsuleHeaderSize+fmpHeaderSize:], 0x1000)
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"short", valid[:10]},
+		{"image size beyond data", valid[:len(valid)-1]},
+		{"truncated payload", truncatedImage},
+		{"payload offset outside capsule", badOffset},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := Parse(tt.data)
+			if err == nil {
+				_, err = c.Images()
+			}
+			if err == nil {
+				t.Errorf("expected an error")
+			}
+		})
+	}
+
+	other := append([]byte{}, valid...)
+	copy(other, efi.StringToGUID(efi.EfiGlobalVariable).Bytes())
+	c, err := Parse(other)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if _, err := c.Images(); !errors.Is(err, ErrNotFMP) {
+		t.Errorf("Images() error = %v, want ErrNotFMP", err)
+	}
+}
+
+func FuzzParse(f *testing.F) {
+	f.Add(buildCapsule(nil, [][]byte{buildImage(efi.NotValid, []byte("fw"), []byte("vendor"))}))
+	f.Add(buildCapsule([][]byte{[]byte("driver")}, [][]byte{buildImage(efi.NotValid, nil, nil)}))
+	f.Add([]byte{})
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		c, err := Parse(data)
+		if err != nil {
+			return
+		}
+		_, _ = c.Images()
+	})
+}
@@ -1,36 +1,588 @@
 package main
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
 	"io"
+	"maps"
 	"net"
 	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
 
 	"github.com/go-logr/logr"
+	"github.com/metal3-community/uefi-firmware-manager/atomicfile"
+	"github.com/metal3-community/uefi-firmware-manager/audit"
+	"github.com/metal3-community/uefi-firmware-manager/edk2"
 	"github.com/metal3-community/uefi-firmware-manager/manager"
+	"github.com/metal3-community/uefi-firmware-manager/types"
+	"gopkg.in/yaml.v3"
 )
 
+const usage = `usage: mgr [-output text|json|yaml] <command> [arguments]
+
+commands:
+  boot-order <path>                list the boot entries of a firmware in boot order
+  diff <path> <path>               list the variables that differ between two firmwares
+  firmware -mac <mac> [-out file]  write the firmware image generated for a host
+  gc [flags]                       archive and remove stale MAC directories
+  manifest                         list the served firmware files
+  validate <path>...               check the variables, boot entries and network settings of firmwares
+  vars <path>                      list the variables of a firmware
+  version <image>...               print the version of firmware images
+
+A firmware path is anything manager.Open accepts: an image, an OVMF VARS
+file, an efivarfs directory or a JSON data or MAC directory.
+`
+
 func main() {
 	log := logr.Logger.WithName(logr.Logger{}, "main")
-	mgr, err := manager.NewSimpleFirmwareManager(log)
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), usage)
+		flag.PrintDefaults()
+	}
+	output := flag.String("output", "text", "output format of commands: text, json or yaml")
+	flag.StringVar(output, "o", "text", "shorthand for -output")
+	flag.Parse()
+	args := flag.Args()
+	if len(args) == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	command, args := args[0], args[1:]
+	p, err := newPrinter(os.Stdout, *output)
 	if err != nil {
-		log.Error(err, "failed to create firmware manager")
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	if command != "gc" {
+		if dir := os.Getenv("EDK2_FIRMWARE_DIR"); dir != "" {
+			if err := edk2.LoadDir(dir); err != nil {
+				log.Error(err, "failed to load firmware files", "dir", dir)
+				os.Exit(1)
+			}
+		}
+	}
+	var status int
+	switch command {
+	case "boot-order":
+		status = bootOrder(args, p, log)
+	case "diff":
+		status = diff(args, p, log)
+	case "firmware":
+		status = firmware(args, p, log)
+	case "gc":
+		status = gc(args, p, log)
+	case "manifest":
+		status = manifest(p)
+	case "validate":
+		status = validate(args, p, log)
+	case "vars":
+		status = vars(args, p, log)
+	case "version":
+		status = version(args, p)
+	default:
+		fmt.Fprintf(os.Stderr, "mgr: unknown command %q\n", command)
+		flag.Usage()
+		status = 2
+	}
+	if err := p.Close(); err != nil {
+		fmt.Fprintln(os.Stderr, "mgr:", err)
+		status = 1
+	}
+	os.Exit(status)
+}
+
+// printer writes the results of a command in the output format.
+type printer struct {
+	w    io.Writer
+	json *json.Encoder
+	yaml *yaml.Encoder
+}
+
+// newPrinter returns a printer writing to w in format, text, json or
+// yaml. Several yaml values are written as a stream of documents.
+func newPrinter(w io.Writer, format string) (*printer, error) {
+	p := &printer{w: w}
+	switch format {
+	case "text":
+	case "json":
+		p.json = json.NewEncoder(w)
+		p.json.SetIndent("", "  ")
+	case "yaml":
+		p.yaml = yaml.NewEncoder(w)
+		p.yaml.SetIndent(2)
+	default:
+		return nil, fmt.Errorf("unknown output format %q; want text, json or yaml", format)
 	}
-	mac, err := net.ParseMAC("00:11:22:33:44:55")
+	return p, nil
+}
+
+// Print writes v, or in the text format calls text to write it.
+func (p *printer) Print(v any, text func(w io.Writer) error) error {
+	switch {
+	case p.json != nil:
+		return p.json.Encode(v)
+	case p.yaml != nil:
+		return p.yaml.Encode(v)
+	}
+	return text(p.w)
+}
+
+// Close ends the yaml stream.
+func (p *printer) Close() error {
+	if p.yaml == nil {
+		return nil
+	}
+	return p.yaml.Close()
+}
+
+// table writes rows of tab separated cells as aligned columns.
+func table(w io.Writer, header string, rows ...string) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, header)
+	for _, row := range rows {
+		fmt.Fprintln(tw, row)
+	}
+	return tw.Flush()
+}
+
+// firmware writes the firmware image generated for a host to a file and
+// prints it.
+func firmware(args []string, p *printer, log logr.Logger) int {
+	fs := flag.NewFlagSet("firmware", flag.ContinueOnError)
+	macFlag := fs.String("mac", "", "MAC address of the host")
+	out := fs.String("out", edk2.FirmwareFileName, "file the image is written to")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *macFlag == "" || fs.NArg() > 0 {
+		fmt.Fprintln(os.Stderr, "usage: mgr [-output text|json|yaml] firmware -mac <mac> [-out file]")
+		return 2
+	}
+	mac, err := net.ParseMAC(*macFlag)
 	if err != nil {
-		log.Error(err, "failed to parse MAC address")
+		fmt.Fprintln(os.Stderr, "firmware:", err)
+		return 2
 	}
 
+	mgr, err := manager.NewSimpleFirmwareManager(log)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "firmware:", err)
+		return 1
+	}
 	reader, err := mgr.GetFirmwareReader(mac)
 	if err != nil {
-		log.Error(err, "failed to get firmware reader")
+		fmt.Fprintln(os.Stderr, "firmware:", err)
+		return 1
+	}
+	image, err := io.ReadAll(reader)
+	if err == nil {
+		err = atomicfile.WriteFile(*out, image, 0o644)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "firmware:", err)
+		return 1
+	}
+
+	sum := sha256.Sum256(image)
+	asset := types.FirmwareAsset{Name: *out, Size: int64(len(image)), SHA256: hex.EncodeToString(sum[:])}
+	if build, err := edk2.ScanVersion(image); err == nil {
+		asset.Version = build.Version
+	}
+	err = p.Print(asset, func(w io.Writer) error {
+		_, err := fmt.Fprintf(w, "wrote %s for %s: %d bytes, sha256 %s\n", asset.Name, mac, asset.Size, asset.SHA256)
+		return err
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "firmware:", err)
+		return 1
+	}
+	return 0
+}
+
+// variable describes a firmware variable.
+type variable struct {
+	Name       string `json:"name" yaml:"name"`
+	GUID       string `json:"guid" yaml:"guid"`
+	Attributes uint32 `json:"attributes" yaml:"attributes"`
+	Size       int    `json:"size" yaml:"size"`
+	SHA256     string `json:"sha256" yaml:"sha256"`
+}
+
+// listVariables returns the variables of the firmware at path, sorted by
+// name.
+func listVariables(path string, log logr.Logger) ([]variable, error) {
+	mgr, err := manager.Open(path, log)
+	if err != nil {
+		return nil, err
+	}
+	vars, err := mgr.ListVariables()
+	if err != nil {
+		return nil, err
+	}
+	list := make([]variable, 0, len(vars))
+	for name, v := range vars {
+		list = append(list, variable{
+			Name:       name,
+			GUID:       v.Guid.String(),
+			Attributes: v.Attr,
+			Size:       len(v.Data),
+			SHA256:     audit.Hash(v.Data),
+		})
+	}
+	slices.SortFunc(list, func(a, b variable) int { return strings.Compare(a.Name, b.Name) })
+	return list, nil
+}
+
+// vars prints the variables of a firmware.
+func vars(args []string, p *printer, log logr.Logger) int {
+	if len(args) != 1 {
+		fmt.Fprintln(os.Stderr, "usage: mgr [-output text|json|yaml] vars <path>")
+		return 2
+	}
+	list, err := listVariables(args[0], log)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "vars:", err)
+		return 1
+	}
+	err = p.Print(list, func(w io.Writer) error {
+		rows := make([]string, 0, len(list))
+		for _, v := range list {
+			rows = append(rows, fmt.Sprintf("%s\t%s\t0x%08x\t%d", v.Name, v.GUID, v.Attributes, v.Size))
+		}
+		return table(w, "NAME\tGUID\tATTRIBUTES\tSIZE", rows...)
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "vars:", err)
+		return 1
+	}
+	return 0
+}
+
+// Changes of a variable between two firmwares.
+const (
+	changeAdded    = "added"
+	changeRemoved  = "removed"
+	changeModified = "modified"
+)
+
+// change is a variable that differs between two firmwares. Before is nil
+// for an added variable, After for a removed one.
+type change struct {
+	Name   string    `json:"name" yaml:"name"`
+	Change string    `json:"change" yaml:"change"`
+	Before *variable `json:"before,omitempty" yaml:"before,omitempty"`
+	After  *variable `json:"after,omitempty" yaml:"after,omitempty"`
+}
+
+// diffVariables returns the changes from the variables before to after,
+// both sorted by name, sorted by name.
+func diffVariables(before, after []variable) []change {
+	changes := []change{}
+	for len(before) > 0 || len(after) > 0 {
+		switch {
+		case len(after) == 0 || len(before) > 0 && before[0].Name < after[0].Name:
+			changes = append(changes, change{Name: before[0].Name, Change: changeRemoved, Before: &before[0]})
+			before = before[1:]
+		case len(before) == 0 || after[0].Name < before[0].Name:
+			changes = append(changes, change{Name: after[0].Name, Change: changeAdded, After: &after[0]})
+			after = after[1:]
+		default:
+			if before[0] != after[0] {
+				changes = append(changes, change{Name: after[0].Name, Change: changeModified, Before: &before[0], After: &after[0]})
+			}
+			before, after = before[1:], after[1:]
+		}
+	}
+	return changes
+}
+
+// diff prints the variables that differ between two firmwares.
+func diff(args []string, p *printer, log logr.Logger) int {
+	if len(args) != 2 {
+		fmt.Fprintln(os.Stderr, "usage: mgr [-output text|json|yaml] diff <path> <path>")
+		return 2
+	}
+	before, err := listVariables(args[0], log)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "diff:", err)
+		return 1
+	}
+	after, err := listVariables(args[1], log)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "diff:", err)
+		return 1
+	}
+	changes := diffVariables(before, after)
+	err = p.Print(changes, func(w io.Writer) error {
+		for _, c := range changes {
+			var line string
+			switch c.Change {
+			case changeAdded:
+				line = fmt.Sprintf("+ %s (%d bytes)", c.Name, c.After.Size)
+			case changeRemoved:
+				line = fmt.Sprintf("- %s (%d bytes)", c.Name, c.Before.Size)
+			default:
+				line = fmt.Sprintf("~ %s (%d -> %d bytes, attributes 0x%08x -> 0x%08x)",
+					c.Name, c.Before.Size, c.After.Size, c.Before.Attributes, c.After.Attributes)
+			}
+			if _, err := fmt.Fprintln(w, line); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "diff:", err)
+		return 1
+	}
+	return 0
+}
+
+// report lists the problems found in a firmware.
+type report struct {
+	Path     string   `json:"path" yaml:"path"`
+	Valid    bool     `json:"valid" yaml:"valid"`
+	Problems []string `json:"problems,omitempty" yaml:"problems,omitempty"`
+}
+
+// validateFirmware checks the variables, boot entries and network
+// settings of the firmware at path.
+func validateFirmware(path string, log logr.Logger) (report, error) {
+	r := report{Path: path}
+	mgr, err := manager.Open(path, log)
+	if err != nil {
+		return r, err
+	}
+	vars, err := mgr.ListVariables()
+	if err != nil {
+		return r, err
+	}
+	for _, name := range slices.Sorted(maps.Keys(vars)) {
+		if err := vars[name].ValidateStored(); err != nil {
+			r.Problems = append(r.Problems, err.Error())
+		}
+	}
+	if entries, err := mgr.GetBootEntries(); err != nil {
+		r.Problems = append(r.Problems, fmt.Sprintf("boot entries: %v", err))
+	} else {
+		for _, e := range entries {
+			if err := e.Validate(); err != nil {
+				r.Problems = append(r.Problems, fmt.Sprintf("boot entry %s: %v", e.ID, err))
+			}
+		}
+	}
+	if settings, err := mgr.GetNetworkSettings(); err == nil {
+		if err := settings.Validate(); err != nil {
+			r.Problems = append(r.Problems, fmt.Sprintf("network settings: %v", err))
+		}
+	}
+	r.Valid = len(r.Problems) == 0
+	return r, nil
+}
+
+// validate prints the validation reports of firmwares. It fails if one is
+// invalid.
+func validate(paths []string, p *printer, log logr.Logger) int {
+	if len(paths) == 0 {
+		fmt.Fprintln(os.Stderr, "usage: mgr [-output text|json|yaml] validate <path>...")
+		return 2
+	}
+	status := 0
+	for _, path := range paths {
+		r, err := validateFirmware(path, log)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "validate: %s: %v\n", path, err)
+			status = 1
+			continue
+		}
+		if !r.Valid {
+			status = 1
+		}
+		err = p.Print(r, func(w io.Writer) error {
+			if r.Valid {
+				_, err := fmt.Fprintf(w, "%s: valid\n", path)
+				return err
+			}
+			if _, err := fmt.Fprintf(w, "%s: %d problems\n", path, len(r.Problems)); err != nil {
+				return err
+			}
+			for _, problem := range r.Problems {
+				if _, err := fmt.Fprintf(w, "  %s\n", problem); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "validate:", err)
+			return 1
+		}
+	}
+	return status
+}
+
+// bootOrder prints the boot entries of a firmware in boot order.
+func bootOrder(args []string, p *printer, log logr.Logger) int {
+	if len(args) != 1 {
+		fmt.Fprintln(os.Stderr, "usage: mgr [-output text|json|yaml] boot-order <path>")
+		return 2
+	}
+	mgr, err := manager.Open(args[0], log)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "boot-order:", err)
+		return 1
+	}
+	order, err := mgr.GetBootOrder()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "boot-order:", err)
+		return 1
 	}
-	file, err := os.OpenFile("RPI_EFI.fd", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	entries, err := mgr.GetBootEntries()
 	if err != nil {
-		log.Error(err, "failed to create firmware file")
+		fmt.Fprintln(os.Stderr, "boot-order:", err)
+		return 1
+	}
+	ordered := make([]types.BootEntry, 0, len(order))
+	for _, id := range order {
+		i := slices.IndexFunc(entries, func(e types.BootEntry) bool { return strings.EqualFold(e.ID, id) })
+		if i < 0 {
+			fmt.Fprintf(os.Stderr, "boot-order: no boot entry %s\n", id)
+			return 1
+		}
+		ordered = append(ordered, entries[i])
 	}
-	defer file.Close()
-	_, err = io.Copy(file, reader)
+	err = p.Print(ordered, func(w io.Writer) error {
+		rows := make([]string, 0, len(ordered))
+		for _, e := range ordered {
+			enabled := "no"
+			if e.Enabled {
+				enabled = "yes"
+			}
+			rows = append(rows, fmt.Sprintf("%s\t%s\t%s\t%s", e.ID, enabled, e.Name, e.DevPath))
+		}
+		return table(w, "ID\tENABLED\tNAME\tDEVICE PATH", rows...)
+	})
 	if err != nil {
-		log.Error(err, "failed to write firmware file")
+		fmt.Fprintln(os.Stderr, "boot-order:", err)
+		return 1
+	}
+	return 0
+}
+
+// gc archives and removes the stale MAC directories of a data directory
+// and prints them.
+func gc(args []string, p *printer, log logr.Logger) int {
+	fs := flag.NewFlagSet("gc", flag.ContinueOnError)
+	dataDir := fs.String("data-dir", "data", "data directory, or the directory of a tenant")
+	var opts manager.GCOptions
+	fs.DurationVar(&opts.TTL, "ttl", 30*24*time.Hour, "collect MAC directories not accessed for this long")
+	fs.StringVar(&opts.ArchiveDir, "archive-dir", "archive", "directory receiving the tar.gz archives")
+	fs.BoolVar(&opts.DryRun, "dry-run", false, "only list the stale MAC directories")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	stale, err := manager.CollectStaleHosts(*dataDir, opts, log)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "gc:", err)
+		return 1
+	}
+	err = p.Print(stale, func(w io.Writer) error {
+		rows := make([]string, 0, len(stale))
+		for _, host := range stale {
+			result := host.Archive
+			if host.Error != "" {
+				result = "error: " + host.Error
+			} else if result == "" {
+				result = "-"
+			}
+			rows = append(rows, fmt.Sprintf("%s\t%s\t%s", host.MacAddress, host.LastAccessed.Format(time.RFC3339), result))
+		}
+		return table(w, "MAC ADDRESS\tLAST ACCESSED\tARCHIVE", rows...)
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "gc:", err)
+		return 1
+	}
+	for _, host := range stale {
+		if host.Error != "" {
+			return 1
+		}
+	}
+	return 0
+}
+
+// manifest prints the manifest of the served firmware files.
+func manifest(p *printer) int {
+	assets := edk2.Manifest()
+	err := p.Print(assets, func(w io.Writer) error {
+		rows := make([]string, 0, len(assets))
+		for _, a := range assets {
+			rows = append(rows, fmt.Sprintf("%s\t%d\t%s\t%s", a.Name, a.Size, a.SHA256, a.Version))
+		}
+		return table(w, "NAME\tSIZE\tSHA256\tVERSION", rows...)
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "manifest:", err)
+		return 1
+	}
+	return 0
+}
+
+// version prints the version information of firmware images.
+func version(images []string, p *printer) int {
+	if len(images) == 0 {
+		fmt.Fprintln(os.Stderr, "usage: mgr [-output text|json|yaml] version <image>...")
+		return 2
+	}
+	status := 0
+	for _, path := range images {
+		image, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "version:", err)
+			status = 1
+			continue
+		}
+		build, err := edk2.ScanVersion(image)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "version: %s: %v\n", path, err)
+			status = 1
+			continue
+		}
+		err = p.Print(build, func(w io.Writer) error {
+			_, err := fmt.Fprintf(w, "%s: %s\n", path, versionText(build))
+			return err
+		})
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "version:", err)
+			return 1
+		}
+	}
+	return status
+}
+
+// versionText describes build on one line.
+func versionText(build types.FirmwareBuild) string {
+	text := build.Version
+	if text == "" {
+		text = "unknown version"
+	}
+	if build.BuildID != "" && !strings.Contains(text, build.BuildID) {
+		text += " (" + build.BuildID + ")"
+	}
+	if build.Dirty {
+		text += ", modified source"
+	}
+	if build.TrustedFirmware != "" {
+		text += ", TF-A " + build.TrustedFirmware
 	}
+	return text
 }
@@ -0,0 +1,223 @@
+// Package dtb reads the properties of flattened device tree (dtb) blobs
+// needed to identify a board.
+package dtb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+const (
+	fdtMagic = 0xd00dfeed
+
+	fdtBeginNode = 0x1
+	fdtEndNode   = 0x2
+	fdtProp      = 0x3
+	fdtNop       = 0x4
+	fdtEnd       = 0x9
+
+	fdtHeaderSize = 40
+)
+
+// ErrInvalid is returned for blobs that are not valid flattened device trees.
+var ErrInvalid = errors.New("invalid device tree blob")
+
+// Info is the board description of a device tree.
+type Info struct {
+	// Model is the root model property, e.g. "Raspberry Pi 4 Model B".
+	Model string
+	// Compatible is the root compatible list, most specific first.
+	Compatible []string
+	// MemorySize is the total size of the memory nodes in bytes. The
+	// Raspberry Pi firmware fills in memory at boot, so it is often zero.
+	MemorySize uint64
+}
+
+// IsCompatible reports whether the device tree lists compat in its root
+// compatible property.
+func (i *Info) IsCompatible(compat string) bool {
+	return slices.Contains(i.Compatible, compat)
+}
+
+// Parse extracts the board description from a dtb blob.
+func Parse(data []byte) (*Info, error) {
+	if len(data) < fdtHeaderSize {
+		return nil, fmt.Errorf("%w: %d bytes", ErrInvalid, len(data))
+	}
+	be := binary.BigEndian
+	if be.Uint32(data[0:4]) != fdtMagic {
+		return nil, fmt.Errorf("%w: bad magic 0x%08x", ErrInvalid, be.Uint32(data[0:4]))
+	}
+	total := be.Uint32(data[4:8])
+	structOff := be.Uint32(data[8:12])
+	stringsOff := be.Uint32(data[12:16])
+	stringsSize := be.Uint32(data[32:36])
+	structSize := be.Uint32(data[36:40])
+	if uint64(total) > uint64(len(data)) ||
+		uint64(structOff)+uint64(structSize) > uint64(total) ||
+		uint64(stringsOff)+uint64(stringsSize) > uint64(total) {
+		return nil, fmt.Errorf("%w: blocks exceed blob size", ErrInvalid)
+	}
+	p := &parser{
+		data:    data[structOff : structOff+structSize],
+		strings: data[stringsOff : stringsOff+stringsSize],
+	}
+	return p.parse()
+}
+
+type parser struct {
+	data    []byte
+	strings []byte
+	off     int
+}
+
+func (p *parser) u32() (uint32, error) {
+	if p.off+4 > len(p.data) {
+		return 0, fmt.Errorf("%w: structure block truncated", ErrInvalid)
+	}
+	v := binary.BigEndian.Uint32(p.data[p.off:])
+	p.off += 4
+	return v, nil
+}
+
+func (p *parser) align() {
+	p.off = (p.off + 3) &^ 3
+}
+
+func (p *parser) parse() (*Info, error) {
+	info := &Info{}
+	// Cell sizes of the root node, which apply to the memory nodes.
+	addrCells, sizeCells := uint32(2), uint32(1)
+	var path []string
+	var memRegs [][]byte
+
+	for {
+		token, err := p.u32()
+		if err != nil {
+			return nil, err
+		}
+		switch token {
+		case fdtBeginNode:
+			end := bytes.IndexByte(p.data[p.off:], 0)
+			if end < 0 {
+				return nil, fmt.Errorf("%w: unterminated node name", ErrInvalid)
+			}
+			path = append(path, string(p.data[p.off:p.off+end]))
+			p.off += end + 1
+			p.align()
+		case fdtEndNode:
+			if len(path) == 0 {
+				return nil, fmt.Errorf("%w: unbalanced end of node", ErrInvalid)
+			}
+			path = path[:len(path)-1]
+		case fdtProp:
+			size, err := p.u32()
+			if err != nil {
+				return nil, err
+			}
+			nameOff, err := p.u32()
+			if err != nil {
+				return nil, err
+			}
+			if uint64(p.off)+uint64(size) > uint64(len(p.data)) {
+				return nil, fmt.Errorf("%w: property exceeds structure block", ErrInvalid)
+			}
+			value := p.data[p.off : p.off+int(size)]
+			p.off += int(size)
+			p.align()
+			name, err := p.str(nameOff)
+			if err != nil {
+				return nil, err
+			}
+
+			switch {
+			case len(path) == 1:
+				switch name {
+				case "model":
+					info.Model = strings.TrimRight(string(value), "\x00")
+				case "compatible":
+					info.Compatible = stringList(value)
+				case "#address-cells":
+					addrCells = cell(value)
+				case "#size-cells":
+					sizeCells = cell(value)
+				}
+			case len(path) == 2 && name == "reg" && isMemoryNode(path[1]):
+				memRegs = append(memRegs, value)
+			}
+		case fdtNop:
+		case fdtEnd:
+			for _, reg := range memRegs {
+				size, err := regSize(reg, addrCells, sizeCells)
+				if err != nil {
+					return nil, err
+				}
+				info.MemorySize += size
+			}
+			return info, nil
+		default:
+			return nil, fmt.Errorf("%w: unknown token 0x%x", ErrInvalid, token)
+		}
+	}
+}
+
+func (p *parser) str(off uint32) (string, error) {
+	if uint64(off) >= uint64(len(p.strings)) {
+		return "", fmt.Errorf("%w: property name outside strings block", ErrInvalid)
+	}
+	s := p.strings[off:]
+	if end := bytes.IndexByte(s, 0); end >= 0 {
+		s = s[:end]
+	}
+	return string(s), nil
+}
+
+func isMemoryNode(name string) bool {
+	return name == "memory" || strings.HasPrefix(name, "memory@")
+}
+
+func stringList(value []byte) []string {
+	var list []string
+	for _, s := range strings.Split(string(value), "\x00") {
+		if s != "" {
+			list = append(list, s)
+		}
+	}
+	return list
+}
+
+func cell(value []byte) uint32 {
+	if len(value) < 4 {
+		return 0
+	}
+	return binary.BigEndian.Uint32(value)
+}
+
+// regSize sums the sizes of the (address, size) pairs in a reg property.
+// Addresses and sizes span at most two cells.
+func regSize(reg []byte, addrCells, sizeCells uint32) (uint64, error) {
+	if addrCells > 2 || sizeCells > 2 {
+		return 0, fmt.Errorf("%w: %d address and %d size cells", ErrInvalid, addrCells, sizeCells)
+	}
+	addr, entry := int64(addrCells)*4, int64(addrCells+sizeCells)*4
+	if sizeCells == 0 {
+		return 0, nil
+	}
+	var total uint64
+	for off := int64(0); off+entry <= int64(len(reg)); off += entry {
+		var size uint64
+		for i := range int64(sizeCells) {
+			start := off + addr + i*4
+			if start+4 > int64(len(reg)) {
+				return 0, fmt.Errorf("%w: reg property truncated", ErrInvalid)
+			}
+			size = size<<32 | uint64(binary.BigEndian.Uint32(reg[start:start+4]))
+		}
+		total += size
+	}
+	return total, nil
+}
@@ -0,0 +1,191 @@
+package dtb
+
+import (
+	"encoding/binary"
+	"errors"
+	"os"
+	"reflect"
+	"testing"
+)
+
+// fdtBuilder assembles a flattened device tree for tests.
+type fdtBuilder struct {
+	structs []byte
+	strings []byte
+}
+
+func (b *fdtBuilder) token(t uint32) {
+	b.structs = binary.BigEndian.AppendUint32(b.structs, t)
+}
+
+func (b *fdtBuilder) pad() {
+	for len(b.structs)%4 != 0 {
+		b.structs = append(b.structs, 0)
+	}
+}
+
+func (b *fdtBuilder) begin(name string) {
+	b.token(fdtBeginNode)
+	b.structs = append(append(b.structs, name...), 0)
+	b.pad()
+}
+
+func (b *fdtBuilder) prop(name string, value []byte) {
+	b.token(fdtProp)
+	b.token(uint32(len(value)))
+	b.token(uint32(len(b.strings)))
+	b.strings = append(append(b.strings, name...), 0)
+	b.structs = append(b.structs, value...)
+	b.pad()
+}
+
+func (b *fdtBuilder) cells(v ...uint32) []byte {
+	var out []byte
+	for _, c := range v {
+		out = binary.BigEndian.AppendUint32(out, c)
+	}
+	return out
+}
+
+func (b *fdtBuilder) bytes() []byte {
+	b.token(fdtEnd)
+	hdr := make([]byte, fdtHeaderSize)
+	be := binary.BigEndian
+	be.PutUint32(hdr[0:], fdtMagic)
+	be.PutUint32(hdr[4:], uint32(fdtHeaderSize+len(b.structs)+len(b.strings)))
+	be.PutUint32(hdr[8:], fdtHeaderSize)
+	be.PutUint32(hdr[12:], uint32(fdtHeaderSize+len(b.structs)))
+	be.PutUint32(hdr[20:], 17)
+	be.PutUint32(hdr[32:], uint32(len(b.strings)))
+	be.PutUint32(hdr[36:], uint32(len(b.structs)))
+	return append(append(hdr, b.structs...), b.strings...)
+}
+
+func TestParse(t *testing.T) {
+	b := &fdtBuilder{}
+	b.begin("")
+	b.prop("#address-cells", b.cells(2))
+	b.prop("#size-cells", b.cells(1))
+	b.prop("model", []byte("Raspberry Pi 4 Model B\x00"))
+	b.prop("compatible", []byte("raspberrypi,4-model-b\x00brcm,bcm2711\x00"))
+	b.token(fdtNop)
+	b.begin("memory@0")
+	b.prop("device_type", []byte("memory\x00"))
+	b.prop("reg", b.cells(0, 0, 0x3b400000, 0, 0x40000000, 0xbc000000))
+	b.token(fdtEndNode)
+	b.begin("soc")
+	b.prop("model", []byte("ignored\x00"))
+	b.token(fdtEndNode)
+	b.token(fdtEndNode)
+
+	got, err := Parse(b.bytes())
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	want := &Info{
+		Model:      "Raspberry Pi 4 Model B",
+		Compatible: []string{"raspberrypi,4-model-b", "brcm,bcm2711"},
+		MemorySize: 0x3b400000 + 0xbc000000,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Parse() = %+v, want %+v", got, want)
+	}
+	if !got.IsCompatible("brcm,bcm2711") || got.IsCompatible("raspberrypi,400") {
+		t.Errorf("IsCompatible() gave unexpected results for %v", got.Compatible)
+	}
+}
+
+func TestParse_Embedded(t *testing.T) {
+	tests := []struct {
+		file  string
+		model string
+	}{
+		{"bcm2711-rpi-4-b.dtb", "Raspberry Pi 4 Model B"},
+		{"bcm2711-rpi-400.dtb", "Raspberry Pi 400"},
+		{"bcm2711-rpi-cm4.dtb", "Raspberry Pi Compute Module 4"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			data, err := os.ReadFile("../edk2/" + tt.file)
+			if err != nil {
+				t.Fatalf("failed to read %s: %v", tt.file, err)
+			}
+			info, err := Parse(data)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if info.Model != tt.model {
+				t.Errorf("Model = %q, want %q", info.Model, tt.model)
+			}
+			if !info.IsCompatible("brcm,bcm2711") {
+				t.Errorf("Compatible = %v, want brcm,bcm2711", info.Compatible)
+			}
+		})
+	}
+}
+
+func TestParse_Invalid(t *testing.T) {
+	b := &fdtBuilder{}
+	b.begin("")
+	b.prop("model", []byte("x\x00"))
+	b.token(fdtEndNode)
+	valid := b.bytes()
+
+	badToken := append([]byte{}, valid...)
+	binary.BigEndian.PutUint32(badToken[fdtHeaderSize:], 0x7)
+
+	// memory returns a blob with a memory node under a root with the given
+	// cell sizes.
+	memory := func(addrCells, sizeCells uint32) []byte {
+		b := &fdtBuilder{}
+		b.begin("")
+		b.prop("#address-cells", b.cells(addrCells))
+		b.prop("#size-cells", b.cells(sizeCells))
+		b.begin("memory@0")
+		b.prop("reg", b.cells(0, 0, 0x3b400000, 0, 0x40000000, 0xbc000000))
+		b.token(fdtEndNode)
+		b.token(fdtEndNode)
+		return b.bytes()
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"empty", nil},
+		{"bad magic", make([]byte, fdtHeaderSize)},
+		{"truncated", valid[:len(valid)-4]},
+		{"unknown token", badToken},
+		{"too many address cells", memory(3, 1)},
+		{"too many size cells", memory(2, 3)},
+		{"huge cell sizes", memory(0x40000000, 0x40000000)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Parse(tt.data); !errors.Is(err, ErrInvalid) {
+				t.Errorf("Parse() error = %v, want ErrInvalid", err)
+			}
+		})
+	}
+}
+
+func FuzzParse(f *testing.F) {
+	b := &fdtBuilder{}
+	b.begin("")
+	b.prop("model", []byte("Raspberry Pi 4 Model B\x00"))
+	b.prop("compatible", []byte("raspberrypi,4-model-b\x00brcm,bcm2711\x00"))
+	b.token(fdtEndNode)
+	f.Add(b.bytes())
+	f.Add([]byte{})
+	for _, file := range []string{"bcm2711-rpi-4-b.dtb", "bcm2711-rpi-400.dtb", "bcm2711-rpi-cm4.dtb"} {
+		data, err := os.ReadFile("../edk2/" + file)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(data)
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		_, _ = Parse(data)
+	})
+}
@@ -0,0 +1,80 @@
+//go:build !noembed
+
+package edk2
+
+import _ "embed"
+
+// Embedded reports whether the firmware files are embedded in the binary.
+// Builds with the noembed tag leave them out and load them at run time
+// with LoadDir or LoadFiles.
+const Embedded = true
+
+// RpiEfi returns the RPI_EFI.fd file.
+//
+//go:embed RPI_EFI.fd
+var RpiEfi []byte
+
+// FixupDat returns the fixup.dat file.
+//
+//go:embed fixup4.dat
+var Fixup4Dat []byte
+
+// Start4ElfDat returns the start4.elf file.
+//
+//go:embed start4.elf
+var Start4ElfDat []byte
+
+// Bcm2711Rpi4BDtb returns the bcm2711-rpi-4-b.dtb file.
+//
+//go:embed bcm2711-rpi-4-b.dtb
+var Bcm2711Rpi4BDtb []byte
+
+// Bcm2711Rpi400Dtb returns the bcm2711-rpi-400.dtb file.
+//
+//go:embed bcm2711-rpi-400.dtb
+var Bcm2711Rpi400Dtb []byte
+
+// Bcm2711RpiCm4Dtb returns the bcm2711-rpi-cm4.dtb file.
+//
+//go:embed bcm2711-rpi-cm4.dtb
+var Bcm2711RpiCm4Dtb []byte
+
+// OverlaysMiniUartBtDtbo returns the overlays/miniuart-bt.dtbo file.
+//
+//go:embed overlays/miniuart-bt.dtbo
+var OverlaysMiniUartBtDtbo []byte
+
+// OverlaysUpstreamPi4Dtbo returns the overlays/upstream-pi4.dtbo file.
+//
+//go:embed overlays/upstream-pi4.dtbo
+var OverlaysUpstreamPi4Dtbo []byte
+
+// OverlaysRpiPoePlusDtbo returns the overlays/rpi-poe-plus.dtbo file.
+//
+//go:embed overlays/rpi-poe-plus.dtbo
+var OverlaysRpiPoePlusDtbo []byte
+
+// FirmwareBrcmBrcmfmac43455SdioBin returns the firmware/brcm/brcmfmac43455-sdio.bin file.
+//
+//go:embed firmware/brcm/brcmfmac43455-sdio.bin
+var FirmwareBrcmBrcmfmac43455SdioBin []byte
+
+// FirmwareBrcmBrcmfmac43455SdioTxt returns the firmware/brcm/brcmfmac43455-sdio.txt file.
+//
+//go:embed firmware/brcm/brcmfmac43455-sdio.txt
+var FirmwareBrcmBrcmfmac43455SdioTxt []byte
+
+// FirmwareBrcmBrcmfmac43455SdioClmBlob returns the firmware/brcm/brcmfmac43455-sdio.clm_blob file.
+//
+//go:embed firmware/brcm/brcmfmac43455-sdio.clm_blob
+var FirmwareBrcmBrcmfmac43455SdioClmBlob []byte
+
+// FirmwareBrcmBrcmfmac43455SdioRaspberry returns the firmware/brcm/brcmfmac43455-sdio.Raspberry file.
+//
+//go:embed firmware/brcm/brcmfmac43455-sdio.Raspberry
+var FirmwareBrcmBrcmfmac43455SdioRaspberry []byte
+
+// ConfigTxt is the default configuration for the Raspberry Pi 4.
+//
+//go:embed config.txt
+var ConfigTxt []byte
@@ -0,0 +1,25 @@
+//go:build noembed
+
+package edk2
+
+// Embedded reports whether the firmware files are embedded in the binary.
+// Builds with the noembed tag leave them out and load them at run time
+// with LoadDir or LoadFiles.
+const Embedded = false
+
+var (
+	RpiEfi                                 []byte
+	Fixup4Dat                              []byte
+	Start4ElfDat                           []byte
+	Bcm2711Rpi4BDtb                        []byte
+	Bcm2711Rpi400Dtb                       []byte
+	Bcm2711RpiCm4Dtb                       []byte
+	OverlaysMiniUartBtDtbo                 []byte
+	OverlaysUpstreamPi4Dtbo                []byte
+	OverlaysRpiPoePlusDtbo                 []byte
+	FirmwareBrcmBrcmfmac43455SdioBin       []byte
+	FirmwareBrcmBrcmfmac43455SdioTxt       []byte
+	FirmwareBrcmBrcmfmac43455SdioClmBlob   []byte
+	FirmwareBrcmBrcmfmac43455SdioRaspberry []byte
+	ConfigTxt                              []byte
+)
@@ -0,0 +1,103 @@
+package edk2
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/metal3-community/uefi-firmware-manager/types"
+)
+
+// MergeConfigTxt merges the changes from base to upstream into local, line
+// by line, as done by diff3. Hunks changed both locally and upstream keep
+// the local lines, so operator edits are never lost, and are returned as
+// conflicts.
+func MergeConfigTxt(base, local, upstream []byte) ([]byte, []types.ConfigConflict) {
+	o, a, b := configLines(base), configLines(local), configLines(upstream)
+	matchA, matchB := matchLines(o, a), matchLines(o, b)
+
+	var merged []string
+	var conflicts []types.ConfigConflict
+	i, ia, ib := 0, 0, 0
+	for {
+		for i < len(o) && matchA[i] == ia && matchB[i] == ib {
+			merged = append(merged, o[i])
+			i, ia, ib = i+1, ia+1, ib+1
+		}
+		if i == len(o) && ia == len(a) && ib == len(b) {
+			break
+		}
+
+		// The hunk ends at the next base line kept on both sides.
+		j, ja, jb := i, len(a), len(b)
+		for ; j < len(o); j++ {
+			if matchA[j] >= 0 && matchB[j] >= 0 {
+				ja, jb = matchA[j], matchB[j]
+				break
+			}
+		}
+		hunkO, hunkA, hunkB := o[i:j], a[ia:ja], b[ib:jb]
+		switch {
+		case slices.Equal(hunkA, hunkO), slices.Equal(hunkA, hunkB):
+			merged = append(merged, hunkB...)
+		case slices.Equal(hunkB, hunkO):
+			merged = append(merged, hunkA...)
+		default:
+			conflicts = append(conflicts, types.ConfigConflict{
+				Line:     len(merged) + 1,
+				Base:     slices.Clone(hunkO),
+				Local:    slices.Clone(hunkA),
+				Upstream: slices.Clone(hunkB),
+			})
+			merged = append(merged, hunkA...)
+		}
+		i, ia, ib = j, ja, jb
+	}
+
+	if len(merged) == 0 {
+		return []byte{}, conflicts
+	}
+	return []byte(strings.Join(merged, "\n") + "\n"), conflicts
+}
+
+// configLines splits a config.txt into lines without their line endings.
+func configLines(data []byte) []string {
+	s := strings.TrimSuffix(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n")
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, "\n")
+}
+
+// matchLines returns, for every line of o, the index of the line of a it
+// is matched with in a longest common subsequence of both, or -1.
+func matchLines(o, a []string) []int {
+	// lcs[i][j] is the length of the LCS of o[i:] and a[j:].
+	lcs := make([][]int, len(o)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(a)+1)
+	}
+	for i := len(o) - 1; i >= 0; i-- {
+		for j := len(a) - 1; j >= 0; j-- {
+			if o[i] == a[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	match := make([]int, len(o))
+	for i, j := 0, 0; i < len(o); {
+		switch {
+		case j < len(a) && o[i] == a[j]:
+			match[i] = j
+			i, j = i+1, j+1
+		case j < len(a) && lcs[i][j+1] >= lcs[i+1][j]:
+			j++
+		default:
+			match[i] = -1
+			i++
+		}
+	}
+	return match
+}
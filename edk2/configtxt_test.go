@@ -0,0 +1,73 @@
+package edk2
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/metal3-community/uefi-firmware-manager/types"
+)
+
+func TestMergeConfigTxt(t *testing.T) {
+	const base = "arm_64bit=1\nenable_uart=1\n\n[pi4]\nenable_gic=1\ndtoverlay=upstream-pi4\n"
+	tests := []struct {
+		name      string
+		local     string
+		upstream  string
+		want      string
+		conflicts []types.ConfigConflict
+	}{
+		{
+			name:     "unchanged locally",
+			local:    base,
+			upstream: "arm_64bit=1\nenable_uart=1\n\n[pi4]\nenable_gic=1\ndtoverlay=upstream-pi4\ndtoverlay=disable-bt\n",
+			want:     "arm_64bit=1\nenable_uart=1\n\n[pi4]\nenable_gic=1\ndtoverlay=upstream-pi4\ndtoverlay=disable-bt\n",
+		},
+		{
+			name:     "unchanged upstream",
+			local:    "arm_64bit=1\nenable_uart=0\n\n[pi4]\nenable_gic=1\ndtoverlay=upstream-pi4\n",
+			upstream: base,
+			want:     "arm_64bit=1\nenable_uart=0\n\n[pi4]\nenable_gic=1\ndtoverlay=upstream-pi4\n",
+		},
+		{
+			name:     "separate changes",
+			local:    "arm_64bit=1\nenable_uart=1\ngpu_mem=16\n\n[pi4]\nenable_gic=1\ndtoverlay=upstream-pi4\n",
+			upstream: "arm_64bit=1\nenable_uart=1\n\n[pi4]\nenable_gic=1\ndtoverlay=upstream-pi4\ndtoverlay=miniuart-bt\n",
+			want:     "arm_64bit=1\nenable_uart=1\ngpu_mem=16\n\n[pi4]\nenable_gic=1\ndtoverlay=upstream-pi4\ndtoverlay=miniuart-bt\n",
+		},
+		{
+			name:     "same change",
+			local:    "arm_64bit=1\nenable_uart=1\n\n[pi4]\ndtoverlay=upstream-pi4\n",
+			upstream: "arm_64bit=1\nenable_uart=1\n\n[pi4]\ndtoverlay=upstream-pi4\n",
+			want:     "arm_64bit=1\nenable_uart=1\n\n[pi4]\ndtoverlay=upstream-pi4\n",
+		},
+		{
+			name:     "conflict keeps local",
+			local:    "arm_64bit=1\nenable_uart=0\n\n[pi4]\nenable_gic=1\ndtoverlay=upstream-pi4\n",
+			upstream: "arm_64bit=1\nenable_uart=1\nuart_2ndstage=1\n\n[pi4]\nenable_gic=1\ndtoverlay=upstream-pi4\n",
+			want:     "arm_64bit=1\nenable_uart=0\n\n[pi4]\nenable_gic=1\ndtoverlay=upstream-pi4\n",
+			conflicts: []types.ConfigConflict{{
+				Line:     2,
+				Base:     []string{"enable_uart=1"},
+				Local:    []string{"enable_uart=0"},
+				Upstream: []string{"enable_uart=1", "uart_2ndstage=1"},
+			}},
+		},
+		{
+			name:     "CRLF local",
+			local:    "arm_64bit=1\r\nenable_uart=1\r\n\r\n[pi4]\r\nenable_gic=1\r\ndtoverlay=upstream-pi4\r\n",
+			upstream: "arm_64bit=1\n\n[pi4]\nenable_gic=1\ndtoverlay=upstream-pi4\n",
+			want:     "arm_64bit=1\n\n[pi4]\nenable_gic=1\ndtoverlay=upstream-pi4\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, conflicts := MergeConfigTxt([]byte(base), []byte(tt.local), []byte(tt.upstream))
+			if string(got) != tt.want {
+				t.Errorf("MergeConfigTxt() = %q, want %q", got, tt.want)
+			}
+			if !reflect.DeepEqual(conflicts, tt.conflicts) {
+				t.Errorf("MergeConfigTxt() conflicts = %+v, want %+v", conflicts, tt.conflicts)
+			}
+		})
+	}
+}
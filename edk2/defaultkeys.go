@@ -0,0 +1,41 @@
+package edk2
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/metal3-community/uefi-firmware-manager/types"
+)
+
+// ErrNoDefaultKeys is returned by DefaultKeys when the package was built
+// without the defaultkeys build tag.
+var ErrNoDefaultKeys = errors.New("default secure boot keys not included; build with -tags defaultkeys")
+
+// DefaultKeys returns the default KEK, db and dbx of the Raspberry Pi
+// firmware: the Microsoft KEK and UEFI CA certificates and the current
+// revocation list. The key files are only embedded when building with the
+// defaultkeys build tag. The set has no platform key, which belongs to the
+// owner of the platform.
+func DefaultKeys() (types.SecureBootKeySet, error) {
+	var ks types.SecureBootKeySet
+	if defaultKEK == nil {
+		return ks, ErrNoDefaultKeys
+	}
+
+	for _, v := range []struct {
+		name string
+		data []byte
+		keys *[]types.SecureBootKey
+	}{
+		{"KEK", defaultKEK, &ks.KEK},
+		{"db", defaultDB, &ks.DB},
+		{"dbx", defaultDBX, &ks.DBX},
+	} {
+		keys, err := types.ParseSignatureDatabase(v.data)
+		if err != nil {
+			return ks, fmt.Errorf("default %s: %w", v.name, err)
+		}
+		*v.keys = keys
+	}
+	return ks, nil
+}
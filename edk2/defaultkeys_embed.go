@@ -0,0 +1,22 @@
+//go:build defaultkeys
+
+package edk2
+
+import _ "embed"
+
+// defaultKEK holds the Microsoft KEK CA 2011 and KEK 2K CA 2023
+// certificates.
+//
+//go:embed keys/KEK.esl
+var defaultKEK []byte
+
+// defaultDB holds the Microsoft Windows Production PCA 2011, UEFI CA 2011,
+// Windows UEFI CA 2023 and UEFI CA 2023 certificates.
+//
+//go:embed keys/db.esl
+var defaultDB []byte
+
+// defaultDBX holds the revoked certificates and image hashes.
+//
+//go:embed keys/dbx.esl
+var defaultDBX []byte
@@ -0,0 +1,5 @@
+//go:build !defaultkeys
+
+package edk2
+
+var defaultKEK, defaultDB, defaultDBX []byte
@@ -0,0 +1,39 @@
+package edk2
+
+import (
+	"crypto/x509"
+	"errors"
+	"testing"
+
+	"github.com/metal3-community/uefi-firmware-manager/efi"
+)
+
+func TestDefaultKeys(t *testing.T) {
+	ks, err := DefaultKeys()
+	if errors.Is(err, ErrNoDefaultKeys) {
+		t.Skip("built without the defaultkeys tag")
+	}
+	if err != nil {
+		t.Fatalf("DefaultKeys() error = %v", err)
+	}
+	if err := ks.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+
+	if len(ks.PK) != 0 {
+		t.Errorf("DefaultKeys() has %d platform keys, want none", len(ks.PK))
+	}
+	if len(ks.KEK) != 2 || len(ks.DB) != 4 || len(ks.DBX) == 0 {
+		t.Errorf("DefaultKeys() has %d KEK, %d db and %d dbx keys",
+			len(ks.KEK), len(ks.DB), len(ks.DBX))
+	}
+	for _, key := range append(ks.KEK, ks.DB...) {
+		cert, err := x509.ParseCertificate(key.Data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if key.Owner != efi.MicrosoftVendor {
+			t.Errorf("%s owner = %s, want %s", cert.Subject.CommonName, key.Owner, efi.MicrosoftVendor)
+		}
+	}
+}
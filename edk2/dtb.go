@@ -0,0 +1,30 @@
+package edk2
+
+import (
+	"fmt"
+
+	"github.com/metal3-community/uefi-firmware-manager/dtb"
+)
+
+// BoardCompatible maps the embedded dtb files to the compatible string of
+// the board they describe.
+var BoardCompatible = map[string]string{
+	"bcm2711-rpi-4-b.dtb": "raspberrypi,4-model-b",
+	"bcm2711-rpi-400.dtb": "raspberrypi,400",
+	"bcm2711-rpi-cm4.dtb": "raspberrypi,4-compute-module",
+}
+
+// ValidateDtb checks that the dtb stored under name describes the board the
+// name refers to. Names without a known board are only checked to parse.
+func ValidateDtb(name string, data []byte) error {
+	info, err := dtb.Parse(data)
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	compat, ok := BoardCompatible[name]
+	if ok && !info.IsCompatible(compat) {
+		return fmt.Errorf("%s: device tree for %q is not compatible with %s",
+			name, info.Model, compat)
+	}
+	return nil
+}
@@ -0,0 +1,24 @@
+package edk2
+
+import "testing"
+
+func TestValidateDtb(t *testing.T) {
+	if !Embedded {
+		t.Skip("built with the noembed tag")
+	}
+	for name := range BoardCompatible {
+		if err := ValidateDtb(name, Files[name]); err != nil {
+			t.Errorf("ValidateDtb(%s) error = %v", name, err)
+		}
+	}
+
+	if err := ValidateDtb("bcm2711-rpi-4-b.dtb", Bcm2711Rpi400Dtb); err == nil {
+		t.Error("ValidateDtb() accepted the Pi 400 device tree as a Pi 4 Model B")
+	}
+	if err := ValidateDtb("custom.dtb", Bcm2711RpiCm4Dtb); err != nil {
+		t.Errorf("ValidateDtb(custom.dtb) error = %v", err)
+	}
+	if err := ValidateDtb("custom.dtb", ConfigTxt); err == nil {
+		t.Error("ValidateDtb() accepted a non dtb file")
+	}
+}
@@ -1,7 +1,6 @@
 package edk2
 
 import (
-	_ "embed"
 	"fmt"
 	"net"
 
@@ -11,94 +10,67 @@ import (
 
 const FirmwareFileName = "RPI_EFI.fd"
 
-// RpiEfi returns the RPI_EFI.fd file.
-//
-//go:embed RPI_EFI.fd
-var RpiEfi []byte
-
-// FixupDat returns the fixup.dat file.
-//
-//go:embed fixup4.dat
-var Fixup4Dat []byte
-
-// Start4ElfDat returns the start4.elf file.
-//
-//go:embed start4.elf
-var Start4ElfDat []byte
-
-// Bcm2711Rpi4BDtb returns the bcm2711-rpi-4-b.dtb file.
-//
-//go:embed bcm2711-rpi-4-b.dtb
-var Bcm2711Rpi4BDtb []byte
-
-// Bcm2711Rpi400Dtb returns the bcm2711-rpi-400.dtb file.
-//
-//go:embed bcm2711-rpi-400.dtb
-var Bcm2711Rpi400Dtb []byte
-
-// Bcm2711RpiCm4Dtb returns the bcm2711-rpi-cm4.dtb file.
-//
-//go:embed bcm2711-rpi-cm4.dtb
-var Bcm2711RpiCm4Dtb []byte
-
-// OverlaysMiniUartBtDtbo returns the overlays/miniuart-bt.dtbo file.
-//
-//go:embed overlays/miniuart-bt.dtbo
-var OverlaysMiniUartBtDtbo []byte
-
-// OverlaysUpstreamPi4Dtbo returns the overlays/upstream-pi4.dtbo file.
-//
-//go:embed overlays/upstream-pi4.dtbo
-var OverlaysUpstreamPi4Dtbo []byte
-
-// OverlaysRpiPoePlusDtbo returns the overlays/rpi-poe-plus.dtbo file.
-//
-//go:embed overlays/rpi-poe-plus.dtbo
-var OverlaysRpiPoePlusDtbo []byte
-
-// FirmwareBrcmBrcmfmac43455SdioBin returns the firmware/brcm/brcmfmac43455-sdio.bin file.
-//
-//go:embed firmware/brcm/brcmfmac43455-sdio.bin
-var FirmwareBrcmBrcmfmac43455SdioBin []byte
-
-// FirmwareBrcmBrcmfmac43455SdioTxt returns the firmware/brcm/brcmfmac43455-sdio.txt file.
-//
-//go:embed firmware/brcm/brcmfmac43455-sdio.txt
-var FirmwareBrcmBrcmfmac43455SdioTxt []byte
-
-// FirmwareBrcmBrcmfmac43455SdioClmBlob returns the firmware/brcm/brcmfmac43455-sdio.clm_blob file.
-//
-//go:embed firmware/brcm/brcmfmac43455-sdio.clm_blob
-var FirmwareBrcmBrcmfmac43455SdioClmBlob []byte
+// Files is the mapping to the embedded iPXE binaries. In builds with the
+// noembed tag it only holds the files loaded with LoadDir or LoadFiles.
+var Files = files()
+
+// blobs maps the names of Files to the variables holding them.
+var blobs = map[string]*[]byte{
+	FirmwareFileName:               &RpiEfi,
+	"fixup4.dat":                   &Fixup4Dat,
+	"start4.elf":                   &Start4ElfDat,
+	"bcm2711-rpi-4-b.dtb":          &Bcm2711Rpi4BDtb,
+	"bcm2711-rpi-400.dtb":          &Bcm2711Rpi400Dtb,
+	"bcm2711-rpi-cm4.dtb":          &Bcm2711RpiCm4Dtb,
+	"miniuart-bt.dtbo":             &OverlaysMiniUartBtDtbo,
+	"upstream-pi4.dtbo":            &OverlaysUpstreamPi4Dtbo,
+	"rpi-poe-plus.dtbo":            &OverlaysRpiPoePlusDtbo,
+	"brcmfmac43455-sdio.bin":       &FirmwareBrcmBrcmfmac43455SdioBin,
+	"brcmfmac43455-sdio.txt":       &FirmwareBrcmBrcmfmac43455SdioTxt,
+	"brcmfmac43455-sdio.clm_blob":  &FirmwareBrcmBrcmfmac43455SdioClmBlob,
+	"brcmfmac43455-sdio.Raspberry": &FirmwareBrcmBrcmfmac43455SdioRaspberry,
+	"config.txt":                   &ConfigTxt,
+}
 
-// FirmwareBrcmBrcmfmac43455SdioRaspberry returns the firmware/brcm/brcmfmac43455-sdio.Raspberry file.
-//
-//go:embed firmware/brcm/brcmfmac43455-sdio.Raspberry
-var FirmwareBrcmBrcmfmac43455SdioRaspberry []byte
+// files returns the mapping of Files for the current blobs, leaving out
+// those that are not loaded.
+func files() map[string][]byte {
+	files := map[string][]byte{
+		"cmdline.txt": []byte(""),
+		"bootcfg.txt": []byte(""),
+	}
+	for name, blob := range blobs {
+		if *blob != nil {
+			files[name] = *blob
+		}
+	}
+	return files
+}
 
-// ConfigTxt is the default configuration for the Raspberry Pi 4.
-//
-//go:embed config.txt
-var ConfigTxt []byte
+// Dirs maps the files of Files that live in a subdirectory of the boot
+// partition to that directory.
+var Dirs = map[string]string{
+	"miniuart-bt.dtbo":             "overlays",
+	"upstream-pi4.dtbo":            "overlays",
+	"rpi-poe-plus.dtbo":            "overlays",
+	"brcmfmac43455-sdio.bin":       "firmware/brcm",
+	"brcmfmac43455-sdio.txt":       "firmware/brcm",
+	"brcmfmac43455-sdio.clm_blob":  "firmware/brcm",
+	"brcmfmac43455-sdio.Raspberry": "firmware/brcm",
+}
 
-// Files is the mapping to the embedded iPXE binaries.
-var Files = map[string][]byte{
-	FirmwareFileName:               RpiEfi,
-	"fixup4.dat":                   Fixup4Dat,
-	"start4.elf":                   Start4ElfDat,
-	"bcm2711-rpi-4-b.dtb":          Bcm2711Rpi4BDtb,
-	"bcm2711-rpi-400.dtb":          Bcm2711Rpi400Dtb,
-	"bcm2711-rpi-cm4.dtb":          Bcm2711RpiCm4Dtb,
-	"miniuart-bt.dtbo":             OverlaysMiniUartBtDtbo,
-	"upstream-pi4.dtbo":            OverlaysUpstreamPi4Dtbo,
-	"rpi-poe-plus.dtbo":            OverlaysRpiPoePlusDtbo,
-	"brcmfmac43455-sdio.bin":       FirmwareBrcmBrcmfmac43455SdioBin,
-	"brcmfmac43455-sdio.txt":       FirmwareBrcmBrcmfmac43455SdioTxt,
-	"brcmfmac43455-sdio.clm_blob":  FirmwareBrcmBrcmfmac43455SdioClmBlob,
-	"brcmfmac43455-sdio.Raspberry": FirmwareBrcmBrcmfmac43455SdioRaspberry,
-	"config.txt":                   ConfigTxt,
-	"cmdline.txt":                  []byte(""),
-	"bootcfg.txt":                  []byte(""),
+// BootFiles returns Files keyed by their path on the boot partition, e.g.
+// overlays/upstream-pi4.dtbo, with RPI_EFI.fd replaced by firmware.
+func BootFiles(firmware []byte) map[string][]byte {
+	files := make(map[string][]byte, len(Files))
+	for name, data := range Files {
+		if dir, ok := Dirs[name]; ok {
+			name = dir + "/" + name
+		}
+		files[name] = data
+	}
+	files[FirmwareFileName] = firmware
+	return files
 }
 
 func Read(macAddr net.HardwareAddr) ([]byte, error) {
@@ -126,8 +98,8 @@ func Read(macAddr net.HardwareAddr) ([]byte, error) {
 	}
 
 	// Set variables using pre-computed templates
-	vl["Boot0099"] = bootOption
-	vl["BootNext"] = bootNextTemplate
+	vl.Set(bootOption)
+	vl.Set(bootNextTemplate)
 
 	return vs.ReadAll(vl)
 }
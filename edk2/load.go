@@ -0,0 +1,66 @@
+package edk2
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// ErrNotLoaded is returned when the firmware files are needed but were
+// neither embedded nor loaded with LoadDir or LoadFiles.
+var ErrNotLoaded = errors.New("EDK2 firmware files not loaded; built with -tags noembed")
+
+// Loaded reports whether RPI_EFI.fd is available, either embedded or
+// loaded at run time.
+func Loaded() bool {
+	return RpiEfi != nil
+}
+
+// LoadDir loads the firmware files from dir, laid out as on the boot
+// partition (see BootFiles), for instance an extracted release or a bundle
+// pulled with the fetch package. RPI_EFI.fd is required; the other files
+// are optional and, if missing, keep their current contents.
+//
+// LoadDir replaces embedded files too. It is not safe for concurrent use
+// and must be called before the files are used.
+func LoadDir(dir string) error {
+	files := map[string][]byte{}
+	for name := range blobs {
+		path := name
+		if d, ok := Dirs[name]; ok {
+			path = d + "/" + name
+		}
+		data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(path)))
+		if errors.Is(err, fs.ErrNotExist) && name != FirmwareFileName {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to load firmware file: %w", err)
+		}
+		files[path] = data
+	}
+	return LoadFiles(files)
+}
+
+// LoadFiles loads the firmware files from bootFiles, keyed by their path
+// on the boot partition as returned by BootFiles. Files of other names are
+// ignored. Like LoadDir it requires RPI_EFI.fd and must be called before
+// the files are used.
+func LoadFiles(bootFiles map[string][]byte) error {
+	if bootFiles[FirmwareFileName] == nil {
+		return fmt.Errorf("failed to load firmware files: %s: %w", FirmwareFileName, fs.ErrNotExist)
+	}
+	for name, blob := range blobs {
+		path := name
+		if d, ok := Dirs[name]; ok {
+			path = d + "/" + name
+		}
+		if data, ok := bootFiles[path]; ok {
+			*blob = data
+		}
+	}
+	Files = files()
+	return nil
+}
@@ -0,0 +1,100 @@
+package edk2
+
+import (
+	"bytes"
+	"errors"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// restoreBlobs restores the firmware files when the test ends.
+func restoreBlobs(t *testing.T) {
+	saved := map[*[]byte][]byte{}
+	for _, blob := range blobs {
+		saved[blob] = *blob
+	}
+	savedFiles := Files
+	t.Cleanup(func() {
+		for blob, data := range saved {
+			*blob = data
+		}
+		Files = savedFiles
+	})
+}
+
+func TestLoadDir(t *testing.T) {
+	restoreBlobs(t)
+	for _, blob := range blobs {
+		*blob = nil
+	}
+	Files = files()
+
+	dir := t.TempDir()
+	for path, data := range map[string][]byte{
+		FirmwareFileName:                       []byte("firmware"),
+		"overlays/upstream-pi4.dtbo":           []byte("overlay"),
+		"firmware/brcm/brcmfmac43455-sdio.bin": []byte("wifi"),
+		"unrelated.txt":                        []byte("ignored"),
+	} {
+		path = filepath.Join(dir, path)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := LoadDir(dir); err != nil {
+		t.Fatalf("LoadDir() error = %v", err)
+	}
+	if !Loaded() || string(RpiEfi) != "firmware" {
+		t.Errorf("RpiEfi = %q, want the loaded firmware", RpiEfi)
+	}
+	if string(OverlaysUpstreamPi4Dtbo) != "overlay" || string(FirmwareBrcmBrcmfmac43455SdioBin) != "wifi" {
+		t.Errorf("overlay = %q, wifi = %q", OverlaysUpstreamPi4Dtbo, FirmwareBrcmBrcmfmac43455SdioBin)
+	}
+	if Start4ElfDat != nil {
+		t.Errorf("start4.elf = %q, want it not loaded", Start4ElfDat)
+	}
+
+	got := BootFiles([]byte("patched"))
+	want := map[string][]byte{
+		FirmwareFileName:                       []byte("patched"),
+		"overlays/upstream-pi4.dtbo":           []byte("overlay"),
+		"firmware/brcm/brcmfmac43455-sdio.bin": []byte("wifi"),
+		"cmdline.txt":                          []byte(""),
+		"bootcfg.txt":                          []byte(""),
+	}
+	if !maps.EqualFunc(got, want, bytes.Equal) {
+		t.Errorf("BootFiles() = %q, want %q", got, want)
+	}
+}
+
+func TestLoadDirMissingFirmware(t *testing.T) {
+	restoreBlobs(t)
+	err := LoadDir(t.TempDir())
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("LoadDir() error = %v, want fs.ErrNotExist", err)
+	}
+	if Embedded && !Loaded() {
+		t.Error("failed LoadDir() dropped the embedded firmware")
+	}
+}
+
+func TestLoadFilesKeepsOthers(t *testing.T) {
+	restoreBlobs(t)
+	config := ConfigTxt
+	if err := LoadFiles(map[string][]byte{FirmwareFileName: []byte("firmware")}); err != nil {
+		t.Fatalf("LoadFiles() error = %v", err)
+	}
+	if string(Files[FirmwareFileName]) != "firmware" {
+		t.Errorf("Files[%s] = %q, want the loaded firmware", FirmwareFileName, Files[FirmwareFileName])
+	}
+	if !bytes.Equal(ConfigTxt, config) {
+		t.Error("LoadFiles() replaced config.txt it was not given")
+	}
+}
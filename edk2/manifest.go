@@ -0,0 +1,70 @@
+package edk2
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/metal3-community/uefi-firmware-manager/types"
+)
+
+// UpstreamRelease is the Raspberry Pi 4 UEFI firmware release the
+// embedded files were taken from, as downloaded by script/get-firmware.sh.
+const UpstreamRelease = "v1.38"
+
+// ErrDrift is returned by Drift when the firmware files differ from an
+// upstream release.
+var ErrDrift = errors.New("firmware files differ from upstream")
+
+// Manifest returns the name, size, SHA-256 and upstream version of every
+// embedded or loaded firmware file, ordered by name. Names are paths on
+// the boot partition, as for BootFiles. The version of RPI_EFI.fd is read
+// from the image; the other files are attributed to UpstreamRelease.
+func Manifest() []types.FirmwareAsset {
+	manifest := make([]types.FirmwareAsset, 0, len(blobs))
+	for name, blob := range blobs {
+		if *blob == nil {
+			continue
+		}
+		version := UpstreamRelease
+		if name == FirmwareFileName {
+			if build, err := ScanVersion(*blob); err == nil && build.Version != "" {
+				version = build.Version
+			}
+		}
+		if dir, ok := Dirs[name]; ok {
+			name = dir + "/" + name
+		}
+		sum := sha256.Sum256(*blob)
+		manifest = append(manifest, types.FirmwareAsset{
+			Name:    name,
+			Size:    int64(len(*blob)),
+			SHA256:  hex.EncodeToString(sum[:]),
+			Version: version,
+		})
+	}
+	sort.Slice(manifest, func(i, j int) bool { return manifest[i].Name < manifest[j].Name })
+	return manifest
+}
+
+// Drift compares the firmware files of Manifest to those of an upstream
+// release, keyed by their path on the boot partition, e.g. as extracted
+// from the release archive. Files of the release that are not served are
+// ignored. Every missing or different file is reported; each wraps
+// ErrDrift.
+func Drift(upstream map[string][]byte) error {
+	var errs []error
+	for _, asset := range Manifest() {
+		data, ok := upstream[asset.Name]
+		if !ok {
+			errs = append(errs, fmt.Errorf("%w: %s is not in the release", ErrDrift, asset.Name))
+			continue
+		}
+		if sum := sha256.Sum256(data); hex.EncodeToString(sum[:]) != asset.SHA256 {
+			errs = append(errs, fmt.Errorf("%w: %s differs from the release", ErrDrift, asset.Name))
+		}
+	}
+	return errors.Join(errs...)
+}
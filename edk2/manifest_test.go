@@ -0,0 +1,97 @@
+package edk2
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestManifest(t *testing.T) {
+	restoreBlobs(t)
+	if err := LoadFiles(map[string][]byte{
+		FirmwareFileName:             []byte("firmware"),
+		"overlays/upstream-pi4.dtbo": []byte("overlay"),
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	manifest := Manifest()
+	if Embedded && len(manifest) != len(blobs) {
+		t.Errorf("Manifest() has %d files, want %d", len(manifest), len(blobs))
+	}
+	for i := 1; i < len(manifest); i++ {
+		if manifest[i-1].Name >= manifest[i].Name {
+			t.Errorf("Manifest() is not ordered by name: %s before %s", manifest[i-1].Name, manifest[i].Name)
+		}
+	}
+
+	found := map[string]bool{}
+	for _, asset := range manifest {
+		found[asset.Name] = true
+		if asset.Version == "" {
+			t.Errorf("%s has no version", asset.Name)
+		}
+		if asset.Name != "overlays/upstream-pi4.dtbo" {
+			continue
+		}
+		sum := sha256.Sum256([]byte("overlay"))
+		if asset.Size != 7 || asset.SHA256 != hex.EncodeToString(sum[:]) || asset.Version != UpstreamRelease {
+			t.Errorf("overlay asset = %+v", asset)
+		}
+	}
+	if !found[FirmwareFileName] || !found["overlays/upstream-pi4.dtbo"] {
+		t.Errorf("Manifest() = %+v, want RPI_EFI.fd and the overlay", manifest)
+	}
+	if found["cmdline.txt"] {
+		t.Error("Manifest() lists the generated cmdline.txt")
+	}
+}
+
+func TestManifestFirmwareVersion(t *testing.T) {
+	if !Embedded {
+		t.Skip("built with the noembed tag")
+	}
+	for _, asset := range Manifest() {
+		if asset.Name == FirmwareFileName && !strings.HasPrefix(asset.Version, "UEFI Firmware v") {
+			t.Errorf("RPI_EFI.fd version = %q, want the image firmware version", asset.Version)
+		}
+	}
+}
+
+func TestDrift(t *testing.T) {
+	restoreBlobs(t)
+	for _, blob := range blobs {
+		*blob = nil
+	}
+	if err := LoadFiles(map[string][]byte{
+		FirmwareFileName:             []byte("firmware"),
+		"overlays/upstream-pi4.dtbo": []byte("overlay"),
+		"config.txt":                 []byte("config"),
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Drift(map[string][]byte{
+		FirmwareFileName:             []byte("firmware"),
+		"overlays/upstream-pi4.dtbo": []byte("overlay"),
+		"config.txt":                 []byte("config"),
+		"README.md":                  []byte("not served"),
+	}); err != nil {
+		t.Errorf("Drift() of the same files error = %v", err)
+	}
+
+	err := Drift(map[string][]byte{
+		FirmwareFileName: []byte("firmware"),
+		"config.txt":     []byte("changed"),
+	})
+	if !errors.Is(err, ErrDrift) {
+		t.Fatalf("Drift() error = %v, want ErrDrift", err)
+	}
+	for _, want := range []string{"overlays/upstream-pi4.dtbo is not in the release", "config.txt differs"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Drift() error = %v, want it to mention %q", err, want)
+		}
+	}
+}
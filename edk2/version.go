@@ -0,0 +1,54 @@
+package edk2
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+
+	"github.com/metal3-community/uefi-firmware-manager/smbios"
+	"github.com/metal3-community/uefi-firmware-manager/types"
+)
+
+// ErrNoVersion is returned by ScanVersion when an image carries no version
+// string.
+var ErrNoVersion = errors.New("no version string found in firmware image")
+
+var (
+	// buildIDPattern matches the git description of a build.
+	buildIDPattern = regexp.MustCompile(`^(v?[0-9]+(?:\.[0-9]+)+)(?:-[0-9]+-g([0-9a-f]{7,40}))?(-dirty)?$`)
+	// asciiVersionPattern matches firmware version strings stored as ASCII.
+	asciiVersionPattern = regexp.MustCompile(smbios.FirmwareVersionPrefix + `v?[0-9]+(?:\.[0-9]+)+[0-9A-Za-z.+-]*`)
+	// tfaVersionPattern matches the version banner of Trusted Firmware-A.
+	tfaVersionPattern = regexp.MustCompile(`v[0-9]+\.[0-9]+(?:\.[0-9]+)?\((?:release|debug)\):[0-9A-Za-z.+_-]+`)
+)
+
+// ScanVersion extracts the version information of a firmware image from
+// its contents, so that the version of arbitrary images can be reported.
+// The firmware version is read as for smbios.FirmwareInfo, falling back to
+// ASCII version strings, and is split into the release tag and commit of
+// the build. Most of an EDK2 image is compressed, so only the strings of
+// its uncompressed parts are found.
+func ScanVersion(image []byte) (types.FirmwareBuild, error) {
+	var build types.FirmwareBuild
+	if info, err := smbios.FirmwareInfo(image); err == nil && info.Version != "" {
+		build.Version = info.Version
+	} else if v := asciiVersionPattern.Find(image); v != nil {
+		build.Version = string(v)
+	}
+	if id, ok := strings.CutPrefix(build.Version, smbios.FirmwareVersionPrefix); ok {
+		if m := buildIDPattern.FindStringSubmatch(id); m != nil {
+			build.BuildID = id
+			build.Release = m[1]
+			build.Commit = m[2]
+			build.Dirty = m[3] != ""
+		}
+	}
+	if v := tfaVersionPattern.Find(image); v != nil {
+		build.TrustedFirmware = string(v)
+	}
+
+	if build.Version == "" && build.TrustedFirmware == "" {
+		return build, ErrNoVersion
+	}
+	return build, nil
+}
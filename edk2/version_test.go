@@ -0,0 +1,97 @@
+package edk2
+
+import (
+	"encoding/binary"
+	"errors"
+	"testing"
+	"unicode/utf16"
+
+	"github.com/metal3-community/uefi-firmware-manager/types"
+)
+
+// ucs2 returns s encoded as a NUL terminated UCS-2 string.
+func ucs2(s string) []byte {
+	var b []byte
+	for _, c := range utf16.Encode([]rune(s)) {
+		b = binary.LittleEndian.AppendUint16(b, c)
+	}
+	return append(b, 0, 0)
+}
+
+func TestScanVersion(t *testing.T) {
+	pad := make([]byte, 64)
+	tests := []struct {
+		name    string
+		image   []byte
+		want    types.FirmwareBuild
+		wantErr error
+	}{
+		{
+			name:  "release",
+			image: append(append(pad, ucs2("UEFI Firmware v1.38")...), pad...),
+			want:  types.FirmwareBuild{Version: "UEFI Firmware v1.38", BuildID: "v1.38", Release: "v1.38"},
+		},
+		{
+			name:  "build after a release",
+			image: append(pad, ucs2("UEFI Firmware v1.38-5-g1a2b3c4d-dirty")...),
+			want: types.FirmwareBuild{
+				Version: "UEFI Firmware v1.38-5-g1a2b3c4d-dirty",
+				BuildID: "v1.38-5-g1a2b3c4d-dirty",
+				Release: "v1.38",
+				Commit:  "1a2b3c4d",
+				Dirty:   true,
+			},
+		},
+		{
+			name:  "ASCII version and Trusted Firmware",
+			image: append(append(pad, "UEFI Firmware v1.35\x00"...), "NOTICE:  BL31: v2.9(release):v2.9\x00"...),
+			want: types.FirmwareBuild{
+				Version:         "UEFI Firmware v1.35",
+				BuildID:         "v1.35",
+				Release:         "v1.35",
+				TrustedFirmware: "v2.9(release):v2.9",
+			},
+		},
+		{
+			name:  "custom version",
+			image: append(pad, ucs2("UEFI Firmware lab build")...),
+			want:  types.FirmwareBuild{Version: "UEFI Firmware lab build"},
+		},
+		{
+			name:    "no version",
+			image:   pad,
+			wantErr: ErrNoVersion,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ScanVersion(tt.image)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ScanVersion() error = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ScanVersion() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScanVersionEmbedded(t *testing.T) {
+	if !Embedded {
+		t.Skip("built with the noembed tag")
+	}
+	got, err := ScanVersion(RpiEfi)
+	if err != nil {
+		t.Fatalf("ScanVersion() error = %v", err)
+	}
+	want := types.FirmwareBuild{
+		Version:         "UEFI Firmware v0.0.2-62-gc1c9118",
+		BuildID:         "v0.0.2-62-gc1c9118",
+		Release:         "v0.0.2",
+		Commit:          "c1c9118",
+		TrustedFirmware: "v2.9(release):v2.9",
+	}
+	if got != want {
+		t.Errorf("ScanVersion() = %+v, want %+v", got, want)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/binary"
 	"encoding/hex"
 	"fmt"
+	"math"
 )
 
 // BootEntry represents an EFI boot entry
@@ -40,7 +41,7 @@ func NewBootEntry(
 	}
 
 	if data != nil {
-		_ = entry.Parse(data)
+		_ = entry.ParseWithOptions(data, ParseOptions{})
 	}
 	if attr != 0 {
 		entry.Attr = attr
@@ -62,30 +63,63 @@ func NewBootEntry(
 	return entry
 }
 
-// Parse parses binary data into a BootEntry.
+// Parse parses an EFI_LOAD_OPTION into a BootEntry, rejecting malformed
+// input. It is equivalent to ParseWithOptions with StrictParseOptions.
 func (entry *BootEntry) Parse(data []byte) error {
-	if len(data) < 8 {
-		return fmt.Errorf("data too short to parse boot entry")
+	return entry.ParseWithOptions(data, StrictParseOptions)
+}
+
+// ParseWithOptions parses an EFI_LOAD_OPTION into a BootEntry.
+//
+// The layout is a UINT32 Attributes, a UINT16 FilePathListLength, a null
+// terminated CHAR16 Description, FilePathListLength bytes of device paths
+// and optional data filling the rest of the option. Only the first device
+// path instance of the file path list is kept.
+//
+// In lenient mode an unterminated description, a file path list that
+// overruns the option and malformed device path elements are tolerated.
+func (entry *BootEntry) ParseWithOptions(data []byte, opts ParseOptions) error {
+	if len(data) < 6 {
+		return fmt.Errorf("boot entry too short: %d bytes, need at least 6", len(data))
 	}
 
 	// Read the attribute and path size
 	entry.Attr = binary.LittleEndian.Uint32(data[0:4])
-	pathSize := binary.LittleEndian.Uint16(data[4:6])
+	pathSize := int(binary.LittleEndian.Uint16(data[4:6]))
+	entry.OptData = nil
 
 	// Parse the title
-	title := FromUCS16(data, 6)
-	titleSize := title.Size()
+	title, err := ParseUCS16(data, 6)
+	if err != nil {
+		if opts.Strict {
+			return fmt.Errorf("invalid boot entry description: %w", err)
+		}
+		title = FromUCS16(data, 6)
+	}
 	entry.Title = *title
 
 	// Extract and parse the device path
-	pathOffset := 6 + titleSize
-	if pathOffset+int(pathSize) > len(data) {
-		return fmt.Errorf("data too short for device path")
+	pathOffset := min(6+title.Size(), len(data))
+	if !opts.Strict {
+		pathSize = min(pathSize, len(data)-pathOffset)
+		entry.DevicePath = *NewDevicePath(data[pathOffset : pathOffset+pathSize])
+	} else {
+		if pathSize == 0 {
+			return fmt.Errorf("boot entry has an empty file path list")
+		}
+		if pathSize > len(data)-pathOffset {
+			return fmt.Errorf("file path list length %d exceeds the %d bytes after the description",
+				pathSize, len(data)-pathOffset)
+		}
+		path, err := parseFilePathList(data[pathOffset : pathOffset+pathSize])
+		if err != nil {
+			return fmt.Errorf("invalid file path list at offset %d: %w", pathOffset, err)
+		}
+		entry.DevicePath = *path
 	}
-	entry.DevicePath = *NewDevicePath(data[pathOffset : pathOffset+int(pathSize)])
 
 	// Extract optional data if present
-	optOffset := pathOffset + int(pathSize)
+	optOffset := pathOffset + pathSize
 	if optOffset < len(data) {
 		entry.OptData = data[optOffset:]
 	}
@@ -93,6 +127,34 @@ func (entry *BootEntry) Parse(data []byte) error {
 	return nil
 }
 
+// parseFilePathList parses the device path instances of a load option and
+// returns the first one. The list must end exactly at its last end node.
+func parseFilePathList(data []byte) (*DevicePath, error) {
+	first, err := ParseDevicePath(data)
+	if err != nil {
+		return nil, err
+	}
+
+	pos := 0
+	for pos < len(data) {
+		if len(data)-pos < 4 {
+			return nil, fmt.Errorf("truncated device path element at offset %d", pos)
+		}
+		size := int(binary.LittleEndian.Uint16(data[pos+2 : pos+4]))
+		if size < 4 || size > len(data)-pos {
+			return nil, fmt.Errorf("invalid device path element length %d at offset %d", size, pos)
+		}
+		pos += size
+		if DeviceType(data[pos-size]) == DevTypeEnd && data[pos-size+1] == devSubTypeEndEntire {
+			if pos != len(data) {
+				return nil, fmt.Errorf("%d bytes after the end of the device path", len(data)-pos)
+			}
+			return first, nil
+		}
+	}
+	return nil, fmt.Errorf("device path is not terminated")
+}
+
 // ParseBootEntry parses a boot entry from binary data.
 func ParseBootEntry(data []byte) (*BootEntry, error) {
 	entry := &BootEntry{}
@@ -100,6 +162,13 @@ func ParseBootEntry(data []byte) (*BootEntry, error) {
 	return entry, err
 }
 
+// ParseBootEntryWithOptions parses a boot entry from binary data using opts.
+func ParseBootEntryWithOptions(data []byte, opts ParseOptions) (*BootEntry, error) {
+	entry := &BootEntry{}
+	err := entry.ParseWithOptions(data, opts)
+	return entry, err
+}
+
 // Bytes returns the binary representation of the BootEntry.
 func (entry *BootEntry) Bytes() []byte {
 	var buf bytes.Buffer
@@ -125,6 +194,19 @@ func (entry *BootEntry) Bytes() []byte {
 	return buf.Bytes()
 }
 
+// Serialize returns the EFI_LOAD_OPTION for the entry. Unlike Bytes it
+// fails if the device path does not fit in the FilePathListLength field.
+func (entry *BootEntry) Serialize() ([]byte, error) {
+	pathData, err := entry.DevicePath.Serialize()
+	if err != nil {
+		return nil, err
+	}
+	if len(pathData) > math.MaxUint16 {
+		return nil, fmt.Errorf("device path too long for boot entry: %d bytes", len(pathData))
+	}
+	return entry.Bytes(), nil
+}
+
 // ToBytes is an alias for Bytes to maintain compatibility with tests.
 func (entry *BootEntry) ToBytes() ([]byte, error) {
 	return entry.Bytes(), nil
@@ -178,13 +260,13 @@ func (entry *BootEntry) SetHiddenStatus(hidden bool) {
 
 // GetCategory returns the category bits from the attributes.
 func (entry *BootEntry) GetCategory() uint32 {
-	return entry.Attr & LOAD_OPTION_CATEGORY_MASK
+	return entry.Attr & LOAD_OPTION_CATEGORY
 }
 
 // SetCategory sets the category bits in the attributes.
 func (entry *BootEntry) SetCategory(category uint32) {
 	// Clear category bits first
-	entry.Attr &= ^LOAD_OPTION_CATEGORY_MASK
+	entry.Attr &= ^uint32(LOAD_OPTION_CATEGORY)
 	// Set new category
 	entry.Attr |= category
 }
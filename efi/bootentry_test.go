@@ -0,0 +1,191 @@
+package efi
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/quick"
+)
+
+func TestParseBootEntry(t *testing.T) {
+	end := []byte{0x7f, 0xff, 0x04, 0x00}
+	fvName := []byte{0x04, 0x07, 0x14, 0x00}
+	fvName = append(fvName, StringToGUID("9a15aa37-d555-4a4e-b541-86391ff68164").Bytes()...)
+	option := func(attr uint32, title string, pathLen int, rest ...[]byte) []byte {
+		data := []byte{byte(attr), byte(attr >> 8), byte(attr >> 16), byte(attr >> 24)}
+		data = append(data, byte(pathLen), byte(pathLen>>8))
+		data = append(data, FromString(title).Bytes()...)
+		for _, r := range rest {
+			data = append(data, r...)
+		}
+		return data
+	}
+
+	tests := []struct {
+		name        string
+		data        []byte
+		wantTitle   string
+		wantPath    string
+		wantOptData []byte
+		wantErr     string
+	}{
+		{
+			name:      "minimal option",
+			data:      option(LOAD_OPTION_ACTIVE, "", 4, end),
+			wantTitle: "",
+			wantPath:  "",
+		},
+		{
+			name:        "option with optional data",
+			data:        option(LOAD_OPTION_ACTIVE, "UiApp", 24, fvName, end, []byte{0x4e, 0xac}),
+			wantTitle:   "UiApp",
+			wantPath:    "FvName(9a15aa37-d555-4a4e-b541-86391ff68164)",
+			wantOptData: []byte{0x4e, 0xac},
+		},
+		{
+			name:    "truncated header",
+			data:    []byte{0x01, 0x00, 0x00, 0x00, 0x04},
+			wantErr: "boot entry too short",
+		},
+		{
+			name:    "unterminated description",
+			data:    []byte{0x01, 0x00, 0x00, 0x00, 0x04, 0x00, 'U', 0x00},
+			wantErr: "invalid boot entry description",
+		},
+		{
+			name:    "empty file path list",
+			data:    option(LOAD_OPTION_ACTIVE, "UiApp", 0, end),
+			wantErr: "empty file path list",
+		},
+		{
+			name:    "file path list overruns option",
+			data:    option(LOAD_OPTION_ACTIVE, "UiApp", 8, end),
+			wantErr: "file path list length 8 exceeds the 4 bytes",
+		},
+		{
+			name:    "file path list without end node",
+			data:    option(LOAD_OPTION_ACTIVE, "UiApp", 20, fvName),
+			wantErr: "not terminated",
+		},
+		{
+			name:    "bad element length",
+			data:    option(LOAD_OPTION_ACTIVE, "UiApp", 4, []byte{0x04, 0x07, 0x02, 0x00}),
+			wantErr: "invalid device path element length 2",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseBootEntry(tt.data)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("ParseBootEntry() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseBootEntry() error = %v", err)
+			}
+			if got.Attr != LOAD_OPTION_ACTIVE {
+				t.Errorf("ParseBootEntry() Attr = %v, want %v", got.Attr, LOAD_OPTION_ACTIVE)
+			}
+			if got.Title.String() != tt.wantTitle {
+				t.Errorf("ParseBootEntry() Title = %v, want %v", got.Title.String(), tt.wantTitle)
+			}
+			if got.DevicePath.String() != tt.wantPath {
+				t.Errorf("ParseBootEntry() DevicePath = %v, want %v", got.DevicePath.String(), tt.wantPath)
+			}
+			if !reflect.DeepEqual(got.OptData, tt.wantOptData) {
+				t.Errorf("ParseBootEntry() OptData = %v, want %v", got.OptData, tt.wantOptData)
+			}
+			if !reflect.DeepEqual(got.Bytes(), tt.data) {
+				t.Errorf("ParseBootEntry().Bytes() = %x, want %x", got.Bytes(), tt.data)
+			}
+		})
+	}
+}
+
+func TestParseBootEntryWithOptions(t *testing.T) {
+	// Description "U" without its terminator and a file path list that
+	// overruns the option.
+	data := []byte{0x01, 0x00, 0x00, 0x00, 0x10, 0x00, 'U', 0x00}
+	if _, err := ParseBootEntryWithOptions(data, StrictParseOptions); err == nil {
+		t.Error("ParseBootEntryWithOptions() strict error = nil")
+	}
+	got, err := ParseBootEntryWithOptions(data, ParseOptions{})
+	if err != nil {
+		t.Fatalf("ParseBootEntryWithOptions() lenient error = %v", err)
+	}
+	if got.Title.String() != "U" || got.Attr != LOAD_OPTION_ACTIVE {
+		t.Errorf("ParseBootEntryWithOptions() = %v, want title U", got)
+	}
+}
+
+func TestBootEntry_SerializeRoundTrip(t *testing.T) {
+	for _, v := range corpusVariables(t) {
+		if _, ok := bootEntryIndex(v.Name.String()); !ok {
+			continue
+		}
+		entry, err := ParseBootEntry(v.Data)
+		if err != nil {
+			t.Fatalf("ParseBootEntry() %s error = %v", v.Name, err)
+		}
+		got, err := entry.Serialize()
+		if err != nil {
+			t.Fatalf("BootEntry.Serialize() %s error = %v", v.Name, err)
+		}
+		if !bytes.Equal(got, v.Data) {
+			t.Errorf("Serialize(ParseBootEntry()) %s = %x, want %x", v.Name, got, v.Data)
+		}
+	}
+
+	property := func(attr uint32, title, path string, optData []byte) bool {
+		ucs, err := UCS16FromString(title)
+		if err != nil || strings.ContainsRune(path, 0) {
+			return true
+		}
+		entry := &BootEntry{
+			Attr:       attr,
+			Title:      *ucs,
+			DevicePath: *DevicePathFilepath(path),
+			OptData:    optData,
+		}
+		blob, err := entry.Serialize()
+		if err != nil {
+			return false
+		}
+		got, err := ParseBootEntry(blob)
+		if err != nil || got.Attr != attr || got.Title.String() != title ||
+			!got.DevicePath.Equal(&entry.DevicePath) || !bytes.Equal(got.OptData, optData) {
+			return false
+		}
+		again, err := got.Serialize()
+		return err == nil && bytes.Equal(again, blob)
+	}
+	if err := quick.Check(property, nil); err != nil {
+		t.Error(err)
+	}
+}
+
+func FuzzParseBootEntry(f *testing.F) {
+	title := FromString("UEFI Shell")
+	path := &DevicePath{elems: []*DevicePathElem{}}
+	path.FvName("9e21fd93-9c72-4c15-8c4b-e77f1db2d792").FVFileName("7c04a583-9e3e-4f1c-ad65-e05268d0b4d1")
+	f.Add(NewBootEntry(nil, LOAD_OPTION_ACTIVE, title, path, nil).Bytes())
+	f.Add(NewBootEntry(nil, LOAD_OPTION_ACTIVE, title, path, &[]byte{0x4e, 0xac}).Bytes())
+	f.Add([]byte{0x01, 0x00, 0x00, 0x00, 0x04, 0x00, 0x00, 0x00, 0x7f, 0xff, 0x04, 0x00})
+	f.Add([]byte{})
+	addCorpusSeeds(f, func(name string) bool {
+		_, ok := bootEntryIndex(name)
+		return ok
+	})
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		entry, err := ParseBootEntry(data)
+		if err != nil {
+			return
+		}
+		_ = entry.String()
+		_ = entry.Bytes()
+	})
+}
@@ -0,0 +1,179 @@
+package efi
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"unicode/utf8"
+)
+
+// BootMetadataGuid starts the optional data of a boot option holding
+// BootMetadata.
+var BootMetadataGuid = GUID{
+	0x6a3c52d1,
+	0x2f4e,
+	0x4b8a,
+	[8]byte{0x9d, 0x17, 0x5e, 0xc0, 0x43, 0xa8, 0x7b, 0x21},
+}
+
+// bootMetadataVersion is the version of the BootMetadata encoding.
+const bootMetadataVersion = 1
+
+// BootMetadata record tags.
+const (
+	bootMetadataImageURL = 1
+	bootMetadataToken    = 2
+	bootMetadataRole     = 3
+)
+
+// BootMetadata is provisioning information for a host, carried in the
+// optional data of its network boot option so that the boot loader or
+// the OS can read it back from the BootXXXX variable.
+//
+// The optional data is BootMetadataGuid, a version byte and a record per
+// non-empty field: a UINT8 tag, the UINT16 length of the value and the
+// UTF-8 value. Decoders skip records with unknown tags.
+type BootMetadata struct {
+	// ImageURL is the URL of the OS image to install.
+	ImageURL string
+	// Token authenticates the host to the provisioning service.
+	Token string
+	// Role is the role of the host in its cluster, e.g. "worker".
+	Role string
+}
+
+// IsZero reports whether m has no field set.
+func (m BootMetadata) IsZero() bool {
+	return m == BootMetadata{}
+}
+
+// Bytes encodes m as the optional data of a boot option. Empty metadata
+// encodes as BmAutoCreateBootOptionGuid, the optional data of the boot
+// options the firmware creates itself.
+func (m BootMetadata) Bytes() ([]byte, error) {
+	if m.IsZero() {
+		return BmAutoCreateBootOptionGuid.Bytes(), nil
+	}
+	buf := bytes.NewBuffer(BootMetadataGuid.Bytes())
+	buf.WriteByte(bootMetadataVersion)
+	for _, r := range []struct {
+		tag   byte
+		name  string
+		value string
+	}{
+		{bootMetadataImageURL, "image URL", m.ImageURL},
+		{bootMetadataToken, "token", m.Token},
+		{bootMetadataRole, "role", m.Role},
+	} {
+		if r.value == "" {
+			continue
+		}
+		if len(r.value) > 0xffff {
+			return nil, fmt.Errorf("boot metadata %s is %d bytes, at most 65535 allowed", r.name, len(r.value))
+		}
+		if !utf8.ValidString(r.value) {
+			return nil, fmt.Errorf("boot metadata %s is not valid UTF-8", r.name)
+		}
+		buf.WriteByte(r.tag)
+		_ = binary.Write(buf, binary.LittleEndian, uint16(len(r.value)))
+		buf.WriteString(r.value)
+	}
+	return buf.Bytes(), nil
+}
+
+// ParseBootMetadata decodes the optional data of a boot option. It
+// returns nil if data does not start with BootMetadataGuid, as for boot
+// options created by the firmware.
+func ParseBootMetadata(data []byte) (*BootMetadata, error) {
+	if len(data) < 16 || ParseBinGUID(data, 0) != BootMetadataGuid {
+		return nil, nil
+	}
+	data = data[16:]
+	if len(data) < 1 {
+		return nil, fmt.Errorf("data too short for boot metadata version")
+	}
+	if data[0] != bootMetadataVersion {
+		return nil, fmt.Errorf("unsupported boot metadata version %d", data[0])
+	}
+
+	m := &BootMetadata{}
+	for off := 1; off < len(data); {
+		if off+3 > len(data) {
+			return nil, fmt.Errorf("truncated boot metadata record at %d", off)
+		}
+		tag := data[off]
+		n := int(binary.LittleEndian.Uint16(data[off+1:]))
+		off += 3
+		if off+n > len(data) {
+			return nil, fmt.Errorf("boot metadata record %d overruns the data", tag)
+		}
+		value := string(data[off : off+n])
+		off += n
+
+		var field *string
+		switch tag {
+		case bootMetadataImageURL:
+			field = &m.ImageURL
+		case bootMetadataToken:
+			field = &m.Token
+		case bootMetadataRole:
+			field = &m.Role
+		default:
+			continue
+		}
+		if !utf8.ValidString(value) {
+			return nil, fmt.Errorf("boot metadata record %d is not valid UTF-8", tag)
+		}
+		*field = value
+	}
+	return m, nil
+}
+
+// NewPxeBootOptionWithMetadata is like NewPxeBootOption but carries md in
+// the optional data of the boot option. Unlike the boot options the
+// firmware creates, an option with metadata is kept by the firmware when
+// it refreshes its boot options.
+func NewPxeBootOptionWithMetadata(mac net.HardwareAddr, md BootMetadata) (*EfiVar, error) {
+	if len(mac) != 6 {
+		return nil, fmt.Errorf("invalid MAC address length: %d", len(mac))
+	}
+	optData, err := md.Bytes()
+	if err != nil {
+		return nil, err
+	}
+	return networkBootOption("UEFI PXEv4", (&DevicePath{}).Mac(mac).IPv4(), mac, optData), nil
+}
+
+// NewHttpBootOption creates the Boot0099 variable booting the host with
+// the MAC address mac over HTTP from uri, with md in its optional data.
+func NewHttpBootOption(mac net.HardwareAddr, uri string, md BootMetadata) (*EfiVar, error) {
+	if len(mac) != 6 {
+		return nil, fmt.Errorf("invalid MAC address length: %d", len(mac))
+	}
+	if uri == "" {
+		return nil, fmt.Errorf("empty HTTP boot URI")
+	}
+	optData, err := md.Bytes()
+	if err != nil {
+		return nil, err
+	}
+	return networkBootOption("UEFI HTTPv4", (&DevicePath{}).Mac(mac).IPv4().URI(uri), mac, optData), nil
+}
+
+// networkBootOption returns the Boot0099 variable for a network boot
+// option titled after protocol and mac.
+func networkBootOption(protocol string, devPath *DevicePath, mac net.HardwareAddr, optData []byte) *EfiVar {
+	bootEntry := &BootEntry{
+		Attr:       LOAD_OPTION_ACTIVE,
+		Title:      *NewUCS16String(formatMACTitle(protocol, mac)),
+		DevicePath: *devPath,
+		OptData:    optData,
+	}
+	return &EfiVar{
+		Name: boot0099Name,
+		Guid: EFI_GLOBAL_VARIABLE_GUID,
+		Attr: EfiVariableDefault | EfiVariableRuntimeAccess, // Attr 7
+		Data: bootEntry.Bytes(),
+	}
+}
@@ -0,0 +1,110 @@
+package efi
+
+import (
+	"bytes"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestBootMetadata_RoundTrip(t *testing.T) {
+	for _, md := range []BootMetadata{
+		{ImageURL: "https://images.example.com/worker.img", Token: "s3cret", Role: "worker"},
+		{Role: "control-plane"},
+		{Token: "ünïcode"},
+	} {
+		data, err := md.Bytes()
+		if err != nil {
+			t.Fatalf("Bytes() error = %v", err)
+		}
+		if ParseBinGUID(data, 0) != BootMetadataGuid {
+			t.Errorf("Bytes() = %x, want it to start with BootMetadataGuid", data)
+		}
+		got, err := ParseBootMetadata(data)
+		if err != nil {
+			t.Fatalf("ParseBootMetadata() error = %v", err)
+		}
+		if got == nil || *got != md {
+			t.Errorf("ParseBootMetadata() = %+v, want %+v", got, md)
+		}
+	}
+}
+
+func TestBootMetadata_Empty(t *testing.T) {
+	data, err := BootMetadata{}.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, BmAutoCreateBootOptionGuid.Bytes()) {
+		t.Errorf("Bytes() of empty metadata = %x", data)
+	}
+	for _, data := range [][]byte{data, nil, []byte("short")} {
+		if md, err := ParseBootMetadata(data); md != nil || err != nil {
+			t.Errorf("ParseBootMetadata(%x) = %+v, %v, want nil", data, md, err)
+		}
+	}
+}
+
+func TestParseBootMetadata(t *testing.T) {
+	header := append(BootMetadataGuid.Bytes(), bootMetadataVersion)
+
+	// Records with unknown tags are skipped.
+	data := append(bytes.Clone(header), 9, 2, 0, 'x', 'y', bootMetadataRole, 6, 0)
+	data = append(data, "worker"...)
+	md, err := ParseBootMetadata(data)
+	if err != nil || md == nil || *md != (BootMetadata{Role: "worker"}) {
+		t.Errorf("ParseBootMetadata() = %+v, %v", md, err)
+	}
+
+	for _, tt := range []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{"no version", BootMetadataGuid.Bytes(), "too short"},
+		{"future version", append(BootMetadataGuid.Bytes(), 2), "unsupported boot metadata version 2"},
+		{"truncated header", append(bytes.Clone(header), bootMetadataToken, 1), "truncated"},
+		{"overrun", append(bytes.Clone(header), bootMetadataToken, 5, 0, 'a'), "overruns"},
+		{"invalid UTF-8", append(bytes.Clone(header), bootMetadataRole, 1, 0, 0xff), "UTF-8"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseBootMetadata(tt.data)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("ParseBootMetadata() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+
+	if _, err := (BootMetadata{Token: strings.Repeat("t", 0x10000)}).Bytes(); err == nil {
+		t.Error("Bytes() of a 64 KiB token succeeded")
+	}
+}
+
+func TestNewHttpBootOption(t *testing.T) {
+	mac, _ := net.ParseMAC("d8:3a:dd:01:02:03")
+	md := BootMetadata{Role: "worker"}
+	v, err := NewHttpBootOption(mac, "http://boot.example.com/ipxe.efi", md)
+	if err != nil {
+		t.Fatalf("NewHttpBootOption() error = %v", err)
+	}
+	entry, err := v.GetBootEntry()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := entry.Title.String(); got != "UEFI HTTPv4 (MAC:D8:3A:DD:01:02:03)" {
+		t.Errorf("title = %q", got)
+	}
+	if got := entry.DevicePath.String(); !strings.HasSuffix(got, "/URI(http://boot.example.com/ipxe.efi)") {
+		t.Errorf("device path = %s", got)
+	}
+	if got, err := ParseBootMetadata(entry.OptData); err != nil || got == nil || *got != md {
+		t.Errorf("metadata = %+v, %v", got, err)
+	}
+
+	if _, err := NewHttpBootOption(mac, "", md); err == nil {
+		t.Error("NewHttpBootOption() with an empty URI succeeded")
+	}
+	if _, err := NewPxeBootOptionWithMetadata(mac[:4], md); err == nil {
+		t.Error("NewPxeBootOptionWithMetadata() with a short MAC succeeded")
+	}
+}
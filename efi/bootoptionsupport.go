@@ -0,0 +1,82 @@
+package efi
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+)
+
+// BootOptionSupportVar is the EFI global variable in which the boot
+// manager publishes the BootOptionSupport it implements.
+const BootOptionSupportVar = "BootOptionSupport"
+
+// BootOptionSupport bits (EFI_BOOT_OPTION_SUPPORT_*).
+const (
+	// BootOptionSupportKey means the boot manager supports Key####
+	// hot keys.
+	BootOptionSupportKey BootOptionSupport = 0x00000001
+	// BootOptionSupportApp means the boot manager runs load options of
+	// the application category.
+	BootOptionSupportApp BootOptionSupport = 0x00000002
+	// BootOptionSupportSysPrep means the boot manager runs SysPrep####
+	// load options.
+	BootOptionSupportSysPrep BootOptionSupport = 0x00000010
+	// BootOptionSupportCount is the field holding the maximum number of
+	// key presses of a Key#### hot key.
+	BootOptionSupportCount BootOptionSupport = 0x00000300
+)
+
+// BootOptionSupport is the UINT32 BootOptionSupport variable.
+type BootOptionSupport uint32
+
+// NewBootOptionSupport decodes a BootOptionSupport variable.
+func NewBootOptionSupport(data []byte) (BootOptionSupport, error) {
+	if len(data) != 4 {
+		return 0, fmt.Errorf("BootOptionSupport is %d bytes, want 4", len(data))
+	}
+	return BootOptionSupport(binary.LittleEndian.Uint32(data)), nil
+}
+
+// Key reports whether Key#### hot keys are supported.
+func (s BootOptionSupport) Key() bool {
+	return s&BootOptionSupportKey != 0
+}
+
+// App reports whether application load options are supported.
+func (s BootOptionSupport) App() bool {
+	return s&BootOptionSupportApp != 0
+}
+
+// SysPrep reports whether SysPrep#### load options are supported.
+func (s BootOptionSupport) SysPrep() bool {
+	return s&BootOptionSupportSysPrep != 0
+}
+
+// KeyCount returns the maximum number of key presses of a hot key, from 0
+// to 3.
+func (s BootOptionSupport) KeyCount() int {
+	return int(s&BootOptionSupportCount) >> 8
+}
+
+// Bytes encodes the value as stored in the variable.
+func (s BootOptionSupport) Bytes() []byte {
+	return binary.LittleEndian.AppendUint32(nil, uint32(s))
+}
+
+// String lists the supported features, e.g. "Key(3)|App".
+func (s BootOptionSupport) String() string {
+	var names []string
+	if s.Key() {
+		names = append(names, fmt.Sprintf("Key(%d)", s.KeyCount()))
+	}
+	if s.App() {
+		names = append(names, "App")
+	}
+	if s.SysPrep() {
+		names = append(names, "SysPrep")
+	}
+	if len(names) == 0 {
+		return "none"
+	}
+	return strings.Join(names, "|")
+}
@@ -0,0 +1,29 @@
+package efi
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestBootOptionSupport(t *testing.T) {
+	data := []byte{0x13, 0x03, 0, 0}
+	s, err := NewBootOptionSupport(data)
+	if err != nil {
+		t.Fatalf("NewBootOptionSupport() error = %v", err)
+	}
+	if !s.Key() || !s.App() || !s.SysPrep() || s.KeyCount() != 3 {
+		t.Errorf("BootOptionSupport %#x decoded wrong", uint32(s))
+	}
+	if got, want := s.String(), "Key(3)|App|SysPrep"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+	if !bytes.Equal(s.Bytes(), data) {
+		t.Errorf("Bytes() = %x, want %x", s.Bytes(), data)
+	}
+	if BootOptionSupport(0).String() != "none" {
+		t.Errorf("String() of no support = %q", BootOptionSupport(0).String())
+	}
+	if _, err := NewBootOptionSupport(data[:2]); err == nil {
+		t.Error("NewBootOptionSupport() accepted 2 bytes")
+	}
+}
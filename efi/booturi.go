@@ -0,0 +1,39 @@
+package efi
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"net/url"
+)
+
+// BootEntryFromURI creates the Boot0099 variable booting the host with the
+// MAC address mac from uri over HTTP or HTTPS. Its device path is
+// MAC()/IPv6()/URI() if the host of uri is a literal IPv6 address and
+// MAC()/IPv4()/URI() otherwise, with the addresses configured by DHCP, as
+// the firmware builds them for HTTP boot.
+func BootEntryFromURI(uri string, mac net.HardwareAddr) (*EfiVar, error) {
+	if len(mac) != 6 {
+		return nil, fmt.Errorf("invalid MAC address length: %d", len(mac))
+	}
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, fmt.Errorf("invalid boot URI: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("unsupported boot URI scheme %q: want http or https", u.Scheme)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("boot URI %s has no host", uri)
+	}
+
+	devPath := (&DevicePath{}).Mac(mac)
+	protocol := "UEFI HTTPv4"
+	if addr, err := netip.ParseAddr(u.Hostname()); err == nil && addr.Is6() && !addr.Is4In6() {
+		devPath.IPv6()
+		protocol = "UEFI HTTPv6"
+	} else {
+		devPath.IPv4()
+	}
+	return networkBootOption(protocol, devPath.URI(uri), mac, nil), nil
+}
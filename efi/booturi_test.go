@@ -0,0 +1,69 @@
+package efi
+
+import (
+	"net"
+	"testing"
+)
+
+func TestBootEntryFromURI(t *testing.T) {
+	mac, _ := net.ParseMAC("d8:3a:dd:01:02:03")
+	tests := []struct {
+		uri       string
+		wantTitle string
+		wantPath  string
+	}{
+		{
+			uri:       "http://boot.example.com/ipxe.efi",
+			wantTitle: "UEFI HTTPv4 (MAC:D8:3A:DD:01:02:03)",
+			wantPath:  "MAC()/IPv4()/URI(http://boot.example.com/ipxe.efi)",
+		},
+		{
+			uri:       "https://10.0.0.1:8443/boot.efi",
+			wantTitle: "UEFI HTTPv4 (MAC:D8:3A:DD:01:02:03)",
+			wantPath:  "MAC()/IPv4()/URI(https://10.0.0.1:8443/boot.efi)",
+		},
+		{
+			uri:       "http://[2001:db8::1]/boot.efi",
+			wantTitle: "UEFI HTTPv6 (MAC:D8:3A:DD:01:02:03)",
+			wantPath:  "MAC()/IPv6()/URI(http://[2001:db8::1]/boot.efi)",
+		},
+		{
+			uri:       "http://[fe80::1%25eth0]:8080/boot.efi",
+			wantTitle: "UEFI HTTPv6 (MAC:D8:3A:DD:01:02:03)",
+			wantPath:  "MAC()/IPv6()/URI(http://[fe80::1%25eth0]:8080/boot.efi)",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.uri, func(t *testing.T) {
+			v, err := BootEntryFromURI(tt.uri, mac)
+			if err != nil {
+				t.Fatalf("BootEntryFromURI() error = %v", err)
+			}
+			if v.Name.String() != "Boot0099" || v.Guid != EFI_GLOBAL_VARIABLE_GUID {
+				t.Errorf("variable = %s-%s", v.Name, v.Guid)
+			}
+			entry, err := v.GetBootEntry()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := entry.Title.String(); got != tt.wantTitle {
+				t.Errorf("title = %q, want %q", got, tt.wantTitle)
+			}
+			if got := entry.DevicePath.String(); got != tt.wantPath {
+				t.Errorf("device path = %s, want %s", got, tt.wantPath)
+			}
+			if len(entry.OptData) != 0 {
+				t.Errorf("optional data = %x", entry.OptData)
+			}
+		})
+	}
+
+	for _, uri := range []string{"tftp://10.0.0.1/boot.efi", "http:///boot.efi", "boot.efi", "http://%zz"} {
+		if _, err := BootEntryFromURI(uri, mac); err == nil {
+			t.Errorf("BootEntryFromURI(%q) succeeded", uri)
+		}
+	}
+	if _, err := BootEntryFromURI("http://10.0.0.1/boot.efi", mac[:4]); err == nil {
+		t.Error("BootEntryFromURI() with a short MAC succeeded")
+	}
+}
@@ -35,8 +35,14 @@ const (
 	EfiImageSecurityDatabase       = "d719b2cb-3d3a-4596-a3bc-dad00e67656f"
 	EfiSecureBootEnableDisable     = "f0a30bc7-af08-4556-99c4-001009c93a44"
 	EfiCustomModeEnable            = "c076ec0c-7028-4399-a072-71ee5c448b9f"
+	EfiVendorKeysNv                = "9073e4e0-60ec-4b6e-9903-4c223c260f3c"
 	EfiDhcp6ServiceBindingProtocol = "9fb9a8a1-2f4a-43a6-889c-d0f7b6c47ad5"
+	EfiIp4Config2Protocol          = "5b446ed1-e30b-4faa-871a-3654eca36080"
 	EfiIp6ConfigProtocol           = "937fe521-95ae-4d1a-8929-48bcd90ad31a"
+	EfiTcg2PhysicalPresence        = "aeb9c5c1-94f1-4d02-bfd9-4602db2d3c54"
+	EfiMemoryTypeInformation       = "4c19049f-4137-4dd3-9c10-8b97a83ffdfa"
+	Tcg2ConfigFormSet              = "6339d487-26ba-424b-9a5d-687e25d740bc"
+	UserAuthentication             = "f06e3ea7-611c-4b6b-b410-c2bf943f38f2"
 
 	EfiCertX509   = "a5c059a1-94e4-4aa7-87b5-ab155c2bf072"
 	EfiCertSha256 = "c1c41626-504c-4092-aca9-41f936934328"
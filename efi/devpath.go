@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"encoding/binary"
 	"fmt"
+	"math"
 	"net"
 	"strconv"
 	"strings"
@@ -22,6 +23,10 @@ const (
 	DevTypeEnd      DeviceType = 0x7f
 )
 
+// devSubTypeEndEntire terminates the whole device path, as opposed to a
+// single instance of a multi-instance path.
+const devSubTypeEndEntire = 0xff
+
 // DeviceSubType represents the subtype of EFI device path element.
 type DeviceSubType uint8
 
@@ -507,6 +512,11 @@ func (dp *DevicePath) Append(elem *DevicePathElem) *DevicePath {
 	return dp
 }
 
+// Elems returns the elements of the device path, without the terminator.
+func (dp *DevicePath) Elems() []*DevicePathElem {
+	return dp.elems
+}
+
 // NewDevicePath creates a new DevicePath from data.
 // It parses each DevicePathElem until a terminating element is found.
 func NewDevicePath(data []byte) *DevicePath {
@@ -652,14 +662,13 @@ func ParseDevicePathFromString(s string) (*DevicePath, error) {
 				hidStr := strings.TrimPrefix(hidParts[1], "0x")
 				hid, err := strconv.ParseUint(hidStr, 16, 32)
 				if err != nil {
-					fmt.Println("Error:", err)
-					return nil, fmt.Errorf("invalid ACPI HID hex: %v", err)
+					return nil, fmt.Errorf("invalid ACPI HID hex: %w", err)
 				}
 				uidStr := strings.TrimPrefix(uidParts[1], "0x")
 				uidStr = "0" + uidStr // Ensure we have an even number of hex digits
 				uid, err := strconv.ParseUint(uidStr, 16, 32)
 				if err != nil {
-					return nil, fmt.Errorf("invalid ACPI UID hex: %v", err)
+					return nil, fmt.Errorf("invalid ACPI UID hex: %w", err)
 				}
 				elem.set_apci(uint32(hid), uint32(uid))
 			}
@@ -750,15 +759,41 @@ func DevicePathFilepath(filepath string) *DevicePath {
 }
 
 // ParseDevicePath parses a device path from binary data.
+// Unlike NewDevicePath it fails on truncated or malformed elements.
 func ParseDevicePath(data []byte) (*DevicePath, error) {
-	return NewDevicePath(data), nil
+	dp := &DevicePath{elems: []*DevicePathElem{}}
+	pos := 0
+	for pos < len(data) {
+		if len(data)-pos < 4 {
+			return nil, fmt.Errorf("truncated device path element at offset %d", pos)
+		}
+		size := int(binary.LittleEndian.Uint16(data[pos+2 : pos+4]))
+		if size < 4 || size > len(data)-pos {
+			return nil, fmt.Errorf("invalid device path element length %d at offset %d", size, pos)
+		}
+		if DeviceType(data[pos]) == DevTypeEnd {
+			return dp, nil
+		}
+		dp.elems = append(dp.elems, NewDevicePathElem(data[pos:pos+size]))
+		pos += size
+	}
+	return nil, fmt.Errorf("device path is not terminated")
+}
+
+// ParseDevicePathWithOptions parses a device path from binary data using
+// opts. In lenient mode it behaves like NewDevicePath.
+func ParseDevicePathWithOptions(data []byte, opts ParseOptions) (*DevicePath, error) {
+	if !opts.Strict {
+		return NewDevicePath(data), nil
+	}
+	return ParseDevicePath(data)
 }
 
 // ParseFromString parses a string representation of a device path.
 func (dp *DevicePath) ParseFromString(s string) error {
 	dp.elems = []*DevicePathElem{}
 
-	ndp, err := ParseDevicePath([]byte(s))
+	ndp, err := ParseDevicePathFromString(s)
 	if err != nil {
 		return err
 	}
@@ -766,6 +801,17 @@ func (dp *DevicePath) ParseFromString(s string) error {
 	return nil
 }
 
+// Serialize returns the binary device path. Unlike Bytes it fails if an
+// element does not fit in its 16-bit length field.
+func (dp *DevicePath) Serialize() ([]byte, error) {
+	for i, elem := range dp.elems {
+		if elem.size() > math.MaxUint16 {
+			return nil, fmt.Errorf("device path element %d too long: %d bytes", i, elem.size())
+		}
+	}
+	return dp.Bytes(), nil
+}
+
 func (dp *DevicePath) Bytes() []byte {
 	var blob bytes.Buffer
 	for _, elem := range dp.elems {
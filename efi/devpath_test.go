@@ -1,9 +1,12 @@
 package efi
 
 import (
+	"bytes"
 	"net"
 	"reflect"
+	"slices"
 	"testing"
+	"testing/quick"
 )
 
 func TestGuid_String(t *testing.T) {
@@ -1199,6 +1202,11 @@ func TestParseDevicePathFromString(t *testing.T) {
 				},
 			},
 		},
+		{
+			name:    "invalid_acpi_hid",
+			args:    args{s: "ACPI(hid=0xzz,uid=0x0)"},
+			wantErr: true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -1213,3 +1221,79 @@ func TestParseDevicePathFromString(t *testing.T) {
 		})
 	}
 }
+
+func TestDevicePath_SerializeRoundTrip(t *testing.T) {
+	for _, v := range corpusVariables(t) {
+		if _, ok := bootEntryIndex(v.Name.String()); !ok {
+			continue
+		}
+		entry, err := ParseBootEntry(v.Data)
+		if err != nil {
+			t.Fatalf("ParseBootEntry() %s error = %v", v.Name, err)
+		}
+		want := v.Data[6+entry.Title.Size() : len(v.Data)-len(entry.OptData)]
+		got, err := entry.DevicePath.Serialize()
+		if err != nil {
+			t.Fatalf("DevicePath.Serialize() %s error = %v", v.Name, err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("DevicePath.Serialize() %s = %x, want %x", v.Name, got, want)
+		}
+	}
+
+	property := func(types []uint8, payloads [][]byte) bool {
+		dp := &DevicePath{elems: []*DevicePathElem{}}
+		for i, devtype := range types {
+			elem := &DevicePathElem{
+				Devtype: DeviceType(devtype%5 + 1),
+				Subtype: DeviceSubType(devtype),
+				Data:    []byte{},
+			}
+			if i < len(payloads) {
+				elem.Data = append(elem.Data, payloads[i]...)
+			}
+			dp.Append(elem)
+		}
+		blob, err := dp.Serialize()
+		if err != nil {
+			return false
+		}
+		got, err := ParseDevicePath(blob)
+		if err != nil || !reflect.DeepEqual(got, dp) {
+			return false
+		}
+		again, err := got.Serialize()
+		return err == nil && bytes.Equal(again, blob)
+	}
+	if err := quick.Check(property, nil); err != nil {
+		t.Error(err)
+	}
+}
+
+func FuzzParseDevicePath(f *testing.F) {
+	f.Add(DevicePathFilepath(`\EFI\BOOT\BOOTAA64.EFI`).Bytes())
+	f.Add(DevicePathUri("http://example.com/boot.efi").Bytes())
+	f.Add((&DevicePath{}).Mac(net.HardwareAddr{0xd8, 0x3a, 0xdd, 0x5a, 0x44, 0x36}).IPv4().Bytes())
+	f.Add([]byte{0x01, 0x01, 0x02, 0x00})
+	f.Add([]byte{})
+	addCorpusSeeds(f, func(name string) bool { return slices.Contains(dpathNames, name) })
+	for _, v := range corpusVariables(f) {
+		if _, ok := bootEntryIndex(v.Name.String()); !ok {
+			continue
+		}
+		if entry, err := ParseBootEntry(v.Data); err == nil {
+			f.Add(entry.DevicePath.Bytes())
+		}
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		dp, err := ParseDevicePath(data)
+		if err != nil {
+			return
+		}
+		_ = dp.String()
+		if _, err := ParseDevicePath(dp.Bytes()); err != nil {
+			t.Errorf("ParseDevicePath() of re-encoded path error = %v", err)
+		}
+	})
+}
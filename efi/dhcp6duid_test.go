@@ -0,0 +1,30 @@
+package efi
+
+import (
+	"encoding/hex"
+	"slices"
+	"testing"
+)
+
+func FuzzNewDhcp6Duid(f *testing.F) {
+	for _, s := range []string{
+		"01000100c0ffee00d83add5a4436", // DUID-LLT
+		"0200a0b1c2d301020304",         // DUID-EN
+		"03000100d83add5a4436",         // DUID-LL
+	} {
+		data, _ := hex.DecodeString(s)
+		f.Add(data)
+	}
+	f.Add([]byte{})
+	addCorpusSeeds(f, func(name string) bool { return slices.Contains(duidNames, name) })
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		duid, err := NewDhcp6Duid(data)
+		if err != nil {
+			return
+		}
+		_ = duid.String()
+		_ = duid.GetMacAddress()
+		_ = duid.Bytes()
+	})
+}
@@ -1,11 +1,18 @@
 package efi
 
 import (
+	"bytes"
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"sort"
+	"unicode/utf16"
 )
 
+// virtFwVarsVersion is the EfiVarList layout version written by the
+// virt-firmware virt-fw-vars tool.
+const virtFwVarsVersion = 2
+
 // jsonEncoder handles serializing EFI data types to JSON.
 type jsonEncoder struct{}
 
@@ -49,7 +56,7 @@ func (e *jsonEncoder) MarshalEfiVarList(list EfiVarList) efiVarListJSON {
 	}
 
 	return efiVarListJSON{
-		Version:   2,
+		Version:   virtFwVarsVersion,
 		Variables: variables,
 	}
 }
@@ -73,9 +80,12 @@ func (v *EfiVar) UnmarshalJSON(data []byte) error {
 		return err
 	}
 
-	name := FromString(jsonVar.Name)
+	name, err := UCS16FromString(jsonVar.Name)
+	if err != nil {
+		return fmt.Errorf("invalid variable name %q: %w", jsonVar.Name, err)
+	}
 
-	guid, err := GUIDFromBytes([]byte(jsonVar.GUID)) // ParseGUIDString(jsonVar.GUID)
+	guid, err := ParseGUID(jsonVar.GUID)
 	if err != nil {
 		return err
 	}
@@ -104,17 +114,24 @@ func (v *EfiVar) UnmarshalJSON(data []byte) error {
 }
 
 // UnmarshalJSON implements the json.Unmarshaler interface for EfiVarList.
+// Besides the versioned object written by virt-fw-vars it accepts a bare
+// list of variables.
 func (list *EfiVarList) UnmarshalJSON(data []byte) error {
 	var jsonList struct {
 		Version   int               `json:"version"`
 		Variables []json.RawMessage `json:"variables"`
 	}
 
-	if err := json.Unmarshal(data, &jsonList); err != nil {
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '[' {
+		if err := json.Unmarshal(trimmed, &jsonList.Variables); err != nil {
+			return err
+		}
+		jsonList.Version = virtFwVarsVersion
+	} else if err := json.Unmarshal(data, &jsonList); err != nil {
 		return err
 	}
 
-	if jsonList.Version != 2 {
+	if jsonList.Version != virtFwVarsVersion {
 		return fmt.Errorf("unsupported EfiVarList version: %d", jsonList.Version)
 	}
 
@@ -125,12 +142,54 @@ func (list *EfiVarList) UnmarshalJSON(data []byte) error {
 		if err := json.Unmarshal(varData, &v); err != nil {
 			return err
 		}
-		(*list)[v.Name.String()] = &v
+		list.Set(&v)
 	}
 
 	return nil
 }
 
+// MarshalVirtFwVars encodes list exactly like virt-fw-vars --output-json:
+// indented by four spaces, with non-ASCII characters escaped and no
+// trailing newline. Variables are sorted by name and GUID.
+func MarshalVirtFwVars(list EfiVarList) ([]byte, error) {
+	encoder := jsonEncoder{}
+	keys := make([]string, 0, len(list))
+	for k := range list {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	variables := make([]efiVarJSON, 0, len(list))
+	for _, k := range keys {
+		variables = append(variables, encoder.MarshalEfiVar(list[k]))
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "    ")
+	if err := enc.Encode(efiVarListJSON{Version: virtFwVarsVersion, Variables: variables}); err != nil {
+		return nil, err
+	}
+	return asciiJSON(bytes.TrimSuffix(buf.Bytes(), []byte("\n"))), nil
+}
+
+// asciiJSON escapes every non-ASCII character of an encoded JSON document
+// the way Python's json module does with ensure_ascii. Non-ASCII characters
+// can only appear inside strings, so escaping them is always safe.
+func asciiJSON(data []byte) []byte {
+	out := make([]byte, 0, len(data))
+	for _, r := range string(data) {
+		if r < 0x80 {
+			out = append(out, byte(r))
+			continue
+		}
+		for _, u := range utf16.Encode([]rune{r}) {
+			out = fmt.Appendf(out, "\\u%04x", u)
+		}
+	}
+	return out
+}
+
 // Custom JSON decoder function for use with json.Unmarshal.
 func DecodeEfiJSON(data []byte, v *efiVarJSON) error {
 	return json.Unmarshal(data, v)
@@ -3,6 +3,7 @@ package efi
 import (
 	"os"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 )
@@ -249,3 +250,77 @@ func TestDecodeEfiJSON(t *testing.T) {
 		})
 	}
 }
+
+func TestEfiVarList_UnmarshalJSONBareList(t *testing.T) {
+	data := []byte(`[
+    {
+        "name": "Timeout",
+        "guid": "8be4df61-93ca-11d2-aa0d-00e098032b8c",
+        "attr": 7,
+        "data": "0500"
+    }
+]`)
+	list := EfiVarList{}
+	if err := list.UnmarshalJSON(data); err != nil {
+		t.Fatalf("EfiVarList.UnmarshalJSON() error = %v", err)
+	}
+	v, found := list.Lookup("Timeout")
+	if !found || v.Attr != 7 || !reflect.DeepEqual(v.Data, []byte{0x05, 0x00}) {
+		t.Errorf("EfiVarList.UnmarshalJSON() = %v, want Timeout", list)
+	}
+
+	if err := list.UnmarshalJSON([]byte(`{"version": 1, "variables": []}`)); err == nil {
+		t.Error("EfiVarList.UnmarshalJSON() accepted version 1")
+	}
+}
+
+func TestMarshalVirtFwVars(t *testing.T) {
+	list := NewEfiVarList()
+	list.Set(&EfiVar{
+		Name: FromString("Lang<é>"),
+		Guid: EFI_GLOBAL_VARIABLE_GUID,
+		Attr: 7,
+		Data: []byte{0x01},
+	})
+	list.Set(&EfiVar{
+		Name: FromString("BootNext"),
+		Guid: EFI_GLOBAL_VARIABLE_GUID,
+		Attr: 7,
+		Data: []byte{0x99, 0x00},
+	})
+
+	got, err := MarshalVirtFwVars(list)
+	if err != nil {
+		t.Fatalf("MarshalVirtFwVars() error = %v", err)
+	}
+	want := strings.Join([]string{
+		`{`,
+		`    "version": 2,`,
+		`    "variables": [`,
+		`        {`,
+		`            "name": "BootNext",`,
+		`            "guid": "8be4df61-93ca-11d2-aa0d-00e098032b8c",`,
+		`            "attr": 7,`,
+		`            "data": "9900"`,
+		`        },`,
+		`        {`,
+		`            "name": "Lang<\u00e9>",`,
+		`            "guid": "8be4df61-93ca-11d2-aa0d-00e098032b8c",`,
+		`            "attr": 7,`,
+		`            "data": "01"`,
+		`        }`,
+		`    ]`,
+		`}`,
+	}, "\n")
+	if string(got) != want {
+		t.Errorf("MarshalVirtFwVars() = %s, want %s", got, want)
+	}
+
+	decoded := EfiVarList{}
+	if err := decoded.UnmarshalJSON(got); err != nil {
+		t.Fatalf("EfiVarList.UnmarshalJSON() error = %v", err)
+	}
+	if _, found := decoded.Lookup("Lang<é>"); !found || len(decoded) != 2 {
+		t.Errorf("EfiVarList.UnmarshalJSON(MarshalVirtFwVars()) = %v", decoded)
+	}
+}
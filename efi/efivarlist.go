@@ -3,11 +3,18 @@ package efi
 import (
 	"errors"
 	"fmt"
-	"log"
+	"sort"
 	"strings"
 )
 
-// EfiVarList is a map of variable names to EfiVar objects.
+// guidStringLen is the length of a GUID in its canonical string form.
+const guidStringLen = 36
+
+// EfiVarList is a map of variable keys to EfiVar objects.
+//
+// Variables are identified by the combination of their name and vendor GUID,
+// so entries are stored under the key returned by VarKey. Lookups by plain
+// name are still supported through Lookup for backward compatibility.
 type EfiVarList map[string]*EfiVar
 
 // NewEfiVarList creates a new empty EfiVarList.
@@ -15,44 +22,191 @@ func NewEfiVarList() EfiVarList {
 	return make(EfiVarList)
 }
 
+// VarKey returns the list key for a variable, in the "Name-GUID" form used by efivarfs.
+func VarKey(name string, guid GUID) string {
+	return name + "-" + guid.String()
+}
+
+// ParseVarKey splits a key produced by VarKey into the variable name and GUID.
+// It returns false if the key is not a qualified key.
+func ParseVarKey(key string) (string, GUID, bool) {
+	if len(key) < guidStringLen+2 || key[len(key)-guidStringLen-1] != '-' {
+		return "", GUID{}, false
+	}
+	guidStr := key[len(key)-guidStringLen:]
+	if strings.Count(guidStr, "-") != 4 {
+		return "", GUID{}, false
+	}
+	guid, err := ParseGUID(guidStr)
+	if err != nil {
+		return "", GUID{}, false
+	}
+	return key[:len(key)-guidStringLen-1], guid, true
+}
+
+// Key returns the list key identifying the variable.
+func (v *EfiVar) Key() string {
+	return VarKey(v.Name.String(), v.Guid)
+}
+
+// Get returns the variable with the given name and vendor GUID.
+func (l EfiVarList) Get(name string, guid GUID) (*EfiVar, bool) {
+	if v, ok := l[VarKey(name, guid)]; ok {
+		return v, true
+	}
+	// Lists built by hand may still use plain names as keys.
+	if v, ok := l[name]; ok && v.Guid == guid {
+		return v, true
+	}
+	return nil, false
+}
+
+// Lookup returns a variable by name. The name may also be a qualified key as
+// returned by VarKey. When several variables share the same name, the one in
+// the EFI global variable namespace wins, otherwise the first in key order.
+func (l EfiVarList) Lookup(name string) (*EfiVar, bool) {
+	key, ok := l.lookupKey(name)
+	if !ok {
+		return nil, false
+	}
+	return l[key], true
+}
+
+// lookupKey resolves a name or qualified key to the key it is stored under.
+func (l EfiVarList) lookupKey(name string) (string, bool) {
+	if _, ok := l[name]; ok {
+		return name, true
+	}
+	if key := VarKey(name, EFI_GLOBAL_VARIABLE_GUID); l[key] != nil {
+		return key, true
+	}
+
+	keys := make([]string, 0, 1)
+	for k, v := range l {
+		if v != nil && v.Name.String() == name {
+			keys = append(keys, k)
+		}
+	}
+	if len(keys) == 0 {
+		return "", false
+	}
+	sort.Strings(keys)
+	return keys[0], true
+}
+
+// Set stores a variable under its name and GUID, replacing any existing
+// variable with the same identity.
+func (l EfiVarList) Set(v *EfiVar) {
+	name := v.Name.String()
+	if old, ok := l[name]; ok && old.Guid == v.Guid {
+		delete(l, name)
+	}
+	l[v.Key()] = v
+}
+
+// Remove deletes the variable with the given name and vendor GUID.
+func (l EfiVarList) Remove(name string, guid GUID) bool {
+	if _, ok := l[VarKey(name, guid)]; ok {
+		delete(l, VarKey(name, guid))
+		return true
+	}
+	if v, ok := l[name]; ok && v.Guid == guid {
+		delete(l, name)
+		return true
+	}
+	return false
+}
+
+// ByName returns the variables keyed by plain name. Names that exist under
+// more than one GUID keep their qualified keys so none of them are hidden.
+func (l EfiVarList) ByName() map[string]*EfiVar {
+	counts := make(map[string]int, len(l))
+	for _, v := range l {
+		counts[v.Name.String()]++
+	}
+
+	result := make(map[string]*EfiVar, len(l))
+	for _, v := range l {
+		name := v.Name.String()
+		if counts[name] > 1 {
+			result[v.Key()] = v
+		} else {
+			result[name] = v
+		}
+	}
+	return result
+}
+
+// GroupByGuid returns the variables keyed by vendor GUID, each group
+// sorted by name.
+func (l EfiVarList) GroupByGuid() map[GUID][]*EfiVar {
+	groups := make(map[GUID][]*EfiVar)
+	for _, v := range l {
+		groups[v.Guid] = append(groups[v.Guid], v)
+	}
+	for _, vars := range groups {
+		sort.Slice(vars, func(i, j int) bool { return vars[i].Name.String() < vars[j].Name.String() })
+	}
+	return groups
+}
+
+// Validate checks every variable in the list against the invariants of a
+// variable store. Variables are checked in key order so errors are stable.
+func (l EfiVarList) Validate() error {
+	keys := make([]string, 0, len(l))
+	for k := range l {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if l[k] == nil {
+			return fmt.Errorf("%w: nil entry for key %s", ErrInvalidVariable, k)
+		}
+		if err := l[k].ValidateStored(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (l EfiVarList) Add(v *EfiVar) error {
 	if v == nil {
 		return errors.New("cannot add nil EfiVar")
 	}
-	if _, exists := l[v.Name.String()]; exists {
-		return fmt.Errorf("variable %s already exists", v.Name)
+	if _, exists := l.Get(v.Name.String(), v.Guid); exists {
+		return fmt.Errorf("variable %s already exists", v.Key())
 	}
-	l[v.Name.String()] = v
-	log.Printf("added variable: %s", v.Name)
+	l.Set(v)
+	logger.V(1).Info("added variable", "name", v.Name.String(), "guid", v.Guid.String())
 	return nil
 }
 
 // Create creates a new variable in the list.
 func (l EfiVarList) Create(name string) (*EfiVar, error) {
-	log.Printf("create variable %s", name)
+	logger.V(1).Info("created variable", "name", name)
 
 	v, err := NewEfiVar(name, nil, 0, []byte{}, 0)
 	if err != nil {
 		return nil, err
 	}
 
-	l[name] = v
+	l.Set(v)
 	return v, nil
 }
 
 // Delete deletes a variable from the list.
 func (l EfiVarList) Delete(name string) {
-	if _, ok := l[name]; ok {
-		log.Printf("delete variable: %s", name)
-		delete(l, name)
+	if key, ok := l.lookupKey(name); ok {
+		logger.V(1).Info("deleted variable", "name", name)
+		delete(l, key)
 	} else {
-		log.Printf("warning: variable %s not found", name)
+		logger.V(1).Info("variable to delete not found", "name", name)
 	}
 }
 
 // SetBool sets a boolean variable.
 func (l EfiVarList) SetBool(name string, value bool) error {
-	v, ok := l[name]
+	v, ok := l.Lookup(name)
 	if !ok {
 		var err error
 		v, err = l.Create(name)
@@ -61,14 +215,14 @@ func (l EfiVarList) SetBool(name string, value bool) error {
 		}
 	}
 
-	log.Printf("set variable %s: %v", name, value)
+	logger.V(1).Info("set variable", "name", name, "value", value)
 	v.SetBool(value)
 	return nil
 }
 
 // SetUint32 sets a 32-bit unsigned integer variable.
 func (l EfiVarList) SetUint32(name string, value uint32) error {
-	v, ok := l[name]
+	v, ok := l.Lookup(name)
 	if !ok {
 		var err error
 		v, err = l.Create(name)
@@ -77,7 +231,7 @@ func (l EfiVarList) SetUint32(name string, value uint32) error {
 		}
 	}
 
-	log.Printf("set variable %s: %d", name, value)
+	logger.V(1).Info("set variable", "name", name, "value", value)
 	v.SetUint32(value)
 	return nil
 }
@@ -85,7 +239,7 @@ func (l EfiVarList) SetUint32(name string, value uint32) error {
 // SetBootEntry sets a boot entry variable.
 func (l EfiVarList) SetBootEntry(index uint16, title string, path string, optdata []byte) error {
 	name := fmt.Sprintf("Boot%04X", index)
-	v, ok := l[name]
+	v, ok := l.Lookup(name)
 	if !ok {
 		var err error
 		v, err = l.Create(name)
@@ -94,7 +248,7 @@ func (l EfiVarList) SetBootEntry(index uint16, title string, path string, optdat
 		}
 	}
 
-	log.Printf("set variable %s: %s = %s", name, title, path)
+	logger.V(1).Info("set boot entry", "name", name, "title", title, "path", path)
 	return v.SetBootEntry(LOAD_OPTION_ACTIVE, title, path, optdata)
 }
 
@@ -102,7 +256,7 @@ func (l EfiVarList) SetBootEntry(index uint16, title string, path string, optdat
 func (l EfiVarList) AddBootEntry(title string, path string, optdata []byte) (uint16, error) {
 	for index := uint16(0); index < 0xffff; index++ {
 		name := fmt.Sprintf("Boot%04X", index)
-		if _, ok := l[name]; !ok {
+		if _, ok := l.Get(name, EFI_GLOBAL_VARIABLE_GUID); !ok {
 			err := l.SetBootEntry(index, title, path, optdata)
 			if err != nil {
 				return 0, err
@@ -115,7 +269,7 @@ func (l EfiVarList) AddBootEntry(title string, path string, optdata []byte) (uin
 }
 
 func (l EfiVarList) GetBootNext() (uint16, error) {
-	v, ok := l[BootNext]
+	v, ok := l.Lookup(BootNext)
 	if !ok {
 		return 0, errors.New("BootNext variable not found")
 	}
@@ -124,7 +278,7 @@ func (l EfiVarList) GetBootNext() (uint16, error) {
 
 // SetBootNext sets the BootNext variable.
 func (l EfiVarList) SetBootNext(index uint16) error {
-	v, ok := l[BootNext]
+	v, ok := l.Lookup(BootNext)
 	if !ok {
 		var err error
 		v, err = l.Create(BootNext)
@@ -133,46 +287,46 @@ func (l EfiVarList) SetBootNext(index uint16) error {
 		}
 	}
 
-	log.Printf("set variable BootNext: 0x%04X", index)
+	logger.V(1).Info("set variable", "name", BootNext, "value", fmt.Sprintf("0x%04X", index))
 	v.SetBootNext(index)
 	return nil
 }
 
 // SetBootOrder sets the BootOrder variable.
 func (l EfiVarList) SetBootOrder(order []uint16) error {
-	v, ok := l["BootOrder"]
+	v, ok := l.Lookup(BootOrder)
 	if !ok {
 		var err error
-		v, err = l.Create("BootOrder")
+		v, err = l.Create(BootOrder)
 		if err != nil {
 			return err
 		}
 	}
 
-	log.Printf("set variable BootOrder: %v", order)
+	logger.V(1).Info("set variable", "name", BootOrder, "value", order)
 	v.SetBootOrder(order)
 	return nil
 }
 
 // AppendBootOrder appends to the BootOrder variable.
 func (l EfiVarList) AppendBootOrder(index uint16) error {
-	v, ok := l["BootOrder"]
+	v, ok := l.Lookup(BootOrder)
 	if !ok {
 		var err error
-		v, err = l.Create("BootOrder")
+		v, err = l.Create(BootOrder)
 		if err != nil {
 			return err
 		}
 	}
 
-	log.Printf("append to variable BootOrder: 0x%04X", index)
+	logger.V(1).Info("appended to variable", "name", BootOrder, "value", fmt.Sprintf("0x%04X", index))
 	v.AppendBootOrder(index)
 	return nil
 }
 
 // GetBootOrder retrieves the BootOrder variable.
 func (l EfiVarList) GetBootOrder() ([]uint16, error) {
-	v, ok := l["BootOrder"]
+	v, ok := l.Lookup(BootOrder)
 	if !ok {
 		return nil, errors.New("BootOrder variable not found")
 	}
@@ -182,7 +336,7 @@ func (l EfiVarList) GetBootOrder() ([]uint16, error) {
 
 // SetFromFile sets a variable's data from a file.
 func (l EfiVarList) SetFromFile(name string, filename string) error {
-	v, ok := l[name]
+	v, ok := l.Lookup(name)
 	if !ok {
 		var err error
 		v, err = l.Create(name)
@@ -191,14 +345,14 @@ func (l EfiVarList) SetFromFile(name string, filename string) error {
 		}
 	}
 
-	log.Printf("set variable %s from file %s", name, filename)
+	logger.V(1).Info("set variable from file", "name", name, "filename", filename)
 	return v.SetFromFile(filename)
 }
 
 // GetBootEntry retrieves a boot entry.
 func (l EfiVarList) GetBootEntry(index uint16) (*BootEntry, error) {
 	name := fmt.Sprintf("Boot%04X", index)
-	v, ok := l[name]
+	v, ok := l.Lookup(name)
 	if !ok {
 		return nil, errors.New("boot entry not found")
 	}
@@ -210,10 +364,9 @@ func (l EfiVarList) GetBootEntry(index uint16) (*BootEntry, error) {
 func (l EfiVarList) ListBootEntries() (map[uint16]*BootEntry, error) {
 	entries := make(map[uint16]*BootEntry)
 
-	for index := uint16(0); index < 0xffff; index++ {
-		name := fmt.Sprintf("Boot%04X", index)
-		v, ok := l[name]
-		if !ok {
+	for _, v := range l {
+		index, ok := bootEntryIndex(v.Name.String())
+		if !ok || v.Guid != EFI_GLOBAL_VARIABLE_GUID {
 			continue
 		}
 
@@ -231,16 +384,33 @@ func (l EfiVarList) ListBootEntries() (map[uint16]*BootEntry, error) {
 // DeleteBootEntry deletes a boot entry.
 func (l EfiVarList) DeleteBootEntry(index uint16) error {
 	name := fmt.Sprintf("Boot%04X", index)
-	_, ok := l[name]
-	if !ok {
+	if !l.Remove(name, EFI_GLOBAL_VARIABLE_GUID) {
 		return errors.New("boot entry not found")
 	}
 
-	log.Printf("delete variable %s", name)
-	l.Delete(name)
+	logger.V(1).Info("deleted variable", "name", name)
 	return nil
 }
 
+// bootEntryIndex returns the index of a Boot#### variable name.
+func bootEntryIndex(name string) (uint16, bool) {
+	if len(name) != 8 || !strings.HasPrefix(name, BootPrefix) {
+		return 0, false
+	}
+	var index uint16
+	for _, c := range name[4:] {
+		switch {
+		case c >= '0' && c <= '9':
+			index = index<<4 | uint16(c-'0')
+		case c >= 'A' && c <= 'F':
+			index = index<<4 | uint16(c-'A'+10)
+		default:
+			return 0, false
+		}
+	}
+	return index, true
+}
+
 // FindFirst returns the first variable that matches the criteria.
 func (l EfiVarList) FindFirst(predicate func(name string, efiVar *EfiVar) bool) (*EfiVar, string) {
 	for name, v := range l {
@@ -263,8 +433,8 @@ func (l EfiVarList) Variables() []*EfiVar {
 // FindByPrefix returns all variables that have names starting with the given prefix.
 func (l EfiVarList) FindByPrefix(prefix string) []*EfiVar {
 	vars := make([]*EfiVar, 0)
-	for k, v := range l {
-		if strings.HasPrefix(k, prefix) {
+	for _, v := range l {
+		if strings.HasPrefix(v.Name.String(), prefix) {
 			vars = append(vars, v)
 		}
 	}
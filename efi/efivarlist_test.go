@@ -3,6 +3,9 @@ package efi
 import (
 	"reflect"
 	"testing"
+
+	"github.com/go-logr/logr"
+	"github.com/go-logr/logr/funcr"
 )
 
 func TestNewEfiVarList(t *testing.T) {
@@ -48,6 +51,30 @@ func TestEfiVarList_Create(t *testing.T) {
 	}
 }
 
+func TestEfiVarList_GroupByGuid(t *testing.T) {
+	l := NewEfiVarList()
+	vendor := StringToGUID(MicrosoftVendor)
+	for _, v := range []*EfiVar{
+		{Name: FromString("Timeout"), Guid: EFI_GLOBAL_VARIABLE_GUID},
+		{Name: FromString("BootOrder"), Guid: EFI_GLOBAL_VARIABLE_GUID},
+		{Name: FromString("Timeout"), Guid: vendor},
+	} {
+		l.Set(v)
+	}
+
+	groups := l.GroupByGuid()
+	if len(groups) != 2 {
+		t.Fatalf("GroupByGuid() has %d groups, want 2", len(groups))
+	}
+	global := groups[EFI_GLOBAL_VARIABLE_GUID]
+	if len(global) != 2 || global[0].Name.String() != "BootOrder" || global[1].Name.String() != "Timeout" {
+		t.Errorf("global group = %v, want BootOrder, Timeout", global)
+	}
+	if len(groups[vendor]) != 1 {
+		t.Errorf("vendor group = %v, want Timeout", groups[vendor])
+	}
+}
+
 func TestEfiVarList_Delete(t *testing.T) {
 	type args struct {
 		name string
@@ -428,3 +455,127 @@ func TestEfiVarList_FindByPrefix(t *testing.T) {
 		})
 	}
 }
+
+func TestParseVarKey(t *testing.T) {
+	tests := []struct {
+		name     string
+		key      string
+		wantName string
+		wantGuid GUID
+		wantOk   bool
+	}{
+		{
+			name:     "qualified key",
+			key:      "BootOrder-" + EFI_GLOBAL_VARIABLE,
+			wantName: "BootOrder",
+			wantGuid: EFI_GLOBAL_VARIABLE_GUID,
+			wantOk:   true,
+		},
+		{
+			name:     "name containing dashes",
+			key:      "Attempt-1-" + EFI_GLOBAL_VARIABLE,
+			wantName: "Attempt-1",
+			wantGuid: EFI_GLOBAL_VARIABLE_GUID,
+			wantOk:   true,
+		},
+		{
+			name:   "plain name",
+			key:    "BootOrder",
+			wantOk: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotName, gotGuid, gotOk := ParseVarKey(tt.key)
+			if gotOk != tt.wantOk || gotName != tt.wantName || gotGuid != tt.wantGuid {
+				t.Errorf("ParseVarKey() = %v, %v, %v, want %v, %v, %v",
+					gotName, gotGuid, gotOk, tt.wantName, tt.wantGuid, tt.wantOk)
+			}
+		})
+	}
+}
+
+func TestEfiVarList_SameNameDifferentGuid(t *testing.T) {
+	l := NewEfiVarList()
+	global := &EfiVar{Name: FromString("Setup"), Guid: EFI_GLOBAL_VARIABLE_GUID, Data: []byte{1}}
+	vendor := &EfiVar{Name: FromString("Setup"), Guid: MICROSOFT_GUID, Data: []byte{2}}
+
+	if err := l.Add(global); err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+	if err := l.Add(vendor); err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+	if err := l.Add(vendor); err == nil {
+		t.Errorf("Add() of duplicate variable should fail")
+	}
+	if len(l) != 2 {
+		t.Fatalf("len(list) = %d, want 2", len(l))
+	}
+
+	if got, ok := l.Get("Setup", MICROSOFT_GUID); !ok || got != vendor {
+		t.Errorf("Get() = %v, %v, want vendor variable", got, ok)
+	}
+	if got, ok := l.Lookup("Setup"); !ok || got != global {
+		t.Errorf("Lookup() = %v, %v, want global variable", got, ok)
+	}
+	if got, ok := l.Lookup(vendor.Key()); !ok || got != vendor {
+		t.Errorf("Lookup(key) = %v, %v, want vendor variable", got, ok)
+	}
+
+	byName := l.ByName()
+	if _, ok := byName["Setup"]; ok {
+		t.Errorf("ByName() should qualify colliding names")
+	}
+	if len(byName) != 2 {
+		t.Errorf("len(ByName()) = %d, want 2", len(byName))
+	}
+
+	if !l.Remove("Setup", EFI_GLOBAL_VARIABLE_GUID) {
+		t.Fatalf("Remove() = false, want true")
+	}
+	if got, ok := l.Lookup("Setup"); !ok || got != vendor {
+		t.Errorf("Lookup() after Remove() = %v, %v, want vendor variable", got, ok)
+	}
+	if _, ok := l.ByName()["Setup"]; !ok {
+		t.Errorf("ByName() should use the plain name once it is unique")
+	}
+}
+
+func TestEfiVarList_SetReplacesPlainKey(t *testing.T) {
+	l := EfiVarList{
+		"Timeout": {Name: FromString("Timeout"), Guid: EFI_GLOBAL_VARIABLE_GUID, Data: []byte{5, 0}},
+	}
+	l.Set(&EfiVar{Name: FromString("Timeout"), Guid: EFI_GLOBAL_VARIABLE_GUID, Data: []byte{1, 0}})
+
+	if len(l) != 1 {
+		t.Fatalf("len(list) = %d, want 1", len(l))
+	}
+	got, ok := l.Lookup("Timeout")
+	if !ok || !reflect.DeepEqual(got.Data, []byte{1, 0}) {
+		t.Errorf("Lookup() = %v, %v, want updated variable", got, ok)
+	}
+}
+
+func TestSetLogger(t *testing.T) {
+	var lines []string
+	SetLogger(funcr.New(func(prefix, args string) {
+		lines = append(lines, args)
+	}, funcr.Options{Verbosity: 1}))
+	t.Cleanup(func() { SetLogger(logr.Discard()) })
+
+	l := NewEfiVarList()
+	if err := l.SetBootNext(3); err != nil {
+		t.Fatal(err)
+	}
+	l.Delete("Missing")
+
+	want := []string{
+		`"level"=1 "msg"="created variable" "name"="BootNext"`,
+		`"level"=1 "msg"="set variable" "name"="BootNext" "value"="0x0003"`,
+		`"level"=1 "msg"="variable to delete not found" "name"="Missing"`,
+	}
+	if !reflect.DeepEqual(lines, want) {
+		t.Errorf("logged %q, want %q", lines, want)
+	}
+}
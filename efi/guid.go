@@ -47,7 +47,7 @@ var GuidNameTable = map[string]string{
 	// protocols (also used for variables)
 	"59324945-ec44-4c0d-b1cd-9db139df070c": "EfiIScsiInitiatorNameProtocol",
 	EfiDhcp6ServiceBindingProtocol:         "EfiDhcp6ServiceBindingProtocol",
-	"5b446ed1-e30b-4faa-871a-3654eca36080": "EfiIp4Config2Protocol",
+	EfiIp4Config2Protocol:                  "EfiIp4Config2Protocol",
 	EfiIp6ConfigProtocol:                   "EfiIp6ConfigProtocol",
 
 	// signature list types
@@ -0,0 +1,324 @@
+package efi
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+)
+
+// Ip4Config2 data types (EFI_IP4_CONFIG2_DATA_TYPE).
+const (
+	ip4Config2InterfaceInfo = 0
+	ip4Config2Policy        = 1
+	ip4Config2ManualAddress = 2
+	ip4Config2Gateway       = 3
+	ip4Config2DNSServer     = 4
+)
+
+// Ip6Config data types (EFI_IP6_CONFIG_DATA_TYPE).
+const (
+	ip6ConfigInterfaceInfo  = 0
+	ip6ConfigAltInterfaceID = 1
+	ip6ConfigPolicy         = 2
+	ip6ConfigDupAddrDetect  = 3
+	ip6ConfigManualAddress  = 4
+	ip6ConfigGateway        = 5
+	ip6ConfigDNSServer      = 6
+)
+
+const (
+	ip4Config2PolicyStatic = 0
+	ip4Config2PolicyDhcp   = 1
+
+	ip6ConfigPolicyManual    = 0
+	ip6ConfigPolicyAutomatic = 1
+
+	// ipConfigRecordSize is the size of a data record: UINT16 Offset,
+	// padding, UINT32 DataSize and the UINT32 data type.
+	ipConfigRecordSize = 12
+	// ip6ManualAddressSize is the size of EFI_IP6_CONFIG_MANUAL_ADDRESS.
+	ip6ManualAddressSize = 18
+)
+
+// ErrInvalidIPConfig is returned for malformed IP configuration variables.
+var ErrInvalidIPConfig = errors.New("invalid IP configuration variable")
+
+// IPConfigVarName returns the name under which EDK2 stores the IP
+// configuration of the network interface with the given MAC address.
+func IPConfigVarName(mac net.HardwareAddr) string {
+	return strings.ToUpper(strings.ReplaceAll(mac.String(), ":", ""))
+}
+
+// ipConfigRecord is one data item of an IP configuration variable.
+type ipConfigRecord struct {
+	dataType uint32
+	data     []byte
+}
+
+// IP4Config is the IP4_CONFIG2_VARIABLE that EDK2 stores per interface
+// under the EfiIp4Config2Protocol GUID.
+type IP4Config struct {
+	DHCP       bool
+	Address    net.IP
+	SubnetMask net.IPMask
+	Gateway    net.IP
+	DNSServers []net.IP
+
+	// other holds the data items that are not decoded.
+	other []ipConfigRecord
+}
+
+// IP6Config is the IP6_CONFIG_VARIABLE that EDK2 stores per interface
+// under the EfiIp6ConfigProtocol GUID.
+type IP6Config struct {
+	IaID         uint32
+	Automatic    bool
+	Address      net.IP
+	PrefixLength uint8
+	Gateway      net.IP
+	DNSServers   []net.IP
+
+	// other holds the data items that are not decoded.
+	other []ipConfigRecord
+}
+
+// ParseIP4Config parses an Ip4Config2 variable.
+func ParseIP4Config(data []byte) (*IP4Config, error) {
+	records, err := parseIPConfig(data, 4)
+	if err != nil {
+		return nil, err
+	}
+	c := &IP4Config{DHCP: true}
+	for _, r := range records {
+		switch r.dataType {
+		case ip4Config2Policy:
+			if len(r.data) != 4 {
+				return nil, fmt.Errorf("%w: policy of %d bytes", ErrInvalidIPConfig, len(r.data))
+			}
+			c.DHCP = binary.LittleEndian.Uint32(r.data) == ip4Config2PolicyDhcp
+		case ip4Config2ManualAddress:
+			if len(r.data) != 8 {
+				return nil, fmt.Errorf("%w: manual address of %d bytes", ErrInvalidIPConfig, len(r.data))
+			}
+			c.Address = net.IP(append([]byte{}, r.data[0:4]...))
+			c.SubnetMask = net.IPMask(append([]byte{}, r.data[4:8]...))
+		case ip4Config2Gateway:
+			if len(r.data) >= 4 {
+				c.Gateway = net.IP(append([]byte{}, r.data[0:4]...))
+			}
+		case ip4Config2DNSServer:
+			c.DNSServers = splitIPs(r.data, net.IPv4len)
+		default:
+			c.other = append(c.other, r)
+		}
+	}
+	return c, nil
+}
+
+// Bytes encodes the configuration as an Ip4Config2 variable. Static
+// addresses are only stored with the static policy, as EDK2 does.
+func (c *IP4Config) Bytes() []byte {
+	records := append([]ipConfigRecord{}, c.other...)
+	policy := uint32(ip4Config2PolicyDhcp)
+	if !c.DHCP {
+		policy = ip4Config2PolicyStatic
+	}
+	records = append(records, ipConfigRecord{
+		dataType: ip4Config2Policy,
+		data:     binary.LittleEndian.AppendUint32(nil, policy),
+	})
+	if !c.DHCP {
+		if ip := c.Address.To4(); ip != nil && len(c.SubnetMask) == net.IPv4len {
+			records = append(records, ipConfigRecord{
+				dataType: ip4Config2ManualAddress,
+				data:     append(append([]byte{}, ip...), c.SubnetMask...),
+			})
+		}
+		if gw := c.Gateway.To4(); gw != nil {
+			records = append(records, ipConfigRecord{dataType: ip4Config2Gateway, data: gw})
+		}
+		if len(c.DNSServers) > 0 {
+			records = append(records, ipConfigRecord{
+				dataType: ip4Config2DNSServer,
+				data:     joinIPs(c.DNSServers, net.IPv4len),
+			})
+		}
+	}
+	return encodeIPConfig(nil, records)
+}
+
+// ParseIP6Config parses an Ip6Config variable.
+func ParseIP6Config(data []byte) (*IP6Config, error) {
+	if len(data) < 4 {
+		return nil, fmt.Errorf("%w: %d bytes", ErrInvalidIPConfig, len(data))
+	}
+	records, err := parseIPConfig(data, 8)
+	if err != nil {
+		return nil, err
+	}
+	c := &IP6Config{IaID: binary.LittleEndian.Uint32(data[0:4])}
+	for _, r := range records {
+		switch r.dataType {
+		case ip6ConfigPolicy:
+			if len(r.data) != 4 {
+				return nil, fmt.Errorf("%w: policy of %d bytes", ErrInvalidIPConfig, len(r.data))
+			}
+			c.Automatic = binary.LittleEndian.Uint32(r.data) == ip6ConfigPolicyAutomatic
+		case ip6ConfigManualAddress:
+			// Use the first unicast address.
+			for off := 0; off+ip6ManualAddressSize <= len(r.data); off += ip6ManualAddressSize {
+				if r.data[off+16] == 0 {
+					c.Address = net.IP(append([]byte{}, r.data[off:off+16]...))
+					c.PrefixLength = r.data[off+17]
+					break
+				}
+			}
+			if c.Address == nil {
+				c.other = append(c.other, r)
+			}
+		case ip6ConfigGateway:
+			if len(r.data) >= net.IPv6len {
+				c.Gateway = net.IP(append([]byte{}, r.data[0:16]...))
+			}
+		case ip6ConfigDNSServer:
+			c.DNSServers = splitIPs(r.data, net.IPv6len)
+		default:
+			c.other = append(c.other, r)
+		}
+	}
+	return c, nil
+}
+
+// Bytes encodes the configuration as an Ip6Config variable.
+func (c *IP6Config) Bytes() []byte {
+	records := append([]ipConfigRecord{}, c.other...)
+	policy := uint32(ip6ConfigPolicyManual)
+	if c.Automatic {
+		policy = ip6ConfigPolicyAutomatic
+	}
+	records = append(records, ipConfigRecord{
+		dataType: ip6ConfigPolicy,
+		data:     binary.LittleEndian.AppendUint32(nil, policy),
+	})
+	if !c.Automatic {
+		if ip := c.Address.To16(); ip != nil && c.Address.To4() == nil {
+			records = append(records, ipConfigRecord{
+				dataType: ip6ConfigManualAddress,
+				data:     append(append([]byte{}, ip...), 0, c.PrefixLength),
+			})
+		}
+		if gw := c.Gateway.To16(); gw != nil {
+			records = append(records, ipConfigRecord{dataType: ip6ConfigGateway, data: gw})
+		}
+		if len(c.DNSServers) > 0 {
+			records = append(records, ipConfigRecord{
+				dataType: ip6ConfigDNSServer,
+				data:     joinIPs(c.DNSServers, net.IPv6len),
+			})
+		}
+	}
+	return encodeIPConfig(binary.LittleEndian.AppendUint32(nil, c.IaID), records)
+}
+
+// parseIPConfig validates the checksum of an IP configuration variable
+// and returns its data records. The checksum and record count follow
+// headerSize-4 bytes of protocol specific header.
+func parseIPConfig(data []byte, headerSize int) ([]ipConfigRecord, error) {
+	if len(data) < headerSize {
+		return nil, fmt.Errorf("%w: %d bytes", ErrInvalidIPConfig, len(data))
+	}
+	if ipConfigChecksum(data) != 0xffff {
+		return nil, fmt.Errorf("%w: checksum mismatch", ErrInvalidIPConfig)
+	}
+	count := int(binary.LittleEndian.Uint16(data[headerSize-2:]))
+	if headerSize+count*ipConfigRecordSize > len(data) {
+		return nil, fmt.Errorf("%w: %d records do not fit", ErrInvalidIPConfig, count)
+	}
+
+	records := make([]ipConfigRecord, 0, count)
+	for i := range count {
+		r := data[headerSize+i*ipConfigRecordSize:]
+		off := int(binary.LittleEndian.Uint16(r[0:2]))
+		size := int(binary.LittleEndian.Uint32(r[4:8]))
+		if off < headerSize || size < 0 || off+size > len(data) {
+			return nil, fmt.Errorf("%w: record %d outside variable", ErrInvalidIPConfig, i)
+		}
+		records = append(records, ipConfigRecord{
+			dataType: binary.LittleEndian.Uint32(r[8:12]),
+			data:     append([]byte{}, data[off:off+size]...),
+		})
+	}
+	return records, nil
+}
+
+// encodeIPConfig lays out a variable like EDK2 does: the header, the
+// record table in data type order, then the record data packed backwards
+// from the end.
+func encodeIPConfig(prefix []byte, records []ipConfigRecord) []byte {
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].dataType < records[j].dataType
+	})
+
+	headerSize := len(prefix) + 4
+	size := headerSize + len(records)*ipConfigRecordSize
+	for _, r := range records {
+		size += len(r.data)
+	}
+
+	buf := make([]byte, size)
+	copy(buf, prefix)
+	binary.LittleEndian.PutUint16(buf[headerSize-2:], uint16(len(records)))
+	heap := size
+	for i, r := range records {
+		heap -= len(r.data)
+		copy(buf[heap:], r.data)
+		rec := buf[headerSize+i*ipConfigRecordSize:]
+		binary.LittleEndian.PutUint16(rec[0:2], uint16(heap))
+		binary.LittleEndian.PutUint32(rec[4:8], uint32(len(r.data)))
+		binary.LittleEndian.PutUint32(rec[8:12], r.dataType)
+	}
+	binary.LittleEndian.PutUint16(buf[headerSize-4:], ^ipConfigChecksum(buf))
+	return buf
+}
+
+// ipConfigChecksum is the ones' complement sum of the little endian 16-bit
+// words in data, as computed by NetblockChecksum.
+func ipConfigChecksum(data []byte) uint16 {
+	var sum uint32
+	for i := 0; i+1 < len(data); i += 2 {
+		sum += uint32(binary.LittleEndian.Uint16(data[i:]))
+	}
+	if len(data)%2 != 0 {
+		sum += uint32(data[len(data)-1])
+	}
+	for sum>>16 != 0 {
+		sum = sum&0xffff + sum>>16
+	}
+	return uint16(sum)
+}
+
+func splitIPs(data []byte, size int) []net.IP {
+	var ips []net.IP
+	for off := 0; off+size <= len(data); off += size {
+		ips = append(ips, net.IP(append([]byte{}, data[off:off+size]...)))
+	}
+	return ips
+}
+
+func joinIPs(ips []net.IP, size int) []byte {
+	var data []byte
+	for _, ip := range ips {
+		if size == net.IPv4len {
+			ip = ip.To4()
+		} else {
+			ip = ip.To16()
+		}
+		if ip != nil {
+			data = append(data, ip...)
+		}
+	}
+	return data
+}
@@ -0,0 +1,125 @@
+package efi
+
+import (
+	"encoding/hex"
+	"errors"
+	"net"
+	"reflect"
+	"testing"
+)
+
+// ip6ConfigFixture is the D83ADD5A440C Ip6Config variable from fw-test.json.
+const ip6ConfigFixture = "dcffdd5e19fe03003400440008000000010000003000430004000000020000002c" +
+	"00000004000000030000000100000000000000da3addfffe5a440c"
+
+func TestParseIP6Config(t *testing.T) {
+	data, _ := hex.DecodeString(ip6ConfigFixture)
+	c, err := ParseIP6Config(data)
+	if err != nil {
+		t.Fatalf("ParseIP6Config() error = %v", err)
+	}
+	if c.IaID != 0x5eddffdc || c.Automatic || c.Address != nil {
+		t.Errorf("ParseIP6Config() = %+v", c)
+	}
+
+	// Re-encoding keeps the records that are not decoded.
+	again, err := ParseIP6Config(c.Bytes())
+	if err != nil {
+		t.Fatalf("ParseIP6Config(Bytes()) error = %v", err)
+	}
+	if !reflect.DeepEqual(again, c) {
+		t.Errorf("round trip = %+v, want %+v", again, c)
+	}
+
+	c.Address = net.ParseIP("2001:db8::10")
+	c.PrefixLength = 64
+	c.Gateway = net.ParseIP("2001:db8::1")
+	c.DNSServers = []net.IP{net.ParseIP("2001:db8::53")}
+	again, err = ParseIP6Config(c.Bytes())
+	if err != nil {
+		t.Fatalf("ParseIP6Config(Bytes()) error = %v", err)
+	}
+	if !reflect.DeepEqual(again, c) {
+		t.Errorf("static round trip = %+v, want %+v", again, c)
+	}
+
+	data[len(data)-1] ^= 0xff
+	if _, err := ParseIP6Config(data); !errors.Is(err, ErrInvalidIPConfig) {
+		t.Errorf("corrupted variable: error = %v, want ErrInvalidIPConfig", err)
+	}
+}
+
+func TestIP4Config_Bytes(t *testing.T) {
+	tests := []struct {
+		name   string
+		config *IP4Config
+		want   *IP4Config
+	}{
+		{
+			name:   "dhcp",
+			config: &IP4Config{DHCP: true, Address: net.IPv4(10, 0, 0, 5).To4()},
+			want:   &IP4Config{DHCP: true},
+		},
+		{
+			name: "static",
+			config: &IP4Config{
+				Address:    net.IPv4(10, 0, 0, 5).To4(),
+				SubnetMask: net.CIDRMask(24, 32),
+				Gateway:    net.IPv4(10, 0, 0, 1).To4(),
+				DNSServers: []net.IP{net.IPv4(10, 0, 0, 53).To4(), net.IPv4(1, 1, 1, 1).To4()},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want := tt.want
+			if want == nil {
+				want = tt.config
+			}
+			got, err := ParseIP4Config(tt.config.Bytes())
+			if err != nil {
+				t.Fatalf("ParseIP4Config() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("ParseIP4Config() = %+v, want %+v", got, want)
+			}
+		})
+	}
+
+	if got := IPConfigVarName(net.HardwareAddr{0xd8, 0x3a, 0xdd, 0x5a, 0x44, 0x0c}); got != "D83ADD5A440C" {
+		t.Errorf("IPConfigVarName() = %q", got)
+	}
+}
+
+// isIPConfigName reports whether name is that of an Ip4Config2 or Ip6Config
+// variable, the MAC address of the interface in hex.
+func isIPConfigName(name string) bool {
+	_, err := hex.DecodeString(name)
+	return len(name) == 12 && err == nil
+}
+
+func FuzzParseIPConfig(f *testing.F) {
+	data, _ := hex.DecodeString(ip6ConfigFixture)
+	f.Add(data)
+	f.Add((&IP4Config{
+		Address:    net.IPv4(10, 0, 0, 5).To4(),
+		SubnetMask: net.CIDRMask(24, 32),
+		Gateway:    net.IPv4(10, 0, 0, 1).To4(),
+		DNSServers: []net.IP{net.IPv4(10, 0, 0, 53).To4()},
+	}).Bytes())
+	f.Add([]byte{})
+	addCorpusSeeds(f, isIPConfigName)
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if c, err := ParseIP4Config(data); err == nil {
+			if _, err := ParseIP4Config(c.Bytes()); err != nil {
+				t.Errorf("ParseIP4Config() of re-encoded config error = %v", err)
+			}
+		}
+		if c, err := ParseIP6Config(data); err == nil {
+			if _, err := ParseIP6Config(c.Bytes()); err != nil {
+				t.Errorf("ParseIP6Config() of re-encoded config error = %v", err)
+			}
+		}
+	})
+}
@@ -0,0 +1,91 @@
+package efi
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Variables of the EFI global namespace selecting the firmware language.
+const (
+	// PlatformLangVar holds the RFC 4646 language tag of the firmware UI.
+	PlatformLangVar = "PlatformLang"
+	// PlatformLangCodesVar holds the language tags the firmware supports,
+	// separated by ";". The firmware sets it on every boot and does not
+	// store it.
+	PlatformLangCodesVar = "PlatformLangCodes"
+	// LangVar is the deprecated ISO 639-2 form of PlatformLang. The
+	// firmware keeps it in sync with PlatformLang.
+	LangVar = "Lang"
+)
+
+// ValidateLangTag checks that tag is a well-formed RFC 4646 language tag:
+// subtags of 1 to 8 ASCII letters or digits separated by "-", starting
+// with a language of 2 to 8 letters or the private use singleton "x", and
+// with no singleton ending the tag.
+func ValidateLangTag(tag string) error {
+	if tag == "" {
+		return errors.New("empty language tag")
+	}
+	subtags := strings.Split(tag, "-")
+	for i, subtag := range subtags {
+		if len(subtag) == 0 || len(subtag) > 8 {
+			return fmt.Errorf("language tag %q: subtag %q is not 1 to 8 characters", tag, subtag)
+		}
+		for _, c := range subtag {
+			if !isAlpha(c) && (i == 0 || c < '0' || c > '9') {
+				return fmt.Errorf("language tag %q: invalid character %q", tag, c)
+			}
+		}
+		if len(subtag) == 1 && i == len(subtags)-1 {
+			return fmt.Errorf("language tag %q ends with singleton %q", tag, subtag)
+		}
+	}
+	if first := subtags[0]; len(first) == 1 && !strings.EqualFold(first, "x") {
+		return fmt.Errorf("language tag %q: language %q is too short", tag, first)
+	}
+	return nil
+}
+
+func isAlpha(c rune) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// ParseAscii decodes a null-terminated ASCII variable such as
+// PlatformLang. A missing terminator is tolerated.
+func ParseAscii(data []byte) (string, error) {
+	for i, c := range data {
+		if c == 0 {
+			return string(data[:i]), nil
+		}
+		if c > 0x7f {
+			return "", fmt.Errorf("non-ASCII byte 0x%02x at %d", c, i)
+		}
+	}
+	return string(data), nil
+}
+
+// AsciiBytes encodes s as a null-terminated ASCII variable.
+func AsciiBytes(s string) ([]byte, error) {
+	for i := range len(s) {
+		if s[i] == 0 || s[i] > 0x7f {
+			return nil, fmt.Errorf("invalid ASCII byte 0x%02x at %d", s[i], i)
+		}
+	}
+	return append([]byte(s), 0), nil
+}
+
+// ParsePlatformLangCodes decodes a PlatformLangCodes variable.
+func ParsePlatformLangCodes(data []byte) ([]string, error) {
+	s, err := ParseAscii(data)
+	if err != nil {
+		return nil, err
+	}
+	var codes []string
+	for code := range strings.SplitSeq(s, ";") {
+		if code != "" {
+			codes = append(codes, code)
+		}
+	}
+	return codes, nil
+}
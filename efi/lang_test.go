@@ -0,0 +1,52 @@
+package efi
+
+import (
+	"bytes"
+	"slices"
+	"testing"
+)
+
+func TestValidateLangTag(t *testing.T) {
+	for _, tag := range []string{"en", "en-US", "zh-Hant-TW", "sl-rozaj-biske", "de-CH-1901", "x-private", "en-a-bbb-x-a-ccc"} {
+		if err := ValidateLangTag(tag); err != nil {
+			t.Errorf("ValidateLangTag(%q) error = %v", tag, err)
+		}
+	}
+	for _, tag := range []string{"", "e", "en_US", "en-", "-US", "en--US", "en-US-x", "1en", "en-toolongsubtag", "fr-é"} {
+		if err := ValidateLangTag(tag); err == nil {
+			t.Errorf("ValidateLangTag(%q) succeeded", tag)
+		}
+	}
+}
+
+func TestAscii(t *testing.T) {
+	data, err := AsciiBytes("en-US")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []byte("en-US\x00"); !bytes.Equal(data, want) {
+		t.Errorf("AsciiBytes() = %x, want %x", data, want)
+	}
+	if _, err := AsciiBytes("café"); err == nil {
+		t.Error("AsciiBytes() accepted non-ASCII")
+	}
+
+	for _, data := range [][]byte{[]byte("en-US\x00"), []byte("en-US"), []byte("en-US\x00\xff")} {
+		if s, err := ParseAscii(data); err != nil || s != "en-US" {
+			t.Errorf("ParseAscii(%x) = %q, %v, want en-US", data, s, err)
+		}
+	}
+	if _, err := ParseAscii([]byte{'e', 0x80, 0}); err == nil {
+		t.Error("ParseAscii() accepted non-ASCII")
+	}
+}
+
+func TestParsePlatformLangCodes(t *testing.T) {
+	codes, err := ParsePlatformLangCodes([]byte("en-US;fr-FR;\x00"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"en-US", "fr-FR"}; !slices.Equal(codes, want) {
+		t.Errorf("ParsePlatformLangCodes() = %q, want %q", codes, want)
+	}
+}
@@ -0,0 +1,13 @@
+package efi
+
+import "github.com/go-logr/logr"
+
+// logger receives changes made through EfiVarList at level 1. It discards
+// everything until SetLogger is called.
+var logger = logr.Discard()
+
+// SetLogger sets the logger of the package. It is not safe to call while
+// variable lists are being modified.
+func SetLogger(l logr.Logger) {
+	logger = l
+}
@@ -0,0 +1,86 @@
+package efi
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+)
+
+// MemoryTypeInformationVar is the variable, in the EfiMemoryTypeInformation
+// namespace, in which the boot manager records how many pages of each
+// memory type the previous boots used. The firmware sizes its memory map
+// bins from it and resets the machine when a bin was too small.
+const MemoryTypeInformationVar = "MemoryTypeInformation"
+
+// MemoryType is an EFI_MEMORY_TYPE.
+type MemoryType uint32
+
+var memoryTypeNames = []string{
+	"EfiReservedMemoryType",
+	"EfiLoaderCode",
+	"EfiLoaderData",
+	"EfiBootServicesCode",
+	"EfiBootServicesData",
+	"EfiRuntimeServicesCode",
+	"EfiRuntimeServicesData",
+	"EfiConventionalMemory",
+	"EfiUnusableMemory",
+	"EfiACPIReclaimMemory",
+	"EfiACPIMemoryNVS",
+	"EfiMemoryMappedIO",
+	"EfiMemoryMappedIOPortSpace",
+	"EfiPalCode",
+	"EfiPersistentMemory",
+	"EfiUnacceptedMemoryType",
+}
+
+func (t MemoryType) String() string {
+	if int(t) < len(memoryTypeNames) {
+		return memoryTypeNames[t]
+	}
+	return fmt.Sprintf("0x%08x", uint32(t))
+}
+
+// MemoryTypeInformationEntry is an EFI_MEMORY_TYPE_INFORMATION.
+type MemoryTypeInformationEntry struct {
+	Type          MemoryType
+	NumberOfPages uint32
+}
+
+// MemoryTypeInformation is the MemoryTypeInformation variable. Its last
+// entry is a terminator whose Type is the EfiMaxMemoryType of the firmware.
+type MemoryTypeInformation []MemoryTypeInformationEntry
+
+// NewMemoryTypeInformation decodes a MemoryTypeInformation variable.
+func NewMemoryTypeInformation(data []byte) (MemoryTypeInformation, error) {
+	if len(data)%8 != 0 {
+		return nil, fmt.Errorf("MemoryTypeInformation is %d bytes, want a multiple of 8", len(data))
+	}
+	info := make(MemoryTypeInformation, len(data)/8)
+	for i := range info {
+		info[i] = MemoryTypeInformationEntry{
+			Type:          MemoryType(binary.LittleEndian.Uint32(data[i*8:])),
+			NumberOfPages: binary.LittleEndian.Uint32(data[i*8+4:]),
+		}
+	}
+	return info, nil
+}
+
+// Bytes encodes the entries as stored in the variable.
+func (m MemoryTypeInformation) Bytes() []byte {
+	buf := make([]byte, 0, len(m)*8)
+	for _, e := range m {
+		buf = binary.LittleEndian.AppendUint32(buf, uint32(e.Type))
+		buf = binary.LittleEndian.AppendUint32(buf, e.NumberOfPages)
+	}
+	return buf
+}
+
+// String lists the entries as "type=pages", separated by ", ".
+func (m MemoryTypeInformation) String() string {
+	entries := make([]string, len(m))
+	for i, e := range m {
+		entries[i] = fmt.Sprintf("%s=%d", e.Type, e.NumberOfPages)
+	}
+	return strings.Join(entries, ", ")
+}
@@ -0,0 +1,39 @@
+package efi
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+func TestMemoryTypeInformation(t *testing.T) {
+	// From a Raspberry Pi 4 varstore.
+	data, err := hex.DecodeString("09000000040000000a000000000000000000000078000000" +
+		"060000005c030000050000004803000003000000dc05000004000000e02e0000" +
+		"010000002b00000002000000000000001000000000000000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := NewMemoryTypeInformation(data)
+	if err != nil {
+		t.Fatalf("NewMemoryTypeInformation() error = %v", err)
+	}
+	if len(info) != 10 {
+		t.Fatalf("got %d entries, want 10", len(info))
+	}
+	if e := info[6]; e.Type.String() != "EfiBootServicesData" || e.NumberOfPages != 0x2ee0 {
+		t.Errorf("entry 6 = %s=%d, want EfiBootServicesData=12000", e.Type, e.NumberOfPages)
+	}
+	if got := info[9].Type.String(); got != "0x00000010" {
+		t.Errorf("terminator type = %s, want 0x00000010", got)
+	}
+	if !bytes.Equal(info.Bytes(), data) {
+		t.Errorf("Bytes() = %x, want %x", info.Bytes(), data)
+	}
+	if got, want := info[:2].String(), "EfiACPIReclaimMemory=4, EfiACPIMemoryNVS=0"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+	if _, err := NewMemoryTypeInformation(data[:12]); err == nil {
+		t.Error("NewMemoryTypeInformation() accepted a partial entry")
+	}
+}
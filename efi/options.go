@@ -0,0 +1,43 @@
+package efi
+
+import "fmt"
+
+// ParseOptions controls how strictly binary firmware structures are parsed.
+// The zero value is lenient: it accepts anything that can be decoded.
+type ParseOptions struct {
+	// Strict rejects input that decodes but violates the UEFI specification,
+	// such as variables with invalid attribute combinations or boot entries
+	// with a malformed file path list.
+	Strict bool
+	// MaxVars limits the number of variables accepted from a varstore.
+	// Zero means no limit.
+	MaxVars int
+	// AllowUnknownGUIDs accepts vendor GUIDs missing from GuidNameTable in
+	// strict mode. Lenient parsing always accepts them.
+	AllowUnknownGUIDs bool
+}
+
+// StrictParseOptions is suitable for validating firmware images in CI.
+var StrictParseOptions = ParseOptions{Strict: true}
+
+// CheckVar reports whether a decoded variable is acceptable under opts.
+func (opts ParseOptions) CheckVar(v *EfiVar) error {
+	if !opts.Strict {
+		return nil
+	}
+	if err := v.ValidateStored(); err != nil {
+		return err
+	}
+	if _, known := GuidNameTable[v.Guid.String()]; !known && !opts.AllowUnknownGUIDs {
+		return fmt.Errorf("variable %s has unknown vendor guid %s", v.Name, v.Guid)
+	}
+	return nil
+}
+
+// CheckCount reports whether n variables are within the MaxVars limit.
+func (opts ParseOptions) CheckCount(n int) error {
+	if opts.MaxVars > 0 && n > opts.MaxVars {
+		return fmt.Errorf("too many variables: more than %d", opts.MaxVars)
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package efi
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseOptions_CheckVar(t *testing.T) {
+	known := &EfiVar{Name: FromString("Timeout"), Guid: EFI_GLOBAL_VARIABLE_GUID, Attr: EfiVariableDefault}
+	unknown := &EfiVar{
+		Name: FromString("Timeout"),
+		Guid: StringToGUID("01234567-89ab-cdef-0123-456789abcdef"),
+		Attr: EfiVariableDefault,
+	}
+	invalid := &EfiVar{
+		Name: FromString("Timeout"),
+		Guid: EFI_GLOBAL_VARIABLE_GUID,
+		Attr: EfiVariableNonVolatile | EfiVariableRuntimeAccess,
+	}
+	tests := []struct {
+		name    string
+		opts    ParseOptions
+		v       *EfiVar
+		wantErr bool
+	}{
+		{name: "lenient accepts invalid attributes", opts: ParseOptions{}, v: invalid},
+		{name: "lenient accepts unknown guid", opts: ParseOptions{}, v: unknown},
+		{name: "strict accepts known guid", opts: StrictParseOptions, v: known},
+		{name: "strict rejects invalid attributes", opts: StrictParseOptions, v: invalid, wantErr: true},
+		{name: "strict rejects unknown guid", opts: StrictParseOptions, v: unknown, wantErr: true},
+		{
+			name: "strict allows unknown guid when asked",
+			opts: ParseOptions{Strict: true, AllowUnknownGUIDs: true},
+			v:    unknown,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.opts.CheckVar(tt.v); (err != nil) != tt.wantErr {
+				t.Errorf("ParseOptions.CheckVar() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseEfiVarWithOptions(t *testing.T) {
+	blob, err := (&EfiVar{
+		Name: FromString("Timeout"),
+		Guid: EFI_GLOBAL_VARIABLE_GUID,
+		Attr: EfiVariableRuntimeAccess,
+		Data: []byte{0x05, 0x00},
+	}).Serialize()
+	if err != nil {
+		t.Fatalf("EfiVar.Serialize() error = %v", err)
+	}
+	if _, err := ParseEfiVarWithOptions(blob, ParseOptions{}); err != nil {
+		t.Errorf("ParseEfiVarWithOptions() lenient error = %v", err)
+	}
+	if _, err := ParseEfiVarWithOptions(blob, StrictParseOptions); !errors.Is(err, ErrInvalidVariable) {
+		t.Errorf("ParseEfiVarWithOptions() strict error = %v, want ErrInvalidVariable", err)
+	}
+}
+
+func TestParseOptions_CheckCount(t *testing.T) {
+	if err := (ParseOptions{}).CheckCount(1 << 20); err != nil {
+		t.Errorf("ParseOptions.CheckCount() without limit error = %v", err)
+	}
+	if err := (ParseOptions{MaxVars: 2}).CheckCount(2); err != nil {
+		t.Errorf("ParseOptions.CheckCount() at limit error = %v", err)
+	}
+	if err := (ParseOptions{MaxVars: 2}).CheckCount(3); err == nil {
+		t.Error("ParseOptions.CheckCount() over limit error = nil")
+	}
+}
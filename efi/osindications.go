@@ -0,0 +1,86 @@
+package efi
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+)
+
+// Variables of the EFI global namespace through which the OS asks the
+// firmware for actions on the next boot.
+const (
+	// OsIndicationsVar holds the OsIndications requested by the OS. The
+	// firmware clears a request when it acts on it.
+	OsIndicationsVar = "OsIndications"
+	// OsIndicationsSupportedVar holds the OsIndications the firmware
+	// supports. The firmware sets it on every boot and does not store it.
+	OsIndicationsSupportedVar = "OsIndicationsSupported"
+)
+
+// OsIndications bits (EFI_OS_INDICATIONS_*).
+const (
+	OsIndicationsBootToFwUI                   OsIndications = 0x0001
+	OsIndicationsTimestampRevocation          OsIndications = 0x0002
+	OsIndicationsFileCapsuleDeliverySupported OsIndications = 0x0004
+	OsIndicationsFmpCapsuleSupported          OsIndications = 0x0008
+	OsIndicationsCapsuleResultVarSupported    OsIndications = 0x0010
+	OsIndicationsStartOsRecovery              OsIndications = 0x0020
+	OsIndicationsStartPlatformRecovery        OsIndications = 0x0040
+	OsIndicationsJsonConfigDataRefresh        OsIndications = 0x0080
+)
+
+var osIndicationsNames = []struct {
+	bit  OsIndications
+	name string
+}{
+	{OsIndicationsBootToFwUI, "BootToFwUI"},
+	{OsIndicationsTimestampRevocation, "TimestampRevocation"},
+	{OsIndicationsFileCapsuleDeliverySupported, "FileCapsuleDeliverySupported"},
+	{OsIndicationsFmpCapsuleSupported, "FmpCapsuleSupported"},
+	{OsIndicationsCapsuleResultVarSupported, "CapsuleResultVarSupported"},
+	{OsIndicationsStartOsRecovery, "StartOsRecovery"},
+	{OsIndicationsStartPlatformRecovery, "StartPlatformRecovery"},
+	{OsIndicationsJsonConfigDataRefresh, "JsonConfigDataRefresh"},
+}
+
+// OsIndications is the UINT64 bit mask of the OsIndications and
+// OsIndicationsSupported variables.
+type OsIndications uint64
+
+// NewOsIndications decodes an OsIndications or OsIndicationsSupported
+// variable.
+func NewOsIndications(data []byte) (OsIndications, error) {
+	if len(data) != 8 {
+		return 0, fmt.Errorf("OsIndications is %d bytes, want 8", len(data))
+	}
+	return OsIndications(binary.LittleEndian.Uint64(data)), nil
+}
+
+// Has reports whether all bits of mask are set.
+func (o OsIndications) Has(mask OsIndications) bool {
+	return o&mask == mask
+}
+
+// Bytes encodes the mask as stored in the variable.
+func (o OsIndications) Bytes() []byte {
+	return binary.LittleEndian.AppendUint64(nil, uint64(o))
+}
+
+// String lists the names of the set bits separated by "|", with unknown
+// bits in hex.
+func (o OsIndications) String() string {
+	if o == 0 {
+		return "0"
+	}
+	var names []string
+	for _, n := range osIndicationsNames {
+		if o.Has(n.bit) {
+			names = append(names, n.name)
+			o &^= n.bit
+		}
+	}
+	if o != 0 {
+		names = append(names, fmt.Sprintf("0x%x", uint64(o)))
+	}
+	return strings.Join(names, "|")
+}
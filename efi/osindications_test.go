@@ -0,0 +1,29 @@
+package efi
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestOsIndications(t *testing.T) {
+	data := []byte{0x41, 0, 0, 0, 0, 0, 0, 0x80}
+	ind, err := NewOsIndications(data)
+	if err != nil {
+		t.Fatalf("NewOsIndications() error = %v", err)
+	}
+	if !ind.Has(OsIndicationsBootToFwUI) || ind.Has(OsIndicationsBootToFwUI|OsIndicationsStartOsRecovery) {
+		t.Errorf("Has() wrong for %s", ind)
+	}
+	if got, want := ind.String(), "BootToFwUI|StartPlatformRecovery|0x8000000000000000"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+	if !bytes.Equal(ind.Bytes(), data) {
+		t.Errorf("Bytes() = %x, want %x", ind.Bytes(), data)
+	}
+	if OsIndications(0).String() != "0" {
+		t.Errorf("String() of no bits = %q", OsIndications(0).String())
+	}
+	if _, err := NewOsIndications(data[:4]); err == nil {
+		t.Error("NewOsIndications() accepted 4 bytes")
+	}
+}
@@ -0,0 +1,85 @@
+package efi
+
+import (
+	"crypto/hmac"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"unicode/utf8"
+)
+
+// SetupPasswordVar is the variable of the EDK2 user authentication driver
+// (UserAuthFeaturePkg) holding the setup password hash, under the
+// UserAuthentication GUID.
+const SetupPasswordVar = "Password"
+
+const (
+	setupPasswordHashSize   = 32
+	setupPasswordSaltSize   = 32
+	setupPasswordIterations = 1000
+	// SetupPasswordMaxLen is the longest password the driver accepts, in
+	// characters.
+	SetupPasswordMaxLen = 32
+)
+
+// ErrInvalidPassword is returned for passwords the firmware cannot accept.
+var ErrInvalidPassword = errors.New("invalid setup password")
+
+// SetupPassword is USER_PASSWORD_VAR_STRUCT: a PBKDF2-HMAC-SHA256 hash of
+// the UCS-2 password, without terminator, and its salt.
+type SetupPassword struct {
+	Hash [setupPasswordHashSize]byte
+	Salt [setupPasswordSaltSize]byte
+}
+
+// NewSetupPassword hashes password with a random salt.
+func NewSetupPassword(password string) (*SetupPassword, error) {
+	p := &SetupPassword{}
+	if _, err := rand.Read(p.Salt[:]); err != nil {
+		return nil, err
+	}
+	hash, err := hashSetupPassword(password, p.Salt[:])
+	if err != nil {
+		return nil, err
+	}
+	copy(p.Hash[:], hash)
+	return p, nil
+}
+
+// ParseSetupPassword decodes a USER_PASSWORD_VAR_STRUCT.
+func ParseSetupPassword(data []byte) (*SetupPassword, error) {
+	if len(data) != setupPasswordHashSize+setupPasswordSaltSize {
+		return nil, fmt.Errorf("setup password data is %d bytes, want %d",
+			len(data), setupPasswordHashSize+setupPasswordSaltSize)
+	}
+	p := &SetupPassword{}
+	copy(p.Hash[:], data)
+	copy(p.Salt[:], data[setupPasswordHashSize:])
+	return p, nil
+}
+
+// Bytes encodes the structure as stored in the variable.
+func (p *SetupPassword) Bytes() []byte {
+	return append(p.Hash[:], p.Salt[:]...)
+}
+
+// Verify reports whether password matches the hash.
+func (p *SetupPassword) Verify(password string) bool {
+	hash, err := hashSetupPassword(password, p.Salt[:])
+	return err == nil && hmac.Equal(hash, p.Hash[:])
+}
+
+func hashSetupPassword(password string, salt []byte) ([]byte, error) {
+	if password == "" || utf8.RuneCountInString(password) > SetupPasswordMaxLen {
+		return nil, fmt.Errorf("%w: must be 1 to %d characters", ErrInvalidPassword, SetupPasswordMaxLen)
+	}
+	s, err := UCS16FromString(password)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidPassword, err)
+	}
+	ucs := s.Bytes()
+	ucs = ucs[:len(ucs)-2]
+	return pbkdf2.Key(sha256.New, string(ucs), salt, setupPasswordIterations, setupPasswordHashSize)
+}
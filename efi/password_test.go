@@ -0,0 +1,41 @@
+package efi
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestSetupPassword(t *testing.T) {
+	p, err := NewSetupPassword("s3cret")
+	if err != nil {
+		t.Fatalf("NewSetupPassword() error = %v", err)
+	}
+	parsed, err := ParseSetupPassword(p.Bytes())
+	if err != nil {
+		t.Fatalf("ParseSetupPassword() error = %v", err)
+	}
+	if *parsed != *p {
+		t.Errorf("ParseSetupPassword() = %+v, want %+v", parsed, p)
+	}
+	if !parsed.Verify("s3cret") {
+		t.Error("Verify() rejected the password")
+	}
+	if parsed.Verify("s3cre") || parsed.Verify("") {
+		t.Error("Verify() accepted a wrong password")
+	}
+
+	other, err := NewSetupPassword("s3cret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other.Hash == p.Hash {
+		t.Error("NewSetupPassword() reused a salt")
+	}
+
+	for _, password := range []string{"", strings.Repeat("x", SetupPasswordMaxLen+1), "a\x00b"} {
+		if _, err := NewSetupPassword(password); !errors.Is(err, ErrInvalidPassword) {
+			t.Errorf("NewSetupPassword(%q) error = %v, want %v", password, err, ErrInvalidPassword)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package efi
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+// signatureListHeaderSize is the size of the fixed EFI_SIGNATURE_LIST header.
+const signatureListHeaderSize = 16 + 4 + 4 + 4
+
+// SignatureList represents an EFI_SIGNATURE_LIST as stored in the PK, KEK,
+// db and dbx variables.
+type SignatureList struct {
+	Type GUID
+	// Header is the signature type specific header, usually empty.
+	Header []byte
+	// SignatureSize is the size of each EFI_SIGNATURE_DATA entry, including
+	// the owner GUID. If zero, Serialize derives it from the signatures.
+	SignatureSize uint32
+	Signatures    []SignatureData
+}
+
+// SignatureData represents an EFI_SIGNATURE_DATA entry.
+type SignatureData struct {
+	Owner GUID
+	Data  []byte
+}
+
+// ParseSignatureLists parses the concatenated EFI_SIGNATURE_LIST structures
+// of a signature database variable.
+func ParseSignatureLists(data []byte) ([]SignatureList, error) {
+	lists := []SignatureList{}
+	pos := 0
+	for pos < len(data) {
+		if len(data)-pos < signatureListHeaderSize {
+			return nil, fmt.Errorf("truncated signature list header at offset %d", pos)
+		}
+		listSize := binary.LittleEndian.Uint32(data[pos+16:])
+		headerSize := binary.LittleEndian.Uint32(data[pos+20:])
+		sigSize := binary.LittleEndian.Uint32(data[pos+24:])
+
+		if uint64(listSize) > uint64(len(data)-pos) {
+			return nil, fmt.Errorf("signature list at offset %d overruns data: size %d", pos, listSize)
+		}
+		if uint64(listSize) < signatureListHeaderSize+uint64(headerSize) {
+			return nil, fmt.Errorf("signature list at offset %d too small for its header: size %d",
+				pos, listSize)
+		}
+		if sigSize < 16 {
+			return nil, fmt.Errorf("signature list at offset %d has invalid signature size %d",
+				pos, sigSize)
+		}
+		sigStart := pos + signatureListHeaderSize + int(headerSize)
+		listEnd := pos + int(listSize)
+		if (listEnd-sigStart)%int(sigSize) != 0 {
+			return nil, fmt.Errorf("signature list at offset %d is not a multiple of signature size %d",
+				pos, sigSize)
+		}
+
+		list := SignatureList{
+			Type:          ParseBinGUID(data, pos),
+			Header:        data[pos+signatureListHeaderSize : sigStart],
+			SignatureSize: sigSize,
+			Signatures:    []SignatureData{},
+		}
+		for sig := sigStart; sig < listEnd; sig += int(sigSize) {
+			list.Signatures = append(list.Signatures, SignatureData{
+				Owner: ParseBinGUID(data, sig),
+				Data:  data[sig+16 : sig+int(sigSize)],
+			})
+		}
+		lists = append(lists, list)
+		pos = listEnd
+	}
+	return lists, nil
+}
+
+// Serialize returns the binary EFI_SIGNATURE_LIST.
+func (l *SignatureList) Serialize() ([]byte, error) {
+	sigSize := l.SignatureSize
+	if sigSize == 0 {
+		if len(l.Signatures) == 0 {
+			return nil, fmt.Errorf("signature list has no signatures and no signature size")
+		}
+		sigSize = uint32(16 + len(l.Signatures[0].Data))
+	}
+	for i, sig := range l.Signatures {
+		if uint64(16+len(sig.Data)) != uint64(sigSize) {
+			return nil, fmt.Errorf("signature %d is %d bytes, list signature size is %d",
+				i, 16+len(sig.Data), sigSize)
+		}
+	}
+	listSize := uint64(signatureListHeaderSize) + uint64(len(l.Header)) +
+		uint64(len(l.Signatures))*uint64(sigSize)
+	if listSize > math.MaxUint32 {
+		return nil, fmt.Errorf("signature list too large: %d bytes", listSize)
+	}
+
+	buf := new(bytes.Buffer)
+	buf.Write(l.Type.Bytes())
+	_ = binary.Write(buf, binary.LittleEndian, uint32(listSize))
+	_ = binary.Write(buf, binary.LittleEndian, uint32(len(l.Header)))
+	_ = binary.Write(buf, binary.LittleEndian, sigSize)
+	buf.Write(l.Header)
+	for _, sig := range l.Signatures {
+		buf.Write(sig.Owner.Bytes())
+		buf.Write(sig.Data)
+	}
+	return buf.Bytes(), nil
+}
+
+// SerializeSignatureLists returns the concatenated binary signature lists.
+func SerializeSignatureLists(lists []SignatureList) ([]byte, error) {
+	var blob []byte
+	for i := range lists {
+		data, err := lists[i].Serialize()
+		if err != nil {
+			return nil, fmt.Errorf("signature list %d: %w", i, err)
+		}
+		blob = append(blob, data...)
+	}
+	return blob, nil
+}
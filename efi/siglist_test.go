@@ -0,0 +1,130 @@
+package efi
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/quick"
+)
+
+func TestParseSignatureLists(t *testing.T) {
+	sha256 := SignatureList{
+		Type:          StringToGUID(EfiCertSha256),
+		Header:        []byte{},
+		SignatureSize: 16 + 32,
+		Signatures: []SignatureData{
+			{Owner: StringToGUID(MicrosoftVendor), Data: bytes.Repeat([]byte{0xaa}, 32)},
+			{Owner: StringToGUID(MicrosoftVendor), Data: bytes.Repeat([]byte{0xbb}, 32)},
+		},
+	}
+	x509 := SignatureList{
+		Type:          StringToGUID(EfiCertX509),
+		Header:        []byte{},
+		SignatureSize: 16 + 5,
+		Signatures: []SignatureData{
+			{Owner: StringToGUID(Shim), Data: []byte{0x30, 0x82, 0x01, 0x02, 0x03}},
+		},
+	}
+	valid, err := SerializeSignatureLists([]SignatureList{sha256, x509})
+	if err != nil {
+		t.Fatalf("SerializeSignatureLists() error = %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		data    []byte
+		want    []SignatureList
+		wantErr string
+	}{
+		{
+			name: "empty",
+			data: []byte{},
+			want: []SignatureList{},
+		},
+		{
+			name: "sha256 and x509 lists",
+			data: valid,
+			want: []SignatureList{sha256, x509},
+		},
+		{
+			name:    "truncated header",
+			data:    valid[:20],
+			wantErr: "truncated signature list header",
+		},
+		{
+			name:    "truncated list",
+			data:    valid[:len(valid)-1],
+			wantErr: "overruns data",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseSignatureLists(tt.data)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("ParseSignatureLists() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseSignatureLists() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseSignatureLists() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSignatureList_SerializeRoundTrip(t *testing.T) {
+	property := func(header []byte, owners []uint32, size uint8) bool {
+		list := SignatureList{
+			Type:          StringToGUID(EfiCertSha256),
+			Header:        append([]byte{}, header...),
+			SignatureSize: 16 + uint32(size),
+			Signatures:    []SignatureData{},
+		}
+		for _, owner := range owners {
+			list.Signatures = append(list.Signatures, SignatureData{
+				Owner: NewGUID(owner, 0, 0, [8]byte{}),
+				Data:  bytes.Repeat([]byte{byte(owner)}, int(size)),
+			})
+		}
+		blob, err := list.Serialize()
+		if err != nil {
+			return false
+		}
+		got, err := ParseSignatureLists(blob)
+		if err != nil || len(got) != 1 || !reflect.DeepEqual(got[0], list) {
+			return false
+		}
+		again, err := SerializeSignatureLists(got)
+		return err == nil && bytes.Equal(again, blob)
+	}
+	if err := quick.Check(property, nil); err != nil {
+		t.Error(err)
+	}
+}
+
+func FuzzParseSignatureLists(f *testing.F) {
+	data, err := SerializeSignatureLists([]SignatureList{{
+		Type:       StringToGUID(EfiCertSha256),
+		Signatures: []SignatureData{{Owner: StringToGUID(MicrosoftVendor), Data: bytes.Repeat([]byte{0xaa}, 32)}},
+	}})
+	if err != nil {
+		f.Fatal(err)
+	}
+	f.Add(data)
+	f.Add([]byte{})
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		lists, err := ParseSignatureLists(data)
+		if err != nil {
+			return
+		}
+		if _, err := SerializeSignatureLists(lists); err != nil {
+			t.Errorf("SerializeSignatureLists() of parsed lists error = %v", err)
+		}
+	})
+}
@@ -0,0 +1,106 @@
+package efi
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// Variables of the EDK2 TCG2 physical presence and configuration drivers.
+const (
+	// Tcg2PhysicalPresenceVar holds a Tcg2PhysicalPresence under the
+	// EfiTcg2PhysicalPresence GUID.
+	Tcg2PhysicalPresenceVar = "Tcg2PhysicalPresence"
+	// Tcg2PhysicalPresenceFlagsVar holds the UINT32 Tcg2PP* management
+	// flags under the EfiTcg2PhysicalPresence GUID.
+	Tcg2PhysicalPresenceFlagsVar = "Tcg2PhysicalPresenceFlags"
+	// Tcg2ConfigurationVar holds a Tcg2Configuration under the
+	// Tcg2ConfigFormSet GUID.
+	Tcg2ConfigurationVar = "TCG2_CONFIGURATION"
+)
+
+// TCG2 physical presence operations (TCG PC Client Platform Physical
+// Presence Interface Specification).
+const (
+	Tcg2PPNoAction                   = 0
+	Tcg2PPEnable                     = 1
+	Tcg2PPDisable                    = 2
+	Tcg2PPClear                      = 5
+	Tcg2PPEnableClear                = 14
+	Tcg2PPSetPPRequiredForClearTrue  = 17
+	Tcg2PPSetPPRequiredForClearFalse = 18
+	Tcg2PPSetPCRBanks                = 23
+	Tcg2PPChangeEPS                  = 24
+	Tcg2PPLogAllDigests              = 25
+)
+
+// TCG2 physical presence management flags. A set flag makes the firmware
+// ask for confirmation before running the operation.
+const (
+	Tcg2PPRequiredForClear      = 0x00000002
+	Tcg2PPRequiredForChangePCRs = 0x00000004
+	Tcg2PPRequiredForChangeEPS  = 0x00000008
+	Tcg2PPRequiredForTurnOff    = 0x00000010
+)
+
+// TPM devices selected by Tcg2Configuration.
+const (
+	TpmDeviceNull = 0
+	TpmDevice12   = 1
+	TpmDevice20   = 2
+)
+
+// tcg2PPDataSize is the size of the packed EFI_TCG2_PHYSICAL_PRESENCE.
+const tcg2PPDataSize = 10
+
+// Tcg2PhysicalPresence is EFI_TCG2_PHYSICAL_PRESENCE, the pending physical
+// presence request and the result of the last one.
+type Tcg2PhysicalPresence struct {
+	PPRequest          uint8
+	PPRequestParameter uint32
+	LastPPRequest      uint8
+	PPResponse         uint32
+}
+
+// NewTcg2PhysicalPresence decodes the packed EFI_TCG2_PHYSICAL_PRESENCE
+// structure.
+func NewTcg2PhysicalPresence(data []byte) (*Tcg2PhysicalPresence, error) {
+	if len(data) != tcg2PPDataSize {
+		return nil, fmt.Errorf("TCG2 physical presence data is %d bytes, want %d",
+			len(data), tcg2PPDataSize)
+	}
+	return &Tcg2PhysicalPresence{
+		PPRequest:          data[0],
+		PPRequestParameter: binary.LittleEndian.Uint32(data[1:5]),
+		LastPPRequest:      data[5],
+		PPResponse:         binary.LittleEndian.Uint32(data[6:10]),
+	}, nil
+}
+
+// Bytes encodes the structure as stored in the variable.
+func (pp *Tcg2PhysicalPresence) Bytes() []byte {
+	data := make([]byte, tcg2PPDataSize)
+	data[0] = pp.PPRequest
+	binary.LittleEndian.PutUint32(data[1:5], pp.PPRequestParameter)
+	data[5] = pp.LastPPRequest
+	binary.LittleEndian.PutUint32(data[6:10], pp.PPResponse)
+	return data
+}
+
+// Tcg2Configuration is TCG2_CONFIGURATION, the TPM device the firmware
+// uses.
+type Tcg2Configuration struct {
+	TpmDevice uint8
+}
+
+// NewTcg2Configuration decodes a TCG2_CONFIGURATION variable.
+func NewTcg2Configuration(data []byte) (*Tcg2Configuration, error) {
+	if len(data) != 1 {
+		return nil, fmt.Errorf("TCG2 configuration is %d bytes, want 1", len(data))
+	}
+	return &Tcg2Configuration{TpmDevice: data[0]}, nil
+}
+
+// Bytes encodes the structure as stored in the variable.
+func (c *Tcg2Configuration) Bytes() []byte {
+	return []byte{c.TpmDevice}
+}
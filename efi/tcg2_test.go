@@ -0,0 +1,29 @@
+package efi
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestTcg2PhysicalPresence(t *testing.T) {
+	data := []byte{0x17, 0x03, 0x00, 0x00, 0x00, 0x05, 0x00, 0x00, 0x00, 0x00}
+	pp, err := NewTcg2PhysicalPresence(data)
+	if err != nil {
+		t.Fatalf("NewTcg2PhysicalPresence() error = %v", err)
+	}
+	want := Tcg2PhysicalPresence{
+		PPRequest:          Tcg2PPSetPCRBanks,
+		PPRequestParameter: 3,
+		LastPPRequest:      Tcg2PPClear,
+	}
+	if *pp != want {
+		t.Errorf("NewTcg2PhysicalPresence() = %+v, want %+v", *pp, want)
+	}
+	if got := pp.Bytes(); !bytes.Equal(got, data) {
+		t.Errorf("Bytes() = %x, want %x", got, data)
+	}
+
+	if _, err := NewTcg2PhysicalPresence(data[:9]); err == nil {
+		t.Error("NewTcg2PhysicalPresence() accepted truncated data")
+	}
+}
@@ -268,3 +268,21 @@ func TestIsValidMACPattern(t *testing.T) {
 		})
 	}
 }
+
+func FuzzNewIp6ConfigData(f *testing.F) {
+	addCorpusSeeds(f, isIPConfigName)
+	f.Add([]byte{})
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		_, _ = NewIp6ConfigData(data)
+	})
+}
+
+func FuzzNewNetworkDeviceList(f *testing.F) {
+	addCorpusSeeds(f, func(name string) bool { return name == "_NDL" })
+	f.Add([]byte{})
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		_, _ = NewNetworkDeviceList(data)
+	})
+}
@@ -1,8 +1,19 @@
 package efi
 
 import (
+	"errors"
 	"fmt"
 	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Errors returned by strict UCS-16 parsing and validation.
+var (
+	ErrUCS16OddLength        = errors.New("ucs-16 data has odd length")
+	ErrUCS16NotTerminated    = errors.New("ucs-16 string is not null terminated")
+	ErrUCS16EmbeddedNull     = errors.New("ucs-16 string contains an embedded null")
+	ErrUCS16InvalidSurrogate = errors.New("ucs-16 string contains an unpaired surrogate")
+	ErrUCS16InvalidUTF8      = errors.New("string is not valid utf-8")
 )
 
 // UCS16String represents an EFI UCS-16 string.
@@ -47,6 +58,37 @@ func (s *UCS16String) ParseStr(str string) {
 	}
 }
 
+// Validate checks that the string holds well-formed UTF-16 without embedded
+// nulls, so that String and Bytes round-trip without loss.
+func (s *UCS16String) Validate() error {
+	return validateUCS16(s.data)
+}
+
+// validateUCS16 checks unterminated UCS-16 little-endian data.
+func validateUCS16(data []byte) error {
+	if len(data)%2 != 0 {
+		return ErrUCS16OddLength
+	}
+	for i := 0; i < len(data); i += 2 {
+		c := rune(uint16(data[i]) | uint16(data[i+1])<<8)
+		switch {
+		case c == 0:
+			return fmt.Errorf("%w at byte %d", ErrUCS16EmbeddedNull, i)
+		case utf16.IsSurrogate(c):
+			// A valid pair is a high surrogate followed by a low surrogate.
+			if c >= 0xdc00 || i+3 >= len(data) {
+				return fmt.Errorf("%w at byte %d", ErrUCS16InvalidSurrogate, i)
+			}
+			low := rune(uint16(data[i+2]) | uint16(data[i+3])<<8)
+			if low < 0xdc00 || low > 0xdfff {
+				return fmt.Errorf("%w at byte %d", ErrUCS16InvalidSurrogate, i)
+			}
+			i += 2
+		}
+	}
+	return nil
+}
+
 // Bytes returns bytes representing StringUCS16, with terminating 0.
 func (s *UCS16String) Bytes() []byte {
 	return append(s.data, 0, 0)
@@ -93,6 +135,61 @@ func FromUCS16(data []byte, offset ...int) *UCS16String {
 	return obj
 }
 
+// ParseUCS16 strictly parses a null terminated UCS-16 string from data at
+// offset. Unlike FromUCS16 it fails if the terminator is missing or the
+// content is not valid UTF-16, instead of returning a mangled string.
+func ParseUCS16(data []byte, offset int) (*UCS16String, error) {
+	if offset < 0 || offset > len(data) {
+		return nil, fmt.Errorf("ucs-16 offset %d out of range", offset)
+	}
+	end := -1
+	for pos := offset; pos+2 <= len(data); pos += 2 {
+		if data[pos] == 0 && data[pos+1] == 0 {
+			end = pos
+			break
+		}
+	}
+	if end < 0 {
+		return nil, ErrUCS16NotTerminated
+	}
+
+	s := &UCS16String{data: append([]byte{}, data[offset:end]...)}
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// ParseUCS16Exact parses data that must contain exactly one null terminated
+// UCS-16 string, such as a variable name field of known size.
+func ParseUCS16Exact(data []byte) (*UCS16String, error) {
+	if len(data)%2 != 0 {
+		return nil, ErrUCS16OddLength
+	}
+	s, err := ParseUCS16(data, 0)
+	if err != nil {
+		return nil, err
+	}
+	if s.Size() != len(data) {
+		return nil, fmt.Errorf("%w: terminator at byte %d of %d",
+			ErrUCS16EmbeddedNull, s.Size()-2, len(data))
+	}
+	return s, nil
+}
+
+// UCS16FromString strictly converts a Go string to StringUCS16, failing on
+// invalid UTF-8 or embedded nulls rather than substituting characters.
+func UCS16FromString(str string) (*UCS16String, error) {
+	if !utf8.ValidString(str) {
+		return nil, ErrUCS16InvalidUTF8
+	}
+	s := NewUCS16String(str)
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
 // FromString converts Go string to StringUCS16.
 func FromString(str string) *UCS16String {
 	return NewUCS16String(str)
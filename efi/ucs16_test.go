@@ -1,6 +1,7 @@
 package efi
 
 import (
+	"errors"
 	"reflect"
 	"testing"
 )
@@ -246,3 +247,107 @@ func TestUcs16ToString(t *testing.T) {
 		})
 	}
 }
+
+func TestParseUCS16(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    []byte
+		offset  int
+		want    string
+		wantErr error
+	}{
+		{
+			name: "terminated ascii",
+			data: []byte{'B', 0, 'o', 0, 'o', 0, 't', 0, 0, 0},
+			want: "Boot",
+		},
+		{
+			name:   "with offset",
+			data:   []byte{0xff, 0xff, 'A', 0, 0, 0},
+			offset: 2,
+			want:   "A",
+		},
+		{
+			name: "surrogate pair",
+			data: []byte{0x3d, 0xd8, 0x00, 0xde, 0, 0},
+			want: "\U0001F600",
+		},
+		{
+			name:    "missing terminator",
+			data:    []byte{'B', 0, 'o', 0},
+			wantErr: ErrUCS16NotTerminated,
+		},
+		{
+			name:    "unpaired high surrogate",
+			data:    []byte{0x3d, 0xd8, 'A', 0, 0, 0},
+			wantErr: ErrUCS16InvalidSurrogate,
+		},
+		{
+			name:    "lone low surrogate",
+			data:    []byte{0x00, 0xde, 0, 0},
+			wantErr: ErrUCS16InvalidSurrogate,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseUCS16(tt.data, tt.offset)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ParseUCS16() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if got.String() != tt.want {
+				t.Errorf("ParseUCS16() = %q, want %q", got.String(), tt.want)
+			}
+			if !reflect.DeepEqual(got.Bytes(), tt.data[tt.offset:]) {
+				t.Errorf("ParseUCS16().Bytes() = %v, want %v", got.Bytes(), tt.data[tt.offset:])
+			}
+		})
+	}
+}
+
+func TestParseUCS16Exact(t *testing.T) {
+	if _, err := ParseUCS16Exact([]byte{'A', 0, 0, 0, 'B', 0, 0, 0}); !errors.Is(err, ErrUCS16EmbeddedNull) {
+		t.Errorf("ParseUCS16Exact() error = %v, want %v", err, ErrUCS16EmbeddedNull)
+	}
+	if _, err := ParseUCS16Exact([]byte{'A', 0, 0}); !errors.Is(err, ErrUCS16OddLength) {
+		t.Errorf("ParseUCS16Exact() error = %v, want %v", err, ErrUCS16OddLength)
+	}
+	got, err := ParseUCS16Exact([]byte{'A', 0, 0, 0})
+	if err != nil || got.String() != "A" {
+		t.Errorf("ParseUCS16Exact() = %v, %v, want \"A\"", got, err)
+	}
+}
+
+func TestUCS16FromString(t *testing.T) {
+	tests := []struct {
+		name    string
+		str     string
+		wantErr error
+	}{
+		{name: "ascii", str: "PlatformLang"},
+		{name: "non-bmp", str: "Boot\U0001F600"},
+		{name: "embedded null", str: "Boot\x00Order", wantErr: ErrUCS16EmbeddedNull},
+		{name: "invalid utf-8", str: "Boot\xff", wantErr: ErrUCS16InvalidUTF8},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := UCS16FromString(tt.str)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("UCS16FromString() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			// The binary form must parse back to the identical string.
+			back, err := ParseUCS16(got.Bytes(), 0)
+			if err != nil {
+				t.Fatalf("ParseUCS16() error = %v", err)
+			}
+			if back.String() != tt.str || !reflect.DeepEqual(back.Bytes(), got.Bytes()) {
+				t.Errorf("round trip = %q, want %q", back.String(), tt.str)
+			}
+		})
+	}
+}
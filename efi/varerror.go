@@ -0,0 +1,51 @@
+package efi
+
+import (
+	"fmt"
+	"strings"
+)
+
+// VarError is an error decoding or encoding a variable, with the context
+// needed to find the record at fault, e.g.
+//
+//	VARS.fd: variable Timeout (8be4df61-93ca-11d2-aa0d-00e098032b8c) at offset 0x1b4: data too short for uint32
+type VarError struct {
+	// Name and Guid identify the variable. Name is empty if the record is
+	// too damaged to tell.
+	Name string
+	Guid GUID
+	// Offset is the byte offset of the record in the image, or -1 if the
+	// variable does not come from an image.
+	Offset int
+	// Path is the file holding the variable, if known.
+	Path string
+	Err  error
+}
+
+func (e *VarError) Error() string {
+	var b strings.Builder
+	if e.Path != "" {
+		b.WriteString(e.Path + ": ")
+	}
+	b.WriteString("variable")
+	if e.Name != "" {
+		fmt.Fprintf(&b, " %s (%s)", e.Name, e.Guid)
+	}
+	if e.Offset >= 0 {
+		fmt.Fprintf(&b, " at offset 0x%x", e.Offset)
+	}
+	return b.String() + ": " + e.Err.Error()
+}
+
+func (e *VarError) Unwrap() error {
+	return e.Err
+}
+
+// wrapErr returns err with the name and GUID of v.
+func (v *EfiVar) wrapErr(err error) error {
+	e := &VarError{Guid: v.Guid, Offset: -1, Err: err}
+	if v.Name != nil {
+		e.Name = v.Name.String()
+	}
+	return e
+}
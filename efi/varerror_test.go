@@ -0,0 +1,33 @@
+package efi
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestVarError(t *testing.T) {
+	v := &EfiVar{Name: FromString("Timeout"), Guid: EFI_GLOBAL_VARIABLE_GUID, Data: []byte{5, 0}}
+	_, err := v.GetUint32()
+
+	var ve *VarError
+	if !errors.As(err, &ve) {
+		t.Fatalf("GetUint32() error = %v, want a VarError", err)
+	}
+	if ve.Name != "Timeout" || ve.Guid != EFI_GLOBAL_VARIABLE_GUID || ve.Offset != -1 {
+		t.Errorf("VarError = %+v", ve)
+	}
+	want := "variable Timeout (8be4df61-93ca-11d2-aa0d-00e098032b8c): data too short for uint32"
+	if err.Error() != want {
+		t.Errorf("Error() = %q, want %q", err, want)
+	}
+
+	ve.Path, ve.Offset = "VARS.fd", 0x1b4
+	want = "VARS.fd: variable Timeout (8be4df61-93ca-11d2-aa0d-00e098032b8c) at offset 0x1b4: data too short for uint32"
+	if err.Error() != want {
+		t.Errorf("Error() = %q, want %q", err, want)
+	}
+
+	if _, err := (&EfiVar{}).Serialize(); err == nil || err.Error() != "variable: no name" {
+		t.Errorf("Serialize() error = %v, want %q", err, "variable: no name")
+	}
+}
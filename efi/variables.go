@@ -6,6 +6,7 @@ import (
 	"encoding/hex"
 	"errors"
 	"fmt"
+	"math"
 	"net"
 	"os"
 	"slices"
@@ -28,10 +29,18 @@ const (
 	hexTable = "0123456789ABCDEF"
 )
 
-var (
-	// Pre-decoded hex constant to avoid repeated parsing.
-	pxeOptData = BmAutoCreateBootOptionGuid.Bytes()
+// EDK2 authenticated variable record layout.
+const (
+	// VarHeaderMagic is the start id of every variable record.
+	VarHeaderMagic = 0x55aa
+	// VarStateAdded is the state of a variable that is present in the store.
+	VarStateAdded = 0x3f
+	// VarHeaderSize is the size of a variable record header, including the
+	// vendor GUID that precedes the name.
+	VarHeaderSize = 44 + 16
+)
 
+var (
 	// Pre-computed variable template for BootNext.
 	bootNextTemplate = &EfiVar{
 		Name: FromString("BootNext"),
@@ -107,10 +116,23 @@ type EfiVar struct {
 	Attr  uint32
 	Data  []byte
 	Count int
-	Time  *time.Time
-	PkIdx int
+	// Time is the EFI_TIME of time-based authenticated variables. It holds
+	// the stored date and time as UTC; the time zone and daylight saving
+	// fields are kept apart so that times written by firmware round-trip.
+	Time     *time.Time
+	TimeZone int16
+	Daylight uint8
+	PkIdx    int
 }
 
+// EFI_TIME TimeZone and Daylight values.
+const (
+	// EfiUnspecifiedTimezone marks a local time with no known offset.
+	EfiUnspecifiedTimezone = 0x07ff
+	EfiTimeAdjustDaylight  = 0x01
+	EfiTimeInDaylight      = 0x02
+)
+
 // NewEfiVar creates a new EFI variable.
 func NewEfiVar(name any, guid *string, attr uint32, data []byte, count int) (*EfiVar, error) {
 	v := &EfiVar{
@@ -119,16 +141,23 @@ func NewEfiVar(name any, guid *string, attr uint32, data []byte, count int) (*Ef
 	}
 
 	// Handle name
+	var err error
 	switch n := name.(type) {
 	case *UCS16String:
-		v.Name = n
+		if n == nil {
+			return nil, errors.New("invalid variable name: nil")
+		}
+		v.Name, err = n, n.Validate()
 	case string:
-		v.Name = FromString(n)
+		v.Name, err = UCS16FromString(n)
 	case []byte:
-		v.Name = FromString(string(n))
+		v.Name, err = UCS16FromString(string(n))
 	default:
 		return nil, errors.New("invalid name type")
 	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid variable name: %w", err)
+	}
 
 	// Parse GUID
 	if guid != nil {
@@ -167,46 +196,27 @@ func NewEfiVar(name any, guid *string, attr uint32, data []byte, count int) (*Ef
 	return v, nil
 }
 
+// NewPxeBootOption creates the Boot0099 variable booting the host with
+// the MAC address mac over PXE, marked as created by the firmware.
 func NewPxeBootOption(mac net.HardwareAddr) (*EfiVar, error) {
-	if len(mac) != 6 {
-		return nil, fmt.Errorf("invalid MAC address length: %d", len(mac))
-	}
-
-	// Create device path and boot entry efficiently
-	devPath := (&DevicePath{}).Mac(mac).IPv4()
-
-	// Fast MAC address formatting using optimized conversion
-	title := NewUCS16String(formatMACTitle(mac))
-
-	// Create boot entry with pre-allocated data
-	bootEntry := &BootEntry{
-		Attr:       LOAD_OPTION_ACTIVE,
-		Title:      *title,
-		DevicePath: *devPath,
-		OptData:    pxeOptData, // Use pre-decoded constant
-	}
-
-	return &EfiVar{
-		Name: boot0099Name,
-		Guid: EFI_GLOBAL_VARIABLE_GUID,
-		Attr: EfiVariableDefault | EfiVariableRuntimeAccess, // Attr 7
-		Data: bootEntry.Bytes(),
-	}, nil
+	return NewPxeBootOptionWithMetadata(mac, BootMetadata{})
 }
 
-// formatMACTitle creates MAC title string with optimized formatting.
-func formatMACTitle(macAddr net.HardwareAddr) string {
+// formatMACTitle creates the title of a network boot option, e.g.
+// "UEFI PXEv4 (MAC:D8:3A:DD:01:02:03)", with optimized formatting.
+func formatMACTitle(protocol string, macAddr net.HardwareAddr) string {
 	if len(macAddr) != 6 {
 		// Fallback for non-standard MAC addresses
-		return fmt.Sprintf("UEFI PXEv4 (MAC:%s)", strings.ToUpper(macAddr.String()))
+		return fmt.Sprintf("%s (MAC:%s)", protocol, strings.ToUpper(macAddr.String()))
 	}
 
 	sb := &strings.Builder{}
 
-	// Pre-allocate exact size: "UEFI PXEv4 (MAC:" + "XX:XX:XX:XX:XX:XX" + ")"
-	sb.Grow(32)
+	// Pre-allocate exact size: protocol + " (MAC:" + "XX:XX:XX:XX:XX:XX" + ")"
+	sb.Grow(len(protocol) + 24)
 
-	sb.WriteString("UEFI PXEv4 (MAC:")
+	sb.WriteString(protocol)
+	sb.WriteString(" (MAC:")
 
 	// Direct byte-to-hex conversion for maximum speed
 	for i, b := range macAddr {
@@ -221,7 +231,75 @@ func formatMACTitle(macAddr net.HardwareAddr) string {
 	return sb.String()
 }
 
-// ParseTime parses an EFI_TIME structure.
+// ErrInvalidVariable is returned when a variable violates an attribute invariant.
+var ErrInvalidVariable = errors.New("invalid variable")
+
+// Validate checks a variable before it is written. In addition to the
+// invariants checked by ValidateStored, a time-based authenticated variable
+// must carry the timestamp used to authenticate the write.
+func (v *EfiVar) Validate() error {
+	if err := v.ValidateStored(); err != nil {
+		return err
+	}
+	if v.Attr&EfiVariableTimeBasedAuthenticatedWriteAccess != 0 && v.Time == nil {
+		return v.invalid("time-based authenticated variable requires a timestamp")
+	}
+	return nil
+}
+
+// ValidateStored checks the invariants that hold for every variable in a
+// variable store. Variables maintained by the firmware itself, such as
+// certdb, are stored with a zero timestamp, so one is not required here.
+func (v *EfiVar) ValidateStored() error {
+	if v.Name == nil || v.Name.String() == "" {
+		return fmt.Errorf("%w: missing name", ErrInvalidVariable)
+	}
+	if err := v.Name.Validate(); err != nil {
+		return fmt.Errorf("%w %s: %w", ErrInvalidVariable, v.Name, err)
+	}
+
+	attr := v.Attr
+	if attr&EfiVariableRuntimeAccess != 0 && attr&EfiVariableBootserviceAccess == 0 {
+		return v.invalid("runtime access requires boot service access")
+	}
+	if attr&EfiVariableHardwareErrorRecord != 0 {
+		required := EfiVariableNonVolatile | EfiVariableBootserviceAccess | EfiVariableRuntimeAccess
+		if attr&required != required {
+			return v.invalid("hardware error record requires non-volatile, boot service and runtime access")
+		}
+	}
+	if attr&EfiVariableAppendWrite != 0 {
+		return v.invalid("append write is a request flag and cannot be stored")
+	}
+	if attr&EfiVariableAuthenticatedWriteAccess != 0 &&
+		attr&EfiVariableTimeBasedAuthenticatedWriteAccess != 0 {
+		return v.invalid("count-based and time-based authentication are mutually exclusive")
+	}
+
+	if v.Count < 0 {
+		return v.invalid(fmt.Sprintf("monotonic count %d out of range", v.Count))
+	}
+	if v.PkIdx < 0 || uint64(v.PkIdx) > math.MaxUint32 {
+		return v.invalid(fmt.Sprintf("public key index %d out of range", v.PkIdx))
+	}
+	if attr&EfiVariableAuthenticatedWriteAccess == 0 && v.Count != 0 {
+		return v.invalid(fmt.Sprintf("monotonic count %d set without count-based authentication", v.Count))
+	}
+	if attr&(EfiVariableAuthenticatedWriteAccess|EfiVariableTimeBasedAuthenticatedWriteAccess) == 0 &&
+		v.PkIdx != 0 {
+		return v.invalid(fmt.Sprintf("public key index %d set on unauthenticated variable", v.PkIdx))
+	}
+
+	return nil
+}
+
+// invalid builds an ErrInvalidVariable error for the variable.
+func (v *EfiVar) invalid(reason string) error {
+	return fmt.Errorf("%w %s (attr=0x%08x): %s", ErrInvalidVariable, v.Name, v.Attr, reason)
+}
+
+// ParseTime parses an EFI_TIME structure, including its time zone and
+// daylight saving fields.
 func (v *EfiVar) ParseTime(data []byte, offset int) error {
 	if len(data) < offset+16 {
 		return errors.New("data too short for EFI_TIME")
@@ -235,15 +313,21 @@ func (v *EfiVar) ParseTime(data []byte, offset int) error {
 	second := data[offset+6]
 	// Skip pad byte at offset+7
 	ns := binary.LittleEndian.Uint32(data[offset+8:])
-	// Skip timezone, daylight savings and pad
+	timeZone := int16(binary.LittleEndian.Uint16(data[offset+12:]))
+	daylight := data[offset+14]
+	// Skip pad byte at offset+15
 
 	if year != 0 {
 		t := time.Date(int(year), time.Month(month), int(day),
 			int(hour), int(minute), int(second),
-			int(ns)/1000, time.UTC)
+			int(ns), time.UTC)
 		v.Time = &t
+		v.TimeZone = timeZone
+		v.Daylight = daylight
 	} else {
 		v.Time = nil
+		v.TimeZone = 0
+		v.Daylight = 0
 	}
 
 	return nil
@@ -263,14 +347,102 @@ func (v *EfiVar) BytesTime() []byte {
 	buf.WriteByte(byte(v.Time.Minute()))
 	buf.WriteByte(byte(v.Time.Second()))
 	buf.WriteByte(0) // pad
-	_ = binary.Write(buf, binary.LittleEndian, uint32(v.Time.Nanosecond()/1000))
-	_ = binary.Write(buf, binary.LittleEndian, int16(0)) // timezone
-	buf.WriteByte(0)                                     // daylight
-	buf.WriteByte(0)                                     // pad
+	_ = binary.Write(buf, binary.LittleEndian, uint32(v.Time.Nanosecond()))
+	_ = binary.Write(buf, binary.LittleEndian, v.TimeZone)
+	buf.WriteByte(v.Daylight)
+	buf.WriteByte(0) // pad
 
 	return buf.Bytes()
 }
 
+// Serialize returns the EDK2 variable record for v, without the padding
+// that aligns records in a varstore.
+func (v *EfiVar) Serialize() ([]byte, error) {
+	if v.Name == nil {
+		return nil, v.wrapErr(errors.New("no name"))
+	}
+	if uint64(len(v.Data)) > math.MaxUint32 {
+		return nil, v.wrapErr(fmt.Errorf("data too large: %d bytes", len(v.Data)))
+	}
+	if v.Count < 0 || v.PkIdx < 0 || uint64(v.PkIdx) > math.MaxUint32 {
+		return nil, v.wrapErr(fmt.Errorf("invalid count %d or key index %d", v.Count, v.PkIdx))
+	}
+
+	buf := new(bytes.Buffer)
+
+	// Equivalent to struct.pack("=HBxLQ", 0x55aa, 0x3f, var.attr, var.count)
+	_ = binary.Write(buf, binary.LittleEndian, uint16(VarHeaderMagic))
+	_ = binary.Write(buf, binary.LittleEndian, uint8(VarStateAdded))
+	_ = binary.Write(buf, binary.LittleEndian, uint8(0)) // padding byte (x)
+	_ = binary.Write(buf, binary.LittleEndian, v.Attr)
+	_ = binary.Write(buf, binary.LittleEndian, uint64(v.Count))
+
+	buf.Write(v.BytesTime())
+
+	// Equivalent to struct.pack("=LLL", var.pkidx, var.name.size(), len(var.data))
+	_ = binary.Write(buf, binary.LittleEndian, uint32(v.PkIdx))
+	_ = binary.Write(buf, binary.LittleEndian, uint32(v.Name.Size()))
+	_ = binary.Write(buf, binary.LittleEndian, uint32(len(v.Data)))
+
+	buf.Write(v.Guid.Bytes())
+	buf.Write(v.Name.Bytes())
+	buf.Write(v.Data)
+
+	return buf.Bytes(), nil
+}
+
+// ParseEfiVar parses a single EDK2 variable record as produced by
+// Serialize. The record must be in the added state and fill data exactly.
+func ParseEfiVar(data []byte) (*EfiVar, error) {
+	return ParseEfiVarWithOptions(data, ParseOptions{})
+}
+
+// ParseEfiVarWithOptions is like ParseEfiVar but also checks the decoded
+// variable against opts.
+func ParseEfiVarWithOptions(data []byte, opts ParseOptions) (*EfiVar, error) {
+	if len(data) < VarHeaderSize {
+		return nil, fmt.Errorf("variable record too short: %d bytes", len(data))
+	}
+	if magic := binary.LittleEndian.Uint16(data[0:2]); magic != VarHeaderMagic {
+		return nil, fmt.Errorf("bad variable magic 0x%04x", magic)
+	}
+	if state := data[2]; state != VarStateAdded {
+		return nil, fmt.Errorf("variable is not in the added state: 0x%02x", state)
+	}
+
+	nsize := binary.LittleEndian.Uint32(data[36:40])
+	dsize := binary.LittleEndian.Uint32(data[40:44])
+	if want := uint64(VarHeaderSize) + uint64(nsize) + uint64(dsize); want != uint64(len(data)) {
+		return nil, fmt.Errorf("variable record is %d bytes, header describes %d", len(data), want)
+	}
+
+	nameEnd := VarHeaderSize + int(nsize)
+	name, err := ParseUCS16Exact(data[VarHeaderSize:nameEnd])
+	if err != nil {
+		return nil, fmt.Errorf("invalid variable name: %w", err)
+	}
+
+	count := binary.LittleEndian.Uint64(data[8:16])
+	if count > math.MaxInt64 {
+		return nil, fmt.Errorf("variable %s has invalid count %d", name, count)
+	}
+	v := &EfiVar{
+		Name:  name,
+		Guid:  ParseBinGUID(data, 44),
+		Attr:  binary.LittleEndian.Uint32(data[4:8]),
+		Data:  data[nameEnd:],
+		Count: int(count),
+		PkIdx: int(binary.LittleEndian.Uint32(data[32:36])),
+	}
+	if err := v.ParseTime(data, 16); err != nil {
+		return nil, err
+	}
+	if err := opts.CheckVar(v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
 // updateTime updates the time field if needed.
 func (v *EfiVar) updateTime(ts *time.Time) {
 	if v.Attr&EfiVariableTimeBasedAuthenticatedWriteAccess == 0 {
@@ -284,6 +456,8 @@ func (v *EfiVar) updateTime(ts *time.Time) {
 
 	if v.Time == nil || v.Time.Before(*ts) {
 		v.Time = ts
+		v.TimeZone = 0
+		v.Daylight = 0
 	}
 }
 
@@ -310,7 +484,7 @@ func (v *EfiVar) SetString(value string) {
 func (v *EfiVar) SetHexString(value string) error {
 	data, err := hex.DecodeString(value)
 	if err != nil {
-		return err
+		return v.wrapErr(err)
 	}
 	v.Data = data
 	return nil
@@ -326,7 +500,7 @@ func (v *EfiVar) SetUint32(value uint32) {
 
 func (v *EfiVar) GetUint32() (uint32, error) {
 	if len(v.Data) < 4 {
-		return 0, errors.New("data too short for uint32")
+		return 0, v.wrapErr(errors.New("data too short for uint32"))
 	}
 	return binary.LittleEndian.Uint32(v.Data), nil
 }
@@ -341,7 +515,7 @@ func (v *EfiVar) SetUint16(value uint16) {
 
 func (v *EfiVar) GetUint16() (uint16, error) {
 	if len(v.Data) < 2 {
-		return 0, errors.New("data too short for uint16")
+		return 0, v.wrapErr(errors.New("data too short for uint16"))
 	}
 	return binary.LittleEndian.Uint16(v.Data), nil
 }
@@ -354,7 +528,7 @@ func (v *EfiVar) SetUint8(value uint8) {
 
 func (v *EfiVar) GetUint8() (uint8, error) {
 	if len(v.Data) < 1 {
-		return 0, errors.New("data too short for uint8")
+		return 0, v.wrapErr(errors.New("data too short for uint8"))
 	}
 	return v.Data[0], nil
 }
@@ -369,27 +543,31 @@ func (v *EfiVar) SetUint64(value uint64) {
 
 func (v *EfiVar) GetUint64() (uint64, error) {
 	if len(v.Data) < 8 {
-		return 0, errors.New("data too short for uint64")
+		return 0, v.wrapErr(errors.New("data too short for uint64"))
 	}
 	return binary.LittleEndian.Uint64(v.Data), nil
 }
 
+// GetBootEntry parses the variable data as a load option. The load option
+// attributes come from the data, not from the variable attributes.
 func (v *EfiVar) GetBootEntry() (*BootEntry, error) {
-	return NewBootEntry(v.Data, v.Attr, nil, nil, nil), nil
+	return NewBootEntry(v.Data, 0, nil, nil, nil), nil
 }
 
 // GetDhcp6Duid parses the variable data as a DHCP6 DUID.
 func (v *EfiVar) GetDhcp6Duid() (*Dhcp6Duid, error) {
 	if len(v.Data) < 2 {
-		return nil, fmt.Errorf("data too short for DHCP6 DUID")
+		return nil, v.wrapErr(errors.New("data too short for DHCP6 DUID"))
 	}
-	return NewDhcp6Duid(v.Data)
+	duid, err := NewDhcp6Duid(v.Data)
+	if err != nil {
+		return nil, v.wrapErr(err)
+	}
+	return duid, nil
 }
 
 // SetBootEntry sets a boot entry.
 func (v *EfiVar) SetBootEntry(attr uint32, title string, path string, optdata []byte) error {
-	t := NewUCS16String(title)
-
 	var p *DevicePath
 	var err error
 
@@ -402,7 +580,18 @@ func (v *EfiVar) SetBootEntry(attr uint32, title string, path string, optdata []
 		p = NewDevicePath([]byte(path))
 	}
 
-	entry := NewBootEntry(nil, attr, t, p, &optdata)
+	return v.SetBootEntryDevicePath(attr, title, p, optdata)
+}
+
+// SetBootEntryDevicePath sets a boot entry from an already built device
+// path.
+func (v *EfiVar) SetBootEntryDevicePath(
+	attr uint32,
+	title string,
+	path *DevicePath,
+	optdata []byte,
+) error {
+	entry := NewBootEntry(nil, attr, NewUCS16String(title), path, &optdata)
 
 	v.Data = entry.Bytes()
 	v.updateTime(nil)
@@ -411,7 +600,7 @@ func (v *EfiVar) SetBootEntry(attr uint32, title string, path string, optdata []
 
 func (v *EfiVar) GetBootNext() (uint16, error) {
 	if len(v.Data) < 2 {
-		return 0, errors.New("data too short for BootNext")
+		return 0, v.wrapErr(errors.New("data too short for BootNext"))
 	}
 	return binary.LittleEndian.Uint16(v.Data), nil
 }
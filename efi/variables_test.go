@@ -1,8 +1,12 @@
 package efi
 
 import (
+	"bytes"
+	"errors"
+	"os"
 	"reflect"
 	"testing"
+	"testing/quick"
 	"time"
 )
 
@@ -117,6 +121,31 @@ func TestEfiVar_BytesTime(t *testing.T) {
 	}
 }
 
+func TestEfiVar_TimeRoundTrip(t *testing.T) {
+	// 2024-03-31 01:30:00, one hour east of UTC, in daylight saving time.
+	data := []byte{0xe8, 0x07, 3, 31, 1, 30, 0, 0, 0, 0, 0, 0, 0xc4, 0xff, 0x03, 0}
+	v := &EfiVar{}
+	if err := v.ParseTime(data, 0); err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2024, 3, 31, 1, 30, 0, 0, time.UTC); !v.Time.Equal(want) {
+		t.Errorf("Time = %v, want %v", v.Time, want)
+	}
+	if v.TimeZone != -60 || v.Daylight != EfiTimeAdjustDaylight|EfiTimeInDaylight {
+		t.Errorf("TimeZone, Daylight = %d, %d, want -60, 3", v.TimeZone, v.Daylight)
+	}
+	if got := v.BytesTime(); !reflect.DeepEqual(got, data) {
+		t.Errorf("BytesTime() = %x, want %x", got, data)
+	}
+
+	// Setting a new time stores it as UTC.
+	v.Attr = EfiVariableTimeBasedAuthenticatedWriteAccess
+	v.updateTime(nil)
+	if v.TimeZone != 0 || v.Daylight != 0 {
+		t.Errorf("TimeZone, Daylight = %d, %d after update, want 0, 0", v.TimeZone, v.Daylight)
+	}
+}
+
 func TestEfiVar_updateTime(t *testing.T) {
 	type fields struct {
 		Name  *UCS16String
@@ -876,3 +905,210 @@ func TestEfiVar_String(t *testing.T) {
 		})
 	}
 }
+
+func TestEfiVar_Validate(t *testing.T) {
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	tests := []struct {
+		name          string
+		v             *EfiVar
+		wantErr       bool
+		wantStoredErr bool
+	}{
+		{
+			name: "plain boot service variable",
+			v:    &EfiVar{Name: FromString("Timeout"), Attr: EfiVariableDefault},
+		},
+		{
+			name: "runtime without boot service",
+			v: &EfiVar{
+				Name: FromString("Timeout"),
+				Attr: EfiVariableNonVolatile | EfiVariableRuntimeAccess,
+			},
+			wantErr:       true,
+			wantStoredErr: true,
+		},
+		{
+			name: "time-based authenticated with timestamp",
+			v: &EfiVar{
+				Name: FromString("db"),
+				Attr: EfiVariableDefault | EfiVariableRuntimeAccess |
+					EfiVariableTimeBasedAuthenticatedWriteAccess,
+				Time: &now,
+			},
+		},
+		{
+			name: "time-based authenticated without timestamp",
+			v: &EfiVar{
+				Name: FromString("certdb"),
+				Attr: EfiVariableDefault | EfiVariableRuntimeAccess |
+					EfiVariableTimeBasedAuthenticatedWriteAccess,
+			},
+			wantErr: true,
+		},
+		{
+			name: "append write flag",
+			v: &EfiVar{
+				Name: FromString("db"),
+				Attr: EfiVariableDefault | EfiVariableAppendWrite,
+			},
+			wantErr:       true,
+			wantStoredErr: true,
+		},
+		{
+			name: "hardware error record without runtime access",
+			v: &EfiVar{
+				Name: FromString("HwErrRec0000"),
+				Attr: EfiVariableDefault | EfiVariableHardwareErrorRecord,
+			},
+			wantErr:       true,
+			wantStoredErr: true,
+		},
+		{
+			name: "count on unauthenticated variable",
+			v: &EfiVar{
+				Name:  FromString("Timeout"),
+				Attr:  EfiVariableDefault,
+				Count: 3,
+			},
+			wantErr:       true,
+			wantStoredErr: true,
+		},
+		{
+			name: "public key index on unauthenticated variable",
+			v: &EfiVar{
+				Name:  FromString("Timeout"),
+				Attr:  EfiVariableDefault,
+				PkIdx: 1,
+			},
+			wantErr:       true,
+			wantStoredErr: true,
+		},
+		{
+			name:          "missing name",
+			v:             &EfiVar{Attr: EfiVariableDefault},
+			wantErr:       true,
+			wantStoredErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.v.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("EfiVar.Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrInvalidVariable) {
+				t.Errorf("EfiVar.Validate() error = %v, want ErrInvalidVariable", err)
+			}
+			if err := tt.v.ValidateStored(); (err != nil) != tt.wantStoredErr {
+				t.Errorf("EfiVar.ValidateStored() error = %v, wantErr %v", err, tt.wantStoredErr)
+			}
+		})
+	}
+}
+
+// corpusVariables returns the variables of the firmware dumps in test/.
+func corpusVariables(t testing.TB) []*EfiVar {
+	t.Helper()
+	var vars []*EfiVar
+	for _, file := range []string{"test/fw-test.json", "test/fw-test-2.json"} {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatalf("os.ReadFile() error = %v", err)
+		}
+		list := EfiVarList{}
+		if err := list.UnmarshalJSON(data); err != nil {
+			t.Fatalf("EfiVarList.UnmarshalJSON() error = %v", err)
+		}
+		vars = append(vars, list.Variables()...)
+	}
+	return vars
+}
+
+// addCorpusSeeds adds the data of the corpus variables named for which match
+// returns true to the seed corpus of f.
+func addCorpusSeeds(f *testing.F, match func(name string) bool) {
+	for _, v := range corpusVariables(f) {
+		if match(v.Name.String()) {
+			f.Add(v.Data)
+		}
+	}
+}
+
+func equalEfiVar(a, b *EfiVar) bool {
+	if a.Name.String() != b.Name.String() || a.Guid != b.Guid || a.Attr != b.Attr ||
+		!bytes.Equal(a.Data, b.Data) || a.Count != b.Count || a.PkIdx != b.PkIdx {
+		return false
+	}
+	if a.Time == nil || b.Time == nil {
+		return a.Time == nil && b.Time == nil
+	}
+	return a.Time.Equal(*b.Time)
+}
+
+func TestEfiVar_SerializeRoundTrip(t *testing.T) {
+	for _, v := range corpusVariables(t) {
+		blob, err := v.Serialize()
+		if err != nil {
+			t.Fatalf("EfiVar.Serialize() %s error = %v", v.Name, err)
+		}
+		got, err := ParseEfiVar(blob)
+		if err != nil {
+			t.Fatalf("ParseEfiVar() %s error = %v", v.Name, err)
+		}
+		if !equalEfiVar(got, v) {
+			t.Errorf("ParseEfiVar(Serialize()) = %v, want %v", got, v)
+		}
+		again, _ := got.Serialize()
+		if !bytes.Equal(again, blob) {
+			t.Errorf("Serialize(ParseEfiVar()) %s = %x, want %x", v.Name, again, blob)
+		}
+	}
+
+	property := func(name string, attr, count, pkIdx uint32, data []byte, ts uint32) bool {
+		ucs, err := UCS16FromString(name)
+		if err != nil || name == "" {
+			return true
+		}
+		v := &EfiVar{Name: ucs, Guid: EFI_GLOBAL_VARIABLE_GUID, Attr: attr, Data: data,
+			Count: int(count), PkIdx: int(pkIdx)}
+		if ts != 0 {
+			tm := time.Unix(int64(ts), int64(ts%1000000000)).UTC()
+			v.Time = &tm
+		}
+		blob, err := v.Serialize()
+		if err != nil {
+			return false
+		}
+		got, err := ParseEfiVar(blob)
+		if err != nil || !equalEfiVar(got, v) {
+			return false
+		}
+		again, err := got.Serialize()
+		return err == nil && bytes.Equal(again, blob)
+	}
+	if err := quick.Check(property, nil); err != nil {
+		t.Error(err)
+	}
+}
+
+func FuzzParseEfiVar(f *testing.F) {
+	for _, v := range corpusVariables(f) {
+		blob, err := v.Serialize()
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(blob)
+	}
+	f.Add([]byte{})
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		v, err := ParseEfiVar(data)
+		if err != nil {
+			return
+		}
+		_ = v.String()
+		if _, err := v.Serialize(); err != nil {
+			t.Errorf("EfiVar.Serialize() of parsed variable error = %v", err)
+		}
+	})
+}
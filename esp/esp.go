@@ -0,0 +1,141 @@
+// Package esp finds UEFI boot loaders on disk images and builds boot
+// entries for them.
+package esp
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/metal3-community/uefi-firmware-manager/efi"
+	"github.com/metal3-community/uefi-firmware-manager/fat"
+)
+
+// ErrNoESP is returned when a disk image has no EFI system partition.
+var ErrNoESP = errors.New("no EFI system partition found")
+
+// Loader is a boot loader found on an EFI system partition.
+type Loader struct {
+	Partition Partition
+	// Path is the loader path in UEFI notation, e.g. \EFI\debian\shimaa64.efi.
+	Path string
+}
+
+// Vendor returns the directory below \EFI holding the loader.
+func (l Loader) Vendor() string {
+	parts := strings.Split(l.Path, `\`)
+	if len(parts) < 3 {
+		return ""
+	}
+	return parts[2]
+}
+
+// DevicePath returns the HD()/FilePath device path of the loader.
+func (l Loader) DevicePath() *efi.DevicePath {
+	p := l.Partition
+	dp := &efi.DevicePath{}
+	return dp.
+		GptPartition(p.Number, p.FirstLBA, p.LastLBA-p.FirstLBA+1, p.UniqueGUID.String()).
+		FilePath(l.Path)
+}
+
+// BootEntry returns an active boot entry for the loader, titled after its
+// vendor directory.
+func (l Loader) BootEntry() *efi.BootEntry {
+	return &efi.BootEntry{
+		Attr:       efi.LOAD_OPTION_ACTIVE,
+		Title:      *efi.NewUCS16String(l.Vendor()),
+		DevicePath: *l.DevicePath(),
+	}
+}
+
+// FindLoaders returns the \EFI\*\*.efi loaders on every EFI system
+// partition of a GPT disk image, sorted by partition and path.
+func FindLoaders(r io.ReaderAt) ([]Loader, error) {
+	parts, err := ReadPartitions(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var loaders []Loader
+	found := false
+	for _, p := range parts {
+		if !p.IsESP() {
+			continue
+		}
+		found = true
+		paths, err := findLoaderPaths(io.NewSectionReader(r, p.Offset(), p.Size()))
+		if err != nil {
+			return nil, fmt.Errorf("partition %d: %w", p.Number, err)
+		}
+		for _, path := range paths {
+			loaders = append(loaders, Loader{Partition: p, Path: path})
+		}
+	}
+	if !found {
+		return nil, ErrNoESP
+	}
+	return loaders, nil
+}
+
+// BootEntries returns a boot entry for every loader found by FindLoaders.
+func BootEntries(r io.ReaderAt) ([]*efi.BootEntry, error) {
+	loaders, err := FindLoaders(r)
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]*efi.BootEntry, 0, len(loaders))
+	for _, l := range loaders {
+		entries = append(entries, l.BootEntry())
+	}
+	return entries, nil
+}
+
+// findLoaderPaths lists the \EFI\*\*.efi files of a FAT file system.
+func findLoaderPaths(r io.ReaderAt) ([]string, error) {
+	vol, err := fat.OpenReader(r)
+	if err != nil {
+		return nil, err
+	}
+	root, err := vol.ReadDir("/")
+	if err != nil {
+		return nil, err
+	}
+
+	var paths []string
+	for _, efiDir := range root {
+		if !efiDir.IsDir || !strings.EqualFold(efiDir.Name, "EFI") {
+			continue
+		}
+		vendors, err := vol.ReadDir(efiDir.Name)
+		if err != nil {
+			return nil, err
+		}
+		for _, vendor := range vendors {
+			if !vendor.IsDir {
+				continue
+			}
+			files, err := vol.ReadDir(efiDir.Name + "/" + vendor.Name)
+			if err != nil {
+				return nil, err
+			}
+			for _, f := range files {
+				if f.IsDir || !strings.EqualFold(pathExt(f.Name), ".efi") {
+					continue
+				}
+				paths = append(paths, `\`+efiDir.Name+`\`+vendor.Name+`\`+f.Name)
+			}
+		}
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+func pathExt(name string) string {
+	if i := strings.LastIndexByte(name, '.'); i >= 0 {
+		return name[i:]
+	}
+	return ""
+}
@@ -0,0 +1,244 @@
+package esp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf16"
+
+	"github.com/metal3-community/uefi-firmware-manager/efi"
+)
+
+const (
+	fatAttrDirectory = 0x10
+	fatAttrVolumeID  = 0x08
+	fatAttrLongName  = 0x0f
+
+	fatLowerBase = 0x08
+	fatLowerExt  = 0x10
+)
+
+const (
+	testESPStart  = 64
+	testESPEnd    = 127
+	testPartGUID  = "2d4e5f6a-7b8c-4d9e-a0b1-c2d3e4f5a6b7"
+	testFATSector = 512
+)
+
+// dirent builds a short directory entry.
+func dirent(name string, attr, ntres byte, cluster uint16) []byte {
+	e := make([]byte, 32)
+	copy(e, []byte(name))
+	e[11] = attr
+	e[12] = ntres
+	binary.LittleEndian.PutUint16(e[26:28], cluster)
+	return e
+}
+
+// lfnEntries builds the long name entries for name, in on-disk order.
+func lfnEntries(name string, short string) []byte {
+	u := utf16.Encode([]rune(name))
+	u = append(u, 0)
+	for len(u)%13 != 0 {
+		u = append(u, 0xffff)
+	}
+	var sum byte
+	for _, c := range []byte(short) {
+		sum = (sum>>1 | sum<<7) + c
+	}
+	n := len(u) / 13
+	var out []byte
+	for i := n; i >= 1; i-- {
+		e := make([]byte, 32)
+		e[0] = byte(i)
+		if i == n {
+			e[0] |= 0x40
+		}
+		e[11] = fatAttrLongName
+		e[13] = sum
+		chars := u[(i-1)*13 : i*13]
+		k := 0
+		for _, r := range [][2]int{{1, 11}, {14, 26}, {28, 32}} {
+			for j := r[0]; j < r[1]; j += 2 {
+				binary.LittleEndian.PutUint16(e[j:], chars[k])
+				k++
+			}
+		}
+		out = append(out, e...)
+	}
+	return out
+}
+
+// buildFAT12 returns a small FAT12 volume with a few boot loaders.
+func buildFAT12() []byte {
+	const sectors = testESPEnd - testESPStart + 1
+	img := make([]byte, sectors*testFATSector)
+	bs := img[0:512]
+	binary.LittleEndian.PutUint16(bs[11:], testFATSector)
+	bs[13] = 1                                 // sectors per cluster
+	binary.LittleEndian.PutUint16(bs[14:], 1)  // reserved sectors
+	bs[16] = 1                                 // FATs
+	binary.LittleEndian.PutUint16(bs[17:], 16) // root entries
+	binary.LittleEndian.PutUint16(bs[19:], sectors)
+	binary.LittleEndian.PutUint16(bs[22:], 1) // sectors per FAT
+	bs[510], bs[511] = 0x55, 0xaa
+
+	// Clusters 2-4 are single-cluster directories.
+	fat := img[512:1024]
+	copy(fat, []byte{0xf8, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x0f})
+
+	dataSector := 3 // reserved + FAT + root directory
+	cluster := func(n int) []byte {
+		off := (dataSector + n - 2) * testFATSector
+		return img[off : off+testFATSector]
+	}
+
+	root := img[1024:1536]
+	copy(root, bytes.Join([][]byte{
+		dirent("ESP        ", fatAttrVolumeID, 0, 0),
+		dirent("EFI        ", fatAttrDirectory, 0, 2),
+	}, nil))
+	copy(cluster(2), bytes.Join([][]byte{
+		dirent(".          ", fatAttrDirectory, 0, 2),
+		dirent("..         ", fatAttrDirectory, 0, 0),
+		dirent("BOOT       ", fatAttrDirectory, 0, 3),
+		dirent("DEBIAN     ", fatAttrDirectory, fatLowerBase, 4),
+	}, nil))
+	copy(cluster(3), bytes.Join([][]byte{
+		dirent("BOOTAA64EFI", 0x20, 0, 0),
+		dirent("README  TXT", 0x20, 0, 0),
+	}, nil))
+	deleted := dirent("OLD     EFI", 0x20, 0, 0)
+	deleted[0] = 0xe5
+	copy(cluster(4), bytes.Join([][]byte{
+		dirent("SHIMAA64EFI", 0x20, fatLowerBase|fatLowerExt, 0),
+		lfnEntries("fwupdaa64.efi", "FWUPDA~1EFI"),
+		dirent("FWUPDA~1EFI", 0x20, 0, 0),
+		deleted,
+	}, nil))
+	return img
+}
+
+// buildDisk wraps fs in a GPT disk image with 512 byte sectors.
+func buildDisk(fs []byte, typeGUID string) []byte {
+	disk := make([]byte, (testESPEnd+1)*512)
+	copy(disk[testESPStart*512:], fs)
+
+	const numEntries = 4
+	entries := disk[2*512 : 2*512+numEntries*128]
+	e := entries[0:128]
+	copy(e[0:16], efi.StringToGUID(typeGUID).Bytes())
+	copy(e[16:32], efi.StringToGUID(testPartGUID).Bytes())
+	binary.LittleEndian.PutUint64(e[32:40], testESPStart)
+	binary.LittleEndian.PutUint64(e[40:48], testESPEnd)
+	for i, c := range utf16.Encode([]rune("EFI system partition")) {
+		binary.LittleEndian.PutUint16(e[56+2*i:], c)
+	}
+
+	hdr := disk[512 : 512+92]
+	copy(hdr, gptSignature)
+	binary.LittleEndian.PutUint32(hdr[8:12], 0x00010000)
+	binary.LittleEndian.PutUint32(hdr[12:16], 92)
+	binary.LittleEndian.PutUint64(hdr[24:32], 1)
+	binary.LittleEndian.PutUint64(hdr[72:80], 2)
+	binary.LittleEndian.PutUint32(hdr[80:84], numEntries)
+	binary.LittleEndian.PutUint32(hdr[84:88], 128)
+	binary.LittleEndian.PutUint32(hdr[88:92], crc32.ChecksumIEEE(entries))
+	binary.LittleEndian.PutUint32(hdr[16:20], crc32.ChecksumIEEE(hdr))
+	return disk
+}
+
+func TestFindLoaders(t *testing.T) {
+	disk := buildDisk(buildFAT12(), ESPTypeGUID)
+
+	loaders, err := FindLoaders(bytes.NewReader(disk))
+	if err != nil {
+		t.Fatalf("FindLoaders() error = %v", err)
+	}
+	var paths []string
+	for _, l := range loaders {
+		paths = append(paths, l.Path)
+	}
+	want := []string{
+		`\EFI\BOOT\BOOTAA64.EFI`,
+		`\EFI\debian\fwupdaa64.efi`,
+		`\EFI\debian\shimaa64.efi`,
+	}
+	if !reflect.DeepEqual(paths, want) {
+		t.Fatalf("FindLoaders() paths = %v, want %v", paths, want)
+	}
+
+	p := loaders[0].Partition
+	if p.Number != 1 || p.UniqueGUID.String() != testPartGUID || p.Name != "EFI system partition" {
+		t.Errorf("unexpected partition %+v", p)
+	}
+
+	entries, err := BootEntries(bytes.NewReader(disk))
+	if err != nil {
+		t.Fatalf("BootEntries() error = %v", err)
+	}
+	shim := entries[2]
+	if got := shim.Title.String(); got != "debian" {
+		t.Errorf("Title = %q, want %q", got, "debian")
+	}
+	wantPath := (&efi.DevicePath{}).
+		GptPartition(1, testESPStart, testESPEnd-testESPStart+1, testPartGUID).
+		FilePath(`\EFI\debian\shimaa64.efi`)
+	if !shim.DevicePath.Equal(wantPath) {
+		t.Errorf("DevicePath = %s, want %s", shim.DevicePath.String(), wantPath.String())
+	}
+
+	// The generated entries must survive a serialize/parse round trip.
+	data, err := shim.Serialize()
+	if err != nil {
+		t.Fatalf("Serialize() error = %v", err)
+	}
+	if _, err := efi.ParseBootEntry(data); err != nil {
+		t.Errorf("ParseBootEntry() error = %v", err)
+	}
+}
+
+func TestFindLoaders_Errors(t *testing.T) {
+	if _, err := FindLoaders(bytes.NewReader(make([]byte, 8192))); !errors.Is(err, ErrNoGPT) {
+		t.Errorf("blank disk: error = %v, want ErrNoGPT", err)
+	}
+
+	linux := "0fc63daf-8483-4772-8e79-3d69d8477de4"
+	disk := buildDisk(buildFAT12(), linux)
+	if _, err := FindLoaders(bytes.NewReader(disk)); !errors.Is(err, ErrNoESP) {
+		t.Errorf("no ESP: error = %v, want ErrNoESP", err)
+	}
+
+	corrupt := buildDisk(buildFAT12(), ESPTypeGUID)
+	corrupt[2*512] ^= 0xff
+	if _, err := FindLoaders(bytes.NewReader(corrupt)); err == nil {
+		t.Errorf("corrupt entry array: expected an error")
+	}
+
+	// Entry arrays with impossible sizes are refused before they are read.
+	for _, tt := range []struct {
+		name                  string
+		numEntries, entrySize uint32
+	}{
+		{"short entries", 4, 64},
+		{"unaligned entries", 4, 132},
+		{"huge entries", 4, 1 << 20},
+		{"huge array", 1 << 20, 128},
+		{"overflowing array", 0xffffffff, 0xfffffff8},
+	} {
+		disk := buildDisk(buildFAT12(), ESPTypeGUID)
+		hdr := disk[512 : 512+92]
+		binary.LittleEndian.PutUint32(hdr[80:84], tt.numEntries)
+		binary.LittleEndian.PutUint32(hdr[84:88], tt.entrySize)
+		binary.LittleEndian.PutUint32(hdr[16:20], 0)
+		binary.LittleEndian.PutUint32(hdr[16:20], crc32.ChecksumIEEE(hdr))
+		_, err := ReadPartitions(bytes.NewReader(disk))
+		if err == nil || !strings.Contains(err.Error(), "invalid GPT entry array") {
+			t.Errorf("%s: error = %v, want an invalid entry array", tt.name, err)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package esp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"unicode/utf16"
+
+	"github.com/metal3-community/uefi-firmware-manager/efi"
+)
+
+// ESPTypeGUID is the GPT partition type of an EFI system partition.
+const ESPTypeGUID = "c12a7328-f81f-11d2-ba4b-00a0c93ec93b"
+
+const gptSignature = "EFI PART"
+
+const (
+	// maxGPTEntrySize bounds the size of a partition entry; the UEFI
+	// specification requires a multiple of 8 of at least 128 bytes.
+	maxGPTEntrySize = 4096
+	// maxGPTEntryArraySize bounds the partition entry array read from a
+	// header, which usually holds 128 entries of 128 bytes.
+	maxGPTEntryArraySize = 1 << 20
+)
+
+// ErrNoGPT is returned when a disk image has no valid GPT header.
+var ErrNoGPT = errors.New("no GPT partition table found")
+
+// Partition is a GPT partition entry.
+type Partition struct {
+	// Number is the 1-based index of the entry in the partition table.
+	Number     uint32
+	TypeGUID   efi.GUID
+	UniqueGUID efi.GUID
+	FirstLBA   uint64
+	LastLBA    uint64
+	Name       string
+	// SectorSize is the logical block size of the disk.
+	SectorSize int
+}
+
+// Offset returns the byte offset of the partition.
+func (p Partition) Offset() int64 {
+	return int64(p.FirstLBA) * int64(p.SectorSize)
+}
+
+// Size returns the partition size in bytes.
+func (p Partition) Size() int64 {
+	return int64(p.LastLBA-p.FirstLBA+1) * int64(p.SectorSize)
+}
+
+// IsESP reports whether the partition is an EFI system partition.
+func (p Partition) IsESP() bool {
+	return p.TypeGUID.String() == ESPTypeGUID
+}
+
+// ReadPartitions reads the primary GPT of a disk image. Both 512 and 4096
+// byte sectors are tried.
+func ReadPartitions(r io.ReaderAt) ([]Partition, error) {
+	for _, sectorSize := range []int{512, 4096} {
+		parts, err := readGPT(r, sectorSize)
+		if errors.Is(err, ErrNoGPT) {
+			continue
+		}
+		return parts, err
+	}
+	return nil, ErrNoGPT
+}
+
+func readGPT(r io.ReaderAt, sectorSize int) ([]Partition, error) {
+	hdr := make([]byte, 92)
+	if _, err := r.ReadAt(hdr, int64(sectorSize)); err != nil {
+		return nil, ErrNoGPT
+	}
+	if !bytes.Equal(hdr[0:8], []byte(gptSignature)) {
+		return nil, ErrNoGPT
+	}
+
+	hdrSize := binary.LittleEndian.Uint32(hdr[12:16])
+	if hdrSize < 92 || int(hdrSize) > sectorSize {
+		return nil, fmt.Errorf("invalid GPT header size %d", hdrSize)
+	}
+	hdr = make([]byte, hdrSize)
+	if _, err := r.ReadAt(hdr, int64(sectorSize)); err != nil {
+		return nil, fmt.Errorf("failed to read GPT header: %w", err)
+	}
+	crc := binary.LittleEndian.Uint32(hdr[16:20])
+	binary.LittleEndian.PutUint32(hdr[16:20], 0)
+	if crc32.ChecksumIEEE(hdr) != crc {
+		return nil, fmt.Errorf("GPT header checksum mismatch")
+	}
+
+	entriesLBA := binary.LittleEndian.Uint64(hdr[72:80])
+	numEntries := binary.LittleEndian.Uint32(hdr[80:84])
+	entrySize := binary.LittleEndian.Uint32(hdr[84:88])
+	entriesCRC := binary.LittleEndian.Uint32(hdr[88:92])
+	if entrySize < 128 || entrySize%8 != 0 || entrySize > maxGPTEntrySize ||
+		uint64(numEntries)*uint64(entrySize) > maxGPTEntryArraySize {
+		return nil, fmt.Errorf("invalid GPT entry array: %d entries of %d bytes",
+			numEntries, entrySize)
+	}
+
+	entries := make([]byte, int(numEntries)*int(entrySize))
+	if _, err := r.ReadAt(entries, int64(entriesLBA)*int64(sectorSize)); err != nil {
+		return nil, fmt.Errorf("failed to read GPT entries: %w", err)
+	}
+	if crc32.ChecksumIEEE(entries) != entriesCRC {
+		return nil, fmt.Errorf("GPT entry array checksum mismatch")
+	}
+
+	var parts []Partition
+	for i := range int(numEntries) {
+		e := entries[i*int(entrySize) : (i+1)*int(entrySize)]
+		p := Partition{
+			Number:     uint32(i + 1),
+			TypeGUID:   efi.ParseBinGUID(e, 0),
+			UniqueGUID: efi.ParseBinGUID(e, 16),
+			FirstLBA:   binary.LittleEndian.Uint64(e[32:40]),
+			LastLBA:    binary.LittleEndian.Uint64(e[40:48]),
+			Name:       partitionName(e[56:128]),
+			SectorSize: sectorSize,
+		}
+		if p.TypeGUID == (efi.GUID{}) {
+			continue
+		}
+		if p.LastLBA < p.FirstLBA {
+			return nil, fmt.Errorf("partition %d ends before it starts", p.Number)
+		}
+		parts = append(parts, p)
+	}
+	return parts, nil
+}
+
+func partitionName(data []byte) string {
+	u := make([]uint16, 0, len(data)/2)
+	for i := 0; i+1 < len(data); i += 2 {
+		c := binary.LittleEndian.Uint16(data[i:])
+		if c == 0 {
+			break
+		}
+		u = append(u, c)
+	}
+	return string(utf16.Decode(u))
+}
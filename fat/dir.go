@@ -0,0 +1,633 @@
+package fat
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strings"
+	"unicode/utf16"
+)
+
+const (
+	attrVolumeID  = 0x08
+	attrDirectory = 0x10
+	attrArchive   = 0x20
+	attrLongName  = 0x0f
+
+	ntLowerBase = 0x08
+	ntLowerExt  = 0x10
+
+	slotFree    = 0xe5
+	lfnLastSlot = 0x40
+
+	// fatDate is 1980-01-01, the FAT epoch. Every entry gets it so that
+	// the same files always give the same volume.
+	fatDate = 1<<5 | 1
+)
+
+// DirEntry is an entry of a directory.
+type DirEntry struct {
+	// Name is the long name of the entry.
+	Name string
+	// ShortName is the 8.3 alias of the entry, e.g. LONGFI~1.DTB.
+	ShortName string
+	IsDir     bool
+	Size      int64
+}
+
+// entry is a directory entry with its location.
+type entry struct {
+	DirEntry
+	cluster uint32
+	// slot is the index of the first 32 byte slot of the entry in its
+	// directory and slots the number of slots, long name included.
+	slot, slots int
+}
+
+// dir is a directory read from the volume.
+type dir struct {
+	clusters []uint32
+	data     []byte
+	entries  []entry
+}
+
+// readDir reads the directory starting at cluster c, or the fixed root
+// directory of FAT12 and FAT16 if c is 0.
+func (v *Volume) readDir(c uint32) (*dir, error) {
+	var clusters []uint32
+	var data []byte
+	var err error
+	if c == 0 && v.rootCluster == 0 {
+		data = make([]byte, v.rootDirSize)
+		_, err = v.dev.ReadAt(data, v.rootDirOffset)
+	} else {
+		if clusters, err = v.chain(c); err != nil {
+			return nil, err
+		}
+		data, err = v.readChain(c)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read directory: %w", err)
+	}
+	d := &dir{clusters: clusters, data: data}
+
+	var lfn []uint16
+	var lfnSum byte
+	first := 0
+	for i := 0; (i+1)*32 <= len(data); i++ {
+		e := data[i*32 : (i+1)*32]
+		switch {
+		case e[0] == 0:
+			return d, nil
+		case e[0] == slotFree:
+			lfn = nil
+			continue
+		case e[11] == attrLongName:
+			if e[0]&lfnLastSlot != 0 || lfn == nil {
+				lfn, first = nil, i
+			}
+			lfn = append(lfnChars(e), lfn...)
+			lfnSum = e[13]
+			continue
+		}
+		// A long name left over from a short entry changed by a system
+		// without long name support does not match its checksum.
+		if lfn != nil && lfnSum != shortNameSum(e[0:11]) {
+			lfn = nil
+		}
+		if lfn == nil {
+			first = i
+		}
+		base := strings.TrimRight(string(e[0:8]), " ")
+		ext := strings.TrimRight(string(e[8:11]), " ")
+		name := decodeLFN(lfn)
+		lfn = nil
+		if e[11]&attrVolumeID != 0 || base == "." || base == ".." {
+			continue
+		}
+		short := base
+		if ext != "" {
+			short += "." + ext
+		}
+		if name == "" {
+			if e[12]&ntLowerBase != 0 {
+				base = strings.ToLower(base)
+			}
+			if e[12]&ntLowerExt != 0 {
+				ext = strings.ToLower(ext)
+			}
+			name = base
+			if ext != "" {
+				name += "." + ext
+			}
+		}
+		d.entries = append(d.entries, entry{
+			DirEntry: DirEntry{
+				Name:      name,
+				ShortName: short,
+				IsDir:     e[11]&attrDirectory != 0,
+				Size:      int64(binary.LittleEndian.Uint32(e[28:32])),
+			},
+			cluster: uint32(binary.LittleEndian.Uint16(e[20:22]))<<16 |
+				uint32(binary.LittleEndian.Uint16(e[26:28])),
+			slot:  first,
+			slots: i - first + 1,
+		})
+	}
+	return d, nil
+}
+
+// lookup finds name in d, ignoring case.
+func (d *dir) lookup(name string) *entry {
+	for i := range d.entries {
+		if strings.EqualFold(d.entries[i].Name, name) {
+			return &d.entries[i]
+		}
+	}
+	return nil
+}
+
+// writeSlots writes slots to d starting at slot index i.
+func (v *Volume) writeSlots(d *dir, i int, slots ...[]byte) error {
+	size := v.clusterSize()
+	for j, slot := range slots {
+		off := (i + j) * 32
+		copy(d.data[off:], slot)
+		c := d.clusters[off/size]
+		if _, err := v.dev.WriteAt(slot, v.clusterOffset(c)+int64(off%size)); err != nil {
+			return fmt.Errorf("failed to write directory: %w", err)
+		}
+	}
+	return nil
+}
+
+// split splits path into its directory names and final name.
+func split(path string) ([]string, string) {
+	var names []string
+	for name := range strings.SplitSeq(path, "/") {
+		if name != "" {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return nil, ""
+	}
+	return names[:len(names)-1], names[len(names)-1]
+}
+
+// walk returns the directory holding the last element of path, creating
+// missing directories if create is set.
+func (v *Volume) walk(path string, create bool) (*dir, string, error) {
+	dirs, name := split(path)
+	d, err := v.readDir(v.rootCluster)
+	if err != nil {
+		return nil, "", err
+	}
+	for i, dirName := range dirs {
+		e := d.lookup(dirName)
+		var c uint32
+		switch {
+		case e != nil && !e.IsDir:
+			return nil, "", fmt.Errorf("%s is not a directory", strings.Join(dirs[:i+1], "/"))
+		case e != nil:
+			c = e.cluster
+		case !create:
+			return nil, "", fmt.Errorf("%s: %w", strings.Join(dirs[:i+1], "/"), fs.ErrNotExist)
+		default:
+			if c, err = v.mkdir(d, dirName); err != nil {
+				return nil, "", err
+			}
+		}
+		if d, err = v.readDir(c); err != nil {
+			return nil, "", err
+		}
+	}
+	return d, name, nil
+}
+
+// mkdir creates the directory name in parent and returns its cluster.
+func (v *Volume) mkdir(parent *dir, name string) (uint32, error) {
+	if err := validName(name); err != nil {
+		return 0, err
+	}
+	c, err := v.alloc(1)
+	if err != nil {
+		return 0, err
+	}
+	up := parent.clusters[0]
+	if up == v.rootCluster {
+		up = 0
+	}
+	buf := make([]byte, v.clusterSize())
+	copy(buf[0:32], shortEntry(padName(".", 11), attrDirectory, 0, c, 0))
+	copy(buf[32:64], shortEntry(padName("..", 11), attrDirectory, 0, up, 0))
+	if _, err := v.dev.WriteAt(buf, v.clusterOffset(c)); err != nil {
+		return 0, err
+	}
+	if err := v.addEntry(parent, name, attrDirectory, c, 0); err != nil {
+		v.free(c)
+		return 0, err
+	}
+	return c, nil
+}
+
+// ReadDir returns the entries of the directory at path, sorted by name.
+func (v *Volume) ReadDir(path string) ([]DirEntry, error) {
+	d, name, err := v.walk(path, false)
+	if err != nil {
+		return nil, err
+	}
+	if name != "" {
+		e := d.lookup(name)
+		if e == nil {
+			return nil, fmt.Errorf("%s: %w", path, fs.ErrNotExist)
+		}
+		if !e.IsDir {
+			return nil, fmt.Errorf("%s is not a directory", path)
+		}
+		if d, err = v.readDir(e.cluster); err != nil {
+			return nil, err
+		}
+	}
+	entries := make([]DirEntry, len(d.entries))
+	for i, e := range d.entries {
+		entries[i] = e.DirEntry
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+	return entries, nil
+}
+
+// ReadFile returns the contents of the file at path.
+func (v *Volume) ReadFile(path string) ([]byte, error) {
+	d, name, err := v.walk(path, false)
+	if err != nil {
+		return nil, err
+	}
+	e := d.lookup(name)
+	if e == nil {
+		return nil, fmt.Errorf("%s: %w", path, fs.ErrNotExist)
+	}
+	if e.IsDir {
+		return nil, fmt.Errorf("%s is a directory", path)
+	}
+	data, err := v.readChain(e.cluster)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	if int64(len(data)) < e.Size {
+		return nil, fmt.Errorf("%s is truncated", path)
+	}
+	return data[:e.Size], nil
+}
+
+// MkdirAll creates the directory at path and its missing parents.
+func (v *Volume) MkdirAll(path string) error {
+	if v.readOnly {
+		return ErrReadOnly
+	}
+	d, name, err := v.walk(path, true)
+	if err != nil || name == "" {
+		return err
+	}
+	if e := d.lookup(name); e != nil {
+		if !e.IsDir {
+			return fmt.Errorf("%s is not a directory", path)
+		}
+		return nil
+	}
+	_, err = v.mkdir(d, name)
+	return err
+}
+
+// WriteFile writes data to the file at path, replacing it if it exists
+// and creating missing parent directories.
+func (v *Volume) WriteFile(path string, data []byte) error {
+	if v.readOnly {
+		return ErrReadOnly
+	}
+	if uint64(len(data)) > 0xffffffff {
+		return fmt.Errorf("%s is too large for FAT", path)
+	}
+	d, name, err := v.walk(path, true)
+	if err != nil {
+		return err
+	}
+	if err := validName(name); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	old := d.lookup(name)
+	if old != nil && old.IsDir {
+		return fmt.Errorf("%s is a directory", path)
+	}
+	c, err := v.writeData(data)
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if old == nil {
+		if err := v.addEntry(d, name, attrArchive, c, uint32(len(data))); err != nil {
+			v.free(c)
+			return err
+		}
+		return nil
+	}
+
+	v.free(old.cluster)
+	i := old.slot + old.slots - 1
+	short := d.data[i*32 : (i+1)*32]
+	binary.LittleEndian.PutUint16(short[20:22], uint16(c>>16))
+	binary.LittleEndian.PutUint16(short[26:28], uint16(c))
+	binary.LittleEndian.PutUint32(short[28:32], uint32(len(data)))
+	return v.writeSlots(d, i, short)
+}
+
+// WriteFiles writes files, keyed by path, in path order.
+func (v *Volume) WriteFiles(files map[string][]byte) error {
+	paths := make([]string, 0, len(files))
+	for path := range files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		if err := v.WriteFile(path, files[path]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Remove removes the file or empty directory at path.
+func (v *Volume) Remove(path string) error {
+	if v.readOnly {
+		return ErrReadOnly
+	}
+	d, name, err := v.walk(path, false)
+	if err != nil {
+		return err
+	}
+	e := d.lookup(name)
+	if e == nil {
+		return fmt.Errorf("%s: %w", path, fs.ErrNotExist)
+	}
+	if e.IsDir {
+		sub, err := v.readDir(e.cluster)
+		if err != nil {
+			return err
+		}
+		if len(sub.entries) != 0 {
+			return fmt.Errorf("%s is not empty", path)
+		}
+	}
+
+	v.free(e.cluster)
+	for i := e.slot; i < e.slot+e.slots; i++ {
+		slot := append([]byte(nil), d.data[i*32:(i+1)*32]...)
+		slot[0] = slotFree
+		if err := v.writeSlots(d, i, slot); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addEntry adds an entry for name to d, growing the directory if it has
+// no room.
+func (v *Volume) addEntry(d *dir, name string, attr byte, cluster, size uint32) error {
+	taken := make(map[string]bool, len(d.entries))
+	for _, e := range d.entries {
+		taken[e.ShortName] = true
+	}
+	short, ntRes, long := shortName(name, taken)
+	slots := lfnEntries(name, short, long)
+	slots = append(slots, shortEntry(padShort(short), attr, ntRes, cluster, size))
+
+	// Find a run of free slots, or append clusters to the directory.
+	start, run := 0, 0
+	for i := 0; (i+1)*32 <= len(d.data) && run < len(slots); i++ {
+		if b := d.data[i*32]; b == 0 || b == slotFree {
+			if run == 0 {
+				start = i
+			}
+			run++
+		} else {
+			run = 0
+		}
+	}
+	for run < len(slots) {
+		c, err := v.alloc(1)
+		if err != nil {
+			return err
+		}
+		buf := make([]byte, v.clusterSize())
+		if _, err := v.dev.WriteAt(buf, v.clusterOffset(c)); err != nil {
+			return err
+		}
+		v.fat[d.clusters[len(d.clusters)-1]] = c
+		if run == 0 {
+			start = len(d.data) / 32
+		}
+		d.clusters = append(d.clusters, c)
+		d.data = append(d.data, buf...)
+		run += len(buf) / 32
+	}
+	return v.writeSlots(d, start, slots...)
+}
+
+// shortEntry encodes a short directory entry. name is the 11 byte padded
+// 8.3 name.
+func shortEntry(name []byte, attr, ntRes byte, cluster, size uint32) []byte {
+	e := make([]byte, 32)
+	copy(e[0:11], name)
+	e[11] = attr
+	e[12] = ntRes
+	binary.LittleEndian.PutUint16(e[16:18], fatDate)
+	binary.LittleEndian.PutUint16(e[18:20], fatDate)
+	binary.LittleEndian.PutUint16(e[20:22], uint16(cluster>>16))
+	binary.LittleEndian.PutUint16(e[24:26], fatDate)
+	binary.LittleEndian.PutUint16(e[26:28], uint16(cluster))
+	binary.LittleEndian.PutUint32(e[28:32], size)
+	return e
+}
+
+// padShort pads a "NAME.EXT" short name to the 11 bytes of an entry.
+func padShort(name string) []byte {
+	base, ext, _ := strings.Cut(name, ".")
+	return append(padName(base, 8), padName(ext, 3)...)
+}
+
+func padName(s string, n int) []byte {
+	b := []byte(strings.Repeat(" ", n))
+	copy(b, strings.ToUpper(s))
+	return b
+}
+
+// validName checks that name can be a FAT long name.
+func validName(name string) error {
+	if name == "" || name == "." || name == ".." {
+		return fmt.Errorf("invalid file name %q", name)
+	}
+	if len(utf16.Encode([]rune(name))) > 255 {
+		return fmt.Errorf("file name %q is longer than 255 characters", name)
+	}
+	for _, c := range name {
+		if c < 0x20 || strings.ContainsRune(`"*/:<>?\|`, c) {
+			return fmt.Errorf("file name %q has invalid character %q", name, c)
+		}
+	}
+	return nil
+}
+
+// shortName returns the 8.3 name of name, unique among taken, with the
+// NTRes case flags. long is true if name needs long name entries.
+func shortName(name string, taken map[string]bool) (short string, ntRes byte, long bool) {
+	base, ext := name, ""
+	if i := strings.LastIndexByte(name, '.'); i > 0 {
+		base, ext = name[:i], name[i+1:]
+	}
+	if len(base) <= 8 && len(ext) <= 3 && validShort(base) && validShort(ext) {
+		baseCase, extCase := nameCase(base), nameCase(ext)
+		if baseCase >= 0 && extCase >= 0 {
+			short = strings.ToUpper(base)
+			if ext != "" {
+				short += "." + strings.ToUpper(ext)
+			}
+			if !taken[short] {
+				if baseCase == 1 {
+					ntRes |= ntLowerBase
+				}
+				if extCase == 1 {
+					ntRes |= ntLowerExt
+				}
+				return short, ntRes, false
+			}
+		}
+	}
+
+	basis := shortBasis(strings.TrimLeft(base, "."))
+	if ext != "" {
+		ext = shortBasis(ext)
+		ext = ext[:min(len(ext), 3)]
+	}
+	for n := 1; ; n++ {
+		tail := fmt.Sprintf("~%d", n)
+		short = basis[:min(len(basis), 8-len(tail))] + tail
+		if ext != "" {
+			short += "." + ext
+		}
+		if !taken[short] {
+			return short, 0, true
+		}
+	}
+}
+
+// nameCase returns 0 if s has no lower case letters, 1 if it has no upper
+// case letters and -1 if it has both.
+func nameCase(s string) int {
+	lower, upper := false, false
+	for _, c := range s {
+		lower = lower || c >= 'a' && c <= 'z'
+		upper = upper || c >= 'A' && c <= 'Z'
+	}
+	switch {
+	case lower && upper:
+		return -1
+	case lower:
+		return 1
+	}
+	return 0
+}
+
+func validShort(s string) bool {
+	for _, c := range s {
+		if !shortChar(c) {
+			return false
+		}
+	}
+	return true
+}
+
+func shortChar(c rune) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' ||
+		strings.ContainsRune("!#$%&'()-@^_`{}~", c)
+}
+
+// shortBasis maps s to upper case short name characters, dropping spaces
+// and dots and replacing other characters with "_".
+func shortBasis(s string) string {
+	var b strings.Builder
+	for _, c := range strings.ToUpper(s) {
+		switch {
+		case c == ' ' || c == '.':
+		case shortChar(c):
+			b.WriteRune(c)
+		default:
+			b.WriteByte('_')
+		}
+	}
+	if b.Len() == 0 {
+		return "_"
+	}
+	return b.String()
+}
+
+// lfnEntries encodes the long name entries of name, in on-disk order, or
+// nil if long is false.
+func lfnEntries(name, short string, long bool) [][]byte {
+	if !long {
+		return nil
+	}
+	u := utf16.Encode([]rune(name))
+	if len(u)%13 != 0 {
+		u = append(u, 0)
+	}
+	for len(u)%13 != 0 {
+		u = append(u, 0xffff)
+	}
+	sum := shortNameSum(padShort(short))
+	n := len(u) / 13
+	slots := make([][]byte, n)
+	for i := range n {
+		e := make([]byte, 32)
+		e[0] = byte(i + 1)
+		if i == n-1 {
+			e[0] |= lfnLastSlot
+		}
+		e[11] = attrLongName
+		e[13] = sum
+		for j, off := range lfnOffsets {
+			binary.LittleEndian.PutUint16(e[off:], u[i*13+j])
+		}
+		slots[n-1-i] = e
+	}
+	return slots
+}
+
+// lfnOffsets are the offsets of the 13 characters of a long name entry.
+var lfnOffsets = [13]int{1, 3, 5, 7, 9, 14, 16, 18, 20, 22, 24, 28, 30}
+
+func lfnChars(e []byte) []uint16 {
+	u := make([]uint16, 0, 13)
+	for _, off := range lfnOffsets {
+		u = append(u, binary.LittleEndian.Uint16(e[off:]))
+	}
+	return u
+}
+
+func decodeLFN(u []uint16) string {
+	for i, c := range u {
+		if c == 0 {
+			u = u[:i]
+			break
+		}
+	}
+	return string(utf16.Decode(u))
+}
+
+func shortNameSum(name []byte) byte {
+	var sum byte
+	for _, c := range name[:11] {
+		sum = (sum&1)<<7 + sum>>1 + c
+	}
+	return sum
+}
@@ -0,0 +1,492 @@
+// Package fat reads and writes FAT32 file systems, such as the boot
+// partition of a Raspberry Pi SD card. It can format a new volume and add,
+// replace or remove files on an existing one. FAT12 and FAT16 volumes,
+// such as small EFI system partitions, can be read.
+package fat
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+const (
+	// SectorSize is the sector size of volumes created by Format.
+	SectorSize = 512
+
+	fatReserved = 32
+	fatCount    = 2
+	minClusters = 65525
+	maxClusters = 0x0ffffff5 - 2
+	fatMedia    = 0xf8
+	fatEOC      = 0x0fffffff
+	fatBad      = 0x0ffffff7
+	rootCluster = 2
+
+	// fat12Clusters is the cluster count below which a volume is FAT12.
+	fat12Clusters = 4085
+
+	fsInfoLeadSig   = 0x41615252
+	fsInfoStructSig = 0x61417272
+	fsInfoTrailSig  = 0xaa550000
+)
+
+var (
+	// ErrVolumeFull is returned when a volume has no free cluster left.
+	ErrVolumeFull = errors.New("FAT volume is full")
+	// ErrReadOnly is returned when changing a volume opened by OpenReader.
+	ErrReadOnly = errors.New("FAT volume is read-only")
+)
+
+// Device is the storage of a volume. *os.File implements it, and
+// NewSection limits it to a partition of a disk image.
+type Device interface {
+	io.ReaderAt
+	io.WriterAt
+}
+
+// NewSection returns the n bytes of dev starting at off.
+func NewSection(dev Device, off, n int64) Device {
+	return &section{dev: dev, off: off, n: n}
+}
+
+type section struct {
+	dev    Device
+	off, n int64
+}
+
+func (s *section) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 || off+int64(len(p)) > s.n {
+		return 0, fmt.Errorf("read of %d bytes at %d is out of range", len(p), off)
+	}
+	return s.dev.ReadAt(p, s.off+off)
+}
+
+func (s *section) WriteAt(p []byte, off int64) (int, error) {
+	if off < 0 || off+int64(len(p)) > s.n {
+		return 0, fmt.Errorf("write of %d bytes at %d is out of range", len(p), off)
+	}
+	return s.dev.WriteAt(p, s.off+off)
+}
+
+// FormatOptions configures a new volume.
+type FormatOptions struct {
+	// Label is the volume label, at most 11 characters.
+	Label string
+	// VolumeID is the volume serial number.
+	VolumeID uint32
+	// HiddenSectors is the number of sectors before the volume on its
+	// disk, that is the first sector of its partition.
+	HiddenSectors uint32
+}
+
+// Volume is a FAT32 file system. The FAT is kept in memory and written
+// back by Flush, which must be called after changing the volume. Paths
+// are separated by "/" and, like on FAT, matched ignoring case.
+type Volume struct {
+	dev               Device
+	bytesPerSector    int
+	sectorsPerCluster int
+	reservedSectors   int64
+	fatSectors        int64
+	numFATs           int
+	fsInfoSector      int64
+	backupBootSector  int64
+	rootCluster       uint32
+	fat               []uint32
+	dataOffset        int64
+	nextFree          uint32
+	// rootDirOffset and rootDirSize locate the fixed root directory of
+	// FAT12 and FAT16, whose rootCluster is 0.
+	rootDirOffset int64
+	rootDirSize   int
+	readOnly      bool
+}
+
+// Format creates an empty FAT32 file system of size bytes on dev, with
+// the cluster size Windows would pick.
+func Format(dev Device, size int64, opts FormatOptions) (*Volume, error) {
+	if len(opts.Label) > 11 {
+		return nil, fmt.Errorf("volume label %q is longer than 11 characters", opts.Label)
+	}
+	sectors := size / SectorSize
+	var spc int64
+	switch {
+	case sectors <= 260<<11:
+		spc = 1
+	case sectors <= 8<<21:
+		spc = 8
+	case sectors <= 16<<21:
+		spc = 16
+	case sectors <= 32<<21:
+		spc = 32
+	default:
+		spc = 64
+	}
+	// Sizing of the FAT from the Microsoft FAT specification.
+	perFATSector := (256*spc + fatCount) / 2
+	fatSectors := (sectors - fatReserved + perFATSector - 1) / perFATSector
+	clusters := (sectors - fatReserved - fatCount*fatSectors) / spc
+	if clusters < minClusters {
+		return nil, fmt.Errorf("%d bytes are too few for FAT32", size)
+	}
+	if clusters > maxClusters || sectors > 0xffffffff {
+		return nil, fmt.Errorf("%d bytes are too many for FAT32", size)
+	}
+
+	v := &Volume{
+		dev:               dev,
+		bytesPerSector:    SectorSize,
+		sectorsPerCluster: int(spc),
+		reservedSectors:   fatReserved,
+		fatSectors:        fatSectors,
+		numFATs:           fatCount,
+		fsInfoSector:      1,
+		backupBootSector:  6,
+		rootCluster:       rootCluster,
+		fat:               make([]uint32, clusters+2),
+		dataOffset:        (fatReserved + fatCount*fatSectors) * SectorSize,
+		nextFree:          rootCluster + 1,
+	}
+	v.fat[0] = 0x0fffff00 | fatMedia
+	v.fat[1] = fatEOC
+	v.fat[rootCluster] = fatEOC
+
+	reserved := make([]byte, fatReserved*SectorSize)
+	bs := reserved[:SectorSize]
+	copy(bs, []byte{0xeb, 0x58, 0x90})
+	copy(bs[3:11], "MSWIN4.1")
+	binary.LittleEndian.PutUint16(bs[11:13], SectorSize)
+	bs[13] = byte(spc)
+	binary.LittleEndian.PutUint16(bs[14:16], fatReserved)
+	bs[16] = fatCount
+	bs[21] = fatMedia
+	binary.LittleEndian.PutUint16(bs[24:26], 63)
+	binary.LittleEndian.PutUint16(bs[26:28], 255)
+	binary.LittleEndian.PutUint32(bs[28:32], opts.HiddenSectors)
+	binary.LittleEndian.PutUint32(bs[32:36], uint32(sectors))
+	binary.LittleEndian.PutUint32(bs[36:40], uint32(fatSectors))
+	binary.LittleEndian.PutUint32(bs[44:48], rootCluster)
+	binary.LittleEndian.PutUint16(bs[48:50], uint16(v.fsInfoSector))
+	binary.LittleEndian.PutUint16(bs[50:52], uint16(v.backupBootSector))
+	bs[64] = 0x80
+	bs[66] = 0x29
+	binary.LittleEndian.PutUint32(bs[67:71], opts.VolumeID)
+	copy(bs[71:82], padName(opts.Label, 11))
+	copy(bs[82:90], "FAT32   ")
+	bs[510], bs[511] = 0x55, 0xaa
+
+	fsInfo := reserved[SectorSize : 2*SectorSize]
+	binary.LittleEndian.PutUint32(fsInfo[0:4], fsInfoLeadSig)
+	binary.LittleEndian.PutUint32(fsInfo[484:488], fsInfoStructSig)
+	binary.LittleEndian.PutUint32(fsInfo[508:512], fsInfoTrailSig)
+	copy(reserved[6*SectorSize:8*SectorSize], reserved[:2*SectorSize])
+	if _, err := dev.WriteAt(reserved, 0); err != nil {
+		return nil, fmt.Errorf("failed to write boot sector: %w", err)
+	}
+
+	root := make([]byte, v.clusterSize())
+	if opts.Label != "" {
+		copy(root[0:11], padName(opts.Label, 11))
+		root[11] = attrVolumeID
+		binary.LittleEndian.PutUint16(root[24:26], fatDate)
+	}
+	if _, err := dev.WriteAt(root, v.clusterOffset(rootCluster)); err != nil {
+		return nil, fmt.Errorf("failed to write root directory: %w", err)
+	}
+	return v, v.Flush()
+}
+
+// Open opens the FAT32 file system on dev. FAT12 and FAT16 volumes are
+// not supported; see OpenReader.
+func Open(dev Device) (*Volume, error) {
+	return open(dev, false)
+}
+
+// OpenReader opens the FAT12, FAT16 or FAT32 file system of r for reading.
+// Changing the volume fails with ErrReadOnly.
+func OpenReader(r io.ReaderAt) (*Volume, error) {
+	return open(readOnlyDevice{r}, true)
+}
+
+// readOnlyDevice is the Device of a volume opened by OpenReader.
+type readOnlyDevice struct {
+	io.ReaderAt
+}
+
+func (readOnlyDevice) WriteAt([]byte, int64) (int, error) {
+	return 0, ErrReadOnly
+}
+
+func open(dev Device, readOnly bool) (*Volume, error) {
+	bs := make([]byte, SectorSize)
+	if _, err := dev.ReadAt(bs, 0); err != nil {
+		return nil, fmt.Errorf("failed to read boot sector: %w", err)
+	}
+	if bs[510] != 0x55 || bs[511] != 0xaa {
+		return nil, errors.New("missing boot sector signature")
+	}
+
+	v := &Volume{
+		dev:               dev,
+		bytesPerSector:    int(binary.LittleEndian.Uint16(bs[11:13])),
+		sectorsPerCluster: int(bs[13]),
+		reservedSectors:   int64(binary.LittleEndian.Uint16(bs[14:16])),
+		numFATs:           int(bs[16]),
+		fatSectors:        int64(binary.LittleEndian.Uint16(bs[22:24])),
+		readOnly:          readOnly,
+	}
+	switch v.bytesPerSector {
+	case 512, 1024, 2048, 4096:
+	default:
+		return nil, fmt.Errorf("invalid bytes per sector %d", v.bytesPerSector)
+	}
+	if v.fatSectors == 0 {
+		v.fatSectors = int64(binary.LittleEndian.Uint32(bs[36:40]))
+	}
+	if v.sectorsPerCluster == 0 || v.numFATs == 0 || v.fatSectors == 0 {
+		return nil, errors.New("invalid FAT boot sector")
+	}
+
+	bps := int64(v.bytesPerSector)
+	rootEntries := int64(binary.LittleEndian.Uint16(bs[17:19]))
+	sectors := int64(binary.LittleEndian.Uint16(bs[19:21]))
+	if sectors == 0 {
+		sectors = int64(binary.LittleEndian.Uint32(bs[32:36]))
+	}
+	fatOffset := v.reservedSectors * bps
+	rootDirSectors := (rootEntries*32 + bps - 1) / bps
+	firstData := v.reservedSectors + int64(v.numFATs)*v.fatSectors + rootDirSectors
+	if sectors <= firstData {
+		return nil, errors.New("FAT volume has no data area")
+	}
+	clusters := (sectors - firstData) / int64(v.sectorsPerCluster)
+
+	// The cluster count alone tells the FAT type.
+	var bits int64
+	switch {
+	case clusters >= minClusters:
+		bits = 32
+		if rootEntries != 0 {
+			return nil, errors.New("not a FAT32 volume")
+		}
+		v.rootCluster = binary.LittleEndian.Uint32(bs[44:48])
+		v.fsInfoSector = int64(binary.LittleEndian.Uint16(bs[48:50]))
+		v.backupBootSector = int64(binary.LittleEndian.Uint16(bs[50:52]))
+		// Sector 0 or 0xffff means there is no FSInfo or backup.
+		if v.fsInfoSector == 0xffff {
+			v.fsInfoSector = 0
+		}
+		if v.backupBootSector == 0xffff {
+			v.backupBootSector = 0
+		}
+	case !readOnly:
+		return nil, fmt.Errorf("not a FAT32 volume: %d clusters", clusters)
+	case clusters < fat12Clusters:
+		bits = 12
+	default:
+		bits = 16
+	}
+	if bits != 32 {
+		v.rootDirOffset = fatOffset + int64(v.numFATs)*v.fatSectors*bps
+		v.rootDirSize = int(rootEntries) * 32
+	}
+	clusters = min(clusters, v.fatSectors*bps*8/bits-2, maxClusters)
+	if bits == 32 && (v.rootCluster < 2 || int64(v.rootCluster) >= clusters+2) {
+		return nil, fmt.Errorf("invalid root cluster %d", v.rootCluster)
+	}
+	v.dataOffset = firstData * bps
+
+	// The boot sector may claim a FAT larger than the device: make sure
+	// its last byte can be read before allocating it.
+	size := ((clusters+2)*bits + 7) / 8
+	if _, err := dev.ReadAt(make([]byte, 1), fatOffset+size-1); err != nil {
+		return nil, fmt.Errorf("FAT of %d bytes exceeds the volume: %w", size, err)
+	}
+	buf := make([]byte, size)
+	if _, err := dev.ReadAt(buf, fatOffset); err != nil {
+		return nil, fmt.Errorf("failed to read FAT: %w", err)
+	}
+	v.fat = make([]uint32, clusters+2)
+	for i := range v.fat {
+		v.fat[i] = fatEntry(buf, i, bits)
+	}
+
+	v.nextFree = rootCluster + 1
+	if v.fsInfoSector != 0 {
+		info := make([]byte, 512)
+		if _, err := dev.ReadAt(info, v.fsInfoSector*bps); err != nil {
+			return nil, fmt.Errorf("failed to read FSInfo: %w", err)
+		}
+		next := binary.LittleEndian.Uint32(info[492:496])
+		if binary.LittleEndian.Uint32(info[0:4]) == fsInfoLeadSig && next >= 2 && int64(next) < clusters+2 {
+			v.nextFree = next
+		}
+	}
+	return v, nil
+}
+
+// fatEntry decodes entry i of a FAT with entries of the given bits. The
+// bad cluster and end of chain markers of FAT12 and FAT16 are widened to
+// those of FAT32.
+func fatEntry(fat []byte, i int, bits int64) uint32 {
+	var n, bad uint32
+	switch bits {
+	case 12:
+		n = uint32(binary.LittleEndian.Uint16(fat[i*3/2:]))
+		if i&1 != 0 {
+			n >>= 4
+		}
+		n &= 0xfff
+		bad = 0xff7
+	case 16:
+		n = uint32(binary.LittleEndian.Uint16(fat[i*2:]))
+		bad = 0xfff7
+	default:
+		return binary.LittleEndian.Uint32(fat[i*4:]) & 0x0fffffff
+	}
+	if n >= bad {
+		n += fatBad - bad
+	}
+	return n
+}
+
+func (v *Volume) clusterSize() int {
+	return v.sectorsPerCluster * v.bytesPerSector
+}
+
+func (v *Volume) clusterOffset(c uint32) int64 {
+	return v.dataOffset + int64(c-2)*int64(v.clusterSize())
+}
+
+// chain returns the clusters of the chain starting at c.
+func (v *Volume) chain(c uint32) ([]uint32, error) {
+	var clusters []uint32
+	for c >= 2 && c < fatBad {
+		if int(c) >= len(v.fat) || len(clusters) >= len(v.fat) {
+			return nil, fmt.Errorf("invalid cluster chain at %d", c)
+		}
+		clusters = append(clusters, c)
+		c = v.fat[c]
+	}
+	return clusters, nil
+}
+
+// alloc allocates a chain of n clusters and returns its first cluster.
+func (v *Volume) alloc(n int) (uint32, error) {
+	var first, prev uint32
+	wrapped := false
+	for c := v.nextFree; n > 0; c++ {
+		if int(c) >= len(v.fat) {
+			if wrapped {
+				v.free(first)
+				return 0, ErrVolumeFull
+			}
+			c, wrapped = 2, true
+		}
+		if v.fat[c] != 0 {
+			continue
+		}
+		v.fat[c] = fatEOC
+		if prev != 0 {
+			v.fat[prev] = c
+		} else {
+			first = c
+		}
+		prev = c
+		v.nextFree = c + 1
+		n--
+	}
+	return first, nil
+}
+
+// free releases the chain starting at c.
+func (v *Volume) free(c uint32) {
+	for c >= 2 && int(c) < len(v.fat) {
+		next := v.fat[c]
+		v.fat[c] = 0
+		v.nextFree = min(v.nextFree, c)
+		c = next
+	}
+}
+
+// readChain reads the clusters of the chain starting at c.
+func (v *Volume) readChain(c uint32) ([]byte, error) {
+	clusters, err := v.chain(c)
+	if err != nil {
+		return nil, err
+	}
+	size := v.clusterSize()
+	data := make([]byte, len(clusters)*size)
+	for i, c := range clusters {
+		if _, err := v.dev.ReadAt(data[i*size:(i+1)*size], v.clusterOffset(c)); err != nil {
+			return nil, err
+		}
+	}
+	return data, nil
+}
+
+// writeData writes data to newly allocated clusters, padding the last
+// one with zeros, and returns the first cluster, or 0 if data is empty.
+func (v *Volume) writeData(data []byte) (uint32, error) {
+	if len(data) == 0 {
+		return 0, nil
+	}
+	size := v.clusterSize()
+	first, err := v.alloc((len(data) + size - 1) / size)
+	if err != nil {
+		return 0, err
+	}
+	clusters, err := v.chain(first)
+	if err != nil {
+		return 0, err
+	}
+	buf := make([]byte, size)
+	for i, c := range clusters {
+		clear(buf)
+		copy(buf, data[i*size:])
+		if _, err := v.dev.WriteAt(buf, v.clusterOffset(c)); err != nil {
+			return 0, err
+		}
+	}
+	return first, nil
+}
+
+// Flush writes the FATs and the free cluster count.
+func (v *Volume) Flush() error {
+	if v.readOnly {
+		return ErrReadOnly
+	}
+	bps := int64(v.bytesPerSector)
+	buf := make([]byte, v.fatSectors*bps)
+	free := uint32(0)
+	for i, n := range v.fat {
+		binary.LittleEndian.PutUint32(buf[i*4:], n)
+		if n == 0 && i >= 2 {
+			free++
+		}
+	}
+	for i := range int64(v.numFATs) {
+		if _, err := v.dev.WriteAt(buf, (v.reservedSectors+i*v.fatSectors)*bps); err != nil {
+			return fmt.Errorf("failed to write FAT: %w", err)
+		}
+	}
+
+	if v.fsInfoSector == 0 {
+		return nil
+	}
+	info := make([]byte, 8)
+	binary.LittleEndian.PutUint32(info[0:4], free)
+	binary.LittleEndian.PutUint32(info[4:8], v.nextFree)
+	sectors := []int64{v.fsInfoSector}
+	if v.backupBootSector != 0 {
+		sectors = append(sectors, v.backupBootSector+v.fsInfoSector)
+	}
+	for _, sector := range sectors {
+		if _, err := v.dev.WriteAt(info, sector*bps+488); err != nil {
+			return fmt.Errorf("failed to write FSInfo: %w", err)
+		}
+	}
+	return nil
+}
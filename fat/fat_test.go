@@ -0,0 +1,243 @@
+package fat
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/fs"
+	"strings"
+	"testing"
+)
+
+// memory is a Device backed by a byte slice.
+type memory []byte
+
+func (m memory) ReadAt(p []byte, off int64) (int, error) {
+	return copy(p, m[off:]), nil
+}
+
+func (m memory) WriteAt(p []byte, off int64) (int, error) {
+	return copy(m[off:], p), nil
+}
+
+func newVolume(t *testing.T, size int64) (memory, *Volume) {
+	t.Helper()
+	img := make(memory, size)
+	v, err := Format(img, size, FormatOptions{Label: "TEST", VolumeID: 1})
+	if err != nil {
+		t.Fatalf("Format() error = %v", err)
+	}
+	return img, v
+}
+
+func TestFormat(t *testing.T) {
+	img, v := newVolume(t, 40<<20)
+	if string(img[82:90]) != "FAT32   " || string(img[71:82]) != "TEST       " {
+		t.Errorf("boot sector = %q", img[3:90])
+	}
+	if !bytes.Equal(img[0:512], img[6*512:7*512]) {
+		t.Error("backup boot sector differs")
+	}
+	if v.sectorsPerCluster != 1 || len(v.fat)-2 < minClusters {
+		t.Errorf("%d clusters of %d sectors", len(v.fat)-2, v.sectorsPerCluster)
+	}
+
+	if _, err := Format(make(memory, 32<<20), 32<<20, FormatOptions{}); err == nil {
+		t.Error("Format() of 32 MiB succeeded, want too few clusters")
+	}
+	if _, err := Format(img, 40<<20, FormatOptions{Label: "TWELVE CHARS"}); err == nil {
+		t.Error("Format() with a 12 character label succeeded")
+	}
+}
+
+func TestVolume_Names(t *testing.T) {
+	img, v := newVolume(t, 40<<20)
+
+	// Enough long names to grow the directory past its first cluster.
+	var names []string
+	for i := range 40 {
+		names = append(names, fmt.Sprintf("dir/Long File Name %02d.dtbo", i))
+	}
+	names = append(names, "dir/UPPER.TXT", "dir/lower.txt", "dir/Mixed.txt", "dir/.hidden")
+	for _, name := range names {
+		if err := v.WriteFile(name, []byte(name)); err != nil {
+			t.Fatalf("WriteFile(%s) error = %v", name, err)
+		}
+	}
+	if err := v.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	v, err := Open(img)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	d, _, err := v.walk("DIR", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e := d.lookup("dir"); e == nil {
+		t.Fatal("dir not found")
+	} else if clusters, _ := v.chain(e.cluster); len(clusters) < 2 {
+		t.Errorf("directory has %d clusters, want it grown", len(clusters))
+	}
+
+	entries, err := v.ReadDir("Dir")
+	if err != nil {
+		t.Fatalf("ReadDir() error = %v", err)
+	}
+	if len(entries) != len(names) {
+		t.Fatalf("ReadDir() has %d entries, want %d", len(entries), len(names))
+	}
+	shorts := map[string]string{}
+	for _, e := range entries {
+		shorts[e.Name] = e.ShortName
+	}
+	for _, tt := range []struct{ name, short string }{
+		{"Long File Name 00.dtbo", "LONGFI~1.DTB"},
+		{"Long File Name 11.dtbo", "LONGF~12.DTB"},
+		{"UPPER.TXT", "UPPER.TXT"},
+		{"lower.txt", "LOWER.TXT"},
+		{"Mixed.txt", "MIXED~1.TXT"},
+		{".hidden", "HIDDEN~1"},
+	} {
+		if got, ok := shorts[tt.name]; !ok || got != tt.short {
+			t.Errorf("short name of %q = %q, want %q", tt.name, got, tt.short)
+		}
+	}
+	for _, name := range names {
+		got, err := v.ReadFile(strings.ToUpper(name))
+		if err != nil || string(got) != name {
+			t.Errorf("ReadFile(%s) = %q, %v", name, got, err)
+		}
+	}
+}
+
+func TestVolume_Update(t *testing.T) {
+	img, v := newVolume(t, 40<<20)
+	if err := v.WriteFiles(map[string][]byte{
+		"config.txt":              []byte("arm_64bit=1\n"),
+		"overlays/upstream.dtbo":  bytes.Repeat([]byte{1}, 3000),
+		"firmware/brcm/wifi.bin":  bytes.Repeat([]byte{2}, 1000),
+		"overlays/miniuart.dtbo":  nil,
+		"firmware/brcm/empty.txt": {},
+	}); err != nil {
+		t.Fatalf("WriteFiles() error = %v", err)
+	}
+	if err := v.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	free := func(v *Volume) int {
+		n := 0
+		for _, c := range v.fat[2:] {
+			if c == 0 {
+				n++
+			}
+		}
+		return n
+	}
+	before := free(v)
+
+	v, err := Open(img)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	if err := v.WriteFile("OVERLAYS/UPSTREAM.DTBO", []byte("smaller")); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	if err := v.Remove("firmware/brcm/wifi.bin"); err != nil {
+		t.Fatalf("Remove() error = %v", err)
+	}
+	if err := v.Remove("firmware/brcm"); err == nil {
+		t.Error("Remove() of a directory that is not empty succeeded")
+	}
+	if err := v.MkdirAll("a/b/c"); err != nil {
+		t.Fatalf("MkdirAll() error = %v", err)
+	}
+	if err := v.Remove("a/b/c"); err != nil {
+		t.Fatalf("Remove() of an empty directory error = %v", err)
+	}
+	if err := v.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	v, err = Open(img)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Removing and shrinking files frees 7 clusters, the two new
+	// directories take 2.
+	if got := free(v); got != before+5 {
+		t.Errorf("%d free clusters, want %d", got, before+5)
+	}
+	if got, err := v.ReadFile("overlays/upstream.dtbo"); err != nil || string(got) != "smaller" {
+		t.Errorf("ReadFile() = %q, %v", got, err)
+	}
+	if _, err := v.ReadFile("firmware/brcm/wifi.bin"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("ReadFile() of a removed file error = %v", err)
+	}
+	if got, err := v.ReadFile("firmware/brcm/empty.txt"); err != nil || len(got) != 0 {
+		t.Errorf("ReadFile() of an empty file = %q, %v", got, err)
+	}
+	entries, err := v.ReadDir("/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, e := range entries {
+		got = append(got, e.Name)
+	}
+	if want := "a config.txt firmware overlays"; strings.Join(got, " ") != want {
+		t.Errorf("ReadDir(/) = %v, want %s", got, want)
+	}
+
+	if err := v.WriteFile("config.txt/x", nil); err == nil {
+		t.Error("WriteFile() below a file succeeded")
+	}
+	if err := v.WriteFile("overlays", nil); err == nil {
+		t.Error("WriteFile() over a directory succeeded")
+	}
+	if err := v.WriteFile("a|b", nil); err == nil {
+		t.Error("WriteFile() with an invalid name succeeded")
+	}
+	if err := v.WriteFile("big", make([]byte, 41<<20)); !errors.Is(err, ErrVolumeFull) {
+		t.Errorf("WriteFile() of a file larger than the volume error = %v", err)
+	}
+	if _, err := Open(make(memory, 4096)); err == nil {
+		t.Error("Open() of a blank device succeeded")
+	}
+}
+
+func TestOpenReader(t *testing.T) {
+	img, v := newVolume(t, 40<<20)
+	if err := v.WriteFile("config.txt", []byte("arm_64bit=1\n")); err != nil {
+		t.Fatal(err)
+	}
+	if err := v.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	v, err := OpenReader(bytes.NewReader(img))
+	if err != nil {
+		t.Fatalf("OpenReader() error = %v", err)
+	}
+	if got, err := v.ReadFile("CONFIG.TXT"); err != nil || string(got) != "arm_64bit=1\n" {
+		t.Errorf("ReadFile() = %q, %v", got, err)
+	}
+	if err := v.WriteFile("config.txt", nil); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("WriteFile() error = %v, want ErrReadOnly", err)
+	}
+	if err := v.Flush(); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("Flush() error = %v, want ErrReadOnly", err)
+	}
+
+	// A boot sector claiming a FAT larger than the image is refused
+	// before the FAT is allocated.
+	huge := bytes.Clone(img[:1<<20])
+	binary.LittleEndian.PutUint32(huge[32:36], 0xffffffff)
+	binary.LittleEndian.PutUint32(huge[36:40], 0x00ffffff)
+	if _, err := OpenReader(bytes.NewReader(huge)); err == nil {
+		t.Error("OpenReader() of a FAT larger than the image succeeded")
+	}
+}
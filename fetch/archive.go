@@ -0,0 +1,130 @@
+package fetch
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/metal3-community/uefi-firmware-manager/types"
+)
+
+// FetchBundle downloads the firmware bundle of src: an OCI artifact, pulled
+// as with OCI.Pull, or a zip, tar or tar.gz archive, such as a release of
+// the Raspberry Pi UEFI firmware. ExtractSubdir of src selects a directory
+// of the bundle, whose files become the root of the returned bundle. A
+// bundle shipping a manifest.json at that root is verified against it.
+// Archives are verified against their signature as with Fetch; OCI
+// artifacts have none, so they are refused when signatures are in use.
+func (f *Fetcher) FetchBundle(ctx context.Context, src types.FirmwareSource) (*Bundle, error) {
+	var bundle *Bundle
+	if u, err := url.Parse(src.URL); err == nil && u.Scheme == "oci" {
+		oci, ok := f.source("oci").(*OCI)
+		if !ok {
+			return nil, fmt.Errorf("no OCI source to pull %s", src.URL)
+		}
+		if src.SignatureURL != "" || f.SignatureKey != nil {
+			return nil, fmt.Errorf("cannot verify %s: OCI sources have no detached signatures", src.URL)
+		}
+		if src.Timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, time.Duration(src.Timeout))
+			defer cancel()
+		}
+		if bundle, err = oci.Pull(ctx, u, src.Headers); err != nil {
+			return nil, err
+		}
+	} else {
+		data, err := f.Fetch(ctx, src)
+		if err != nil {
+			return nil, err
+		}
+		bundle = &Bundle{Files: map[string][]byte{}}
+		if err := extractArchive(bundle.Files, data); err != nil {
+			return nil, fmt.Errorf("failed to extract %s: %w", src.URL, err)
+		}
+	}
+
+	if src.ExtractSubdir != "" {
+		sub, err := bundleName(src.ExtractSubdir)
+		if err != nil {
+			return nil, err
+		}
+		files := map[string][]byte{}
+		for name, data := range bundle.Files {
+			if rest, ok := strings.CutPrefix(name, sub+"/"); ok {
+				files[rest] = data
+			}
+		}
+		if len(files) == 0 {
+			return nil, fmt.Errorf("%s has no directory %s", src.URL, sub)
+		}
+		bundle.Files = files
+	}
+
+	if bundle.HasManifest() || f.RequireManifest {
+		if err := bundle.Verify(); err != nil {
+			return nil, fmt.Errorf("%s: %w", src.URL, err)
+		}
+	}
+	return bundle, nil
+}
+
+// source returns the source registered for scheme.
+func (f *Fetcher) source(scheme string) Source {
+	sources := f.Sources
+	if sources == nil {
+		sources = DefaultSources()
+	}
+	return sources[scheme]
+}
+
+// extractArchive adds the regular files of the zip, tar or tar.gz archive
+// data to files.
+func extractArchive(files map[string][]byte, data []byte) error {
+	switch {
+	case bytes.HasPrefix(data, []byte("PK\x03\x04")), bytes.HasPrefix(data, []byte("PK\x05\x06")):
+		return extractZip(files, data)
+	case bytes.HasPrefix(data, []byte{0x1f, 0x8b}):
+		gr, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return err
+		}
+		defer gr.Close()
+		return extractTar(files, gr)
+	case len(data) > 262 && string(data[257:262]) == "ustar":
+		return extractTar(files, bytes.NewReader(data))
+	}
+	return fmt.Errorf("not a zip, tar or tar.gz archive")
+}
+
+// extractZip adds the regular files of the zip archive data to files.
+func extractZip(files map[string][]byte, data []byte) error {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return err
+	}
+	for _, zf := range zr.File {
+		if !zf.Mode().IsRegular() || strings.HasSuffix(zf.Name, "/") {
+			continue
+		}
+		name, err := bundleName(strings.ReplaceAll(zf.Name, "\\", "/"))
+		if err != nil {
+			return err
+		}
+		r, err := zf.Open()
+		if err != nil {
+			return err
+		}
+		files[name], err = readAll(r)
+		r.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %w", zf.Name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+package fetch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+
+	"github.com/metal3-community/uefi-firmware-manager/atomicfile"
+	"github.com/metal3-community/uefi-firmware-manager/edk2"
+	"github.com/metal3-community/uefi-firmware-manager/filelock"
+	"github.com/metal3-community/uefi-firmware-manager/types"
+)
+
+// FetchBoards downloads the firmware bundle of every board model of
+// sources in parallel and lays each out in dir/<model>, replacing the
+// previous one under a lock of the directory. A board whose bundle fails
+// to download keeps its previous firmware; the failures of all boards are
+// reported.
+func (f *Fetcher) FetchBoards(ctx context.Context, sources map[types.BoardModel]types.FirmwareSource, dir string) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create firmware directory: %w", err)
+	}
+
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs = map[types.BoardModel]error{}
+	)
+	for model, src := range sources {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := f.fetchBoard(ctx, model, src, dir)
+			if err != nil {
+				mu.Lock()
+				errs[model] = err
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	var joined []error
+	for _, model := range slices.Sorted(maps.Keys(errs)) {
+		joined = append(joined, fmt.Errorf("%s: %w", model, errs[model]))
+	}
+	return errors.Join(joined...)
+}
+
+// fetchBoard downloads the bundle of model from src and installs it in
+// dir/<model>.
+func (f *Fetcher) fetchBoard(ctx context.Context, model types.BoardModel, src types.FirmwareSource, dir string) error {
+	if _, err := types.ParseBoardModel(string(model)); err != nil {
+		return err
+	}
+	bundle, err := f.FetchBundle(ctx, src)
+	if err != nil {
+		return err
+	}
+	if _, ok := bundle.Files[edk2.FirmwareFileName]; !ok {
+		return fmt.Errorf("bundle has no %s", edk2.FirmwareFileName)
+	}
+
+	tmp, err := os.MkdirTemp(dir, "."+string(model)+"-*")
+	if err != nil {
+		return fmt.Errorf("failed to create firmware directory: %w", err)
+	}
+	defer os.RemoveAll(tmp)
+	for name, data := range bundle.Files {
+		path := filepath.Join(tmp, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return fmt.Errorf("failed to create firmware directory: %w", err)
+		}
+		if err := atomicfile.WriteFile(path, data, 0o644); err != nil {
+			return fmt.Errorf("failed to write firmware file: %w", err)
+		}
+	}
+
+	boardDir := filepath.Join(dir, string(model))
+	unlock, err := filelock.Lock(boardDir, filelock.DefaultTimeout)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	return swapDir(tmp, boardDir)
+}
+
+// swapDir replaces the directory dst with src. The previous dst is moved
+// aside first and restored if src cannot be moved in, so that dst is
+// never left missing.
+func swapDir(src, dst string) error {
+	old := ""
+	if _, err := os.Stat(dst); err == nil {
+		aside, err := os.MkdirTemp(filepath.Dir(dst), "."+filepath.Base(dst)+"-old-*")
+		if err != nil {
+			return fmt.Errorf("failed to move previous firmware aside: %w", err)
+		}
+		old = filepath.Join(aside, filepath.Base(dst))
+		defer os.RemoveAll(aside)
+		if err := os.Rename(dst, old); err != nil {
+			return fmt.Errorf("failed to move previous firmware aside: %w", err)
+		}
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	if err := os.Rename(src, dst); err != nil {
+		if old != "" {
+			if rerr := os.Rename(old, dst); rerr != nil {
+				err = errors.Join(err, fmt.Errorf("failed to restore previous firmware: %w", rerr))
+			}
+		}
+		return fmt.Errorf("failed to install firmware: %w", err)
+	}
+	return nil
+}
+
+// SelectBoard returns the directory of the firmware of model laid out by
+// FetchBoards in dir, or that of its fallback model if model has none.
+// Servers use it to pick the base firmware of a host. A missing firmware
+// is reported with an error matching fs.ErrNotExist.
+func SelectBoard(dir string, model types.BoardModel) (string, error) {
+	for m := model; m != ""; m = m.Fallback() {
+		boardDir := filepath.Join(dir, string(m))
+		if _, err := os.Stat(filepath.Join(boardDir, edk2.FirmwareFileName)); err == nil {
+			return boardDir, nil
+		}
+	}
+	return "", fmt.Errorf("no firmware for board %s: %w", model, fs.ErrNotExist)
+}
@@ -0,0 +1,214 @@
+package fetch
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/metal3-community/uefi-firmware-manager/types"
+	"github.com/metal3-community/uefi-firmware-manager/updatetest"
+)
+
+func TestFetcher_FetchBundle(t *testing.T) {
+	server := updatetest.NewServer(t)
+	files := map[string][]byte{
+		"RPI_EFI.fd":         []byte("firmware"),
+		"overlays/uart.dtbo": []byte("dtbo"),
+		"release/RPI_EFI.fd": []byte("nested"),
+		"release/config.txt": []byte("arm_64bit=1\n"),
+	}
+	var f Fetcher
+	for _, src := range []types.FirmwareSource{server.Zip("fw.zip", files), server.TarGz("fw.tar.gz", files)} {
+		bundle, err := f.FetchBundle(context.Background(), src)
+		if err != nil {
+			t.Fatalf("FetchBundle(%s) error = %v", src.URL, err)
+		}
+		if len(bundle.Files) != len(files) || string(bundle.Files["overlays/uart.dtbo"]) != "dtbo" {
+			t.Errorf("FetchBundle(%s) files = %q", src.URL, bundle.Files)
+		}
+
+		src.ExtractSubdir = "release"
+		bundle, err = f.FetchBundle(context.Background(), src)
+		if err != nil {
+			t.Fatalf("FetchBundle(%s) of a subdirectory error = %v", src.URL, err)
+		}
+		if len(bundle.Files) != 2 || string(bundle.Files["RPI_EFI.fd"]) != "nested" {
+			t.Errorf("FetchBundle(%s) of a subdirectory files = %q", src.URL, bundle.Files)
+		}
+	}
+
+	if _, err := f.FetchBundle(context.Background(), server.File("RPI_EFI.fd", []byte("firmware"))); err == nil {
+		t.Error("FetchBundle() of a plain file succeeded")
+	}
+}
+
+func TestFetcher_TooLarge(t *testing.T) {
+	limit := maxFileSize
+	maxFileSize = 4096
+	t.Cleanup(func() { maxFileSize = limit })
+
+	server := updatetest.NewServer(t)
+	large := make([]byte, maxFileSize+1)
+	var f Fetcher
+	if _, err := f.Fetch(context.Background(), server.File("RPI_EFI.fd", large)); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("Fetch() of a large file error = %v", err)
+	}
+	// The archives compress below the limit, their files do not.
+	files := map[string][]byte{"RPI_EFI.fd": large}
+	for _, src := range []types.FirmwareSource{server.Zip("large.zip", files), server.TarGz("large.tar.gz", files)} {
+		if _, err := f.FetchBundle(context.Background(), src); !errors.Is(err, ErrTooLarge) {
+			t.Errorf("FetchBundle(%s) of a large file error = %v", src.URL, err)
+		}
+	}
+	if _, err := f.FetchBundle(context.Background(), server.Zip("small.zip", map[string][]byte{"RPI_EFI.fd": large[:maxFileSize]})); err != nil {
+		t.Errorf("FetchBundle() at the limit error = %v", err)
+	}
+}
+
+func TestFetcher_FetchBundleManifest(t *testing.T) {
+	server := updatetest.NewServer(t)
+	files := map[string][]byte{
+		"RPI_EFI.fd":         []byte("firmware"),
+		"overlays/uart.dtbo": []byte("dtbo"),
+	}
+	manifest, err := json.Marshal(types.NewBundleManifest(files))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Release archives ship the bundle in a directory.
+	withManifest := map[string][]byte{"release/" + BundleManifestName: manifest}
+	tampered := map[string][]byte{"release/" + BundleManifestName: manifest}
+	for name, data := range files {
+		withManifest["release/"+name] = data
+		tampered["release/"+name] = data
+	}
+	tampered["release/RPI_EFI.fd"] = []byte("tampered")
+
+	var f Fetcher
+	for _, src := range []types.FirmwareSource{server.Zip("ok.zip", withManifest), server.TarGz("ok.tar.gz", withManifest)} {
+		src.ExtractSubdir = "release"
+		if _, err := f.FetchBundle(context.Background(), src); err != nil {
+			t.Errorf("FetchBundle(%s) of a bundle matching its manifest error = %v", src.URL, err)
+		}
+	}
+	for _, src := range []types.FirmwareSource{server.Zip("bad.zip", tampered), server.TarGz("bad.tar.gz", tampered)} {
+		src.ExtractSubdir = "release"
+		if _, err := f.FetchBundle(context.Background(), src); !errors.Is(err, types.ErrBundleMismatch) {
+			t.Errorf("FetchBundle(%s) of a tampered bundle error = %v", src.URL, err)
+		}
+	}
+
+	plain := server.Zip("plain.zip", files)
+	if _, err := f.FetchBundle(context.Background(), plain); err != nil {
+		t.Errorf("FetchBundle() of a bundle without manifest error = %v", err)
+	}
+	f.RequireManifest = true
+	if _, err := f.FetchBundle(context.Background(), plain); err == nil {
+		t.Error("FetchBundle() of a bundle without manifest with RequireManifest succeeded")
+	}
+}
+
+func TestFetcher_FetchBoards(t *testing.T) {
+	server := updatetest.NewServer(t)
+	dir := t.TempDir()
+	stale := filepath.Join(dir, "rpi4", "stale.dtbo")
+	if err := os.MkdirAll(filepath.Dir(stale), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(stale, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var f Fetcher
+	err := f.FetchBoards(context.Background(), map[types.BoardModel]types.FirmwareSource{
+		types.BoardRPi4: server.Zip("rpi4.zip", map[string][]byte{
+			"RPI_EFI.fd":                 []byte("rpi4"),
+			"overlays/upstream-pi4.dtbo": []byte("dtbo"),
+		}),
+		types.BoardRPi5: server.TarGz("rpi5.tar.gz", map[string][]byte{"RPI_EFI.fd": []byte("rpi5")}),
+		types.BoardCM4:  server.Zip("cm4.zip", map[string][]byte{"config.txt": []byte("no firmware")}),
+		"rpi9":          server.Zip("rpi9.zip", map[string][]byte{"RPI_EFI.fd": []byte("rpi9")}),
+	}, dir)
+	if err == nil || !strings.Contains(err.Error(), "cm4: bundle has no RPI_EFI.fd") || !strings.Contains(err.Error(), "rpi9") {
+		t.Fatalf("FetchBoards() error = %v, want the cm4 and rpi9 failures", err)
+	}
+
+	for name, want := range map[string]string{
+		"rpi4/RPI_EFI.fd":                 "rpi4",
+		"rpi4/overlays/upstream-pi4.dtbo": "dtbo",
+		"rpi5/RPI_EFI.fd":                 "rpi5",
+	} {
+		if data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(name))); err != nil || string(data) != want {
+			t.Errorf("%s = %q, %v; want %q", name, data, err, want)
+		}
+	}
+	if _, err := os.Stat(stale); !errors.Is(err, fs.ErrNotExist) {
+		t.Error("FetchBoards() kept a file of the previous rpi4 firmware")
+	}
+	entries, _ := os.ReadDir(dir)
+	for _, entry := range entries {
+		temp := strings.HasPrefix(entry.Name(), ".") && !strings.HasSuffix(entry.Name(), ".lock")
+		if temp || entry.Name() == "cm4" || entry.Name() == "rpi9" {
+			t.Errorf("FetchBoards() left %s behind", entry.Name())
+		}
+	}
+
+	for model, want := range map[types.BoardModel]string{
+		types.BoardRPi4:   "rpi4",
+		types.BoardCM4:    "rpi4",
+		types.BoardRPi400: "rpi4",
+		types.BoardRPi5:   "rpi5",
+	} {
+		got, err := SelectBoard(dir, model)
+		if err != nil || got != filepath.Join(dir, want) {
+			t.Errorf("SelectBoard(%s) = %s, %v; want the %s firmware", model, got, err, want)
+		}
+	}
+	if _, err := SelectBoard(t.TempDir(), types.BoardRPi5); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("SelectBoard() of a missing board error = %v, want fs.ErrNotExist", err)
+	}
+}
+
+func TestSwapDir(t *testing.T) {
+	dir := t.TempDir()
+	dst := filepath.Join(dir, "rpi4")
+	write := func(path, data string) {
+		t.Helper()
+		if err := os.MkdirAll(path, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(path, "RPI_EFI.fd"), []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	firmware := func() string {
+		data, _ := os.ReadFile(filepath.Join(dst, "RPI_EFI.fd"))
+		return string(data)
+	}
+
+	write(filepath.Join(dir, "new"), "v1")
+	if err := swapDir(filepath.Join(dir, "new"), dst); err != nil || firmware() != "v1" {
+		t.Fatalf("swapDir() into a missing directory = %v, firmware %q", err, firmware())
+	}
+	write(filepath.Join(dir, "new"), "v2")
+	if err := swapDir(filepath.Join(dir, "new"), dst); err != nil || firmware() != "v2" {
+		t.Fatalf("swapDir() = %v, firmware %q", err, firmware())
+	}
+
+	// A failed install keeps the previous firmware.
+	if err := swapDir(filepath.Join(dir, "missing"), dst); err == nil {
+		t.Error("swapDir() of a missing directory succeeded")
+	}
+	if firmware() != "v2" {
+		t.Errorf("failed swapDir() left firmware %q", firmware())
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Errorf("swapDir() left %d entries in %s", len(entries), dir)
+	}
+}
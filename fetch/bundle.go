@@ -0,0 +1,127 @@
+package fetch
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// Layer media types extracted by Pull.
+const (
+	ociLayerTarMediaType        = "application/vnd.oci.image.layer.v1.tar"
+	ociLayerTarGzipMediaType    = "application/vnd.oci.image.layer.v1.tar+gzip"
+	dockerLayerTarGzipMediaType = "application/vnd.docker.image.rootfs.diff.tar.gzip"
+)
+
+// Bundle is a firmware bundle pulled from an OCI registry.
+type Bundle struct {
+	// Digest is the digest of the manifest of the bundle. Pull
+	// oci://<registry>/<repository>@<Digest> to get the same bundle again.
+	Digest string
+	// Files maps the slash separated names of the files of the bundle to
+	// their contents, e.g. "RPI_EFI.fd" or "overlays/miniuart-bt.dtbo".
+	Files map[string][]byte
+}
+
+// Pull downloads the firmware bundle published as the OCI artifact of u.
+// Tar layers, possibly gzip compressed, are extracted; other layers are
+// files named by their title annotation, as "oras push" sets it. Later
+// layers replace the files of earlier ones. Every layer is verified
+// against its digest, and the manifest too if u is pinned to a digest. A
+// bundle shipping a manifest.json is verified against it.
+func (o *OCI) Pull(ctx context.Context, u *url.URL, headers map[string]string) (*Bundle, error) {
+	base, ref, err := o.repository(u)
+	if err != nil {
+		return nil, err
+	}
+	c := &ociClient{oci: o, ctx: ctx, headers: headers}
+
+	manifest, digest, err := c.manifest(base, ref)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", u, err)
+	}
+	if len(manifest.Layers) == 0 {
+		return nil, fmt.Errorf("%s: artifact has no layers", u)
+	}
+
+	bundle := &Bundle{Digest: digest, Files: map[string][]byte{}}
+	for _, layer := range manifest.Layers {
+		data, err := c.blob(base, layer)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", u, err)
+		}
+		if err := extractLayer(bundle.Files, layer, data); err != nil {
+			return nil, fmt.Errorf("%s: layer %s: %w", u, layer.Digest, err)
+		}
+	}
+
+	if bundle.HasManifest() || o.RequireManifest {
+		if err := bundle.Verify(); err != nil {
+			return nil, fmt.Errorf("%s: %w", u, err)
+		}
+	}
+	return bundle, nil
+}
+
+// extractLayer adds the files of layer, holding data, to files.
+func extractLayer(files map[string][]byte, layer ociDescriptor, data []byte) error {
+	switch layer.MediaType {
+	case ociLayerTarMediaType:
+		return extractTar(files, bytes.NewReader(data))
+	case ociLayerTarGzipMediaType, dockerLayerTarGzipMediaType:
+		gr, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return err
+		}
+		defer gr.Close()
+		return extractTar(files, gr)
+	}
+
+	name, err := bundleName(layer.Annotations[ociTitleAnnotation])
+	if err != nil {
+		return fmt.Errorf("%s layer: %w", layer.MediaType, err)
+	}
+	files[name] = data
+	return nil
+}
+
+// extractTar adds the regular files of the tar archive r to files.
+func extractTar(files map[string][]byte, r io.Reader) error {
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		name, err := bundleName(hdr.Name)
+		if err != nil {
+			return err
+		}
+		if files[name], err = readAll(tr); err != nil {
+			return fmt.Errorf("%s: %w", hdr.Name, err)
+		}
+	}
+}
+
+// bundleName returns the cleaned name of a bundle file, refusing names
+// that would escape the bundle.
+func bundleName(name string) (string, error) {
+	clean := path.Clean(strings.TrimPrefix(name, "./"))
+	if name == "" || clean == "." || path.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, "../") {
+		return "", fmt.Errorf("invalid file name %q", name)
+	}
+	return clean, nil
+}
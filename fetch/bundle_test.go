@@ -0,0 +1,158 @@
+package fetch
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/metal3-community/uefi-firmware-manager/types"
+	"github.com/metal3-community/uefi-firmware-manager/updatetest"
+)
+
+func TestOCI_Pull(t *testing.T) {
+	server, digest := fakeRegistry(t,
+		testLayer{ociLayerTarGzipMediaType, "", updatetest.TarGzArchive(map[string][]byte{
+			"config.txt":             []byte("old\n"),
+			"./overlays/uart.dtbo":   []byte("dtbo"),
+			"firmware/brcm/wifi.bin": []byte("wifi"),
+		})},
+		testLayer{"application/octet-stream", "RPI_EFI.fd", []byte("firmware")},
+		testLayer{"text/plain", "config.txt", []byte("arm_64bit=1\n")},
+	)
+	host := strings.TrimPrefix(server.URL, "http://")
+	o := &OCI{PlainHTTP: true, Username: "robot", Password: "secret"}
+
+	u, _ := url.Parse("oci://" + host + "/firmware/rpi4:v1.38")
+	bundle, err := o.Pull(context.Background(), u, nil)
+	if err != nil {
+		t.Fatalf("Pull() error = %v", err)
+	}
+	if bundle.Digest != digest {
+		t.Errorf("Pull() digest = %s, want %s", bundle.Digest, digest)
+	}
+	want := map[string]string{
+		"RPI_EFI.fd":             "firmware",
+		"config.txt":             "arm_64bit=1\n",
+		"overlays/uart.dtbo":     "dtbo",
+		"firmware/brcm/wifi.bin": "wifi",
+	}
+	if len(bundle.Files) != len(want) {
+		t.Errorf("Pull() files = %d, want %d", len(bundle.Files), len(want))
+	}
+	for name, data := range want {
+		if string(bundle.Files[name]) != data {
+			t.Errorf("file %s = %q, want %q", name, bundle.Files[name], data)
+		}
+	}
+
+	o.RequireDigest = true
+	if _, err := o.Pull(context.Background(), u, nil); err == nil {
+		t.Error("Pull() of a tag with RequireDigest succeeded")
+	}
+	pinned, _ := url.Parse("oci://" + host + "/firmware/rpi4@" + bundle.Digest)
+	if again, err := o.Pull(context.Background(), pinned, nil); err != nil || again.Digest != bundle.Digest {
+		t.Errorf("Pull() of the pinned digest = %v, %v", again, err)
+	}
+	other, _ := url.Parse("oci://" + host + "/firmware/rpi4@" + checksum([]byte("other")))
+	if _, err := o.Pull(context.Background(), other, nil); err == nil {
+		t.Error("Pull() of an unknown digest succeeded")
+	}
+}
+
+func TestExtractLayer(t *testing.T) {
+	for _, tt := range []struct {
+		name  string
+		layer ociDescriptor
+		data  []byte
+	}{
+		{"untitled file", ociDescriptor{MediaType: "application/octet-stream"}, []byte("x")},
+		{"escaping title", ociDescriptor{
+			MediaType:   "application/octet-stream",
+			Annotations: map[string]string{ociTitleAnnotation: "../RPI_EFI.fd"},
+		}, []byte("x")},
+		{"escaping tar", ociDescriptor{MediaType: ociLayerTarGzipMediaType},
+			updatetest.TarGzArchive(map[string][]byte{"../../etc/passwd": []byte("x")})},
+		{"not gzip", ociDescriptor{MediaType: ociLayerTarGzipMediaType}, []byte("plain")},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := extractLayer(map[string][]byte{}, tt.layer, tt.data); err == nil {
+				t.Error("extractLayer() succeeded")
+			}
+		})
+	}
+}
+
+func TestOCI_PullManifest(t *testing.T) {
+	files := map[string][]byte{
+		"RPI_EFI.fd":         []byte("firmware"),
+		"overlays/uart.dtbo": []byte("dtbo"),
+	}
+	manifest, err := json.Marshal(types.NewBundleManifest(files))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pull := func(o *OCI, layers ...testLayer) error {
+		t.Helper()
+		server, _ := fakeRegistry(t, layers...)
+		u, _ := url.Parse("oci://" + strings.TrimPrefix(server.URL, "http://") + "/firmware/rpi4:v1.38")
+		o.PlainHTTP, o.Username, o.Password = true, "robot", "secret"
+		_, err := o.Pull(context.Background(), u, nil)
+		return err
+	}
+
+	bundle := testLayer{ociLayerTarGzipMediaType, "", updatetest.TarGzArchive(files)}
+	withManifest := testLayer{"application/json", BundleManifestName, manifest}
+	if err := pull(&OCI{RequireManifest: true}, bundle, withManifest); err != nil {
+		t.Errorf("Pull() of a bundle matching its manifest error = %v", err)
+	}
+	extra := testLayer{"text/plain", "config.txt", []byte("extra")}
+	if err := pull(&OCI{}, bundle, withManifest, extra); !errors.Is(err, types.ErrBundleMismatch) {
+		t.Errorf("Pull() of a bundle with an extra file error = %v", err)
+	}
+	if err := pull(&OCI{}, bundle); err != nil {
+		t.Errorf("Pull() of a bundle without manifest error = %v", err)
+	}
+	if err := pull(&OCI{RequireManifest: true}, bundle); err == nil {
+		t.Error("Pull() of a bundle without manifest with RequireManifest succeeded")
+	}
+}
+
+func TestVerifyBundleDir(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string][]byte{
+		"RPI_EFI.fd":         []byte("firmware"),
+		"overlays/uart.dtbo": []byte("dtbo"),
+	}
+	manifest, err := json.Marshal(types.NewBundleManifest(files))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files[BundleManifestName] = manifest
+	for name, data := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := VerifyBundleDir(dir); err != nil {
+		t.Fatalf("VerifyBundleDir() error = %v", err)
+	}
+
+	if err := os.Remove(filepath.Join(dir, "overlays", "uart.dtbo")); err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyBundleDir(dir); !errors.Is(err, types.ErrBundleMismatch) || !strings.Contains(err.Error(), "overlays/uart.dtbo is missing") {
+		t.Errorf("VerifyBundleDir() error = %v, want the missing file", err)
+	}
+	if err := VerifyBundleDir(t.TempDir()); err == nil {
+		t.Error("VerifyBundleDir() without manifest succeeded")
+	}
+}
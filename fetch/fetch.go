@@ -0,0 +1,227 @@
+// Package fetch downloads firmware releases described by a
+// types.FirmwareSource over pluggable transports, so that air-gapped sites
+// can install from local mirrors, S3 buckets or OCI registries.
+package fetch
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/metal3-community/uefi-firmware-manager/types"
+)
+
+// maxFileSize bounds the size of a download, and of every file extracted
+// from a bundle, so that a broken or hostile source cannot exhaust memory.
+// It is a variable for tests.
+var maxFileSize int64 = 256 << 20
+
+// ErrTooLarge is returned for a download, or a file of a bundle, larger
+// than the files this package reads.
+var ErrTooLarge = errors.New("file too large")
+
+// Source opens files at URLs of the schemes it is registered for.
+type Source interface {
+	// Open returns the contents of the file at u. headers are added to
+	// the requests of sources that support them. A missing file is
+	// reported with an error matching fs.ErrNotExist.
+	Open(ctx context.Context, u *url.URL, headers map[string]string) (io.ReadCloser, error)
+}
+
+// Fetcher downloads firmware sources through the Source registered for
+// the scheme of their URL.
+type Fetcher struct {
+	// Sources maps URL schemes to their source. Nil uses DefaultSources.
+	Sources map[string]Source
+	// RequireManifest makes FetchBundle refuse bundles without a
+	// manifest.json, whatever their source.
+	RequireManifest bool
+	// SignatureKey verifies the downloads of Fetch against the detached
+	// signature, checked with types.VerifyImage, at the SignatureURL of
+	// their source. With a key every source needs a signature, and a
+	// source with a signature cannot be fetched without a key.
+	SignatureKey crypto.PublicKey
+}
+
+// DefaultSources returns the sources of the http, https, file and oci
+// schemes. S3 needs credentials, so it is not included.
+func DefaultSources() map[string]Source {
+	return map[string]Source{
+		"http":  &HTTP{},
+		"https": &HTTP{},
+		"file":  File{},
+		"oci":   &OCI{},
+	}
+}
+
+// Open returns the contents of the file at rawURL. A URL without a scheme
+// is a local path.
+func (f *Fetcher) Open(ctx context.Context, rawURL string, headers map[string]string) (io.ReadCloser, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Scheme == "" || filepath.VolumeName(rawURL) != "" {
+		u = &url.URL{Scheme: "file", Path: rawURL}
+	}
+
+	src := f.source(u.Scheme)
+	if src == nil {
+		return nil, fmt.Errorf("no source for URL scheme %q", u.Scheme)
+	}
+	return src.Open(ctx, u, headers)
+}
+
+// Fetch downloads the file of src within its timeout and verifies its
+// checksum and signature.
+func (f *Fetcher) Fetch(ctx context.Context, src types.FirmwareSource) ([]byte, error) {
+	if src.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(src.Timeout))
+		defer cancel()
+	}
+
+	data, err := f.read(ctx, src.URL, src.Headers)
+	if err != nil {
+		return nil, err
+	}
+	if err := src.VerifyChecksum(data); err != nil {
+		return nil, fmt.Errorf("failed to verify %s: %w", src.URL, err)
+	}
+	if err := f.verifySignature(ctx, src, data); err != nil {
+		return nil, fmt.Errorf("failed to verify %s: %w", src.URL, err)
+	}
+	return data, nil
+}
+
+// verifySignature checks data, downloaded from src, against the detached
+// signature at the SignatureURL of src with the SignatureKey.
+func (f *Fetcher) verifySignature(ctx context.Context, src types.FirmwareSource, data []byte) error {
+	switch {
+	case src.SignatureURL == "" && f.SignatureKey == nil:
+		return nil
+	case src.SignatureURL == "":
+		return fmt.Errorf("%w: source has no signature URL", types.ErrInvalidSignature)
+	case f.SignatureKey == nil:
+		return fmt.Errorf("no key to verify the signature at %s", src.SignatureURL)
+	}
+	sig, err := f.read(ctx, src.SignatureURL, src.Headers)
+	if err != nil {
+		return err
+	}
+	return types.VerifyImage(bytes.NewReader(data), sig, f.SignatureKey)
+}
+
+// FetchSignature downloads the detached signature of src, or returns nil
+// if it has none.
+func (f *Fetcher) FetchSignature(ctx context.Context, src types.FirmwareSource) ([]byte, error) {
+	if src.SignatureURL == "" {
+		return nil, nil
+	}
+	if src.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(src.Timeout))
+		defer cancel()
+	}
+	return f.read(ctx, src.SignatureURL, src.Headers)
+}
+
+// read returns the contents of the file at rawURL.
+func (f *Fetcher) read(ctx context.Context, rawURL string, headers map[string]string) ([]byte, error) {
+	r, err := f.Open(ctx, rawURL, headers)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", rawURL, err)
+	}
+	defer r.Close()
+	data, err := readAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", rawURL, err)
+	}
+	return data, nil
+}
+
+// readAll reads r like io.ReadAll, up to maxFileSize bytes.
+func readAll(r io.Reader) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(r, maxFileSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > maxFileSize {
+		return nil, fmt.Errorf("%w: over %d bytes", ErrTooLarge, maxFileSize)
+	}
+	return data, nil
+}
+
+// HTTP is the Source of http and https URLs.
+type HTTP struct {
+	// Client sends the requests; nil uses http.DefaultClient.
+	Client *http.Client
+}
+
+// Open sends a GET request for u with headers.
+func (h *HTTP) Open(ctx context.Context, u *url.URL, headers map[string]string) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	resp, err := httpClient(h.Client).Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if err := responseError(resp); err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// httpClient returns client, or http.DefaultClient if it is nil.
+func httpClient(client *http.Client) *http.Client {
+	if client == nil {
+		return http.DefaultClient
+	}
+	return client
+}
+
+// responseError returns an error for a response that is not a success.
+func responseError(resp *http.Response) error {
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return fmt.Errorf("%s: %w", resp.Request.URL, fs.ErrNotExist)
+	case resp.StatusCode < 200 || resp.StatusCode > 299:
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s: %s: %s", resp.Request.URL, resp.Status, bytes.TrimSpace(body))
+	}
+	return nil
+}
+
+// File is the Source of file URLs and local paths.
+type File struct {
+	// Root, if set, is the directory relative paths are resolved in.
+	Root string
+}
+
+// Open opens the local file at u.
+func (f File) Open(_ context.Context, u *url.URL, _ map[string]string) (io.ReadCloser, error) {
+	path := u.Path
+	if u.Opaque != "" {
+		path = u.Opaque
+	}
+	if u.Host != "" && u.Host != "localhost" {
+		return nil, fmt.Errorf("file URL %s is not local", u)
+	}
+	path = filepath.FromSlash(path)
+	if f.Root != "" && !filepath.IsAbs(path) {
+		path = filepath.Join(f.Root, path)
+	}
+	return os.Open(path)
+}
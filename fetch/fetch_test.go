@@ -0,0 +1,270 @@
+package fetch
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/metal3-community/uefi-firmware-manager/manager"
+	"github.com/metal3-community/uefi-firmware-manager/types"
+	"github.com/metal3-community/uefi-firmware-manager/updatetest"
+)
+
+func checksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+func TestFetcher_HTTP(t *testing.T) {
+	server := updatetest.NewServer(t)
+	data := []byte("firmware")
+	src := server.Serve("RPI_EFI.fd", data, updatetest.Behavior{})
+	src.Headers = map[string]string{"Authorization": "Bearer t0ken"}
+
+	var f Fetcher
+	got, err := f.Fetch(context.Background(), src)
+	if err != nil || string(got) != "firmware" {
+		t.Fatalf("Fetch() = %q, %v", got, err)
+	}
+	if auth := server.Requests("RPI_EFI.fd")[0].Header.Get("Authorization"); auth != "Bearer t0ken" {
+		t.Errorf("Authorization header = %q", auth)
+	}
+	corrupt := server.Serve("corrupt.fd", data, updatetest.Behavior{Corrupt: true})
+	if _, err := f.Fetch(context.Background(), corrupt); !errors.Is(err, types.ErrChecksumMismatch) {
+		t.Errorf("Fetch() of corrupt data error = %v", err)
+	}
+	missing := types.FirmwareSource{URL: server.URL + "/missing.fd"}
+	if _, err := f.Fetch(context.Background(), missing); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Fetch() of a missing file error = %v", err)
+	}
+	slow := server.Serve("slow.fd", data, updatetest.Behavior{ChunkSize: 1, Delay: 50 * time.Millisecond})
+	slow.Timeout = types.Duration(100 * time.Millisecond)
+	if _, err := f.Fetch(context.Background(), slow); err == nil {
+		t.Error("Fetch() past the timeout succeeded")
+	}
+}
+
+func TestFetcher_Signature(t *testing.T) {
+	pub, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := []byte("firmware")
+	sig, err := types.SignImage(bytes.NewReader(data), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := updatetest.NewServer(t)
+	signed := server.Serve("RPI_EFI.fd", data, updatetest.Behavior{Signature: sig})
+	forged := server.Serve("forged.fd", data, updatetest.Behavior{Signature: []byte("sig")})
+	unsigned := server.Serve("unsigned.fd", data, updatetest.Behavior{})
+
+	f := Fetcher{SignatureKey: pub}
+	if got, err := f.Fetch(context.Background(), signed); err != nil || string(got) != "firmware" {
+		t.Errorf("Fetch() = %q, %v", got, err)
+	}
+	if got, err := f.FetchSignature(context.Background(), signed); err != nil || !bytes.Equal(got, sig) {
+		t.Errorf("FetchSignature() = %q, %v", got, err)
+	}
+	if _, err := f.Fetch(context.Background(), forged); !errors.Is(err, types.ErrInvalidSignature) {
+		t.Errorf("Fetch() of a forged signature error = %v", err)
+	}
+	if _, err := f.Fetch(context.Background(), unsigned); !errors.Is(err, types.ErrInvalidSignature) {
+		t.Errorf("Fetch() of an unsigned source error = %v", err)
+	}
+
+	var keyless Fetcher
+	if _, err := keyless.Fetch(context.Background(), signed); err == nil {
+		t.Error("Fetch() of a signed source without a key succeeded")
+	}
+	if _, err := keyless.FetchBundle(context.Background(), types.FirmwareSource{URL: "oci://registry.example/firmware:v1", SignatureURL: signed.SignatureURL}); err == nil {
+		t.Error("FetchBundle() of a signed OCI source succeeded")
+	}
+}
+
+func TestFetcher_File(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "RPI_EFI.fd")
+	if err := os.WriteFile(path, []byte("local"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var f Fetcher
+	for _, rawURL := range []string{path, "file://" + filepath.ToSlash(path)} {
+		got, err := f.Fetch(context.Background(), types.FirmwareSource{URL: rawURL, Checksum: checksum([]byte("local"))})
+		if err != nil || string(got) != "local" {
+			t.Errorf("Fetch(%s) = %q, %v", rawURL, got, err)
+		}
+	}
+	rooted := Fetcher{Sources: map[string]Source{"file": File{Root: dir}}}
+	if got, err := rooted.Fetch(context.Background(), types.FirmwareSource{URL: "RPI_EFI.fd"}); err != nil || string(got) != "local" {
+		t.Errorf("Fetch() relative to the root = %q, %v", got, err)
+	}
+	if _, err := f.Fetch(context.Background(), types.FirmwareSource{URL: "file://host/RPI_EFI.fd"}); err == nil {
+		t.Error("Fetch() of a remote file URL succeeded")
+	}
+	if _, err := f.Fetch(context.Background(), types.FirmwareSource{URL: "s3://bucket/key"}); err == nil {
+		t.Error("Fetch() of an unregistered scheme succeeded")
+	}
+}
+
+func TestS3(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=minio/") {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		if r.URL.Path != "/mirror/rpi4/RPI_EFI.fd" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = io.WriteString(w, "from s3")
+	}))
+	defer server.Close()
+
+	f := Fetcher{Sources: map[string]Source{"s3": &S3{Storage: manager.S3Storage{
+		Endpoint:        server.URL,
+		Prefix:          "ignored/",
+		AccessKeyID:     "minio",
+		SecretAccessKey: "minio123",
+	}}}}
+	got, err := f.Fetch(context.Background(), types.FirmwareSource{URL: "s3://mirror/rpi4/RPI_EFI.fd"})
+	if err != nil || string(got) != "from s3" {
+		t.Errorf("Fetch() = %q, %v", got, err)
+	}
+	if _, err := f.Fetch(context.Background(), types.FirmwareSource{URL: "s3://mirror/missing"}); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Fetch() of a missing object error = %v", err)
+	}
+	if _, err := f.Fetch(context.Background(), types.FirmwareSource{URL: "s3://mirror"}); err == nil {
+		t.Error("Fetch() without a key succeeded")
+	}
+}
+
+// testLayer is a layer served by fakeRegistry.
+type testLayer struct {
+	mediaType string
+	title     string
+	data      []byte
+}
+
+// fakeRegistry serves the artifact firmware/rpi4:v1.38 with layers,
+// requiring a token. It returns the server and the manifest digest.
+func fakeRegistry(t *testing.T, layers ...testLayer) (*httptest.Server, string) {
+	t.Helper()
+	blobs := map[string][]byte{}
+	var manifest ociManifest
+	for _, layer := range layers {
+		digest := checksum(layer.data)
+		blobs[digest] = layer.data
+		d := ociDescriptor{MediaType: layer.mediaType, Digest: digest, Size: int64(len(layer.data))}
+		if layer.title != "" {
+			d.Annotations = map[string]string{ociTitleAnnotation: layer.title}
+		}
+		manifest.Layers = append(manifest.Layers, d)
+	}
+	manifestJSON, err := json.Marshal(manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifestDigest := checksum(manifestJSON)
+
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			user, pass, _ := r.BasicAuth()
+			if user != "robot" || pass != "secret" || r.URL.Query().Get("scope") != "repository:firmware/rpi4:pull" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			_, _ = io.WriteString(w, `{"token":"t0ken"}`)
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer t0ken" {
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(
+				`Bearer realm="%s/token",service="registry",scope="repository:firmware/rpi4:pull"`, server.URL))
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch {
+		case r.URL.Path == "/v2/firmware/rpi4/manifests/v1.38", r.URL.Path == "/v2/firmware/rpi4/manifests/"+manifestDigest:
+			if !strings.Contains(r.Header.Get("Accept"), ociManifestMediaType) {
+				w.WriteHeader(http.StatusNotAcceptable)
+				return
+			}
+			w.Header().Set("Content-Type", ociManifestMediaType)
+			_, _ = w.Write(manifestJSON)
+		case strings.HasPrefix(r.URL.Path, "/v2/firmware/rpi4/blobs/"):
+			data, ok := blobs[strings.TrimPrefix(r.URL.Path, "/v2/firmware/rpi4/blobs/")]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			_, _ = w.Write(data)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server, manifestDigest
+}
+
+func TestOCI(t *testing.T) {
+	server, _ := fakeRegistry(t,
+		testLayer{"application/octet-stream", "RPI_EFI.fd", []byte("from oci")},
+		testLayer{"text/plain", "config.txt", []byte("arm_64bit=1\n")},
+	)
+	host := strings.TrimPrefix(server.URL, "http://")
+	f := Fetcher{Sources: map[string]Source{"oci": &OCI{PlainHTTP: true, Username: "robot", Password: "secret"}}}
+
+	got, err := f.Fetch(context.Background(), types.FirmwareSource{URL: "oci://" + host + "/firmware/rpi4:v1.38#RPI_EFI.fd"})
+	if err != nil || string(got) != "from oci" {
+		t.Fatalf("Fetch() = %q, %v", got, err)
+	}
+	for _, rawURL := range []string{
+		"oci://" + host + "/firmware/rpi4:v1.38",
+		"oci://" + host + "/firmware/rpi4:v1.38#missing",
+		"oci://" + host + "/firmware/rpi4:v2",
+		"oci://" + host + "/firmware/rpi4@md5:abc",
+	} {
+		if _, err := f.Fetch(context.Background(), types.FirmwareSource{URL: rawURL}); err == nil {
+			t.Errorf("Fetch(%s) succeeded", rawURL)
+		}
+	}
+
+	anonymous := Fetcher{Sources: map[string]Source{"oci": &OCI{PlainHTTP: true}}}
+	if _, err := anonymous.Fetch(context.Background(), types.FirmwareSource{URL: "oci://" + host + "/firmware/rpi4:v1.38#RPI_EFI.fd"}); err == nil {
+		t.Error("Fetch() without credentials succeeded")
+	}
+}
+
+func TestParseOCIReference(t *testing.T) {
+	for _, tt := range []struct {
+		url, repo, ref string
+	}{
+		{"oci://ghcr.io/org/firmware:v1", "org/firmware", "v1"},
+		{"oci://localhost:5000/firmware", "firmware", "latest"},
+		{"oci://ghcr.io/org/firmware@sha256:abcd", "org/firmware", "sha256:abcd"},
+	} {
+		u, _ := url.Parse(tt.url)
+		repo, ref, err := parseOCIReference(u)
+		if err != nil || repo != tt.repo || ref != tt.ref {
+			t.Errorf("parseOCIReference(%s) = %s, %s, %v", tt.url, repo, ref, err)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package fetch
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/metal3-community/uefi-firmware-manager/types"
+)
+
+// BundleManifestName is the name of the types.BundleManifest shipped in a
+// firmware bundle, as JSON.
+const BundleManifestName = "manifest.json"
+
+// HasManifest reports whether the bundle ships a manifest.
+func (b *Bundle) HasManifest() bool {
+	_, ok := b.Files[BundleManifestName]
+	return ok
+}
+
+// Verify checks the files of the bundle against its manifest. Every
+// missing, extra or altered file is reported.
+func (b *Bundle) Verify() error {
+	return verifyBundleFiles(b.Files)
+}
+
+// VerifyBundleDir checks the files below dir, a bundle extracted to disk,
+// against the manifest at its root.
+func VerifyBundleDir(dir string) error {
+	files := map[string][]byte{}
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return fmt.Errorf("%s is not a regular file", path)
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		files[filepath.ToSlash(rel)], err = os.ReadFile(path)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to read bundle: %w", err)
+	}
+	return verifyBundleFiles(files)
+}
+
+// verifyBundleFiles checks files against the manifest among them.
+func verifyBundleFiles(files map[string][]byte) error {
+	data, ok := files[BundleManifestName]
+	if !ok {
+		return fmt.Errorf("bundle has no %s", BundleManifestName)
+	}
+	var manifest types.BundleManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", BundleManifestName, err)
+	}
+	return manifest.Verify(files, BundleManifestName)
+}
@@ -0,0 +1,292 @@
+package fetch
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// OCI media types of image manifests.
+const (
+	ociManifestMediaType    = "application/vnd.oci.image.manifest.v1+json"
+	dockerManifestMediaType = "application/vnd.docker.distribution.manifest.v2+json"
+)
+
+// ociTitleAnnotation names the file a layer holds, as set by ORAS.
+const ociTitleAnnotation = "org.opencontainers.image.title"
+
+// OCI is the Source of oci://<registry>/<repository>[:<tag>|@<digest>]
+// URLs, reading a layer of an artifact from an OCI distribution registry,
+// as pushed with "oras push". The URL fragment selects the layer by its
+// title annotation; it may be omitted for single layer artifacts.
+type OCI struct {
+	// Client sends the requests; nil uses http.DefaultClient.
+	Client *http.Client
+	// PlainHTTP talks to the registry without TLS.
+	PlainHTTP bool
+	// Username and Password, if set, authenticate to the token service of
+	// the registry. Otherwise tokens are requested anonymously.
+	Username string
+	Password string
+	// RequireDigest refuses references that are not pinned to a manifest
+	// digest, so a moved tag cannot change what is installed.
+	RequireDigest bool
+	// RequireManifest makes Pull refuse bundles without a manifest.json.
+	RequireManifest bool
+}
+
+// ociManifest is an image manifest.
+type ociManifest struct {
+	Layers []ociDescriptor `json:"layers"`
+}
+
+// ociDescriptor describes a layer of a manifest.
+type ociDescriptor struct {
+	MediaType   string            `json:"mediaType"`
+	Digest      string            `json:"digest"`
+	Size        int64             `json:"size"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// Open downloads the selected layer of the artifact of u and verifies its
+// digest. headers are added to the registry requests.
+func (o *OCI) Open(ctx context.Context, u *url.URL, headers map[string]string) (io.ReadCloser, error) {
+	base, ref, err := o.repository(u)
+	if err != nil {
+		return nil, err
+	}
+	c := &ociClient{oci: o, ctx: ctx, headers: headers}
+
+	manifest, _, err := c.manifest(base, ref)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", u, err)
+	}
+	layer, err := selectLayer(manifest.Layers, u.Fragment)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", u, err)
+	}
+
+	data, err := c.blob(base, layer)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", u, err)
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+// repository returns the registry API URL of the repository of u and the
+// tag or digest u references.
+func (o *OCI) repository(u *url.URL) (string, string, error) {
+	repo, ref, err := parseOCIReference(u)
+	if err != nil {
+		return "", "", err
+	}
+	if o.RequireDigest && !strings.HasPrefix(ref, "sha256:") {
+		return "", "", fmt.Errorf("OCI URL %s is not pinned to a digest", u)
+	}
+	scheme := "https"
+	if o.PlainHTTP {
+		scheme = "http"
+	}
+	return scheme + "://" + u.Host + "/v2/" + repo, ref, nil
+}
+
+// parseOCIReference returns the repository and the tag or digest of u.
+func parseOCIReference(u *url.URL) (repo, ref string, err error) {
+	repo = strings.TrimPrefix(u.Path, "/")
+	if at := strings.LastIndex(repo, "@"); at >= 0 {
+		repo, ref = repo[:at], repo[at+1:]
+		if !strings.HasPrefix(ref, "sha256:") {
+			return "", "", fmt.Errorf("invalid OCI URL %s: unsupported digest %s", u, ref)
+		}
+	} else if colon := strings.LastIndex(repo, ":"); colon > strings.LastIndex(repo, "/") {
+		repo, ref = repo[:colon], repo[colon+1:]
+	} else {
+		ref = "latest"
+	}
+	if u.Host == "" || repo == "" || ref == "" {
+		return "", "", fmt.Errorf("invalid OCI URL %s: want oci://<registry>/<repository>[:<tag>]", u)
+	}
+	return repo, ref, nil
+}
+
+// selectLayer returns the layer titled name, or the only layer if name is
+// empty.
+func selectLayer(layers []ociDescriptor, name string) (ociDescriptor, error) {
+	if name == "" {
+		if len(layers) != 1 {
+			return ociDescriptor{}, fmt.Errorf("artifact has %d layers; select one with a URL fragment", len(layers))
+		}
+		return layers[0], nil
+	}
+	for _, layer := range layers {
+		if layer.Annotations[ociTitleAnnotation] == name {
+			return layer, nil
+		}
+	}
+	return ociDescriptor{}, fmt.Errorf("artifact has no layer titled %s", name)
+}
+
+// manifest returns the manifest ref references in the repository at base
+// and its digest. The manifest of a digest reference is verified.
+func (c *ociClient) manifest(base, ref string) (ociManifest, string, error) {
+	resp, err := c.get(base+"/manifests/"+ref, ociManifestMediaType+", "+dockerManifestMediaType)
+	if err != nil {
+		return ociManifest{}, "", fmt.Errorf("failed to get manifest: %w", err)
+	}
+	defer resp.Body.Close()
+	data, err := readAll(resp.Body)
+	if err != nil {
+		return ociManifest{}, "", fmt.Errorf("failed to read manifest: %w", err)
+	}
+	sum := sha256.Sum256(data)
+	digest := "sha256:" + hex.EncodeToString(sum[:])
+	if strings.HasPrefix(ref, "sha256:") && ref != digest {
+		return ociManifest{}, "", fmt.Errorf("manifest does not match its digest %s", ref)
+	}
+
+	var manifest ociManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return ociManifest{}, "", fmt.Errorf("failed to parse manifest: %w", err)
+	}
+	return manifest, digest, nil
+}
+
+// blob returns the contents of layer from the repository at base,
+// verified against its digest.
+func (c *ociClient) blob(base string, layer ociDescriptor) ([]byte, error) {
+	resp, err := c.get(base+"/blobs/"+layer.Digest, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get layer %s: %w", layer.Digest, err)
+	}
+	defer resp.Body.Close()
+	data, err := readAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read layer %s: %w", layer.Digest, err)
+	}
+	if got := sha256.Sum256(data); layer.Digest != "sha256:"+hex.EncodeToString(got[:]) {
+		return nil, fmt.Errorf("layer does not match its digest %s", layer.Digest)
+	}
+	return data, nil
+}
+
+// ociClient sends the registry requests of one Open or Pull,
+// authenticating with a bearer token when the registry asks for one.
+type ociClient struct {
+	oci     *OCI
+	ctx     context.Context
+	headers map[string]string
+	token   string
+}
+
+// get sends a GET request for u, fetching a token and retrying once if
+// the registry requires authentication.
+func (c *ociClient) get(u, accept string) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequestWithContext(c.ctx, http.MethodGet, u, nil)
+		if err != nil {
+			return nil, err
+		}
+		for name, value := range c.headers {
+			req.Header.Set(name, value)
+		}
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		if c.token != "" {
+			req.Header.Set("Authorization", "Bearer "+c.token)
+		}
+		resp, err := httpClient(c.oci.Client).Do(req)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode == http.StatusUnauthorized && attempt == 0 {
+			challenge := resp.Header.Get("WWW-Authenticate")
+			resp.Body.Close()
+			if c.token, err = c.fetchToken(challenge); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if err := responseError(resp); err != nil {
+			resp.Body.Close()
+			return nil, err
+		}
+		return resp, nil
+	}
+}
+
+// fetchToken requests a token from the service of a Bearer challenge.
+func (c *ociClient) fetchToken(challenge string) (string, error) {
+	scheme, params, _ := strings.Cut(challenge, " ")
+	if !strings.EqualFold(scheme, "Bearer") {
+		return "", fmt.Errorf("unsupported registry authentication %q", challenge)
+	}
+	values := parseChallenge(params)
+	realm, err := url.Parse(values["realm"])
+	if err != nil || values["realm"] == "" {
+		return "", fmt.Errorf("invalid registry authentication challenge %q", challenge)
+	}
+	query := realm.Query()
+	for _, name := range []string{"service", "scope"} {
+		if v := values[name]; v != "" {
+			query.Set(name, v)
+		}
+	}
+	realm.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(c.ctx, http.MethodGet, realm.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	if c.oci.Username != "" {
+		req.SetBasicAuth(c.oci.Username, c.oci.Password)
+	}
+	resp, err := httpClient(c.oci.Client).Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if err := responseError(resp); err != nil {
+		return "", fmt.Errorf("failed to get registry token: %w", err)
+	}
+	var token struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", fmt.Errorf("failed to parse registry token: %w", err)
+	}
+	if token.Token == "" {
+		token.Token = token.AccessToken
+	}
+	if token.Token == "" {
+		return "", fmt.Errorf("registry token service returned no token")
+	}
+	return token.Token, nil
+}
+
+// parseChallenge parses the comma separated key="value" parameters of a
+// WWW-Authenticate challenge.
+func parseChallenge(params string) map[string]string {
+	values := map[string]string{}
+	for params != "" {
+		var key, value string
+		key, params, _ = strings.Cut(params, "=")
+		key = strings.ToLower(strings.TrimSpace(key))
+		if strings.HasPrefix(params, `"`) {
+			value, params, _ = strings.Cut(params[1:], `"`)
+			_, params, _ = strings.Cut(params, ",")
+		} else {
+			value, params, _ = strings.Cut(params, ",")
+		}
+		values[key] = strings.TrimSpace(value)
+	}
+	return values
+}
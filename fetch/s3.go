@@ -0,0 +1,37 @@
+package fetch
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+
+	"github.com/metal3-community/uefi-firmware-manager/manager"
+)
+
+// S3 is the Source of s3://<bucket>/<key> URLs, reading objects from an
+// S3 compatible service such as MinIO.
+type S3 struct {
+	// Storage holds the endpoint and credentials. Its bucket and prefix
+	// are replaced by those of the URL.
+	Storage manager.S3Storage
+}
+
+// Open reads the object of u. The context and headers are not used: the
+// storage signs its own requests.
+func (s *S3) Open(_ context.Context, u *url.URL, _ map[string]string) (io.ReadCloser, error) {
+	key := strings.TrimPrefix(u.Path, "/")
+	if u.Host == "" || key == "" {
+		return nil, fmt.Errorf("invalid S3 URL %s: want s3://<bucket>/<key>", u)
+	}
+	storage := s.Storage
+	storage.Bucket = u.Host
+	storage.Prefix = ""
+	data, err := storage.ReadFile(key)
+	if err != nil {
+		return nil, err
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
@@ -0,0 +1,60 @@
+// Package filelock serializes writers of a file across processes with
+// advisory locks, so that for instance an updater and a manager writing
+// the same RPI_EFI.fd cannot interleave.
+package filelock
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// DefaultTimeout is how long writers wait for a lock by default.
+const DefaultTimeout = 30 * time.Second
+
+// ErrTimeout is returned by Lock when the lock is still held by another
+// writer after the timeout.
+var ErrTimeout = errors.New("timed out waiting for file lock")
+
+// pollInterval is how often Lock retries a held lock.
+const pollInterval = 50 * time.Millisecond
+
+// Lock takes an exclusive advisory lock on the file at path and returns
+// the function releasing it. The lock is held on the hidden file
+// .<name>.lock next to path rather than on path itself, so it survives
+// writers that replace path. Lock waits up to timeout for other holders; a
+// timeout of zero waits indefinitely. Locks are only advisory: writers
+// that do not call Lock are not held back. On platforms without flock,
+// Lock does nothing.
+func Lock(path string, timeout time.Duration) (func(), error) {
+	lockPath := filepath.Join(filepath.Dir(path), "."+filepath.Base(path)+".lock")
+	f, err := os.OpenFile(lockPath, os.O_CREATE|os.O_RDWR, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lock file: %w", err)
+	}
+
+	var deadline time.Time
+	if timeout > 0 {
+		deadline = time.Now().Add(timeout)
+	}
+	for {
+		locked, err := tryLock(f)
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("failed to lock %s: %w", path, err)
+		}
+		if locked {
+			return func() {
+				unlock(f)
+				f.Close()
+			}, nil
+		}
+		if !deadline.IsZero() && time.Now().After(deadline) {
+			f.Close()
+			return nil, fmt.Errorf("%s: %w", path, ErrTimeout)
+		}
+		time.Sleep(pollInterval)
+	}
+}
@@ -0,0 +1,13 @@
+//go:build !(linux || darwin || dragonfly || freebsd || netbsd || openbsd)
+
+package filelock
+
+import "os"
+
+// tryLock does nothing on platforms without flock.
+func tryLock(f *os.File) (bool, error) {
+	return true, nil
+}
+
+// unlock does nothing on platforms without flock.
+func unlock(f *os.File) {}
@@ -0,0 +1,47 @@
+package filelock
+
+import (
+	"errors"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestLock(t *testing.T) {
+	switch runtime.GOOS {
+	case "linux", "darwin", "dragonfly", "freebsd", "netbsd", "openbsd":
+	default:
+		t.Skip("no flock on " + runtime.GOOS)
+	}
+	path := filepath.Join(t.TempDir(), "RPI_EFI.fd")
+
+	unlock, err := Lock(path, time.Second)
+	if err != nil {
+		t.Fatalf("Lock() error = %v", err)
+	}
+	if _, err := Lock(path, 100*time.Millisecond); !errors.Is(err, ErrTimeout) {
+		t.Fatalf("Lock() of a held lock error = %v, want ErrTimeout", err)
+	}
+
+	// A waiting writer gets the lock once it is released.
+	done := make(chan error)
+	go func() {
+		unlock, err := Lock(path, 5*time.Second)
+		if err == nil {
+			unlock()
+		}
+		done <- err
+	}()
+	time.Sleep(2 * pollInterval)
+	unlock()
+	if err := <-done; err != nil {
+		t.Fatalf("Lock() after release error = %v", err)
+	}
+
+	unlock, err = Lock(path, 0)
+	if err != nil {
+		t.Fatalf("Lock() without timeout error = %v", err)
+	}
+	unlock()
+}
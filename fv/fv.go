@@ -0,0 +1,304 @@
+// Package fv enumerates the firmware volumes of a UEFI firmware image and
+// the FFS files and sections they contain.
+package fv
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/metal3-community/uefi-firmware-manager/efi"
+)
+
+const (
+	fvSignature        = "_FVH"
+	ffs3FileSystem     = "5473c07a-3dcb-4dca-bd6f-1e9689e7349a"
+	fvHeaderMinSize    = 56
+	fvbErasePolarity   = 0x00000800
+	ffsHeaderSize      = 24
+	ffsHeader2Size     = 32
+	ffsAttribLargeFile = 0x01
+	ffsAttribChecksum  = 0x40
+	ffsFixedChecksum   = 0xaa
+
+	fileStateDataValid = 0x04
+	fileStateDeleted   = 0x10
+)
+
+// ErrInvalidVolume is returned for malformed firmware volumes.
+var ErrInvalidVolume = errors.New("invalid firmware volume")
+
+// FileType is an EFI_FV_FILETYPE.
+type FileType uint8
+
+const (
+	FileTypeRaw                FileType = 0x01
+	FileTypeFreeform           FileType = 0x02
+	FileTypeSecurityCore       FileType = 0x03
+	FileTypePEICore            FileType = 0x04
+	FileTypeDXECore            FileType = 0x05
+	FileTypePEIM               FileType = 0x06
+	FileTypeDriver             FileType = 0x07
+	FileTypeCombinedPEIMDriver FileType = 0x08
+	FileTypeApplication        FileType = 0x09
+	FileTypeMM                 FileType = 0x0a
+	FileTypeFirmwareVolume     FileType = 0x0b
+	FileTypeCombinedMMDXE      FileType = 0x0c
+	FileTypeMMCore             FileType = 0x0d
+	FileTypeMMStandalone       FileType = 0x0e
+	FileTypeMMCoreStandalone   FileType = 0x0f
+	FileTypePad                FileType = 0xf0
+)
+
+var fileTypeNames = map[FileType]string{
+	FileTypeRaw:                "RAW",
+	FileTypeFreeform:           "FREEFORM",
+	FileTypeSecurityCore:       "SECURITY_CORE",
+	FileTypePEICore:            "PEI_CORE",
+	FileTypeDXECore:            "DXE_CORE",
+	FileTypePEIM:               "PEIM",
+	FileTypeDriver:             "DRIVER",
+	FileTypeCombinedPEIMDriver: "COMBINED_PEIM_DRIVER",
+	FileTypeApplication:        "APPLICATION",
+	FileTypeMM:                 "MM",
+	FileTypeFirmwareVolume:     "FIRMWARE_VOLUME_IMAGE",
+	FileTypeCombinedMMDXE:      "COMBINED_MM_DXE",
+	FileTypeMMCore:             "MM_CORE",
+	FileTypeMMStandalone:       "MM_STANDALONE",
+	FileTypeMMCoreStandalone:   "MM_CORE_STANDALONE",
+	FileTypePad:                "FFS_PAD",
+}
+
+func (t FileType) String() string {
+	if name, ok := fileTypeNames[t]; ok {
+		return name
+	}
+	return fmt.Sprintf("0x%02x", uint8(t))
+}
+
+// Volume is a firmware volume and the files it contains.
+type Volume struct {
+	// Offset is the offset of the volume in the data it was found in.
+	Offset     int
+	FileSystem efi.GUID
+	// Name is the volume name from the extended header, if present.
+	Name       *efi.GUID
+	Length     uint64
+	Attributes uint32
+	Files      []File
+}
+
+// File is an FFS file.
+type File struct {
+	Name       efi.GUID
+	Type       FileType
+	Attributes uint8
+	State      uint8
+	// Offset is the offset of the file header within its volume.
+	Offset int
+	// Size is the size of the file including its header.
+	Size int
+	// Data is the file contents following the header.
+	Data []byte
+	// ChecksumValid reports whether the header and data checksums match.
+	ChecksumValid bool
+	Sections      []Section
+}
+
+// FindVolumes scans data for firmware volumes and parses each of them.
+// Volumes are searched on 8-byte boundaries and must have a valid header
+// checksum.
+func FindVolumes(data []byte) ([]Volume, error) {
+	var volumes []Volume
+	for off := 0; off+fvHeaderMinSize <= len(data); {
+		if !bytes.Equal(data[off+40:off+44], []byte(fvSignature)) || !validHeader(data[off:]) {
+			off += 8
+			continue
+		}
+		v, err := ParseVolume(data[off:])
+		if err != nil {
+			return nil, fmt.Errorf("volume at offset 0x%x: %w", off, err)
+		}
+		v.Offset = off
+		volumes = append(volumes, *v)
+		off += int(v.Length)
+	}
+	return volumes, nil
+}
+
+// validHeader reports whether data starts with a checksummed volume header.
+func validHeader(data []byte) bool {
+	hlen := int(binary.LittleEndian.Uint16(data[48:50]))
+	length := binary.LittleEndian.Uint64(data[32:40])
+	if hlen < fvHeaderMinSize || hlen > len(data) || length < uint64(hlen) ||
+		length > uint64(len(data)) || hlen%2 != 0 {
+		return false
+	}
+	var sum uint16
+	for i := 0; i < hlen; i += 2 {
+		sum += binary.LittleEndian.Uint16(data[i:])
+	}
+	return sum == 0
+}
+
+// ParseVolume parses the firmware volume at the start of data.
+func ParseVolume(data []byte) (*Volume, error) {
+	if len(data) < fvHeaderMinSize || !bytes.Equal(data[40:44], []byte(fvSignature)) {
+		return nil, fmt.Errorf("%w: missing %s signature", ErrInvalidVolume, fvSignature)
+	}
+	v := &Volume{
+		FileSystem: efi.ParseBinGUID(data, 16),
+		Length:     binary.LittleEndian.Uint64(data[32:40]),
+		Attributes: binary.LittleEndian.Uint32(data[44:48]),
+	}
+	hlen := int(binary.LittleEndian.Uint16(data[48:50]))
+	if v.Length > uint64(len(data)) || uint64(hlen) > v.Length || hlen < fvHeaderMinSize {
+		return nil, fmt.Errorf("%w: length 0x%x, header length 0x%x", ErrInvalidVolume, v.Length, hlen)
+	}
+	data = data[:v.Length]
+
+	start := hlen
+	if ext := int(binary.LittleEndian.Uint16(data[52:54])); ext != 0 {
+		if ext+20 > len(data) {
+			return nil, fmt.Errorf("%w: extended header outside volume", ErrInvalidVolume)
+		}
+		name := efi.ParseBinGUID(data, ext)
+		v.Name = &name
+		start = ext + int(binary.LittleEndian.Uint32(data[ext+16:ext+20]))
+	}
+
+	// Other file systems, like the variable store, do not hold FFS files.
+	if fs := v.FileSystem.String(); fs != efi.Ffs && fs != ffs3FileSystem {
+		return v, nil
+	}
+
+	files, err := parseFiles(data, align(start, 8), v.Attributes&fvbErasePolarity != 0)
+	if err != nil {
+		return nil, err
+	}
+	v.Files = files
+	return v, nil
+}
+
+// parseFiles parses the FFS files of a volume starting at offset start.
+func parseFiles(data []byte, start int, erasePolarity bool) ([]File, error) {
+	var files []File
+	for off := start; off+ffsHeaderSize <= len(data); {
+		hdr := data[off : off+ffsHeaderSize]
+		if isErased(hdr) {
+			break
+		}
+		f := File{
+			Name:       efi.ParseBinGUID(hdr, 0),
+			Type:       FileType(hdr[18]),
+			Attributes: hdr[19],
+			State:      hdr[23],
+			Offset:     off,
+		}
+		headerSize := ffsHeaderSize
+		size := int(hdr[20]) | int(hdr[21])<<8 | int(hdr[22])<<16
+		if f.Attributes&ffsAttribLargeFile != 0 {
+			if off+ffsHeader2Size > len(data) {
+				return nil, fmt.Errorf("%w: truncated file header at 0x%x", ErrInvalidVolume, off)
+			}
+			headerSize = ffsHeader2Size
+			size64 := binary.LittleEndian.Uint64(data[off+24 : off+32])
+			if size64 > uint64(len(data)) {
+				return nil, fmt.Errorf("%w: file at 0x%x exceeds volume", ErrInvalidVolume, off)
+			}
+			size = int(size64)
+		}
+		if size < headerSize || off+size > len(data) {
+			return nil, fmt.Errorf("%w: file %s at 0x%x has invalid size 0x%x",
+				ErrInvalidVolume, f.Name, off, size)
+		}
+		f.Size = size
+		f.Data = data[off+headerSize : off+size]
+		f.ChecksumValid = fileChecksumValid(data[off:off+headerSize], f.Data)
+
+		state := f.State
+		if erasePolarity {
+			state = ^state
+		}
+		if state&fileStateDataValid != 0 && state&fileStateDeleted == 0 && f.Type != FileTypePad {
+			f.Sections = parseSections(f.Data)
+			files = append(files, f)
+		}
+		off = align(off+size, 8)
+	}
+	return files, nil
+}
+
+// fileChecksumValid checks the IntegrityCheck field of an FFS file header.
+func fileChecksumValid(hdr, body []byte) bool {
+	// The header checksum covers the header with the file checksum and
+	// state fields treated as zero.
+	var sum uint8
+	for i, b := range hdr {
+		if i == 17 || i == 23 {
+			continue
+		}
+		sum += b
+	}
+	if sum != 0 {
+		return false
+	}
+	if hdr[19]&ffsAttribChecksum == 0 {
+		return hdr[17] == ffsFixedChecksum
+	}
+	sum = hdr[17]
+	for _, b := range body {
+		sum += b
+	}
+	return sum == 0
+}
+
+func isErased(data []byte) bool {
+	for _, b := range data {
+		if b != 0xff {
+			return false
+		}
+	}
+	return true
+}
+
+func align(n, a int) int {
+	return (n + a - 1) &^ (a - 1)
+}
+
+// AllFiles returns the files of the volume and of every volume nested in
+// its sections, depth first.
+func (v *Volume) AllFiles() []File {
+	var files []File
+	for _, f := range v.Files {
+		files = append(files, f)
+		for _, s := range f.Sections {
+			files = append(files, s.allFiles()...)
+		}
+	}
+	return files
+}
+
+// UIName returns the name from the file's user interface section.
+func (f *File) UIName() string {
+	for _, s := range f.allSections() {
+		if s.Type == SectionTypeUserInterface {
+			return s.UIName
+		}
+	}
+	return ""
+}
+
+func (f *File) allSections() []Section {
+	var all []Section
+	var walk func([]Section)
+	walk = func(sections []Section) {
+		for _, s := range sections {
+			all = append(all, s)
+			walk(s.Sections)
+		}
+	}
+	walk(f.Sections)
+	return all
+}
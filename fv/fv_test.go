@@ -0,0 +1,204 @@
+package fv
+
+import (
+	"encoding/binary"
+	"os"
+	"testing"
+
+	"github.com/metal3-community/uefi-firmware-manager/efi"
+)
+
+const (
+	testDriverGUID = "11111111-2222-3333-4444-555555555555"
+	testNestedGUID = "66666666-7777-8888-9999-aaaaaaaaaaaa"
+	testDeadGUID   = "deaddead-dead-dead-dead-deaddeaddead"
+)
+
+// section builds a section of type t around body.
+func section(t SectionType, body []byte) []byte {
+	size := sectionHeaderSize + len(body)
+	s := []byte{byte(size), byte(size >> 8), byte(size >> 16), byte(t)}
+	s = append(s, body...)
+	for len(s)%4 != 0 {
+		s = append(s, 0)
+	}
+	return s
+}
+
+// ffsFile builds an FFS file with valid checksums. The state is written
+// for an erase polarity of 1.
+func ffsFile(name string, t FileType, state uint8, body []byte) []byte {
+	size := ffsHeaderSize + len(body)
+	hdr := make([]byte, ffsHeaderSize)
+	copy(hdr, efi.StringToGUID(name).Bytes())
+	hdr[18] = byte(t)
+	hdr[20], hdr[21], hdr[22] = byte(size), byte(size>>8), byte(size>>16)
+	var sum uint8
+	for _, b := range hdr {
+		sum += b
+	}
+	hdr[16] = -sum
+	hdr[17] = ffsFixedChecksum
+	hdr[23] = ^state
+	f := append(hdr, body...)
+	for len(f)%8 != 0 {
+		f = append(f, 0xff)
+	}
+	return f
+}
+
+// volume builds an FFS2 firmware volume holding files.
+func volume(files ...[]byte) []byte {
+	hdr := make([]byte, 72)
+	copy(hdr[16:], efi.StringToGUID(efi.Ffs).Bytes())
+	copy(hdr[40:], fvSignature)
+	binary.LittleEndian.PutUint32(hdr[44:], fvbErasePolarity)
+	binary.LittleEndian.PutUint16(hdr[48:], 72)
+	hdr[55] = 2
+	binary.LittleEndian.PutUint32(hdr[56:], 1) // one block map entry
+	v := hdr
+	for _, f := range files {
+		v = append(v, f...)
+	}
+	v = append(v, make([]byte, 64)...)
+	for i := len(v) - 64; i < len(v); i++ {
+		v[i] = 0xff
+	}
+	binary.LittleEndian.PutUint32(v[60:], uint32(len(v)))
+	binary.LittleEndian.PutUint64(v[32:], uint64(len(v)))
+	var sum uint16
+	for i := 0; i < 72; i += 2 {
+		sum += binary.LittleEndian.Uint16(v[i:])
+	}
+	binary.LittleEndian.PutUint16(v[50:], -sum)
+	return v
+}
+
+func TestFindVolumes(t *testing.T) {
+	valid := uint8(fileStateDataValid | 0x03)
+	ui := efi.NewUCS16String("TestDriver").Bytes()
+	driver := ffsFile(testDriverGUID, FileTypeDriver, valid, append(
+		section(SectionTypePE32, []byte("MZ")),
+		section(SectionTypeUserInterface, ui)...,
+	))
+	nested := volume(ffsFile(testNestedGUID, FileTypeApplication, valid,
+		section(SectionTypeRaw, []byte{1, 2, 3, 4})))
+	fvImage := ffsFile(testNestedGUID, FileTypeFirmwareVolume, valid,
+		section(SectionTypeFirmwareVolumeImage, nested))
+	deleted := ffsFile(testDeadGUID, FileTypeDriver, valid|fileStateDeleted, nil)
+	pad := ffsFile(efi.NotValid, FileTypePad, valid, make([]byte, 8))
+
+	image := append(make([]byte, 0x40), volume(driver, deleted, pad, fvImage)...)
+	volumes, err := FindVolumes(image)
+	if err != nil {
+		t.Fatalf("FindVolumes() error = %v", err)
+	}
+	if len(volumes) != 1 || volumes[0].Offset != 0x40 {
+		t.Fatalf("FindVolumes() = %d volumes, want one at 0x40", len(volumes))
+	}
+
+	files := volumes[0].AllFiles()
+	if len(files) != 3 {
+		t.Fatalf("AllFiles() returned %d files, want 3", len(files))
+	}
+	if f := files[0]; f.Name.String() != testDriverGUID || f.Type != FileTypeDriver ||
+		f.UIName() != "TestDriver" || !f.ChecksumValid {
+		t.Errorf("unexpected driver file %s %s %q checksum=%v",
+			f.Name, f.Type, f.UIName(), f.ChecksumValid)
+	}
+	if got := files[0].Sections[0].Type; got != SectionTypePE32 {
+		t.Errorf("first driver section = %s, want PE32", got)
+	}
+	if files[1].Type != FileTypeFirmwareVolume || files[2].Type != FileTypeApplication {
+		t.Errorf("nested files = %s, %s", files[1].Type, files[2].Type)
+	}
+
+	// Renaming a file breaks its header checksum.
+	image[0x40+72] ^= 0xff
+	volumes, err = FindVolumes(image)
+	if err != nil {
+		t.Fatalf("FindVolumes() error = %v", err)
+	}
+	if volumes[0].Files[0].ChecksumValid {
+		t.Error("ChecksumValid = true for a tampered file header")
+	}
+}
+
+func TestFindVolumes_EDK2Image(t *testing.T) {
+	image, err := os.ReadFile("../edk2/RPI_EFI.fd")
+	if err != nil {
+		t.Fatalf("failed to read firmware: %v", err)
+	}
+	volumes, err := FindVolumes(image)
+	if err != nil {
+		t.Fatalf("FindVolumes() error = %v", err)
+	}
+	if len(volumes) != 2 {
+		t.Fatalf("FindVolumes() returned %d volumes, want 2", len(volumes))
+	}
+	if volumes[1].FileSystem.String() != efi.NvData || len(volumes[1].Files) != 0 {
+		t.Errorf("second volume should be the variable store")
+	}
+	for _, f := range volumes[0].AllFiles() {
+		if !f.ChecksumValid {
+			t.Errorf("file %s has an invalid checksum", f.Name)
+		}
+	}
+	// The DXE volume is LZMA compressed and not decoded.
+	fvFile := volumes[0].Files[1]
+	if fvFile.Type != FileTypeFirmwareVolume || !fvFile.Sections[0].Encoded {
+		t.Errorf("expected an encoded firmware volume file, got %s", fvFile.Type)
+	}
+}
+
+func TestParseVolume_Invalid(t *testing.T) {
+	v := volume()
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"short", v[:16]},
+		{"no signature", make([]byte, 128)},
+		{"truncated", v[:len(v)-1]},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseVolume(tt.data); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func FuzzParseVolume(f *testing.F) {
+	valid := uint8(fileStateDataValid | 0x03)
+	ui := efi.NewUCS16String("TestDriver").Bytes()
+	f.Add(volume(ffsFile(testDriverGUID, FileTypeDriver, valid, append(
+		section(SectionTypePE32, []byte("MZ")),
+		section(SectionTypeUserInterface, ui)...,
+	))))
+	f.Add(volume(ffsFile(testNestedGUID, FileTypeFirmwareVolume, valid,
+		section(SectionTypeFirmwareVolumeImage, volume()))))
+	f.Add([]byte{})
+
+	// The variable store volume of the shipped firmware.
+	image, err := os.ReadFile("../edk2/RPI_EFI.fd")
+	if err != nil {
+		f.Fatal(err)
+	}
+	volumes, err := FindVolumes(image)
+	if err != nil {
+		f.Fatal(err)
+	}
+	nv := volumes[len(volumes)-1]
+	f.Add(image[nv.Offset : nv.Offset+int(nv.Length)])
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if v, err := ParseVolume(data); err == nil {
+			for _, file := range v.AllFiles() {
+				_ = file.UIName()
+			}
+		}
+		_, _ = FindVolumes(data)
+	})
+}
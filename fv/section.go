@@ -0,0 +1,166 @@
+package fv
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/metal3-community/uefi-firmware-manager/efi"
+)
+
+const (
+	sectionHeaderSize  = 4
+	sectionHeader2Size = 8
+	// guidedProcessingRequired marks GUID-defined sections whose contents
+	// must be decoded, e.g. decompressed, before they can be read.
+	guidedProcessingRequired = 0x01
+)
+
+// SectionType is an EFI_SECTION_TYPE.
+type SectionType uint8
+
+const (
+	SectionTypeCompression         SectionType = 0x01
+	SectionTypeGUIDDefined         SectionType = 0x02
+	SectionTypeDisposable          SectionType = 0x03
+	SectionTypePE32                SectionType = 0x10
+	SectionTypePIC                 SectionType = 0x11
+	SectionTypeTE                  SectionType = 0x12
+	SectionTypeDXEDepex            SectionType = 0x13
+	SectionTypeVersion             SectionType = 0x14
+	SectionTypeUserInterface       SectionType = 0x15
+	SectionTypeCompatibility16     SectionType = 0x16
+	SectionTypeFirmwareVolumeImage SectionType = 0x17
+	SectionTypeFreeformSubtypeGUID SectionType = 0x18
+	SectionTypeRaw                 SectionType = 0x19
+	SectionTypePEIDepex            SectionType = 0x1b
+	SectionTypeMMDepex             SectionType = 0x1c
+)
+
+var sectionTypeNames = map[SectionType]string{
+	SectionTypeCompression:         "COMPRESSION",
+	SectionTypeGUIDDefined:         "GUID_DEFINED",
+	SectionTypeDisposable:          "DISPOSABLE",
+	SectionTypePE32:                "PE32",
+	SectionTypePIC:                 "PIC",
+	SectionTypeTE:                  "TE",
+	SectionTypeDXEDepex:            "DXE_DEPEX",
+	SectionTypeVersion:             "VERSION",
+	SectionTypeUserInterface:       "USER_INTERFACE",
+	SectionTypeCompatibility16:     "COMPATIBILITY16",
+	SectionTypeFirmwareVolumeImage: "FIRMWARE_VOLUME_IMAGE",
+	SectionTypeFreeformSubtypeGUID: "FREEFORM_SUBTYPE_GUID",
+	SectionTypeRaw:                 "RAW",
+	SectionTypePEIDepex:            "PEI_DEPEX",
+	SectionTypeMMDepex:             "MM_DEPEX",
+}
+
+func (t SectionType) String() string {
+	if name, ok := sectionTypeNames[t]; ok {
+		return name
+	}
+	return fmt.Sprintf("0x%02x", uint8(t))
+}
+
+// Section is a section of an FFS file.
+type Section struct {
+	Type SectionType
+	// Offset is the offset of the section header within the file data.
+	Offset int
+	// Size is the size of the section including its header.
+	Size int
+	// GUID is the definition GUID of GUID-defined sections and the subtype
+	// of freeform subtype sections.
+	GUID *efi.GUID
+	// Encoded is set for encapsulation sections whose contents could not
+	// be decoded, such as compressed sections.
+	Encoded bool
+	// UIName is the name carried by user interface sections.
+	UIName string
+	// Sections are the sections encapsulated by this one.
+	Sections []Section
+	// Volumes are the firmware volumes in firmware volume image sections.
+	Volumes []Volume
+}
+
+// parseSections parses the sections of an FFS file. Files that do not
+// hold sections, like raw files, simply yield none.
+func parseSections(data []byte) []Section {
+	var sections []Section
+	for off := 0; off+sectionHeaderSize <= len(data); off = align(off, 4) {
+		s, next, ok := parseSection(data, off)
+		if !ok {
+			return sections
+		}
+		sections = append(sections, s)
+		off = next
+	}
+	return sections
+}
+
+func parseSection(data []byte, off int) (Section, int, bool) {
+	hdr := data[off:]
+	s := Section{Type: SectionType(hdr[3]), Offset: off}
+	headerSize := sectionHeaderSize
+	size := int(hdr[0]) | int(hdr[1])<<8 | int(hdr[2])<<16
+	if size == 0xffffff {
+		if len(hdr) < sectionHeader2Size {
+			return s, 0, false
+		}
+		headerSize = sectionHeader2Size
+		size = int(binary.LittleEndian.Uint32(hdr[4:8]))
+	}
+	if size < headerSize || size > len(hdr) {
+		return s, 0, false
+	}
+	s.Size = size
+	body := hdr[headerSize:size]
+
+	switch s.Type {
+	case SectionTypeCompression:
+		// UINT32 UncompressedLength, UINT8 CompressionType
+		if len(body) >= 5 && body[4] == 0 {
+			s.Sections = parseSections(body[5:])
+		} else {
+			s.Encoded = true
+		}
+	case SectionTypeGUIDDefined:
+		if len(body) < 20 {
+			return s, 0, false
+		}
+		guid := efi.ParseBinGUID(body, 0)
+		s.GUID = &guid
+		dataOffset := int(binary.LittleEndian.Uint16(body[16:18]))
+		attrs := binary.LittleEndian.Uint16(body[18:20])
+		switch {
+		case attrs&guidedProcessingRequired != 0:
+			s.Encoded = true
+		case dataOffset >= headerSize && dataOffset <= size:
+			s.Sections = parseSections(hdr[dataOffset:size])
+		}
+	case SectionTypeDisposable:
+		s.Sections = parseSections(body)
+	case SectionTypeFreeformSubtypeGUID:
+		if len(body) >= 16 {
+			guid := efi.ParseBinGUID(body, 0)
+			s.GUID = &guid
+		}
+	case SectionTypeUserInterface:
+		s.UIName = efi.FromUCS16(body).String()
+	case SectionTypeFirmwareVolumeImage:
+		if v, err := ParseVolume(body); err == nil {
+			s.Volumes = []Volume{*v}
+		}
+	}
+	return s, off + size, true
+}
+
+func (s *Section) allFiles() []File {
+	var files []File
+	for _, v := range s.Volumes {
+		files = append(files, v.AllFiles()...)
+	}
+	for _, sub := range s.Sections {
+		files = append(files, sub.allFiles()...)
+	}
+	return files
+}
@@ -0,0 +1,174 @@
+// Package archive packs directories into tar.gz and zip archives.
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// Format is the format of an archive.
+type Format string
+
+const (
+	// TarGz is a gzip compressed tar archive.
+	TarGz Format = "tar.gz"
+	// Zip is a zip archive.
+	Zip Format = "zip"
+)
+
+// Create packs the files below dir into the archive dest. Names in the
+// archive are relative to dir, below a directory named prefix if it is not
+// empty. Files other than directories and regular files are an error.
+// dest is written to a temporary file next to it and renamed into place,
+// so it is replaced atomically.
+func Create(dir, dest string, format Format, prefix string) error {
+	if format != TarGz && format != Zip {
+		return fmt.Errorf("unknown archive format %q", format)
+	}
+	if info, err := os.Stat(dir); err != nil {
+		return err
+	} else if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", dir)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(dest), "."+filepath.Base(dest)+"-*")
+	if err != nil {
+		return fmt.Errorf("failed to create archive: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	// Skip the archive itself when dest is below dir.
+	skip := map[string]bool{}
+	for _, p := range []string{tmp.Name(), dest} {
+		if abs, err := filepath.Abs(p); err == nil {
+			skip[abs] = true
+		}
+	}
+	walk := func(add func(name, path string, info fs.FileInfo) error) error {
+		return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if abs, err := filepath.Abs(path); err == nil && skip[abs] {
+				return nil
+			}
+			rel, err := filepath.Rel(dir, path)
+			if err != nil {
+				return err
+			}
+			name := filepath.ToSlash(rel)
+			if prefix != "" {
+				name = prefix + "/" + name
+				if rel == "." {
+					name = prefix
+				}
+			} else if rel == "." {
+				return nil
+			}
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			if !info.IsDir() && !info.Mode().IsRegular() {
+				return fmt.Errorf("%s is not a regular file", path)
+			}
+			return add(name, path, info)
+		})
+	}
+
+	if format == Zip {
+		err = writeZip(tmp, walk)
+	} else {
+		err = writeTarGz(tmp, walk)
+	}
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), 0o644)
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), dest)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to archive %s: %w", dir, err)
+	}
+	return nil
+}
+
+// walker calls add for every directory and file to archive.
+type walker func(add func(name, path string, info fs.FileInfo) error) error
+
+func writeTarGz(w io.Writer, walk walker) error {
+	gw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gw)
+	err := walk(func(name, path string, info fs.FileInfo) error {
+		hdr, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		hdr.Name = name
+		if info.IsDir() {
+			hdr.Name += "/"
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		return copyFile(tw, path)
+	})
+	if err == nil {
+		err = tw.Close()
+	}
+	if err == nil {
+		err = gw.Close()
+	}
+	return err
+}
+
+func writeZip(w io.Writer, walk walker) error {
+	zw := zip.NewWriter(w)
+	err := walk(func(name, path string, info fs.FileInfo) error {
+		hdr, err := zip.FileInfoHeader(info)
+		if err != nil {
+			return err
+		}
+		hdr.Name = name
+		if info.IsDir() {
+			hdr.Name += "/"
+		} else {
+			hdr.Method = zip.Deflate
+		}
+		fw, err := zw.CreateHeader(hdr)
+		if err != nil || info.IsDir() {
+			return err
+		}
+		return copyFile(fw, path)
+	})
+	if err == nil {
+		err = zw.Close()
+	}
+	return err
+}
+
+// copyFile copies the file at path to w.
+func copyFile(w io.Writer, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(w, f)
+	return err
+}
@@ -0,0 +1,331 @@
+package manager
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/metal3-community/uefi-firmware-manager/audit"
+	"github.com/metal3-community/uefi-firmware-manager/efi"
+)
+
+// auditedManager records the changes made through a FirmwareManager.
+type auditedManager struct {
+	FirmwareManager
+	sink  audit.Sink
+	actor string
+	// saved maps variable keys to the hash of their data as last saved.
+	saved map[string]string
+	// reads records GetVariable and ListVariables calls too.
+	reads bool
+}
+
+// NewAuditedManager wraps m so that SetVariable, DeleteVariable and
+// SaveChanges calls are recorded to sink on behalf of actor. On save,
+// every variable that differs from the last saved state is also recorded,
+// including changes made by higher level methods such as SetBootOrder.
+// An operation whose event cannot be recorded returns the sink's error.
+func NewAuditedManager(m FirmwareManager, sink audit.Sink, actor string) (FirmwareManager, error) {
+	am := &auditedManager{FirmwareManager: m, sink: sink, actor: actor}
+	saved, err := am.hashes()
+	if err != nil {
+		return nil, err
+	}
+	am.saved = saved
+	return am, nil
+}
+
+// NewReadAuditedManager is like NewAuditedManager but also records the
+// GetVariable and ListVariables calls, for sites that audit who read the
+// firmware configuration.
+func NewReadAuditedManager(m FirmwareManager, sink audit.Sink, actor string) (FirmwareManager, error) {
+	fm, err := NewAuditedManager(m, sink, actor)
+	if err != nil {
+		return nil, err
+	}
+	fm.(*auditedManager).reads = true
+	return fm, nil
+}
+
+// hashes returns the data hashes of all variables by key.
+func (am *auditedManager) hashes() (map[string]string, error) {
+	vars, err := am.FirmwareManager.ListVariables()
+	if err != nil {
+		return nil, err
+	}
+	hashes := make(map[string]string, len(vars))
+	for name, v := range vars {
+		hashes[name] = audit.Hash(v.Data)
+	}
+	return hashes, nil
+}
+
+func (am *auditedManager) record(e audit.Event, opErr error) error {
+	e.Time = time.Now().UTC()
+	e.Actor = am.actor
+	if opErr != nil {
+		e.Error = opErr.Error()
+	}
+	if err := am.sink.Record(e); err != nil {
+		if opErr != nil {
+			return opErr
+		}
+		return fmt.Errorf("failed to record audit event: %w", err)
+	}
+	return opErr
+}
+
+// currentHash returns the hash of the data of the named variable, or ""
+// if it does not exist.
+func (am *auditedManager) currentHash(name string) string {
+	v, err := am.FirmwareManager.GetVariable(name)
+	if err != nil {
+		return ""
+	}
+	return audit.Hash(v.Data)
+}
+
+// GetVariable returns a variable, recording the read if reads are
+// audited.
+func (am *auditedManager) GetVariable(name string) (*efi.EfiVar, error) {
+	v, err := am.FirmwareManager.GetVariable(name)
+	if !am.reads {
+		return v, err
+	}
+	e := audit.Event{Action: audit.ActionRead, Variable: name}
+	if err == nil {
+		e.After = audit.Hash(v.Data)
+	}
+	if err := am.record(e, err); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// ListVariables returns all variables, recording the read if reads are
+// audited.
+func (am *auditedManager) ListVariables() (map[string]*efi.EfiVar, error) {
+	vars, err := am.FirmwareManager.ListVariables()
+	if !am.reads {
+		return vars, err
+	}
+	if err := am.record(audit.Event{Action: audit.ActionRead}, err); err != nil {
+		return nil, err
+	}
+	return vars, nil
+}
+
+// SetVariable sets a variable and records the change.
+func (am *auditedManager) SetVariable(name string, value *efi.EfiVar) error {
+	e := audit.Event{Action: audit.ActionSet, Variable: name, Before: am.currentHash(name)}
+	err := am.FirmwareManager.SetVariable(name, value)
+	if err == nil {
+		e.After = audit.Hash(value.Data)
+	}
+	return am.record(e, err)
+}
+
+// DeleteVariable deletes a variable and records the change.
+func (am *auditedManager) DeleteVariable(name string) error {
+	e := audit.Event{Action: audit.ActionDelete, Variable: name, Before: am.currentHash(name)}
+	err := am.FirmwareManager.DeleteVariable(name)
+	return am.record(e, err)
+}
+
+// SaveChanges records every variable changed since the last save, then
+// saves.
+func (am *auditedManager) SaveChanges() error {
+	current, err := am.hashes()
+	if err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(current))
+	for name := range current {
+		names = append(names, name)
+	}
+	for name := range am.saved {
+		if _, found := current[name]; !found {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		before, after := am.saved[name], current[name]
+		if before == after {
+			continue
+		}
+		e := audit.Event{Action: audit.ActionChange, Variable: name, Before: before, After: after}
+		if err := am.record(e, nil); err != nil {
+			return err
+		}
+	}
+
+	err = am.FirmwareManager.SaveChanges()
+	if err == nil {
+		am.saved = current
+	}
+	return am.record(audit.Event{Action: audit.ActionSave}, err)
+}
+
+// auditQueueSize is the number of serve events that can wait for the
+// audit sink before further events are dropped.
+const auditQueueSize = 1024
+
+// SetAuditSink makes ServeFirmware record an audit.ActionServe event for
+// every request to sink. Serve events are queued and recorded in the
+// background, so a slow sink does not delay boots; events that do not fit
+// in the queue, or cannot be recorded, are logged and dropped. Setting
+// another sink, or nil, waits for the events queued for the previous one.
+// Set it before serving firmware.
+func (sm *SimpleFirmwareManager) SetAuditSink(sink audit.Sink) {
+	if sm.auditQueue != nil {
+		sm.auditQueue.close()
+		sm.auditQueue = nil
+	}
+	sm.auditSink = sink
+	if sink != nil {
+		sm.auditQueue = newAuditQueue(sink, auditQueueSize, sm.logger)
+	}
+}
+
+// closeAudit waits for the requests in flight to queue their serve events
+// and for the events to be recorded, and stops the audit queue. Later
+// requests are not audited.
+func (sm *SimpleFirmwareManager) closeAudit() {
+	if sm.auditQueue != nil {
+		sm.auditQueue.close()
+	}
+}
+
+// recordServe queues, on q, the event of the response w made to r for the
+// firmware of macAddr.
+func recordServe(q *auditQueue, w *statusWriter, r *http.Request, macAddr net.HardwareAddr) {
+	e := audit.Event{
+		Time:       time.Now().UTC(),
+		Action:     audit.ActionServe,
+		RemoteAddr: r.RemoteAddr,
+		Status:     w.status,
+	}
+	if macAddr != nil {
+		e.MacAddress = macAddr.String()
+	}
+	if w.status >= 400 {
+		e.Error = http.StatusText(w.status)
+	}
+	q.end(e)
+}
+
+// auditQueue records events to a sink in the background.
+type auditQueue struct {
+	sink   audit.Sink
+	logger logr.Logger
+	events chan audit.Event
+	done   chan struct{}
+
+	// mu guards closed, which stops begin from adding to pending, the
+	// requests whose events close waits for.
+	mu      sync.Mutex
+	closed  bool
+	pending sync.WaitGroup
+}
+
+// newAuditQueue starts recording the events queued, up to size at a time,
+// to sink.
+func newAuditQueue(sink audit.Sink, size int, logger logr.Logger) *auditQueue {
+	q := &auditQueue{
+		sink:   sink,
+		logger: logger,
+		events: make(chan audit.Event, size),
+		done:   make(chan struct{}),
+	}
+	go q.run()
+	return q
+}
+
+func (q *auditQueue) run() {
+	defer close(q.done)
+	for e := range q.events {
+		if err := q.sink.Record(e); err != nil {
+			q.logger.Error(err, "failed to record audit event", "action", e.Action, "mac", e.MacAddress)
+		}
+	}
+}
+
+// record queues e, or drops it if the queue is full.
+func (q *auditQueue) record(e audit.Event) {
+	select {
+	case q.events <- e:
+	default:
+		q.logger.Info("audit queue full, dropping event", "action", e.Action, "mac", e.MacAddress)
+	}
+}
+
+// begin registers a request that will queue its event with end. It
+// returns false once the queue is closed.
+func (q *auditQueue) begin() bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return false
+	}
+	q.pending.Add(1)
+	return true
+}
+
+// end queues e for a request registered with begin.
+func (q *auditQueue) end(e audit.Event) {
+	q.record(e)
+	q.pending.Done()
+}
+
+// close waits for the requests registered with begin to end and for the
+// queued events to be recorded. Nothing may be recorded after it.
+func (q *auditQueue) close() {
+	q.mu.Lock()
+	closed := q.closed
+	q.closed = true
+	q.mu.Unlock()
+	if !closed {
+		q.pending.Wait()
+		close(q.events)
+	}
+	<-q.done
+}
+
+// recordAdmin records the admin request r, made by the Principal of its
+// context, to the audit sink, if one is set. Events that cannot be
+// recorded are logged.
+func (sm *SimpleFirmwareManager) recordAdmin(r *http.Request, e audit.Event, opErr error) {
+	if sm.auditSink == nil {
+		return
+	}
+	e.Time = time.Now().UTC()
+	e.RemoteAddr = r.RemoteAddr
+	if p, ok := PrincipalFromContext(r.Context()); ok {
+		e.Actor = p.Subject
+	}
+	if opErr != nil {
+		e.Error = opErr.Error()
+	}
+	if err := sm.auditSink.Record(e); err != nil {
+		sm.logger.Error(err, "failed to record audit event", "action", e.Action)
+	}
+}
+
+// statusWriter records the status of a response.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+// WriteHeader records status and writes it.
+func (w *statusWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
@@ -0,0 +1,223 @@
+package manager
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/metal3-community/uefi-firmware-manager/audit"
+	"github.com/metal3-community/uefi-firmware-manager/efi"
+)
+
+func TestAuditedManager(t *testing.T) {
+	fixture := newFixtureManager(t)
+	var events []audit.Event
+	sink := audit.SinkFunc(func(e audit.Event) error {
+		events = append(events, e)
+		return nil
+	})
+	m, err := NewAuditedManager(fixture, sink, "ops")
+	if err != nil {
+		t.Fatalf("NewAuditedManager() error = %v", err)
+	}
+
+	var bootNextBefore string
+	if v, err := m.GetVariable("BootNext"); err == nil {
+		bootNextBefore = audit.Hash(v.Data)
+	}
+	timeout, err := m.GetVariable("Timeout")
+	if err != nil {
+		t.Fatal(err)
+	}
+	before := audit.Hash(timeout.Data)
+	updated := *timeout
+	updated.Data = []byte{0x07, 0x00}
+	if err := m.SetVariable("Timeout", &updated); err != nil {
+		t.Fatal(err)
+	}
+	assetTag, err := m.GetVariable("AssetTag")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.DeleteVariable("AssetTag"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.DeleteVariable("NoSuchVariable"); err == nil {
+		t.Error("DeleteVariable() deleted a missing variable")
+	}
+	// Not made through SetVariable, but recorded on save.
+	if err := m.SetBootNext(1); err != nil {
+		t.Fatal(err)
+	}
+	bootNext := fixture.varList.ByName()["BootNext"]
+	if err := m.SaveChanges(); err != nil {
+		t.Fatalf("SaveChanges() error = %v", err)
+	}
+
+	want := []audit.Event{
+		{Action: audit.ActionSet, Variable: "Timeout", Before: before, After: audit.Hash(updated.Data)},
+		{Action: audit.ActionDelete, Variable: "AssetTag", Before: audit.Hash(assetTag.Data)},
+		{Action: audit.ActionDelete, Variable: "NoSuchVariable", Error: "variable not found: NoSuchVariable"},
+		{Action: audit.ActionChange, Variable: "AssetTag", Before: audit.Hash(assetTag.Data)},
+		{Action: audit.ActionChange, Variable: "BootNext", Before: bootNextBefore, After: audit.Hash(bootNext.Data)},
+		{Action: audit.ActionChange, Variable: "Timeout", Before: before, After: audit.Hash(updated.Data)},
+		{Action: audit.ActionSave},
+	}
+	if len(events) != len(want) {
+		t.Fatalf("recorded %d events, want %d: %+v", len(events), len(want), events)
+	}
+	for i, e := range events {
+		if e.Actor != "ops" || e.Time.IsZero() {
+			t.Errorf("event %d actor = %q, time = %v", i, e.Actor, e.Time)
+		}
+		e.Actor, e.Time = "", want[i].Time
+		if e != want[i] {
+			t.Errorf("event %d = %+v, want %+v", i, e, want[i])
+		}
+	}
+
+	events = nil
+	if err := m.SaveChanges(); err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || events[0].Action != audit.ActionSave {
+		t.Errorf("second save recorded %+v, want only the save", events)
+	}
+
+	failing := audit.SinkFunc(func(audit.Event) error { return errors.New("disk full") })
+	m, err = NewAuditedManager(fixture, failing, "ops")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = m.SetVariable("Timeout", &efi.EfiVar{Guid: timeout.Guid, Attr: timeout.Attr, Data: []byte{0x01, 0x00}})
+	if err == nil {
+		t.Error("SetVariable() succeeded although the event was not recorded")
+	}
+}
+
+func TestReadAuditedManager(t *testing.T) {
+	var events []audit.Event
+	sink := audit.SinkFunc(func(e audit.Event) error {
+		events = append(events, e)
+		return nil
+	})
+	m, err := NewReadAuditedManager(newFixtureManager(t), sink, "ops")
+	if err != nil {
+		t.Fatal(err)
+	}
+	timeout, err := m.GetVariable("Timeout")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.GetVariable("NoSuchVariable"); err == nil {
+		t.Error("GetVariable() found a missing variable")
+	}
+	if _, err := m.ListVariables(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []audit.Event{
+		{Action: audit.ActionRead, Variable: "Timeout", After: audit.Hash(timeout.Data)},
+		{Action: audit.ActionRead, Variable: "NoSuchVariable", Error: events[1].Error},
+		{Action: audit.ActionRead},
+	}
+	if len(events) != len(want) || events[1].Error == "" {
+		t.Fatalf("recorded %+v", events)
+	}
+	for i, e := range events {
+		e.Actor, e.Time = "", want[i].Time
+		if e != want[i] {
+			t.Errorf("event %d = %+v, want %+v", i, e, want[i])
+		}
+	}
+}
+
+func TestSimpleFirmwareManager_AuditSink(t *testing.T) {
+	mgr, err := NewSimpleFirmwareManager(logr.Discard())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var events []audit.Event
+	mgr.SetAuditSink(audit.SinkFunc(func(e audit.Event) error {
+		events = append(events, e)
+		return nil
+	}))
+	mac, _ := net.ParseMAC("d8:3a:dd:61:4d:15")
+	serveFirmware(mgr, mac, http.MethodHead, nil)
+	serveFirmware(mgr, nil, http.MethodPost, nil)
+	// Unsetting the sink waits for the queued events.
+	mgr.SetAuditSink(nil)
+
+	if len(events) != 2 {
+		t.Fatalf("recorded %+v", events)
+	}
+	if e := events[0]; e.Action != audit.ActionServe || e.MacAddress != mac.String() || e.Status != http.StatusOK ||
+		e.RemoteAddr == "" || e.Error != "" || e.Time.IsZero() {
+		t.Errorf("served event = %+v", e)
+	}
+	if e := events[1]; e.MacAddress != "" || e.Status != http.StatusMethodNotAllowed || e.Error == "" {
+		t.Errorf("refused event = %+v", e)
+	}
+}
+
+func TestAuditQueue_Full(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	var events []audit.Event
+	q := newAuditQueue(audit.SinkFunc(func(e audit.Event) error {
+		if len(events) == 0 {
+			close(started)
+			<-release
+		}
+		events = append(events, e)
+		return nil
+	}), 1, logr.Discard())
+
+	// The first event blocks the sink, the second fills the queue and the
+	// third is dropped rather than blocking the caller.
+	q.record(audit.Event{Variable: "first"})
+	<-started
+	q.record(audit.Event{Variable: "second"})
+	q.record(audit.Event{Variable: "third"})
+	close(release)
+	q.close()
+
+	if len(events) != 2 || events[0].Variable != "first" || events[1].Variable != "second" {
+		t.Errorf("recorded %+v", events)
+	}
+}
+
+func TestAuditQueue_Close(t *testing.T) {
+	var events []audit.Event
+	q := newAuditQueue(audit.SinkFunc(func(e audit.Event) error {
+		events = append(events, e)
+		return nil
+	}), 4, logr.Discard())
+
+	// Closing waits for the requests in flight to queue their events.
+	if !q.begin() {
+		t.Fatal("begin() on an open queue = false")
+	}
+	closed := make(chan struct{})
+	go func() {
+		q.close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+		t.Fatal("close() returned with a request in flight")
+	case <-time.After(20 * time.Millisecond):
+	}
+	q.end(audit.Event{Variable: "in flight"})
+	<-closed
+
+	if q.begin() {
+		t.Error("begin() on a closed queue = true")
+	}
+	q.close()
+	if len(events) != 1 || events[0].Variable != "in flight" {
+		t.Errorf("recorded %+v", events)
+	}
+}
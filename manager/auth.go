@@ -0,0 +1,98 @@
+package manager
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// Roles granted to the holders of the tokens a TokenValidator accepts.
+// RoleAdmin implies RoleRead.
+const (
+	// RoleRead may read firmware and configuration.
+	RoleRead = "read"
+	// RoleAdmin may also change them, for instance replace firmware or
+	// reload the configuration.
+	RoleAdmin = "admin"
+)
+
+// ErrUnauthorized is returned by a TokenValidator for a token it does not
+// accept.
+var ErrUnauthorized = errors.New("unauthorized")
+
+// Principal is the holder of a token.
+type Principal struct {
+	// Subject identifies the holder, for audit events.
+	Subject string
+	Roles   []string
+}
+
+// HasRole reports whether p was granted role.
+func (p Principal) HasRole(role string) bool {
+	return slices.Contains(p.Roles, role) || (role == RoleRead && slices.Contains(p.Roles, RoleAdmin))
+}
+
+// TokenValidator validates the bearer tokens of requests to the firmware
+// server, for instance against a static list or an OIDC provider.
+type TokenValidator interface {
+	// ValidateToken returns the holder of token, or an error wrapping
+	// ErrUnauthorized if the token is not accepted.
+	ValidateToken(ctx context.Context, token string) (Principal, error)
+}
+
+// StaticTokens is a TokenValidator accepting a fixed set of tokens,
+// mapped to their holders.
+type StaticTokens map[string]Principal
+
+// ValidateToken returns the holder of token.
+func (t StaticTokens) ValidateToken(_ context.Context, token string) (Principal, error) {
+	for known, p := range t {
+		if subtle.ConstantTimeCompare([]byte(known), []byte(token)) == 1 {
+			return p, nil
+		}
+	}
+	return Principal{}, ErrUnauthorized
+}
+
+type principalKey struct{}
+
+// PrincipalFromContext returns the holder of the token of the request of
+// ctx, as authorized by RequireRole.
+func PrincipalFromContext(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(Principal)
+	return p, ok
+}
+
+// RequireRole returns a handler passing the requests whose bearer token
+// validator accepts with role to next, with their Principal in the
+// context. Requests without a valid token get 401 Unauthorized and those
+// without the role 403 Forbidden. A nil validator refuses every request,
+// so that admin routes stay closed until authentication is configured.
+func RequireRole(validator TokenValidator, role string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if validator == nil {
+			http.Error(w, "authentication not configured", http.StatusForbidden)
+			return
+		}
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || token == "" {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="firmware"`)
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+		p, err := validator.ValidateToken(r.Context(), token)
+		if err != nil {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="firmware", error="invalid_token"`)
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+		if !p.HasRole(role) {
+			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), principalKey{}, p)))
+	})
+}
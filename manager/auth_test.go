@@ -0,0 +1,62 @@
+package manager
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// testTokens are the tokens of the tests of admin requests.
+var testTokens = StaticTokens{
+	"admin-token":  {Subject: "admin", Roles: []string{RoleAdmin}},
+	"reader-token": {Subject: "reader", Roles: []string{RoleRead}},
+}
+
+// adminRequest returns a request carrying the admin token of testTokens.
+func adminRequest(method, target string, body io.Reader) *http.Request {
+	r := httptest.NewRequest(method, target, body)
+	r.Header.Set("Authorization", "Bearer admin-token")
+	return r
+}
+
+func TestRequireRole(t *testing.T) {
+	var got Principal
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, _ = PrincipalFromContext(r.Context())
+	})
+	tests := []struct {
+		name          string
+		validator     TokenValidator
+		role          string
+		authorization string
+		want          int
+		subject       string
+	}{
+		{"no validator", nil, RoleRead, "Bearer admin-token", http.StatusForbidden, ""},
+		{"no token", testTokens, RoleRead, "", http.StatusUnauthorized, ""},
+		{"basic auth", testTokens, RoleRead, "Basic YWRtaW46YWRtaW4=", http.StatusUnauthorized, ""},
+		{"invalid token", testTokens, RoleRead, "Bearer guess", http.StatusUnauthorized, ""},
+		{"reader reads", testTokens, RoleRead, "Bearer reader-token", http.StatusOK, "reader"},
+		{"reader administers", testTokens, RoleAdmin, "Bearer reader-token", http.StatusForbidden, ""},
+		{"admin reads", testTokens, RoleRead, "Bearer admin-token", http.StatusOK, "admin"},
+		{"admin administers", testTokens, RoleAdmin, "Bearer admin-token", http.StatusOK, "admin"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got = Principal{}
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.authorization != "" {
+				r.Header.Set("Authorization", tt.authorization)
+			}
+			w := httptest.NewRecorder()
+			RequireRole(tt.validator, tt.role, next).ServeHTTP(w, r)
+			if w.Code != tt.want || got.Subject != tt.subject {
+				t.Errorf("status = %d, subject %q; want %d, %q", w.Code, got.Subject, tt.want, tt.subject)
+			}
+			if w.Code == http.StatusUnauthorized && w.Header().Get("WWW-Authenticate") == "" {
+				t.Error("401 without WWW-Authenticate")
+			}
+		})
+	}
+}
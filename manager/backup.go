@@ -0,0 +1,142 @@
+package manager
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/metal3-community/uefi-firmware-manager/types"
+	"github.com/metal3-community/uefi-firmware-manager/varstore"
+)
+
+const (
+	// backupSuffix separates the image name from the ID of its backups:
+	// RPI_EFI.fd.backup-<id>.
+	backupSuffix = ".backup-"
+	// backupIDFormat formats the creation time of a backup as its ID.
+	backupIDFormat = "20060102T150405.000Z"
+)
+
+// SetBackupRetention makes UpdateFirmware keep the last keep backups of
+// the firmware image, named <image>.backup-<id>, instead of removing its
+// backup once the image is written. Older backups are removed. Zero, the
+// default, keeps none.
+func (m *EDK2Manager) SetBackupRetention(keep int) {
+	m.backupRetention = max(keep, 0)
+}
+
+// ListBackups returns the backups of the firmware image, newest first.
+func (m *EDK2Manager) ListBackups() ([]types.FirmwareBackup, error) {
+	dir := filepath.Dir(m.firmwarePath)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read firmware directory: %w", err)
+	}
+	prefix := filepath.Base(m.firmwarePath) + backupSuffix
+	backups := []types.FirmwareBackup{}
+	for _, entry := range entries {
+		id, ok := strings.CutPrefix(entry.Name(), prefix)
+		if !ok || !entry.Type().IsRegular() {
+			continue
+		}
+		created, err := time.Parse(backupIDFormat, id)
+		if err != nil {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		backups = append(backups, types.FirmwareBackup{
+			ID:      id,
+			Path:    filepath.Join(dir, entry.Name()),
+			Created: created,
+			Size:    info.Size(),
+		})
+	}
+	sort.Slice(backups, func(i, j int) bool { return backups[i].Created.After(backups[j].Created) })
+	return backups, nil
+}
+
+// Restore replaces the firmware image with the backup backupID, as listed
+// by ListBackups, and reloads its variables. With a backup retention set,
+// the current image is backed up first, so the restore can be undone.
+func (m *EDK2Manager) Restore(backupID string) error {
+	if _, err := time.Parse(backupIDFormat, backupID); err != nil {
+		return fmt.Errorf("invalid backup ID %q", backupID)
+	}
+	backupPath := m.firmwarePath + backupSuffix + backupID
+	if _, err := os.Stat(backupPath); err != nil {
+		return fmt.Errorf("backup %s: %w", backupID, fs.ErrNotExist)
+	}
+
+	if m.backupRetention > 0 {
+		if _, err := m.backup(); err != nil {
+			return fmt.Errorf("failed to backup firmware: %w", err)
+		}
+	}
+	if err := copyFile(backupPath, m.firmwarePath); err != nil {
+		return fmt.Errorf("failed to restore backup %s: %w", backupID, err)
+	}
+	m.reopenVarStore()
+	if err := m.loadVarList(); err != nil {
+		return err
+	}
+	if m.backupRetention > 0 {
+		m.pruneBackups()
+	}
+
+	m.logger.Info("firmware restored from backup", "path", m.firmwarePath, "backup", backupID)
+	return nil
+}
+
+// backup copies the firmware image to a new backup and returns its path.
+func (m *EDK2Manager) backup() (string, error) {
+	now := time.Now().UTC()
+	for {
+		path := m.firmwarePath + backupSuffix + now.Format(backupIDFormat)
+		if _, err := os.Stat(path); err != nil {
+			return path, copyFile(m.firmwarePath, path)
+		}
+		// Backups within the same millisecond get the next free ID.
+		now = now.Add(time.Millisecond)
+	}
+}
+
+// pruneBackups removes the backups beyond the retention.
+func (m *EDK2Manager) pruneBackups() {
+	backups, err := m.ListBackups()
+	if err != nil {
+		m.logger.Error(err, "failed to list firmware backups")
+		return
+	}
+	for _, b := range backups[min(m.backupRetention, len(backups)):] {
+		if err := removeFile(b.Path); err != nil {
+			m.logger.Error(err, "failed to remove firmware backup", "path", b.Path)
+		}
+	}
+}
+
+// reopenVarStore reads the variable store from the firmware image again,
+// keeping the options of the current one.
+func (m *EDK2Manager) reopenVarStore() {
+	old := m.varStore
+	m.varStore = varstore.NewEdk2VarStore(m.firmwarePath)
+	inheritVarStoreOptions(m.varStore, old)
+}
+
+// inheritVarStoreOptions sets the options of vs to those of old.
+func inheritVarStoreOptions(vs, old *varstore.Edk2VarStore) {
+	vs.Options = old.Options
+	vs.Wipe = old.Wipe
+	vs.Recover = old.Recover
+	vs.Deterministic = old.Deterministic
+	vs.Mirror = old.Mirror
+	vs.LockTimeout = old.LockTimeout
+	vs.Metrics = old.Metrics
+	vs.Logger = old.Logger
+}
@@ -0,0 +1,68 @@
+package manager
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-logr/logr"
+	"github.com/metal3-community/uefi-firmware-manager/edk2"
+)
+
+func TestEDK2Manager_BackupRetention(t *testing.T) {
+	path := filepath.Join(t.TempDir(), edk2.FirmwareFileName)
+	if err := os.WriteFile(path, edk2.RpiEfi, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	fm, err := NewEDK2Manager(path, logr.Discard())
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := fm.(*EDK2Manager)
+
+	// Without a retention the backup is removed after the update.
+	if err := m.UpdateFirmware(nil); err != nil {
+		t.Fatalf("UpdateFirmware() error = %v", err)
+	}
+	if backups, err := m.ListBackups(); err != nil || len(backups) != 0 {
+		t.Fatalf("ListBackups() = %v, %v; want none", backups, err)
+	}
+
+	m.SetBackupRetention(2)
+	for _, seconds := range []int{1, 2, 3} {
+		if err := m.SetFirmwareTimeoutSeconds(seconds); err != nil {
+			t.Fatal(err)
+		}
+		if err := m.UpdateFirmware(nil); err != nil {
+			t.Fatalf("UpdateFirmware() error = %v", err)
+		}
+	}
+	backups, err := m.ListBackups()
+	if err != nil || len(backups) != 2 {
+		t.Fatalf("ListBackups() = %v, %v; want 2 backups", backups, err)
+	}
+	if !backups[0].Created.After(backups[1].Created) {
+		t.Errorf("ListBackups() is not newest first: %v", backups)
+	}
+
+	// The oldest kept backup was taken before the timeout was set to 2.
+	if err := m.Restore(backups[1].ID); err != nil {
+		t.Fatalf("Restore() error = %v", err)
+	}
+	if got, err := m.GetFirmwareTimeoutSeconds(); err != nil || got != 1 {
+		t.Errorf("GetFirmwareTimeoutSeconds() after Restore() = %d, %v; want 1", got, err)
+	}
+	after, err := m.ListBackups()
+	if err != nil || len(after) != 2 || after[0].ID == backups[0].ID {
+		t.Errorf("ListBackups() after Restore() = %v, %v; want the restored image backed up", after, err)
+	}
+
+	if err := m.Restore("20000101T000000.000Z"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Restore() of a missing backup error = %v, want fs.ErrNotExist", err)
+	}
+	if err := m.Restore("../" + edk2.FirmwareFileName); err == nil {
+		t.Error("Restore() of an invalid ID succeeded")
+	}
+}
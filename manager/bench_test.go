@@ -0,0 +1,162 @@
+package manager
+
+import (
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-logr/logr"
+	"github.com/metal3-community/uefi-firmware-manager/efi"
+	"github.com/metal3-community/uefi-firmware-manager/testutil"
+)
+
+// The cross-manager benchmarks run the same work on every backend, starting
+// from the same variables: a few boot entries built by testutil.VarList.
+//
+//   - Load reads the stored state of a host,
+//   - Patch adds a PXE boot option for the host and boots it next,
+//   - Serialize writes the patched state back.
+//
+// SimpleFirmwareManager always starts from the embedded RPI_EFI.fd, whose
+// varstore is empty, and patches and serializes in one call per request, so
+// its Serialize includes the patch. Compare with benchstat:
+//
+//	go test ./manager -run '^$' -bench BenchmarkManagers -count 10
+
+var benchMAC = net.HardwareAddr{0xd8, 0x3a, 0xdd, 0x61, 0x4d, 0x15}
+
+// benchPatch applies the patch of the benchmarks to m.
+func benchPatch(b *testing.B, m FirmwareManager) {
+	option, err := efi.NewPxeBootOption(benchMAC)
+	if err != nil {
+		b.Fatal(err)
+	}
+	if err := m.SetVariable(option.Name.String(), option); err != nil {
+		b.Fatalf("SetVariable() error = %v", err)
+	}
+	// JsonEDK2Manager does not implement SetBootNext, so set the variable.
+	if err := m.SetVariable("BootNext", bootNextTemplate); err != nil {
+		b.Fatalf("SetVariable(BootNext) error = %v", err)
+	}
+}
+
+func BenchmarkManagers(b *testing.B) {
+	varList := testutil.VarList(b, "UEFI Shell", "SD/MMC", "UEFI PXEv4", "UEFI HTTPv4")
+	image, err := testutil.Firmware(varList)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.Run("EDK2Manager", func(b *testing.B) {
+		path := filepath.Join(b.TempDir(), "VARS.fd")
+		if err := os.WriteFile(path, image, 0o644); err != nil {
+			b.Fatal(err)
+		}
+		load := func(b *testing.B) FirmwareManager {
+			m, err := NewEDK2Manager(path, logr.Discard())
+			if err != nil {
+				b.Fatalf("NewEDK2Manager() error = %v", err)
+			}
+			return m
+		}
+		b.Run("Load", func(b *testing.B) {
+			b.ReportAllocs()
+			for range b.N {
+				load(b)
+			}
+		})
+		m := load(b)
+		b.Run("Patch", func(b *testing.B) {
+			b.ReportAllocs()
+			for range b.N {
+				benchPatch(b, m)
+			}
+		})
+		b.Run("Serialize", func(b *testing.B) {
+			b.ReportAllocs()
+			for range b.N {
+				if err := m.SaveChanges(); err != nil {
+					b.Fatalf("SaveChanges() error = %v", err)
+				}
+			}
+		})
+	})
+
+	b.Run("SimpleFirmwareManager", func(b *testing.B) {
+		sm, err := NewSimpleFirmwareManager(logr.Discard())
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.Run("Load", func(b *testing.B) {
+			b.ReportAllocs()
+			for range b.N {
+				// Drop the cache so every iteration parses the image.
+				varstoreCache.Lock()
+				varstoreCache.vs, varstoreCache.varList = nil, nil
+				varstoreCache.Unlock()
+				if _, _, err := sm.getOrCreateVarstore(nil); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+		b.Run("Serialize", func(b *testing.B) {
+			b.ReportAllocs()
+			for range b.N {
+				reader, err := sm.GetFirmwareReader(benchMAC)
+				if err != nil {
+					b.Fatalf("GetFirmwareReader() error = %v", err)
+				}
+				if _, err := io.Copy(io.Discard, reader); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	})
+
+	b.Run("JsonEDK2Manager", func(b *testing.B) {
+		dir := b.TempDir()
+		data, err := varList.MarshalJSON()
+		if err != nil {
+			b.Fatal(err)
+		}
+		macDir := filepath.Join(dir, "d8-3a-dd-61-4d-15")
+		if err := os.MkdirAll(macDir, 0o755); err != nil {
+			b.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(macDir, jsonVarsFileName), data, 0o644); err != nil {
+			b.Fatal(err)
+		}
+		m, err := NewJsonEDK2Manager(dir, logr.Discard())
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.Run("Load", func(b *testing.B) {
+			b.ReportAllocs()
+			for range b.N {
+				if err := m.LoadMAC(benchMAC); err != nil {
+					b.Fatalf("LoadMAC() error = %v", err)
+				}
+			}
+		})
+		b.Run("Patch", func(b *testing.B) {
+			b.ReportAllocs()
+			for range b.N {
+				benchPatch(b, m)
+			}
+		})
+		b.Run("Serialize", func(b *testing.B) {
+			b.ReportAllocs()
+			for range b.N {
+				// SaveChanges skips unmodified variables.
+				b.StopTimer()
+				benchPatch(b, m)
+				b.StartTimer()
+				if err := m.SaveChanges(); err != nil {
+					b.Fatalf("SaveChanges() error = %v", err)
+				}
+			}
+		})
+	})
+}
@@ -0,0 +1,173 @@
+package manager
+
+import (
+	"encoding/hex"
+	"fmt"
+
+	"github.com/metal3-community/uefi-firmware-manager/efi"
+	"github.com/metal3-community/uefi-firmware-manager/types"
+)
+
+// toBootEntry converts a parsed load option to the public boot entry type.
+func toBootEntry(id uint16, entry *efi.BootEntry, position int) types.BootEntry {
+	return types.BootEntry{
+		ID:             fmt.Sprintf("%04X", id),
+		Name:           entry.Title.String(),
+		DevPath:        entry.DevicePath.String(),
+		Enabled:        (entry.Attr & efi.LOAD_OPTION_ACTIVE) != 0,
+		Position:       position,
+		Category:       bootCategory(entry.GetCategory()),
+		DevicePath:     devicePathNodes(&entry.DevicePath),
+		DeviceCategory: deviceCategory(&entry.DevicePath),
+	}
+}
+
+// Firmware files and devices recognised by deviceCategory.
+var (
+	shellFileGUID   = efi.StringToGUID("7c04a583-9e3e-4f1c-ad65-e05268d0b4d1")
+	uiAppFileGUID   = efi.StringToGUID("462caa21-7614-4503-836e-8ab6f4662331")
+	arasanSdhciGUID = efi.StringToGUID("100c2cfa-b586-4198-9b4c-1683d195b1da")
+)
+
+// Device path message subtypes used by deviceCategory that efi does not
+// name.
+const (
+	devSubTypeUSBClass = 0x0f
+	devSubTypeUSBWWID  = 0x10
+	devSubTypeNVMe     = 0x17
+	devSubTypeSD       = 0x1a
+	devSubTypeEMMC     = 0x1d
+)
+
+// deviceCategory classifies a boot entry by the nodes of its device path.
+// Network and USB nodes take precedence over the disk nodes that may
+// follow them.
+func deviceCategory(dp *efi.DevicePath) string {
+	category := types.BootDeviceUnknown
+	network, usb, disk := false, false, false
+	for _, elem := range dp.Elems() {
+		switch elem.Devtype {
+		case efi.DevTypeMessage:
+			switch elem.Subtype {
+			case efi.DevSubTypeURI:
+				return types.BootDeviceHTTP
+			case efi.DevSubTypeMAC, efi.DevSubTypeIPv4, efi.DevSubTypeIPv6:
+				network = true
+			case efi.DevSubTypeUSB, devSubTypeUSBClass, devSubTypeUSBWWID:
+				usb = true
+			case efi.DevSubTypeSCSI, efi.DevSubTypeSATA, devSubTypeNVMe, devSubTypeSD, devSubTypeEMMC:
+				disk = true
+			}
+		case efi.DevTypeMedia:
+			switch elem.Subtype {
+			case efi.DevSubTypeFVFilename:
+				if guid, err := efi.GUIDFromBytes(elem.Data); err == nil {
+					switch {
+					case guid.Equal(shellFileGUID):
+						category = types.BootDeviceShell
+					case guid.Equal(uiAppFileGUID):
+						category = types.BootDeviceUiApp
+					}
+				}
+			case efi.DevSubTypePartition, efi.DevSubTypeFilePath:
+				disk = true
+			}
+		case efi.DevTypeHardware:
+			if elem.Subtype == efi.DevSubTypeVendorHW {
+				guid, err := efi.GUIDFromBytes(elem.Data)
+				if err == nil && guid.Equal(arasanSdhciGUID) {
+					disk = true
+				}
+			}
+		}
+	}
+
+	switch {
+	case network:
+		return types.BootDevicePXE
+	case usb:
+		return types.BootDeviceUSB
+	case disk:
+		return types.BootDeviceDisk
+	}
+	return category
+}
+
+// bootCategory names the category bits of load option attributes.
+func bootCategory(category uint32) string {
+	switch category {
+	case efi.LOAD_OPTION_CATEGORY_BOOT:
+		return types.BootCategoryBoot
+	case efi.LOAD_OPTION_CATEGORY_APP:
+		return types.BootCategoryApp
+	}
+	return fmt.Sprintf("0x%04x", category)
+}
+
+// categoryBits returns the load option category bits for a category name.
+func categoryBits(category string) (uint32, error) {
+	switch category {
+	case types.BootCategoryBoot:
+		return efi.LOAD_OPTION_CATEGORY_BOOT, nil
+	case types.BootCategoryApp:
+		return efi.LOAD_OPTION_CATEGORY_APP, nil
+	}
+	return 0, fmt.Errorf("unknown boot entry category %q", category)
+}
+
+// devicePathNodes splits a device path into its nodes.
+func devicePathNodes(dp *efi.DevicePath) []types.DevicePathNode {
+	elems := dp.Elems()
+	nodes := make([]types.DevicePathNode, 0, len(elems))
+	for _, elem := range elems {
+		nodes = append(nodes, types.DevicePathNode{
+			Type:    uint8(elem.Devtype),
+			SubType: uint8(elem.Subtype),
+			Text:    elem.String(),
+			Data:    hex.EncodeToString(elem.Data),
+		})
+	}
+	return nodes
+}
+
+// devicePathFromNodes builds a device path from its nodes. Only the type,
+// subtype and data of each node are used.
+func devicePathFromNodes(nodes []types.DevicePathNode) (*efi.DevicePath, error) {
+	dp := &efi.DevicePath{}
+	for i, node := range nodes {
+		data, err := hex.DecodeString(node.Data)
+		if err != nil {
+			return nil, fmt.Errorf("invalid data in device path node %d: %w", i, err)
+		}
+		if len(data)+4 > 0xffff {
+			return nil, fmt.Errorf("device path node %d is too large", i)
+		}
+		dp.Append(&efi.DevicePathElem{
+			Devtype: efi.DeviceType(node.Type),
+			Subtype: efi.DeviceSubType(node.SubType),
+			Data:    data,
+		})
+	}
+	return dp, nil
+}
+
+// setBootEntry stores entry in v as a load option with the given
+// attributes, applying the entry's category if it has one.
+func setBootEntry(v *efi.EfiVar, attr uint32, entry types.BootEntry, optData []byte) error {
+	if entry.Category != "" {
+		category, err := categoryBits(entry.Category)
+		if err != nil {
+			return err
+		}
+		attr = attr&^uint32(efi.LOAD_OPTION_CATEGORY) | category
+	}
+
+	if entry.DevPath == "" && len(entry.DevicePath) > 0 {
+		dp, err := devicePathFromNodes(entry.DevicePath)
+		if err != nil {
+			return err
+		}
+		return v.SetBootEntryDevicePath(attr, entry.Name, dp, optData)
+	}
+	return v.SetBootEntry(attr, entry.Name, entry.DevPath, optData)
+}
@@ -0,0 +1,38 @@
+package manager
+
+import (
+	"fmt"
+
+	"github.com/metal3-community/uefi-firmware-manager/efi"
+	"github.com/metal3-community/uefi-firmware-manager/types"
+)
+
+// GetBootOptionSupport returns the load option features of the boot
+// manager. found is false if the firmware does not publish them, as images
+// of firmware that sets the variable at runtime do not.
+func GetBootOptionSupport(m FirmwareManager) (support efi.BootOptionSupport, found bool, err error) {
+	v, err := m.GetVariable(efi.VarKey(efi.BootOptionSupportVar, efi.EFI_GLOBAL_VARIABLE_GUID))
+	if err != nil {
+		return 0, false, nil
+	}
+	support, err = efi.NewBootOptionSupport(v.Data)
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to parse %s: %w", efi.BootOptionSupportVar, err)
+	}
+	return support, true, nil
+}
+
+// CheckBootEntrySupported returns an error wrapping ErrNotSupported if the
+// boot manager is known to ignore entry, such as an application entry on
+// firmware without application support. Without BootOptionSupport every
+// entry is accepted.
+func CheckBootEntrySupported(m FirmwareManager, entry types.BootEntry) error {
+	support, found, err := GetBootOptionSupport(m)
+	if err != nil || !found {
+		return err
+	}
+	if entry.Category == types.BootCategoryApp && !support.App() {
+		return fmt.Errorf("application boot entries: %w", ErrNotSupported)
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package manager
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/metal3-community/uefi-firmware-manager/efi"
+	"github.com/metal3-community/uefi-firmware-manager/types"
+)
+
+func TestCheckBootEntrySupported(t *testing.T) {
+	m := newFixtureManager(t)
+	dp := (&efi.DevicePath{}).FilePath(`\EFI\diag.efi`)
+	app := types.BootEntry{
+		Name:       "Diagnostics",
+		Enabled:    true,
+		Category:   types.BootCategoryApp,
+		DevicePath: devicePathNodes(dp),
+		Position:   -1,
+	}
+
+	if _, found, err := GetBootOptionSupport(m); found || err != nil {
+		t.Fatalf("GetBootOptionSupport() found = %v, %v, want not published", found, err)
+	}
+	if err := CheckBootEntrySupported(m, app); err != nil {
+		t.Errorf("CheckBootEntrySupported() without BootOptionSupport error = %v", err)
+	}
+
+	key := efi.VarKey(efi.BootOptionSupportVar, efi.EFI_GLOBAL_VARIABLE_GUID)
+	v := &efi.EfiVar{Attr: efi.EfiVariableDefault | efi.EfiVariableRuntimeAccess, Data: efi.BootOptionSupportKey.Bytes()}
+	if err := m.SetVariable(key, v); err != nil {
+		t.Fatal(err)
+	}
+	if support, found, err := GetBootOptionSupport(m); !found || err != nil || support != efi.BootOptionSupportKey {
+		t.Errorf("GetBootOptionSupport() = %s, %v, %v", support, found, err)
+	}
+	if err := m.AddBootEntry(app); !errors.Is(err, ErrNotSupported) {
+		t.Errorf("AddBootEntry() of application entry error = %v, want ErrNotSupported", err)
+	}
+	app.Category = types.BootCategoryBoot
+	if err := m.AddBootEntry(app); err != nil {
+		t.Errorf("AddBootEntry() of boot entry error = %v", err)
+	}
+}
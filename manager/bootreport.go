@@ -0,0 +1,120 @@
+package manager
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+
+	"github.com/go-logr/logr"
+	"github.com/metal3-community/uefi-firmware-manager/efi"
+	"github.com/metal3-community/uefi-firmware-manager/types"
+)
+
+// bootOptionColumns is the header of WriteBootOptionsCSV.
+var bootOptionColumns = []string{
+	"mac_address", "path", "id", "name", "category", "device_path", "active", "position",
+	"optional_data_size", "error",
+}
+
+// ListBootOptions returns a record for every Boot#### entry of m, ordered
+// by ID.
+func ListBootOptions(m FirmwareManager) ([]types.BootOptionRecord, error) {
+	varList, err := m.GetVarList()
+	if err != nil {
+		return nil, err
+	}
+	return bootOptionRecords(varList)
+}
+
+// ListDataDirBootOptions returns the boot option records of every host of
+// the data directory dir, read as for GenerateInventory, ordered by host
+// and ID. A host that cannot be read has a single record with an error.
+func ListDataDirBootOptions(dir string, logger logr.Logger) ([]types.BootOptionRecord, error) {
+	hosts, err := readDataDir(dir, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	var records []types.BootOptionRecord
+	for _, host := range hosts {
+		var list []types.BootOptionRecord
+		err := host.err
+		if err == nil {
+			list, err = bootOptionRecords(host.varList)
+		}
+		if err != nil {
+			logger.Error(err, "failed to read host firmware", "path", host.path)
+			list = []types.BootOptionRecord{{Error: err.Error()}}
+		}
+		for _, record := range list {
+			record.MacAddress = host.mac.String()
+			record.Path = host.path
+			records = append(records, record)
+		}
+	}
+	return records, nil
+}
+
+// bootOptionRecords describes the boot entries of varList.
+func bootOptionRecords(varList efi.EfiVarList) ([]types.BootOptionRecord, error) {
+	entries, err := varList.ListBootEntries()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list boot entries: %w", err)
+	}
+	var order []uint16
+	if orderVar, found := varList.Lookup(efi.BootOrder); found {
+		if order, err = orderVar.GetBootOrder(); err != nil {
+			return nil, fmt.Errorf("failed to parse boot order: %w", err)
+		}
+	}
+
+	ids := make([]uint16, 0, len(entries))
+	for id, entry := range entries {
+		if entry != nil {
+			ids = append(ids, id)
+		}
+	}
+	slices.Sort(ids)
+
+	records := make([]types.BootOptionRecord, 0, len(ids))
+	for _, id := range ids {
+		entry := entries[id]
+		records = append(records, types.BootOptionRecord{
+			ID:               fmt.Sprintf("%04X", id),
+			Name:             entry.Title.String(),
+			Category:         deviceCategory(&entry.DevicePath),
+			DevicePath:       entry.DevicePath.String(),
+			Active:           entry.Attr&efi.LOAD_OPTION_ACTIVE != 0,
+			Position:         slices.Index(order, id),
+			OptionalDataSize: len(entry.OptData),
+		})
+	}
+	return records, nil
+}
+
+// WriteBootOptionsCSV writes records to w as CSV with a header row. The
+// position of entries outside the boot order is left empty, as are the
+// entry fields of error records.
+func WriteBootOptionsCSV(w io.Writer, records []types.BootOptionRecord) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(bootOptionColumns); err != nil {
+		return err
+	}
+	for _, r := range records {
+		row := []string{r.MacAddress, r.Path, r.ID, r.Name, r.Category, r.DevicePath, "", "", "", r.Error}
+		if r.Error == "" {
+			row[6] = strconv.FormatBool(r.Active)
+			if r.Position >= 0 {
+				row[7] = strconv.Itoa(r.Position)
+			}
+			row[8] = strconv.Itoa(r.OptionalDataSize)
+		}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
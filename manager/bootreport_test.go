@@ -0,0 +1,94 @@
+package manager
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-logr/logr"
+	"github.com/metal3-community/uefi-firmware-manager/edk2"
+	"github.com/metal3-community/uefi-firmware-manager/efi"
+	"github.com/metal3-community/uefi-firmware-manager/testutil"
+	"github.com/metal3-community/uefi-firmware-manager/types"
+)
+
+func TestListDataDirBootOptions(t *testing.T) {
+	varList := testutil.VarList(t, "UEFI Shell", "UEFI PXEv4")
+	// An entry outside the boot order.
+	if err := varList.SetBootOrder([]uint16{1}); err != nil {
+		t.Fatal(err)
+	}
+	image, err := testutil.Firmware(varList)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	for name, data := range map[string][]byte{
+		"d8-3a-dd-5a-44-0c/" + edk2.FirmwareFileName: image,
+		"aa-bb-cc-dd-ee-ff/fw-vars.json":             []byte("{"),
+	} {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	records, err := ListDataDirBootOptions(dir, logr.Discard())
+	if err != nil {
+		t.Fatalf("ListDataDirBootOptions() error = %v", err)
+	}
+	if len(records) != 3 {
+		t.Fatalf("got %d records, want 3: %+v", len(records), records)
+	}
+	if r := records[0]; r.MacAddress != "aa:bb:cc:dd:ee:ff" || r.Error == "" {
+		t.Errorf("broken host record = %+v, want an error", r)
+	}
+	imagePath := filepath.Join(dir, "d8-3a-dd-5a-44-0c", edk2.FirmwareFileName)
+	want := types.BootOptionRecord{
+		MacAddress: "d8:3a:dd:5a:44:0c",
+		Path:       imagePath,
+		ID:         "0000",
+		Name:       "UEFI Shell",
+		Category:   types.BootDeviceDisk,
+		DevicePath: (&efi.DevicePath{}).FilePath(testutil.BootFile).String(),
+		Active:     true,
+		Position:   -1,
+	}
+	if records[1] != want {
+		t.Errorf("record = %+v, want %+v", records[1], want)
+	}
+	if records[2].ID != "0001" || records[2].Position != 0 {
+		t.Errorf("record = %+v, want Boot0001 first in the boot order", records[2])
+	}
+
+	var out bytes.Buffer
+	if err := WriteBootOptionsCSV(&out, records); err != nil {
+		t.Fatalf("WriteBootOptionsCSV() error = %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("CSV has %d lines, want 4:\n%s", len(lines), out.String())
+	}
+	if want := "d8:3a:dd:5a:44:0c," + imagePath + ",0000,UEFI Shell," + types.BootDeviceDisk; !strings.HasPrefix(lines[2], want) {
+		t.Errorf("CSV row = %q, want prefix %q", lines[2], want)
+	}
+	if !strings.HasSuffix(lines[2], ",true,,0,") {
+		t.Errorf("CSV row = %q, want an empty position", lines[2])
+	}
+}
+
+func TestListBootOptions(t *testing.T) {
+	records, err := ListBootOptions(newFixtureManager(t))
+	if err != nil {
+		t.Fatalf("ListBootOptions() error = %v", err)
+	}
+	if len(records) == 0 || records[0].ID != "0000" || records[0].MacAddress != "" {
+		t.Errorf("ListBootOptions() = %+v", records)
+	}
+}
@@ -0,0 +1,338 @@
+package manager
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+
+	"github.com/metal3-community/uefi-firmware-manager/efi"
+	"github.com/metal3-community/uefi-firmware-manager/types"
+)
+
+// errNoTargets is returned when a CompositeManager has nothing to manage.
+var errNoTargets = errors.New("composite manager has no targets")
+
+// TargetError is the error of one target of a CompositeManager.
+type TargetError struct {
+	// Index is the position of the target in the managers passed to
+	// NewCompositeManager.
+	Index int
+	Err   error
+}
+
+func (e *TargetError) Error() string {
+	return fmt.Sprintf("target %d: %v", e.Index, e.Err)
+}
+
+func (e *TargetError) Unwrap() error {
+	return e.Err
+}
+
+// CompositeManager applies every change to many managers at once, for
+// instance to set the same boot order on a fleet of hosts. Targets are
+// updated concurrently; the errors of failed targets are joined as
+// TargetErrors and the other targets keep the change.
+//
+// Getters return the state of the first target. Operations that depend on
+// per host state, such as ApplyProfile, should run through Each.
+type CompositeManager struct {
+	targets []FirmwareManager
+}
+
+// NewCompositeManager returns a manager applying changes to all of targets.
+func NewCompositeManager(targets []FirmwareManager) *CompositeManager {
+	return &CompositeManager{targets: targets}
+}
+
+// Targets returns the managed targets.
+func (c *CompositeManager) Targets() []FirmwareManager {
+	return c.targets
+}
+
+// Each calls fn concurrently for every target and joins the errors as
+// TargetErrors.
+func (c *CompositeManager) Each(fn func(i int, m FirmwareManager) error) error {
+	if len(c.targets) == 0 {
+		return errNoTargets
+	}
+	errs := make([]error, len(c.targets))
+	var wg sync.WaitGroup
+	for i, m := range c.targets {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := fn(i, m); err != nil {
+				errs[i] = &TargetError{Index: i, Err: err}
+			}
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// all calls fn for every target.
+func (c *CompositeManager) all(fn func(m FirmwareManager) error) error {
+	return c.Each(func(_ int, m FirmwareManager) error { return fn(m) })
+}
+
+// first returns the target getters read from.
+func (c *CompositeManager) first() (FirmwareManager, error) {
+	if len(c.targets) == 0 {
+		return nil, errNoTargets
+	}
+	return c.targets[0], nil
+}
+
+// cloneVar copies v and its data.
+func cloneVar(v *efi.EfiVar) *efi.EfiVar {
+	if v == nil {
+		return nil
+	}
+	clone := *v
+	clone.Data = bytes.Clone(v.Data)
+	return &clone
+}
+
+// cloneVarList copies l and its variables.
+func cloneVarList(l efi.EfiVarList) efi.EfiVarList {
+	clone := make(efi.EfiVarList, len(l))
+	for key, v := range l {
+		clone[key] = cloneVar(v)
+	}
+	return clone
+}
+
+// GetBootOrder returns the boot order of the first target.
+func (c *CompositeManager) GetBootOrder() ([]string, error) {
+	m, err := c.first()
+	if err != nil {
+		return nil, err
+	}
+	return m.GetBootOrder()
+}
+
+// SetBootOrder sets the boot order of every target.
+func (c *CompositeManager) SetBootOrder(order []string) error {
+	return c.all(func(m FirmwareManager) error { return m.SetBootOrder(order) })
+}
+
+// GetBootEntries returns the boot entries of the first target.
+func (c *CompositeManager) GetBootEntries() ([]types.BootEntry, error) {
+	m, err := c.first()
+	if err != nil {
+		return nil, err
+	}
+	return m.GetBootEntries()
+}
+
+// AddBootEntry adds the entry to every target.
+func (c *CompositeManager) AddBootEntry(entry types.BootEntry) error {
+	return c.all(func(m FirmwareManager) error { return m.AddBootEntry(entry) })
+}
+
+// UpdateBootEntry updates the entry on every target.
+func (c *CompositeManager) UpdateBootEntry(id string, entry types.BootEntry) error {
+	return c.all(func(m FirmwareManager) error { return m.UpdateBootEntry(id, entry) })
+}
+
+// DeleteBootEntry deletes the entry from every target.
+func (c *CompositeManager) DeleteBootEntry(id string) error {
+	return c.all(func(m FirmwareManager) error { return m.DeleteBootEntry(id) })
+}
+
+// GetVarList returns the variables of the first target.
+func (c *CompositeManager) GetVarList() (efi.EfiVarList, error) {
+	m, err := c.first()
+	if err != nil {
+		return nil, err
+	}
+	return m.GetVarList()
+}
+
+// SetBootLast sets the fallback boot entry of every target.
+func (c *CompositeManager) SetBootLast(entry types.BootEntry) error {
+	return c.all(func(m FirmwareManager) error { return m.SetBootLast(entry) })
+}
+
+// GetBootLast returns the fallback boot entry of the first target.
+func (c *CompositeManager) GetBootLast() (*types.BootEntry, error) {
+	m, err := c.first()
+	if err != nil {
+		return nil, err
+	}
+	return m.GetBootLast()
+}
+
+// SetBootNext sets BootNext on every target.
+func (c *CompositeManager) SetBootNext(index uint16) error {
+	return c.all(func(m FirmwareManager) error { return m.SetBootNext(index) })
+}
+
+// GetBootNext returns BootNext of the first target.
+func (c *CompositeManager) GetBootNext() (uint16, error) {
+	m, err := c.first()
+	if err != nil {
+		return 0, err
+	}
+	return m.GetBootNext()
+}
+
+// DeleteBootNext deletes BootNext from every target.
+func (c *CompositeManager) DeleteBootNext() error {
+	return c.all(func(m FirmwareManager) error { return m.DeleteBootNext() })
+}
+
+// GetNetworkSettings returns the network settings of the first target.
+func (c *CompositeManager) GetNetworkSettings() (types.NetworkSettings, error) {
+	m, err := c.first()
+	if err != nil {
+		return types.NetworkSettings{}, err
+	}
+	return m.GetNetworkSettings()
+}
+
+// SetNetworkSettings applies the settings to every target. Host specific
+// settings such as addresses are better applied through Each.
+func (c *CompositeManager) SetNetworkSettings(settings types.NetworkSettings) error {
+	return c.all(func(m FirmwareManager) error { return m.SetNetworkSettings(settings) })
+}
+
+// GetMacAddress returns the MAC address of the first target.
+func (c *CompositeManager) GetMacAddress() (net.HardwareAddr, error) {
+	m, err := c.first()
+	if err != nil {
+		return nil, err
+	}
+	return m.GetMacAddress()
+}
+
+// SetMacAddress is refused; hosts cannot share a MAC address.
+func (c *CompositeManager) SetMacAddress(mac net.HardwareAddr) error {
+	return fmt.Errorf("cannot set the same MAC address on %d targets", len(c.targets))
+}
+
+// GetVariable returns the variable of the first target.
+func (c *CompositeManager) GetVariable(name string) (*efi.EfiVar, error) {
+	m, err := c.first()
+	if err != nil {
+		return nil, err
+	}
+	return m.GetVariable(name)
+}
+
+// SetVariable sets a copy of the variable on every target.
+func (c *CompositeManager) SetVariable(name string, value *efi.EfiVar) error {
+	return c.all(func(m FirmwareManager) error { return m.SetVariable(name, cloneVar(value)) })
+}
+
+// DeleteVariable deletes the variable from every target.
+func (c *CompositeManager) DeleteVariable(name string) error {
+	return c.all(func(m FirmwareManager) error { return m.DeleteVariable(name) })
+}
+
+// ListVariables returns the variables of the first target.
+func (c *CompositeManager) ListVariables() (map[string]*efi.EfiVar, error) {
+	m, err := c.first()
+	if err != nil {
+		return nil, err
+	}
+	return m.ListVariables()
+}
+
+// GetVariableAsType returns the typed variable of the first target.
+func (c *CompositeManager) GetVariableAsType(name string) (any, error) {
+	m, err := c.first()
+	if err != nil {
+		return nil, err
+	}
+	return m.GetVariableAsType(name)
+}
+
+// ListVariablesWithTypes returns the typed variables of the first target.
+func (c *CompositeManager) ListVariablesWithTypes() (map[string]any, error) {
+	m, err := c.first()
+	if err != nil {
+		return nil, err
+	}
+	return m.ListVariablesWithTypes()
+}
+
+// SetVariableFromType sets the variable on every target.
+func (c *CompositeManager) SetVariableFromType(name string, value any) error {
+	return c.all(func(m FirmwareManager) error {
+		if v, ok := value.(*efi.EfiVar); ok {
+			return m.SetVariableFromType(name, cloneVar(v))
+		}
+		return m.SetVariableFromType(name, value)
+	})
+}
+
+// EnablePXEBoot enables or disables PXE boot on every target.
+func (c *CompositeManager) EnablePXEBoot(enable bool) error {
+	return c.all(func(m FirmwareManager) error { return m.EnablePXEBoot(enable) })
+}
+
+// EnableHTTPBoot enables or disables HTTP boot on every target.
+func (c *CompositeManager) EnableHTTPBoot(enable bool) error {
+	return c.all(func(m FirmwareManager) error { return m.EnableHTTPBoot(enable) })
+}
+
+// GetFirmwareTimeoutSeconds returns the boot menu timeout of the first
+// target.
+func (c *CompositeManager) GetFirmwareTimeoutSeconds() (int, error) {
+	m, err := c.first()
+	if err != nil {
+		return 0, err
+	}
+	return m.GetFirmwareTimeoutSeconds()
+}
+
+// SetFirmwareTimeoutSeconds sets the boot menu timeout of every target.
+func (c *CompositeManager) SetFirmwareTimeoutSeconds(seconds int) error {
+	return c.all(func(m FirmwareManager) error { return m.SetFirmwareTimeoutSeconds(seconds) })
+}
+
+// SetConsoleConfig sets the console of every target.
+func (c *CompositeManager) SetConsoleConfig(consoleName string, baudRate int) error {
+	return c.all(func(m FirmwareManager) error { return m.SetConsoleConfig(consoleName, baudRate) })
+}
+
+// GetSystemInfo returns the system information of the first target.
+func (c *CompositeManager) GetSystemInfo() (types.SystemInfo, error) {
+	m, err := c.first()
+	if err != nil {
+		return types.SystemInfo{}, err
+	}
+	return m.GetSystemInfo()
+}
+
+// UpdateFirmware updates the firmware of every target.
+func (c *CompositeManager) UpdateFirmware(firmwareData []byte) error {
+	return c.all(func(m FirmwareManager) error { return m.UpdateFirmware(firmwareData) })
+}
+
+// GetFirmwareVersion returns the firmware version of the first target.
+func (c *CompositeManager) GetFirmwareVersion() (string, error) {
+	m, err := c.first()
+	if err != nil {
+		return "", err
+	}
+	return m.GetFirmwareVersion()
+}
+
+// SaveChanges saves the changes of every target.
+func (c *CompositeManager) SaveChanges() error {
+	return c.all(func(m FirmwareManager) error { return m.SaveChanges() })
+}
+
+// RevertChanges discards the changes of every target.
+func (c *CompositeManager) RevertChanges() error {
+	return c.all(func(m FirmwareManager) error { return m.RevertChanges() })
+}
+
+// ResetToDefaults resets every target to its default settings.
+func (c *CompositeManager) ResetToDefaults() error {
+	return c.all(func(m FirmwareManager) error { return m.ResetToDefaults() })
+}
@@ -0,0 +1,61 @@
+package manager
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/go-logr/logr"
+	"github.com/metal3-community/uefi-firmware-manager/efi"
+)
+
+func TestCompositeManager(t *testing.T) {
+	ovmf, err := NewOVMFManager(newOVMFVars(t), logr.Discard())
+	if err != nil {
+		t.Fatal(err)
+	}
+	pis := []*EDK2Manager{newFixtureManager(t), newFixtureManager(t)}
+	c := NewCompositeManager([]FirmwareManager{pis[0], pis[1], ovmf})
+	var _ FirmwareManager = c
+
+	if err := c.SetFirmwareTimeoutSeconds(9); err != nil {
+		t.Fatalf("SetFirmwareTimeoutSeconds() error = %v", err)
+	}
+	for i, m := range c.Targets() {
+		v, err := m.GetVariable("Timeout")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, _ := v.GetUint16(); got != 9 {
+			t.Errorf("target %d Timeout = %d, want 9", i, got)
+		}
+	}
+
+	// Targets must not share the variable data.
+	v := &efi.EfiVar{Attr: efi.EfiVariableDefault, Data: []byte{0x01}}
+	if err := c.SetVariable("Flag-"+efi.LoaderInfo, v); err != nil {
+		t.Fatal(err)
+	}
+	v.Data[0] = 0x02
+	got, err := pis[1].GetVariable("Flag-" + efi.LoaderInfo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Data[0] != 0x01 {
+		t.Errorf("target 1 Flag = %x, want 01", got.Data)
+	}
+
+	err = c.SetConsoleConfig("serial", 115200)
+	var te *TargetError
+	if !errors.As(err, &te) || te.Index != 2 || !errors.Is(err, ErrNotSupported) {
+		t.Fatalf("SetConsoleConfig() error = %v, want the OVMF target to fail", err)
+	}
+	if pref, err := pis[0].GetVariable(consolePrefVar); err != nil {
+		t.Error(err)
+	} else if val, _ := pref.GetUint32(); val != 1 {
+		t.Errorf("target 0 ConsolePref = %d, want 1", val)
+	}
+
+	if _, err := NewCompositeManager(nil).GetBootOrder(); !errors.Is(err, errNoTargets) {
+		t.Errorf("GetBootOrder() on no targets error = %v, want %v", err, errNoTargets)
+	}
+}
@@ -0,0 +1,103 @@
+package manager
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-logr/logr"
+	"github.com/metal3-community/uefi-firmware-manager/atomicfile"
+	"github.com/metal3-community/uefi-firmware-manager/edk2"
+	"github.com/metal3-community/uefi-firmware-manager/filelock"
+	"github.com/metal3-community/uefi-firmware-manager/types"
+)
+
+const (
+	configTxtFileName = "config.txt"
+	// configBaseFileName keeps the upstream config.txt last installed in a
+	// firmware directory, the base of the next merge.
+	configBaseFileName = ".config.txt.base"
+)
+
+// UpdateConfigTxt installs the config.txt of a new firmware release in the
+// firmware directory dir. Local edits are kept: the changes upstream made
+// since the release last installed are merged into the local file, and
+// hunks edited on both sides keep the local lines and are returned as
+// conflicts. Directories without a recorded release are merged from the
+// embedded config.txt. The file is locked while it is merged.
+func UpdateConfigTxt(dir string, upstream []byte) ([]types.ConfigConflict, error) {
+	path := filepath.Join(dir, configTxtFileName)
+	unlock, err := filelock.Lock(path, filelock.DefaultTimeout)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	local, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		local = upstream
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read config.txt: %w", err)
+	}
+	base, err := os.ReadFile(filepath.Join(dir, configBaseFileName))
+	if errors.Is(err, fs.ErrNotExist) {
+		base = edk2.ConfigTxt
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read config.txt base: %w", err)
+	}
+
+	merged, conflicts := edk2.MergeConfigTxt(base, local, upstream)
+	if err := atomicfile.WriteFile(path, merged, 0o644); err != nil {
+		return nil, fmt.Errorf("failed to write config.txt: %w", err)
+	}
+	if err := atomicfile.WriteFile(filepath.Join(dir, configBaseFileName), upstream, 0o644); err != nil {
+		return nil, fmt.Errorf("failed to write config.txt base: %w", err)
+	}
+	return conflicts, nil
+}
+
+// UpdateDataDirConfigTxt runs UpdateConfigTxt for the config.txt of
+// dataDir and of each of its MAC directories, so that per-host
+// customizations survive a firmware update. Directories without a
+// config.txt are skipped. A directory that cannot be merged is left as is
+// and reported with an error.
+func UpdateDataDirConfigTxt(dataDir string, upstream []byte, logger logr.Logger) ([]types.ConfigMerge, error) {
+	entries, err := os.ReadDir(dataDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read data directory: %w", err)
+	}
+	dirs := []string{dataDir}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		if _, err := net.ParseMAC(strings.ReplaceAll(entry.Name(), "-", ":")); err == nil {
+			dirs = append(dirs, filepath.Join(dataDir, entry.Name()))
+		}
+	}
+
+	merges := []types.ConfigMerge{}
+	for _, dir := range dirs {
+		path := filepath.Join(dir, configTxtFileName)
+		if _, err := os.Stat(path); errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		merge := types.ConfigMerge{Path: path}
+		merge.Conflicts, err = UpdateConfigTxt(dir, upstream)
+		switch {
+		case err != nil:
+			logger.Error(err, "failed to update config.txt", "path", path)
+			merge.Error = err.Error()
+		case len(merge.Conflicts) > 0:
+			logger.Info("config.txt has conflicting local edits", "path", path, "conflicts", len(merge.Conflicts))
+		default:
+			logger.V(1).Info("Updated config.txt", "path", path)
+		}
+		merges = append(merges, merge)
+	}
+	return merges, nil
+}
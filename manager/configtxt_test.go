@@ -0,0 +1,106 @@
+package manager
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-logr/logr"
+)
+
+func TestUpdateConfigTxt(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, configTxtFileName)
+	v1 := []byte("arm_64bit=1\nenable_uart=1\n")
+	v2 := []byte("arm_64bit=1\nenable_uart=1\nenable_gic=1\n")
+	v3 := []byte("arm_64bit=1\nenable_gic=1\n")
+
+	// A directory without config.txt gets the upstream file.
+	if conflicts, err := UpdateConfigTxt(dir, v1); err != nil || len(conflicts) != 0 {
+		t.Fatalf("UpdateConfigTxt() = %v, %v", conflicts, err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != string(v1) {
+		t.Fatalf("config.txt = %q, want %q", data, v1)
+	}
+
+	// Local edits survive the next release.
+	if err := os.WriteFile(path, []byte("gpu_mem=16\narm_64bit=1\nenable_uart=1\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if conflicts, err := UpdateConfigTxt(dir, v2); err != nil || len(conflicts) != 0 {
+		t.Fatalf("UpdateConfigTxt() = %v, %v", conflicts, err)
+	}
+	want := "gpu_mem=16\narm_64bit=1\nenable_uart=1\nenable_gic=1\n"
+	if data, _ := os.ReadFile(path); string(data) != want {
+		t.Fatalf("config.txt = %q, want %q", data, want)
+	}
+
+	// The base is now v2, so the upstream removal of enable_uart applies.
+	if conflicts, err := UpdateConfigTxt(dir, v3); err != nil || len(conflicts) != 0 {
+		t.Fatalf("UpdateConfigTxt() = %v, %v", conflicts, err)
+	}
+	want = "gpu_mem=16\narm_64bit=1\nenable_gic=1\n"
+	if data, _ := os.ReadFile(path); string(data) != want {
+		t.Errorf("config.txt = %q, want %q", data, want)
+	}
+}
+
+func TestUpdateDataDirConfigTxt(t *testing.T) {
+	dataDir := t.TempDir()
+	base := []byte("arm_64bit=1\nenable_uart=1\n")
+	files := map[string]string{
+		"config.txt":                     "arm_64bit=1\nenable_uart=1\n",
+		"d8-3a-dd-5a-44-0c/config.txt":   "arm_64bit=1\nenable_uart=0\n",
+		"d8-3a-dd-5a-44-0d/config.txt":   "arm_64bit=1\nenable_uart=1\ngpu_mem=16\n",
+		"d8-3a-dd-5a-44-0e/fw-vars.json": "{}",
+		"lab/config.txt":                 "not a MAC directory\n",
+	}
+	for name, data := range files {
+		path := filepath.Join(dataDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if filepath.Base(path) == configTxtFileName {
+			if err := os.WriteFile(filepath.Join(filepath.Dir(path), configBaseFileName), base, 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	upstream := []byte("arm_64bit=1\nenable_uart=2\n")
+	merges, err := UpdateDataDirConfigTxt(dataDir, upstream, logr.Discard())
+	if err != nil {
+		t.Fatalf("UpdateDataDirConfigTxt() error = %v", err)
+	}
+	if len(merges) != 3 {
+		t.Fatalf("UpdateDataDirConfigTxt() = %+v, want 3 merges", merges)
+	}
+	conflicted := 0
+	for _, m := range merges {
+		if m.Error != "" {
+			t.Errorf("%s: %s", m.Path, m.Error)
+		}
+		conflicted += len(m.Conflicts)
+	}
+	if conflicted != 2 {
+		t.Errorf("UpdateDataDirConfigTxt() reported %d conflicts, want 2", conflicted)
+	}
+
+	for name, want := range map[string]string{
+		"config.txt":                   "arm_64bit=1\nenable_uart=2\n",
+		"d8-3a-dd-5a-44-0c/config.txt": "arm_64bit=1\nenable_uart=0\n",
+		"d8-3a-dd-5a-44-0d/config.txt": "arm_64bit=1\nenable_uart=1\ngpu_mem=16\n",
+		"lab/config.txt":               "not a MAC directory\n",
+	} {
+		data, err := os.ReadFile(filepath.Join(dataDir, filepath.FromSlash(name)))
+		if err != nil || string(data) != want {
+			t.Errorf("%s = %q, %v; want %q", name, data, err, want)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dataDir, "d8-3a-dd-5a-44-0e", configTxtFileName)); err == nil {
+		t.Error("UpdateDataDirConfigTxt() created config.txt in a directory without one")
+	}
+}
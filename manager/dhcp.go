@@ -0,0 +1,161 @@
+package manager
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+
+	"github.com/go-logr/logr"
+	"github.com/metal3-community/uefi-firmware-manager/types"
+)
+
+// DHCPFormat is the DHCP server configuration syntax of
+// WriteDHCPReservations.
+type DHCPFormat string
+
+const (
+	// DHCPFormatDnsmasq writes dhcp-host and dhcp-boot lines for a
+	// dnsmasq configuration file.
+	DHCPFormatDnsmasq DHCPFormat = "dnsmasq"
+	// DHCPFormatKea writes a JSON list for the reservations of a Kea
+	// subnet4.
+	DHCPFormatKea DHCPFormat = "kea"
+	// DHCPFormatISC writes host declarations for ISC dhcpd.
+	DHCPFormatISC DHCPFormat = "isc"
+)
+
+// httpClientVendorClass is the vendor class identifier of UEFI HTTP boot
+// clients.
+const httpClientVendorClass = "HTTPClient"
+
+// DHCPReservations returns a reservation for every host of inventory,
+// booting it over HTTP if HTTP boot is enabled in its firmware and opts
+// has an HTTP boot URL, and over TFTP otherwise. Hosts that could not be
+// read boot over TFTP.
+func DHCPReservations(inventory types.FirmwareInventory, opts types.DHCPBootOptions) ([]types.DHCPReservation, error) {
+	if opts.NextServer != "" {
+		if ip := net.ParseIP(opts.NextServer); ip == nil || ip.To4() == nil {
+			return nil, fmt.Errorf("next server %q is not an IPv4 address", opts.NextServer)
+		}
+	}
+
+	for _, v := range []string{opts.TFTPBootFile, opts.HTTPBootURL} {
+		if strings.ContainsFunc(v, func(c rune) bool { return c <= ' ' || strings.ContainsRune(`",\`, c) }) {
+			return nil, fmt.Errorf("boot file %q has a space, quote, comma or backslash", v)
+		}
+	}
+
+	reservations := make([]types.DHCPReservation, 0, len(inventory.Hosts))
+	for _, host := range inventory.Hosts {
+		mac, err := net.ParseMAC(host.MacAddress)
+		if err != nil {
+			return nil, fmt.Errorf("invalid MAC address %q: %w", host.MacAddress, err)
+		}
+		r := types.DHCPReservation{
+			MacAddress: mac.String(),
+			Name:       "rpi-" + strings.ReplaceAll(mac.String(), ":", ""),
+			NextServer: opts.NextServer,
+			BootFile:   opts.TFTPBootFile,
+		}
+		if host.HTTPBootEnabled && opts.HTTPBootURL != "" {
+			r.HTTPBoot = true
+			r.BootFile = strings.ReplaceAll(opts.HTTPBootURL, "{mac}", strings.ReplaceAll(mac.String(), ":", "-"))
+		}
+		reservations = append(reservations, r)
+	}
+	return reservations, nil
+}
+
+// ListDataDirDHCPReservations returns the reservations of the hosts of the
+// data directory dir, read as for GenerateInventory.
+func ListDataDirDHCPReservations(dir string, opts types.DHCPBootOptions, logger logr.Logger) ([]types.DHCPReservation, error) {
+	inventory, err := GenerateInventory(dir, logger)
+	if err != nil {
+		return nil, err
+	}
+	return DHCPReservations(inventory, opts)
+}
+
+// WriteDHCPReservations writes reservations to w in the syntax of format.
+func WriteDHCPReservations(w io.Writer, format DHCPFormat, reservations []types.DHCPReservation) error {
+	switch format {
+	case DHCPFormatDnsmasq:
+		return writeDnsmasqReservations(w, reservations)
+	case DHCPFormatKea:
+		return writeKeaReservations(w, reservations)
+	case DHCPFormatISC:
+		return writeISCReservations(w, reservations)
+	}
+	return fmt.Errorf("unknown DHCP format %q", format)
+}
+
+func writeDnsmasqReservations(w io.Writer, reservations []types.DHCPReservation) error {
+	var b strings.Builder
+	for _, r := range reservations {
+		fmt.Fprintf(&b, "dhcp-host=%s,set:%s\n", r.MacAddress, r.Name)
+		if r.HTTPBoot {
+			fmt.Fprintf(&b, "dhcp-option-force=tag:%s,60,%s\n", r.Name, httpClientVendorClass)
+		}
+		if r.BootFile != "" || r.NextServer != "" {
+			fmt.Fprintf(&b, "dhcp-boot=tag:%s,%s,,%s\n", r.Name, r.BootFile, r.NextServer)
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// keaReservation is a host reservation of a Kea subnet4.
+type keaReservation struct {
+	Hostname     string          `json:"hostname"`
+	HWAddress    string          `json:"hw-address"`
+	NextServer   string          `json:"next-server,omitempty"`
+	BootFileName string          `json:"boot-file-name,omitempty"`
+	OptionData   []keaOptionData `json:"option-data,omitempty"`
+}
+
+type keaOptionData struct {
+	Name string `json:"name"`
+	Data string `json:"data"`
+}
+
+func writeKeaReservations(w io.Writer, reservations []types.DHCPReservation) error {
+	list := make([]keaReservation, 0, len(reservations))
+	for _, r := range reservations {
+		k := keaReservation{
+			Hostname:     r.Name,
+			HWAddress:    r.MacAddress,
+			NextServer:   r.NextServer,
+			BootFileName: r.BootFile,
+		}
+		if r.HTTPBoot {
+			k.OptionData = []keaOptionData{{Name: "vendor-class-identifier", Data: httpClientVendorClass}}
+		}
+		list = append(list, k)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	return enc.Encode(list)
+}
+
+func writeISCReservations(w io.Writer, reservations []types.DHCPReservation) error {
+	var b strings.Builder
+	for _, r := range reservations {
+		fmt.Fprintf(&b, "host %s {\n", r.Name)
+		fmt.Fprintf(&b, "  hardware ethernet %s;\n", r.MacAddress)
+		if r.NextServer != "" {
+			fmt.Fprintf(&b, "  next-server %s;\n", r.NextServer)
+		}
+		if r.HTTPBoot {
+			fmt.Fprintf(&b, "  option vendor-class-identifier %q;\n", httpClientVendorClass)
+		}
+		if r.BootFile != "" {
+			fmt.Fprintf(&b, "  filename %q;\n", r.BootFile)
+		}
+		b.WriteString("}\n")
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,162 @@
+package manager
+
+import (
+	"bytes"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-logr/logr"
+	"github.com/metal3-community/uefi-firmware-manager/edk2"
+	"github.com/metal3-community/uefi-firmware-manager/efi"
+	"github.com/metal3-community/uefi-firmware-manager/testutil"
+	"github.com/metal3-community/uefi-firmware-manager/types"
+)
+
+var testDHCPReservations = []types.DHCPReservation{
+	{
+		MacAddress: "d8:3a:dd:01:02:03",
+		Name:       "rpi-d83add010203",
+		NextServer: "10.0.0.1",
+		BootFile:   "snp.efi",
+	},
+	{
+		MacAddress: "d8:3a:dd:01:02:04",
+		Name:       "rpi-d83add010204",
+		NextServer: "10.0.0.1",
+		BootFile:   "http://10.0.0.1/d8-3a-dd-01-02-04/RPI_EFI.fd",
+		HTTPBoot:   true,
+	},
+}
+
+func TestDHCPReservations(t *testing.T) {
+	inventory := types.FirmwareInventory{Hosts: []types.HostFirmware{
+		{MacAddress: "d8:3a:dd:01:02:03", PXEEnabled: true},
+		{MacAddress: "D8-3A-DD-01-02-04", HTTPBootEnabled: true},
+	}}
+	opts := types.DHCPBootOptions{
+		NextServer:   "10.0.0.1",
+		TFTPBootFile: "snp.efi",
+		HTTPBootURL:  "http://10.0.0.1/{mac}/RPI_EFI.fd",
+	}
+	got, err := DHCPReservations(inventory, opts)
+	if err != nil {
+		t.Fatalf("DHCPReservations() error = %v", err)
+	}
+	if len(got) != 2 || got[0] != testDHCPReservations[0] || got[1] != testDHCPReservations[1] {
+		t.Errorf("DHCPReservations() = %+v", got)
+	}
+
+	opts.HTTPBootURL = ""
+	if got, _ := DHCPReservations(inventory, opts); got[1].HTTPBoot || got[1].BootFile != "snp.efi" {
+		t.Errorf("DHCPReservations() without HTTP boot URL = %+v, want TFTP boot", got[1])
+	}
+
+	for _, opts := range []types.DHCPBootOptions{
+		{NextServer: "fd00::1"},
+		{NextServer: "tftp.example.com"},
+		{TFTPBootFile: "snp.efi,10.0.0.2"},
+		{HTTPBootURL: "http://x/\"{mac}"},
+	} {
+		if _, err := DHCPReservations(inventory, opts); err == nil {
+			t.Errorf("DHCPReservations(%+v) succeeded", opts)
+		}
+	}
+}
+
+func TestWriteDHCPReservations(t *testing.T) {
+	tests := []struct {
+		format DHCPFormat
+		want   string
+	}{
+		{DHCPFormatDnsmasq, `dhcp-host=d8:3a:dd:01:02:03,set:rpi-d83add010203
+dhcp-boot=tag:rpi-d83add010203,snp.efi,,10.0.0.1
+dhcp-host=d8:3a:dd:01:02:04,set:rpi-d83add010204
+dhcp-option-force=tag:rpi-d83add010204,60,HTTPClient
+dhcp-boot=tag:rpi-d83add010204,http://10.0.0.1/d8-3a-dd-01-02-04/RPI_EFI.fd,,10.0.0.1
+`},
+		{DHCPFormatKea, `[
+  {
+    "hostname": "rpi-d83add010203",
+    "hw-address": "d8:3a:dd:01:02:03",
+    "next-server": "10.0.0.1",
+    "boot-file-name": "snp.efi"
+  },
+  {
+    "hostname": "rpi-d83add010204",
+    "hw-address": "d8:3a:dd:01:02:04",
+    "next-server": "10.0.0.1",
+    "boot-file-name": "http://10.0.0.1/d8-3a-dd-01-02-04/RPI_EFI.fd",
+    "option-data": [
+      {
+        "name": "vendor-class-identifier",
+        "data": "HTTPClient"
+      }
+    ]
+  }
+]
+`},
+		{DHCPFormatISC, `host rpi-d83add010203 {
+  hardware ethernet d8:3a:dd:01:02:03;
+  next-server 10.0.0.1;
+  filename "snp.efi";
+}
+host rpi-d83add010204 {
+  hardware ethernet d8:3a:dd:01:02:04;
+  next-server 10.0.0.1;
+  option vendor-class-identifier "HTTPClient";
+  filename "http://10.0.0.1/d8-3a-dd-01-02-04/RPI_EFI.fd";
+}
+`},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.format), func(t *testing.T) {
+			var out bytes.Buffer
+			if err := WriteDHCPReservations(&out, tt.format, testDHCPReservations); err != nil {
+				t.Fatalf("WriteDHCPReservations() error = %v", err)
+			}
+			if out.String() != tt.want {
+				t.Errorf("WriteDHCPReservations() =\n%s\nwant\n%s", out.String(), tt.want)
+			}
+		})
+	}
+
+	if err := WriteDHCPReservations(&bytes.Buffer{}, "udhcpd", nil); err == nil {
+		t.Error("WriteDHCPReservations() with an unknown format succeeded")
+	}
+}
+
+func TestListDataDirDHCPReservations(t *testing.T) {
+	mac, _ := net.ParseMAC("d8:3a:dd:01:02:04")
+	varList := testutil.VarList(t, "UEFI Shell")
+	option, err := efi.NewHttpBootOption(mac, "http://10.0.0.1/boot.efi", efi.BootMetadata{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	varList.Set(option)
+	image, err := testutil.Firmware(varList)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "d8-3a-dd-01-02-04", edk2.FirmwareFileName)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, image, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := ListDataDirDHCPReservations(dir, types.DHCPBootOptions{
+		NextServer:  "10.0.0.1",
+		HTTPBootURL: "http://10.0.0.1/{mac}/RPI_EFI.fd",
+	}, logr.Discard())
+	if err != nil {
+		t.Fatalf("ListDataDirDHCPReservations() error = %v", err)
+	}
+	if len(got) != 1 || got[0] != testDHCPReservations[1] {
+		t.Errorf("ListDataDirDHCPReservations() = %+v", got)
+	}
+}
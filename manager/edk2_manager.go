@@ -1,6 +1,8 @@
 package manager
 
 import (
+	"bytes"
+	"crypto"
 	"encoding/hex"
 	"fmt"
 	"net"
@@ -12,6 +14,8 @@ import (
 	"time"
 
 	"github.com/go-logr/logr"
+	"github.com/metal3-community/uefi-firmware-manager/atomicfile"
+	"github.com/metal3-community/uefi-firmware-manager/dtb"
 	"github.com/metal3-community/uefi-firmware-manager/edk2"
 	"github.com/metal3-community/uefi-firmware-manager/efi"
 	"github.com/metal3-community/uefi-firmware-manager/types"
@@ -24,10 +28,30 @@ type EDK2Manager struct {
 	varStore     *varstore.Edk2VarStore
 	varList      efi.EfiVarList
 	logger       logr.Logger
+	signer       crypto.Signer
+	// backupRetention is how many backups UpdateFirmware keeps.
+	backupRetention int
+	// savedHashes maps variable names to their hashes as last loaded or
+	// saved.
+	savedHashes map[string]string
 }
 
-// NewEDK2Manager creates a new EDK2Manager for the given firmware file.
+// NewEDK2Manager creates a new EDK2Manager for the given firmware file. A
+// varstore with damaged records fails with varstore.ErrCorrupt; see
+// NewEDK2ManagerRecovering.
 func NewEDK2Manager(firmwarePath string, logger logr.Logger) (FirmwareManager, error) {
+	return newEDK2Manager(firmwarePath, false, logger)
+}
+
+// NewEDK2ManagerRecovering is NewEDK2Manager for a firmware file whose
+// varstore may be damaged: it skips the damaged records, logging the
+// regions dropped, and keeps the variables it can read. Saving the
+// firmware then drops the damaged records for good.
+func NewEDK2ManagerRecovering(firmwarePath string, logger logr.Logger) (FirmwareManager, error) {
+	return newEDK2Manager(firmwarePath, true, logger)
+}
+
+func newEDK2Manager(firmwarePath string, recoverDamaged bool, logger logr.Logger) (FirmwareManager, error) {
 	manager := &EDK2Manager{
 		firmwarePath: firmwarePath,
 		logger:       logger.WithName("edk2-manager"),
@@ -42,6 +66,12 @@ func NewEDK2Manager(firmwarePath string, logger logr.Logger) (FirmwareManager, e
 		}
 
 		for k, f := range edk2.Files {
+			if filepath.Ext(k) == ".dtb" {
+				if err := edk2.ValidateDtb(k, f); err != nil {
+					return nil, fmt.Errorf("refusing to install device tree: %w", err)
+				}
+			}
+
 			kf := filepath.Join(firmwareRoot, k)
 			kfr := filepath.Dir(kf)
 
@@ -60,20 +90,33 @@ func NewEDK2Manager(firmwarePath string, logger logr.Logger) (FirmwareManager, e
 	// Initialize the variable store
 	manager.varStore = varstore.NewEdk2VarStore(firmwarePath)
 	manager.varStore.Logger = logger.WithName("edk2-varstore")
+	manager.varStore.Recover = recoverDamaged
 
-	// Load the variable list
-	var err error
-	manager.varList, err = manager.varStore.GetVarList()
-	if err != nil {
-		return nil, fmt.Errorf("failed to get variable list: %w", err)
+	if err := manager.loadVarList(); err != nil {
+		return nil, err
 	}
 
 	return manager, nil
 }
 
+// loadVarList reads the variables of the varstore. A damaged one fails
+// with varstore.ErrCorrupt unless the varstore recovers what it can.
+func (m *EDK2Manager) loadVarList() error {
+	var err error
+	m.varList, err = m.varStore.GetVarList()
+	if err != nil {
+		return fmt.Errorf("failed to get variable list: %w", err)
+	}
+	for _, region := range m.varStore.Skipped() {
+		m.logger.Info("dropped damaged varstore region", "path", m.firmwarePath, "region", region.String())
+	}
+	m.savedHashes = varHashes(m.varList)
+	return nil
+}
+
 // GetBootOrder retrieves the boot order as a list of entry IDs.
 func (m *EDK2Manager) GetBootOrder() ([]string, error) {
-	bootOrderVar, found := m.varList[efi.BootOrder]
+	bootOrderVar, found := m.varList.Lookup(efi.BootOrder)
 	if !found {
 		return []string{}, nil
 	}
@@ -96,6 +139,9 @@ func (m *EDK2Manager) SetBootNext(index uint16) error {
 }
 
 func (m *EDK2Manager) SetBootLast(entry types.BootEntry) error {
+	if err := entry.Validate(); err != nil {
+		return fmt.Errorf("invalid boot entry: %w", err)
+	}
 	bootEntryName := "Boot0099"
 	// Create or update the boot entry variable
 	bootEntryVar := &efi.EfiVar{
@@ -113,36 +159,32 @@ func (m *EDK2Manager) SetBootLast(entry types.BootEntry) error {
 	}
 
 	// Set the boot entry with the specified title and device path
-	err := bootEntryVar.SetBootEntry(1, entry.Name, entry.DevPath, optData)
+	err := setBootEntry(bootEntryVar, 1, entry, optData)
 	if err != nil {
 		return fmt.Errorf("failed to set boot entry: %w", err)
 	}
 
 	// Add the entry to the variable list
-	m.varList[bootEntryName] = bootEntryVar
+	m.varList.Set(bootEntryVar)
 
 	return nil
 }
 
 func (m *EDK2Manager) GetBootLast() (*types.BootEntry, error) {
-	if bootEntryVar, found := m.varList["Boot0099"]; found {
+	if bootEntryVar, found := m.varList.Lookup("Boot0099"); found {
 		bootEntry, err := bootEntryVar.GetBootEntry()
 		if err != nil {
 			return nil, fmt.Errorf("failed to get boot entry: %w", err)
 		}
-		return &types.BootEntry{
-			ID:      fmt.Sprintf("%04X", 99),
-			Name:    bootEntry.Title.String(),
-			DevPath: bootEntry.DevicePath.String(),
-			Enabled: (bootEntry.Attr & efi.LOAD_OPTION_ACTIVE) != 0,
-			OptData: hex.EncodeToString(bootEntry.OptData),
-		}, nil
+		result := toBootEntry(99, bootEntry, 0)
+		result.OptData = hex.EncodeToString(bootEntry.OptData)
+		return &result, nil
 	}
 	return nil, fmt.Errorf("boot entry not found")
 }
 
 func (m *EDK2Manager) GetBootNext() (uint16, error) {
-	bootNextVar, found := m.varList[efi.BootNext]
+	bootNextVar, found := m.varList.Lookup(efi.BootNext)
 	if !found {
 		return 0, nil
 	}
@@ -171,7 +213,7 @@ func (m *EDK2Manager) SetBootOrder(order []string) error {
 	}
 
 	// Get or create the BootOrder variable
-	bootOrderVar, found := m.varList[efi.BootOrder]
+	bootOrderVar, found := m.varList.Lookup(efi.BootOrder)
 	if !found {
 		bootOrderVar = &efi.EfiVar{
 			Name: efi.NewUCS16String(efi.BootOrder),
@@ -180,7 +222,7 @@ func (m *EDK2Manager) SetBootOrder(order []string) error {
 				efi.EFI_VARIABLE_BOOTSERVICE_ACCESS |
 				efi.EFI_VARIABLE_RUNTIME_ACCESS,
 		}
-		m.varList[efi.BootOrder] = bootOrderVar
+		m.varList.Set(bootOrderVar)
 	}
 
 	// Set the new boot order
@@ -205,10 +247,9 @@ func (m *EDK2Manager) GetBootEntries() ([]types.BootEntry, error) {
 		}
 
 		position := 0
-		enabled := (entry.Attr & efi.LOAD_OPTION_ACTIVE) != 0
 
 		// Get position from boot order
-		bootOrderVar, found := m.varList[efi.BootOrder]
+		bootOrderVar, found := m.varList.Lookup(efi.BootOrder)
 		if found {
 			bootSequence, err := bootOrderVar.GetBootOrder()
 			if err == nil {
@@ -221,15 +262,7 @@ func (m *EDK2Manager) GetBootEntries() ([]types.BootEntry, error) {
 			}
 		}
 
-		bootEntry := types.BootEntry{
-			ID:       fmt.Sprintf("%04X", id),
-			Name:     entry.Title.String(),
-			DevPath:  entry.DevicePath.String(),
-			Enabled:  enabled,
-			Position: position,
-		}
-
-		result = append(result, bootEntry)
+		result = append(result, toBootEntry(id, entry, position))
 	}
 
 	return result, nil
@@ -237,10 +270,17 @@ func (m *EDK2Manager) GetBootEntries() ([]types.BootEntry, error) {
 
 // AddBootEntry adds a new boot entry to the firmware.
 func (m *EDK2Manager) AddBootEntry(entry types.BootEntry) error {
+	if err := entry.Validate(); err != nil {
+		return fmt.Errorf("invalid boot entry: %w", err)
+	}
+	if err := CheckBootEntrySupported(m, entry); err != nil {
+		return err
+	}
 	foundKey := false
 	// Find the next available boot entry ID
 	maxID := uint16(0)
-	for k := range m.varList {
+	for _, v := range m.varList {
+		k := v.Name.String()
 		if strings.HasPrefix(k, efi.BootPrefix) && len(k) == 8 {
 			foundKey = true
 			idStr := k[4:] // Extract the ID portion
@@ -282,13 +322,13 @@ func (m *EDK2Manager) AddBootEntry(entry types.BootEntry) error {
 	}
 
 	// Set the boot entry with the specified title and device path
-	err = bootEntryVar.SetBootEntry(attr, entry.Name, entry.DevPath, optData)
+	err = setBootEntry(bootEntryVar, attr, entry, optData)
 	if err != nil {
 		return fmt.Errorf("failed to set boot entry: %w", err)
 	}
 
 	// Add the entry to the variable list
-	m.varList[bootEntryName] = bootEntryVar
+	m.varList.Set(bootEntryVar)
 
 	// Update the boot order if position is specified
 	if entry.Position >= 0 {
@@ -318,13 +358,19 @@ func (m *EDK2Manager) AddBootEntry(entry types.BootEntry) error {
 
 // UpdateBootEntry updates an existing boot entry in the firmware.
 func (m *EDK2Manager) UpdateBootEntry(id string, entry types.BootEntry) error {
+	if err := entry.Validate(); err != nil {
+		return fmt.Errorf("invalid boot entry: %w", err)
+	}
+	if err := CheckBootEntrySupported(m, entry); err != nil {
+		return err
+	}
 	// Add "Boot" prefix if not present
 	if !strings.HasPrefix(id, efi.BootPrefix) {
 		id = efi.BootPrefix + id
 	}
 
 	// Check if the entry exists
-	bootEntryVar, found := m.varList[id]
+	bootEntryVar, found := m.varList.Lookup(id)
 	if !found {
 		return fmt.Errorf("boot entry not found: %s", id)
 	}
@@ -344,7 +390,7 @@ func (m *EDK2Manager) UpdateBootEntry(id string, entry types.BootEntry) error {
 	}
 
 	// Update the boot entry
-	err = bootEntryVar.SetBootEntry(attr, entry.Name, entry.DevPath, currentEntry.OptData)
+	err = setBootEntry(bootEntryVar, attr, entry, currentEntry.OptData)
 	if err != nil {
 		return fmt.Errorf("failed to update boot entry: %w", err)
 	}
@@ -401,7 +447,7 @@ func (m *EDK2Manager) DeleteBootEntry(id string) error {
 	}
 
 	// Check if the entry exists
-	_, found := m.varList[id]
+	_, found := m.varList.Lookup(id)
 	if !found {
 		return fmt.Errorf("boot entry not found: %s", id)
 	}
@@ -429,57 +475,35 @@ func (m *EDK2Manager) DeleteBootEntry(id string) error {
 	}
 
 	// Delete the entry from the variable list
-	delete(m.varList, id)
+	m.varList.Delete(id)
 
 	return nil
 }
 
 // GetNetworkSettings returns the current network settings.
 func (m *EDK2Manager) GetNetworkSettings() (types.NetworkSettings, error) {
-	settings := types.NetworkSettings{
-		EnableDHCP: true, // Default to DHCP enabled
-	}
-
-	// Get MAC address
+	// The MAC address is optional, without it only the address settings are
+	// unavailable.
 	macAddr, err := m.GetMacAddress()
-	if err == nil && macAddr != nil {
-		settings.MacAddress = macAddr.String()
-	}
-
-	// Get IPv6 enabled setting
-	ipv6Var, found := m.varList["IPv6Support"]
-	if found {
-		ipv6Enabled, err := ipv6Var.GetUint32()
-		if err == nil {
-			settings.EnableIPv6 = ipv6Enabled != 0
-		}
-	}
-
-	// Get VLAN settings
-	vlanVar, found := m.varList["VLANEnable"]
-	if found {
-		vlanEnabled, err := vlanVar.GetUint32()
-		if err == nil {
-			settings.VLANEnabled = vlanEnabled != 0
-		}
-	}
-
-	vlanIDVar, found := m.varList["VLANID"]
-	if found {
-		vlanID, err := vlanIDVar.GetUint32()
-		if err == nil {
-			settings.VLANID = fmt.Sprintf("%d", vlanID)
-		}
+	if err != nil {
+		macAddr = nil
 	}
 
-	return settings, nil
+	return networkSettings(m.varList, macAddr), nil
 }
 
 // SetNetworkSettings sets the network settings.
 func (m *EDK2Manager) SetNetworkSettings(settings types.NetworkSettings) error {
+	if err := settings.Validate(); err != nil {
+		return fmt.Errorf("invalid network settings: %w", err)
+	}
+
+	var mac net.HardwareAddr
+
 	// Set MAC address if provided
 	if settings.MacAddress != "" {
-		mac, err := net.ParseMAC(settings.MacAddress)
+		var err error
+		mac, err = net.ParseMAC(settings.MacAddress)
 		if err != nil {
 			return fmt.Errorf("invalid MAC address: %w", err)
 		}
@@ -487,27 +511,11 @@ func (m *EDK2Manager) SetNetworkSettings(settings types.NetworkSettings) error {
 		if err := m.SetMacAddress(mac); err != nil {
 			return fmt.Errorf("failed to set MAC address: %w", err)
 		}
+	} else if current, err := m.GetMacAddress(); err == nil {
+		mac = current
 	}
 
-	// Set IPv6 support
-	ipv6Var := m.getOrCreateVar("IPv6Support", efi.EFI_GLOBAL_VARIABLE)
-	ipv6Var.SetUint32(boolToUint32(settings.EnableIPv6))
-
-	// Set VLAN settings
-	vlanVar := m.getOrCreateVar("VLANEnable", efi.EFI_GLOBAL_VARIABLE)
-	vlanVar.SetUint32(boolToUint32(settings.VLANEnabled))
-
-	if settings.VLANEnabled && settings.VLANID != "" {
-		vlanID, err := strconv.ParseUint(settings.VLANID, 10, 32)
-		if err != nil {
-			return fmt.Errorf("invalid VLAN ID: %w", err)
-		}
-
-		vlanIDVar := m.getOrCreateVar("VLANID", efi.EFI_GLOBAL_VARIABLE)
-		vlanIDVar.SetUint32(uint32(vlanID))
-	}
-
-	return nil
+	return applyNetworkSettings(m.varList, mac, settings)
 }
 
 // GetMacAddress retrieves the MAC address from the firmware.
@@ -585,7 +593,7 @@ func (m *EDK2Manager) SetMacAddress(mac net.HardwareAddr) error {
 
 // GetVariable retrieves a variable by name.
 func (m *EDK2Manager) GetVariable(name string) (*efi.EfiVar, error) {
-	v, found := m.varList[name]
+	v, found := m.varList.Lookup(name)
 	if !found {
 		return nil, fmt.Errorf("variable not found: %s", name)
 	}
@@ -594,10 +602,10 @@ func (m *EDK2Manager) GetVariable(name string) (*efi.EfiVar, error) {
 
 // DeleteVariable removes a variable by name.
 func (m *EDK2Manager) DeleteVariable(name string) error {
-	if _, found := m.varList[name]; !found {
+	if _, found := m.varList.Lookup(name); !found {
 		return fmt.Errorf("variable not found: %s", name)
 	}
-	delete(m.varList, name)
+	m.varList.Delete(name)
 	return nil
 }
 
@@ -608,17 +616,17 @@ func (m *EDK2Manager) GetVarList() (efi.EfiVarList, error) {
 
 // GetVariableAsType retrieves a variable and converts it to a structured Go type based on its characteristics.
 func (m *EDK2Manager) GetVariableAsType(name string) (any, error) {
-	v, found := m.varList[name]
+	v, found := m.varList.Lookup(name)
 	if !found {
 		return nil, fmt.Errorf("variable not found: %s", name)
 	}
 
 	// Identify the variable type based on name patterns and GUID
-	return m.identifyAndConvertVariable(name, v)
+	return identifyAndConvertVariable(name, v)
 }
 
 // identifyAndConvertVariable identifies the type of EFI variable and converts it to appropriate Go type.
-func (m *EDK2Manager) identifyAndConvertVariable(name string, v *efi.EfiVar) (any, error) {
+func identifyAndConvertVariable(name string, v *efi.EfiVar) (any, error) {
 	guidStr := v.Guid.String()
 
 	// Check for MAC address-based IPv6 configuration (12-character hex MAC addresses)
@@ -691,6 +699,49 @@ func (m *EDK2Manager) identifyAndConvertVariable(name string, v *efi.EfiVar) (an
 		return keyData, nil
 	}
 
+	// TPM physical presence request and configuration
+	if name == efi.Tcg2PhysicalPresenceVar && guidStr == efi.EfiTcg2PhysicalPresence {
+		pp, err := efi.NewTcg2PhysicalPresence(v.Data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse TCG2 physical presence: %w", err)
+		}
+		return pp, nil
+	}
+	if name == efi.Tcg2ConfigurationVar && guidStr == efi.Tcg2ConfigFormSet {
+		config, err := efi.NewTcg2Configuration(v.Data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse TCG2 configuration: %w", err)
+		}
+		return config, nil
+	}
+
+	// Boot manager capabilities
+	if name == efi.BootOptionSupportVar && guidStr == efi.EFI_GLOBAL_VARIABLE {
+		support, err := efi.NewBootOptionSupport(v.Data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", name, err)
+		}
+		return support, nil
+	}
+
+	// OS requests to the firmware
+	if (name == efi.OsIndicationsVar || name == efi.OsIndicationsSupportedVar) && guidStr == efi.EFI_GLOBAL_VARIABLE {
+		ind, err := efi.NewOsIndications(v.Data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", name, err)
+		}
+		return ind, nil
+	}
+
+	// Memory map bin sizes
+	if name == efi.MemoryTypeInformationVar && guidStr == efi.EfiMemoryTypeInformation {
+		info, err := efi.NewMemoryTypeInformation(v.Data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", name, err)
+		}
+		return info, nil
+	}
+
 	// Asset Tag
 	if name == "AssetTag" {
 		assetTag, err := efi.NewAssetTag(v.Data)
@@ -713,12 +764,13 @@ func (m *EDK2Manager) identifyAndConvertVariable(name string, v *efi.EfiVar) (an
 	return v, nil
 }
 
-// ListVariablesWithTypes returns all variables with their converted Go types.
-func (m *EDK2Manager) ListVariablesWithTypes() (map[string]any, error) {
-	result := make(map[string]any)
-
-	for name, v := range m.varList {
-		convertedVar, err := m.identifyAndConvertVariable(name, v)
+// convertVariables converts every variable of vars with
+// identifyAndConvertVariable. Variables that fail to convert are returned
+// raw with the error.
+func convertVariables(vars map[string]*efi.EfiVar) map[string]any {
+	result := make(map[string]any, len(vars))
+	for name, v := range vars {
+		convertedVar, err := identifyAndConvertVariable(v.Name.String(), v)
 		if err != nil {
 			// If conversion fails, store the raw variable with error info
 			result[name] = map[string]any{
@@ -729,8 +781,12 @@ func (m *EDK2Manager) ListVariablesWithTypes() (map[string]any, error) {
 			result[name] = convertedVar
 		}
 	}
+	return result
+}
 
-	return result, nil
+// ListVariablesWithTypes returns all variables with their converted Go types.
+func (m *EDK2Manager) ListVariablesWithTypes() (map[string]any, error) {
+	return convertVariables(m.varList.ByName()), nil
 }
 
 // SetVariableFromType sets a variable from a structured Go type.
@@ -739,8 +795,7 @@ func (m *EDK2Manager) SetVariableFromType(name string, value any) error {
 	switch v := value.(type) {
 	case *efi.EfiVar:
 		// Direct EfiVar assignment
-		m.varList[name] = v
-		return nil
+		return m.SetVariable(name, v)
 	default:
 		return fmt.Errorf("unsupported variable type for direct assignment: %T. Only *efi.EfiVar is currently supported", value)
 	}
@@ -758,13 +813,29 @@ func (m *EDK2Manager) SetVariable(name string, value *efi.EfiVar) error {
 	if value == nil {
 		return fmt.Errorf("variable is nil")
 	}
-	m.varList[name] = value
+	setVarIdentity(name, value)
+	if err := value.Validate(); err != nil {
+		return err
+	}
+	// Live systems have no varstore image; the firmware checks the space.
+	if m.varStore != nil {
+		if err := m.varStore.CheckSet(m.varList, value); err != nil {
+			return err
+		}
+	}
+	m.varList.Set(value)
 	return nil
 }
 
-// ListVariables returns all variables in the firmware.
+// FreeSpace returns the number of bytes that would be left in the
+// varstore if the current variables were saved.
+func (m *EDK2Manager) FreeSpace() int {
+	return m.varStore.FreeSpaceFor(m.varList)
+}
+
+// ListVariables returns all variables in the firmware keyed by name.
 func (m *EDK2Manager) ListVariables() (map[string]*efi.EfiVar, error) {
-	return m.varList, nil
+	return m.varList.ByName(), nil
 }
 
 // EnablePXEBoot enables or disables PXE boot.
@@ -865,8 +936,27 @@ func (m *EDK2Manager) EnableHTTPBoot(enable bool) error {
 	return nil
 }
 
+// GetFirmwareTimeoutSeconds returns the boot menu timeout in seconds. It
+// fails if the firmware has no Timeout variable, in which case the firmware
+// uses its built-in default.
+func (m *EDK2Manager) GetFirmwareTimeoutSeconds() (int, error) {
+	timeoutVar, found := m.varList.Lookup("Timeout")
+	if !found {
+		return 0, fmt.Errorf("variable not found: %s", "Timeout")
+	}
+	timeout, err := timeoutVar.GetUint16()
+	if err != nil {
+		return 0, err
+	}
+	return int(timeout), nil
+}
+
 // SetFirmwareTimeoutSeconds sets the boot menu timeout in seconds.
 func (m *EDK2Manager) SetFirmwareTimeoutSeconds(seconds int) error {
+	if seconds < 0 || seconds > 0xffff {
+		return fmt.Errorf("timeout %d is not between 0 and 65535", seconds)
+	}
+
 	// The timeout is stored as a 16-bit value in the Timeout variable
 	timeoutVar := m.getOrCreateVar("Timeout", efi.EFI_GLOBAL_VARIABLE)
 
@@ -904,6 +994,9 @@ func (m *EDK2Manager) SetConsoleConfig(consoleName string, baudRate int) error {
 	return nil
 }
 
+// cpuClockCustom is the CpuClock setting that uses CustomCpuClock.
+const cpuClockCustom = 3
+
 // GetSystemInfo returns information about the system.
 func (m *EDK2Manager) GetSystemInfo() (types.SystemInfo, error) {
 	info := types.SystemInfo{}
@@ -911,60 +1004,143 @@ func (m *EDK2Manager) GetSystemInfo() (types.SystemInfo, error) {
 	// Add firmware version
 	version, err := m.GetFirmwareVersion()
 	if err == nil {
-		info["FirmwareVersion"] = version
+		info.FirmwareVersion = version
 	}
 
 	// Try to get asset tag
-	assetVar, found := m.varList["AssetTag"]
+	assetVar, found := m.varList.Lookup("AssetTag")
 	if found {
-		info["AssetTag"] = string(assetVar.Data)
+		info.AssetTag = string(assetVar.Data)
+	}
+
+	if timeout, err := m.GetFirmwareTimeoutSeconds(); err == nil {
+		info.TimeoutSeconds = &timeout
 	}
 
 	// Get CPU settings
-	cpuVar, found := m.varList["CpuClock"]
+	cpuVar, found := m.varList.Lookup("CpuClock")
 	if found {
 		cpuVal, err := cpuVar.GetUint32()
 		if err == nil {
-			info["CpuClock"] = fmt.Sprintf("%d", cpuVal)
+			info.CPUClock = &cpuVal
+		}
+	}
+	customVar, found := m.varList.Lookup("CustomCpuClock")
+	if found && info.CPUClock != nil && *info.CPUClock == cpuClockCustom {
+		mhz, err := customVar.GetUint32()
+		if err == nil {
+			info.CPUClockMHz = mhz
 		}
 	}
 
-	// Add RAM information
-	ramVar, found := m.varList["RamMoreThan3GB"]
+	// Add RAM information. Older firmware has RamMoreThan3GB, newer
+	// firmware RamLimitTo3GB.
+	ramVar, found := m.varList.Lookup("RamMoreThan3GB")
 	if found {
 		ramVal, err := ramVar.GetUint32()
 		if err == nil {
 			if ramVal != 0 {
-				info["RAM"] = "More than 3GB"
+				info.RAMLimit = types.RAMLimitNone
 			} else {
-				info["RAM"] = "3GB or less"
+				info.RAMLimit = types.RAMLimit3GB
+			}
+		}
+	}
+	limitVar, found := m.varList.Lookup("RamLimitTo3GB")
+	if found {
+		limitVal, err := limitVar.GetUint32()
+		if err == nil {
+			if limitVal != 0 {
+				info.RAMLimit = types.RAMLimit3GB
+			} else if info.RAMLimit == types.RAMLimitUnknown {
+				info.RAMLimit = types.RAMLimitNone
 			}
 		}
 	}
 
 	// Add system table mode
-	sysTableVar, found := m.varList["SystemTableMode"]
+	sysTableVar, found := m.varList.Lookup("SystemTableMode")
 	if found {
 		sysTableVal, err := sysTableVar.GetUint32()
 		if err == nil {
-			info["SystemTableMode"] = fmt.Sprintf("%d", sysTableVal)
+			info.SystemTableMode = &sysTableVal
 		}
 	}
 
+	m.addDeviceTreeInfo(&info)
+
 	return info, nil
 }
 
-// UpdateFirmware updates the firmware with the provided data.
+// addDeviceTreeInfo describes the board from the device trees installed
+// next to the firmware. A single dtb describes the board directly; with
+// several, each file is listed with its model.
+func (m *EDK2Manager) addDeviceTreeInfo(info *types.SystemInfo) {
+	// Managers without a firmware file have no device trees.
+	if m.firmwarePath == "" {
+		return
+	}
+	files, err := filepath.Glob(filepath.Join(filepath.Dir(m.firmwarePath), "*.dtb"))
+	if err != nil || len(files) == 0 {
+		return
+	}
+
+	models := map[string]string{}
+	var last *dtb.Info
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			m.logger.Error(err, "failed to read device tree", "path", file)
+			continue
+		}
+		dt, err := dtb.Parse(data)
+		if err != nil {
+			m.logger.Error(err, "failed to parse device tree", "path", file)
+			continue
+		}
+		models[filepath.Base(file)] = dt.Model
+		last = dt
+	}
+
+	switch {
+	case len(models) == 1:
+		info.BoardModel = last.Model
+		info.Compatible = last.Compatible
+		info.MemorySize = last.MemorySize
+	case len(models) > 1:
+		info.DeviceTrees = models
+	}
+}
+
+// UpdateFirmware replaces the firmware image with firmwareData, keeping the
+// current variables: they are written into the varstore of the new image,
+// which must have room for them. Nil firmwareData rewrites the current
+// image. The image is backed up and replaced atomically.
 func (m *EDK2Manager) UpdateFirmware(firmwareData []byte) error {
+	vs := m.varStore
+	if firmwareData != nil {
+		var err error
+		vs, err = varstore.New(bytes.Clone(firmwareData))
+		if err != nil {
+			return fmt.Errorf("failed to parse new firmware: %w", err)
+		}
+		inheritVarStoreOptions(vs, m.varStore)
+		if free := vs.FreeSpaceFor(m.varList); free < 0 {
+			return fmt.Errorf("variables do not fit the new firmware: %w, need %d more bytes",
+				varstore.ErrQuotaExceeded, -free)
+		}
+	}
+
 	// Backup the original firmware
-	backupPath := m.firmwarePath + ".backup"
-	if err := copyFile(m.firmwarePath, backupPath); err != nil {
+	backupPath, err := m.backup()
+	if err != nil {
 		return fmt.Errorf("failed to backup firmware: %w", err)
 	}
+	if m.backupRetention == 0 {
+		defer func() { _ = removeFile(backupPath) }()
+	}
 
-	defer func() { _ = removeFile(backupPath) }()
-
-	err := m.varStore.WriteVarStore(m.firmwarePath, m.varList)
+	err = vs.WriteVarStore(m.firmwarePath, m.varList)
 	if err != nil {
 		// Restore from backup if write fails
 		if restoreErr := copyFile(backupPath, m.firmwarePath); restoreErr != nil {
@@ -972,6 +1148,13 @@ func (m *EDK2Manager) UpdateFirmware(firmwareData []byte) error {
 		}
 		return fmt.Errorf("failed to write variable store: %w", err)
 	}
+	if firmwareData != nil {
+		m.reopenVarStore()
+	}
+	m.savedHashes = varHashes(m.varList)
+	if m.backupRetention > 0 {
+		m.pruneBackups()
+	}
 
 	m.logger.Info("firmware updated successfully", "path", m.firmwarePath)
 
@@ -984,11 +1167,20 @@ func (m *EDK2Manager) GetFirmwareVersion() (string, error) {
 	var version string
 
 	// Get the data from the FirmwareRevision variable if it exists
-	revVar, found := m.varList["FirmwareRevision"]
+	revVar, found := m.varList.Lookup("FirmwareRevision")
 	if found {
 		version = string(revVar.Data)
 	}
 
+	// Otherwise scan the image for the version it reports through SMBIOS
+	if version == "" {
+		if image, err := os.ReadFile(m.firmwarePath); err == nil {
+			if build, err := edk2.ScanVersion(image); err == nil {
+				version = build.Version
+			}
+		}
+	}
+
 	// If no version found, use the firmware file modification time
 	if version == "" {
 		fileInfo, err := getFileInfo(m.firmwarePath)
@@ -1008,12 +1200,55 @@ func (m *EDK2Manager) SaveChanges() error {
 	if err := m.varStore.WriteVarStore(m.firmwarePath, m.varList); err != nil {
 		return fmt.Errorf("failed to write variable store: %w", err)
 	}
+	m.savedHashes = varHashes(m.varList)
+
+	if m.signer != nil {
+		if err := signImageFile(m.firmwarePath, m.signer); err != nil {
+			return fmt.Errorf("failed to sign firmware: %w", err)
+		}
+	}
 
 	m.logger.Info("firmware saved successfully", "path", m.firmwarePath)
 
 	return nil
 }
 
+// SetImageSigner makes SaveChanges write a detached signature of the
+// firmware file next to it, named with types.ImageSignatureExt. A nil
+// signer disables signing.
+func (m *EDK2Manager) SetImageSigner(signer crypto.Signer) {
+	m.signer = signer
+}
+
+// SetSecureWipe makes SaveChanges also zero the data of deleted and
+// replaced variables in the stale copies the firmware keeps outside the
+// varstore, so secrets such as boot option data or iSCSI CHAP secrets do
+// not persist in the image.
+func (m *EDK2Manager) SetSecureWipe(enable bool) {
+	m.varStore.Wipe = enable
+}
+
+// SetDeterministic makes SaveChanges write a reproducible image: the same
+// variables always produce the same bytes, whatever their timestamps or
+// the order they were set in.
+func (m *EDK2Manager) SetDeterministic(enable bool) {
+	m.varStore.Deterministic = enable
+}
+
+// signImageFile writes the detached signature of the file at path.
+func signImageFile(path string, signer crypto.Signer) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	sig, err := types.SignImage(f, signer)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path+types.ImageSignatureExt, sig, 0o644)
+}
+
 // RevertChanges discards all changes.
 func (m *EDK2Manager) RevertChanges() error {
 	// Reload the variables from the file
@@ -1056,22 +1291,22 @@ func (m *EDK2Manager) ResetToDefaults() error {
 
 // getOrCreateVar gets an existing variable or creates a new one with the specified name and GUID.
 func (m *EDK2Manager) getOrCreateVar(name, guidStr string) *efi.EfiVar {
-	v, found := m.varList[name]
-	if found {
-		return v
-	}
+	return varListGetOrCreate(m.varList, name, guidStr)
+}
 
-	// Create a new variable
-	v = &efi.EfiVar{
-		Name: efi.NewUCS16String(name),
-		Guid: efi.StringToGUID(guidStr),
-		Attr: efi.EFI_VARIABLE_NON_VOLATILE |
-			efi.EFI_VARIABLE_BOOTSERVICE_ACCESS |
-			efi.EFI_VARIABLE_RUNTIME_ACCESS,
+// setVarIdentity fills in the name and GUID of a variable from the key it is
+// being stored under, when the variable does not carry them itself.
+func setVarIdentity(key string, v *efi.EfiVar) {
+	name, guid, qualified := efi.ParseVarKey(key)
+	if !qualified {
+		name = key
+	}
+	if v.Name == nil || v.Name.String() == "" {
+		v.Name = efi.NewUCS16String(name)
+	}
+	if qualified && v.Guid == (efi.GUID{}) {
+		v.Guid = guid
 	}
-	m.varList[name] = v
-
-	return v
 }
 
 // boolToUint32 converts a boolean to a uint32 (0 or 1).
@@ -1088,7 +1323,7 @@ func copyFile(src, dst string) error {
 	if err != nil {
 		return fmt.Errorf("failed to read file %s: %w", src, err)
 	}
-	return os.WriteFile(dst, data, 0o644)
+	return atomicfile.WriteFile(dst, data, 0o644)
 }
 
 func removeFile(path string) error {
@@ -1098,3 +1333,8 @@ func removeFile(path string) error {
 func getFileInfo(path string) (os.FileInfo, error) {
 	return os.Stat(path)
 }
+
+func fileExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir()
+}
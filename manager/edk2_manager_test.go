@@ -2,12 +2,21 @@
 package manager
 
 import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
 	"net"
+	"os"
+	"path/filepath"
 	"reflect"
 	"testing"
 
 	"github.com/go-logr/logr"
+	"github.com/metal3-community/uefi-firmware-manager/edk2"
 	"github.com/metal3-community/uefi-firmware-manager/efi"
+	"github.com/metal3-community/uefi-firmware-manager/testutil"
 	"github.com/metal3-community/uefi-firmware-manager/types"
 	"github.com/metal3-community/uefi-firmware-manager/varstore"
 )
@@ -25,7 +34,7 @@ func TestNewEDK2Manager(t *testing.T) {
 		{
 			name: "valid firmware path",
 			args: args{
-				firmwarePath: "/Users/atkini01/src/go/uefi-firmware-manager/data/d8-3a-dd-5a-44-36/RPI_EFI.fd",
+				firmwarePath: testutil.WriteFirmware(t, testutil.VarList(t, "UEFI Shell")),
 				logger:       logr.Discard().WithName("edk2-manager"),
 			},
 			wantErr: false,
@@ -46,6 +55,64 @@ func TestNewEDK2Manager(t *testing.T) {
 	}
 }
 
+func TestNewEDK2ManagerRecovering(t *testing.T) {
+	path := filepath.Join(t.TempDir(), edk2.FirmwareFileName)
+	if err := os.WriteFile(path, edk2.RpiEfi, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	fm, err := NewEDK2Manager(path, logr.Discard())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The embedded image has an empty varstore.
+	if err := fm.SetFirmwareTimeoutSeconds(5); err != nil {
+		t.Fatal(err)
+	}
+	if err := fm.SetVariable("Example", &efi.EfiVar{
+		Name: efi.FromString("Example"),
+		Guid: efi.EFI_GLOBAL_VARIABLE_GUID,
+		Attr: efi.EfiVariableDefault,
+		Data: []byte{1},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := fm.SaveChanges(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Damage the header of the Timeout variable.
+	image, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pos := bytes.Index(image, efi.NewUCS16String("Timeout").Bytes()) - efi.VarHeaderSize
+	if pos < 0 || binary.LittleEndian.Uint16(image[pos:]) != efi.VarHeaderMagic {
+		t.Fatal("no Timeout variable record in the firmware")
+	}
+	binary.LittleEndian.PutUint16(image[pos:], 0xdead)
+	if err := os.WriteFile(path, image, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := NewEDK2Manager(path, logr.Discard()); !errors.Is(err, varstore.ErrCorrupt) {
+		t.Fatalf("NewEDK2Manager() error = %v, want ErrCorrupt", err)
+	}
+	fm, err = NewEDK2ManagerRecovering(path, logr.Discard())
+	if err != nil {
+		t.Fatalf("NewEDK2ManagerRecovering() error = %v", err)
+	}
+	vars, err := fm.ListVariables()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, found := vars["Timeout"]; found {
+		t.Error("NewEDK2ManagerRecovering() kept the damaged Timeout")
+	}
+	if _, found := vars["Example"]; !found {
+		t.Error("NewEDK2ManagerRecovering() lost Example")
+	}
+}
+
 func TestEDK2Manager_GetBootOrder(t *testing.T) {
 	type fields struct {
 		firmwarePath string
@@ -216,6 +283,85 @@ func TestEDK2Manager_UpdateBootEntry(t *testing.T) {
 	}
 }
 
+func TestEDK2Manager_BootEntryDevicePathNodes(t *testing.T) {
+	m := &EDK2Manager{varList: efi.NewEfiVarList(), logger: logr.Discard()}
+
+	mac, _ := net.ParseMAC("d8:3a:dd:5a:44:36")
+	dp := (&efi.DevicePath{}).Mac(mac).IPv4()
+	entry := types.BootEntry{
+		Name:       "Network",
+		Enabled:    true,
+		Position:   0,
+		Category:   types.BootCategoryApp,
+		DevicePath: devicePathNodes(dp),
+	}
+	if err := m.AddBootEntry(entry); err != nil {
+		t.Fatalf("AddBootEntry() error = %v", err)
+	}
+
+	entries, err := m.GetBootEntries()
+	if err != nil {
+		t.Fatalf("GetBootEntries() error = %v", err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("GetBootEntries() returned %d entries, want 1", len(entries))
+	}
+	got := entries[0]
+	if got.Category != types.BootCategoryApp {
+		t.Errorf("Category = %q, want %q", got.Category, types.BootCategoryApp)
+	}
+	if got.DevPath != dp.String() {
+		t.Errorf("DevPath = %q, want %q", got.DevPath, dp.String())
+	}
+	if !reflect.DeepEqual(got.DevicePath, entry.DevicePath) {
+		t.Errorf("DevicePath = %+v, want %+v", got.DevicePath, entry.DevicePath)
+	}
+	if got.DevicePath[1].Text != "IPv4()" {
+		t.Errorf("second node = %q, want IPv4()", got.DevicePath[1].Text)
+	}
+
+	entry.Category = "firmware"
+	if err := m.AddBootEntry(entry); err == nil {
+		t.Error("AddBootEntry() accepted an unknown category")
+	}
+}
+
+func TestEDK2Manager_BootEntryDeviceCategory(t *testing.T) {
+	data, err := os.ReadFile("../efi/test/fw-test.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	varList := efi.NewEfiVarList()
+	if err := varList.UnmarshalJSON(data); err != nil {
+		t.Fatal(err)
+	}
+	m := &EDK2Manager{varList: varList, logger: logr.Discard()}
+
+	entries, err := m.GetBootEntries()
+	if err != nil {
+		t.Fatalf("GetBootEntries() error = %v", err)
+	}
+	got := map[string]string{}
+	for _, entry := range entries {
+		got[entry.Name] = entry.DeviceCategory
+	}
+	want := map[string]string{
+		"UiApp":                             types.BootDeviceUiApp,
+		"SD/MMC on Arasan SDHCI":            types.BootDeviceDisk,
+		"UEFI SupTronics X862 20210100007C": types.BootDeviceUSB,
+		"UEFI PXEv4 (MAC:D83ADD5A440C)":     types.BootDevicePXE,
+		"UEFI PXEv6 (MAC:D83ADD5A440C)":     types.BootDevicePXE,
+		"UEFI HTTPv4 (MAC:D83ADD5A440C)":    types.BootDeviceHTTP,
+		"UEFI HTTPv6 (MAC:D83ADD5A440C)":    types.BootDeviceHTTP,
+		"UEFI Shell":                        types.BootDeviceShell,
+		"UEFI iPXE":                         types.BootDeviceDisk,
+		"netboot ipxe.efi":                  types.BootDeviceHTTP,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("device categories = %v, want %v", got, want)
+	}
+}
+
 func TestEDK2Manager_DeleteBootEntry(t *testing.T) {
 	type fields struct {
 		firmwarePath string
@@ -526,6 +672,25 @@ func TestEDK2Manager_SetVariable(t *testing.T) {
 	}
 }
 
+func TestEDK2Manager_DeleteVariable(t *testing.T) {
+	varList := efi.NewEfiVarList()
+	if err := varList.SetUint32("Timeout", 5); err != nil {
+		t.Fatal(err)
+	}
+	m := &EDK2Manager{varList: varList, logger: logr.Discard()}
+
+	// Variables are keyed by name and vendor GUID but deleted by name.
+	if err := m.DeleteVariable("Timeout"); err != nil {
+		t.Fatalf("EDK2Manager.DeleteVariable() error = %v", err)
+	}
+	if _, found := m.varList.Lookup("Timeout"); found || len(m.varList) != 0 {
+		t.Errorf("EDK2Manager.DeleteVariable() left %d variables", len(m.varList))
+	}
+	if err := m.DeleteVariable("Timeout"); err == nil {
+		t.Error("EDK2Manager.DeleteVariable() of a missing variable succeeded")
+	}
+}
+
 func TestEDK2Manager_ListVariables(t *testing.T) {
 	type fields struct {
 		firmwarePath string
@@ -664,6 +829,44 @@ func TestEDK2Manager_SetFirmwareTimeoutSeconds(t *testing.T) {
 	}
 }
 
+func TestEDK2Manager_GetFirmwareTimeoutSeconds(t *testing.T) {
+	m := &EDK2Manager{varList: efi.NewEfiVarList(), logger: logr.Discard()}
+	if _, err := m.GetFirmwareTimeoutSeconds(); err == nil {
+		t.Error("GetFirmwareTimeoutSeconds() without Timeout succeeded")
+	}
+	info, err := m.GetSystemInfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.TimeoutSeconds != nil {
+		t.Errorf("SystemInfo.TimeoutSeconds = %d, want nil", *info.TimeoutSeconds)
+	}
+
+	if err := m.SetFirmwareTimeoutSeconds(300); err != nil {
+		t.Fatal(err)
+	}
+	got, err := m.GetFirmwareTimeoutSeconds()
+	if err != nil {
+		t.Fatalf("GetFirmwareTimeoutSeconds() error = %v", err)
+	}
+	if got != 300 {
+		t.Errorf("GetFirmwareTimeoutSeconds() = %d, want 300", got)
+	}
+	info, err = m.GetSystemInfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.TimeoutSeconds == nil || *info.TimeoutSeconds != 300 {
+		t.Errorf("SystemInfo.TimeoutSeconds = %v, want 300", info.TimeoutSeconds)
+	}
+
+	for _, seconds := range []int{-1, 0x10000} {
+		if err := m.SetFirmwareTimeoutSeconds(seconds); err == nil {
+			t.Errorf("SetFirmwareTimeoutSeconds(%d) succeeded", seconds)
+		}
+	}
+}
+
 func TestEDK2Manager_SetConsoleConfig(t *testing.T) {
 	type fields struct {
 		firmwarePath string
@@ -699,6 +902,31 @@ func TestEDK2Manager_SetConsoleConfig(t *testing.T) {
 }
 
 func TestEDK2Manager_GetSystemInfo(t *testing.T) {
+	single := t.TempDir()
+	if err := os.WriteFile(filepath.Join(single, "bcm2711-rpi-4-b.dtb"), edk2.Bcm2711Rpi4BDtb, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	multiple := t.TempDir()
+	for _, name := range []string{"bcm2711-rpi-400.dtb", "bcm2711-rpi-cm4.dtb"} {
+		if err := os.WriteFile(filepath.Join(multiple, name), edk2.Files[name], 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	platformVars := efi.NewEfiVarList()
+	customClock, acpiMode := uint32(cpuClockCustom), uint32(0)
+	for name, value := range map[string]uint32{
+		"CpuClock":        customClock,
+		"CustomCpuClock":  1800,
+		"RamMoreThan3GB":  1,
+		"RamLimitTo3GB":   1,
+		"SystemTableMode": acpiMode,
+	} {
+		if err := platformVars.SetUint32(name, value); err != nil {
+			t.Fatal(err)
+		}
+	}
+
 	type fields struct {
 		firmwarePath string
 		varStore     *varstore.Edk2VarStore
@@ -711,7 +939,49 @@ func TestEDK2Manager_GetSystemInfo(t *testing.T) {
 		want    types.SystemInfo
 		wantErr bool
 	}{
-		// TODO: Add test cases.
+		{
+			name: "single device tree",
+			fields: fields{
+				firmwarePath: filepath.Join(single, "RPI_EFI.fd"),
+				varList:      efi.NewEfiVarList(),
+				logger:       logr.Discard(),
+			},
+			want: types.SystemInfo{
+				FirmwareVersion: "Unknown",
+				BoardModel:      "Raspberry Pi 4 Model B",
+				Compatible:      []string{"raspberrypi,4-model-b", "brcm,bcm2711"},
+			},
+		},
+		{
+			name: "several device trees",
+			fields: fields{
+				firmwarePath: filepath.Join(multiple, "RPI_EFI.fd"),
+				varList:      efi.NewEfiVarList(),
+				logger:       logr.Discard(),
+			},
+			want: types.SystemInfo{
+				FirmwareVersion: "Unknown",
+				DeviceTrees: map[string]string{
+					"bcm2711-rpi-400.dtb": "Raspberry Pi 400",
+					"bcm2711-rpi-cm4.dtb": "Raspberry Pi Compute Module 4",
+				},
+			},
+		},
+		{
+			name: "platform settings",
+			fields: fields{
+				firmwarePath: filepath.Join(t.TempDir(), "RPI_EFI.fd"),
+				varList:      platformVars,
+				logger:       logr.Discard(),
+			},
+			want: types.SystemInfo{
+				FirmwareVersion: "Unknown",
+				CPUClock:        &customClock,
+				CPUClockMHz:     1800,
+				RAMLimit:        types.RAMLimit3GB,
+				SystemTableMode: &acpiMode,
+			},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -746,7 +1016,15 @@ func TestEDK2Manager_GetFirmwareVersion(t *testing.T) {
 		want    string
 		wantErr bool
 	}{
-		// TODO: Add test cases.
+		{
+			name: "version string in firmware image",
+			fields: fields{
+				firmwarePath: "../edk2/RPI_EFI.fd",
+				varList:      efi.NewEfiVarList(),
+				logger:       logr.Discard(),
+			},
+			want: "UEFI Firmware v0.0.2-62-gc1c9118",
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -801,6 +1079,50 @@ func TestEDK2Manager_UpdateFirmware(t *testing.T) {
 	}
 }
 
+func TestEDK2Manager_UpdateFirmwareImage(t *testing.T) {
+	path := filepath.Join(t.TempDir(), edk2.FirmwareFileName)
+	if err := os.WriteFile(path, edk2.RpiEfi, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	fm, err := NewEDK2Manager(path, logr.Discard())
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := fm.(*EDK2Manager)
+	if err := m.SetFirmwareTimeoutSeconds(7); err != nil {
+		t.Fatal(err)
+	}
+
+	// A build differing outside of the variable store.
+	image := bytes.Clone(edk2.RpiEfi)
+	image[len(image)-1] ^= 0xff
+	if err := m.UpdateFirmware(image); err != nil {
+		t.Fatalf("UpdateFirmware() error = %v", err)
+	}
+	written, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(written) != len(image) || written[len(written)-1] != image[len(image)-1] {
+		t.Error("UpdateFirmware() did not write the new image")
+	}
+	reopened, err := NewEDK2Manager(path, logr.Discard())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := reopened.GetFirmwareTimeoutSeconds(); err != nil || got != 7 {
+		t.Errorf("GetFirmwareTimeoutSeconds() after update = %d, %v; want 7", got, err)
+	}
+
+	// An image without a varstore leaves the firmware as it is.
+	if err := m.UpdateFirmware([]byte("not firmware")); err == nil {
+		t.Error("UpdateFirmware() of an invalid image succeeded")
+	}
+	if after, _ := os.ReadFile(path); !bytes.Equal(after, written) {
+		t.Error("failed UpdateFirmware() changed the firmware")
+	}
+}
+
 func TestEDK2Manager_SaveChanges(t *testing.T) {
 	type fields struct {
 		firmwarePath string
@@ -830,6 +1152,36 @@ func TestEDK2Manager_SaveChanges(t *testing.T) {
 	}
 }
 
+func TestEDK2Manager_SaveChangesSigned(t *testing.T) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := newFixtureManager(t)
+	m.SetImageSigner(key)
+	if err := m.SetFirmwareTimeoutSeconds(7); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.SaveChanges(); err != nil {
+		t.Fatalf("SaveChanges() error = %v", err)
+	}
+	if err := types.VerifyImageFile(m.firmwarePath, key.Public()); err != nil {
+		t.Errorf("VerifyImageFile() error = %v", err)
+	}
+
+	m.SetImageSigner(nil)
+	if err := m.SetFirmwareTimeoutSeconds(8); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.SaveChanges(); err != nil {
+		t.Fatal(err)
+	}
+	if err := types.VerifyImageFile(m.firmwarePath, key.Public()); err == nil {
+		t.Error("VerifyImageFile() accepted a stale signature")
+	}
+}
+
 func TestEDK2Manager_RevertChanges(t *testing.T) {
 	type fields struct {
 		firmwarePath string
@@ -0,0 +1,130 @@
+package manager
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+
+	"github.com/go-logr/logr"
+	"github.com/metal3-community/uefi-firmware-manager/efi"
+	"github.com/metal3-community/uefi-firmware-manager/varstore"
+)
+
+// EfivarfsManager manages the variables of the running host through
+// efivarfs, so a profile can be applied to the machine it runs on. Boot
+// entry, network and variable methods work as with EDK2Manager;
+// SaveChanges writes only the variables that changed.
+//
+// Authenticated variables such as PK, KEK, db and dbx are only accepted by
+// the firmware in setup mode or with a signed update.
+type EfivarfsManager struct {
+	*EDK2Manager
+
+	store *varstore.EfivarfsVarStore
+	// saved is the state of the host after the last load or save.
+	saved map[string]efi.EfiVar
+}
+
+// NewEfivarfsManager reads the variables in the efivarfs directory dir, or
+// in varstore.EfivarfsDir if dir is empty. Writing needs root.
+func NewEfivarfsManager(dir string, logger logr.Logger) (*EfivarfsManager, error) {
+	if dir == "" {
+		dir = varstore.EfivarfsDir
+	}
+	store := varstore.NewEfivarfsVarStore(dir)
+	store.Logger = logger.WithName("efivarfs-varstore")
+
+	m := &EfivarfsManager{
+		EDK2Manager: &EDK2Manager{
+			firmwarePath: dir,
+			logger:       logger.WithName("efivarfs-manager"),
+		},
+		store: store,
+	}
+	if err := m.RevertChanges(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// SaveChanges writes the variables that were set or changed since the last
+// load or save and deletes the removed ones.
+func (m *EfivarfsManager) SaveChanges() error {
+	keys := make([]string, 0, len(m.varList))
+	for key := range m.varList {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		v := m.varList[key]
+		old, found := m.saved[v.Key()]
+		if found && old.Attr == v.Attr && bytes.Equal(old.Data, v.Data) {
+			continue
+		}
+		if err := m.store.SetVar(v); err != nil {
+			return fmt.Errorf("failed to write variable %s: %w", v.Name, err)
+		}
+		m.saved[v.Key()] = snapshotVar(v)
+	}
+
+	live := make(map[string]bool, len(m.varList))
+	for _, v := range m.varList {
+		live[v.Key()] = true
+	}
+	removed := make([]string, 0)
+	for key := range m.saved {
+		if !live[key] {
+			removed = append(removed, key)
+		}
+	}
+	sort.Strings(removed)
+	for _, key := range removed {
+		name, guid, _ := efi.ParseVarKey(key)
+		if err := m.store.DeleteVar(name, guid); err != nil {
+			return fmt.Errorf("failed to delete variable %s: %w", name, err)
+		}
+		delete(m.saved, key)
+	}
+
+	m.logger.Info("variables saved successfully", "dir", m.firmwarePath)
+	return nil
+}
+
+// RevertChanges discards all changes by reading the variables again.
+func (m *EfivarfsManager) RevertChanges() error {
+	varList, err := m.store.GetVarList()
+	if err != nil {
+		return fmt.Errorf("failed to get variable list: %w", err)
+	}
+	m.varList = varList
+	m.saved = make(map[string]efi.EfiVar, len(varList))
+	for _, v := range varList {
+		m.saved[v.Key()] = snapshotVar(v)
+	}
+	return nil
+}
+
+// FreeSpace returns -1; the firmware does not report the space left.
+func (m *EfivarfsManager) FreeSpace() int {
+	return -1
+}
+
+// SetSecureWipe does nothing; the firmware maintains its own varstore.
+func (m *EfivarfsManager) SetSecureWipe(enable bool) {}
+
+// UpdateFirmware is not supported; flash the firmware with the vendor tools.
+func (m *EfivarfsManager) UpdateFirmware(firmwareData []byte) error {
+	return fmt.Errorf("firmware updates are %w on a live system", ErrNotSupported)
+}
+
+// ResetToDefaults is not supported; the defaults are only known to the
+// firmware.
+func (m *EfivarfsManager) ResetToDefaults() error {
+	return fmt.Errorf("resetting to defaults is %w on a live system", ErrNotSupported)
+}
+
+// snapshotVar copies the attributes and data of v.
+func snapshotVar(v *efi.EfiVar) efi.EfiVar {
+	return efi.EfiVar{Name: v.Name, Guid: v.Guid, Attr: v.Attr, Data: bytes.Clone(v.Data)}
+}
@@ -0,0 +1,96 @@
+package manager
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-logr/logr"
+	"github.com/metal3-community/uefi-firmware-manager/efi"
+	"github.com/metal3-community/uefi-firmware-manager/varstore"
+)
+
+func TestEfivarfsManager(t *testing.T) {
+	dir := t.TempDir()
+	varlist := efi.NewEfiVarList()
+	for name, data := range map[string][]byte{
+		"Timeout":   {0x05, 0x00},
+		"BootNext":  {0x01, 0x00},
+		"BootOrder": {0x00, 0x00},
+	} {
+		varlist.Set(&efi.EfiVar{
+			Name: efi.FromString(name),
+			Guid: efi.EFI_GLOBAL_VARIABLE_GUID,
+			Attr: efi.EfiVariableDefault | efi.EfiVariableRuntimeAccess,
+			Data: data,
+		})
+	}
+	if err := varstore.NewEfivarfsVarStore(dir).WriteVarStore("", varlist); err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := NewEfivarfsManager(dir, logr.Discard())
+	if err != nil {
+		t.Fatalf("NewEfivarfsManager() error = %v", err)
+	}
+	var _ FirmwareManager = m
+
+	// Unchanged variables must not be written back.
+	bootOrder := filepath.Join(dir, "BootOrder-"+efi.EFI_GLOBAL_VARIABLE_GUID.String())
+	marker := []byte{0x07, 0x00, 0x00, 0x00, 0x09, 0x00}
+	if err := os.WriteFile(bootOrder, marker, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m.SetFirmwareTimeoutSeconds(3); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.DeleteBootNext(); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.SaveChanges(); err != nil {
+		t.Fatalf("SaveChanges() error = %v", err)
+	}
+
+	timeout, err := os.ReadFile(filepath.Join(dir, "Timeout-"+efi.EFI_GLOBAL_VARIABLE_GUID.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []byte{0x07, 0x00, 0x00, 0x00, 0x03, 0x00}; !bytes.Equal(timeout, want) {
+		t.Errorf("Timeout file = %x, want %x", timeout, want)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "BootNext-"+efi.EFI_GLOBAL_VARIABLE_GUID.String())); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("BootNext file still exists: %v", err)
+	}
+	if got, _ := os.ReadFile(bootOrder); !bytes.Equal(got, marker) {
+		t.Errorf("BootOrder file = %x, want it untouched", got)
+	}
+
+	if err := m.RevertChanges(); err != nil {
+		t.Fatal(err)
+	}
+	order, err := m.GetVariable("BootOrder")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(order.Data, []byte{0x09, 0x00}) {
+		t.Errorf("BootOrder after RevertChanges() = %x, want 0900", order.Data)
+	}
+
+	if report, err := SaveWithReport(m); err != nil || report.Written {
+		t.Errorf("SaveWithReport() without changes = %+v, %v; want no write", report, err)
+	}
+	if err := m.SetFirmwareTimeoutSeconds(4); err != nil {
+		t.Fatal(err)
+	}
+	report, err := SaveWithReport(m)
+	if err != nil || !report.Written || len(report.Modified) != 1 || report.Modified[0] != "Timeout" {
+		t.Errorf("SaveWithReport() = %+v, %v; want Timeout modified", report, err)
+	}
+
+	if err := m.UpdateFirmware(nil); !errors.Is(err, ErrNotSupported) {
+		t.Errorf("UpdateFirmware() error = %v, want %v", err, ErrNotSupported)
+	}
+}
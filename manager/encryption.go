@@ -0,0 +1,94 @@
+package manager
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// encryptedMagic starts encrypted variable files. It cannot start a JSON
+// document, so plaintext files are still recognized.
+var encryptedMagic = []byte("UFMENC1\n")
+
+// ErrEncrypted is returned when an encrypted variable file is loaded
+// without a key.
+var ErrEncrypted = errors.New("variable file is encrypted and no key is configured")
+
+// KeyProvider supplies the AES key, 16, 24 or 32 bytes long, that variable
+// files are encrypted with. Implementations may fetch it from a KMS.
+type KeyProvider interface {
+	DataKey() ([]byte, error)
+}
+
+// EnvKey is a KeyProvider reading a base64 encoded key from the
+// environment variable it names.
+type EnvKey string
+
+// DataKey returns the decoded key.
+func (e EnvKey) DataKey() ([]byte, error) {
+	value, ok := os.LookupEnv(string(e))
+	if !ok {
+		return nil, fmt.Errorf("environment variable %s is not set", string(e))
+	}
+	key, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return nil, fmt.Errorf("environment variable %s: %w", string(e), err)
+	}
+	return key, nil
+}
+
+func newGCM(kp KeyProvider) (cipher.AEAD, error) {
+	key, err := kp.DataKey()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get encryption key: %w", err)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal encrypts data with AES-GCM. aad binds the result to its location,
+// so it cannot be moved to another host's directory.
+func seal(kp KeyProvider, data, aad []byte) ([]byte, error) {
+	gcm, err := newGCM(kp)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	out := append(bytes.Clone(encryptedMagic), nonce...)
+	return gcm.Seal(out, nonce, data, aad), nil
+}
+
+// open decrypts data sealed by seal. Data without the encryption header is
+// returned unchanged.
+func open(kp KeyProvider, data, aad []byte) ([]byte, error) {
+	if !bytes.HasPrefix(data, encryptedMagic) {
+		return data, nil
+	}
+	if kp == nil {
+		return nil, ErrEncrypted
+	}
+	gcm, err := newGCM(kp)
+	if err != nil {
+		return nil, err
+	}
+	data = data[len(encryptedMagic):]
+	if len(data) < gcm.NonceSize() {
+		return nil, fmt.Errorf("encrypted variable file is truncated")
+	}
+	plain, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], aad)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt variable file: %w", err)
+	}
+	return plain, nil
+}
@@ -0,0 +1,176 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/metal3-community/uefi-firmware-manager/internal/archive"
+	"github.com/metal3-community/uefi-firmware-manager/types"
+)
+
+// accessedFileName is the marker MarkAccessed touches in a MAC directory.
+const accessedFileName = ".accessed"
+
+// GCOptions configures CollectStaleHosts.
+type GCOptions struct {
+	// TTL is how long a MAC directory may go unaccessed before it is
+	// collected.
+	TTL time.Duration
+	// ArchiveDir receives a <mac>-<time>.tar.gz archive of every collected
+	// directory. It is created if needed.
+	ArchiveDir string
+	// DryRun reports the stale directories without archiving or removing
+	// them.
+	DryRun bool
+}
+
+// MarkAccessed records that the host with the MAC address mac was served,
+// so that CollectStaleHosts keeps its directory in dataDir. Hosts without
+// a directory are ignored.
+func MarkAccessed(dataDir string, mac net.HardwareAddr) error {
+	dir := filepath.Join(dataDir, strings.ReplaceAll(mac.String(), ":", "-"))
+	if _, err := os.Stat(dir); errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	marker := filepath.Join(dir, accessedFileName)
+	now := time.Now()
+	if err := os.Chtimes(marker, now, now); errors.Is(err, fs.ErrNotExist) {
+		return os.WriteFile(marker, nil, 0o644)
+	} else if err != nil {
+		return fmt.Errorf("failed to mark %s accessed: %w", mac, err)
+	}
+	return nil
+}
+
+// SetDataDir makes ServeFirmware mark the hosts it serves accessed in
+// dataDir with MarkAccessed, so that CollectStaleHosts keeps the
+// directories of hosts that boot regularly even if their configuration
+// does not change. Set it before serving firmware.
+func (sm *SimpleFirmwareManager) SetDataDir(dataDir string) {
+	sm.dataDir = dataDir
+}
+
+// markAccessed marks the host with the MAC address mac accessed in the
+// data directory, if one is set.
+func (sm *SimpleFirmwareManager) markAccessed(mac net.HardwareAddr) {
+	if sm.dataDir == "" {
+		return
+	}
+	if err := MarkAccessed(sm.dataDir, mac); err != nil {
+		sm.logger.Error(err, "failed to mark host accessed", "mac", mac.String())
+	}
+}
+
+// CollectStaleHosts archives and removes the MAC directories of dataDir
+// that were not accessed within opts.TTL. A directory was last accessed
+// when MarkAccessed was last called for it or when a file in it was last
+// modified, whichever is later. A directory that cannot be archived is
+// kept and reported with an error.
+func CollectStaleHosts(dataDir string, opts GCOptions, logger logr.Logger) ([]types.StaleHost, error) {
+	if opts.TTL <= 0 {
+		return nil, fmt.Errorf("garbage collection TTL must be positive")
+	}
+	if opts.ArchiveDir == "" && !opts.DryRun {
+		return nil, fmt.Errorf("no archive directory set")
+	}
+	entries, err := os.ReadDir(dataDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read data directory: %w", err)
+	}
+
+	now := time.Now()
+	stale := []types.StaleHost{}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		mac, err := net.ParseMAC(strings.ReplaceAll(entry.Name(), "-", ":"))
+		if err != nil {
+			continue
+		}
+		dir := filepath.Join(dataDir, entry.Name())
+		accessed, err := lastAccessed(dir)
+		if err != nil {
+			logger.Error(err, "failed to read MAC directory", "path", dir)
+			continue
+		}
+		if now.Sub(accessed) < opts.TTL {
+			continue
+		}
+
+		host := types.StaleHost{MacAddress: mac.String(), Path: dir, LastAccessed: accessed.UTC()}
+		if !opts.DryRun {
+			if host.Archive, err = archiveDir(dir, opts.ArchiveDir, now); err == nil {
+				err = os.RemoveAll(dir)
+			}
+			if err != nil {
+				logger.Error(err, "failed to collect MAC directory", "path", dir)
+				host.Error = err.Error()
+			} else {
+				logger.Info("Collected stale MAC directory", "mac", host.MacAddress, "archive", host.Archive)
+			}
+		}
+		stale = append(stale, host)
+	}
+	return stale, nil
+}
+
+// RunGC runs CollectStaleHosts every interval until ctx is done, so that
+// long-running servers do not keep the state of every host they ever
+// served.
+func RunGC(ctx context.Context, dataDir string, interval time.Duration, opts GCOptions, logger logr.Logger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := CollectStaleHosts(dataDir, opts, logger); err != nil {
+				logger.Error(err, "garbage collection failed")
+			}
+		}
+	}
+}
+
+// lastAccessed returns the latest modification time of dir and the files
+// below it.
+func lastAccessed(dir string) (time.Time, error) {
+	var latest time.Time
+	err := filepath.WalkDir(dir, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+		return nil
+	})
+	return latest, err
+}
+
+// archiveDir writes the files below dir to a tar.gz archive in archiveDir,
+// named after dir and now, and returns its path.
+func archiveDir(dir, archiveDir string, now time.Time) (string, error) {
+	if err := os.MkdirAll(archiveDir, 0o755); err != nil {
+		return "", fmt.Errorf("failed to create archive directory: %w", err)
+	}
+	base := filepath.Base(dir)
+	name := filepath.Join(archiveDir, fmt.Sprintf("%s-%s.tar.gz", base, now.UTC().Format("20060102T150405Z")))
+	if err := archive.Create(dir, name, archive.TarGz, base); err != nil {
+		return "", err
+	}
+	return name, nil
+}
@@ -0,0 +1,182 @@
+package manager
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/metal3-community/uefi-firmware-manager/efi"
+)
+
+func TestCollectStaleHosts(t *testing.T) {
+	dataDir := t.TempDir()
+	old := time.Now().Add(-48 * time.Hour)
+	for _, name := range []string{
+		"d8-3a-dd-5a-44-0c/fw-vars.json",
+		"d8-3a-dd-5a-44-0c/history/1.json",
+		"d8-3a-dd-5a-44-0d/fw-vars.json",
+		"d8-3a-dd-5a-44-0e/fw-vars.json",
+		"lab/d8-3a-dd-5a-44-0f/fw-vars.json",
+	} {
+		path := filepath.Join(dataDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(name), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Age everything but 0e, which was modified recently.
+	err := filepath.WalkDir(dataDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || filepath.Base(filepath.Dir(path)) == "d8-3a-dd-5a-44-0e" {
+			return err
+		}
+		return os.Chtimes(path, old, old)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	served, _ := net.ParseMAC("d8:3a:dd:5a:44:0d")
+	if err := MarkAccessed(dataDir, served); err != nil {
+		t.Fatalf("MarkAccessed() error = %v", err)
+	}
+	unknown, _ := net.ParseMAC("d8:3a:dd:00:00:01")
+	if err := MarkAccessed(dataDir, unknown); err != nil {
+		t.Fatalf("MarkAccessed() of a host without a directory error = %v", err)
+	}
+
+	archiveDir := filepath.Join(t.TempDir(), "archive")
+	opts := GCOptions{TTL: 24 * time.Hour, ArchiveDir: archiveDir, DryRun: true}
+	stale, err := CollectStaleHosts(dataDir, opts, logr.Discard())
+	if err != nil {
+		t.Fatalf("CollectStaleHosts() dry run error = %v", err)
+	}
+	if len(stale) != 1 || stale[0].MacAddress != "d8:3a:dd:5a:44:0c" || stale[0].Archive != "" {
+		t.Fatalf("CollectStaleHosts() dry run = %+v", stale)
+	}
+	if _, err := os.Stat(stale[0].Path); err != nil {
+		t.Errorf("dry run removed %s", stale[0].Path)
+	}
+
+	opts.DryRun = false
+	stale, err = CollectStaleHosts(dataDir, opts, logr.Discard())
+	if err != nil {
+		t.Fatalf("CollectStaleHosts() error = %v", err)
+	}
+	if len(stale) != 1 || stale[0].Error != "" {
+		t.Fatalf("CollectStaleHosts() = %+v", stale)
+	}
+	if _, err := os.Stat(stale[0].Path); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("stale directory not removed: %v", err)
+	}
+	for _, dir := range []string{"d8-3a-dd-5a-44-0d", "d8-3a-dd-5a-44-0e", "lab"} {
+		if _, err := os.Stat(filepath.Join(dataDir, dir)); err != nil {
+			t.Errorf("%s removed: %v", dir, err)
+		}
+	}
+
+	f, err := os.Open(stale[0].Archive)
+	if err != nil {
+		t.Fatalf("archive: %v", err)
+	}
+	defer f.Close()
+	gr, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := tar.NewReader(gr)
+	var names []string
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, hdr.Name)
+		if hdr.Name == "d8-3a-dd-5a-44-0c/fw-vars.json" {
+			if data, _ := io.ReadAll(tr); string(data) != hdr.Name {
+				t.Errorf("archived %s = %q", hdr.Name, data)
+			}
+		}
+	}
+	want := []string{
+		"d8-3a-dd-5a-44-0c/",
+		"d8-3a-dd-5a-44-0c/fw-vars.json",
+		"d8-3a-dd-5a-44-0c/history/",
+		"d8-3a-dd-5a-44-0c/history/1.json",
+	}
+	if !slices.Equal(names, want) {
+		t.Errorf("archive holds %v, want %v", names, want)
+	}
+
+	if _, err := CollectStaleHosts(dataDir, GCOptions{TTL: time.Hour}, logr.Discard()); err == nil {
+		t.Error("CollectStaleHosts() without an archive directory succeeded")
+	}
+	if _, err := CollectStaleHosts(dataDir, GCOptions{ArchiveDir: archiveDir}, logr.Discard()); err == nil {
+		t.Error("CollectStaleHosts() without a TTL succeeded")
+	}
+}
+
+func TestCollectStaleHosts_ServedHosts(t *testing.T) {
+	dataDir := t.TempDir()
+	old := time.Now().Add(-48 * time.Hour)
+	vars, err := json.Marshal(efi.NewEfiVarList())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"d8-3a-dd-5a-44-0c", "d8-3a-dd-5a-44-0d", "d8-3a-dd-5a-44-0e"} {
+		dir := filepath.Join(dataDir, name)
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		path := filepath.Join(dir, jsonVarsFileName)
+		if err := os.WriteFile(path, vars, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		for _, p := range []string{path, dir} {
+			if err := os.Chtimes(p, old, old); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	// 0c boots regularly and 0d is managed, neither changes.
+	mgr, err := NewSimpleFirmwareManager(logr.Discard())
+	if err != nil {
+		t.Fatal(err)
+	}
+	mgr.SetDataDir(dataDir)
+	served, _ := net.ParseMAC("d8:3a:dd:5a:44:0c")
+	if w := serveFirmware(mgr, served, http.MethodGet, nil); w.Code != http.StatusOK {
+		t.Fatalf("ServeFirmware() status = %d", w.Code)
+	}
+	jm, err := NewJsonEDK2Manager(dataDir, logr.Discard())
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded, _ := net.ParseMAC("d8:3a:dd:5a:44:0d")
+	if err := jm.LoadMAC(loaded); err != nil {
+		t.Fatal(err)
+	}
+
+	stale, err := CollectStaleHosts(dataDir, GCOptions{TTL: 24 * time.Hour, DryRun: true}, logr.Discard())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stale) != 1 || stale[0].MacAddress != "d8:3a:dd:5a:44:0e" {
+		t.Errorf("CollectStaleHosts() = %+v, want only d8:3a:dd:5a:44:0e", stale)
+	}
+}
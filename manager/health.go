@@ -0,0 +1,128 @@
+package manager
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/metal3-community/uefi-firmware-manager/types"
+)
+
+// errNotWarm is the error of the cache check before Warm completed.
+var errNotWarm = errors.New("firmware caches not warm")
+
+// Warm parses the base firmware and computes its hash, release date and
+// version, so that the first requests served do not pay for it. Servers
+// call it on startup; Readiness reports them unready until it succeeded.
+func (sm *SimpleFirmwareManager) Warm() error {
+	if err := sm.checkFirmware(); err != nil {
+		return err
+	}
+	base := sm.base.Load()
+	baseSum(base)
+	baseLastModified(base)
+	if _, err := firmwareVersion(base); err != nil {
+		sm.logger.Error(err, "base firmware has no version")
+	}
+	sm.warm.Store(true)
+	return nil
+}
+
+// checkFirmware returns an error if the base firmware does not parse.
+func (sm *SimpleFirmwareManager) checkFirmware() error {
+	if _, _, err := sm.getOrCreateVarstore(sm.base.Load()); err != nil {
+		return fmt.Errorf("failed to parse base firmware: %w", err)
+	}
+	return nil
+}
+
+// Liveness checks that the base firmware parses. Once parsed the firmware
+// is cached, so the check is cheap enough for every liveness probe.
+func (sm *SimpleFirmwareManager) Liveness() types.HealthStatus {
+	return healthStatus(types.HealthCheck{Name: "firmware", Error: errString(sm.checkFirmware())})
+}
+
+// Readiness checks that the base firmware parses, that dataDir is writable
+// and that Warm completed. An empty dataDir skips its check.
+func (sm *SimpleFirmwareManager) Readiness(dataDir string) types.HealthStatus {
+	checks := []types.HealthCheck{{Name: "firmware", Error: errString(sm.checkFirmware())}}
+	if dataDir != "" {
+		checks = append(checks, types.HealthCheck{Name: "dataDir", Error: errString(checkWritable(dataDir))})
+	}
+	var err error
+	if !sm.warm.Load() {
+		err = errNotWarm
+	}
+	checks = append(checks, types.HealthCheck{Name: "cache", Error: errString(err)})
+	return healthStatus(checks...)
+}
+
+// ServeHealthz serves the Liveness of the manager, for a Kubernetes
+// liveness probe.
+func (sm *SimpleFirmwareManager) ServeHealthz(w http.ResponseWriter, r *http.Request) {
+	serveHealth(w, r, sm.Liveness())
+}
+
+// ServeReadyz serves the Readiness of the manager with the data directory
+// dataDir, for a Kubernetes readiness probe.
+func (sm *SimpleFirmwareManager) ServeReadyz(w http.ResponseWriter, r *http.Request, dataDir string) {
+	serveHealth(w, r, sm.Readiness(dataDir))
+}
+
+// serveHealth writes status as JSON, with 200 OK if every check passed and
+// 503 Service Unavailable otherwise.
+func serveHealth(w http.ResponseWriter, r *http.Request, status types.HealthStatus) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	code := http.StatusOK
+	if !status.OK {
+		code = http.StatusServiceUnavailable
+	}
+	w.WriteHeader(code)
+	if r.Method == http.MethodGet {
+		json.NewEncoder(w).Encode(status)
+	}
+}
+
+// healthStatus returns the status of checks.
+func healthStatus(checks ...types.HealthCheck) types.HealthStatus {
+	status := types.HealthStatus{OK: true, Checks: checks}
+	for _, c := range checks {
+		if c.Error != "" {
+			status.OK = false
+		}
+	}
+	return status
+}
+
+// checkWritable returns an error if no file can be created in dir.
+func checkWritable(dir string) error {
+	f, err := os.CreateTemp(dir, ".healthz-*")
+	if err != nil {
+		return fmt.Errorf("data directory is not writable: %w", err)
+	}
+	name := f.Name()
+	err = f.Close()
+	if rerr := os.Remove(name); err == nil {
+		err = rerr
+	}
+	if err != nil {
+		return fmt.Errorf("data directory is not writable: %w", err)
+	}
+	return nil
+}
+
+// errString returns the message of err, or empty if it is nil.
+func errString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
@@ -0,0 +1,67 @@
+package manager
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-logr/logr"
+	"github.com/metal3-community/uefi-firmware-manager/types"
+)
+
+func probe(t *testing.T, handler http.HandlerFunc) (int, types.HealthStatus) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	handler(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	var status types.HealthStatus
+	if err := json.Unmarshal(w.Body.Bytes(), &status); err != nil {
+		t.Fatalf("failed to parse %q: %v", w.Body.String(), err)
+	}
+	return w.Code, status
+}
+
+func TestSimpleFirmwareManager_Health(t *testing.T) {
+	mgr, err := NewSimpleFirmwareManager(logr.Discard())
+	if err != nil {
+		t.Fatal(err)
+	}
+	dataDir := t.TempDir()
+	readyz := func(w http.ResponseWriter, r *http.Request) { mgr.ServeReadyz(w, r, dataDir) }
+
+	if code, status := probe(t, mgr.ServeHealthz); code != http.StatusOK || !status.OK {
+		t.Errorf("healthz = %d, %+v", code, status)
+	}
+
+	code, status := probe(t, readyz)
+	if code != http.StatusServiceUnavailable || status.OK {
+		t.Errorf("readyz before Warm = %d, %+v", code, status)
+	}
+	if last := status.Checks[len(status.Checks)-1]; last.Name != "cache" || last.Error == "" {
+		t.Errorf("cache check before Warm = %+v", last)
+	}
+
+	if err := mgr.Warm(); err != nil {
+		t.Fatal(err)
+	}
+	if code, status := probe(t, readyz); code != http.StatusOK || !status.OK || len(status.Checks) != 3 {
+		t.Errorf("readyz = %d, %+v", code, status)
+	}
+	if entries, _ := os.ReadDir(dataDir); len(entries) != 0 {
+		t.Errorf("readyz left %d files in the data directory", len(entries))
+	}
+
+	missing := filepath.Join(dataDir, "missing")
+	status = mgr.Readiness(missing)
+	if status.OK || status.Checks[1].Name != "dataDir" || status.Checks[1].Error == "" {
+		t.Errorf("Readiness(%s) = %+v", missing, status)
+	}
+
+	w := httptest.NewRecorder()
+	mgr.ServeHealthz(w, httptest.NewRequest(http.MethodPost, "/healthz", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST healthz = %d", w.Code)
+	}
+}
@@ -0,0 +1,128 @@
+package manager
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// GitHistory commits every saved configuration to a git repository, giving
+// history, blame and revert to an earlier commit. It runs the system git
+// client.
+type GitHistory struct {
+	// Dir is the work tree, usually the JSON data directory. A repository
+	// is created there if there is none.
+	Dir string
+	// Author is the commit author as "Name <email>". Empty uses the git
+	// configuration.
+	Author string
+	// Message is the commit message. Empty describes the saved file.
+	Message string
+}
+
+// Revision is a commit changing a configuration.
+type Revision struct {
+	Commit  string    `json:"commit" yaml:"commit"`
+	Author  string    `json:"author" yaml:"author"`
+	Time    time.Time `json:"time" yaml:"time"`
+	Message string    `json:"message" yaml:"message"`
+}
+
+func (g *GitHistory) git(args ...string) ([]byte, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("git", append([]string{"-C", g.Dir}, args...)...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("git %s: %w: %s", args[0], err, msg)
+		}
+		return nil, fmt.Errorf("git %s: %w", args[0], err)
+	}
+	return stdout.Bytes(), nil
+}
+
+// rel returns path relative to the work tree, in the ./ form git resolves
+// against the working directory.
+func (g *GitHistory) rel(path string) (string, error) {
+	rel, err := filepath.Rel(g.Dir, path)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return "", fmt.Errorf("%s is outside the history directory %s", path, g.Dir)
+	}
+	return "./" + filepath.ToSlash(rel), nil
+}
+
+// Commit records the current contents of path. message is used if
+// g.Message is empty. Nothing is committed if the file did not change.
+func (g *GitHistory) Commit(path, message string) error {
+	rel, err := g.rel(path)
+	if err != nil {
+		return err
+	}
+	if _, err := g.git("rev-parse", "--git-dir"); err != nil {
+		if _, err := g.git("init", "-q"); err != nil {
+			return err
+		}
+	}
+	if _, err := g.git("add", "--", rel); err != nil {
+		return err
+	}
+	// diff --quiet exits with 1 if there are staged changes.
+	var exitErr *exec.ExitError
+	if _, err := g.git("diff", "--cached", "--quiet", "--", rel); err == nil {
+		return nil
+	} else if !errors.As(err, &exitErr) {
+		return err
+	}
+
+	if g.Message != "" {
+		message = g.Message
+	}
+	args := []string{"commit", "-q", "-m", message}
+	if g.Author != "" {
+		args = append(args, "--author", g.Author)
+	}
+	_, err = g.git(append(args, "--", rel)...)
+	return err
+}
+
+// Log returns the commits changing path, newest first.
+func (g *GitHistory) Log(path string) ([]Revision, error) {
+	rel, err := g.rel(path)
+	if err != nil {
+		return nil, err
+	}
+	out, err := g.git("log", "--format=%H%x00%an <%ae>%x00%aI%x00%s", "--", rel)
+	if err != nil {
+		return nil, err
+	}
+	var revs []Revision
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		fields := strings.Split(line, "\x00")
+		if len(fields) != 4 {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, fields[2])
+		if err != nil {
+			return nil, fmt.Errorf("invalid commit time %q: %w", fields[2], err)
+		}
+		revs = append(revs, Revision{Commit: fields[0], Author: fields[1], Time: t, Message: fields[3]})
+	}
+	return revs, nil
+}
+
+// Show returns the contents of path at commit.
+func (g *GitHistory) Show(commit, path string) ([]byte, error) {
+	rel, err := g.rel(path)
+	if err != nil {
+		return nil, err
+	}
+	if strings.HasPrefix(commit, "-") {
+		return nil, fmt.Errorf("invalid commit %q", commit)
+	}
+	return g.git("show", commit+":"+rel)
+}
@@ -0,0 +1,142 @@
+package manager
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/metal3-community/uefi-firmware-manager/edk2"
+	"github.com/metal3-community/uefi-firmware-manager/efi"
+	"github.com/metal3-community/uefi-firmware-manager/types"
+)
+
+// jsonVarsFileName is the per-MAC variable file of JsonEDK2Manager.
+const jsonVarsFileName = "fw-vars.json"
+
+// GenerateInventory reports on every MAC directory of dir. A directory
+// with a fw-vars.json file is read as JsonEDK2Manager data, otherwise its
+// firmware image is read. Hosts that cannot be read are listed with an
+// error; directories that are not named after a MAC address are skipped.
+func GenerateInventory(dir string, logger logr.Logger) (types.FirmwareInventory, error) {
+	inventory := types.FirmwareInventory{Generated: time.Now().UTC(), Hosts: []types.HostFirmware{}}
+
+	hosts, err := readDataDir(dir, logger)
+	if err != nil {
+		return inventory, err
+	}
+	for _, h := range hosts {
+		host := types.HostFirmware{MacAddress: h.mac.String(), Path: h.path, FirmwareVersion: h.version}
+		err := h.err
+		if err == nil {
+			var info os.FileInfo
+			if info, err = os.Stat(host.Path); err == nil {
+				host.Modified = info.ModTime().UTC()
+			}
+		}
+		if err == nil {
+			err = describeBoot(&host, h.varList)
+		}
+		if err != nil {
+			logger.Error(err, "failed to read host firmware", "path", host.Path)
+			host = types.HostFirmware{MacAddress: host.MacAddress, Path: host.Path, Error: err.Error()}
+		}
+		inventory.Hosts = append(inventory.Hosts, host)
+	}
+
+	return inventory, nil
+}
+
+// dataDirHost is a host read by readDataDir. err is set if its firmware
+// could not be read.
+type dataDirHost struct {
+	mac     net.HardwareAddr
+	path    string
+	version string
+	varList efi.EfiVarList
+	err     error
+}
+
+// readDataDir reads the firmware of every MAC directory of dir, as
+// described for GenerateInventory.
+func readDataDir(dir string, logger logr.Logger) ([]dataDirHost, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read data directory: %w", err)
+	}
+
+	var hosts []dataDirHost
+	jsonManager := &JsonEDK2Manager{dataDir: dir, storage: DirStorage(dir), logger: logger}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		mac, err := jsonManager.macFromDirName(entry.Name())
+		if err != nil {
+			continue
+		}
+
+		host := dataDirHost{mac: mac}
+		jsonPath := filepath.Join(dir, entry.Name(), jsonVarsFileName)
+		imagePath := filepath.Join(dir, entry.Name(), edk2.FirmwareFileName)
+
+		switch {
+		case fileExists(jsonPath):
+			host.path = jsonPath
+			if host.err = jsonManager.LoadMAC(mac); host.err == nil {
+				host.varList = jsonManager.variables
+				host.version, host.err = jsonManager.GetFirmwareVersion()
+			}
+		case fileExists(imagePath):
+			host.path = imagePath
+			var m FirmwareManager
+			if m, host.err = NewEDK2Manager(imagePath, logger); host.err == nil {
+				host.varList, _ = m.GetVarList()
+				host.version, host.err = m.GetFirmwareVersion()
+			}
+		default:
+			continue
+		}
+		hosts = append(hosts, host)
+	}
+	return hosts, nil
+}
+
+// describeBoot fills in the boot order and network boot state of host.
+func describeBoot(host *types.HostFirmware, varList efi.EfiVarList) error {
+	entries, err := varList.ListBootEntries()
+	if err != nil {
+		return fmt.Errorf("failed to list boot entries: %w", err)
+	}
+
+	for _, entry := range entries {
+		if entry.Attr&efi.LOAD_OPTION_ACTIVE == 0 {
+			continue
+		}
+		switch deviceCategory(&entry.DevicePath) {
+		case types.BootDevicePXE:
+			host.PXEEnabled = true
+		case types.BootDeviceHTTP:
+			host.HTTPBootEnabled = true
+		}
+	}
+
+	orderVar, found := varList.Lookup(efi.BootOrder)
+	if !found {
+		return nil
+	}
+	order, err := orderVar.GetBootOrder()
+	if err != nil {
+		return fmt.Errorf("failed to parse boot order: %w", err)
+	}
+	for _, id := range order {
+		if entry, found := entries[id]; found {
+			host.BootOrder = append(host.BootOrder, entry.Title.String())
+		} else {
+			host.BootOrder = append(host.BootOrder, fmt.Sprintf("%s%04X", efi.BootPrefix, id))
+		}
+	}
+	return nil
+}
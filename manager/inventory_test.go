@@ -0,0 +1,66 @@
+package manager
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-logr/logr"
+	"github.com/metal3-community/uefi-firmware-manager/edk2"
+)
+
+func TestGenerateInventory(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, data []byte) {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	vars, err := os.ReadFile("../efi/test/fw-test-2.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	write("d8-3a-dd-5a-44-36/fw-vars.json", vars)
+	write("d8-3a-dd-5a-44-0c/RPI_EFI.fd", edk2.RpiEfi)
+	write("aa-bb-cc-dd-ee-ff/fw-vars.json", []byte("{"))
+	write("not-a-mac/fw-vars.json", vars)
+	write("11-22-33-44-55-66/README", nil)
+
+	inventory, err := GenerateInventory(dir, logr.Discard())
+	if err != nil {
+		t.Fatalf("GenerateInventory() error = %v", err)
+	}
+	if len(inventory.Hosts) != 3 {
+		t.Fatalf("got %d hosts, want 3: %+v", len(inventory.Hosts), inventory.Hosts)
+	}
+
+	broken, image, json := inventory.Hosts[0], inventory.Hosts[1], inventory.Hosts[2]
+	if broken.MacAddress != "aa:bb:cc:dd:ee:ff" || broken.Error == "" {
+		t.Errorf("broken host = %+v, want an error", broken)
+	}
+
+	if image.MacAddress != "d8:3a:dd:5a:44:0c" || image.Error != "" {
+		t.Errorf("image host = %+v", image)
+	}
+	if image.FirmwareVersion != "UEFI Firmware v0.0.2-62-gc1c9118" {
+		t.Errorf("image FirmwareVersion = %q", image.FirmwareVersion)
+	}
+
+	if json.MacAddress != "d8:3a:dd:5a:44:36" || json.Error != "" {
+		t.Fatalf("json host = %+v", json)
+	}
+	if json.Path != filepath.Join(dir, "d8-3a-dd-5a-44-36", "fw-vars.json") || json.Modified.IsZero() {
+		t.Errorf("json host path = %q, modified = %v", json.Path, json.Modified)
+	}
+	if !json.PXEEnabled || !json.HTTPBootEnabled {
+		t.Errorf("json host PXE = %v, HTTP = %v, want both enabled", json.PXEEnabled, json.HTTPBootEnabled)
+	}
+	if len(json.BootOrder) == 0 || json.BootOrder[0] == "" {
+		t.Errorf("json host BootOrder = %v", json.BootOrder)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"net"
 	"os"
+	"path"
 	"path/filepath"
 	"slices"
 	"strings"
@@ -17,16 +18,35 @@ import (
 // JsonEDK2Manager manages UEFI firmware using JSON files organized by MAC address.
 type JsonEDK2Manager struct {
 	dataDir    string           // Base directory containing MAC subdirectories
+	storage    Storage          // Holds the MAC subdirectories
 	currentMAC net.HardwareAddr // Currently selected MAC address
 	variables  efi.EfiVarList   // Currently loaded variables
 	logger     logr.Logger
 	modified   bool // Track if variables have been modified
+
+	// VirtFwVars writes fw-vars.json exactly as virt-fw-vars --output-json
+	// would, so the files can be diffed against the Python tooling.
+	VirtFwVars bool
+
+	// Encryption, if set, encrypts fw-vars.json with AES-GCM when saving,
+	// since it can hold Wi-Fi PSKs and iSCSI credentials. Plaintext files
+	// are still loaded and are encrypted when next saved.
+	Encryption KeyProvider
+
+	// History, if set, commits fw-vars.json to a git repository on every
+	// save. It needs a local data directory.
+	History *GitHistory
+
+	// Defaults, if set, are the variables ResetToDefaults restores, for
+	// instance those of the tenant of the data directory.
+	Defaults efi.EfiVarList
 }
 
 // NewJsonEDK2Manager creates a new JSON-based EDK2 manager.
 func NewJsonEDK2Manager(dataDir string, logger logr.Logger) (*JsonEDK2Manager, error) {
 	manager := &JsonEDK2Manager{
 		dataDir:   dataDir,
+		storage:   DirStorage(dataDir),
 		variables: make(efi.EfiVarList),
 		logger:    logger,
 	}
@@ -39,14 +59,22 @@ func NewJsonEDK2Manager(dataDir string, logger logr.Logger) (*JsonEDK2Manager, e
 	return manager, nil
 }
 
+// NewJsonEDK2ManagerWithStorage creates a JSON-based EDK2 manager keeping
+// the MAC subdirectories in storage, for instance an S3Storage shared by
+// several servers.
+func NewJsonEDK2ManagerWithStorage(storage Storage, logger logr.Logger) *JsonEDK2Manager {
+	return &JsonEDK2Manager{
+		storage:   storage,
+		variables: make(efi.EfiVarList),
+		logger:    logger,
+	}
+}
+
 // LoadMAC loads variables for a specific MAC address.
 func (j *JsonEDK2Manager) LoadMAC(mac net.HardwareAddr) error {
 	j.logger.Info("Loading variables for MAC", "mac", mac.String())
 
-	macDir := j.macDirName(mac)
-	jsonPath := filepath.Join(j.dataDir, macDir, "fw-vars.json")
-
-	variables, err := j.loadVariablesFromJSON(jsonPath)
+	variables, err := j.loadVariablesFromJSON(j.varsName(mac))
 	if err != nil {
 		return fmt.Errorf("failed to load variables for MAC %s: %w", mac.String(), err)
 	}
@@ -55,6 +83,14 @@ func (j *JsonEDK2Manager) LoadMAC(mac net.HardwareAddr) error {
 	j.variables = variables
 	j.modified = false
 
+	// Keep the directory of the host from garbage collection, which only
+	// collects local data directories.
+	if j.dataDir != "" {
+		if err := MarkAccessed(j.dataDir, mac); err != nil {
+			j.logger.Error(err, "failed to mark host accessed", "mac", mac.String())
+		}
+	}
+
 	// Validate that the loaded MAC matches the directory structure
 	if err := j.validateMACConsistency(); err != nil {
 		j.logger.Info("MAC validation warning", "error", err)
@@ -65,26 +101,24 @@ func (j *JsonEDK2Manager) LoadMAC(mac net.HardwareAddr) error {
 
 // ListAvailableMACs returns all MAC addresses that have configuration directories.
 func (j *JsonEDK2Manager) ListAvailableMACs() ([]net.HardwareAddr, error) {
-	entries, err := os.ReadDir(j.dataDir)
+	names, err := j.storage.List()
 	if err != nil {
 		return nil, fmt.Errorf("failed to read data directory: %w", err)
 	}
 
 	var macs []net.HardwareAddr
-	for _, entry := range entries {
-		if entry.IsDir() {
-			mac, err := j.macFromDirName(entry.Name())
-			if err != nil {
-				j.logger.Info("Skipping invalid MAC directory", "dir", entry.Name(), "error", err)
-				continue
-			}
-
-			// Verify fw-vars.json exists
-			jsonPath := filepath.Join(j.dataDir, entry.Name(), "fw-vars.json")
-			if _, err := os.Stat(jsonPath); err == nil {
-				macs = append(macs, mac)
-			}
+	for _, name := range names {
+		// Only directories holding fw-vars.json count
+		dir, file := path.Split(name)
+		if file != jsonVarsFileName || dir == "" || strings.Contains(dir[:len(dir)-1], "/") {
+			continue
+		}
+		mac, err := j.macFromDirName(dir[:len(dir)-1])
+		if err != nil {
+			j.logger.Info("Skipping invalid MAC directory", "dir", dir, "error", err)
+			continue
 		}
+		macs = append(macs, mac)
 	}
 
 	return macs, nil
@@ -106,35 +140,56 @@ func (j *JsonEDK2Manager) macFromDirName(dirName string) (net.HardwareAddr, erro
 	return net.ParseMAC(macStr)
 }
 
-// loadVariablesFromJSON loads EFI variables from a JSON file.
+// loadVariablesFromJSON loads EFI variables from a JSON file in storage.
 func (j *JsonEDK2Manager) loadVariablesFromJSON(jsonPath string) (efi.EfiVarList, error) {
-	data, err := os.ReadFile(jsonPath)
+	data, err := j.storage.ReadFile(jsonPath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read JSON file: %w", err)
 	}
+	variables, err := j.decodeVariables(jsonPath, data)
+	if err != nil {
+		return nil, err
+	}
+
+	j.logger.Info("Loaded variables from JSON", "path", jsonPath, "count", len(variables))
+	return variables, nil
+}
+
+// decodeVariables decodes the contents of the JSON file at jsonPath,
+// decrypting them if needed.
+func (j *JsonEDK2Manager) decodeVariables(jsonPath string, data []byte) (efi.EfiVarList, error) {
+	data, err := open(j.Encryption, data, varsFileAAD(jsonPath))
+	if err != nil {
+		return nil, err
+	}
 
 	var variables efi.EfiVarList
 	if err := json.Unmarshal(data, &variables); err != nil {
 		return nil, fmt.Errorf("failed to parse JSON: %w", err)
 	}
-
-	j.logger.Info("Loaded variables from JSON", "path", jsonPath, "count", len(variables))
 	return variables, nil
 }
 
-// saveVariablesToJSON saves EFI variables to a JSON file.
+// saveVariablesToJSON saves EFI variables to a JSON file in storage.
 func (j *JsonEDK2Manager) saveVariablesToJSON(jsonPath string, variables efi.EfiVarList) error {
-	data, err := json.MarshalIndent(variables, "", "    ")
+	var data []byte
+	var err error
+	if j.VirtFwVars {
+		data, err = efi.MarshalVirtFwVars(variables)
+	} else {
+		data, err = json.MarshalIndent(variables, "", "    ")
+	}
 	if err != nil {
 		return fmt.Errorf("failed to marshal JSON: %w", err)
 	}
-
-	// Ensure directory exists
-	if err := os.MkdirAll(filepath.Dir(jsonPath), 0o755); err != nil {
-		return fmt.Errorf("failed to create directory: %w", err)
+	if j.Encryption != nil {
+		data, err = seal(j.Encryption, data, varsFileAAD(jsonPath))
+		if err != nil {
+			return fmt.Errorf("failed to encrypt variables: %w", err)
+		}
 	}
 
-	if err := os.WriteFile(jsonPath, data, 0o644); err != nil {
+	if err := j.storage.WriteFile(jsonPath, data); err != nil {
 		return fmt.Errorf("failed to write JSON file: %w", err)
 	}
 
@@ -142,13 +197,19 @@ func (j *JsonEDK2Manager) saveVariablesToJSON(jsonPath string, variables efi.Efi
 	return nil
 }
 
+// varsFileAAD returns the data an encrypted variable file is bound to: the
+// name of its MAC directory.
+func varsFileAAD(jsonPath string) []byte {
+	return []byte(filepath.Base(filepath.Dir(jsonPath)))
+}
+
 // validateMACConsistency checks if the loaded ClientId variable matches the current MAC.
 func (j *JsonEDK2Manager) validateMACConsistency() error {
 	if j.currentMAC == nil {
 		return fmt.Errorf("no MAC address loaded")
 	}
 
-	clientIdVar, exists := j.variables["ClientId"]
+	clientIdVar, exists := j.variables.Lookup("ClientId")
 	if !exists {
 		return fmt.Errorf("ClientId variable not found")
 	}
@@ -193,7 +254,7 @@ func (j *JsonEDK2Manager) GetVariable(name string) (*efi.EfiVar, error) {
 		return nil, fmt.Errorf("no variables loaded")
 	}
 
-	variable, exists := j.variables[name]
+	variable, exists := j.variables.Lookup(name)
 	if !exists {
 		return nil, fmt.Errorf("variable %s not found", name)
 	}
@@ -207,13 +268,76 @@ func (j *JsonEDK2Manager) SetVariable(name string, value *efi.EfiVar) error {
 		return fmt.Errorf("no variables loaded")
 	}
 
-	j.variables[name] = value
+	if value == nil {
+		return fmt.Errorf("variable is nil")
+	}
+
+	setVarIdentity(name, value)
+	if err := value.Validate(); err != nil {
+		return err
+	}
+	j.variables.Set(value)
 	j.modified = true
 
 	j.logger.Info("Variable updated", "name", name)
 	return nil
 }
 
+// DeleteVariable removes a specific EFI variable.
+func (j *JsonEDK2Manager) DeleteVariable(name string) error {
+	if j.variables == nil {
+		return fmt.Errorf("no variables loaded")
+	}
+
+	if _, found := j.variables.Lookup(name); !found {
+		return fmt.Errorf("variable not found: %s", name)
+	}
+	j.variables.Delete(name)
+	j.modified = true
+
+	j.logger.Info("Variable deleted", "name", name)
+	return nil
+}
+
+// GetVarList returns the loaded variable list.
+func (j *JsonEDK2Manager) GetVarList() (efi.EfiVarList, error) {
+	if j.variables == nil {
+		return nil, fmt.Errorf("no variables loaded")
+	}
+	return j.variables, nil
+}
+
+// GetVariableAsType gets a variable converted to a structured type.
+func (j *JsonEDK2Manager) GetVariableAsType(name string) (any, error) {
+	if j.variables == nil {
+		return nil, fmt.Errorf("no variables loaded")
+	}
+	v, found := j.variables.Lookup(name)
+	if !found {
+		return nil, fmt.Errorf("variable not found: %s", name)
+	}
+	return identifyAndConvertVariable(name, v)
+}
+
+// ListVariablesWithTypes returns all variables converted to structured
+// types. Variables that fail to convert are returned raw with the error.
+func (j *JsonEDK2Manager) ListVariablesWithTypes() (map[string]any, error) {
+	if j.variables == nil {
+		return nil, fmt.Errorf("no variables loaded")
+	}
+	return convertVariables(j.variables.ByName()), nil
+}
+
+// SetVariableFromType sets a variable from a structured type. Only
+// *efi.EfiVar is supported.
+func (j *JsonEDK2Manager) SetVariableFromType(name string, value any) error {
+	v, ok := value.(*efi.EfiVar)
+	if !ok {
+		return fmt.Errorf("unsupported variable type for direct assignment: %T", value)
+	}
+	return j.SetVariable(name, v)
+}
+
 // ListVariables returns all loaded variables.
 func (j *JsonEDK2Manager) ListVariables() (map[string]*efi.EfiVar, error) {
 	if j.variables == nil {
@@ -221,12 +345,7 @@ func (j *JsonEDK2Manager) ListVariables() (map[string]*efi.EfiVar, error) {
 	}
 
 	// Return a copy to prevent external modification
-	result := make(map[string]*efi.EfiVar)
-	for name, variable := range j.variables {
-		result[name] = variable
-	}
-
-	return result, nil
+	return j.variables.ByName(), nil
 }
 
 // SaveChanges saves the current variables to the JSON file.
@@ -240,18 +359,64 @@ func (j *JsonEDK2Manager) SaveChanges() error {
 		return nil
 	}
 
-	macDir := j.macDirName(j.currentMAC)
-	jsonPath := filepath.Join(j.dataDir, macDir, "fw-vars.json")
-
-	if err := j.saveVariablesToJSON(jsonPath, j.variables); err != nil {
+	if err := j.saveVariablesToJSON(j.varsName(j.currentMAC), j.variables); err != nil {
 		return fmt.Errorf("failed to save changes: %w", err)
 	}
+	if j.History != nil {
+		if err := j.History.Commit(j.varsPath(), "Update "+j.currentMAC.String()); err != nil {
+			return fmt.Errorf("failed to commit changes: %w", err)
+		}
+	}
 
 	j.modified = false
 	j.logger.Info("Changes saved", "mac", j.currentMAC.String())
 	return nil
 }
 
+// Revisions returns the commits of the configuration of the loaded MAC,
+// newest first. History must be set.
+func (j *JsonEDK2Manager) Revisions() ([]Revision, error) {
+	if j.currentMAC == nil {
+		return nil, fmt.Errorf("no MAC address loaded")
+	}
+	if j.History == nil {
+		return nil, fmt.Errorf("no history configured")
+	}
+	return j.History.Log(j.varsPath())
+}
+
+// RevertTo loads the configuration of the loaded MAC as of commit.
+// Changes are not saved; saving records the revert as a new commit.
+func (j *JsonEDK2Manager) RevertTo(commit string) error {
+	if j.currentMAC == nil {
+		return fmt.Errorf("no MAC address loaded")
+	}
+	if j.History == nil {
+		return fmt.Errorf("no history configured")
+	}
+	data, err := j.History.Show(commit, j.varsPath())
+	if err != nil {
+		return err
+	}
+	variables, err := j.decodeVariables(j.varsName(j.currentMAC), data)
+	if err != nil {
+		return fmt.Errorf("failed to load revision %s: %w", commit, err)
+	}
+	j.variables = variables
+	j.modified = true
+	return nil
+}
+
+// varsName returns the storage name of the JSON file of mac.
+func (j *JsonEDK2Manager) varsName(mac net.HardwareAddr) string {
+	return path.Join(j.macDirName(mac), jsonVarsFileName)
+}
+
+// varsPath returns the local path of the JSON file of the loaded MAC.
+func (j *JsonEDK2Manager) varsPath() string {
+	return filepath.Join(j.dataDir, filepath.FromSlash(j.varsName(j.currentMAC)))
+}
+
 // RevertChanges reloads variables from the JSON file, discarding changes.
 func (j *JsonEDK2Manager) RevertChanges() error {
 	if j.currentMAC == nil {
@@ -261,10 +426,19 @@ func (j *JsonEDK2Manager) RevertChanges() error {
 	return j.LoadMAC(j.currentMAC)
 }
 
-// ResetToDefaults resets variables to default values (implementation needed).
+// ResetToDefaults replaces the variables of the loaded MAC with a copy of
+// Defaults. Save the changes to keep them.
 func (j *JsonEDK2Manager) ResetToDefaults() error {
-	// This would need to be implemented based on default variable requirements
-	return fmt.Errorf("ResetToDefaults not yet implemented")
+	if j.Defaults == nil {
+		return fmt.Errorf("ResetToDefaults not yet implemented")
+	}
+	if j.currentMAC == nil {
+		return fmt.Errorf("no MAC address loaded")
+	}
+
+	j.variables = cloneVarList(j.Defaults)
+	j.modified = true
+	return nil
 }
 
 // UpdateFirmware generates a firmware binary with current variables.
@@ -277,7 +451,7 @@ func (j *JsonEDK2Manager) UpdateFirmware(firmwareData []byte) error {
 // GetFirmwareVersion returns firmware version information.
 func (j *JsonEDK2Manager) GetFirmwareVersion() (string, error) {
 	// Extract version from variables or return a default
-	if variable, exists := j.variables["PlatformLang"]; exists {
+	if variable, exists := j.variables.Lookup("PlatformLang"); exists {
 		return fmt.Sprintf("EDK2-JSON-%s", string(variable.Data)), nil
 	}
 	return "EDK2-JSON-Unknown", nil
@@ -288,7 +462,7 @@ func (j *JsonEDK2Manager) GetFirmwareVersion() (string, error) {
 
 // GetBootOrder returns the current boot order.
 func (j *JsonEDK2Manager) GetBootOrder() ([]string, error) {
-	_, exists := j.variables["BootOrder"]
+	_, exists := j.variables.Lookup(efi.BootOrder)
 	if !exists {
 		return []string{}, nil
 	}
@@ -340,15 +514,56 @@ func (j *JsonEDK2Manager) GetBootNext() (uint16, error) {
 	return 0, fmt.Errorf("GetBootNext not yet implemented")
 }
 
+// DeleteBootNext removes the BootNext variable.
+func (j *JsonEDK2Manager) DeleteBootNext() error {
+	return j.DeleteVariable(efi.BootNext)
+}
+
+// SetBootLast sets the fallback boot entry.
+func (j *JsonEDK2Manager) SetBootLast(entry types.BootEntry) error {
+	// Implementation needed
+	return fmt.Errorf("SetBootLast not yet implemented")
+}
+
+// GetBootLast gets the fallback boot entry.
+func (j *JsonEDK2Manager) GetBootLast() (*types.BootEntry, error) {
+	// Implementation needed
+	return nil, fmt.Errorf("GetBootLast not yet implemented")
+}
+
 // Network Management methods.
 func (j *JsonEDK2Manager) GetNetworkSettings() (types.NetworkSettings, error) {
-	// Implementation needed
-	return types.NetworkSettings{}, fmt.Errorf("GetNetworkSettings not yet implemented")
+	if j.currentMAC == nil {
+		return types.NetworkSettings{}, fmt.Errorf("no MAC address loaded")
+	}
+	return networkSettings(j.variables, j.currentMAC), nil
 }
 
+// SetNetworkSettings applies settings to the loaded MAC address. The MAC
+// address itself selects the configuration and cannot be changed here.
 func (j *JsonEDK2Manager) SetNetworkSettings(settings types.NetworkSettings) error {
-	// Implementation needed
-	return fmt.Errorf("SetNetworkSettings not yet implemented")
+	if j.currentMAC == nil {
+		return fmt.Errorf("no MAC address loaded")
+	}
+	if err := settings.Validate(); err != nil {
+		return fmt.Errorf("invalid network settings: %w", err)
+	}
+	if settings.MacAddress != "" {
+		mac, err := net.ParseMAC(settings.MacAddress)
+		if err != nil {
+			return fmt.Errorf("invalid MAC address: %w", err)
+		}
+		if !slices.Equal(mac, j.currentMAC) {
+			return fmt.Errorf("MAC address %s does not match the loaded MAC %s",
+				mac.String(), j.currentMAC.String())
+		}
+	}
+
+	if err := applyNetworkSettings(j.variables, j.currentMAC, settings); err != nil {
+		return err
+	}
+	j.modified = true
+	return nil
 }
 
 // Boot Configuration methods.
@@ -362,6 +577,19 @@ func (j *JsonEDK2Manager) EnableHTTPBoot(enable bool) error {
 	return fmt.Errorf("EnableHTTPBoot not yet implemented")
 }
 
+// GetFirmwareTimeoutSeconds returns the boot menu timeout in seconds.
+func (j *JsonEDK2Manager) GetFirmwareTimeoutSeconds() (int, error) {
+	timeoutVar, err := j.GetVariable("Timeout")
+	if err != nil {
+		return 0, err
+	}
+	timeout, err := timeoutVar.GetUint16()
+	if err != nil {
+		return 0, err
+	}
+	return int(timeout), nil
+}
+
 func (j *JsonEDK2Manager) SetFirmwareTimeoutSeconds(seconds int) error {
 	// Implementation needed
 	return fmt.Errorf("SetFirmwareTimeoutSeconds not yet implemented")
@@ -1,11 +1,21 @@
 package manager
 
 import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
 	"testing"
 
 	"github.com/go-logr/logr"
 	"github.com/metal3-community/uefi-firmware-manager/efi"
+	"github.com/metal3-community/uefi-firmware-manager/types"
 )
 
 func TestNewJsonEDK2Manager(t *testing.T) {
@@ -178,7 +188,7 @@ func TestGetSetVariable(t *testing.T) {
 
 	// Test setting a variable (create a copy with modified attributes)
 	modifiedClientId := *clientId
-	modifiedClientId.Attr = 999 // Test value
+	modifiedClientId.Attr = clientId.Attr ^ efi.EfiVariableRuntimeAccess
 
 	err = manager.SetVariable("ClientId", &modifiedClientId)
 	if err != nil {
@@ -191,8 +201,15 @@ func TestGetSetVariable(t *testing.T) {
 		t.Fatalf("Failed to get modified ClientId variable: %v", err)
 	}
 
-	if retrievedVar.Attr != 999 {
-		t.Errorf("Expected attr 999, got %d", retrievedVar.Attr)
+	if retrievedVar.Attr != modifiedClientId.Attr {
+		t.Errorf("Expected attr %d, got %d", modifiedClientId.Attr, retrievedVar.Attr)
+	}
+
+	// Invalid attribute combinations are rejected
+	invalidClientId := *clientId
+	invalidClientId.Attr = efi.EfiVariableRuntimeAccess
+	if err := manager.SetVariable("ClientId", &invalidClientId); err == nil {
+		t.Error("Expected SetVariable to reject runtime access without boot service access")
 	}
 
 	// Check that manager knows it's been modified
@@ -278,3 +295,251 @@ func TestValidateMACConsistency(t *testing.T) {
 		t.Log("MAC validation passed")
 	}
 }
+
+func TestJsonEDK2Manager_NetworkSettings(t *testing.T) {
+	dataDir := t.TempDir()
+	macDir := filepath.Join(dataDir, "d8-3a-dd-5a-44-36")
+	if err := os.MkdirAll(macDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	fixture, err := os.ReadFile("../efi/test/fw-test-2.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(macDir, "fw-vars.json"), fixture, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	manager, err := NewJsonEDK2Manager(dataDir, logr.Discard())
+	if err != nil {
+		t.Fatalf("Failed to create JsonEDK2Manager: %v", err)
+	}
+	mac, _ := net.ParseMAC("d8:3a:dd:5a:44:36")
+	if err := manager.LoadMAC(mac); err != nil {
+		t.Fatalf("Failed to load MAC: %v", err)
+	}
+
+	settings, err := manager.GetNetworkSettings()
+	if err != nil {
+		t.Fatalf("GetNetworkSettings() error = %v", err)
+	}
+	if !settings.EnableDHCP || settings.IPv6Address != "" || settings.HTTPBootURL != "" {
+		t.Errorf("unexpected initial settings %+v", settings)
+	}
+
+	want := types.NetworkSettings{
+		MacAddress:       mac.String(),
+		IPAddress:        "192.168.1.50",
+		SubnetMask:       "255.255.255.0",
+		Gateway:          "192.168.1.1",
+		DNSServers:       []string{"192.168.1.53"},
+		EnableIPv6:       true,
+		IPv6Address:      "2001:db8::50",
+		IPv6PrefixLength: 64,
+		IPv6Gateway:      "2001:db8::1",
+		IPv6DNSServers:   []string{"2001:db8::53"},
+		HTTPBootURL:      "http://192.168.1.10/boot.efi",
+	}
+	if err := manager.SetNetworkSettings(want); err != nil {
+		t.Fatalf("SetNetworkSettings() error = %v", err)
+	}
+	if err := manager.SaveChanges(); err != nil {
+		t.Fatalf("SaveChanges() error = %v", err)
+	}
+
+	// The settings survive a reload from disk.
+	if err := manager.LoadMAC(mac); err != nil {
+		t.Fatalf("Failed to reload MAC: %v", err)
+	}
+	got, err := manager.GetNetworkSettings()
+	if err != nil {
+		t.Fatalf("GetNetworkSettings() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("GetNetworkSettings() = %+v, want %+v", got, want)
+	}
+
+	// Switching back to DHCP drops the static IPv4 address.
+	want.EnableDHCP = true
+	want.IPAddress = ""
+	if err := manager.SetNetworkSettings(want); err != nil {
+		t.Fatalf("SetNetworkSettings() error = %v", err)
+	}
+	got, _ = manager.GetNetworkSettings()
+	if !got.EnableDHCP || got.IPAddress != "" || got.IPv6Address != want.IPv6Address {
+		t.Errorf("after DHCP switch got %+v", got)
+	}
+
+	other := want
+	other.MacAddress = "aa:bb:cc:dd:ee:ff"
+	if err := manager.SetNetworkSettings(other); err == nil {
+		t.Error("SetNetworkSettings() accepted a different MAC address")
+	}
+	bad := want
+	bad.EnableDHCP = false
+	bad.IPAddress = "not-an-ip"
+	if err := manager.SetNetworkSettings(bad); err == nil {
+		t.Error("SetNetworkSettings() accepted an invalid address")
+	}
+}
+
+func TestJsonEDK2Manager_Encryption(t *testing.T) {
+	dataDir := t.TempDir()
+	macDir := filepath.Join(dataDir, "d8-3a-dd-5a-44-36")
+	if err := os.MkdirAll(macDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	fixture, err := os.ReadFile("../efi/test/fw-test-2.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	varsPath := filepath.Join(macDir, "fw-vars.json")
+	if err := os.WriteFile(varsPath, fixture, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("UFM_TEST_KEY", base64.StdEncoding.EncodeToString(key))
+
+	manager, err := NewJsonEDK2Manager(dataDir, logr.Discard())
+	if err != nil {
+		t.Fatal(err)
+	}
+	manager.Encryption = EnvKey("UFM_TEST_KEY")
+	mac, _ := net.ParseMAC("d8:3a:dd:5a:44:36")
+	// Plaintext files still load.
+	if err := manager.LoadMAC(mac); err != nil {
+		t.Fatalf("LoadMAC(plaintext) error = %v", err)
+	}
+	timeout, err := manager.GetVariable("Timeout")
+	if err != nil {
+		t.Fatal(err)
+	}
+	timeout.SetUint16(9)
+	if err := manager.SetVariable("Timeout", timeout); err != nil {
+		t.Fatal(err)
+	}
+	if err := manager.SaveChanges(); err != nil {
+		t.Fatalf("SaveChanges() error = %v", err)
+	}
+
+	data, err := os.ReadFile(varsPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if json.Valid(data) || bytes.Contains(data, []byte("Timeout")) {
+		t.Fatal("SaveChanges() wrote the variables in plaintext")
+	}
+
+	if err := manager.LoadMAC(mac); err != nil {
+		t.Fatalf("LoadMAC(encrypted) error = %v", err)
+	}
+	timeout, err = manager.GetVariable("Timeout")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := timeout.GetUint16(); got != 9 {
+		t.Errorf("Timeout = %d, want 9", got)
+	}
+
+	plain, err := NewJsonEDK2Manager(dataDir, logr.Discard())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := plain.LoadMAC(mac); !errors.Is(err, ErrEncrypted) {
+		t.Errorf("LoadMAC() without key error = %v, want %v", err, ErrEncrypted)
+	}
+
+	// The file is bound to its MAC directory.
+	otherDir := filepath.Join(dataDir, "d8-3a-dd-5a-44-37")
+	if err := os.MkdirAll(otherDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(otherDir, "fw-vars.json"), data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	other, _ := net.ParseMAC("d8:3a:dd:5a:44:37")
+	if err := manager.LoadMAC(other); err == nil {
+		t.Error("LoadMAC() accepted a file copied from another host")
+	}
+}
+
+func TestJsonEDK2Manager_History(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	t.Setenv("GIT_CONFIG_GLOBAL", os.DevNull)
+	t.Setenv("GIT_COMMITTER_NAME", "uefi-firmware-manager")
+	t.Setenv("GIT_COMMITTER_EMAIL", "ufm@example.com")
+
+	dataDir := t.TempDir()
+	macDir := filepath.Join(dataDir, "d8-3a-dd-5a-44-36")
+	if err := os.MkdirAll(macDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	fixture, err := os.ReadFile("../efi/test/fw-test-2.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(macDir, "fw-vars.json"), fixture, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	manager, err := NewJsonEDK2Manager(dataDir, logr.Discard())
+	if err != nil {
+		t.Fatal(err)
+	}
+	manager.History = &GitHistory{Dir: dataDir, Author: "Alice <alice@example.com>"}
+	mac, _ := net.ParseMAC("d8:3a:dd:5a:44:36")
+	if err := manager.LoadMAC(mac); err != nil {
+		t.Fatal(err)
+	}
+
+	setTimeout := func(seconds uint16) {
+		t.Helper()
+		timeout, err := manager.GetVariable("Timeout")
+		if err != nil {
+			t.Fatal(err)
+		}
+		timeout.SetUint16(seconds)
+		if err := manager.SetVariable("Timeout", timeout); err != nil {
+			t.Fatal(err)
+		}
+		if err := manager.SaveChanges(); err != nil {
+			t.Fatalf("SaveChanges() error = %v", err)
+		}
+	}
+	setTimeout(7)
+	setTimeout(9)
+
+	revs, err := manager.Revisions()
+	if err != nil {
+		t.Fatalf("Revisions() error = %v", err)
+	}
+	if len(revs) != 2 {
+		t.Fatalf("Revisions() returned %d commits, want 2", len(revs))
+	}
+	if revs[0].Author != "Alice <alice@example.com>" || revs[0].Message != "Update d8:3a:dd:5a:44:36" {
+		t.Errorf("Revisions()[0] = %+v", revs[0])
+	}
+
+	if err := manager.RevertTo(revs[1].Commit); err != nil {
+		t.Fatalf("RevertTo() error = %v", err)
+	}
+	timeout, err := manager.GetVariable("Timeout")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := timeout.GetUint16(); got != 7 {
+		t.Errorf("Timeout after RevertTo() = %d, want 7", got)
+	}
+	if err := manager.SaveChanges(); err != nil {
+		t.Fatal(err)
+	}
+	if revs, _ := manager.Revisions(); len(revs) != 3 {
+		t.Errorf("Revisions() after reverting returned %d commits, want 3", len(revs))
+	}
+}
@@ -0,0 +1,73 @@
+package manager
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/metal3-community/uefi-firmware-manager/efi"
+)
+
+// platformLangAttr are the attributes of the PlatformLang variable.
+const platformLangAttr = efi.EFI_VARIABLE_NON_VOLATILE | efi.EFI_VARIABLE_BOOTSERVICE_ACCESS |
+	efi.EFI_VARIABLE_RUNTIME_ACCESS
+
+var (
+	platformLangKey      = efi.VarKey(efi.PlatformLangVar, efi.EFI_GLOBAL_VARIABLE_GUID)
+	platformLangCodesKey = efi.VarKey(efi.PlatformLangCodesVar, efi.EFI_GLOBAL_VARIABLE_GUID)
+)
+
+// GetPlatformLang returns the language tag of the firmware UI, or "" if
+// the firmware has no PlatformLang variable and uses its default.
+func GetPlatformLang(m FirmwareManager) (string, error) {
+	v, err := m.GetVariable(platformLangKey)
+	if err != nil {
+		return "", nil
+	}
+	lang, err := efi.ParseAscii(v.Data)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse %s: %w", efi.PlatformLangVar, err)
+	}
+	return lang, nil
+}
+
+// GetPlatformLangCodes returns the language tags the firmware supports.
+// Firmware publishes them at runtime only, so images usually lack the
+// variable and nil is returned.
+func GetPlatformLangCodes(m FirmwareManager) ([]string, error) {
+	v, err := m.GetVariable(platformLangCodesKey)
+	if err != nil {
+		return nil, nil
+	}
+	codes, err := efi.ParsePlatformLangCodes(v.Data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", efi.PlatformLangCodesVar, err)
+	}
+	return codes, nil
+}
+
+// SetPlatformLang sets the language of the firmware UI to the RFC 4646
+// language tag lang. It fails with ErrNotSupported if the firmware
+// publishes the languages it supports and lang is not one of them. The
+// firmware updates Lang to match on the next boot. Changes are not saved.
+func SetPlatformLang(m FirmwareManager, lang string) error {
+	if err := efi.ValidateLangTag(lang); err != nil {
+		return err
+	}
+	codes, err := GetPlatformLangCodes(m)
+	if err != nil {
+		return err
+	}
+	if codes != nil && !slices.Contains(codes, lang) {
+		return fmt.Errorf("language %s, supported are %s: %w", lang, strings.Join(codes, ", "), ErrNotSupported)
+	}
+
+	data, err := efi.AsciiBytes(lang)
+	if err != nil {
+		return err
+	}
+	if err := m.SetVariable(platformLangKey, &efi.EfiVar{Attr: platformLangAttr, Data: data}); err != nil {
+		return fmt.Errorf("failed to set %s: %w", efi.PlatformLangVar, err)
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package manager
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/metal3-community/uefi-firmware-manager/efi"
+)
+
+func TestPlatformLang(t *testing.T) {
+	m := newFixtureManager(t)
+
+	if lang, err := GetPlatformLang(m); err != nil || lang != "en-US" {
+		t.Fatalf("GetPlatformLang() = %q, %v, want en-US", lang, err)
+	}
+	if err := SetPlatformLang(m, "fr-FR"); err != nil {
+		t.Fatalf("SetPlatformLang() error = %v", err)
+	}
+	v, err := m.GetVariable(efi.PlatformLangVar)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []byte("fr-FR\x00"); !bytes.Equal(v.Data, want) {
+		t.Errorf("PlatformLang = %x, want %x", v.Data, want)
+	}
+	if err := SetPlatformLang(m, "fr_FR"); err == nil {
+		t.Error("SetPlatformLang() accepted an invalid tag")
+	}
+
+	// Firmware that publishes its languages is checked.
+	codes := &efi.EfiVar{Attr: efi.EFI_VARIABLE_BOOTSERVICE_ACCESS | efi.EFI_VARIABLE_RUNTIME_ACCESS, Data: []byte("en-US;de-DE\x00")}
+	if err := m.SetVariable(platformLangCodesKey, codes); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetPlatformLang(m, "fr-FR"); !errors.Is(err, ErrNotSupported) {
+		t.Errorf("SetPlatformLang() error = %v, want ErrNotSupported", err)
+	}
+	if err := SetPlatformLang(m, "de-DE"); err != nil {
+		t.Errorf("SetPlatformLang() error = %v", err)
+	}
+	if lang, err := GetPlatformLang(m); err != nil || lang != "de-DE" {
+		t.Errorf("GetPlatformLang() = %q, %v, want de-DE", lang, err)
+	}
+}
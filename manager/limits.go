@@ -0,0 +1,128 @@
+package manager
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ServeLimits bounds the load ServeFirmware takes, so that a boot storm of
+// many hosts cannot exhaust memory generating patched images at once.
+type ServeLimits struct {
+	// Rate is the number of requests per second a client may make, and
+	// Burst how many it may make at once. Clients are told by IP address,
+	// since the MAC address of a request is whatever the client claims.
+	// Zero disables the limit.
+	Rate  float64
+	Burst int
+	// MaxGenerations is the maximum number of patched images generated
+	// and served at once. Further requests wait for one to finish or for
+	// their context to be done. Zero disables the limit.
+	MaxGenerations int
+}
+
+// SetServeLimits makes ServeFirmware enforce limits. Set it before serving
+// firmware.
+func (sm *SimpleFirmwareManager) SetServeLimits(limits ServeLimits) {
+	sm.limiter = nil
+	if limits.Rate > 0 {
+		sm.limiter = newRateLimiter(limits.Rate, max(limits.Burst, 1))
+	}
+	sm.generations = nil
+	if limits.MaxGenerations > 0 {
+		sm.generations = make(chan struct{}, limits.MaxGenerations)
+	}
+}
+
+// limit reports whether the client of r may be served, and answers 429
+// Too Many Requests if not.
+func (sm *SimpleFirmwareManager) limit(w http.ResponseWriter, r *http.Request) bool {
+	if sm.limiter == nil {
+		return true
+	}
+	key, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		key = r.RemoteAddr
+	}
+	ok, wait := sm.limiter.allow(key, time.Now())
+	if !ok {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+	}
+	return ok
+}
+
+// acquireGeneration waits for a generation slot, and returns the function
+// releasing it or false if the context of r was done first.
+func (sm *SimpleFirmwareManager) acquireGeneration(r *http.Request) (func(), bool) {
+	if sm.generations == nil {
+		return func() {}, true
+	}
+	select {
+	case sm.generations <- struct{}{}:
+		return func() { <-sm.generations }, true
+	case <-r.Context().Done():
+		return nil, false
+	}
+}
+
+// rateLimiter is a token bucket per client.
+type rateLimiter struct {
+	rate  float64
+	burst float64
+
+	mu        sync.Mutex
+	buckets   map[string]*tokenBucket
+	lastPrune time.Time
+}
+
+// tokenBucket holds the tokens of a client as of last.
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// newRateLimiter returns a limiter allowing rate requests per second with
+// bursts of burst requests.
+func newRateLimiter(rate float64, burst int) *rateLimiter {
+	return &rateLimiter{rate: rate, burst: float64(burst), buckets: map[string]*tokenBucket{}}
+}
+
+// allow takes a token of key at now. If none is left it returns false and
+// how long until one is.
+func (l *rateLimiter) allow(key string, now time.Time) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.prune(now)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &tokenBucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+	}
+	b.tokens = min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+	if b.tokens < 1 {
+		return false, time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+	}
+	b.tokens--
+	return true, 0
+}
+
+// prune drops the buckets that have refilled, at most once per refill
+// period, so clients that went away are forgotten.
+func (l *rateLimiter) prune(now time.Time) {
+	refill := time.Duration(l.burst / l.rate * float64(time.Second))
+	if now.Sub(l.lastPrune) < refill {
+		return
+	}
+	l.lastPrune = now
+	for key, b := range l.buckets {
+		if now.Sub(b.last) >= refill {
+			delete(l.buckets, key)
+		}
+	}
+}
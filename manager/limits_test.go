@@ -0,0 +1,97 @@
+package manager
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/metal3-community/uefi-firmware-manager/efi"
+)
+
+func TestRateLimiter(t *testing.T) {
+	l := newRateLimiter(2, 3)
+	now := time.Unix(0, 0)
+	for i := range 3 {
+		if ok, _ := l.allow("a", now); !ok {
+			t.Fatalf("request %d of burst refused", i)
+		}
+	}
+	ok, wait := l.allow("a", now)
+	if ok || wait != 500*time.Millisecond {
+		t.Errorf("allow() after burst = %v, %v", ok, wait)
+	}
+	if ok, _ := l.allow("b", now); !ok {
+		t.Error("other client refused")
+	}
+	if ok, _ := l.allow("a", now.Add(500*time.Millisecond)); !ok {
+		t.Error("refilled token refused")
+	}
+
+	l.allow("c", now.Add(time.Hour))
+	if _, ok := l.buckets["a"]; ok || len(l.buckets) != 1 {
+		t.Errorf("idle buckets not pruned: %v", l.buckets)
+	}
+}
+
+func TestSimpleFirmwareManager_ServeLimits(t *testing.T) {
+	mgr, err := NewSimpleFirmwareManager(logr.Discard())
+	if err != nil {
+		t.Fatal(err)
+	}
+	mgr.SetServeLimits(ServeLimits{Rate: 1, Burst: 1, MaxGenerations: 1})
+	mac, _ := net.ParseMAC("d8:3a:dd:61:4d:15")
+	other, _ := net.ParseMAC("d8:3a:dd:61:4d:16")
+	request := func(ctx context.Context, remoteAddr string, mac net.HardwareAddr) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodHead, "/RPI_EFI.fd", nil).WithContext(ctx)
+		r.RemoteAddr = remoteAddr
+		w := httptest.NewRecorder()
+		mgr.ServeFirmware(w, r, mac)
+		return w
+	}
+
+	if w := request(context.Background(), "192.0.2.1:1234", mac); w.Code != http.StatusOK {
+		t.Fatalf("first request = %d", w.Code)
+	}
+	w := request(context.Background(), "192.0.2.1:1234", mac)
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "1" {
+		t.Errorf("second request = %d, Retry-After %q", w.Code, w.Header().Get("Retry-After"))
+	}
+	// Claiming another MAC address does not get a client a new budget.
+	if w := request(context.Background(), "192.0.2.1:1235", other); w.Code != http.StatusTooManyRequests {
+		t.Errorf("request for another MAC address = %d", w.Code)
+	}
+	if w := request(context.Background(), "192.0.2.2:1234", nil); w.Code != http.StatusOK {
+		t.Errorf("base firmware request of another client = %d", w.Code)
+	}
+
+	// A generation in progress makes the next one wait.
+	started, unblock := make(chan struct{}), make(chan struct{})
+	mgr.AddPrePatchHook(func(net.HardwareAddr, efi.EfiVarList) error {
+		select {
+		case started <- struct{}{}:
+			<-unblock
+		default:
+		}
+		return nil
+	})
+	done := make(chan int)
+	go func() { done <- request(context.Background(), "192.0.2.3:1234", other).Code }()
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	third, _ := net.ParseMAC("d8:3a:dd:61:4d:17")
+	w = request(ctx, "192.0.2.4:1234", third)
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("request over the generation cap = %d", w.Code)
+	}
+
+	close(unblock)
+	if code := <-done; code != http.StatusOK {
+		t.Errorf("blocked request = %d", code)
+	}
+}
@@ -46,6 +46,7 @@ type FirmwareManager interface {
 	// Boot Configuration
 	EnablePXEBoot(enable bool) error
 	EnableHTTPBoot(enable bool) error
+	GetFirmwareTimeoutSeconds() (int, error)
 	SetFirmwareTimeoutSeconds(seconds int) error
 
 	// Device Specific Settings
@@ -0,0 +1,252 @@
+// Package managertest provides test doubles for manager.FirmwareManager.
+package managertest
+
+import (
+	"net"
+
+	"github.com/metal3-community/uefi-firmware-manager/efi"
+	"github.com/metal3-community/uefi-firmware-manager/manager"
+	"github.com/metal3-community/uefi-firmware-manager/types"
+	"github.com/stretchr/testify/mock"
+)
+
+// Mock is a testify mock of manager.FirmwareManager. Set up the expected
+// calls with On, using the method name:
+//
+//	m := new(managertest.Mock)
+//	m.On("SetBootNext", uint16(1)).Return(nil)
+//	m.On("SaveChanges").Return(nil)
+//	...
+//	m.AssertExpectations(t)
+//
+// Tests that only need working firmware state rather than call
+// expectations can use manager.NewMemoryManager instead.
+type Mock struct {
+	mock.Mock
+}
+
+var _ manager.FirmwareManager = (*Mock)(nil)
+
+func (m *Mock) GetBootOrder() ([]string, error) {
+	args := m.Called()
+	v, ok := args.Get(0).([]string)
+	if !ok {
+		return nil, args.Error(1)
+	}
+	return v, args.Error(1)
+}
+
+func (m *Mock) GetBootLast() (*types.BootEntry, error) {
+	args := m.Called()
+	v, ok := args.Get(0).(*types.BootEntry)
+	if !ok {
+		return nil, args.Error(1)
+	}
+	return v, args.Error(1)
+}
+
+func (m *Mock) SetBootLast(entry types.BootEntry) error {
+	args := m.Called(entry)
+	return args.Error(0)
+}
+
+func (m *Mock) SetBootOrder(order []string) error {
+	args := m.Called(order)
+	return args.Error(0)
+}
+
+func (m *Mock) GetBootEntries() ([]types.BootEntry, error) {
+	args := m.Called()
+	v, ok := args.Get(0).([]types.BootEntry)
+	if !ok {
+		return nil, args.Error(1)
+	}
+	return v, args.Error(1)
+}
+
+func (m *Mock) AddBootEntry(entry types.BootEntry) error {
+	args := m.Called(entry)
+	return args.Error(0)
+}
+
+func (m *Mock) UpdateBootEntry(id string, entry types.BootEntry) error {
+	args := m.Called(id, entry)
+	return args.Error(0)
+}
+
+func (m *Mock) DeleteBootEntry(id string) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func (m *Mock) SetBootNext(index uint16) error {
+	args := m.Called(index)
+	return args.Error(0)
+}
+
+func (m *Mock) DeleteBootNext() error {
+	args := m.Called()
+	return args.Error(0)
+}
+
+func (m *Mock) GetBootNext() (uint16, error) {
+	args := m.Called()
+	v, ok := args.Get(0).(uint16)
+	if !ok {
+		return 0, args.Error(1)
+	}
+	return v, args.Error(1)
+}
+
+func (m *Mock) GetNetworkSettings() (types.NetworkSettings, error) {
+	args := m.Called()
+	v, ok := args.Get(0).(types.NetworkSettings)
+	if !ok {
+		var zero types.NetworkSettings
+		return zero, args.Error(1)
+	}
+	return v, args.Error(1)
+}
+
+func (m *Mock) SetNetworkSettings(settings types.NetworkSettings) error {
+	args := m.Called(settings)
+	return args.Error(0)
+}
+
+func (m *Mock) GetMacAddress() (net.HardwareAddr, error) {
+	args := m.Called()
+	v, ok := args.Get(0).(net.HardwareAddr)
+	if !ok {
+		return nil, args.Error(1)
+	}
+	return v, args.Error(1)
+}
+
+func (m *Mock) SetMacAddress(mac net.HardwareAddr) error {
+	args := m.Called(mac)
+	return args.Error(0)
+}
+
+func (m *Mock) GetVariable(name string) (*efi.EfiVar, error) {
+	args := m.Called(name)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	v, ok := args.Get(0).(*efi.EfiVar)
+	if !ok {
+		return nil, args.Error(1)
+	}
+	return v, args.Error(1)
+}
+
+func (m *Mock) SetVariable(name string, value *efi.EfiVar) error {
+	args := m.Called(name, value)
+	return args.Error(0)
+}
+
+func (m *Mock) DeleteVariable(name string) error {
+	args := m.Called(name)
+	return args.Error(0)
+}
+
+func (m *Mock) ListVariables() (map[string]*efi.EfiVar, error) {
+	args := m.Called()
+	v, ok := args.Get(0).(map[string]*efi.EfiVar)
+	if !ok {
+		return nil, args.Error(1)
+	}
+	return v, args.Error(1)
+}
+
+func (m *Mock) EnablePXEBoot(enable bool) error {
+	args := m.Called(enable)
+	return args.Error(0)
+}
+
+func (m *Mock) GetVarList() (efi.EfiVarList, error) {
+	args := m.Called()
+	v, ok := args.Get(0).(efi.EfiVarList)
+	if !ok {
+		return nil, args.Error(1)
+	}
+	return v, args.Error(1)
+}
+
+func (m *Mock) EnableHTTPBoot(enable bool) error {
+	args := m.Called(enable)
+	return args.Error(0)
+}
+
+func (m *Mock) GetFirmwareTimeoutSeconds() (int, error) {
+	args := m.Called()
+	v, ok := args.Get(0).(int)
+	if !ok {
+		return 0, args.Error(1)
+	}
+	return v, args.Error(1)
+}
+
+func (m *Mock) SetFirmwareTimeoutSeconds(seconds int) error {
+	args := m.Called(seconds)
+	return args.Error(0)
+}
+
+func (m *Mock) SetConsoleConfig(consoleName string, baudRate int) error {
+	args := m.Called(consoleName, baudRate)
+	return args.Error(0)
+}
+
+func (m *Mock) GetSystemInfo() (types.SystemInfo, error) {
+	args := m.Called()
+	v, ok := args.Get(0).(types.SystemInfo)
+	if !ok {
+		var zero types.SystemInfo
+		return zero, args.Error(1)
+	}
+	return v, args.Error(1)
+}
+
+func (m *Mock) UpdateFirmware(firmwareData []byte) error {
+	args := m.Called(firmwareData)
+	return args.Error(0)
+}
+
+func (m *Mock) GetFirmwareVersion() (string, error) {
+	args := m.Called()
+	return args.String(0), args.Error(1)
+}
+
+func (m *Mock) SaveChanges() error {
+	args := m.Called()
+	return args.Error(0)
+}
+
+func (m *Mock) RevertChanges() error {
+	args := m.Called()
+	return args.Error(0)
+}
+
+func (m *Mock) ResetToDefaults() error {
+	args := m.Called()
+	return args.Error(0)
+}
+
+// Enhanced Variable Management with Type Conversion methods.
+func (m *Mock) GetVariableAsType(name string) (any, error) {
+	args := m.Called(name)
+	return args.Get(0), args.Error(1)
+}
+
+func (m *Mock) ListVariablesWithTypes() (map[string]any, error) {
+	args := m.Called()
+	v, ok := args.Get(0).(map[string]any)
+	if !ok {
+		return nil, args.Error(1)
+	}
+	return v, args.Error(1)
+}
+
+func (m *Mock) SetVariableFromType(name string, value any) error {
+	args := m.Called(name, value)
+	return args.Error(0)
+}
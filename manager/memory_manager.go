@@ -0,0 +1,65 @@
+package manager
+
+import (
+	"fmt"
+
+	"github.com/go-logr/logr"
+	"github.com/metal3-community/uefi-firmware-manager/efi"
+)
+
+// MemoryManager keeps the variables in memory only, so firmware logic can
+// be tested or simulated without firmware images. It behaves like
+// EDK2Manager, except that there is no varstore size limit and
+// SaveChanges only records the current variables as the saved state.
+type MemoryManager struct {
+	*EDK2Manager
+
+	// saved is the state after the last save.
+	saved efi.EfiVarList
+}
+
+// NewMemoryManager returns a manager holding a copy of seed, which may be
+// nil for a firmware without variables. Lists read from fixtures with
+// efi.EfiVarList.UnmarshalJSON make convenient seeds.
+func NewMemoryManager(seed efi.EfiVarList) *MemoryManager {
+	if seed == nil {
+		seed = efi.NewEfiVarList()
+	}
+	return &MemoryManager{
+		EDK2Manager: &EDK2Manager{
+			varList: cloneVarList(seed),
+			logger:  logr.Discard(),
+		},
+		saved: cloneVarList(seed),
+	}
+}
+
+// Saved returns a copy of the variables as of the last SaveChanges.
+func (m *MemoryManager) Saved() efi.EfiVarList {
+	return cloneVarList(m.saved)
+}
+
+// SaveChanges records the current variables as the saved state.
+func (m *MemoryManager) SaveChanges() error {
+	m.saved = cloneVarList(m.varList)
+	return nil
+}
+
+// RevertChanges restores the variables of the last save.
+func (m *MemoryManager) RevertChanges() error {
+	m.varList = cloneVarList(m.saved)
+	return nil
+}
+
+// UpdateFirmware is not supported; there is no firmware image.
+func (m *MemoryManager) UpdateFirmware(firmwareData []byte) error {
+	return fmt.Errorf("firmware updates are %w in memory", ErrNotSupported)
+}
+
+// FreeSpace returns -1; memory has no varstore size limit.
+func (m *MemoryManager) FreeSpace() int {
+	return -1
+}
+
+// SetSecureWipe does nothing; memory has no stale copies.
+func (m *MemoryManager) SetSecureWipe(enable bool) {}
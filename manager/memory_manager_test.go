@@ -0,0 +1,68 @@
+package manager
+
+import (
+	"os"
+	"testing"
+
+	"github.com/metal3-community/uefi-firmware-manager/efi"
+)
+
+func TestMemoryManager(t *testing.T) {
+	data, err := os.ReadFile("../efi/test/fw-test.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	seed := efi.NewEfiVarList()
+	if err := seed.UnmarshalJSON(data); err != nil {
+		t.Fatal(err)
+	}
+	timeout := func(l efi.EfiVarList) uint16 {
+		t.Helper()
+		v, found := l.Lookup("Timeout")
+		if !found {
+			t.Fatal("Timeout not found")
+		}
+		n, _ := v.GetUint16()
+		return n
+	}
+	seedTimeout := timeout(seed)
+
+	m := NewMemoryManager(seed)
+	var _ FirmwareManager = m
+
+	if err := m.SetFirmwareTimeoutSeconds(int(seedTimeout) + 1); err != nil {
+		t.Fatal(err)
+	}
+	if got := timeout(seed); got != seedTimeout {
+		t.Errorf("seed Timeout = %d, want it unchanged at %d", got, seedTimeout)
+	}
+	if err := m.RevertChanges(); err != nil {
+		t.Fatal(err)
+	}
+	if got := timeout(m.varList); got != seedTimeout {
+		t.Errorf("Timeout after RevertChanges() = %d, want %d", got, seedTimeout)
+	}
+
+	if err := m.SetFirmwareTimeoutSeconds(42); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.SaveChanges(); err != nil {
+		t.Fatal(err)
+	}
+	if got := timeout(m.Saved()); got != 42 {
+		t.Errorf("saved Timeout = %d, want 42", got)
+	}
+
+	entries, err := m.GetBootEntries()
+	if err != nil || len(entries) == 0 {
+		t.Errorf("GetBootEntries() = %v, %v", entries, err)
+	}
+	if _, err := m.GetSystemInfo(); err != nil {
+		t.Errorf("GetSystemInfo() error = %v", err)
+	}
+
+	empty := NewMemoryManager(nil)
+	if err := empty.SetFirmwareTimeoutSeconds(3); err != nil {
+		t.Errorf("SetFirmwareTimeoutSeconds() on an empty firmware error = %v", err)
+	}
+}
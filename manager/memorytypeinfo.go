@@ -0,0 +1,38 @@
+package manager
+
+import (
+	"fmt"
+
+	"github.com/metal3-community/uefi-firmware-manager/efi"
+)
+
+var memoryTypeInformationKey = efi.VarKey(efi.MemoryTypeInformationVar,
+	efi.StringToGUID(efi.EfiMemoryTypeInformation))
+
+// GetMemoryTypeInformation returns the pages of each memory type recorded
+// by the boot manager, or nil if the firmware has not booted yet.
+func GetMemoryTypeInformation(m FirmwareManager) (efi.MemoryTypeInformation, error) {
+	v, err := m.GetVariable(memoryTypeInformationKey)
+	if err != nil {
+		return nil, nil
+	}
+	info, err := efi.NewMemoryTypeInformation(v.Data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", efi.MemoryTypeInformationVar, err)
+	}
+	return info, nil
+}
+
+// ResetMemoryTypeInformation deletes the MemoryTypeInformation variable,
+// so that the firmware measures the memory types again on the next boot.
+// This clears memory map bins sized for a different configuration. Changes
+// are not saved.
+func ResetMemoryTypeInformation(m FirmwareManager) error {
+	if _, err := m.GetVariable(memoryTypeInformationKey); err != nil {
+		return nil
+	}
+	if err := m.DeleteVariable(memoryTypeInformationKey); err != nil {
+		return fmt.Errorf("failed to delete %s: %w", efi.MemoryTypeInformationVar, err)
+	}
+	return nil
+}
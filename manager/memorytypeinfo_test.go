@@ -0,0 +1,35 @@
+package manager
+
+import (
+	"testing"
+
+	"github.com/metal3-community/uefi-firmware-manager/efi"
+)
+
+func TestMemoryTypeInformation(t *testing.T) {
+	m := newFixtureManager(t)
+
+	info, err := GetMemoryTypeInformation(m)
+	if err != nil {
+		t.Fatalf("GetMemoryTypeInformation() error = %v", err)
+	}
+	if len(info) == 0 {
+		t.Fatal("GetMemoryTypeInformation() returned no entries")
+	}
+	typed, err := m.GetVariableAsType(efi.MemoryTypeInformationVar)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := typed.(efi.MemoryTypeInformation); !ok || got.String() != info.String() {
+		t.Errorf("GetVariableAsType() = %v, want %s", typed, info)
+	}
+
+	for range 2 {
+		if err := ResetMemoryTypeInformation(m); err != nil {
+			t.Fatalf("ResetMemoryTypeInformation() error = %v", err)
+		}
+	}
+	if info, err := GetMemoryTypeInformation(m); err != nil || info != nil {
+		t.Errorf("GetMemoryTypeInformation() after reset = %v, %v, want none", info, err)
+	}
+}
@@ -0,0 +1,32 @@
+package manager
+
+import (
+	"sort"
+
+	"github.com/metal3-community/uefi-firmware-manager/efi"
+	"github.com/metal3-community/uefi-firmware-manager/types"
+)
+
+// ListNamespaces returns the vendor GUIDs of the variables of m with the
+// number and size of their variables, ordered by GUID.
+func ListNamespaces(m FirmwareManager) ([]types.VariableNamespace, error) {
+	varList, err := m.GetVarList()
+	if err != nil {
+		return nil, err
+	}
+
+	groups := varList.GroupByGuid()
+	namespaces := make([]types.VariableNamespace, 0, len(groups))
+	for guid, vars := range groups {
+		ns := types.VariableNamespace{Guid: guid.String(), Count: len(vars)}
+		if name := efi.GuidName(guid); name != ns.Guid {
+			ns.Name = name
+		}
+		for _, v := range vars {
+			ns.Bytes += v.Name.Size() + len(v.Data)
+		}
+		namespaces = append(namespaces, ns)
+	}
+	sort.Slice(namespaces, func(i, j int) bool { return namespaces[i].Guid < namespaces[j].Guid })
+	return namespaces, nil
+}
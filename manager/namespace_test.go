@@ -0,0 +1,33 @@
+package manager
+
+import (
+	"testing"
+
+	"github.com/metal3-community/uefi-firmware-manager/efi"
+)
+
+func TestListNamespaces(t *testing.T) {
+	m := newFixtureManager(t)
+	namespaces, err := ListNamespaces(m)
+	if err != nil {
+		t.Fatalf("ListNamespaces() error = %v", err)
+	}
+
+	vars, err := m.ListVariables()
+	if err != nil {
+		t.Fatal(err)
+	}
+	count := 0
+	for i, ns := range namespaces {
+		count += ns.Count
+		if i > 0 && ns.Guid <= namespaces[i-1].Guid {
+			t.Errorf("namespaces not ordered by GUID: %s after %s", ns.Guid, namespaces[i-1].Guid)
+		}
+		if ns.Guid == efi.EFI_GLOBAL_VARIABLE && (ns.Name == "" || ns.Bytes == 0) {
+			t.Errorf("global namespace = %+v", ns)
+		}
+	}
+	if count != len(vars) {
+		t.Errorf("namespaces count %d variables, want %d", count, len(vars))
+	}
+}
@@ -0,0 +1,350 @@
+package manager
+
+import (
+	"fmt"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/metal3-community/uefi-firmware-manager/efi"
+	"github.com/metal3-community/uefi-firmware-manager/types"
+)
+
+// ipConfigAttr are the attributes EDK2 gives the IP configuration
+// variables.
+const ipConfigAttr = efi.EFI_VARIABLE_NON_VOLATILE | efi.EFI_VARIABLE_BOOTSERVICE_ACCESS
+
+// networkSettings reads the network settings stored in vl. The address
+// settings are read from the IP configuration of the interface mac and
+// are skipped when mac is nil.
+func networkSettings(vl efi.EfiVarList, mac net.HardwareAddr) types.NetworkSettings {
+	settings := types.NetworkSettings{
+		EnableDHCP: true, // Default to DHCP enabled
+	}
+	if mac != nil {
+		settings.MacAddress = mac.String()
+	}
+
+	// Get IPv6 enabled setting
+	ipv6Var, found := vl.Lookup("IPv6Support")
+	if found {
+		ipv6Enabled, err := ipv6Var.GetUint32()
+		if err == nil {
+			settings.EnableIPv6 = ipv6Enabled != 0
+		}
+	}
+
+	// Get VLAN settings
+	vlanVar, found := vl.Lookup("VLANEnable")
+	if found {
+		vlanEnabled, err := vlanVar.GetUint32()
+		if err == nil {
+			settings.VLANEnabled = vlanEnabled != 0
+		}
+	}
+
+	vlanIDVar, found := vl.Lookup("VLANID")
+	if found {
+		vlanID, err := vlanIDVar.GetUint32()
+		if err == nil {
+			settings.VLANID = fmt.Sprintf("%d", vlanID)
+		}
+	}
+
+	if mac != nil {
+		name := efi.IPConfigVarName(mac)
+		if v, found := vl.Get(name, efi.StringToGUID(efi.EfiIp4Config2Protocol)); found {
+			if cfg, err := efi.ParseIP4Config(v.Data); err == nil {
+				settings.EnableDHCP = cfg.DHCP
+				if cfg.Address != nil {
+					settings.IPAddress = cfg.Address.String()
+					settings.SubnetMask = net.IP(cfg.SubnetMask).String()
+				}
+				if cfg.Gateway != nil {
+					settings.Gateway = cfg.Gateway.String()
+				}
+				settings.DNSServers = ipStrings(cfg.DNSServers)
+			}
+		}
+		if v, found := vl.Get(name, efi.StringToGUID(efi.EfiIp6ConfigProtocol)); found {
+			if cfg, err := efi.ParseIP6Config(v.Data); err == nil && !cfg.Automatic {
+				if cfg.Address != nil {
+					settings.IPv6Address = cfg.Address.String()
+					settings.IPv6PrefixLength = int(cfg.PrefixLength)
+				}
+				if cfg.Gateway != nil {
+					settings.IPv6Gateway = cfg.Gateway.String()
+				}
+				settings.IPv6DNSServers = ipStrings(cfg.DNSServers)
+			}
+		}
+	}
+
+	if _, entry := httpBootEntry(vl); entry != nil {
+		settings.HTTPBootURL = string(uriElem(&entry.DevicePath).Data)
+	}
+
+	return settings
+}
+
+// applyNetworkSettings stores settings in vl. A static IPv4 address is
+// written when DHCP is disabled and IPAddress is set, and a static IPv6
+// address when IPv6Address is set. Address settings are keyed by the
+// interface MAC address, so they need mac.
+func applyNetworkSettings(vl efi.EfiVarList, mac net.HardwareAddr, settings types.NetworkSettings) error {
+	// Set IPv6 support
+	ipv6Var := varListGetOrCreate(vl, "IPv6Support", efi.EFI_GLOBAL_VARIABLE)
+	ipv6Var.SetUint32(boolToUint32(settings.EnableIPv6))
+
+	// Set VLAN settings
+	vlanVar := varListGetOrCreate(vl, "VLANEnable", efi.EFI_GLOBAL_VARIABLE)
+	vlanVar.SetUint32(boolToUint32(settings.VLANEnabled))
+
+	if settings.VLANEnabled && settings.VLANID != "" {
+		vlanID, err := strconv.ParseUint(settings.VLANID, 10, 32)
+		if err != nil {
+			return fmt.Errorf("invalid VLAN ID: %w", err)
+		}
+
+		vlanIDVar := varListGetOrCreate(vl, "VLANID", efi.EFI_GLOBAL_VARIABLE)
+		vlanIDVar.SetUint32(uint32(vlanID))
+	}
+
+	if mac == nil {
+		if settings.IPAddress != "" || settings.IPv6Address != "" || settings.HTTPBootURL != "" {
+			return fmt.Errorf("a MAC address is required to configure addresses")
+		}
+		return nil
+	}
+
+	if err := applyIP4Settings(vl, mac, settings); err != nil {
+		return err
+	}
+	if err := applyIP6Settings(vl, mac, settings); err != nil {
+		return err
+	}
+	if settings.HTTPBootURL != "" {
+		if err := setHTTPBootURL(vl, mac, settings); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func applyIP4Settings(vl efi.EfiVarList, mac net.HardwareAddr, settings types.NetworkSettings) error {
+	guid := efi.StringToGUID(efi.EfiIp4Config2Protocol)
+	name := efi.IPConfigVarName(mac)
+	v, found := vl.Get(name, guid)
+	switch {
+	case !found && settings.EnableDHCP:
+		// DHCP is the default policy, there is nothing to store.
+		return nil
+	case !found && settings.IPAddress == "":
+		// Neither DHCP nor a static address was asked for.
+		return nil
+	}
+
+	cfg := &efi.IP4Config{}
+	if found {
+		if parsed, err := efi.ParseIP4Config(v.Data); err == nil {
+			cfg = parsed
+		}
+	} else {
+		v = varListGetOrCreate(vl, name, efi.EfiIp4Config2Protocol)
+	}
+	v.Attr = ipConfigAttr
+
+	cfg.DHCP = settings.EnableDHCP
+	if !cfg.DHCP {
+		ip := net.ParseIP(settings.IPAddress).To4()
+		if ip == nil {
+			return fmt.Errorf("invalid IPv4 address: %q", settings.IPAddress)
+		}
+		mask := net.ParseIP(settings.SubnetMask).To4()
+		if mask == nil {
+			return fmt.Errorf("invalid subnet mask: %q", settings.SubnetMask)
+		}
+		cfg.Address = ip
+		cfg.SubnetMask = net.IPMask(mask)
+
+		cfg.Gateway = nil
+		if settings.Gateway != "" {
+			if cfg.Gateway = net.ParseIP(settings.Gateway).To4(); cfg.Gateway == nil {
+				return fmt.Errorf("invalid IPv4 gateway: %q", settings.Gateway)
+			}
+		}
+
+		dns, err := parseIPs(settings.DNSServers, true)
+		if err != nil {
+			return err
+		}
+		cfg.DNSServers = dns
+	}
+
+	v.Data = cfg.Bytes()
+	return nil
+}
+
+func applyIP6Settings(vl efi.EfiVarList, mac net.HardwareAddr, settings types.NetworkSettings) error {
+	guid := efi.StringToGUID(efi.EfiIp6ConfigProtocol)
+	name := efi.IPConfigVarName(mac)
+	v, found := vl.Get(name, guid)
+	if !found && settings.IPv6Address == "" {
+		// Nothing stored and nothing to store.
+		return nil
+	}
+
+	cfg := &efi.IP6Config{}
+	if found {
+		if parsed, err := efi.ParseIP6Config(v.Data); err == nil {
+			cfg = parsed
+		}
+	} else {
+		v = varListGetOrCreate(vl, name, efi.EfiIp6ConfigProtocol)
+	}
+	v.Attr = ipConfigAttr
+
+	cfg.Automatic = settings.IPv6Address == ""
+	cfg.Address, cfg.Gateway, cfg.DNSServers, cfg.PrefixLength = nil, nil, nil, 0
+	if !cfg.Automatic {
+		ip := net.ParseIP(settings.IPv6Address)
+		if ip == nil || ip.To4() != nil {
+			return fmt.Errorf("invalid IPv6 address: %q", settings.IPv6Address)
+		}
+		if settings.IPv6PrefixLength < 0 || settings.IPv6PrefixLength > 128 {
+			return fmt.Errorf("invalid IPv6 prefix length: %d", settings.IPv6PrefixLength)
+		}
+		cfg.Address = ip
+		cfg.PrefixLength = uint8(settings.IPv6PrefixLength)
+
+		if settings.IPv6Gateway != "" {
+			gw := net.ParseIP(settings.IPv6Gateway)
+			if gw == nil || gw.To4() != nil {
+				return fmt.Errorf("invalid IPv6 gateway: %q", settings.IPv6Gateway)
+			}
+			cfg.Gateway = gw
+		}
+
+		dns, err := parseIPs(settings.IPv6DNSServers, false)
+		if err != nil {
+			return err
+		}
+		cfg.DNSServers = dns
+	}
+
+	v.Data = cfg.Bytes()
+	return nil
+}
+
+// httpBootEntry returns the lowest numbered boot entry with a URI node
+// and its number.
+func httpBootEntry(vl efi.EfiVarList) (uint16, *efi.BootEntry) {
+	entries, err := vl.ListBootEntries()
+	if err != nil {
+		return 0, nil
+	}
+	ids := make([]uint16, 0, len(entries))
+	for id := range entries {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+	for _, id := range ids {
+		if uriElem(&entries[id].DevicePath) != nil {
+			return id, entries[id]
+		}
+	}
+	return 0, nil
+}
+
+// uriElem returns the URI node of a device path, or nil.
+func uriElem(dp *efi.DevicePath) *efi.DevicePathElem {
+	for _, elem := range dp.Elems() {
+		if elem.Devtype == efi.DevTypeMessage && elem.Subtype == efi.DevSubTypeURI {
+			return elem
+		}
+	}
+	return nil
+}
+
+// setHTTPBootURL points the HTTP boot entry at settings.HTTPBootURL,
+// creating the entry if there is none.
+func setHTTPBootURL(vl efi.EfiVarList, mac net.HardwareAddr, settings types.NetworkSettings) error {
+	if id, entry := httpBootEntry(vl); entry != nil {
+		uriElem(&entry.DevicePath).Data = []byte(settings.HTTPBootURL)
+		v, _ := vl.Get(fmt.Sprintf("%s%04X", efi.BootPrefix, id), efi.EFI_GLOBAL_VARIABLE_GUID)
+		data, err := entry.Serialize()
+		if err != nil {
+			return fmt.Errorf("failed to update HTTP boot entry: %w", err)
+		}
+		v.Data = data
+		return nil
+	}
+
+	dp := (&efi.DevicePath{}).Mac(mac)
+	family := "IPv4"
+	if settings.IPAddress == "" && settings.IPv6Address != "" {
+		dp.IPv6()
+		family = "IPv6"
+	} else {
+		dp.IPv4()
+	}
+	dp.URI(settings.HTTPBootURL)
+
+	macStr := strings.ToUpper(strings.ReplaceAll(mac.String(), ":", ""))
+	for index := uint16(0); index < 0xffff; index++ {
+		name := fmt.Sprintf("%s%04X", efi.BootPrefix, index)
+		if _, ok := vl.Get(name, efi.EFI_GLOBAL_VARIABLE_GUID); ok {
+			continue
+		}
+		v := varListGetOrCreate(vl, name, efi.EFI_GLOBAL_VARIABLE)
+		title := fmt.Sprintf("UEFI HTTP%s (MAC:%s)", family, macStr)
+		if err := v.SetBootEntryDevicePath(efi.LOAD_OPTION_ACTIVE, title, dp, nil); err != nil {
+			return err
+		}
+		return vl.AppendBootOrder(index)
+	}
+	return fmt.Errorf("no free boot entry slots")
+}
+
+// varListGetOrCreate returns the named variable, adding an empty one to
+// vl if it does not exist.
+func varListGetOrCreate(vl efi.EfiVarList, name, guidStr string) *efi.EfiVar {
+	guid := efi.StringToGUID(guidStr)
+	v, found := vl.Get(name, guid)
+	if found {
+		return v
+	}
+
+	// Create a new variable
+	v = &efi.EfiVar{
+		Name: efi.NewUCS16String(name),
+		Guid: guid,
+		Attr: efi.EFI_VARIABLE_NON_VOLATILE |
+			efi.EFI_VARIABLE_BOOTSERVICE_ACCESS |
+			efi.EFI_VARIABLE_RUNTIME_ACCESS,
+	}
+	vl.Set(v)
+
+	return v
+}
+
+func parseIPs(addrs []string, ipv4 bool) ([]net.IP, error) {
+	ips := make([]net.IP, 0, len(addrs))
+	for _, addr := range addrs {
+		ip := net.ParseIP(addr)
+		if ip == nil || (ip.To4() != nil) != ipv4 {
+			return nil, fmt.Errorf("invalid DNS server: %q", addr)
+		}
+		ips = append(ips, ip)
+	}
+	return ips, nil
+}
+
+func ipStrings(ips []net.IP) []string {
+	var addrs []string
+	for _, ip := range ips {
+		addrs = append(addrs, ip.String())
+	}
+	return addrs
+}
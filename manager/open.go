@@ -0,0 +1,112 @@
+package manager
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/go-logr/logr"
+	"github.com/metal3-community/uefi-firmware-manager/efi"
+)
+
+// Open detects the kind of firmware at path and returns its manager:
+//
+//   - a JSON data directory, a MAC directory in one, or its fw-vars.json
+//     file opens a JsonEDK2Manager. The MAC is loaded if there is only
+//     one; otherwise select it with SetMacAddress.
+//   - a directory of <Name>-<GUID> files, such as varstore.EfivarfsDir,
+//     opens an EfivarfsManager.
+//   - a file starting with the variable store volume, as OVMF and AAVMF
+//     VARS files do, opens an OVMFManager.
+//   - any other file opens an EDK2Manager.
+func Open(path string, logger logr.Logger) (FirmwareManager, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if !info.IsDir() {
+		if filepath.Base(path) == jsonVarsFileName {
+			return openJSON(filepath.Dir(filepath.Dir(path)), filepath.Base(filepath.Dir(path)), logger)
+		}
+		ovmf, err := isVarsFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if ovmf {
+			return NewOVMFManager(path, logger)
+		}
+		return NewEDK2Manager(path, logger)
+	}
+
+	if _, err := os.Stat(filepath.Join(path, jsonVarsFileName)); err == nil {
+		return openJSON(filepath.Dir(path), filepath.Base(path), logger)
+	}
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			if _, err := os.Stat(filepath.Join(path, entry.Name(), jsonVarsFileName)); err == nil {
+				return openJSON(path, "", logger)
+			}
+			continue
+		}
+		if _, _, ok := efi.ParseVarKey(entry.Name()); ok {
+			return NewEfivarfsManager(path, logger)
+		}
+	}
+	return nil, fmt.Errorf("%s holds neither JSON configurations nor efivarfs variables", path)
+}
+
+// openJSON opens the JSON data directory dataDir and loads the MAC of the
+// directory macDir, or the only MAC if macDir is empty.
+func openJSON(dataDir, macDir string, logger logr.Logger) (FirmwareManager, error) {
+	j, err := NewJsonEDK2Manager(dataDir, logger)
+	if err != nil {
+		return nil, err
+	}
+	if macDir != "" {
+		mac, err := j.macFromDirName(macDir)
+		if err != nil {
+			return nil, fmt.Errorf("invalid MAC directory %s: %w", macDir, err)
+		}
+		if err := j.LoadMAC(mac); err != nil {
+			return nil, err
+		}
+		return j, nil
+	}
+
+	macs, err := j.ListAvailableMACs()
+	if err != nil {
+		return nil, err
+	}
+	if len(macs) == 1 {
+		if err := j.LoadMAC(macs[0]); err != nil {
+			return nil, err
+		}
+	}
+	return j, nil
+}
+
+// isVarsFile reports whether the file at path starts with the variable
+// store firmware volume, as VARS files do. Full firmware images start with
+// the code volume or a reset vector instead.
+func isVarsFile(path string) (bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	header := make([]byte, 48)
+	if _, err := io.ReadFull(f, header); err != nil {
+		// Too short for a volume; let the EDK2 manager report the error.
+		return false, nil
+	}
+	return efi.ParseBinGUID(header, 16) == efi.StringToGUID(efi.NvData) &&
+		bytes.Equal(header[40:44], []byte("_FVH")), nil
+}
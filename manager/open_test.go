@@ -0,0 +1,94 @@
+package manager
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-logr/logr"
+	"github.com/metal3-community/uefi-firmware-manager/efi"
+	"github.com/metal3-community/uefi-firmware-manager/varstore"
+)
+
+func TestOpen(t *testing.T) {
+	firmware, err := os.ReadFile("../edk2/RPI_EFI.fd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rpi := filepath.Join(t.TempDir(), "RPI_EFI.fd")
+	if err := os.WriteFile(rpi, firmware, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	dataDir := t.TempDir()
+	macDir := filepath.Join(dataDir, "d8-3a-dd-5a-44-36")
+	if err := os.MkdirAll(macDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	fixture, err := os.ReadFile("../efi/test/fw-test-2.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(macDir, jsonVarsFileName), fixture, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	efivars := t.TempDir()
+	varlist := efi.NewEfiVarList()
+	varlist.Set(&efi.EfiVar{
+		Name: efi.FromString("Timeout"),
+		Guid: efi.EFI_GLOBAL_VARIABLE_GUID,
+		Attr: efi.EfiVariableDefault | efi.EfiVariableRuntimeAccess,
+		Data: []byte{0x05, 0x00},
+	})
+	if err := varstore.NewEfivarfsVarStore(efivars).WriteVarStore("", varlist); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{rpi, "*manager.EDK2Manager"},
+		{newOVMFVars(t), "*manager.OVMFManager"},
+		{dataDir, "*manager.JsonEDK2Manager"},
+		{macDir, "*manager.JsonEDK2Manager"},
+		{filepath.Join(macDir, jsonVarsFileName), "*manager.JsonEDK2Manager"},
+		{efivars, "*manager.EfivarfsManager"},
+	}
+	for _, tt := range tests {
+		m, err := Open(tt.path, logr.Discard())
+		if err != nil {
+			t.Errorf("Open(%s) error = %v", tt.path, err)
+			continue
+		}
+		if got := fmt.Sprintf("%T", m); got != tt.want {
+			t.Errorf("Open(%s) = %s, want %s", tt.path, got, tt.want)
+		}
+		varList, err := m.GetVarList()
+		if err != nil {
+			t.Errorf("Open(%s).GetVarList() error = %v", tt.path, err)
+		}
+		if tt.want != "*manager.JsonEDK2Manager" {
+			continue
+		}
+		tag, err := m.GetVariableAsType("AssetTag")
+		if err != nil {
+			t.Errorf("Open(%s).GetVariableAsType() error = %v", tt.path, err)
+		} else if _, raw := tag.(*efi.EfiVar); raw {
+			t.Errorf("Open(%s).GetVariableAsType() = %T, want a converted asset tag", tt.path, tag)
+		}
+		if _, err := m.GetVariableAsType("NoSuchVariable"); err == nil {
+			t.Errorf("Open(%s).GetVariableAsType() of a missing variable succeeded", tt.path)
+		}
+		typed, err := m.ListVariablesWithTypes()
+		if err != nil || len(typed) != len(varList) || typed["AssetTag"] == nil {
+			t.Errorf("Open(%s).ListVariablesWithTypes() = %d variables, %v; want %d", tt.path, len(typed), err, len(varList))
+		}
+	}
+
+	if _, err := Open(t.TempDir(), logr.Discard()); err == nil {
+		t.Error("Open() of an empty directory succeeded")
+	}
+}
@@ -0,0 +1,74 @@
+package manager
+
+import (
+	"fmt"
+
+	"github.com/metal3-community/uefi-firmware-manager/efi"
+)
+
+// osIndicationsAttr are the attributes of the OsIndications variable.
+const osIndicationsAttr = efi.EFI_VARIABLE_NON_VOLATILE | efi.EFI_VARIABLE_BOOTSERVICE_ACCESS |
+	efi.EFI_VARIABLE_RUNTIME_ACCESS
+
+var (
+	osIndicationsKey          = efi.VarKey(efi.OsIndicationsVar, efi.EFI_GLOBAL_VARIABLE_GUID)
+	osIndicationsSupportedKey = efi.VarKey(efi.OsIndicationsSupportedVar, efi.EFI_GLOBAL_VARIABLE_GUID)
+)
+
+// GetOsIndications returns the pending OS requests to the firmware. A
+// firmware without the variable has none pending.
+func GetOsIndications(m FirmwareManager) (efi.OsIndications, error) {
+	return osIndications(m, efi.OsIndicationsVar)
+}
+
+// SetOsIndications replaces the pending OS requests to the firmware.
+// Changes are not saved.
+func SetOsIndications(m FirmwareManager, ind efi.OsIndications) error {
+	if err := m.SetVariable(osIndicationsKey, &efi.EfiVar{Attr: osIndicationsAttr, Data: ind.Bytes()}); err != nil {
+		return fmt.Errorf("failed to set %s: %w", efi.OsIndicationsVar, err)
+	}
+	return nil
+}
+
+// GetOsIndicationsSupported returns the requests the firmware supports.
+// Firmware publishes them at runtime only, so images usually lack the
+// variable and 0 is returned.
+func GetOsIndicationsSupported(m FirmwareManager) (efi.OsIndications, error) {
+	return osIndications(m, efi.OsIndicationsSupportedVar)
+}
+
+// RequestBootToFirmwareUI makes the firmware enter its setup UI on the next
+// boot, once, like SetBootNext does for a boot entry. Other pending
+// requests are kept. It fails with ErrNotSupported if the firmware
+// publishes the requests it supports and this is not one of them. Changes
+// are not saved.
+func RequestBootToFirmwareUI(m FirmwareManager) error {
+	if v, err := m.GetVariable(osIndicationsSupportedKey); err == nil {
+		supported, err := efi.NewOsIndications(v.Data)
+		if err != nil {
+			return fmt.Errorf("failed to parse %s: %w", efi.OsIndicationsSupportedVar, err)
+		}
+		if !supported.Has(efi.OsIndicationsBootToFwUI) {
+			return fmt.Errorf("boot to firmware UI: %w", ErrNotSupported)
+		}
+	}
+
+	ind, err := GetOsIndications(m)
+	if err != nil {
+		return err
+	}
+	return SetOsIndications(m, ind|efi.OsIndicationsBootToFwUI)
+}
+
+// osIndications decodes the variable name, returning 0 if it is missing.
+func osIndications(m FirmwareManager, name string) (efi.OsIndications, error) {
+	v, err := m.GetVariable(efi.VarKey(name, efi.EFI_GLOBAL_VARIABLE_GUID))
+	if err != nil {
+		return 0, nil
+	}
+	ind, err := efi.NewOsIndications(v.Data)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse %s: %w", name, err)
+	}
+	return ind, nil
+}
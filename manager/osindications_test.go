@@ -0,0 +1,42 @@
+package manager
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/metal3-community/uefi-firmware-manager/efi"
+)
+
+func TestRequestBootToFirmwareUI(t *testing.T) {
+	m := newFixtureManager(t)
+
+	if ind, err := GetOsIndications(m); err != nil || ind != 0 {
+		t.Fatalf("GetOsIndications() = %s, %v, want none pending", ind, err)
+	}
+	if err := SetOsIndications(m, efi.OsIndicationsFileCapsuleDeliverySupported); err != nil {
+		t.Fatalf("SetOsIndications() error = %v", err)
+	}
+	if err := RequestBootToFirmwareUI(m); err != nil {
+		t.Fatalf("RequestBootToFirmwareUI() error = %v", err)
+	}
+	ind, err := GetOsIndications(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := efi.OsIndicationsBootToFwUI | efi.OsIndicationsFileCapsuleDeliverySupported; ind != want {
+		t.Errorf("GetOsIndications() = %s, want %s", ind, want)
+	}
+	typed, err := m.GetVariableAsType(efi.OsIndicationsVar)
+	if err != nil || typed != ind {
+		t.Errorf("GetVariableAsType() = %v, %v, want %s", typed, err, ind)
+	}
+
+	// Firmware that publishes its supported requests is checked.
+	supported := &efi.EfiVar{Attr: osIndicationsAttr, Data: efi.OsIndicationsStartOsRecovery.Bytes()}
+	if err := m.SetVariable(osIndicationsSupportedKey, supported); err != nil {
+		t.Fatal(err)
+	}
+	if err := RequestBootToFirmwareUI(m); !errors.Is(err, ErrNotSupported) {
+		t.Errorf("RequestBootToFirmwareUI() error = %v, want ErrNotSupported", err)
+	}
+}
@@ -0,0 +1,73 @@
+package manager
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/go-logr/logr"
+	"github.com/metal3-community/uefi-firmware-manager/types"
+	"github.com/metal3-community/uefi-firmware-manager/varstore"
+)
+
+// ErrNotSupported is returned by managers for operations their firmware
+// cannot perform, such as Raspberry Pi settings on OVMF.
+var ErrNotSupported = errors.New("not supported")
+
+// OVMFManager manages the VARS file of an OVMF or AAVMF virtual machine,
+// as used by libvirt and QEMU. Boot entry, network and variable methods
+// work as with EDK2Manager; the firmware code lives in a separate file and
+// is not touched.
+type OVMFManager struct {
+	*EDK2Manager
+}
+
+// NewOVMFManager opens an existing OVMF or AAVMF VARS file, for instance
+// one copied from OVMF_VARS.fd for a new guest.
+func NewOVMFManager(varsPath string, logger logr.Logger) (*OVMFManager, error) {
+	data, err := os.ReadFile(varsPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read VARS file: %w", err)
+	}
+	vs, err := varstore.New(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse VARS file %s: %w", varsPath, err)
+	}
+	vs.Logger = logger.WithName("edk2-varstore")
+
+	m := &EDK2Manager{
+		firmwarePath: varsPath,
+		varStore:     vs,
+		logger:       logger.WithName("ovmf-manager"),
+	}
+	if err := m.loadVarList(); err != nil {
+		return nil, err
+	}
+	return &OVMFManager{EDK2Manager: m}, nil
+}
+
+// SetConsoleConfig is not supported; OVMF consoles are configured by the
+// QEMU command line.
+func (m *OVMFManager) SetConsoleConfig(consoleName string, baudRate int) error {
+	return fmt.Errorf("console settings are %w by OVMF", ErrNotSupported)
+}
+
+// GetSystemInfo returns the firmware version; OVMF has no board settings.
+func (m *OVMFManager) GetSystemInfo() (types.SystemInfo, error) {
+	version, err := m.GetFirmwareVersion()
+	if err != nil {
+		return types.SystemInfo{}, err
+	}
+	return types.SystemInfo{FirmwareVersion: version}, nil
+}
+
+// UpdateFirmware is not supported; the firmware code is in the OVMF_CODE
+// file, which is shared between guests.
+func (m *OVMFManager) UpdateFirmware(firmwareData []byte) error {
+	return fmt.Errorf("firmware updates are %w by OVMF", ErrNotSupported)
+}
+
+// ResetToDefaults is not supported; copy the OVMF_VARS template instead.
+func (m *OVMFManager) ResetToDefaults() error {
+	return fmt.Errorf("resetting to defaults is %w by OVMF; copy the VARS template instead", ErrNotSupported)
+}
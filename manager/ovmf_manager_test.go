@@ -0,0 +1,82 @@
+package manager
+
+import (
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-logr/logr"
+	"github.com/metal3-community/uefi-firmware-manager/efi"
+	"github.com/metal3-community/uefi-firmware-manager/testutil"
+	"github.com/metal3-community/uefi-firmware-manager/types"
+)
+
+// newOVMFVars writes an empty VARS file laid out like OVMF_VARS.fd: a
+// firmware volume holding the authenticated varstore, padded to 528 KiB.
+func newOVMFVars(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "OVMF_VARS.fd")
+	if err := os.WriteFile(path, testutil.EmptyVolume(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestOVMFManager(t *testing.T) {
+	path := newOVMFVars(t)
+	m, err := NewOVMFManager(path, logr.Discard())
+	if err != nil {
+		t.Fatalf("NewOVMFManager() error = %v", err)
+	}
+	var _ FirmwareManager = m
+
+	mac, _ := net.ParseMAC("52:54:00:12:34:56")
+	dp := (&efi.DevicePath{}).Mac(mac).IPv4()
+	entry := types.BootEntry{Name: "UEFI PXEv4", Enabled: true, DevicePath: devicePathNodes(dp)}
+	if err := m.AddBootEntry(entry); err != nil {
+		t.Fatalf("AddBootEntry() error = %v", err)
+	}
+	if err := m.SetBootOrder([]string{"0000"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.SetFirmwareTimeoutSeconds(3); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.SaveChanges(); err != nil {
+		t.Fatalf("SaveChanges() error = %v", err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size() != 0x84000 {
+		t.Errorf("VARS file size = %#x, want %#x", info.Size(), 0x84000)
+	}
+
+	reloaded, err := NewOVMFManager(path, logr.Discard())
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries, err := reloaded.GetBootEntries()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].DeviceCategory != types.BootDevicePXE {
+		t.Errorf("GetBootEntries() = %+v, want one PXE entry", entries)
+	}
+	order, err := reloaded.GetBootOrder()
+	if err != nil || len(order) != 1 || order[0] != "0000" {
+		t.Errorf("GetBootOrder() = %v, %v", order, err)
+	}
+
+	if err := reloaded.SetConsoleConfig("serial", 115200); !errors.Is(err, ErrNotSupported) {
+		t.Errorf("SetConsoleConfig() error = %v, want %v", err, ErrNotSupported)
+	}
+
+	if _, err := NewOVMFManager("../edk2/config.txt", logr.Discard()); err == nil {
+		t.Error("NewOVMFManager() accepted a file without a varstore")
+	}
+}
@@ -0,0 +1,50 @@
+package manager
+
+import (
+	"fmt"
+
+	"github.com/metal3-community/uefi-firmware-manager/efi"
+)
+
+// setupPasswordAttr are the attributes of the setup password variable.
+const setupPasswordAttr = efi.EFI_VARIABLE_NON_VOLATILE | efi.EFI_VARIABLE_BOOTSERVICE_ACCESS
+
+// setupPasswordKey is the qualified key of the setup password variable.
+var setupPasswordKey = efi.VarKey(efi.SetupPasswordVar, efi.StringToGUID(efi.UserAuthentication))
+
+// SetSetupPassword sets the password that firmware built with the EDK2
+// user authentication driver asks for before entering setup. Changes are
+// not saved.
+func SetSetupPassword(m FirmwareManager, password string) error {
+	p, err := efi.NewSetupPassword(password)
+	if err != nil {
+		return err
+	}
+	if err := m.SetVariable(setupPasswordKey, &efi.EfiVar{Attr: setupPasswordAttr, Data: p.Bytes()}); err != nil {
+		return fmt.Errorf("failed to set setup password: %w", err)
+	}
+	return nil
+}
+
+// ClearSetupPassword removes the setup password, if one is set. Changes
+// are not saved.
+func ClearSetupPassword(m FirmwareManager) error {
+	if _, err := m.GetVariable(setupPasswordKey); err != nil {
+		return nil
+	}
+	return m.DeleteVariable(setupPasswordKey)
+}
+
+// VerifySetupPassword reports whether password is the setup password. It
+// returns false if no password is set.
+func VerifySetupPassword(m FirmwareManager, password string) (bool, error) {
+	v, err := m.GetVariable(setupPasswordKey)
+	if err != nil {
+		return false, nil
+	}
+	p, err := efi.ParseSetupPassword(v.Data)
+	if err != nil {
+		return false, err
+	}
+	return p.Verify(password), nil
+}
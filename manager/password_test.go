@@ -0,0 +1,52 @@
+package manager
+
+import (
+	"testing"
+
+	"github.com/go-logr/logr"
+	"github.com/metal3-community/uefi-firmware-manager/efi"
+)
+
+func TestSetupPassword(t *testing.T) {
+	m := newFixtureManager(t)
+
+	if ok, err := VerifySetupPassword(m, "kiosk"); err != nil || ok {
+		t.Errorf("VerifySetupPassword() without password = %v, %v", ok, err)
+	}
+	if err := SetSetupPassword(m, ""); err == nil {
+		t.Error("SetSetupPassword() accepted an empty password")
+	}
+	if err := SetSetupPassword(m, "kiosk"); err != nil {
+		t.Fatalf("SetSetupPassword() error = %v", err)
+	}
+	if err := m.SaveChanges(); err != nil {
+		t.Fatal(err)
+	}
+
+	reloaded, err := NewEDK2Manager(m.firmwarePath, logr.Discard())
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, err := reloaded.GetVariable(efi.SetupPasswordVar)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.Guid.String() != efi.UserAuthentication || v.Attr != setupPasswordAttr {
+		t.Errorf("guid = %s, attr = %#x", v.Guid, v.Attr)
+	}
+	for password, want := range map[string]bool{"kiosk": true, "Kiosk": false} {
+		if ok, err := VerifySetupPassword(reloaded, password); err != nil || ok != want {
+			t.Errorf("VerifySetupPassword(%q) = %v, %v, want %v", password, ok, err, want)
+		}
+	}
+
+	if err := ClearSetupPassword(reloaded); err != nil {
+		t.Fatalf("ClearSetupPassword() error = %v", err)
+	}
+	if ok, _ := VerifySetupPassword(reloaded, "kiosk"); ok {
+		t.Error("VerifySetupPassword() accepted a cleared password")
+	}
+	if err := ClearSetupPassword(reloaded); err != nil {
+		t.Errorf("ClearSetupPassword() without password error = %v", err)
+	}
+}
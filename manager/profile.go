@@ -0,0 +1,249 @@
+package manager
+
+import (
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"sort"
+
+	"github.com/metal3-community/uefi-firmware-manager/efi"
+	"github.com/metal3-community/uefi-firmware-manager/types"
+)
+
+// Variables holding the console settings of the Raspberry Pi firmware.
+const (
+	consolePrefVar    = "ConsolePref"
+	serialBaudRateVar = "SerialBaudRate"
+)
+
+// consoleNames maps ConsolePref values to console names.
+var consoleNames = map[uint32]string{
+	0: types.ConsoleAuto,
+	1: types.ConsoleSerial,
+	2: types.ConsoleGraphics,
+}
+
+// ApplyProfile applies the sections of p that are set to the firmware
+// managed by m. Changes are not saved.
+func ApplyProfile(m FirmwareManager, p types.Profile) error {
+	if err := p.Validate(); err != nil {
+		return fmt.Errorf("invalid profile: %w", err)
+	}
+
+	if p.Boot != nil {
+		if err := applyBootPolicy(m, *p.Boot); err != nil {
+			return fmt.Errorf("failed to apply boot policy: %w", err)
+		}
+	}
+
+	if p.TimeoutSeconds != nil {
+		if err := m.SetFirmwareTimeoutSeconds(*p.TimeoutSeconds); err != nil {
+			return fmt.Errorf("failed to set timeout: %w", err)
+		}
+	}
+
+	if p.Console != nil {
+		if err := m.SetConsoleConfig(p.Console.Name, p.Console.BaudRate); err != nil {
+			return fmt.Errorf("failed to set console: %w", err)
+		}
+	}
+
+	if p.Network != nil {
+		if err := m.SetNetworkSettings(*p.Network); err != nil {
+			return fmt.Errorf("failed to apply network settings: %w", err)
+		}
+	}
+
+	return applyProfileVariables(m, p.Variables)
+}
+
+// applyBootPolicy disables the entries of the disabled categories, then
+// reorders the boot order by category.
+func applyBootPolicy(m FirmwareManager, policy types.BootPolicy) error {
+	entries, err := m.GetBootEntries()
+	if err != nil {
+		return err
+	}
+
+	categories := make(map[string]string, len(entries))
+	for _, entry := range entries {
+		categories[entry.ID] = entry.DeviceCategory
+		if !entry.Enabled || !slices.Contains(policy.Disabled, entry.DeviceCategory) {
+			continue
+		}
+		entry.Enabled = false
+		// Write the nodes back rather than the text form, which does not
+		// round-trip every node type.
+		entry.DevPath = ""
+		if err := m.UpdateBootEntry(entry.ID, entry); err != nil {
+			return err
+		}
+	}
+
+	if len(policy.Order) == 0 {
+		return nil
+	}
+
+	order, err := m.GetBootOrder()
+	if err != nil {
+		return err
+	}
+	rank := func(id string) int {
+		if i := slices.Index(policy.Order, categories[id]); i >= 0 {
+			return i
+		}
+		return len(policy.Order)
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return rank(order[i]) < rank(order[j])
+	})
+	return m.SetBootOrder(order)
+}
+
+// applyProfileVariables sets the extra variables of a profile. Existing
+// variables keep their GUID and attributes.
+func applyProfileVariables(m FirmwareManager, variables map[string]string) error {
+	if len(variables) == 0 {
+		return nil
+	}
+
+	existing, err := m.ListVariables()
+	if err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(variables))
+	for name := range variables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		data, err := hex.DecodeString(variables[name])
+		if err != nil {
+			return fmt.Errorf("invalid data for variable %s: %w", name, err)
+		}
+
+		v, found := existing[name]
+		if found {
+			updated := *v
+			updated.Data = data
+			v = &updated
+		} else {
+			if _, _, qualified := efi.ParseVarKey(name); !qualified {
+				return fmt.Errorf("variable %s not found; use Name-GUID to create it", name)
+			}
+			v = &efi.EfiVar{
+				Attr: efi.EFI_VARIABLE_NON_VOLATILE |
+					efi.EFI_VARIABLE_BOOTSERVICE_ACCESS |
+					efi.EFI_VARIABLE_RUNTIME_ACCESS,
+				Data: data,
+			}
+		}
+
+		if err := m.SetVariable(name, v); err != nil {
+			return fmt.Errorf("failed to set variable %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// ExtractProfile captures the current settings of m as a profile. The
+// boot policy orders categories as they first appear in the boot order
+// and disables categories with no enabled entry. The named variables are
+// captured as extra variables.
+func ExtractProfile(m FirmwareManager, name string, variables ...string) (types.Profile, error) {
+	p := types.Profile{Name: name}
+
+	boot, err := extractBootPolicy(m)
+	if err != nil {
+		return p, fmt.Errorf("failed to read boot policy: %w", err)
+	}
+	p.Boot = boot
+
+	vars, err := m.ListVariables()
+	if err != nil {
+		return p, err
+	}
+
+	if v, found := vars["Timeout"]; found {
+		timeout, err := v.GetUint16()
+		if err != nil {
+			return p, fmt.Errorf("failed to read timeout: %w", err)
+		}
+		seconds := int(timeout)
+		p.TimeoutSeconds = &seconds
+	}
+
+	if v, found := vars[consolePrefVar]; found {
+		pref, err := v.GetUint32()
+		if err != nil {
+			return p, fmt.Errorf("failed to read console preference: %w", err)
+		}
+		console := &types.ConsoleSettings{Name: consoleNames[pref]}
+		if console.Name == "" {
+			console.Name = types.ConsoleAuto
+		}
+		if baud, found := vars[serialBaudRateVar]; found && console.Name == types.ConsoleSerial {
+			rate, err := baud.GetUint32()
+			if err == nil {
+				console.BaudRate = int(rate)
+			}
+		}
+		p.Console = console
+	}
+
+	network, err := m.GetNetworkSettings()
+	if err != nil {
+		return p, fmt.Errorf("failed to read network settings: %w", err)
+	}
+	network.MacAddress = ""
+	p.Network = &network
+
+	for _, name := range variables {
+		v, found := vars[name]
+		if !found {
+			return p, fmt.Errorf("variable not found: %s", name)
+		}
+		if p.Variables == nil {
+			p.Variables = map[string]string{}
+		}
+		p.Variables[name] = hex.EncodeToString(v.Data)
+	}
+
+	return p, nil
+}
+
+// extractBootPolicy describes the current boot order by device category.
+func extractBootPolicy(m FirmwareManager) (*types.BootPolicy, error) {
+	entries, err := m.GetBootEntries()
+	if err != nil {
+		return nil, err
+	}
+	order, err := m.GetBootOrder()
+	if err != nil {
+		return nil, err
+	}
+
+	byID := make(map[string]types.BootEntry, len(entries))
+	enabled := map[string]bool{}
+	for _, entry := range entries {
+		byID[entry.ID] = entry
+		enabled[entry.DeviceCategory] = enabled[entry.DeviceCategory] || entry.Enabled
+	}
+
+	policy := &types.BootPolicy{}
+	for _, id := range order {
+		entry, found := byID[id]
+		if found && !slices.Contains(policy.Order, entry.DeviceCategory) {
+			policy.Order = append(policy.Order, entry.DeviceCategory)
+		}
+	}
+	for category, on := range enabled {
+		if !on {
+			policy.Disabled = append(policy.Disabled, category)
+		}
+	}
+	sort.Strings(policy.Disabled)
+	return policy, nil
+}
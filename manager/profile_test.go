@@ -0,0 +1,144 @@
+package manager
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/go-logr/logr"
+	"github.com/metal3-community/uefi-firmware-manager/efi"
+	"github.com/metal3-community/uefi-firmware-manager/types"
+)
+
+// newFixtureManager returns an EDK2Manager for a copy of the embedded
+// firmware holding the variables of efi/test/fw-test.json.
+func newFixtureManager(t *testing.T) *EDK2Manager {
+	t.Helper()
+	firmware, err := os.ReadFile("../edk2/RPI_EFI.fd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "RPI_EFI.fd")
+	if err := os.WriteFile(path, firmware, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	fm, err := NewEDK2Manager(path, logr.Discard())
+	if err != nil {
+		t.Fatalf("NewEDK2Manager() error = %v", err)
+	}
+
+	data, err := os.ReadFile("../efi/test/fw-test.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := fm.(*EDK2Manager)
+	m.varList = efi.NewEfiVarList()
+	if err := m.varList.UnmarshalJSON(data); err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestApplyProfile(t *testing.T) {
+	m := newFixtureManager(t)
+
+	timeout := 3
+	profile := types.Profile{
+		Name: "netboot",
+		Boot: &types.BootPolicy{
+			Order:    []string{types.BootDeviceHTTP, types.BootDevicePXE},
+			Disabled: []string{types.BootDevicePXE},
+		},
+		TimeoutSeconds: &timeout,
+		Console:        &types.ConsoleSettings{Name: types.ConsoleSerial, BaudRate: 115200},
+		Variables: map[string]string{
+			"CpuClock":                           "02000000",
+			"Example-" + efi.EFI_GLOBAL_VARIABLE: "01",
+		},
+	}
+	if err := ApplyProfile(m, profile); err != nil {
+		t.Fatalf("ApplyProfile() error = %v", err)
+	}
+
+	entries, err := m.GetBootEntries()
+	if err != nil {
+		t.Fatal(err)
+	}
+	categories := map[string]string{}
+	for _, entry := range entries {
+		categories[entry.ID] = entry.DeviceCategory
+		if entry.DeviceCategory == types.BootDevicePXE && entry.Enabled {
+			t.Errorf("PXE entry %s is still enabled", entry.ID)
+		}
+	}
+	order, err := m.GetBootOrder()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var gotOrder []string
+	for _, id := range order {
+		gotOrder = append(gotOrder, categories[id])
+	}
+	if len(gotOrder) < 4 ||
+		gotOrder[0] != types.BootDeviceHTTP || gotOrder[1] != types.BootDeviceHTTP ||
+		gotOrder[2] != types.BootDevicePXE || gotOrder[3] != types.BootDevicePXE {
+		t.Errorf("boot order categories = %v, want HTTP then PXE first", gotOrder)
+	}
+
+	cpu, err := m.GetVariable("CpuClock")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := cpu.GetUint32(); v != 2 {
+		t.Errorf("CpuClock = %d, want 2", v)
+	}
+	if _, err := m.GetVariable("Example"); err != nil {
+		t.Errorf("Example variable was not created: %v", err)
+	}
+
+	got, err := ExtractProfile(m, "netboot", "CpuClock")
+	if err != nil {
+		t.Fatalf("ExtractProfile() error = %v", err)
+	}
+	if !reflect.DeepEqual(got.Boot.Order[:2], profile.Boot.Order) {
+		t.Errorf("extracted order = %v, want prefix %v", got.Boot.Order, profile.Boot.Order)
+	}
+	// The fixture's shell entry was already disabled.
+	wantDisabled := []string{types.BootDevicePXE, types.BootDeviceShell}
+	if !reflect.DeepEqual(got.Boot.Disabled, wantDisabled) {
+		t.Errorf("extracted disabled = %v, want %v", got.Boot.Disabled, wantDisabled)
+	}
+	if got.TimeoutSeconds == nil || *got.TimeoutSeconds != timeout {
+		t.Errorf("extracted timeout = %v, want %d", got.TimeoutSeconds, timeout)
+	}
+	if !reflect.DeepEqual(got.Console, profile.Console) {
+		t.Errorf("extracted console = %+v, want %+v", got.Console, profile.Console)
+	}
+	if got.Variables["CpuClock"] != "02000000" {
+		t.Errorf("extracted variables = %v", got.Variables)
+	}
+	if got.Network == nil || got.Network.MacAddress != "" {
+		t.Errorf("extracted network = %+v, want settings without a MAC", got.Network)
+	}
+}
+
+func TestApplyProfile_Invalid(t *testing.T) {
+	timeout := -1
+	tests := []struct {
+		name    string
+		profile types.Profile
+	}{
+		{name: "timeout", profile: types.Profile{Name: "invalid", TimeoutSeconds: &timeout}},
+		{name: "console", profile: types.Profile{Name: "invalid", Console: &types.ConsoleSettings{Name: "vga"}}},
+		{name: "variable data", profile: types.Profile{Name: "invalid", Variables: map[string]string{"CpuClock": "zz"}}},
+		{name: "unknown variable", profile: types.Profile{Name: "invalid", Variables: map[string]string{"Missing": "00"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ApplyProfile(newFixtureManager(t), tt.profile); err == nil {
+				t.Error("ApplyProfile() error = nil, want an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,131 @@
+package manager
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"os"
+	"slices"
+
+	"github.com/metal3-community/uefi-firmware-manager/efi"
+	"github.com/metal3-community/uefi-firmware-manager/types"
+	"gopkg.in/yaml.v3"
+)
+
+// LoadProvisioning reads and validates a provisioning file. It may be YAML
+// or JSON; unknown fields are rejected to catch typos.
+func LoadProvisioning(path string) (types.Provisioning, error) {
+	var p types.Provisioning
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return p, err
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&p); err != nil {
+		return p, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	if err := p.Validate(); err != nil {
+		return p, fmt.Errorf("invalid provisioning file %s: %w", path, err)
+	}
+	return p, nil
+}
+
+// ReconcileStore applies the assigned profile to the stored configuration
+// of every host of j, saving the hosts that differ unless dryRun is set.
+// Hosts without a profile are left alone. Hosts assigned by exact MAC
+// address that have no stored configuration are reported with an error.
+// The profiles are applied as by ApplyProfile to a copy of the variables,
+// so they work whatever JsonEDK2Manager itself implements.
+func ReconcileStore(j *JsonEDK2Manager, p types.Provisioning, dryRun bool) ([]types.ReconcileResult, error) {
+	macs, err := j.ListAvailableMACs()
+	if err != nil {
+		return nil, err
+	}
+
+	var results []types.ReconcileResult
+	stored := make(map[string]bool, len(macs))
+	for _, mac := range macs {
+		stored[mac.String()] = true
+		profile, found := p.ProfileFor(mac)
+		if !found {
+			continue
+		}
+		result := types.ReconcileResult{MacAddress: mac.String(), Profile: profile.Name}
+		if result.Changed, err = reconcileHost(j, mac, profile, dryRun); err != nil {
+			result.Error = err.Error()
+		}
+		results = append(results, result)
+	}
+
+	for _, host := range p.Hosts {
+		mac, err := net.ParseMAC(host.MacAddress)
+		if err != nil || stored[mac.String()] {
+			continue
+		}
+		stored[mac.String()] = true
+		results = append(results, types.ReconcileResult{
+			MacAddress: mac.String(),
+			Profile:    host.Profile,
+			Error:      "no stored configuration",
+		})
+	}
+	return results, nil
+}
+
+// reconcileHost applies profile to the configuration of mac and returns
+// the names of the variables that changed.
+func reconcileHost(j *JsonEDK2Manager, mac net.HardwareAddr, profile types.Profile, dryRun bool) ([]string, error) {
+	if err := j.LoadMAC(mac); err != nil {
+		return nil, err
+	}
+	current, err := j.GetVarList()
+	if err != nil {
+		return nil, err
+	}
+	desired := NewMemoryManager(current)
+	if err := ApplyProfile(desired, profile); err != nil {
+		return nil, err
+	}
+
+	keys := changedVars(current, desired.varList)
+	names := make([]string, len(keys))
+	for i, key := range keys {
+		name, _, _ := efi.ParseVarKey(key)
+		names[i] = name
+	}
+	if dryRun || len(keys) == 0 {
+		return names, nil
+	}
+
+	for _, key := range keys {
+		if v, found := desired.varList[key]; found {
+			err = j.SetVariable(key, v)
+		} else {
+			err = j.DeleteVariable(key)
+		}
+		if err != nil {
+			return names, err
+		}
+	}
+	return names, j.SaveChanges()
+}
+
+// changedVars returns the sorted keys of the variables that were added,
+// deleted or changed between before and after.
+func changedVars(before, after efi.EfiVarList) []string {
+	var keys []string
+	for key, v := range after {
+		old, found := before[key]
+		if !found || old.Attr != v.Attr || !bytes.Equal(old.Data, v.Data) {
+			keys = append(keys, key)
+		}
+	}
+	for key := range before {
+		if _, found := after[key]; !found {
+			keys = append(keys, key)
+		}
+	}
+	slices.Sort(keys)
+	return keys
+}
@@ -0,0 +1,164 @@
+package manager
+
+import (
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/go-logr/logr"
+	"github.com/metal3-community/uefi-firmware-manager/types"
+	"github.com/metal3-community/uefi-firmware-manager/varstore"
+)
+
+const provisioningYAML = `
+profiles:
+  - name: lab
+    timeoutSeconds: 7
+    console:
+      name: serial
+      baudRate: 115200
+hosts:
+  - macAddress: "d8:3a:dd:*"
+    profile: lab
+  - macAddress: "aa:bb:cc:dd:ee:ff"
+    profile: lab
+`
+
+func TestLoadProvisioning(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "provisioning.yaml")
+	if err := os.WriteFile(path, []byte(provisioningYAML), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	p, err := LoadProvisioning(path)
+	if err != nil {
+		t.Fatalf("LoadProvisioning() error = %v", err)
+	}
+	if len(p.Profiles) != 1 || len(p.Hosts) != 2 {
+		t.Errorf("LoadProvisioning() = %+v", p)
+	}
+
+	for name, data := range map[string]string{
+		"typo.yaml":    "profiles: []\nhost: []\n",
+		"invalid.json": `{"profiles": [], "hosts": [{"macAddress": "d8:3a:dd:*", "profile": "lab"}]}`,
+	} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadProvisioning(path); err == nil {
+			t.Errorf("LoadProvisioning(%s) succeeded", name)
+		}
+	}
+}
+
+func TestReconcileStore(t *testing.T) {
+	dataDir := t.TempDir()
+	fixture, err := os.ReadFile("../efi/test/fw-test-2.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, dir := range []string{"d8-3a-dd-5a-44-36", "11-22-33-44-55-66"} {
+		if err := DirStorage(dataDir).WriteFile(dir+"/"+jsonVarsFileName, fixture); err != nil {
+			t.Fatal(err)
+		}
+	}
+	p := types.Provisioning{
+		Profiles: []types.Profile{{Name: "lab", TimeoutSeconds: new(int)}},
+		Hosts: []types.HostAssignment{
+			{MacAddress: "d8:3a:dd:*", Profile: "lab"},
+			{MacAddress: "aa:bb:cc:dd:ee:ff", Profile: "lab"},
+		},
+	}
+	*p.Profiles[0].TimeoutSeconds = 7
+
+	j, err := NewJsonEDK2Manager(dataDir, logr.Discard())
+	if err != nil {
+		t.Fatal(err)
+	}
+	reconcile := func(dryRun bool) []types.ReconcileResult {
+		t.Helper()
+		results, err := ReconcileStore(j, p, dryRun)
+		if err != nil {
+			t.Fatalf("ReconcileStore() error = %v", err)
+		}
+		if len(results) != 2 || results[1].MacAddress != "aa:bb:cc:dd:ee:ff" || results[1].Error == "" {
+			t.Fatalf("ReconcileStore() = %+v, want the Pi and the missing host", results)
+		}
+		return results
+	}
+
+	for _, dryRun := range []bool{true, false} {
+		results := reconcile(dryRun)
+		if r := results[0]; r.MacAddress != "d8:3a:dd:5a:44:36" || r.Error != "" || !slices.Contains(r.Changed, "Timeout") {
+			t.Errorf("ReconcileStore(dryRun=%v) = %+v, want Timeout changed", dryRun, r)
+		}
+	}
+	if results := reconcile(false); len(results[0].Changed) != 0 {
+		t.Errorf("ReconcileStore() after reconciling changed %v", results[0].Changed)
+	}
+
+	mac, _ := net.ParseMAC("d8:3a:dd:5a:44:36")
+	if err := j.LoadMAC(mac); err != nil {
+		t.Fatal(err)
+	}
+	v, err := j.GetVariable("Timeout")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := v.GetUint16(); got != 7 {
+		t.Errorf("stored Timeout = %d, want 7", got)
+	}
+}
+
+func TestSimpleFirmwareManager_SetProvisioning(t *testing.T) {
+	mgr, err := NewSimpleFirmwareManager(logr.Discard())
+	if err != nil {
+		t.Fatal(err)
+	}
+	timeout := 9
+	p := &types.Provisioning{
+		Profiles: []types.Profile{
+			{Name: "lab", TimeoutSeconds: &timeout},
+			{Name: "pinned", FirmwareVersion: "v0.0.1"},
+		},
+		Hosts: []types.HostAssignment{
+			{MacAddress: "d8:3a:dd:*", Profile: "lab"},
+			{MacAddress: "aa:bb:cc:*", Profile: "pinned"},
+		},
+	}
+	mgr.SetProvisioning(p)
+
+	mac, _ := net.ParseMAC("d8:3a:dd:61:4d:15")
+	reader, err := mgr.GetFirmwareReader(mac)
+	if err != nil {
+		t.Fatalf("GetFirmwareReader() error = %v", err)
+	}
+	image, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vs, err := varstore.New(image)
+	if err != nil {
+		t.Fatal(err)
+	}
+	varList, err := vs.GetVarList()
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, found := varList.Lookup("Timeout")
+	if !found {
+		t.Fatal("served firmware has no Timeout")
+	}
+	if got, _ := v.GetUint16(); got != 9 {
+		t.Errorf("served Timeout = %d, want 9", got)
+	}
+
+	pinned, _ := net.ParseMAC("aa:bb:cc:00:00:01")
+	if _, err := mgr.GetFirmwareReader(pinned); err == nil {
+		t.Error("GetFirmwareReader() served firmware of another version")
+	}
+}
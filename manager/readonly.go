@@ -0,0 +1,77 @@
+package manager
+
+import (
+	"errors"
+	"net"
+
+	"github.com/metal3-community/uefi-firmware-manager/efi"
+	"github.com/metal3-community/uefi-firmware-manager/types"
+)
+
+// ErrReadOnly is returned by the mutating methods of a manager returned
+// by ReadOnly.
+var ErrReadOnly = errors.New("manager is read-only")
+
+// readOnlyManager passes getters through and refuses changes.
+type readOnlyManager struct {
+	FirmwareManager
+}
+
+// ReadOnly wraps m so that getters work but every method that would change
+// or save the firmware returns ErrReadOnly. Variables are returned as
+// copies, so callers cannot change m through them either.
+func ReadOnly(m FirmwareManager) FirmwareManager {
+	return &readOnlyManager{FirmwareManager: m}
+}
+
+// GetVarList returns a copy of the variables.
+func (r *readOnlyManager) GetVarList() (efi.EfiVarList, error) {
+	list, err := r.FirmwareManager.GetVarList()
+	if err != nil {
+		return nil, err
+	}
+	return cloneVarList(list), nil
+}
+
+// GetVariable returns a copy of the variable.
+func (r *readOnlyManager) GetVariable(name string) (*efi.EfiVar, error) {
+	v, err := r.FirmwareManager.GetVariable(name)
+	if err != nil {
+		return nil, err
+	}
+	return cloneVar(v), nil
+}
+
+// ListVariables returns copies of the variables.
+func (r *readOnlyManager) ListVariables() (map[string]*efi.EfiVar, error) {
+	vars, err := r.FirmwareManager.ListVariables()
+	if err != nil {
+		return nil, err
+	}
+	clone := make(map[string]*efi.EfiVar, len(vars))
+	for name, v := range vars {
+		clone[name] = cloneVar(v)
+	}
+	return clone, nil
+}
+
+func (r *readOnlyManager) SetBootOrder([]string) error                    { return ErrReadOnly }
+func (r *readOnlyManager) AddBootEntry(types.BootEntry) error             { return ErrReadOnly }
+func (r *readOnlyManager) UpdateBootEntry(string, types.BootEntry) error  { return ErrReadOnly }
+func (r *readOnlyManager) DeleteBootEntry(string) error                   { return ErrReadOnly }
+func (r *readOnlyManager) SetBootLast(types.BootEntry) error              { return ErrReadOnly }
+func (r *readOnlyManager) SetBootNext(uint16) error                       { return ErrReadOnly }
+func (r *readOnlyManager) DeleteBootNext() error                          { return ErrReadOnly }
+func (r *readOnlyManager) SetNetworkSettings(types.NetworkSettings) error { return ErrReadOnly }
+func (r *readOnlyManager) SetMacAddress(net.HardwareAddr) error           { return ErrReadOnly }
+func (r *readOnlyManager) SetVariable(string, *efi.EfiVar) error          { return ErrReadOnly }
+func (r *readOnlyManager) DeleteVariable(string) error                    { return ErrReadOnly }
+func (r *readOnlyManager) SetVariableFromType(string, any) error          { return ErrReadOnly }
+func (r *readOnlyManager) EnablePXEBoot(bool) error                       { return ErrReadOnly }
+func (r *readOnlyManager) EnableHTTPBoot(bool) error                      { return ErrReadOnly }
+func (r *readOnlyManager) SetFirmwareTimeoutSeconds(int) error            { return ErrReadOnly }
+func (r *readOnlyManager) SetConsoleConfig(string, int) error             { return ErrReadOnly }
+func (r *readOnlyManager) UpdateFirmware([]byte) error                    { return ErrReadOnly }
+func (r *readOnlyManager) SaveChanges() error                             { return ErrReadOnly }
+func (r *readOnlyManager) RevertChanges() error                           { return ErrReadOnly }
+func (r *readOnlyManager) ResetToDefaults() error                         { return ErrReadOnly }
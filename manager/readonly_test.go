@@ -0,0 +1,42 @@
+package manager
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/metal3-community/uefi-firmware-manager/types"
+)
+
+func TestReadOnly(t *testing.T) {
+	m := newFixtureManager(t)
+	ro := ReadOnly(m)
+
+	order, err := ro.GetBootOrder()
+	if err != nil || len(order) == 0 {
+		t.Fatalf("GetBootOrder() = %v, %v", order, err)
+	}
+
+	mutations := map[string]func() error{
+		"SetBootOrder":              func() error { return ro.SetBootOrder(order) },
+		"UpdateBootEntry":           func() error { return ro.UpdateBootEntry(order[0], types.BootEntry{}) },
+		"DeleteBootEntry":           func() error { return ro.DeleteBootEntry(order[0]) },
+		"SetFirmwareTimeoutSeconds": func() error { return ro.SetFirmwareTimeoutSeconds(1) },
+		"DeleteVariable":            func() error { return ro.DeleteVariable("Timeout") },
+		"SaveChanges":               ro.SaveChanges,
+	}
+	for name, fn := range mutations {
+		if err := fn(); !errors.Is(err, ErrReadOnly) {
+			t.Errorf("%s() error = %v, want %v", name, err, ErrReadOnly)
+		}
+	}
+
+	v, err := ro.GetVariable("Timeout")
+	if err != nil {
+		t.Fatal(err)
+	}
+	v.Data[0] ^= 0xff
+	orig, _ := m.GetVariable("Timeout")
+	if orig.Data[0] == v.Data[0] {
+		t.Error("changing a variable returned by GetVariable() changed the manager")
+	}
+}
@@ -0,0 +1,630 @@
+package manager
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/metal3-community/uefi-firmware-manager/efi"
+	"github.com/metal3-community/uefi-firmware-manager/types"
+)
+
+// DefaultRedfishTimeout bounds the requests of a RedfishManager without a
+// Timeout.
+const DefaultRedfishTimeout = 30 * time.Second
+
+// RedfishConfig describes how to reach a BMC.
+type RedfishConfig struct {
+	// Endpoint is the base URL of the BMC, e.g. "https://10.0.0.10".
+	Endpoint string
+	Username string
+	Password string
+	// SystemID selects the computer system; empty uses the only one.
+	SystemID string
+	// Client sends the requests; nil uses a client with Timeout.
+	Client *http.Client
+	// Timeout bounds each request, so that an unresponsive BMC does not
+	// block operations. Zero uses DefaultRedfishTimeout.
+	Timeout time.Duration
+}
+
+// RedfishManager manages the boot settings of a conventional server through
+// its BMC, so the code managing Raspberry Pi images can manage servers too.
+// Boot order, boot entries and BootNext map to the Boot property and boot
+// options of the computer system; changes are sent by SaveChanges and, like
+// BIOS attributes, take effect at the next reset. Raw UEFI variables and
+// network settings are not exposed by Redfish.
+type RedfishManager struct {
+	config     RedfishConfig
+	client     *http.Client
+	systemPath string
+	logger     logr.Logger
+
+	// Changes to send on SaveChanges: Boot properties of the system, boot
+	// option properties by option path and BIOS attributes.
+	bootPatch   map[string]any
+	optionPatch map[string]map[string]any
+	biosPatch   map[string]any
+}
+
+// odataLink is a reference to another Redfish resource.
+type odataLink struct {
+	ODataID string `json:"@odata.id"`
+}
+
+type redfishCollection struct {
+	Members []odataLink `json:"Members"`
+}
+
+type redfishSystem struct {
+	AssetTag    string    `json:"AssetTag"`
+	BiosVersion string    `json:"BiosVersion"`
+	Bios        odataLink `json:"Bios"`
+	Boot        struct {
+		BootOrder                 []string  `json:"BootOrder"`
+		BootNext                  string    `json:"BootNext"`
+		BootSourceOverrideEnabled string    `json:"BootSourceOverrideEnabled"`
+		BootSourceOverrideTarget  string    `json:"BootSourceOverrideTarget"`
+		BootOptions               odataLink `json:"BootOptions"`
+	} `json:"Boot"`
+}
+
+type redfishBootOption struct {
+	ODataID             string `json:"@odata.id"`
+	BootOptionReference string `json:"BootOptionReference"`
+	DisplayName         string `json:"DisplayName"`
+	UefiDevicePath      string `json:"UefiDevicePath"`
+	BootOptionEnabled   *bool  `json:"BootOptionEnabled"`
+}
+
+type redfishBios struct {
+	Attributes map[string]any `json:"Attributes"`
+	Settings   struct {
+		SettingsObject odataLink `json:"SettingsObject"`
+	} `json:"@Redfish.Settings"`
+}
+
+// NewRedfishManager connects to the BMC described by config and selects
+// its computer system.
+func NewRedfishManager(config RedfishConfig, logger logr.Logger) (*RedfishManager, error) {
+	m := &RedfishManager{
+		config:      config,
+		client:      config.Client,
+		logger:      logger.WithName("redfish-manager"),
+		bootPatch:   map[string]any{},
+		optionPatch: map[string]map[string]any{},
+		biosPatch:   map[string]any{},
+	}
+	if m.config.Timeout <= 0 {
+		m.config.Timeout = DefaultRedfishTimeout
+	}
+	if m.client == nil {
+		m.client = &http.Client{Timeout: m.config.Timeout}
+	}
+	m.config.Endpoint = strings.TrimSuffix(config.Endpoint, "/")
+
+	if config.SystemID != "" {
+		m.systemPath = "/redfish/v1/Systems/" + config.SystemID
+	} else {
+		var systems redfishCollection
+		if err := m.get("/redfish/v1/Systems", &systems); err != nil {
+			return nil, err
+		}
+		if len(systems.Members) != 1 {
+			return nil, fmt.Errorf("BMC has %d systems; set SystemID", len(systems.Members))
+		}
+		m.systemPath = systems.Members[0].ODataID
+	}
+
+	if _, err := m.system(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (m *RedfishManager) do(method, path string, body any, out any) error {
+	var reqBody io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reqBody = bytes.NewReader(data)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), m.config.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, method, m.config.Endpoint+path, reqBody)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if m.config.Username != "" {
+		req.SetBasicAuth(m.config.Username, m.config.Password)
+	}
+
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		var rfErr struct {
+			Error struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		data, _ := io.ReadAll(resp.Body)
+		if json.Unmarshal(data, &rfErr) == nil && rfErr.Error.Message != "" {
+			return fmt.Errorf("%s %s: %s: %s", method, path, resp.Status, rfErr.Error.Message)
+		}
+		return fmt.Errorf("%s %s: %s", method, path, resp.Status)
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("%s %s: invalid response: %w", method, path, err)
+	}
+	return nil
+}
+
+func (m *RedfishManager) get(path string, out any) error {
+	return m.do(http.MethodGet, path, nil, out)
+}
+
+func (m *RedfishManager) system() (redfishSystem, error) {
+	var s redfishSystem
+	err := m.get(m.systemPath, &s)
+	return s, err
+}
+
+// unsupported reports an operation Redfish has no equivalent for.
+func (m *RedfishManager) unsupported(what string) error {
+	return fmt.Errorf("%s: %w by Redfish", what, ErrNotSupported)
+}
+
+// bootOptionID returns the boot entry ID of a Redfish boot option
+// reference, e.g. "0001" for "Boot0001".
+func bootOptionID(ref string) string {
+	return strings.TrimPrefix(ref, "Boot")
+}
+
+// bootOptionRef returns the Redfish reference of a boot entry ID.
+func bootOptionRef(id string) (string, error) {
+	n, err := strconv.ParseUint(bootOptionID(id), 16, 16)
+	if err != nil {
+		return "", fmt.Errorf("invalid boot entry ID '%s': %w", id, err)
+	}
+	return fmt.Sprintf("Boot%04X", n), nil
+}
+
+// GetBootOrder returns the boot order, including unsaved changes.
+func (m *RedfishManager) GetBootOrder() ([]string, error) {
+	refs, ok := m.bootPatch["BootOrder"].([]string)
+	if !ok {
+		s, err := m.system()
+		if err != nil {
+			return nil, err
+		}
+		refs = s.Boot.BootOrder
+	}
+	order := make([]string, len(refs))
+	for i, ref := range refs {
+		order[i] = bootOptionID(ref)
+	}
+	return order, nil
+}
+
+// SetBootOrder sets the boot order from a list of entry IDs.
+func (m *RedfishManager) SetBootOrder(order []string) error {
+	refs := make([]string, len(order))
+	for i, id := range order {
+		ref, err := bootOptionRef(id)
+		if err != nil {
+			return err
+		}
+		refs[i] = ref
+	}
+	m.bootPatch["BootOrder"] = refs
+	return nil
+}
+
+// bootOptions returns the boot options of the system.
+func (m *RedfishManager) bootOptions() ([]redfishBootOption, error) {
+	s, err := m.system()
+	if err != nil {
+		return nil, err
+	}
+	if s.Boot.BootOptions.ODataID == "" {
+		return nil, m.unsupported("boot options")
+	}
+	var collection redfishCollection
+	if err := m.get(s.Boot.BootOptions.ODataID, &collection); err != nil {
+		return nil, err
+	}
+	options := make([]redfishBootOption, 0, len(collection.Members))
+	for _, member := range collection.Members {
+		var option redfishBootOption
+		if err := m.get(member.ODataID, &option); err != nil {
+			return nil, err
+		}
+		if option.ODataID == "" {
+			option.ODataID = member.ODataID
+		}
+		options = append(options, option)
+	}
+	return options, nil
+}
+
+// GetBootEntries returns the boot options, including unsaved changes.
+func (m *RedfishManager) GetBootEntries() ([]types.BootEntry, error) {
+	options, err := m.bootOptions()
+	if err != nil {
+		return nil, err
+	}
+	order, err := m.GetBootOrder()
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]types.BootEntry, 0, len(options))
+	for _, option := range options {
+		id := bootOptionID(option.BootOptionReference)
+		entry := types.BootEntry{
+			ID:       id,
+			Name:     option.DisplayName,
+			DevPath:  option.UefiDevicePath,
+			Enabled:  option.BootOptionEnabled == nil || *option.BootOptionEnabled,
+			Category: types.BootCategoryBoot,
+		}
+		if enabled, ok := m.optionPatch[option.ODataID]["BootOptionEnabled"].(bool); ok {
+			entry.Enabled = enabled
+		}
+		for i, orderID := range order {
+			if strings.EqualFold(orderID, id) {
+				entry.Position = i
+				break
+			}
+		}
+		if dp, err := efi.ParseDevicePathFromString(entry.DevPath); err == nil {
+			entry.DevicePath = devicePathNodes(dp)
+			entry.DeviceCategory = deviceCategory(dp)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// UpdateBootEntry enables or disables a boot option. Redfish does not allow
+// changing the name or device path of a boot option.
+func (m *RedfishManager) UpdateBootEntry(id string, entry types.BootEntry) error {
+	ref, err := bootOptionRef(id)
+	if err != nil {
+		return err
+	}
+	options, err := m.bootOptions()
+	if err != nil {
+		return err
+	}
+	for _, option := range options {
+		if option.BootOptionReference != ref {
+			continue
+		}
+		if m.optionPatch[option.ODataID] == nil {
+			m.optionPatch[option.ODataID] = map[string]any{}
+		}
+		m.optionPatch[option.ODataID]["BootOptionEnabled"] = entry.Enabled
+		return nil
+	}
+	return fmt.Errorf("boot entry %s not found", id)
+}
+
+// AddBootEntry is not supported; boot options are created by the firmware.
+func (m *RedfishManager) AddBootEntry(entry types.BootEntry) error {
+	return m.unsupported("adding boot entries")
+}
+
+// DeleteBootEntry is not supported; disable the entry instead.
+func (m *RedfishManager) DeleteBootEntry(id string) error {
+	return m.unsupported("deleting boot entries")
+}
+
+// GetVarList is not supported.
+func (m *RedfishManager) GetVarList() (efi.EfiVarList, error) {
+	return nil, m.unsupported("UEFI variables")
+}
+
+// SetBootLast is not supported; boot options are created by the firmware.
+func (m *RedfishManager) SetBootLast(types.BootEntry) error {
+	return m.unsupported("adding boot entries")
+}
+
+// GetBootLast is not supported.
+func (m *RedfishManager) GetBootLast() (*types.BootEntry, error) {
+	return nil, m.unsupported("adding boot entries")
+}
+
+// SetBootNext makes the system boot the given entry once.
+func (m *RedfishManager) SetBootNext(index uint16) error {
+	m.bootPatch["BootNext"] = fmt.Sprintf("Boot%04X", index)
+	m.bootPatch["BootSourceOverrideTarget"] = "UefiBootNext"
+	m.bootPatch["BootSourceOverrideEnabled"] = "Once"
+	return nil
+}
+
+// GetBootNext returns the entry booted once, or 0 if there is none.
+func (m *RedfishManager) GetBootNext() (uint16, error) {
+	s, err := m.system()
+	if err != nil {
+		return 0, err
+	}
+	next, target, enabled := s.Boot.BootNext, s.Boot.BootSourceOverrideTarget, s.Boot.BootSourceOverrideEnabled
+	if v, ok := m.bootPatch["BootNext"].(string); ok {
+		next = v
+	}
+	if v, ok := m.bootPatch["BootSourceOverrideTarget"].(string); ok {
+		target = v
+	}
+	if v, ok := m.bootPatch["BootSourceOverrideEnabled"].(string); ok {
+		enabled = v
+	}
+	if target != "UefiBootNext" || enabled == "Disabled" || next == "" {
+		return 0, nil
+	}
+	n, err := strconv.ParseUint(bootOptionID(next), 16, 16)
+	if err != nil {
+		return 0, fmt.Errorf("invalid BootNext %q: %w", next, err)
+	}
+	return uint16(n), nil
+}
+
+// DeleteBootNext cancels the one time boot.
+func (m *RedfishManager) DeleteBootNext() error {
+	delete(m.bootPatch, "BootNext")
+	delete(m.bootPatch, "BootSourceOverrideTarget")
+	m.bootPatch["BootSourceOverrideEnabled"] = "Disabled"
+	return nil
+}
+
+// GetNetworkSettings is not supported.
+func (m *RedfishManager) GetNetworkSettings() (types.NetworkSettings, error) {
+	return types.NetworkSettings{}, m.unsupported("network settings")
+}
+
+// SetNetworkSettings is not supported.
+func (m *RedfishManager) SetNetworkSettings(settings types.NetworkSettings) error {
+	return m.unsupported("network settings")
+}
+
+// GetMacAddress is not supported.
+func (m *RedfishManager) GetMacAddress() (net.HardwareAddr, error) {
+	return nil, m.unsupported("network settings")
+}
+
+// SetMacAddress is not supported.
+func (m *RedfishManager) SetMacAddress(mac net.HardwareAddr) error {
+	return m.unsupported("network settings")
+}
+
+// GetVariable is not supported; see BiosAttributes.
+func (m *RedfishManager) GetVariable(name string) (*efi.EfiVar, error) {
+	return nil, m.unsupported("UEFI variables")
+}
+
+// SetVariable is not supported; see SetBiosAttribute.
+func (m *RedfishManager) SetVariable(name string, value *efi.EfiVar) error {
+	return m.unsupported("UEFI variables")
+}
+
+// DeleteVariable is not supported.
+func (m *RedfishManager) DeleteVariable(name string) error {
+	return m.unsupported("UEFI variables")
+}
+
+// ListVariables is not supported; see BiosAttributes.
+func (m *RedfishManager) ListVariables() (map[string]*efi.EfiVar, error) {
+	return nil, m.unsupported("UEFI variables")
+}
+
+// GetVariableAsType is not supported.
+func (m *RedfishManager) GetVariableAsType(name string) (any, error) {
+	return nil, m.unsupported("UEFI variables")
+}
+
+// ListVariablesWithTypes is not supported.
+func (m *RedfishManager) ListVariablesWithTypes() (map[string]any, error) {
+	return nil, m.unsupported("UEFI variables")
+}
+
+// SetVariableFromType is not supported.
+func (m *RedfishManager) SetVariableFromType(name string, value any) error {
+	return m.unsupported("UEFI variables")
+}
+
+// EnablePXEBoot enables or disables the boot options named PXE.
+func (m *RedfishManager) EnablePXEBoot(enable bool) error {
+	return m.enableBootEntries("PXE", enable)
+}
+
+// EnableHTTPBoot enables or disables the boot options named HTTP.
+func (m *RedfishManager) EnableHTTPBoot(enable bool) error {
+	return m.enableBootEntries("HTTP", enable)
+}
+
+func (m *RedfishManager) enableBootEntries(kind string, enable bool) error {
+	entries, err := m.GetBootEntries()
+	if err != nil {
+		return fmt.Errorf("failed to get boot entries: %w", err)
+	}
+	found := false
+	for _, entry := range entries {
+		if !strings.Contains(entry.Name, kind) {
+			continue
+		}
+		found = true
+		entry.Enabled = enable
+		if err := m.UpdateBootEntry(entry.ID, entry); err != nil {
+			return fmt.Errorf("failed to update %s boot entry %s: %w", kind, entry.ID, err)
+		}
+	}
+	if enable && !found {
+		return fmt.Errorf("no %s boot entries found", kind)
+	}
+	return nil
+}
+
+// GetFirmwareTimeoutSeconds is not supported; the boot menu timeout is a
+// vendor specific BIOS attribute, see BiosAttributes.
+func (m *RedfishManager) GetFirmwareTimeoutSeconds() (int, error) {
+	return 0, m.unsupported("the firmware timeout")
+}
+
+// SetFirmwareTimeoutSeconds is not supported; the boot menu timeout is a
+// vendor specific BIOS attribute, see SetBiosAttribute.
+func (m *RedfishManager) SetFirmwareTimeoutSeconds(seconds int) error {
+	return m.unsupported("the firmware timeout")
+}
+
+// SetConsoleConfig is not supported; console redirection is a vendor
+// specific BIOS attribute, see SetBiosAttribute.
+func (m *RedfishManager) SetConsoleConfig(consoleName string, baudRate int) error {
+	return m.unsupported("console settings")
+}
+
+// GetSystemInfo returns the BIOS version and asset tag of the system.
+func (m *RedfishManager) GetSystemInfo() (types.SystemInfo, error) {
+	s, err := m.system()
+	if err != nil {
+		return types.SystemInfo{}, err
+	}
+	return types.SystemInfo{FirmwareVersion: s.BiosVersion, AssetTag: s.AssetTag}, nil
+}
+
+// UpdateFirmware is not supported; use the update service of the BMC.
+func (m *RedfishManager) UpdateFirmware(firmwareData []byte) error {
+	return m.unsupported("firmware updates")
+}
+
+// GetFirmwareVersion returns the BIOS version of the system.
+func (m *RedfishManager) GetFirmwareVersion() (string, error) {
+	s, err := m.system()
+	if err != nil {
+		return "", err
+	}
+	return s.BiosVersion, nil
+}
+
+// bios returns the BIOS resource of the system and its path.
+func (m *RedfishManager) bios() (redfishBios, string, error) {
+	s, err := m.system()
+	if err != nil {
+		return redfishBios{}, "", err
+	}
+	if s.Bios.ODataID == "" {
+		return redfishBios{}, "", m.unsupported("BIOS attributes")
+	}
+	var bios redfishBios
+	err = m.get(s.Bios.ODataID, &bios)
+	return bios, s.Bios.ODataID, err
+}
+
+// BiosAttributes returns the BIOS attributes, including unsaved changes.
+func (m *RedfishManager) BiosAttributes() (map[string]any, error) {
+	bios, _, err := m.bios()
+	if err != nil {
+		return nil, err
+	}
+	attrs := bios.Attributes
+	if attrs == nil {
+		attrs = map[string]any{}
+	}
+	for name, value := range m.biosPatch {
+		attrs[name] = value
+	}
+	return attrs, nil
+}
+
+// SetBiosAttribute sets a vendor specific BIOS attribute. Changes are not
+// saved.
+func (m *RedfishManager) SetBiosAttribute(name string, value any) error {
+	if name == "" {
+		return fmt.Errorf("attribute name is empty")
+	}
+	m.biosPatch[name] = value
+	return nil
+}
+
+// SaveChanges sends the changes to the BMC. They take effect at the next
+// reset of the system.
+func (m *RedfishManager) SaveChanges() error {
+	if len(m.bootPatch) > 0 {
+		if err := m.do(http.MethodPatch, m.systemPath, map[string]any{"Boot": m.bootPatch}, nil); err != nil {
+			return fmt.Errorf("failed to save boot settings: %w", err)
+		}
+		m.bootPatch = map[string]any{}
+	}
+
+	paths := make([]string, 0, len(m.optionPatch))
+	for path := range m.optionPatch {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		if err := m.do(http.MethodPatch, path, m.optionPatch[path], nil); err != nil {
+			return fmt.Errorf("failed to save boot option: %w", err)
+		}
+		delete(m.optionPatch, path)
+	}
+
+	if len(m.biosPatch) > 0 {
+		bios, path, err := m.bios()
+		if err != nil {
+			return err
+		}
+		settings := bios.Settings.SettingsObject.ODataID
+		if settings == "" {
+			settings = path + "/Settings"
+		}
+		if err := m.do(http.MethodPatch, settings, map[string]any{"Attributes": m.biosPatch}, nil); err != nil {
+			return fmt.Errorf("failed to save BIOS attributes: %w", err)
+		}
+		m.biosPatch = map[string]any{}
+	}
+
+	m.logger.Info("settings saved successfully", "system", m.systemPath)
+	return nil
+}
+
+// RevertChanges discards the changes not yet sent to the BMC.
+func (m *RedfishManager) RevertChanges() error {
+	m.bootPatch = map[string]any{}
+	m.optionPatch = map[string]map[string]any{}
+	m.biosPatch = map[string]any{}
+	return nil
+}
+
+// ResetToDefaults resets the BIOS attributes to their defaults at the next
+// reset of the system.
+func (m *RedfishManager) ResetToDefaults() error {
+	_, path, err := m.bios()
+	if err != nil {
+		return err
+	}
+	if err := m.do(http.MethodPost, path+"/Actions/Bios.ResetBios", map[string]any{}, nil); err != nil {
+		return fmt.Errorf("failed to reset BIOS: %w", err)
+	}
+	return m.RevertChanges()
+}
@@ -0,0 +1,210 @@
+package manager
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/metal3-community/uefi-firmware-manager/types"
+)
+
+// fakeBMC serves a minimal Redfish tree and records PATCH requests.
+type fakeBMC struct {
+	mu        sync.Mutex
+	resources map[string]any
+	patches   map[string]map[string]any
+}
+
+func newFakeBMC() *fakeBMC {
+	return &fakeBMC{
+		resources: map[string]any{
+			"/redfish/v1/Systems": map[string]any{
+				"Members": []any{map[string]any{"@odata.id": "/redfish/v1/Systems/1"}},
+			},
+			"/redfish/v1/Systems/1": map[string]any{
+				"AssetTag":    "rack-4",
+				"BiosVersion": "2.19.1",
+				"Bios":        map[string]any{"@odata.id": "/redfish/v1/Systems/1/Bios"},
+				"Boot": map[string]any{
+					"BootOrder":   []string{"Boot0001", "Boot0002"},
+					"BootOptions": map[string]any{"@odata.id": "/redfish/v1/Systems/1/BootOptions"},
+				},
+			},
+			"/redfish/v1/Systems/1/BootOptions": map[string]any{
+				"Members": []any{
+					map[string]any{"@odata.id": "/redfish/v1/Systems/1/BootOptions/0001"},
+					map[string]any{"@odata.id": "/redfish/v1/Systems/1/BootOptions/0002"},
+				},
+			},
+			"/redfish/v1/Systems/1/BootOptions/0001": map[string]any{
+				"BootOptionReference": "Boot0001",
+				"DisplayName":         "Hard Drive",
+				"BootOptionEnabled":   true,
+			},
+			"/redfish/v1/Systems/1/BootOptions/0002": map[string]any{
+				"BootOptionReference": "Boot0002",
+				"DisplayName":         "UEFI PXEv4 (MAC:B8CEF6000001)",
+				"BootOptionEnabled":   true,
+			},
+			"/redfish/v1/Systems/1/Bios": map[string]any{
+				"Attributes": map[string]any{"BootMode": "Uefi"},
+				"@Redfish.Settings": map[string]any{
+					"SettingsObject": map[string]any{"@odata.id": "/redfish/v1/Systems/1/Bios/SD"},
+				},
+			},
+		},
+		patches: map[string]map[string]any{},
+	}
+}
+
+func (b *fakeBMC) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if user, pass, _ := r.BasicAuth(); user != "admin" || pass != "secret" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		resource, found := b.resources[r.URL.Path]
+		if !found {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"error":{"message":"resource not found"}}`))
+			return
+		}
+		_ = json.NewEncoder(w).Encode(resource)
+	case http.MethodPatch:
+		var body map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		b.patches[r.URL.Path] = body
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func newRedfishTestManager(t *testing.T) (*RedfishManager, *fakeBMC) {
+	t.Helper()
+	bmc := newFakeBMC()
+	srv := httptest.NewServer(bmc)
+	t.Cleanup(srv.Close)
+
+	m, err := NewRedfishManager(RedfishConfig{
+		Endpoint: srv.URL + "/",
+		Username: "admin",
+		Password: "secret",
+		Client:   srv.Client(),
+	}, logr.Discard())
+	if err != nil {
+		t.Fatalf("NewRedfishManager() error = %v", err)
+	}
+	return m, bmc
+}
+
+func TestRedfishManager(t *testing.T) {
+	m, bmc := newRedfishTestManager(t)
+	var _ FirmwareManager = m
+
+	if err := m.EnablePXEBoot(false); err != nil {
+		t.Fatalf("EnablePXEBoot() error = %v", err)
+	}
+	if err := m.SetBootOrder([]string{"2", "0001"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.SetBootNext(1); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.SetBiosAttribute("ProcVirtualization", "Enabled"); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := m.GetBootEntries()
+	if err != nil {
+		t.Fatalf("GetBootEntries() error = %v", err)
+	}
+	if len(entries) != 2 || entries[1].Enabled || entries[1].Position != 0 {
+		t.Errorf("GetBootEntries() = %+v, want the disabled PXE entry first", entries)
+	}
+	if next, _ := m.GetBootNext(); next != 1 {
+		t.Errorf("GetBootNext() = %d, want 1", next)
+	}
+	if len(bmc.patches) != 0 {
+		t.Fatalf("changes sent before SaveChanges(): %v", bmc.patches)
+	}
+
+	if err := m.SaveChanges(); err != nil {
+		t.Fatalf("SaveChanges() error = %v", err)
+	}
+	want := map[string]map[string]any{
+		"/redfish/v1/Systems/1": {"Boot": map[string]any{
+			"BootOrder":                 []any{"Boot0002", "Boot0001"},
+			"BootNext":                  "Boot0001",
+			"BootSourceOverrideTarget":  "UefiBootNext",
+			"BootSourceOverrideEnabled": "Once",
+		}},
+		"/redfish/v1/Systems/1/BootOptions/0002": {"BootOptionEnabled": false},
+		"/redfish/v1/Systems/1/Bios/SD":          {"Attributes": map[string]any{"ProcVirtualization": "Enabled"}},
+	}
+	if !reflect.DeepEqual(bmc.patches, want) {
+		t.Errorf("SaveChanges() sent %v, want %v", bmc.patches, want)
+	}
+
+	info, err := m.GetSystemInfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.FirmwareVersion != "2.19.1" || info.AssetTag != "rack-4" {
+		t.Errorf("GetSystemInfo() = %+v", info)
+	}
+}
+
+func TestRedfishManager_Errors(t *testing.T) {
+	m, _ := newRedfishTestManager(t)
+
+	if _, err := m.GetVariable("BootOrder"); !errors.Is(err, ErrNotSupported) {
+		t.Errorf("GetVariable() error = %v, want %v", err, ErrNotSupported)
+	}
+	if err := m.UpdateBootEntry("0007", types.BootEntry{}); err == nil {
+		t.Error("UpdateBootEntry() of a missing entry succeeded")
+	}
+
+	m.systemPath = "/redfish/v1/Systems/2"
+	_, err := m.GetFirmwareVersion()
+	if err == nil || !strings.Contains(err.Error(), "resource not found") {
+		t.Errorf("GetFirmwareVersion() error = %v, want the Redfish message", err)
+	}
+}
+
+func TestRedfishManager_Timeout(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	start := time.Now()
+	_, err := NewRedfishManager(RedfishConfig{
+		Endpoint: srv.URL,
+		SystemID: "1",
+		Timeout:  50 * time.Millisecond,
+	}, logr.Discard())
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("NewRedfishManager() of an unresponsive BMC error = %v, want DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("NewRedfishManager() took %v", elapsed)
+	}
+}
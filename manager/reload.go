@@ -0,0 +1,332 @@
+package manager
+
+import (
+	"context"
+	"crypto"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+
+	"github.com/go-logr/logr"
+	"github.com/metal3-community/uefi-firmware-manager/atomicfile"
+	"github.com/metal3-community/uefi-firmware-manager/audit"
+	"github.com/metal3-community/uefi-firmware-manager/types"
+)
+
+// ServeConfig names the files the configuration of a firmware server is
+// read from.
+type ServeConfig struct {
+	// BaseFirmware, if set, is the firmware image served instead of
+	// edk2.RpiEfi.
+	BaseFirmware string
+	// ProvisioningFile, if set, is the provisioning file applied to the
+	// served firmware.
+	ProvisioningFile string
+	// CertFile and KeyFile, if set, are the PEM TLS certificate and key
+	// GetCertificate returns.
+	CertFile string
+	KeyFile  string
+	// Limits are the ServeLimits of the served firmware. They are shared
+	// by the managers of successive reloads.
+	Limits ServeLimits
+	// ImageKey, if set, verifies the base firmware against its detached
+	// signature, stored next to it with types.ImageSignatureExt, and the
+	// images uploaded to ServeBaseFirmware.
+	ImageKey crypto.PublicKey
+	// Tokens, if set, authorizes the admin requests of the server, such as
+	// ServeReload; see SimpleFirmwareManager.SetTokenValidator. Without it
+	// they are refused.
+	Tokens TokenValidator
+	// Configure, if set, is called with every manager created before it is
+	// served, for instance to add patch hooks or an image signer. The audit
+	// queue of a manager replaced by a reload is closed once its requests
+	// in flight are done.
+	Configure func(*SimpleFirmwareManager)
+}
+
+// Reloader serves firmware with the configuration of a ServeConfig and
+// re-reads it on Reload. A reload builds and warms a new
+// SimpleFirmwareManager and swaps it in: requests in flight finish with
+// the manager they started with, so downloads are not dropped.
+type Reloader struct {
+	config ServeConfig
+	logger logr.Logger
+
+	// mu serializes reloads.
+	mu   sync.Mutex
+	mgr  atomic.Pointer[SimpleFirmwareManager]
+	cert atomic.Pointer[tls.Certificate]
+}
+
+// NewReloader creates a Reloader and loads the configuration of config.
+func NewReloader(config ServeConfig, logger logr.Logger) (*Reloader, error) {
+	r := &Reloader{config: config, logger: logger}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Reload re-reads the base firmware, the provisioning file and the TLS
+// certificate. If any of them fails to load, the current configuration is
+// kept as a whole.
+func (r *Reloader) Reload() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.reload()
+}
+
+// reload loads the configuration; r.mu must be held.
+func (r *Reloader) reload() error {
+	var cert *tls.Certificate
+	if r.config.CertFile != "" || r.config.KeyFile != "" {
+		c, err := tls.LoadX509KeyPair(r.config.CertFile, r.config.KeyFile)
+		if err != nil {
+			return fmt.Errorf("failed to load TLS certificate: %w", err)
+		}
+		cert = &c
+	}
+
+	var (
+		mgr *SimpleFirmwareManager
+		err error
+	)
+	if r.config.BaseFirmware != "" {
+		if r.config.ImageKey != nil {
+			if err := types.VerifyImageFile(r.config.BaseFirmware, r.config.ImageKey); err != nil {
+				return fmt.Errorf("failed to verify base firmware: %w", err)
+			}
+		}
+		image, rerr := os.ReadFile(r.config.BaseFirmware)
+		if rerr != nil {
+			return fmt.Errorf("failed to read base firmware: %w", rerr)
+		}
+		mgr, err = NewSimpleFirmwareManagerWithBase(image, r.logger)
+	} else {
+		mgr, err = NewSimpleFirmwareManager(r.logger)
+	}
+	if err != nil {
+		return err
+	}
+	if r.config.ProvisioningFile != "" {
+		p, err := LoadProvisioning(r.config.ProvisioningFile)
+		if err != nil {
+			return err
+		}
+		mgr.SetProvisioning(&p)
+	}
+	if prev := r.mgr.Load(); prev != nil {
+		mgr.limiter, mgr.generations = prev.limiter, prev.generations
+	} else {
+		mgr.SetServeLimits(r.config.Limits)
+	}
+	mgr.SetTokenValidator(r.config.Tokens)
+	mgr.SetImageVerifier(r.config.ImageKey)
+	if r.config.Configure != nil {
+		r.config.Configure(mgr)
+	}
+	if err := mgr.Warm(); err != nil {
+		return err
+	}
+
+	// The audit queue of the previous manager is closed once the
+	// requests it is serving are done.
+	if prev := r.mgr.Swap(mgr); prev != nil {
+		go prev.closeAudit()
+	}
+	r.cert.Store(cert)
+	r.logger.Info("Loaded server configuration", "baseFirmware", r.config.BaseFirmware,
+		"provisioning", r.config.ProvisioningFile, "cert", r.config.CertFile)
+	return nil
+}
+
+// Manager returns the manager of the current configuration. Callers keep
+// it for the duration of a request.
+func (r *Reloader) Manager() *SimpleFirmwareManager {
+	return r.mgr.Load()
+}
+
+// GetCertificate returns the current TLS certificate, for
+// tls.Config.GetCertificate.
+func (r *Reloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	cert := r.cert.Load()
+	if cert == nil {
+		return nil, fmt.Errorf("no TLS certificate configured")
+	}
+	return cert, nil
+}
+
+// ServeFirmware serves the firmware of macAddr with the current manager,
+// as SimpleFirmwareManager.ServeFirmware does.
+func (r *Reloader) ServeFirmware(w http.ResponseWriter, req *http.Request, macAddr net.HardwareAddr) {
+	r.Manager().ServeFirmware(w, req, macAddr)
+}
+
+// ServeBaseFirmware serves the base firmware of the current manager on GET
+// and HEAD. On PUT, which requires RoleAdmin and is verified and audited
+// as SimpleFirmwareManager.ServeBaseFirmware is, it replaces the base
+// firmware with ReplaceBaseFirmware, so that later reloads keep it.
+func (r *Reloader) ServeBaseFirmware(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPut {
+		r.Manager().ServeBaseFirmware(w, req)
+		return
+	}
+	RequireRole(r.config.Tokens, RoleAdmin, http.HandlerFunc(r.putBaseFirmware)).ServeHTTP(w, req)
+}
+
+// putBaseFirmware replaces the base firmware with the body of req.
+func (r *Reloader) putBaseFirmware(w http.ResponseWriter, req *http.Request) {
+	mgr := r.Manager()
+	before := baseSum(mgr.base.Load())
+	e := audit.Event{Action: audit.ActionReplaceBase, Before: hex.EncodeToString(before[:])}
+	image, sig, status, err := readBaseUpload(w, req, r.config.ImageKey)
+	if err == nil {
+		// Reject invalid images with their error before touching the files.
+		if _, err = newBaseFirmware(image, r.logger); err != nil {
+			status = http.StatusBadRequest
+		}
+	}
+	if err == nil {
+		status = http.StatusNoContent
+		if err = r.ReplaceBaseFirmware(image, sig); err != nil {
+			status = http.StatusInternalServerError
+		} else {
+			e.After = audit.Hash(image)
+		}
+	}
+	e.Status = status
+	mgr.recordAdmin(req, e, err)
+	if err != nil {
+		r.logger.Error(err, "failed to replace base firmware")
+		if status == http.StatusInternalServerError {
+			// The error of a failed reload names local files.
+			http.Error(w, "failed to replace base firmware", status)
+			return
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+	w.WriteHeader(status)
+}
+
+// ReplaceBaseFirmware writes image, and its detached signature sig if it
+// is not nil, to the BaseFirmware file of the ServeConfig and reloads the
+// configuration. The files are replaced atomically, and restored if image
+// is not a valid firmware or the reload fails.
+func (r *Reloader) ReplaceBaseFirmware(image, sig []byte) error {
+	path := r.config.BaseFirmware
+	if path == "" {
+		return fmt.Errorf("no base firmware file configured")
+	}
+	if _, err := newBaseFirmware(image, r.logger); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	restore, err := replaceFiles(map[string][]byte{path: image, path + types.ImageSignatureExt: sig})
+	if err != nil {
+		return err
+	}
+	if err := r.reload(); err != nil {
+		if rerr := restore(); rerr != nil {
+			r.logger.Error(rerr, "failed to restore base firmware", "path", path)
+		}
+		return err
+	}
+	return nil
+}
+
+// replaceFiles atomically writes the data of each path of files, removing
+// those with nil data. The returned function restores the previous files.
+func replaceFiles(files map[string][]byte) (func() error, error) {
+	previous := make(map[string][]byte, len(files))
+	restore := func() error {
+		var errs []error
+		for path, data := range previous {
+			if data == nil {
+				if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+					errs = append(errs, err)
+				}
+				continue
+			}
+			errs = append(errs, atomicfile.WriteFile(path, data, 0o644))
+		}
+		return errors.Join(errs...)
+	}
+	for path, data := range files {
+		old, err := os.ReadFile(path)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, errors.Join(fmt.Errorf("failed to read %s: %w", path, err), restore())
+		}
+		previous[path] = old
+		if data == nil {
+			err = os.Remove(path)
+			if errors.Is(err, fs.ErrNotExist) {
+				err = nil
+			}
+		} else {
+			err = atomicfile.WriteFile(path, data, 0o644)
+		}
+		if err != nil {
+			return nil, errors.Join(fmt.Errorf("failed to write %s: %w", path, err), restore())
+		}
+	}
+	return restore, nil
+}
+
+// ServeReload reloads the configuration on POST, for a /reload admin
+// endpoint. It requires RoleAdmin with the TokenValidator of ServeConfig
+// and answers 204 No Content, or 500 if the reload failed; the error is
+// logged.
+func (r *Reloader) ServeReload(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	RequireRole(r.config.Tokens, RoleAdmin, http.HandlerFunc(r.serveReload)).ServeHTTP(w, req)
+}
+
+// serveReload reloads the configuration.
+func (r *Reloader) serveReload(w http.ResponseWriter, req *http.Request) {
+	if err := r.Reload(); err != nil {
+		// The error names local files; it is only logged.
+		r.logger.Error(err, "failed to reload server configuration")
+		http.Error(w, "failed to reload server configuration", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ReloadOnSignal reloads the configuration whenever the process receives
+// one of sigs, SIGHUP if none are given, until ctx is done. Failed reloads
+// are logged and keep the current configuration.
+func (r *Reloader) ReloadOnSignal(ctx context.Context, sigs ...os.Signal) {
+	if len(sigs) == 0 {
+		sigs = []os.Signal{syscall.SIGHUP}
+	}
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, sigs...)
+	defer signal.Stop(ch)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case sig := <-ch:
+			if err := r.Reload(); err != nil {
+				r.logger.Error(err, "failed to reload server configuration", "signal", sig.String())
+			}
+		}
+	}
+}
@@ -0,0 +1,231 @@
+package manager
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/metal3-community/uefi-firmware-manager/audit"
+	"github.com/metal3-community/uefi-firmware-manager/edk2"
+	"github.com/metal3-community/uefi-firmware-manager/types"
+)
+
+// writeTestKeyPair writes the PEM certificate and key of c to dir.
+func writeTestKeyPair(t *testing.T, dir string, c testCert) (string, string) {
+	t.Helper()
+	keyDER, err := x509.MarshalECPrivateKey(c.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.cert.Raw}), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
+
+func TestReloader(t *testing.T) {
+	dir := t.TempDir()
+	provisioning := filepath.Join(dir, "provisioning.yaml")
+	if err := os.WriteFile(provisioning, []byte(provisioningYAML), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	base := filepath.Join(dir, "RPI_EFI.fd")
+	if err := os.WriteFile(base, edk2.RpiEfi, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	first := newTestCert(t, "first", nil)
+	certFile, keyFile := writeTestKeyPair(t, dir, first)
+
+	configured := 0
+	r, err := NewReloader(ServeConfig{
+		BaseFirmware:     base,
+		ProvisioningFile: provisioning,
+		CertFile:         certFile,
+		KeyFile:          keyFile,
+		Limits:           ServeLimits{Rate: 1, MaxGenerations: 4},
+		Tokens:           testTokens,
+		Configure: func(m *SimpleFirmwareManager) {
+			configured++
+			m.SetAuditSink(audit.SinkFunc(func(audit.Event) error { return nil }))
+		},
+	}, logr.Discard())
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := r.Manager()
+	if old.provisioning == nil || configured != 1 || !r.Manager().Readiness("").OK {
+		t.Fatalf("initial manager not configured: provisioning %v, configured %d", old.provisioning, configured)
+	}
+	cert, err := r.GetCertificate(nil)
+	if err != nil || !bytes.Equal(cert.Certificate[0], first.cert.Raw) {
+		t.Fatalf("GetCertificate() = %v", err)
+	}
+
+	// A reload swaps in a new manager and certificate.
+	second := newTestCert(t, "second", nil)
+	writeTestKeyPair(t, dir, second)
+	w := httptest.NewRecorder()
+	r.ServeReload(w, adminRequest(http.MethodPost, "/reload", nil))
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("reload = %d %s", w.Code, w.Body)
+	}
+	if r.Manager() == old || configured != 2 {
+		t.Error("reload did not replace the manager")
+	}
+	if r.Manager().limiter != old.limiter || r.Manager().generations != old.generations {
+		t.Error("reload did not keep the serve limits")
+	}
+	if cert, _ := r.GetCertificate(nil); !bytes.Equal(cert.Certificate[0], second.cert.Raw) {
+		t.Error("reload did not replace the certificate")
+	}
+	select {
+	case <-old.auditQueue.done:
+	case <-time.After(time.Second):
+		t.Error("reload did not close the audit queue of the replaced manager")
+	}
+
+	// The manager of a request in flight keeps serving.
+	mac, _ := net.ParseMAC("d8:3a:dd:61:4d:15")
+	if w := serveFirmware(old, mac, http.MethodGet, nil); w.Code != http.StatusOK {
+		t.Errorf("replaced manager status = %d", w.Code)
+	}
+
+	// A failed reload keeps the whole current configuration.
+	current := r.Manager()
+	if err := os.WriteFile(provisioning, []byte("profiles: []\nhost: []\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	w = httptest.NewRecorder()
+	r.ServeReload(w, adminRequest(http.MethodPost, "/reload", nil))
+	if w.Code != http.StatusInternalServerError || strings.Contains(w.Body.String(), dir) {
+		t.Errorf("failed reload = %d %s", w.Code, w.Body)
+	}
+	if r.Manager() != current {
+		t.Error("failed reload replaced the manager")
+	}
+	if cert, _ := r.GetCertificate(nil); !bytes.Equal(cert.Certificate[0], second.cert.Raw) {
+		t.Error("failed reload replaced the certificate")
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeReload(w, httptest.NewRequest(http.MethodPost, "/reload", nil))
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("unauthenticated reload = %d", w.Code)
+	}
+	if r.Manager() != current {
+		t.Error("unauthenticated reload replaced the manager")
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeReload(w, httptest.NewRequest(http.MethodGet, "/reload", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("GET reload = %d", w.Code)
+	}
+}
+
+func TestReloader_ServeBaseFirmware(t *testing.T) {
+	dir := t.TempDir()
+	provisioning := filepath.Join(dir, "provisioning.yaml")
+	if err := os.WriteFile(provisioning, []byte(provisioningYAML), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	pub, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sign := func(image []byte) []byte {
+		sig, err := types.SignImage(bytes.NewReader(image), key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return sig
+	}
+	base := filepath.Join(dir, "RPI_EFI.fd")
+	if err := os.WriteFile(base, edk2.RpiEfi, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(base+types.ImageSignatureExt, sign(edk2.RpiEfi), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	r, err := NewReloader(ServeConfig{
+		BaseFirmware:     base,
+		ProvisioningFile: provisioning,
+		ImageKey:         pub,
+		Tokens:           testTokens,
+	}, logr.Discard())
+	if err != nil {
+		t.Fatal(err)
+	}
+	put := func(image []byte) int {
+		req := adminRequest(http.MethodPut, "/base", bytes.NewReader(image))
+		req.Header.Set(ImageSignatureHeader, base64.StdEncoding.EncodeToString(sign(image)))
+		w := httptest.NewRecorder()
+		r.ServeBaseFirmware(w, req)
+		return w.Code
+	}
+	servedBase := func() []byte { return baseImage(r.Manager().base.Load()) }
+
+	image := bytes.Clone(edk2.RpiEfi)
+	image[len(image)-1] ^= 0xff
+	if code := put(image); code != http.StatusNoContent {
+		t.Fatalf("PUT base = %d", code)
+	}
+	if onDisk, _ := os.ReadFile(base); !bytes.Equal(onDisk, image) {
+		t.Error("uploaded base firmware not written to the base firmware file")
+	}
+	if err := types.VerifyImageFile(base, pub); err != nil {
+		t.Errorf("uploaded signature not written: %v", err)
+	}
+	// A later reload keeps the uploaded image.
+	if err := r.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(servedBase(), image) {
+		t.Error("reload undid the uploaded base firmware")
+	}
+
+	if code := put([]byte("not firmware")); code != http.StatusBadRequest {
+		t.Errorf("PUT invalid base = %d", code)
+	}
+	// A failed reload restores the previous files.
+	if err := os.WriteFile(provisioning, []byte("profiles: []\nhost: []\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	current := r.Manager()
+	if code := put(edk2.RpiEfi); code != http.StatusInternalServerError {
+		t.Errorf("PUT base with a failing reload = %d", code)
+	}
+	if onDisk, _ := os.ReadFile(base); !bytes.Equal(onDisk, image) || r.Manager() != current {
+		t.Error("failed PUT replaced the base firmware")
+	}
+	if err := types.VerifyImageFile(base, pub); err != nil {
+		t.Errorf("failed PUT did not restore the signature: %v", err)
+	}
+
+	w := httptest.NewRecorder()
+	r.ServeBaseFirmware(w, httptest.NewRequest(http.MethodPut, "/base", bytes.NewReader(image)))
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("unauthenticated PUT = %d", w.Code)
+	}
+	w = httptest.NewRecorder()
+	r.ServeBaseFirmware(w, httptest.NewRequest(http.MethodGet, "/base", nil))
+	if w.Code != http.StatusOK || !bytes.Equal(w.Body.Bytes(), image) {
+		t.Errorf("GET base = %d with %d bytes", w.Code, w.Body.Len())
+	}
+}
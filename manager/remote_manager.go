@@ -0,0 +1,194 @@
+package manager
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/metal3-community/uefi-firmware-manager/types"
+)
+
+// Transport copies firmware images between this host and a remote one.
+type Transport interface {
+	// Fetch copies the remote file to the local file.
+	Fetch(remotePath, localPath string) error
+	// Put replaces the remote file with the local file. The remote file
+	// must never be seen half written.
+	Put(localPath, remotePath string) error
+}
+
+// SSHTransport is a Transport running the system ssh client, so the usual
+// ssh configuration, agent and known hosts apply.
+type SSHTransport struct {
+	// Host is the destination, optionally as user@host.
+	Host string
+	// Port is the ssh port; zero uses the configured port.
+	Port int
+	// IdentityFile is the private key to use; empty uses the configured
+	// keys.
+	IdentityFile string
+	// Options are extra ssh -o options such as "StrictHostKeyChecking=yes".
+	Options []string
+}
+
+// Fetch copies the remote file to the local file.
+func (t *SSHTransport) Fetch(remotePath, localPath string) error {
+	var out bytes.Buffer
+	if err := t.run("cat -- "+shellQuote(remotePath), nil, &out); err != nil {
+		return fmt.Errorf("failed to fetch %s:%s: %w", t.Host, remotePath, err)
+	}
+	return os.WriteFile(localPath, out.Bytes(), 0o644)
+}
+
+// Put uploads the local file to a new temporary file next to the remote
+// file and renames it over the remote file, so that concurrent uploads do
+// not write to the same file.
+func (t *SSHTransport) Put(localPath, remotePath string) error {
+	f, err := os.Open(localPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	suffix := make([]byte, 8)
+	if _, err := rand.Read(suffix); err != nil {
+		return err
+	}
+	tmp := shellQuote(path.Join(path.Dir(remotePath),
+		"."+path.Base(remotePath)+"."+hex.EncodeToString(suffix)+".tmp"))
+	script := fmt.Sprintf("cat > %s && mv -f -- %s %s || { rm -f -- %s; exit 1; }",
+		tmp, tmp, shellQuote(remotePath), tmp)
+	if err := t.run(script, f, io.Discard); err != nil {
+		return fmt.Errorf("failed to write %s:%s: %w", t.Host, remotePath, err)
+	}
+	return nil
+}
+
+func (t *SSHTransport) run(script string, stdin io.Reader, stdout io.Writer) error {
+	args := []string{"-o", "BatchMode=yes"}
+	if t.Port != 0 {
+		args = append(args, "-p", strconv.Itoa(t.Port))
+	}
+	if t.IdentityFile != "" {
+		args = append(args, "-i", t.IdentityFile)
+	}
+	for _, opt := range t.Options {
+		args = append(args, "-o", opt)
+	}
+	args = append(args, "--", t.Host, script)
+
+	var stderr bytes.Buffer
+	cmd := exec.Command("ssh", args...)
+	cmd.Stdin = stdin
+	cmd.Stdout = stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("%w: %s", err, msg)
+		}
+		return err
+	}
+	return nil
+}
+
+// shellQuote quotes s for a POSIX shell.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// RemoteManager manages a firmware image on another host, for instance a
+// Raspberry Pi SD card mounted on a jump host or an NFS boot server. The
+// image is edited in a local copy, and SaveChanges writes it back.
+type RemoteManager struct {
+	FirmwareManager
+
+	transport  Transport
+	remotePath string
+	localPath  string
+	dir        string
+}
+
+// NewRemoteManager fetches the image at remotePath through t and opens the
+// local copy with open, for example NewEDK2Manager. Close removes the
+// local copy.
+func NewRemoteManager(
+	t Transport,
+	remotePath string,
+	open func(localPath string) (FirmwareManager, error),
+) (*RemoteManager, error) {
+	dir, err := os.MkdirTemp("", "uefi-remote-")
+	if err != nil {
+		return nil, err
+	}
+	rm := &RemoteManager{
+		transport:  t,
+		remotePath: remotePath,
+		localPath:  filepath.Join(dir, path.Base(remotePath)),
+		dir:        dir,
+	}
+	if err := t.Fetch(remotePath, rm.localPath); err != nil {
+		_ = os.RemoveAll(dir)
+		return nil, err
+	}
+	rm.FirmwareManager, err = open(rm.localPath)
+	if err != nil {
+		_ = os.RemoveAll(dir)
+		return nil, err
+	}
+	return rm, nil
+}
+
+// SaveChanges saves the local copy and writes it, and its detached
+// signature if there is one, back to the remote host.
+func (rm *RemoteManager) SaveChanges() error {
+	if err := rm.FirmwareManager.SaveChanges(); err != nil {
+		return err
+	}
+	return rm.put()
+}
+
+// UpdateFirmware updates the local copy and writes it back to the remote
+// host.
+func (rm *RemoteManager) UpdateFirmware(firmwareData []byte) error {
+	if err := rm.FirmwareManager.UpdateFirmware(firmwareData); err != nil {
+		return err
+	}
+	return rm.put()
+}
+
+// RevertChanges fetches the remote image again and discards all changes.
+func (rm *RemoteManager) RevertChanges() error {
+	if err := rm.transport.Fetch(rm.remotePath, rm.localPath); err != nil {
+		return err
+	}
+	return rm.FirmwareManager.RevertChanges()
+}
+
+// Close removes the local copy.
+func (rm *RemoteManager) Close() error {
+	return os.RemoveAll(rm.dir)
+}
+
+// put writes the local copy back, then its signature, so that the remote
+// signature is never newer than the image it signs.
+func (rm *RemoteManager) put() error {
+	if err := rm.transport.Put(rm.localPath, rm.remotePath); err != nil {
+		return err
+	}
+	sig := rm.localPath + types.ImageSignatureExt
+	if _, err := os.Stat(sig); errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	return rm.transport.Put(sig, rm.remotePath+types.ImageSignatureExt)
+}
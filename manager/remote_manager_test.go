@@ -0,0 +1,103 @@
+package manager
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/go-logr/logr"
+	"github.com/metal3-community/uefi-firmware-manager/types"
+)
+
+// copyTransport is a Transport whose remote host is the local file system.
+type copyTransport struct {
+	// puts records the remote paths written, in order.
+	puts []string
+}
+
+func (c *copyTransport) Fetch(remotePath, localPath string) error {
+	return copyFile(remotePath, localPath)
+}
+
+func (c *copyTransport) Put(localPath, remotePath string) error {
+	c.puts = append(c.puts, remotePath)
+	return copyFile(localPath, remotePath)
+}
+
+func TestRemoteManager(t *testing.T) {
+	firmware, err := os.ReadFile("../edk2/RPI_EFI.fd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	remote := filepath.Join(t.TempDir(), "RPI_EFI.fd")
+	if err := os.WriteFile(remote, firmware, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	transport := &copyTransport{}
+	m, err := NewRemoteManager(transport, remote, func(localPath string) (FirmwareManager, error) {
+		return NewEDK2Manager(localPath, logr.Discard())
+	})
+	if err != nil {
+		t.Fatalf("NewRemoteManager() error = %v", err)
+	}
+	t.Cleanup(func() { _ = m.Close() })
+
+	if err := m.SetFirmwareTimeoutSeconds(7); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(remote); !bytes.Equal(data, firmware) {
+		t.Fatal("remote image changed before SaveChanges()")
+	}
+	if err := m.SaveChanges(); err != nil {
+		t.Fatalf("SaveChanges() error = %v", err)
+	}
+	if len(transport.puts) != 1 {
+		t.Errorf("SaveChanges() wrote %v, want only the image", transport.puts)
+	}
+
+	// The image is written before its signature.
+	if err := os.WriteFile(m.localPath+types.ImageSignatureExt, []byte("signature"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	transport.puts = nil
+	if err := m.SaveChanges(); err != nil {
+		t.Fatalf("SaveChanges() error = %v", err)
+	}
+	if want := []string{remote, remote + types.ImageSignatureExt}; !reflect.DeepEqual(transport.puts, want) {
+		t.Errorf("SaveChanges() wrote %v, want %v", transport.puts, want)
+	}
+
+	reopened, err := NewEDK2Manager(remote, logr.Discard())
+	if err != nil {
+		t.Fatal(err)
+	}
+	timeout, err := reopened.GetVariable("Timeout")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := timeout.GetUint16(); got != 7 {
+		t.Errorf("remote Timeout = %d, want 7", got)
+	}
+
+	if err := m.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(m.localPath); !os.IsNotExist(err) {
+		t.Errorf("Close() left the local copy: %v", err)
+	}
+}
+
+func TestShellQuote(t *testing.T) {
+	tests := map[string]string{
+		"/boot/RPI_EFI.fd": `'/boot/RPI_EFI.fd'`,
+		"/srv/it's here":   `'/srv/it'\''s here'`,
+	}
+	for in, want := range tests {
+		if got := shellQuote(in); got != want {
+			t.Errorf("shellQuote(%q) = %s, want %s", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package manager
+
+import (
+	"encoding/binary"
+	"sort"
+
+	"github.com/metal3-community/uefi-firmware-manager/audit"
+	"github.com/metal3-community/uefi-firmware-manager/efi"
+	"github.com/metal3-community/uefi-firmware-manager/types"
+	"github.com/metal3-community/uefi-firmware-manager/varstore"
+)
+
+// SaveReporter is implemented by managers that can report what saving
+// wrote.
+type SaveReporter interface {
+	// SaveChangesWithReport saves like SaveChanges, but skips the write
+	// when no variable changed since the last save, and reports the
+	// changed variables and the space used.
+	SaveChangesWithReport() (types.SaveReport, error)
+}
+
+// SaveWithReport saves the changes of m, with the report of its
+// SaveChangesWithReport if it implements SaveReporter. Other managers
+// always save, and their report only tells that the firmware was written.
+func SaveWithReport(m FirmwareManager) (types.SaveReport, error) {
+	if r, ok := m.(SaveReporter); ok {
+		return r.SaveChangesWithReport()
+	}
+	if err := m.SaveChanges(); err != nil {
+		return types.SaveReport{}, err
+	}
+	return types.SaveReport{Written: true}, nil
+}
+
+// varHashes returns the hashes of the attributes and data of the
+// variables of l, by name.
+func varHashes(l efi.EfiVarList) map[string]string {
+	hashes := make(map[string]string, len(l))
+	for name, v := range l.ByName() {
+		data := binary.LittleEndian.AppendUint32(nil, uint32(v.Attr))
+		hashes[name] = audit.Hash(append(data, v.Data...))
+	}
+	return hashes
+}
+
+// saveIfChanged fills the changed variables of report from the hashes of
+// the saved and current variables, and calls save if any changed.
+func saveIfChanged(report types.SaveReport, saved, current map[string]string, save func() error) (types.SaveReport, error) {
+	for name, hash := range current {
+		switch before, ok := saved[name]; {
+		case !ok:
+			report.Added = append(report.Added, name)
+		case before != hash:
+			report.Modified = append(report.Modified, name)
+		}
+	}
+	for name := range saved {
+		if _, ok := current[name]; !ok {
+			report.Deleted = append(report.Deleted, name)
+		}
+	}
+	sort.Strings(report.Added)
+	sort.Strings(report.Modified)
+	sort.Strings(report.Deleted)
+
+	if !report.Changed() {
+		return report, nil
+	}
+	if err := save(); err != nil {
+		return report, err
+	}
+	report.Written = true
+	return report, nil
+}
+
+// SaveChangesWithReport saves the variables unless none changed since the
+// firmware was loaded or last saved, and reports the changes and the
+// space the variables use in the varstore.
+func (m *EDK2Manager) SaveChangesWithReport() (types.SaveReport, error) {
+	report := types.SaveReport{
+		BytesUsed: varstore.UsedSpace(m.varList),
+		Capacity:  m.varStore.Capacity(),
+	}
+	return saveIfChanged(report, m.savedHashes, varHashes(m.varList), m.SaveChanges)
+}
+
+// SaveChangesWithReport records the current variables as the saved state
+// if any changed since the last save. There is no varstore, so the report
+// has no capacity.
+func (m *MemoryManager) SaveChangesWithReport() (types.SaveReport, error) {
+	report := types.SaveReport{BytesUsed: varstore.UsedSpace(m.varList)}
+	return saveIfChanged(report, varHashes(m.saved), varHashes(m.varList), m.SaveChanges)
+}
+
+// SaveChangesWithReport writes the variables changed since the last load
+// or save, like SaveChanges, and reports them. Efivarfs does not expose
+// the size of the variable store, so the report has no space figures.
+func (m *EfivarfsManager) SaveChangesWithReport() (types.SaveReport, error) {
+	saved := make(efi.EfiVarList, len(m.saved))
+	for key, v := range m.saved {
+		saved[key] = &v
+	}
+	return saveIfChanged(types.SaveReport{}, varHashes(saved), varHashes(m.varList), m.SaveChanges)
+}
@@ -0,0 +1,103 @@
+package manager
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/go-logr/logr"
+	"github.com/metal3-community/uefi-firmware-manager/edk2"
+	"github.com/metal3-community/uefi-firmware-manager/efi"
+)
+
+func TestEDK2Manager_SaveChangesWithReport(t *testing.T) {
+	path := filepath.Join(t.TempDir(), edk2.FirmwareFileName)
+	if err := os.WriteFile(path, edk2.RpiEfi, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	fm, err := NewEDK2Manager(path, logr.Discard())
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := fm.(*EDK2Manager)
+
+	// The embedded image has an empty varstore.
+	if err := m.SetFirmwareTimeoutSeconds(5); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := SaveWithReport(m); err != nil {
+		t.Fatalf("SaveWithReport() error = %v", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := SaveWithReport(m)
+	if err != nil {
+		t.Fatalf("SaveWithReport() error = %v", err)
+	}
+	if report.Written || report.Changed() {
+		t.Errorf("SaveWithReport() without changes = %+v, want no write", report)
+	}
+	if report.Capacity == 0 || report.BytesUsed == 0 || report.BytesUsed > report.Capacity {
+		t.Errorf("SaveWithReport() space = %d of %d bytes", report.BytesUsed, report.Capacity)
+	}
+	if after, err := os.Stat(path); err != nil || !after.ModTime().Equal(info.ModTime()) {
+		t.Error("SaveWithReport() without changes wrote the firmware")
+	}
+
+	timeout, _ := m.GetFirmwareTimeoutSeconds()
+	if err := m.SetFirmwareTimeoutSeconds(timeout + 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.SetVariable("Example", &efi.EfiVar{
+		Name: efi.FromString("Example"),
+		Guid: efi.EFI_GLOBAL_VARIABLE_GUID,
+		Attr: efi.EfiVariableDefault,
+		Data: []byte{1},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	report, err = SaveWithReport(m)
+	if err != nil {
+		t.Fatalf("SaveWithReport() error = %v", err)
+	}
+	if !report.Written || !slices.Equal(report.Added, []string{"Example"}) ||
+		!slices.Equal(report.Modified, []string{"Timeout"}) || len(report.Deleted) != 0 {
+		t.Errorf("SaveWithReport() = %+v, want Example added and Timeout modified", report)
+	}
+
+	if err := m.DeleteVariable("Example"); err != nil {
+		t.Fatal(err)
+	}
+	report, err = SaveWithReport(m)
+	if err != nil || !report.Written || !slices.Equal(report.Deleted, []string{"Example"}) {
+		t.Errorf("SaveWithReport() = %+v, %v; want Example deleted", report, err)
+	}
+	if report, _ = SaveWithReport(m); report.Written {
+		t.Errorf("SaveWithReport() right after a save = %+v, want no write", report)
+	}
+}
+
+func TestSaveWithReport_Other(t *testing.T) {
+	m := NewMemoryManager(efi.NewEfiVarList())
+	if report, err := SaveWithReport(m); err != nil || report.Written {
+		t.Errorf("SaveWithReport() of MemoryManager without changes = %+v, %v; want no write", report, err)
+	}
+	if err := m.SetFirmwareTimeoutSeconds(5); err != nil {
+		t.Fatal(err)
+	}
+	report, err := SaveWithReport(m)
+	if err != nil || !report.Written || !slices.Equal(report.Added, []string{"Timeout"}) {
+		t.Errorf("SaveWithReport() of MemoryManager = %+v, %v; want Timeout added", report, err)
+	}
+
+	// Managers without SaveChangesWithReport always save.
+	wrapped := struct{ FirmwareManager }{m}
+	report, err = SaveWithReport(wrapped)
+	if err != nil || !report.Written || report.Changed() {
+		t.Errorf("SaveWithReport() of a plain manager = %+v, %v; want a bare write", report, err)
+	}
+}
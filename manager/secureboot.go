@@ -0,0 +1,228 @@
+package manager
+
+import (
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/metal3-community/uefi-firmware-manager/edk2"
+	"github.com/metal3-community/uefi-firmware-manager/efi"
+	"github.com/metal3-community/uefi-firmware-manager/types"
+)
+
+// secureBootAttr are the attributes of the secure boot key variables.
+const secureBootAttr = efi.EFI_VARIABLE_NON_VOLATILE |
+	efi.EFI_VARIABLE_BOOTSERVICE_ACCESS |
+	efi.EFI_VARIABLE_RUNTIME_ACCESS |
+	efi.EFI_VARIABLE_TIME_BASED_AUTHENTICATED_WRITE_ACCESS
+
+// EnrollSecureBootKeys writes the keys of ks to the db, dbx, KEK and PK
+// variables, replacing their contents. Variables whose key list is empty
+// are left unchanged. PK is written last, since it ends setup mode.
+// Changes are not saved.
+func EnrollSecureBootKeys(m FirmwareManager, ks types.SecureBootKeySet) error {
+	if err := ks.Validate(); err != nil {
+		return fmt.Errorf("invalid key set: %w", err)
+	}
+
+	now := time.Now().UTC()
+	for _, v := range []struct {
+		name string
+		guid string
+		keys []types.SecureBootKey
+	}{
+		{"db", efi.EfiImageSecurityDatabase, ks.DB},
+		{"dbx", efi.EfiImageSecurityDatabase, ks.DBX},
+		{"KEK", efi.EFI_GLOBAL_VARIABLE, ks.KEK},
+		{"PK", efi.EFI_GLOBAL_VARIABLE, ks.PK},
+	} {
+		if len(v.keys) == 0 {
+			continue
+		}
+		data, err := types.SignatureDatabase(v.keys)
+		if err != nil {
+			return fmt.Errorf("failed to encode %s: %w", v.name, err)
+		}
+		key := efi.VarKey(v.name, efi.StringToGUID(v.guid))
+		if err := m.SetVariable(key, &efi.EfiVar{Attr: secureBootAttr, Data: data, Time: &now}); err != nil {
+			return fmt.Errorf("failed to set %s: %w", v.name, err)
+		}
+	}
+	return nil
+}
+
+// ErrKeyNotSigned is returned by RotateSecureBootKeys when a new key is
+// not signed by the platform key.
+var ErrKeyNotSigned = errors.New("not signed by the platform key")
+
+// RotateSecureBootKeys enrolls ks like EnrollSecureBootKeys, but first
+// checks the chain of trust the firmware would enforce on authenticated
+// writes: while a platform key is enrolled, a new PK must be signed by the
+// current one, and new KEK certificates must be signed by the resulting
+// PK. If a write fails, the key variables are restored to their previous
+// contents. Changes are not saved.
+func RotateSecureBootKeys(m FirmwareManager, ks types.SecureBootKeySet) error {
+	if err := ks.Validate(); err != nil {
+		return fmt.Errorf("invalid key set: %w", err)
+	}
+
+	current, err := SecureBootKeys(m)
+	if err != nil {
+		return err
+	}
+	if err := checkRotation(current, ks); err != nil {
+		return err
+	}
+
+	vars, err := m.ListVariables()
+	if err != nil {
+		return err
+	}
+	saved := map[string]*efi.EfiVar{}
+	for _, name := range []string{"db", "dbx", "KEK", "PK"} {
+		if v, found := vars[name]; found {
+			prev := *v
+			saved[name] = &prev
+		}
+	}
+
+	if err := EnrollSecureBootKeys(m, ks); err != nil {
+		if rerr := restoreSecureBootVars(m, saved); rerr != nil {
+			return errors.Join(err, fmt.Errorf("failed to roll back: %w", rerr))
+		}
+		return err
+	}
+	return nil
+}
+
+// checkRotation verifies that the PK and KEK of next are signed by the
+// platform key of current. Nothing is checked in setup mode.
+func checkRotation(current, next types.SecureBootKeySet) error {
+	if len(current.PK) == 0 {
+		return nil
+	}
+	pk, err := x509.ParseCertificate(current.PK[0].Data)
+	if err != nil {
+		return fmt.Errorf("failed to parse enrolled PK: %w", err)
+	}
+
+	if len(next.PK) > 0 {
+		if err := checkSignedBy(next.PK[0], pk); err != nil {
+			return fmt.Errorf("new PK: %w", err)
+		}
+		// Already parsed by Validate.
+		pk, _ = x509.ParseCertificate(next.PK[0].Data)
+	}
+	for i, key := range next.KEK {
+		if err := checkSignedBy(key, pk); err != nil {
+			return fmt.Errorf("new KEK %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// checkSignedBy checks that key is the certificate pk or a certificate
+// signed by it. The basic constraints of pk are not checked, since
+// platform keys are often not CA certificates.
+func checkSignedBy(key types.SecureBootKey, pk *x509.Certificate) error {
+	if key.Type != efi.EfiCertX509 {
+		return fmt.Errorf("%w: not a certificate", ErrKeyNotSigned)
+	}
+	cert, err := x509.ParseCertificate(key.Data)
+	if err != nil {
+		return err
+	}
+	if cert.Equal(pk) {
+		return nil
+	}
+	if err := pk.CheckSignature(cert.SignatureAlgorithm, cert.RawTBSCertificate, cert.Signature); err != nil {
+		return fmt.Errorf("%w: %v", ErrKeyNotSigned, err)
+	}
+	return nil
+}
+
+// restoreSecureBootVars sets the key variables back to saved, deleting
+// the ones that did not exist.
+func restoreSecureBootVars(m FirmwareManager, saved map[string]*efi.EfiVar) error {
+	vars, err := m.ListVariables()
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, name := range []string{"PK", "KEK", "dbx", "db"} {
+		if v, found := saved[name]; found {
+			errs = append(errs, m.SetVariable(v.Key(), v))
+		} else if v, found := vars[name]; found {
+			errs = append(errs, m.DeleteVariable(v.Key()))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// EnrollDefaultKeys enrolls the default KEK, db and dbx of edk2.DefaultKeys
+// together with pk, which may be empty to keep the current platform key.
+// It returns edk2.ErrNoDefaultKeys unless built with the defaultkeys build
+// tag. Changes are not saved.
+func EnrollDefaultKeys(m FirmwareManager, pk []types.SecureBootKey) error {
+	ks, err := edk2.DefaultKeys()
+	if err != nil {
+		return err
+	}
+	ks.PK = pk
+	return EnrollSecureBootKeys(m, ks)
+}
+
+// SecureBootKeys reads the key set enrolled in the firmware.
+func SecureBootKeys(m FirmwareManager) (types.SecureBootKeySet, error) {
+	vars, err := m.ListVariables()
+	if err != nil {
+		return types.SecureBootKeySet{}, err
+	}
+
+	var ks types.SecureBootKeySet
+	for _, v := range []struct {
+		name string
+		keys *[]types.SecureBootKey
+	}{
+		{"PK", &ks.PK},
+		{"KEK", &ks.KEK},
+		{"db", &ks.DB},
+		{"dbx", &ks.DBX},
+	} {
+		ev, found := vars[v.name]
+		if !found || len(ev.Data) == 0 {
+			continue
+		}
+		keys, err := types.ParseSignatureDatabase(ev.Data)
+		if err != nil {
+			return ks, fmt.Errorf("failed to parse %s: %w", v.name, err)
+		}
+		*v.keys = keys
+	}
+	return ks, nil
+}
+
+// SecureBootReport summarizes the secure boot state, the enrolled
+// certificates and their weaknesses.
+func SecureBootReport(m FirmwareManager) (types.SecureBootReport, error) {
+	ks, err := SecureBootKeys(m)
+	if err != nil {
+		return types.SecureBootReport{}, err
+	}
+	vars, err := m.ListVariables()
+	if err != nil {
+		return types.SecureBootReport{}, err
+	}
+	flag := func(name, guid string) bool {
+		v, found := vars[name]
+		if !found {
+			v, found = vars[efi.VarKey(name, efi.StringToGUID(guid))]
+		}
+		return found && v.Guid.String() == guid && len(v.Data) > 0 && v.Data[0] != 0
+	}
+	return types.NewSecureBootReport(ks,
+		flag("SecureBootEnable", efi.EfiSecureBootEnableDisable),
+		flag("VendorKeysNv", efi.EfiVendorKeysNv),
+		time.Now()), nil
+}
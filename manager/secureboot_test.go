@@ -0,0 +1,229 @@
+package manager
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"math/big"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/metal3-community/uefi-firmware-manager/edk2"
+	"github.com/metal3-community/uefi-firmware-manager/efi"
+	"github.com/metal3-community/uefi-firmware-manager/types"
+)
+
+// testCert is a certificate and its private key.
+type testCert struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+// newTestCert creates a certificate signed by parent, or a self-signed one
+// if parent is nil.
+func newTestCert(t *testing.T, name string, parent *testCert) testCert {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	signer, signerKey := tmpl, key
+	if parent != nil {
+		signer, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return testCert{cert: cert, key: key}
+}
+
+func (c testCert) sbKey() types.SecureBootKey {
+	return types.SecureBootKey{Type: efi.EfiCertX509, Owner: efi.Shim, Data: c.cert.Raw}
+}
+
+func TestEnrollSecureBootKeys(t *testing.T) {
+	m := newFixtureManager(t)
+	certKey := newTestCert(t, "Test PK", nil).sbKey()
+	ks := types.SecureBootKeySet{
+		PK:  []types.SecureBootKey{certKey},
+		KEK: []types.SecureBootKey{certKey},
+		DB:  []types.SecureBootKey{certKey},
+	}
+	if err := EnrollSecureBootKeys(m, ks); err != nil {
+		t.Fatalf("EnrollSecureBootKeys() error = %v", err)
+	}
+	if err := m.SaveChanges(); err != nil {
+		t.Fatalf("SaveChanges() error = %v", err)
+	}
+
+	reloaded, err := NewEDK2Manager(m.firmwarePath, logr.Discard())
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := SecureBootKeys(reloaded)
+	if err != nil {
+		t.Fatalf("SecureBootKeys() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, ks) {
+		t.Errorf("SecureBootKeys() = %+v, want %+v", got, ks)
+	}
+
+	pk, err := reloaded.GetVariable("PK")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pk.Attr != secureBootAttr || pk.Guid.String() != efi.EFI_GLOBAL_VARIABLE {
+		t.Errorf("PK attr = %#x, guid = %s", pk.Attr, pk.Guid)
+	}
+	db, err := reloaded.GetVariable("db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if db.Guid.String() != efi.EfiImageSecurityDatabase {
+		t.Errorf("db guid = %s, want %s", db.Guid, efi.EfiImageSecurityDatabase)
+	}
+
+	ks.PK = append(ks.PK, certKey)
+	if err := EnrollSecureBootKeys(m, ks); err == nil {
+		t.Error("EnrollSecureBootKeys() accepted two platform keys")
+	}
+}
+
+func TestEnrollDefaultKeys(t *testing.T) {
+	m := newFixtureManager(t)
+	err := EnrollDefaultKeys(m, nil)
+	if errors.Is(err, edk2.ErrNoDefaultKeys) {
+		t.Skip("built without the defaultkeys tag")
+	}
+	if err != nil {
+		t.Fatalf("EnrollDefaultKeys() error = %v", err)
+	}
+
+	want, err := edk2.DefaultKeys()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := SecureBootKeys(m)
+	if err != nil {
+		t.Fatalf("SecureBootKeys() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("SecureBootKeys() = %+v, want %+v", got, want)
+	}
+}
+
+func TestRotateSecureBootKeys(t *testing.T) {
+	pk := newTestCert(t, "PK", nil)
+	kek := newTestCert(t, "KEK", &pk)
+	db := newTestCert(t, "db", nil)
+
+	m := newFixtureManager(t)
+	// Setup mode accepts any keys.
+	enrolled := types.SecureBootKeySet{
+		PK:  []types.SecureBootKey{pk.sbKey()},
+		KEK: []types.SecureBootKey{kek.sbKey()},
+		DB:  []types.SecureBootKey{db.sbKey()},
+	}
+	if err := RotateSecureBootKeys(m, enrolled); err != nil {
+		t.Fatalf("RotateSecureBootKeys() error = %v", err)
+	}
+
+	newKEK := newTestCert(t, "new KEK", &pk)
+	rotated := types.SecureBootKeySet{KEK: []types.SecureBootKey{newKEK.sbKey()}}
+	if err := RotateSecureBootKeys(m, rotated); err != nil {
+		t.Fatalf("RotateSecureBootKeys(KEK signed by PK) error = %v", err)
+	}
+	enrolled.KEK = rotated.KEK
+
+	newPK := newTestCert(t, "new PK", &pk)
+	kekOfNewPK := newTestCert(t, "KEK of new PK", &newPK)
+	rotated = types.SecureBootKeySet{
+		PK:  []types.SecureBootKey{newPK.sbKey()},
+		KEK: []types.SecureBootKey{kekOfNewPK.sbKey()},
+	}
+	if err := RotateSecureBootKeys(m, rotated); err != nil {
+		t.Fatalf("RotateSecureBootKeys(PK signed by PK) error = %v", err)
+	}
+	enrolled.PK, enrolled.KEK = rotated.PK, rotated.KEK
+
+	for name, ks := range map[string]types.SecureBootKeySet{
+		"self-signed KEK": {KEK: []types.SecureBootKey{newTestCert(t, "rogue KEK", nil).sbKey()}},
+		"KEK of old PK":   {KEK: []types.SecureBootKey{newTestCert(t, "old KEK", &pk).sbKey()}},
+		"self-signed PK":  {PK: []types.SecureBootKey{newTestCert(t, "rogue PK", nil).sbKey()}},
+	} {
+		if err := RotateSecureBootKeys(m, ks); !errors.Is(err, ErrKeyNotSigned) {
+			t.Errorf("RotateSecureBootKeys(%s) error = %v, want %v", name, err, ErrKeyNotSigned)
+		}
+	}
+
+	// A dbx too large for the varstore fails after db has been written.
+	huge := make([]types.SecureBootKey, 8192)
+	for i := range huge {
+		hash := make([]byte, 32)
+		hash[0], hash[1] = byte(i), byte(i>>8)
+		huge[i] = types.SecureBootKey{Type: efi.EfiCertSha256, Owner: efi.Shim, Data: hash}
+	}
+	ks := types.SecureBootKeySet{
+		DB:  []types.SecureBootKey{newTestCert(t, "new db", nil).sbKey()},
+		DBX: huge,
+	}
+	if err := RotateSecureBootKeys(m, ks); err == nil {
+		t.Fatal("RotateSecureBootKeys() accepted a dbx larger than the varstore")
+	}
+	got, err := SecureBootKeys(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, enrolled) {
+		t.Errorf("SecureBootKeys() after failed rotation = %+v, want %+v", got, enrolled)
+	}
+	if _, err := m.GetVariable("dbx"); err == nil {
+		t.Error("failed rotation left a dbx variable")
+	}
+}
+
+func TestSecureBootReport(t *testing.T) {
+	m := newFixtureManager(t)
+	r, err := SecureBootReport(m)
+	if err != nil {
+		t.Fatalf("SecureBootReport() error = %v", err)
+	}
+	if !r.SetupMode || r.SecureBoot {
+		t.Errorf("SecureBootReport() without keys = %+v, want setup mode", r)
+	}
+
+	pk := newTestCert(t, "PK", nil)
+	ks := types.SecureBootKeySet{PK: []types.SecureBootKey{pk.sbKey()}}
+	if err := EnrollSecureBootKeys(m, ks); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.SetVariable(efi.VarKey("SecureBootEnable", efi.StringToGUID(efi.EfiSecureBootEnableDisable)), &efi.EfiVar{
+		Attr: efi.EFI_VARIABLE_NON_VOLATILE | efi.EFI_VARIABLE_BOOTSERVICE_ACCESS,
+		Data: []byte{1},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	r, err = SecureBootReport(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.SetupMode || !r.SecureBoot || len(r.Certificates) != 1 || r.Certificates[0].Subject != "CN=PK" {
+		t.Errorf("SecureBootReport() = %+v", r)
+	}
+}
@@ -0,0 +1,344 @@
+package manager
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"maps"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/metal3-community/uefi-firmware-manager/audit"
+	"github.com/metal3-community/uefi-firmware-manager/edk2"
+	"github.com/metal3-community/uefi-firmware-manager/efi"
+	"github.com/metal3-community/uefi-firmware-manager/smbios"
+	"github.com/metal3-community/uefi-firmware-manager/types"
+	"github.com/metal3-community/uefi-firmware-manager/varstore"
+)
+
+// FirmwareValidators are the HTTP cache validators of a firmware image.
+type FirmwareValidators struct {
+	// ETag is the strong entity tag of the image, with its quotes.
+	ETag string
+	// LastModified is when the image last changed.
+	LastModified time.Time
+}
+
+// servedImage is the last image served to a MAC address.
+type servedImage struct {
+	etag  string
+	since time.Time
+}
+
+// embeddedImageSum is the SHA-256 of edk2.RpiEfi.
+var embeddedImageSum = sync.OnceValue(func() [sha256.Size]byte {
+	return sha256.Sum256(edk2.RpiEfi)
+})
+
+// loadTime is when the process started, truncated to HTTP date precision.
+var loadTime = time.Now().UTC().Truncate(time.Second)
+
+// embeddedLastModified is the SMBIOS release date of edk2.RpiEfi or, if it
+// has none, loadTime: the embedded image cannot change while the process
+// runs.
+var embeddedLastModified = sync.OnceValue(func() time.Time {
+	return releaseDate(edk2.RpiEfi, loadTime)
+})
+
+// releaseDate returns the SMBIOS release date of image, or fallback if it
+// has none.
+func releaseDate(image []byte, fallback time.Time) time.Time {
+	info, err := smbios.FirmwareInfo(image)
+	if err != nil {
+		return fallback
+	}
+	t, err := time.Parse("01/02/2006", info.ReleaseDate)
+	if err != nil {
+		return fallback
+	}
+	return t
+}
+
+// baseSum returns the SHA-256 of the image of base.
+func baseSum(base *baseFirmware) [sha256.Size]byte {
+	if base != nil {
+		return base.sum
+	}
+	return embeddedImageSum()
+}
+
+// baseLastModified returns when the image of base last changed.
+func baseLastModified(base *baseFirmware) time.Time {
+	if base != nil {
+		return base.lastModified
+	}
+	return embeddedLastModified()
+}
+
+// BaseValidators returns the validators of the base firmware. Its ETag is
+// the hash of the image and its LastModified the SMBIOS release date, or
+// when the manager loaded the image if it has none.
+func (sm *SimpleFirmwareManager) BaseValidators() FirmwareValidators {
+	return baseValidators(sm.base.Load())
+}
+
+// baseValidators returns the validators of the image of base.
+func baseValidators(base *baseFirmware) FirmwareValidators {
+	sum := baseSum(base)
+	return FirmwareValidators{
+		ETag:         formatETag(sum[:]),
+		LastModified: baseLastModified(base),
+	}
+}
+
+// FirmwareValidators returns the validators of the firmware
+// GetFirmwareReader serves to macAddr, without serializing the image. Its
+// ETag is derived from the hash of the base image and of the patched
+// variables, and its LastModified is when this manager first served a
+// different image to macAddr, or that of the base firmware.
+func (sm *SimpleFirmwareManager) FirmwareValidators(macAddr net.HardwareAddr) (FirmwareValidators, error) {
+	base := sm.base.Load()
+	_, varList, err := sm.patchedVarList(base, macAddr)
+	if err != nil {
+		return FirmwareValidators{}, err
+	}
+	return sm.validators(base, macAddr, varList), nil
+}
+
+// validators returns the validators of the firmware of base with the
+// variables varList served to macAddr.
+func (sm *SimpleFirmwareManager) validators(base *baseFirmware, macAddr net.HardwareAddr, varList efi.EfiVarList) FirmwareValidators {
+	sum := baseSum(base)
+	h := sha256.New()
+	h.Write(sum[:])
+	hashVarList(h, varList)
+	etag := formatETag(h.Sum(nil))
+
+	sm.servedMu.Lock()
+	defer sm.servedMu.Unlock()
+	if sm.served == nil {
+		sm.served = map[string]servedImage{}
+	}
+	key := macAddr.String()
+	img, ok := sm.served[key]
+	if !ok {
+		img = servedImage{etag: etag, since: baseLastModified(base)}
+	} else if img.etag != etag {
+		img = servedImage{etag: etag, since: time.Now().UTC().Truncate(time.Second)}
+	}
+	sm.served[key] = img
+	return FirmwareValidators{ETag: etag, LastModified: img.since}
+}
+
+// hashVarList writes the variables of varList to h in key order.
+func hashVarList(h io.Writer, varList efi.EfiVarList) {
+	var buf [8]byte
+	for _, key := range slices.Sorted(maps.Keys(varList)) {
+		v := varList[key]
+		io.WriteString(h, key)
+		binary.LittleEndian.PutUint32(buf[:4], v.Attr)
+		binary.LittleEndian.PutUint32(buf[4:], uint32(len(v.Data)))
+		h.Write(buf[:])
+		h.Write(v.Data)
+		if v.Time != nil {
+			binary.LittleEndian.PutUint64(buf[:], uint64(v.Time.UnixNano()))
+			h.Write(buf[:])
+		}
+	}
+}
+
+// formatETag returns a strong entity tag for the hash sum.
+func formatETag(sum []byte) string {
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// ServeFirmware serves the firmware GetFirmwareReader returns for macAddr,
+// or the base firmware if macAddr is nil, with its ETag and Last-Modified
+// headers. A GET or HEAD whose If-None-Match or If-Modified-Since header
+// matches gets 304 Not Modified without the image being serialized. Range
+// requests get the requested parts of the image, as the Raspberry Pi 4
+// bootloader makes them. The limits set with SetServeLimits apply, and
+// every request is audited to the sink set with SetAuditSink.
+func (sm *SimpleFirmwareManager) ServeFirmware(w http.ResponseWriter, r *http.Request, macAddr net.HardwareAddr) {
+	if q := sm.auditQueue; q != nil && q.begin() {
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		defer recordServe(q, sw, r, macAddr)
+		w = sw
+	}
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	if !sm.limit(w, r) {
+		return
+	}
+
+	var (
+		base    = sm.base.Load()
+		v       FirmwareValidators
+		vs      *varstore.Edk2VarStore
+		varList efi.EfiVarList
+		err     error
+	)
+	if macAddr == nil {
+		v = baseValidators(base)
+	} else {
+		release, ok := sm.acquireGeneration(r)
+		if !ok {
+			http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+			return
+		}
+		defer release()
+		if vs, varList, err = sm.patchedVarList(base, macAddr); err != nil {
+			sm.logger.Error(err, "failed to patch firmware", "mac", macAddr.String())
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		v = sm.validators(base, macAddr, varList)
+		sm.markAccessed(macAddr)
+	}
+
+	header := w.Header()
+	header.Set("ETag", v.ETag)
+	header.Set("Last-Modified", v.LastModified.UTC().Format(http.TimeFormat))
+	if notModified(r, v) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	body := baseReadSeeker(base)
+	if varList != nil {
+		if body, err = readSeeker(vs, varList); err != nil {
+			sm.logger.Error(err, "failed to serialize firmware", "mac", macAddr.String())
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	// ServeContent handles Range and If-Range, answering multiple ranges
+	// with a multipart/byteranges body.
+	header.Set("Content-Type", "application/octet-stream")
+	http.ServeContent(w, r, "", v.LastModified, body)
+}
+
+// notModified reports whether the conditional headers of r match v. As in
+// RFC 9110, If-Modified-Since is ignored if If-None-Match is present.
+func notModified(r *http.Request, v FirmwareValidators) bool {
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		for _, tag := range strings.Split(inm, ",") {
+			tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+			if tag == "*" || tag == v.ETag {
+				return true
+			}
+		}
+		return false
+	}
+	since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil {
+		return false
+	}
+	return !v.LastModified.Truncate(time.Second).After(since)
+}
+
+// SetTokenValidator makes the admin requests of ServeBaseFirmware, and of
+// a Reloader serving the manager, require a token validator accepts with
+// RoleAdmin. Without a validator they are refused. Set it before serving
+// firmware.
+func (sm *SimpleFirmwareManager) SetTokenValidator(validator TokenValidator) {
+	sm.tokens = validator
+}
+
+// maxBaseFirmwareSize bounds the images ServeBaseFirmware accepts.
+const maxBaseFirmwareSize = 64 << 20
+
+// ServeBaseFirmware serves the base firmware on GET and HEAD, as
+// ServeFirmware does for a nil MAC address, and replaces it with the
+// request body on PUT, for an admin endpoint rolling out new builds. A PUT
+// requires RoleAdmin, see SetTokenValidator, and a valid signature if an
+// image verifier is set. It answers 204 No Content, 400 with the error if
+// the image is invalid, or 403 if its signature is. Every PUT is recorded
+// to the audit sink.
+func (sm *SimpleFirmwareManager) ServeBaseFirmware(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD, PUT")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		sm.ServeFirmware(w, r, nil)
+		return
+	}
+	RequireRole(sm.tokens, RoleAdmin, http.HandlerFunc(sm.putBaseFirmware)).ServeHTTP(w, r)
+}
+
+// putBaseFirmware replaces the base firmware with the body of r.
+func (sm *SimpleFirmwareManager) putBaseFirmware(w http.ResponseWriter, r *http.Request) {
+	before := baseSum(sm.base.Load())
+	e := audit.Event{Action: audit.ActionReplaceBase, Before: hex.EncodeToString(before[:])}
+	image, _, status, err := readBaseUpload(w, r, sm.imageKey)
+	if err == nil {
+		status = http.StatusNoContent
+		if err = sm.SetBaseFirmware(image); err != nil {
+			status = http.StatusBadRequest
+		} else {
+			e.After = audit.Hash(image)
+		}
+	}
+	e.Status = status
+	sm.recordAdmin(r, e, err)
+	if err != nil {
+		sm.logger.Error(err, "failed to replace base firmware")
+		http.Error(w, err.Error(), status)
+		return
+	}
+	w.WriteHeader(status)
+}
+
+// ImageSignatureHeader carries the base64 encoded detached signature, as
+// made by types.SignImage, of a base firmware uploaded to
+// ServeBaseFirmware.
+const ImageSignatureHeader = "X-Image-Signature"
+
+// SetImageVerifier makes ServeBaseFirmware accept only images whose
+// ImageSignatureHeader verifies with pub, so that only builds signed by
+// the release process can be rolled out. Set it before serving firmware.
+func (sm *SimpleFirmwareManager) SetImageVerifier(pub crypto.PublicKey) {
+	sm.imageKey = pub
+}
+
+// readBaseUpload reads the base firmware uploaded with r and its
+// signature, which must verify with pub if it is set. It returns the
+// status to answer with if they cannot be accepted.
+func readBaseUpload(w http.ResponseWriter, r *http.Request, pub crypto.PublicKey) ([]byte, []byte, int, error) {
+	image, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBaseFirmwareSize))
+	if err != nil {
+		return nil, nil, http.StatusBadRequest, fmt.Errorf("failed to read base firmware: %w", err)
+	}
+	header := r.Header.Get(ImageSignatureHeader)
+	if header == "" {
+		if pub != nil {
+			return nil, nil, http.StatusForbidden, fmt.Errorf("base firmware is not signed")
+		}
+		return image, nil, 0, nil
+	}
+	sig, err := base64.StdEncoding.DecodeString(header)
+	if err != nil {
+		return nil, nil, http.StatusBadRequest, fmt.Errorf("failed to decode image signature: %w", err)
+	}
+	if pub != nil {
+		if err := types.VerifyImage(bytes.NewReader(image), sig, pub); err != nil {
+			return nil, nil, http.StatusForbidden, err
+		}
+	}
+	return image, sig, 0, nil
+}
@@ -0,0 +1,332 @@
+package manager
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-logr/logr"
+	"github.com/metal3-community/uefi-firmware-manager/audit"
+	"github.com/metal3-community/uefi-firmware-manager/edk2"
+	"github.com/metal3-community/uefi-firmware-manager/efi"
+	"github.com/metal3-community/uefi-firmware-manager/types"
+)
+
+func serveFirmware(mgr *SimpleFirmwareManager, mac net.HardwareAddr, method string, header map[string]string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, "/RPI_EFI.fd", nil)
+	for k, v := range header {
+		r.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	mgr.ServeFirmware(w, r, mac)
+	return w
+}
+
+func TestSimpleFirmwareManager_ServeFirmware(t *testing.T) {
+	mgr, err := NewSimpleFirmwareManager(logr.Discard())
+	if err != nil {
+		t.Fatal(err)
+	}
+	mac, _ := net.ParseMAC("d8:3a:dd:61:4d:15")
+	role := "worker"
+	mgr.SetBootMetadata(func(net.HardwareAddr) (efi.BootMetadata, error) {
+		return efi.BootMetadata{Role: role}, nil
+	})
+
+	base := serveFirmware(mgr, nil, http.MethodGet, nil)
+	if base.Code != http.StatusOK || !bytes.Equal(base.Body.Bytes(), edk2.RpiEfi) {
+		t.Fatalf("base firmware = %d, %d bytes", base.Code, base.Body.Len())
+	}
+	baseTag := base.Header().Get("ETag")
+	if baseTag != mgr.BaseValidators().ETag || base.Header().Get("Last-Modified") == "" {
+		t.Errorf("base firmware headers = %v", base.Header())
+	}
+
+	patched := serveFirmware(mgr, mac, http.MethodGet, nil)
+	if patched.Code != http.StatusOK {
+		t.Fatalf("patched firmware status = %d", patched.Code)
+	}
+	reader, err := mgr.GetFirmwareReader(mac)
+	if err != nil {
+		t.Fatal(err)
+	}
+	image, _ := io.ReadAll(reader)
+	if !bytes.Equal(patched.Body.Bytes(), image) {
+		t.Error("served patched firmware differs from GetFirmwareReader")
+	}
+	if got := patched.Header().Get("Content-Length"); got != fmt.Sprint(len(image)) {
+		t.Errorf("Content-Length = %s for %d bytes", got, len(image))
+	}
+	etag := patched.Header().Get("ETag")
+	if etag == "" || etag == baseTag {
+		t.Errorf("patched ETag = %s, base ETag = %s", etag, baseTag)
+	}
+	if v, err := mgr.FirmwareValidators(mac); err != nil || v.ETag != etag {
+		t.Errorf("FirmwareValidators() = %+v, %v, want ETag %s", v, err, etag)
+	}
+	lastModified := patched.Header().Get("Last-Modified")
+
+	for _, tt := range []struct {
+		name   string
+		mac    net.HardwareAddr
+		method string
+		header map[string]string
+		want   int
+	}{
+		{"base match", nil, http.MethodGet, map[string]string{"If-None-Match": baseTag}, http.StatusNotModified},
+		{"match", mac, http.MethodGet, map[string]string{"If-None-Match": etag}, http.StatusNotModified},
+		{"match in list", mac, http.MethodHead, map[string]string{"If-None-Match": `"x", W/` + etag}, http.StatusNotModified},
+		{"any", mac, http.MethodGet, map[string]string{"If-None-Match": "*"}, http.StatusNotModified},
+		{"mismatch", mac, http.MethodGet, map[string]string{"If-None-Match": baseTag}, http.StatusOK},
+		{"mismatch overrides date", mac, http.MethodGet, map[string]string{
+			"If-None-Match":     baseTag,
+			"If-Modified-Since": lastModified,
+		}, http.StatusOK},
+		{"not modified since", mac, http.MethodGet, map[string]string{"If-Modified-Since": lastModified}, http.StatusNotModified},
+		{"modified since", mac, http.MethodGet, map[string]string{"If-Modified-Since": "Mon, 01 Jan 1990 00:00:00 GMT"}, http.StatusOK},
+		{"head", mac, http.MethodHead, nil, http.StatusOK},
+		{"post", mac, http.MethodPost, nil, http.StatusMethodNotAllowed},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serveFirmware(mgr, tt.mac, tt.method, tt.header)
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d", w.Code, tt.want)
+			}
+			if w.Code == http.StatusNotModified || tt.method == http.MethodHead {
+				if w.Body.Len() != 0 {
+					t.Errorf("body has %d bytes, want none", w.Body.Len())
+				}
+			}
+		})
+	}
+
+	role = "control-plane"
+	changed := serveFirmware(mgr, mac, http.MethodGet, map[string]string{"If-None-Match": etag})
+	if changed.Code != http.StatusOK || changed.Header().Get("ETag") == etag {
+		t.Errorf("after a metadata change status = %d, ETag = %s", changed.Code, changed.Header().Get("ETag"))
+	}
+}
+
+func TestSimpleFirmwareManager_ServeFirmware_Range(t *testing.T) {
+	mgr, err := NewSimpleFirmwareManager(logr.Discard())
+	if err != nil {
+		t.Fatal(err)
+	}
+	mac, _ := net.ParseMAC("d8:3a:dd:61:4d:15")
+	rs, err := mgr.GetFirmwareReadSeeker(mac)
+	if err != nil {
+		t.Fatalf("GetFirmwareReadSeeker() error = %v", err)
+	}
+	if size, err := rs.Seek(0, io.SeekEnd); err != nil || size != mgr.Size() {
+		t.Fatalf("Seek(0, io.SeekEnd) = %d, %v, want %d", size, err, mgr.Size())
+	}
+	if _, err := rs.Seek(0, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	image, _ := io.ReadAll(rs)
+	reader, err := mgr.GetFirmwareReader(mac)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, _ := io.ReadAll(reader); !bytes.Equal(image, want) {
+		t.Fatal("GetFirmwareReadSeeker() differs from GetFirmwareReader()")
+	}
+	size := len(image)
+	etag := serveFirmware(mgr, mac, http.MethodHead, nil).Header().Get("ETag")
+
+	for _, tt := range []struct {
+		name         string
+		header       map[string]string
+		want         int
+		contentRange string
+		body         []byte
+	}{
+		{"first bytes", map[string]string{"Range": "bytes=0-99"}, http.StatusPartialContent,
+			fmt.Sprintf("bytes 0-99/%d", size), image[:100]},
+		{"suffix", map[string]string{"Range": "bytes=-16"}, http.StatusPartialContent,
+			fmt.Sprintf("bytes %d-%d/%d", size-16, size-1, size), image[size-16:]},
+		{"open ended", map[string]string{"Range": fmt.Sprintf("bytes=%d-", size-4)}, http.StatusPartialContent,
+			fmt.Sprintf("bytes %d-%d/%d", size-4, size-1, size), image[size-4:]},
+		{"unsatisfiable", map[string]string{"Range": fmt.Sprintf("bytes=%d-", size)}, http.StatusRequestedRangeNotSatisfiable,
+			fmt.Sprintf("bytes */%d", size), nil},
+		{"if-range match", map[string]string{"Range": "bytes=0-9", "If-Range": etag}, http.StatusPartialContent,
+			fmt.Sprintf("bytes 0-9/%d", size), image[:10]},
+		{"if-range stale", map[string]string{"Range": "bytes=0-9", "If-Range": `"stale"`}, http.StatusOK,
+			"", image},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serveFirmware(mgr, mac, http.MethodGet, tt.header)
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d", w.Code, tt.want)
+			}
+			if got := w.Header().Get("Content-Range"); got != tt.contentRange {
+				t.Errorf("Content-Range = %q, want %q", got, tt.contentRange)
+			}
+			if tt.body != nil && !bytes.Equal(w.Body.Bytes(), tt.body) {
+				t.Errorf("body has %d bytes, want %d", w.Body.Len(), len(tt.body))
+			}
+		})
+	}
+
+	w := serveFirmware(mgr, mac, http.MethodGet, map[string]string{"Range": "bytes=0-9,4096-4111"})
+	if w.Code != http.StatusPartialContent {
+		t.Fatalf("multiple ranges status = %d", w.Code)
+	}
+	mediaType, params, err := mime.ParseMediaType(w.Header().Get("Content-Type"))
+	if err != nil || mediaType != "multipart/byteranges" {
+		t.Fatalf("multiple ranges Content-Type = %s", w.Header().Get("Content-Type"))
+	}
+	parts := multipart.NewReader(w.Body, params["boundary"])
+	for _, want := range []struct {
+		contentRange string
+		body         []byte
+	}{
+		{fmt.Sprintf("bytes 0-9/%d", size), image[:10]},
+		{fmt.Sprintf("bytes 4096-4111/%d", size), image[4096:4112]},
+	} {
+		part, err := parts.NextPart()
+		if err != nil {
+			t.Fatalf("NextPart() error = %v", err)
+		}
+		body, _ := io.ReadAll(part)
+		if part.Header.Get("Content-Range") != want.contentRange || !bytes.Equal(body, want.body) {
+			t.Errorf("part %s has %d bytes, want %s", part.Header.Get("Content-Range"), len(body), want.contentRange)
+		}
+	}
+	if _, err := parts.NextPart(); err != io.EOF {
+		t.Errorf("NextPart() after the last range error = %v, want io.EOF", err)
+	}
+}
+
+func TestSimpleFirmwareManager_SetBaseFirmware(t *testing.T) {
+	mgr, err := NewSimpleFirmwareManager(logr.Discard())
+	if err != nil {
+		t.Fatal(err)
+	}
+	mac, _ := net.ParseMAC("d8:3a:dd:61:4d:15")
+	before := serveFirmware(mgr, mac, http.MethodGet, nil)
+	if before.Code != http.StatusOK {
+		t.Fatalf("status = %d", before.Code)
+	}
+	etag := before.Header().Get("ETag")
+
+	// A build differing outside of the variable store.
+	image := bytes.Clone(edk2.RpiEfi)
+	image[len(image)-1] ^= 0xff
+
+	// Uploads are refused until a token validator is set.
+	w := httptest.NewRecorder()
+	mgr.ServeBaseFirmware(w, httptest.NewRequest(http.MethodPut, "/base", bytes.NewReader(image)))
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("PUT base without a validator = %d", w.Code)
+	}
+	mgr.SetTokenValidator(testTokens)
+
+	w = httptest.NewRecorder()
+	mgr.ServeBaseFirmware(w, adminRequest(http.MethodPut, "/base", bytes.NewReader(image)))
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("PUT base = %d %s", w.Code, w.Body)
+	}
+	after := serveFirmware(mgr, mac, http.MethodGet, map[string]string{"If-None-Match": etag})
+	if after.Code != http.StatusOK || after.Header().Get("ETag") == etag {
+		t.Fatalf("served after swap: status %d, ETag %s", after.Code, after.Header().Get("ETag"))
+	}
+	if got := after.Body.Bytes(); got[len(got)-1] != image[len(image)-1] {
+		t.Error("served firmware not built from the new base")
+	}
+
+	w = httptest.NewRecorder()
+	mgr.ServeBaseFirmware(w, httptest.NewRequest(http.MethodGet, "/base", nil))
+	if w.Code != http.StatusOK || !bytes.Equal(w.Body.Bytes(), image) {
+		t.Errorf("GET base = %d with %d bytes", w.Code, w.Body.Len())
+	}
+
+	// An invalid image keeps the current base.
+	w = httptest.NewRecorder()
+	mgr.ServeBaseFirmware(w, adminRequest(http.MethodPut, "/base", bytes.NewReader([]byte("not firmware"))))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("PUT invalid base = %d", w.Code)
+	}
+	if !bytes.Equal(baseImage(mgr.base.Load()), image) {
+		t.Error("invalid image replaced the base")
+	}
+
+	w = httptest.NewRecorder()
+	mgr.ServeBaseFirmware(w, httptest.NewRequest(http.MethodDelete, "/base", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("DELETE base = %d", w.Code)
+	}
+}
+
+func TestSimpleFirmwareManager_ServeBaseFirmwareSigned(t *testing.T) {
+	mgr, err := NewSimpleFirmwareManager(logr.Discard())
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, otherKey, _ := ed25519.GenerateKey(rand.Reader)
+	var events []audit.Event
+	mgr.SetTokenValidator(testTokens)
+	mgr.SetImageVerifier(pub)
+	mgr.SetAuditSink(audit.SinkFunc(func(e audit.Event) error {
+		events = append(events, e)
+		return nil
+	}))
+
+	image := bytes.Clone(edk2.RpiEfi)
+	image[len(image)-1] ^= 0xff
+	put := func(signer crypto.Signer) int {
+		r := adminRequest(http.MethodPut, "/base", bytes.NewReader(image))
+		if signer != nil {
+			sig, err := types.SignImage(bytes.NewReader(image), signer)
+			if err != nil {
+				t.Fatal(err)
+			}
+			r.Header.Set(ImageSignatureHeader, base64.StdEncoding.EncodeToString(sig))
+		}
+		w := httptest.NewRecorder()
+		mgr.ServeBaseFirmware(w, r)
+		return w.Code
+	}
+
+	if code := put(nil); code != http.StatusForbidden {
+		t.Errorf("unsigned PUT = %d", code)
+	}
+	if code := put(otherKey); code != http.StatusForbidden {
+		t.Errorf("PUT signed with another key = %d", code)
+	}
+	if !bytes.Equal(baseImage(mgr.base.Load()), edk2.RpiEfi) {
+		t.Fatal("rejected image replaced the base")
+	}
+	if code := put(key); code != http.StatusNoContent {
+		t.Fatalf("signed PUT = %d", code)
+	}
+
+	if len(events) != 3 {
+		t.Fatalf("recorded %d events, want 3", len(events))
+	}
+	for i, e := range events {
+		if e.Action != audit.ActionReplaceBase || e.Actor != "admin" || e.Before != audit.Hash(edk2.RpiEfi) {
+			t.Errorf("event %d = %+v", i, e)
+		}
+	}
+	if events[0].Error == "" || events[0].Status != http.StatusForbidden || events[0].After != "" {
+		t.Errorf("rejected upload event = %+v", events[0])
+	}
+	if last := events[2]; last.Error != "" || last.Status != http.StatusNoContent || last.After != audit.Hash(image) {
+		t.Errorf("upload event = %+v", last)
+	}
+}
@@ -1,24 +1,28 @@
 package manager
 
 import (
+	"bytes"
+	"crypto"
+	"crypto/sha256"
 	"fmt"
 	"io"
 	"maps"
 	"net"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 	"unsafe"
 
 	"github.com/go-logr/logr"
+	"github.com/metal3-community/uefi-firmware-manager/audit"
 	"github.com/metal3-community/uefi-firmware-manager/edk2"
 	"github.com/metal3-community/uefi-firmware-manager/efi"
+	"github.com/metal3-community/uefi-firmware-manager/types"
 	"github.com/metal3-community/uefi-firmware-manager/varstore"
 )
 
 var (
-	// Pre-decoded hex constant to avoid repeated parsing.
-	pxeOptData = efi.BmAutoCreateBootOptionGuid.Bytes()
-
 	// Pre-computed variable template for BootNext.
 	bootNextTemplate = &efi.EfiVar{
 		Name: efi.FromString("BootNext"),
@@ -50,67 +54,383 @@ var (
 	hexTable = "0123456789ABCDEF"
 )
 
+// PatchHook changes the variables of the firmware generated for mac, for
+// example to add site specific asset tags or certificates. An error aborts
+// the generation.
+type PatchHook func(mac net.HardwareAddr, varList efi.EfiVarList) error
+
+// BootMetadataFunc returns the metadata served to the host with the MAC
+// address mac. Zero metadata serves a plain PXE boot option.
+type BootMetadataFunc func(mac net.HardwareAddr) (efi.BootMetadata, error)
+
 // SimpleFirmwareManager provides a memory-efficient way to create firmware with PXE boot variables.
 type SimpleFirmwareManager struct {
-	logger logr.Logger
+	logger         logr.Logger
+	signer         crypto.Signer
+	provisioning   *types.Provisioning
+	prePatchHooks  []PatchHook
+	postPatchHooks []PatchHook
+	bootMetadata   BootMetadataFunc
+
+	// base is the firmware served instead of edk2.RpiEfi, if any. Each
+	// request works on the base it loaded first, so SetBaseFirmware can
+	// swap it while requests are served.
+	base atomic.Pointer[baseFirmware]
+
+	// served records when the image served to each MAC address last
+	// changed, for its Last-Modified time.
+	servedMu sync.Mutex
+	served   map[string]servedImage
+
+	// warm is set once Warm has filled the caches of the base firmware.
+	warm atomic.Bool
+
+	// limiter and generations enforce the ServeLimits of ServeFirmware.
+	limiter     *rateLimiter
+	generations chan struct{}
+
+	// auditSink, if set, records the firmware ServeFirmware serves,
+	// through auditQueue, and the admin requests of the server.
+	auditSink  audit.Sink
+	auditQueue *auditQueue
+
+	// tokens, if set, authorizes the admin requests of the server.
+	tokens TokenValidator
+	// dataDir, if set, is where ServeFirmware marks the hosts it serves
+	// accessed.
+	dataDir string
+
+	// imageKey, if set, verifies the base firmware uploaded to
+	// ServeBaseFirmware.
+	imageKey crypto.PublicKey
 }
 
 // NewSimpleFirmwareManager creates a new SimpleFirmwareManager with minimal memory footprint.
 func NewSimpleFirmwareManager(logger logr.Logger) (*SimpleFirmwareManager, error) {
+	if !edk2.Loaded() {
+		return nil, edk2.ErrNotLoaded
+	}
 	return &SimpleFirmwareManager{
 		logger: logger,
 	}, nil
 }
 
+// baseFirmware is a parsed base firmware image other than edk2.RpiEfi. A
+// nil *baseFirmware is the embedded firmware.
+type baseFirmware struct {
+	image        []byte
+	vs           *varstore.Edk2VarStore
+	varList      efi.EfiVarList
+	sum          [sha256.Size]byte
+	lastModified time.Time
+	version      string
+	versionErr   error
+}
+
+// NewSimpleFirmwareManagerWithBase creates a SimpleFirmwareManager serving
+// image instead of the embedded firmware, for instance the firmware of a
+// tenant. image must hold an EDK2 variable store.
+func NewSimpleFirmwareManagerWithBase(image []byte, logger logr.Logger) (*SimpleFirmwareManager, error) {
+	base, err := newBaseFirmware(image, logger)
+	if err != nil {
+		return nil, err
+	}
+	sm := &SimpleFirmwareManager{logger: logger}
+	sm.base.Store(base)
+	return sm, nil
+}
+
+// SetBaseFirmware makes the manager serve image instead of its current
+// base firmware, for instance to roll out a new EDK2 build without
+// restarting the server. image is parsed first and must hold an EDK2
+// variable store; if it does not, the current base is kept. Requests in
+// progress finish with the image they started with; the validators of
+// later responses change with the base, so clients download it again.
+func (sm *SimpleFirmwareManager) SetBaseFirmware(image []byte) error {
+	base, err := newBaseFirmware(image, sm.logger)
+	if err != nil {
+		return err
+	}
+	old := sm.base.Swap(base)
+	// Images served from the previous base no longer apply.
+	sm.servedMu.Lock()
+	sm.served = nil
+	sm.servedMu.Unlock()
+	sm.logger.Info("Replaced base firmware", "version", base.version,
+		"previous", baseValidators(old).ETag, "etag", baseValidators(base).ETag)
+	return nil
+}
+
+// newBaseFirmware parses image as a base firmware.
+func newBaseFirmware(image []byte, logger logr.Logger) (*baseFirmware, error) {
+	vs, err := varstore.New(image)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse base firmware: %w", err)
+	}
+	vs.Logger = logger
+	varList, err := vs.GetVarList()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read base firmware variables: %w", err)
+	}
+
+	base := &baseFirmware{
+		image:        image,
+		vs:           vs,
+		varList:      varList,
+		sum:          sha256.Sum256(image),
+		lastModified: releaseDate(image, time.Now().UTC().Truncate(time.Second)),
+	}
+	build, err := edk2.ScanVersion(image)
+	if err == nil && build.Version == "" {
+		err = edk2.ErrNoVersion
+	}
+	if err != nil {
+		base.versionErr = fmt.Errorf("failed to read base firmware version: %w", err)
+	} else {
+		base.version = build.Version
+	}
+	return base, nil
+}
+
+// baseImage returns the image of base.
+func baseImage(base *baseFirmware) []byte {
+	if base != nil {
+		return base.image
+	}
+	return edk2.RpiEfi
+}
+
 // GetFirmwareReader returns an io.Reader for firmware with PXE variables, optimized for throughput.
 func (sm *SimpleFirmwareManager) GetFirmwareReader(macAddr net.HardwareAddr) (io.Reader, error) {
+	vs, requestVarList, err := sm.patchedVarList(sm.base.Load(), macAddr)
+	if err != nil {
+		return nil, err
+	}
+
+	// Return streaming reader directly - no intermediate storage
+	return vs.ReadBytes(requestVarList)
+}
+
+// GetFirmwareReadSeeker is like GetFirmwareReader but returns an
+// io.ReadSeeker, for serving HTTP Range requests. The image is serialized
+// in memory once; seeking does not copy it.
+func (sm *SimpleFirmwareManager) GetFirmwareReadSeeker(macAddr net.HardwareAddr) (io.ReadSeeker, error) {
+	vs, requestVarList, err := sm.patchedVarList(sm.base.Load(), macAddr)
+	if err != nil {
+		return nil, err
+	}
+	return readSeeker(vs, requestVarList)
+}
+
+// readSeeker serializes the firmware of vs with the variables varList.
+func readSeeker(vs *varstore.Edk2VarStore, varList efi.EfiVarList) (io.ReadSeeker, error) {
+	image, err := vs.ReadAll(varList)
+	if err != nil {
+		return nil, err
+	}
+	return &optimizedFirmwareReader{
+		data: image,
+		size: int64(len(image)),
+	}, nil
+}
+
+// patchedVarList returns the varstore of base and the variables of the
+// firmware served to macAddr.
+func (sm *SimpleFirmwareManager) patchedVarList(base *baseFirmware, macAddr net.HardwareAddr) (*varstore.Edk2VarStore, efi.EfiVarList, error) {
 	// Use cached varstore to avoid repeated parsing
-	vs, varList, err := sm.getOrCreateVarstore()
+	vs, varList, err := sm.getOrCreateVarstore(base)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get varstore: %v", err)
+		return nil, nil, fmt.Errorf("failed to get varstore: %v", err)
 	}
 
-	// Clone the variable list for this request (shallow copy)
-	requestVarList := make(efi.EfiVarList, len(varList))
-	maps.Copy(requestVarList, varList)
+	// Clone the variable list for this request (shallow copy). Hooks may
+	// change variables in place, so they get a deep copy.
+	hooked := len(sm.prePatchHooks) > 0 || len(sm.postPatchHooks) > 0
+	var requestVarList efi.EfiVarList
+	if hooked {
+		requestVarList = cloneVarList(varList)
+	} else {
+		requestVarList = make(efi.EfiVarList, len(varList))
+		maps.Copy(requestVarList, varList)
+	}
 
-	bootOption, err := efi.NewPxeBootOption(macAddr)
+	if err := runPatchHooks(sm.prePatchHooks, macAddr, requestVarList); err != nil {
+		return nil, nil, err
+	}
+
+	var md efi.BootMetadata
+	if sm.bootMetadata != nil {
+		if md, err = sm.bootMetadata(macAddr); err != nil {
+			return nil, nil, fmt.Errorf("failed to get boot metadata: %w", err)
+		}
+	}
+	bootOption, err := efi.NewPxeBootOptionWithMetadata(macAddr, md)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create PXE boot option: %v", err)
+		return nil, nil, fmt.Errorf("failed to create PXE boot option: %v", err)
 	}
 
 	// Set variables using pre-computed templates
-	requestVarList["Boot0099"] = bootOption
-	requestVarList["BootNext"] = bootNextTemplate
+	requestVarList.Set(bootOption)
+	if hooked {
+		requestVarList.Set(cloneVar(bootNextTemplate))
+	} else {
+		requestVarList.Set(bootNextTemplate)
+	}
 
-	// Return streaming reader directly - no intermediate storage
-	return vs.ReadBytes(requestVarList)
+	if sm.provisioning != nil {
+		if profile, found := sm.provisioning.ProfileFor(macAddr); found {
+			if requestVarList, err = sm.applyServedProfile(base, requestVarList, profile); err != nil {
+				return nil, nil, fmt.Errorf("failed to apply profile %s: %w", profile.Name, err)
+			}
+		}
+	}
+
+	if err := runPatchHooks(sm.postPatchHooks, macAddr, requestVarList); err != nil {
+		return nil, nil, err
+	}
+
+	return vs, requestVarList, nil
+}
+
+// AddPrePatchHook registers a hook that GetFirmwareReader runs on the
+// variables of the base firmware, before it adds the PXE boot variables
+// and the provisioned profile, which take precedence. Register hooks
+// before serving firmware; they run in the order registered.
+func (sm *SimpleFirmwareManager) AddPrePatchHook(hook PatchHook) {
+	sm.prePatchHooks = append(sm.prePatchHooks, hook)
+}
+
+// AddPostPatchHook registers a hook that GetFirmwareReader runs on the
+// final variables, right before they are serialized. Register hooks before
+// serving firmware; they run in the order registered.
+func (sm *SimpleFirmwareManager) AddPostPatchHook(hook PatchHook) {
+	sm.postPatchHooks = append(sm.postPatchHooks, hook)
+}
+
+// runPatchHooks runs hooks on varList, stopping at the first error.
+func runPatchHooks(hooks []PatchHook, mac net.HardwareAddr, varList efi.EfiVarList) error {
+	for i, hook := range hooks {
+		if err := hook(mac, varList); err != nil {
+			return fmt.Errorf("patch hook %d failed: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// SetBootMetadata makes GetFirmwareReader carry the metadata fn returns
+// for each host in the optional data of its PXE boot option, where
+// efi.ParseBootMetadata decodes it. Set it before serving firmware.
+func (sm *SimpleFirmwareManager) SetBootMetadata(fn BootMetadataFunc) {
+	sm.bootMetadata = fn
+}
+
+// SetProvisioning makes GetFirmwareReader apply the profile p assigns to
+// each host on top of the PXE boot variables. A host whose profile expects
+// another firmware version than the base one is refused. Set it before
+// serving firmware.
+func (sm *SimpleFirmwareManager) SetProvisioning(p *types.Provisioning) {
+	sm.provisioning = p
+}
+
+// applyServedProfile returns a copy of varList with profile applied.
+func (sm *SimpleFirmwareManager) applyServedProfile(base *baseFirmware, varList efi.EfiVarList, profile types.Profile) (efi.EfiVarList, error) {
+	if profile.FirmwareVersion != "" {
+		version, err := firmwareVersion(base)
+		if err != nil {
+			return nil, err
+		}
+		if version != profile.FirmwareVersion {
+			return nil, fmt.Errorf("profile expects firmware %s, serving %s", profile.FirmwareVersion, version)
+		}
+	}
+	m := NewMemoryManager(varList)
+	if err := ApplyProfile(m, profile); err != nil {
+		return nil, err
+	}
+	return m.varList, nil
+}
+
+// embeddedFirmwareVersion returns the SMBIOS version of edk2.RpiEfi.
+var embeddedFirmwareVersion = sync.OnceValues(func() (string, error) {
+	build, err := edk2.ScanVersion(edk2.RpiEfi)
+	if err == nil && build.Version == "" {
+		err = edk2.ErrNoVersion
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read embedded firmware version: %w", err)
+	}
+	return build.Version, nil
+})
+
+// firmwareVersion returns the SMBIOS version of base.
+func firmwareVersion(base *baseFirmware) (string, error) {
+	if base != nil {
+		return base.version, base.versionErr
+	}
+	return embeddedFirmwareVersion()
+}
+
+// SetImageSigner sets the key GetSignedFirmwareReader signs images with.
+func (sm *SimpleFirmwareManager) SetImageSigner(signer crypto.Signer) {
+	sm.signer = signer
+}
+
+// GetSignedFirmwareReader is like GetFirmwareReader but also returns a
+// detached signature of the image made with types.SignImage. The image is
+// buffered in memory to sign it.
+func (sm *SimpleFirmwareManager) GetSignedFirmwareReader(macAddr net.HardwareAddr) (io.Reader, []byte, error) {
+	if sm.signer == nil {
+		return nil, nil, fmt.Errorf("no image signer set")
+	}
+
+	reader, err := sm.GetFirmwareReader(macAddr)
+	if err != nil {
+		return nil, nil, err
+	}
+	image, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read firmware: %w", err)
+	}
+
+	sig, err := types.SignImage(bytes.NewReader(image), sm.signer)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to sign firmware: %w", err)
+	}
+	return bytes.NewReader(image), sig, nil
 }
 
 // GetBaseReader returns a reader for the base firmware without modifications.
 func (sm *SimpleFirmwareManager) GetBaseReader() io.Reader {
 	// Return optimized reader with ReadSeeker interface
-	return &optimizedFirmwareReader{
-		data: edk2.RpiEfi,
-		size: int64(len(edk2.RpiEfi)),
-	}
+	return sm.GetBaseReadSeeker()
 }
 
 // GetBaseReadSeeker returns a ReadSeeker for the base firmware (useful for HTTP Range requests).
 func (sm *SimpleFirmwareManager) GetBaseReadSeeker() io.ReadSeeker {
+	return baseReadSeeker(sm.base.Load())
+}
+
+// baseReadSeeker returns a ReadSeeker for the image of base.
+func baseReadSeeker(base *baseFirmware) io.ReadSeeker {
+	image := baseImage(base)
 	return &optimizedFirmwareReader{
-		data: edk2.RpiEfi,
-		size: int64(len(edk2.RpiEfi)),
+		data: image,
+		size: int64(len(image)),
 	}
 }
 
 // Size returns the size of the base firmware data.
 func (sm *SimpleFirmwareManager) Size() int64 {
-	return int64(len(edk2.RpiEfi))
+	return int64(len(baseImage(sm.base.Load())))
 }
 
-// getOrCreateVarstore gets cached varstore or creates new one with caching.
-func (sm *SimpleFirmwareManager) getOrCreateVarstore() (*varstore.Edk2VarStore, efi.EfiVarList, error) {
+// getOrCreateVarstore gets the varstore of base, for the embedded firmware
+// from the cache or creating it.
+func (sm *SimpleFirmwareManager) getOrCreateVarstore(base *baseFirmware) (*varstore.Edk2VarStore, efi.EfiVarList, error) {
+	if base != nil {
+		return base.vs, base.varList, nil
+	}
+
 	// Try to get from cache first (read lock)
 	varstoreCache.RLock()
 	if varstoreCache.vs != nil && varstoreCache.varList != nil {
@@ -1,13 +1,22 @@
 package manager
 
 import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"errors"
 	"fmt"
 	"io"
 	"net"
+	"slices"
 	"strings"
 	"testing"
 
 	"github.com/go-logr/logr"
+	"github.com/metal3-community/uefi-firmware-manager/efi"
+	"github.com/metal3-community/uefi-firmware-manager/types"
+	"github.com/metal3-community/uefi-firmware-manager/varstore"
 )
 
 func TestSimpleFirmwareManager_MemoryOptimization(t *testing.T) {
@@ -28,6 +37,34 @@ func TestSimpleFirmwareManager_MemoryOptimization(t *testing.T) {
 	t.Logf("Manager created successfully with minimal footprint")
 }
 
+func TestSimpleFirmwareManager_GetSignedFirmwareReader(t *testing.T) {
+	mgr, err := NewSimpleFirmwareManager(logr.Discard())
+	if err != nil {
+		t.Fatalf("Failed to create manager: %v", err)
+	}
+	macAddr, err := net.ParseMAC("d8:3a:dd:61:4d:15")
+	if err != nil {
+		t.Fatalf("Failed to parse MAC: %v", err)
+	}
+
+	if _, _, err := mgr.GetSignedFirmwareReader(macAddr); err == nil {
+		t.Fatal("GetSignedFirmwareReader() succeeded without a signer")
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mgr.SetImageSigner(key)
+	reader, sig, err := mgr.GetSignedFirmwareReader(macAddr)
+	if err != nil {
+		t.Fatalf("GetSignedFirmwareReader() error = %v", err)
+	}
+	if err := types.VerifyImage(reader, sig, key.Public()); err != nil {
+		t.Errorf("VerifyImage() error = %v", err)
+	}
+}
+
 func TestSimpleFirmwareManager_GetFirmwareReader(t *testing.T) {
 	logger := logr.Discard()
 	mgr, err := NewSimpleFirmwareManager(logger)
@@ -174,13 +211,13 @@ func TestVarstoreCache(t *testing.T) {
 	}
 
 	// First call should populate cache
-	vs1, varList1, err := manager.getOrCreateVarstore()
+	vs1, varList1, err := manager.getOrCreateVarstore(nil)
 	if err != nil {
 		t.Fatalf("Failed to get varstore: %v", err)
 	}
 
 	// Second call should use cached values
-	vs2, varList2, err := manager.getOrCreateVarstore()
+	vs2, varList2, err := manager.getOrCreateVarstore(nil)
 	if err != nil {
 		t.Fatalf("Failed to get cached varstore: %v", err)
 	}
@@ -419,11 +456,11 @@ func BenchmarkVarstoreCache(b *testing.B) {
 	}
 
 	// Prime the cache
-	_, _, _ = manager.getOrCreateVarstore()
+	_, _, _ = manager.getOrCreateVarstore(nil)
 
 	b.ResetTimer()
 	for range b.N {
-		_, _, err := manager.getOrCreateVarstore()
+		_, _, err := manager.getOrCreateVarstore(nil)
 		if err != nil {
 			b.Fatalf("Failed to get varstore: %v", err)
 		}
@@ -481,3 +518,119 @@ func mustParseMac(s string) net.HardwareAddr {
 	}
 	return mac
 }
+
+func TestSimpleFirmwareManager_PatchHooks(t *testing.T) {
+	mgr, err := NewSimpleFirmwareManager(logr.Discard())
+	if err != nil {
+		t.Fatal(err)
+	}
+	mac, _ := net.ParseMAC("d8:3a:dd:61:4d:15")
+
+	var calls []string
+	mgr.AddPrePatchHook(func(got net.HardwareAddr, varList efi.EfiVarList) error {
+		calls = append(calls, "pre")
+		if _, found := varList.Lookup("Boot0099"); found {
+			t.Error("pre-patch hook ran after the PXE boot option was added")
+		}
+		return varList.SetUint32("CpuClock", 2)
+	})
+	mgr.AddPostPatchHook(func(got net.HardwareAddr, varList efi.EfiVarList) error {
+		calls = append(calls, "post")
+		if got.String() != mac.String() {
+			t.Errorf("hook got MAC %s, want %s", got, mac)
+		}
+		if _, found := varList.Lookup("Boot0099"); !found {
+			t.Error("post-patch hook ran before the PXE boot option was added")
+		}
+		// Changing variables in place must not leak into other requests.
+		next, _ := varList.Lookup(efi.BootNext)
+		next.Data[0] = 0x01
+		return nil
+	})
+
+	reader, err := mgr.GetFirmwareReader(mac)
+	if err != nil {
+		t.Fatalf("GetFirmwareReader() error = %v", err)
+	}
+	image, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(calls, []string{"pre", "post"}) {
+		t.Errorf("hooks ran as %v, want pre, post", calls)
+	}
+	if bootNextTemplate.Data[0] != 0x99 {
+		t.Error("hook changed the shared BootNext template")
+	}
+	vs, err := varstore.New(image)
+	if err != nil {
+		t.Fatal(err)
+	}
+	varList, err := vs.GetVarList()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, found := varList.Lookup("CpuClock"); !found {
+		t.Error("served firmware lacks the variable set by the hook")
+	}
+
+	mgr.AddPostPatchHook(func(net.HardwareAddr, efi.EfiVarList) error { return errors.New("no asset tag") })
+	if _, err := mgr.GetFirmwareReader(mac); err == nil || !strings.Contains(err.Error(), "no asset tag") {
+		t.Errorf("GetFirmwareReader() error = %v, want the hook error", err)
+	}
+}
+
+func TestSimpleFirmwareManager_SetBootMetadata(t *testing.T) {
+	mgr, err := NewSimpleFirmwareManager(logr.Discard())
+	if err != nil {
+		t.Fatal(err)
+	}
+	worker, _ := net.ParseMAC("d8:3a:dd:61:4d:15")
+	plain, _ := net.ParseMAC("d8:3a:dd:61:4d:16")
+	want := efi.BootMetadata{ImageURL: "http://images/worker.img", Token: "s3cret", Role: "worker"}
+	mgr.SetBootMetadata(func(mac net.HardwareAddr) (efi.BootMetadata, error) {
+		if mac.String() == worker.String() {
+			return want, nil
+		}
+		return efi.BootMetadata{}, nil
+	})
+
+	optData := func(mac net.HardwareAddr) []byte {
+		reader, err := mgr.GetFirmwareReader(mac)
+		if err != nil {
+			t.Fatalf("GetFirmwareReader() error = %v", err)
+		}
+		image, err := io.ReadAll(reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		vs, err := varstore.New(image)
+		if err != nil {
+			t.Fatal(err)
+		}
+		varList, err := vs.GetVarList()
+		if err != nil {
+			t.Fatal(err)
+		}
+		entry, err := varList.GetBootEntry(0x99)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return entry.OptData
+	}
+
+	got, err := efi.ParseBootMetadata(optData(worker))
+	if err != nil || got == nil || *got != want {
+		t.Errorf("served metadata = %+v, %v, want %+v", got, err, want)
+	}
+	if data := optData(plain); !bytes.Equal(data, efi.BmAutoCreateBootOptionGuid.Bytes()) {
+		t.Errorf("plain boot option optional data = %x", data)
+	}
+
+	mgr.SetBootMetadata(func(net.HardwareAddr) (efi.BootMetadata, error) {
+		return efi.BootMetadata{}, errors.New("unknown host")
+	})
+	if _, err := mgr.GetFirmwareReader(worker); err == nil || !strings.Contains(err.Error(), "unknown host") {
+		t.Errorf("GetFirmwareReader() error = %v, want the metadata error", err)
+	}
+}
@@ -0,0 +1,65 @@
+package manager
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/metal3-community/uefi-firmware-manager/atomicfile"
+)
+
+// Storage holds the MAC directories of a JsonEDK2Manager. Names are slash
+// separated and relative to the data directory, e.g.
+// "d8-3a-dd-5a-44-36/fw-vars.json".
+type Storage interface {
+	// ReadFile returns the contents of the named file. A missing file is
+	// reported with an error matching fs.ErrNotExist.
+	ReadFile(name string) ([]byte, error)
+	// WriteFile creates or replaces the named file.
+	WriteFile(name string, data []byte) error
+	// List returns the names of the files in the MAC directories.
+	List() ([]string, error)
+}
+
+// DirStorage is a Storage in a local data directory.
+type DirStorage string
+
+// ReadFile returns the contents of the named file.
+func (d DirStorage) ReadFile(name string) ([]byte, error) {
+	return os.ReadFile(filepath.Join(string(d), filepath.FromSlash(name)))
+}
+
+// WriteFile creates or atomically replaces the named file, and creates its
+// directory.
+func (d DirStorage) WriteFile(name string, data []byte) error {
+	p := filepath.Join(string(d), filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return err
+	}
+	return atomicfile.WriteFile(p, data, 0o644)
+}
+
+// List returns the names of the files in the subdirectories of the data
+// directory.
+func (d DirStorage) List() ([]string, error) {
+	dirs, err := os.ReadDir(string(d))
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, dir := range dirs {
+		if !dir.IsDir() {
+			continue
+		}
+		files, err := os.ReadDir(filepath.Join(string(d), dir.Name()))
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			if !file.IsDir() {
+				names = append(names, path.Join(dir.Name(), file.Name()))
+			}
+		}
+	}
+	return names, nil
+}
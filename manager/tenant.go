@@ -0,0 +1,201 @@
+package manager
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/go-logr/logr"
+	"github.com/metal3-community/uefi-firmware-manager/edk2"
+	"github.com/metal3-community/uefi-firmware-manager/efi"
+)
+
+// tenantDefaultsFileName holds the default variables of a tenant.
+const tenantDefaultsFileName = "defaults.json"
+
+// tenantNamePattern matches tenant names: DNS labels, so that they are
+// safe as directory names and in URLs.
+var tenantNamePattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
+
+// Tenants hosts the isolated data directories of several clusters in one
+// data directory, as dataDir/<tenant>/<mac>/fw-vars.json. A tenant
+// directory may also hold:
+//
+//   - RPI_EFI.fd, the base firmware served to its hosts instead of the
+//     embedded one.
+//   - defaults.json, variables in the format of fw-vars.json that are set
+//     on the served base firmware and that ResetToDefaults restores.
+//
+// Tenants is safe for concurrent use.
+type Tenants struct {
+	dataDir string
+	logger  logr.Logger
+
+	mu      sync.Mutex
+	servers map[string]*SimpleFirmwareManager
+}
+
+// NewTenants returns the tenants of dataDir.
+func NewTenants(dataDir string, logger logr.Logger) (*Tenants, error) {
+	if _, err := os.Stat(dataDir); os.IsNotExist(err) {
+		return nil, fmt.Errorf("data directory does not exist: %s", dataDir)
+	}
+	return &Tenants{
+		dataDir: dataDir,
+		logger:  logger,
+		servers: map[string]*SimpleFirmwareManager{},
+	}, nil
+}
+
+// ValidateTenantName returns an error if name is not a valid tenant name:
+// a lowercase DNS label that is not a MAC address directory name.
+func ValidateTenantName(name string) error {
+	if !tenantNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid tenant name %q: want a lowercase DNS label", name)
+	}
+	if _, err := net.ParseMAC(strings.ReplaceAll(name, "-", ":")); err == nil {
+		return fmt.Errorf("invalid tenant name %q: it is a MAC address", name)
+	}
+	return nil
+}
+
+// List returns the names of the tenants, sorted.
+func (t *Tenants) List() ([]string, error) {
+	entries, err := os.ReadDir(t.dataDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read data directory: %w", err)
+	}
+	var names []string
+	for _, entry := range entries {
+		if entry.IsDir() && ValidateTenantName(entry.Name()) == nil {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// Create creates the directory of the tenant name if it does not exist.
+func (t *Tenants) Create(name string) error {
+	if err := ValidateTenantName(name); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(t.dir(name), 0o755); err != nil {
+		return fmt.Errorf("failed to create tenant %s: %w", name, err)
+	}
+	return nil
+}
+
+// dir returns the directory of the tenant name.
+func (t *Tenants) dir(name string) string {
+	return filepath.Join(t.dataDir, name)
+}
+
+// open returns the directory of the existing tenant name.
+func (t *Tenants) open(name string) (string, error) {
+	if err := ValidateTenantName(name); err != nil {
+		return "", err
+	}
+	dir := t.dir(name)
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		return "", fmt.Errorf("tenant %s: %w", name, fs.ErrNotExist)
+	}
+	return dir, nil
+}
+
+// Defaults returns the default variables of the tenant name, or nil if it
+// has none.
+func (t *Tenants) Defaults(name string) (efi.EfiVarList, error) {
+	dir, err := t.open(name)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(filepath.Join(dir, tenantDefaultsFileName))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read defaults of tenant %s: %w", name, err)
+	}
+	var defaults efi.EfiVarList
+	if err := json.Unmarshal(data, &defaults); err != nil {
+		return nil, fmt.Errorf("failed to parse defaults of tenant %s: %w", name, err)
+	}
+	return defaults, nil
+}
+
+// Manager returns a JsonEDK2Manager for the MAC directories of the tenant
+// name, with the tenant defaults.
+func (t *Tenants) Manager(name string) (*JsonEDK2Manager, error) {
+	dir, err := t.open(name)
+	if err != nil {
+		return nil, err
+	}
+	defaults, err := t.Defaults(name)
+	if err != nil {
+		return nil, err
+	}
+	j, err := NewJsonEDK2Manager(dir, t.logger.WithValues("tenant", name))
+	if err != nil {
+		return nil, err
+	}
+	j.Defaults = defaults
+	return j, nil
+}
+
+// Server returns the SimpleFirmwareManager serving the hosts of the tenant
+// name: the tenant base firmware, or the embedded one, with the tenant
+// defaults set. Servers are created on first use and shared; configure
+// them before serving firmware.
+func (t *Tenants) Server(name string) (*SimpleFirmwareManager, error) {
+	dir, err := t.open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if sm, ok := t.servers[name]; ok {
+		return sm, nil
+	}
+
+	logger := t.logger.WithValues("tenant", name)
+	var sm *SimpleFirmwareManager
+	image, err := os.ReadFile(filepath.Join(dir, edk2.FirmwareFileName))
+	switch {
+	case err == nil:
+		if sm, err = NewSimpleFirmwareManagerWithBase(image, logger); err != nil {
+			return nil, fmt.Errorf("tenant %s: %w", name, err)
+		}
+	case errors.Is(err, fs.ErrNotExist):
+		if sm, err = NewSimpleFirmwareManager(logger); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("failed to read base firmware of tenant %s: %w", name, err)
+	}
+
+	defaults, err := t.Defaults(name)
+	if err != nil {
+		return nil, err
+	}
+	if len(defaults) > 0 {
+		sm.AddPrePatchHook(func(_ net.HardwareAddr, varList efi.EfiVarList) error {
+			for _, v := range defaults {
+				varList.Set(cloneVar(v))
+			}
+			return nil
+		})
+	}
+
+	t.servers[name] = sm
+	return sm, nil
+}
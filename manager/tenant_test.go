@@ -0,0 +1,167 @@
+package manager
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/go-logr/logr"
+	"github.com/metal3-community/uefi-firmware-manager/edk2"
+	"github.com/metal3-community/uefi-firmware-manager/efi"
+	"github.com/metal3-community/uefi-firmware-manager/testutil"
+	"github.com/metal3-community/uefi-firmware-manager/varstore"
+)
+
+func TestValidateTenantName(t *testing.T) {
+	for _, name := range []string{"lab", "cluster-1", "a"} {
+		if err := ValidateTenantName(name); err != nil {
+			t.Errorf("ValidateTenantName(%q) error = %v", name, err)
+		}
+	}
+	for _, name := range []string{"", "Lab", "-lab", "lab-", "a/b", "..", "d8-3a-dd-5a-44-0c"} {
+		if err := ValidateTenantName(name); err == nil {
+			t.Errorf("ValidateTenantName(%q) succeeded", name)
+		}
+	}
+}
+
+func TestTenants(t *testing.T) {
+	dataDir := t.TempDir()
+	write := func(name string, data []byte) {
+		t.Helper()
+		path := filepath.Join(dataDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	marshal := func(varList efi.EfiVarList) []byte {
+		t.Helper()
+		data, err := json.Marshal(varList)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return data
+	}
+
+	vars := testutil.VarList(t, "UEFI Shell")
+	defaults := testutil.VarList(t, "UEFI Shell")
+	timeout, _ := defaults.Lookup("Timeout")
+	timeout.Data = []byte{3, 0}
+	base, err := testutil.Firmware(testutil.VarList(t, "SD/MMC"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	write("lab/d8-3a-dd-5a-44-0c/fw-vars.json", marshal(vars))
+	write("lab/defaults.json", marshal(defaults))
+	write("prod/d8-3a-dd-5a-44-0d/fw-vars.json", marshal(vars))
+	write("prod/"+edk2.FirmwareFileName, base)
+	write("d8-3a-dd-5a-44-0e/fw-vars.json", marshal(vars))
+
+	tenants, err := NewTenants(dataDir, logr.Discard())
+	if err != nil {
+		t.Fatalf("NewTenants() error = %v", err)
+	}
+	if err := tenants.Create("staging"); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if err := tenants.Create("../x"); err == nil {
+		t.Error("Create() with an invalid name succeeded")
+	}
+	names, err := tenants.List()
+	if err != nil || !slices.Equal(names, []string{"lab", "prod", "staging"}) {
+		t.Errorf("List() = %v, %v", names, err)
+	}
+
+	lab, err := tenants.Manager("lab")
+	if err != nil {
+		t.Fatalf("Manager() error = %v", err)
+	}
+	macs, err := lab.ListAvailableMACs()
+	if err != nil || len(macs) != 1 || macs[0].String() != "d8:3a:dd:5a:44:0c" {
+		t.Errorf("ListAvailableMACs() = %v, %v", macs, err)
+	}
+	if err := lab.LoadMAC(macs[0]); err != nil {
+		t.Fatal(err)
+	}
+	if err := lab.ResetToDefaults(); err != nil {
+		t.Fatalf("ResetToDefaults() error = %v", err)
+	}
+	if seconds, err := lab.GetFirmwareTimeoutSeconds(); err != nil || seconds != 3 {
+		t.Errorf("timeout after ResetToDefaults() = %d, %v, want 3", seconds, err)
+	}
+	if prod, err := tenants.Manager("prod"); err != nil || prod.Defaults != nil {
+		t.Errorf("Manager(prod) = %v, %v, want no defaults", prod, err)
+	} else if err := prod.LoadMAC(macs[0]); err == nil {
+		t.Error("prod loaded a MAC of lab")
+	}
+	if _, err := tenants.Manager("unknown"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Manager(unknown) error = %v, want fs.ErrNotExist", err)
+	}
+
+	mac, _ := net.ParseMAC("d8:3a:dd:5a:44:0d")
+	served := func(sm *SimpleFirmwareManager) efi.EfiVarList {
+		t.Helper()
+		reader, err := sm.GetFirmwareReader(mac)
+		if err != nil {
+			t.Fatalf("GetFirmwareReader() error = %v", err)
+		}
+		image, _ := io.ReadAll(reader)
+		vs, err := varstore.New(image)
+		if err != nil {
+			t.Fatal(err)
+		}
+		varList, err := vs.GetVarList()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return varList
+	}
+
+	prodServer, err := tenants.Server("prod")
+	if err != nil {
+		t.Fatalf("Server(prod) error = %v", err)
+	}
+	if again, _ := tenants.Server("prod"); again != prodServer {
+		t.Error("Server() did not reuse the server of the tenant")
+	}
+	if image, _ := io.ReadAll(prodServer.GetBaseReader()); !bytes.Equal(image, base) || prodServer.Size() != int64(len(base)) {
+		t.Error("prod does not serve its base firmware")
+	}
+	if prodServer.BaseValidators().ETag == (&SimpleFirmwareManager{}).BaseValidators().ETag {
+		t.Error("prod base firmware has the ETag of the embedded firmware")
+	}
+	prodVars := served(prodServer)
+	if entry, err := prodVars.GetBootEntry(0); err != nil || entry.Title.String() != "SD/MMC" {
+		t.Errorf("prod Boot0000 = %v, %v, want its base firmware entry", entry, err)
+	}
+	if _, ok := prodVars.Lookup("Boot0099"); !ok {
+		t.Error("prod firmware has no PXE boot option")
+	}
+
+	labServer, err := tenants.Server("lab")
+	if err != nil {
+		t.Fatalf("Server(lab) error = %v", err)
+	}
+	if labServer.Size() != int64(len(edk2.RpiEfi)) {
+		t.Error("lab does not serve the embedded firmware")
+	}
+	if v, ok := served(labServer).Lookup("Timeout"); !ok || !bytes.Equal(v.Data, []byte{3, 0}) {
+		t.Errorf("lab served Timeout = %v, want its default", v)
+	}
+
+	write("broken/"+edk2.FirmwareFileName, []byte("not firmware"))
+	if _, err := tenants.Server("broken"); err == nil {
+		t.Error("Server() with an invalid base firmware succeeded")
+	}
+}
@@ -0,0 +1,53 @@
+package manager
+
+import (
+	"fmt"
+
+	"github.com/metal3-community/uefi-firmware-manager/efi"
+)
+
+// tcg2PPAttr are the attributes of the TCG2 physical presence variables.
+const tcg2PPAttr = efi.EFI_VARIABLE_NON_VOLATILE | efi.EFI_VARIABLE_BOOTSERVICE_ACCESS
+
+// QueueTPMRequest queues a TCG2 physical presence operation (efi.Tcg2PP*)
+// that the firmware runs on the next boot, asking for confirmation if the
+// management flags require it. param is only used by operations that take
+// one, such as efi.Tcg2PPSetPCRBanks. The result of the previous request
+// is kept. Changes are not saved.
+func QueueTPMRequest(m FirmwareManager, op uint8, param uint32) error {
+	pp, err := TPMPhysicalPresence(m)
+	if err != nil {
+		return err
+	}
+	pp.PPRequest = op
+	pp.PPRequestParameter = param
+
+	key := efi.VarKey(efi.Tcg2PhysicalPresenceVar, efi.StringToGUID(efi.EfiTcg2PhysicalPresence))
+	if err := m.SetVariable(key, &efi.EfiVar{Attr: tcg2PPAttr, Data: pp.Bytes()}); err != nil {
+		return fmt.Errorf("failed to set %s: %w", efi.Tcg2PhysicalPresenceVar, err)
+	}
+	return nil
+}
+
+// TPMPhysicalPresence returns the pending TCG2 physical presence request
+// and the result of the last one. A firmware without the variable has no
+// request pending.
+func TPMPhysicalPresence(m FirmwareManager) (efi.Tcg2PhysicalPresence, error) {
+	key := efi.VarKey(efi.Tcg2PhysicalPresenceVar, efi.StringToGUID(efi.EfiTcg2PhysicalPresence))
+	vars, err := m.ListVariables()
+	if err != nil {
+		return efi.Tcg2PhysicalPresence{}, err
+	}
+	v, found := vars[key]
+	if !found {
+		v, found = vars[efi.Tcg2PhysicalPresenceVar]
+	}
+	if !found || v.Key() != key {
+		return efi.Tcg2PhysicalPresence{}, nil
+	}
+	pp, err := efi.NewTcg2PhysicalPresence(v.Data)
+	if err != nil {
+		return efi.Tcg2PhysicalPresence{}, err
+	}
+	return *pp, nil
+}
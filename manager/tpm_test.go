@@ -0,0 +1,56 @@
+package manager
+
+import (
+	"testing"
+
+	"github.com/metal3-community/uefi-firmware-manager/efi"
+)
+
+func TestQueueTPMRequest(t *testing.T) {
+	m := newFixtureManager(t)
+
+	pp, err := TPMPhysicalPresence(m)
+	if err != nil {
+		t.Fatalf("TPMPhysicalPresence() error = %v", err)
+	}
+	if pp != (efi.Tcg2PhysicalPresence{}) {
+		t.Errorf("TPMPhysicalPresence() = %+v, want no request", pp)
+	}
+
+	if err := QueueTPMRequest(m, efi.Tcg2PPClear, 0); err != nil {
+		t.Fatalf("QueueTPMRequest() error = %v", err)
+	}
+	pp, err = TPMPhysicalPresence(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pp.PPRequest != efi.Tcg2PPClear {
+		t.Errorf("PPRequest = %d, want %d", pp.PPRequest, efi.Tcg2PPClear)
+	}
+
+	// The firmware records the result after running the request.
+	v, err := m.GetVariable(efi.Tcg2PhysicalPresenceVar)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.Attr != tcg2PPAttr || v.Guid.String() != efi.EfiTcg2PhysicalPresence {
+		t.Errorf("attr = %#x, guid = %s", v.Attr, v.Guid)
+	}
+	v.Data = (&efi.Tcg2PhysicalPresence{LastPPRequest: efi.Tcg2PPClear}).Bytes()
+
+	if err := QueueTPMRequest(m, efi.Tcg2PPSetPCRBanks, 0x3); err != nil {
+		t.Fatal(err)
+	}
+	typed, err := m.GetVariableAsType(efi.Tcg2PhysicalPresenceVar)
+	if err != nil {
+		t.Fatalf("GetVariableAsType() error = %v", err)
+	}
+	want := efi.Tcg2PhysicalPresence{
+		PPRequest:          efi.Tcg2PPSetPCRBanks,
+		PPRequestParameter: 0x3,
+		LastPPRequest:      efi.Tcg2PPClear,
+	}
+	if got, ok := typed.(*efi.Tcg2PhysicalPresence); !ok || *got != want {
+		t.Errorf("GetVariableAsType() = %+v, want %+v", typed, want)
+	}
+}
@@ -0,0 +1,284 @@
+// Package sdimage builds bootable SD card images for the Raspberry Pi 4
+// UEFI firmware, for hosts that cannot boot from the network.
+package sdimage
+
+import (
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"net"
+	"path"
+	"sort"
+	"unicode/utf16"
+
+	"github.com/metal3-community/uefi-firmware-manager/edk2"
+	"github.com/metal3-community/uefi-firmware-manager/efi"
+	"github.com/metal3-community/uefi-firmware-manager/esp"
+	"github.com/metal3-community/uefi-firmware-manager/fat"
+)
+
+// PartitionTable is the partitioning scheme of an image.
+type PartitionTable int
+
+const (
+	// MBR partitions the image with a DOS partition table, which every
+	// Raspberry Pi bootloader reads.
+	MBR PartitionTable = iota
+	// GPT partitions the image with a GUID partition table and marks the
+	// boot partition as an EFI system partition.
+	GPT
+)
+
+const (
+	// DefaultSize is the image size used when Options.Size is 0.
+	DefaultSize = 64 << 20
+	// DefaultLabel is the volume label used when Options.Label is empty.
+	DefaultLabel = "RPI-EFI"
+
+	// partitionStart is the offset of the boot partition, aligned for
+	// the erase blocks of SD cards.
+	partitionStart = 1 << 20
+
+	gptEntries   = 128
+	gptEntrySize = 128
+	// gptSectors is the size of the header and entry array of a GPT.
+	gptSectors = 1 + gptEntries*gptEntrySize/fat.SectorSize
+
+	mbrTypeFAT32    = 0x0b
+	mbrTypeFAT32LBA = 0x0c
+	mbrTypeEFI      = 0xef
+	mbrTypeGPT      = 0xee
+
+	basicDataTypeGUID = "ebd0a0a2-b9e5-4433-87c0-68b6b72699c7"
+)
+
+// Options configures an image.
+type Options struct {
+	// Size is the image size in bytes, rounded down to whole sectors.
+	// FAT32 needs at least about 34 MiB.
+	Size int64
+	// PartitionTable is the partitioning scheme, MBR by default.
+	PartitionTable PartitionTable
+	// Label is the volume label of the boot partition, at most 11
+	// characters.
+	Label string
+	// Files are added to the boot partition, keyed by path, replacing
+	// embedded files of the same path. A nil value removes the file.
+	Files map[string][]byte
+}
+
+// BuildForMAC builds an image holding the embedded firmware patched to
+// boot the host with the MAC address mac from the network, like served
+// firmware.
+func BuildForMAC(mac net.HardwareAddr, opts Options) ([]byte, error) {
+	firmware, err := edk2.Read(mac)
+	if err != nil {
+		return nil, fmt.Errorf("failed to patch firmware: %w", err)
+	}
+	return Build(firmware, opts)
+}
+
+// Build returns a disk image with a FAT32 boot partition holding the
+// embedded firmware files, as laid out by edk2.BootFiles, with RPI_EFI.fd
+// replaced by firmware. The image can be written to an SD card as is.
+// Identifiers such as the disk GUID are derived from the files, so the
+// same files always give the same image.
+func Build(firmware []byte, opts Options) ([]byte, error) {
+	if opts.Size == 0 {
+		opts.Size = DefaultSize
+	}
+	if opts.Label == "" {
+		opts.Label = DefaultLabel
+	}
+
+	files := edk2.BootFiles(firmware)
+	for name, data := range opts.Files {
+		name = path.Clean("/" + name)[1:]
+		if data == nil {
+			delete(files, name)
+		} else {
+			files[name] = data
+		}
+	}
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	id := sha512.New()
+	for _, name := range names {
+		id.Write([]byte(name))
+		id.Write(files[name])
+	}
+	seed := id.Sum(nil)
+
+	sectors := opts.Size / fat.SectorSize
+	first := int64(partitionStart / fat.SectorSize)
+	last := sectors - 1
+	if opts.PartitionTable == GPT {
+		last -= gptSectors
+	}
+	if last < first {
+		return nil, fmt.Errorf("image size %d is too small", opts.Size)
+	}
+
+	img := make([]byte, sectors*fat.SectorSize)
+	part := fat.NewSection(memory(img), first*fat.SectorSize, (last-first+1)*fat.SectorSize)
+	vol, err := fat.Format(part, (last-first+1)*fat.SectorSize, fat.FormatOptions{
+		Label:         opts.Label,
+		VolumeID:      binary.LittleEndian.Uint32(seed[0:4]),
+		HiddenSectors: uint32(first),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := vol.WriteFiles(files); err != nil {
+		return nil, err
+	}
+	if err := vol.Flush(); err != nil {
+		return nil, err
+	}
+
+	switch opts.PartitionTable {
+	case MBR:
+		writeMBR(img, binary.LittleEndian.Uint32(seed[4:8]), mbrTypeFAT32LBA, first, last-first+1)
+	case GPT:
+		writeMBR(img, 0, mbrTypeGPT, 1, min(sectors-1, 0xffffffff))
+		writeGPT(img, randomGUID(seed[8:24]), randomGUID(seed[24:40]), first, last)
+	default:
+		return nil, fmt.Errorf("unknown partition table %d", opts.PartitionTable)
+	}
+	return img, nil
+}
+
+// OpenBootPartition opens the FAT32 boot partition of the disk image dev
+// to update it in place, e.g. to replace RPI_EFI.fd with
+// fat.Volume.WriteFile. It is the first FAT32 partition of an MBR, or the
+// first EFI system or basic data partition of a GPT. Call Flush on the
+// volume when done.
+func OpenBootPartition(dev fat.Device) (*fat.Volume, error) {
+	mbr := make([]byte, fat.SectorSize)
+	if _, err := dev.ReadAt(mbr, 0); err != nil {
+		return nil, fmt.Errorf("failed to read MBR: %w", err)
+	}
+	if mbr[510] != 0x55 || mbr[511] != 0xaa {
+		return nil, errors.New("no partition table found")
+	}
+
+	for i := range 4 {
+		e := mbr[446+16*i : 446+16*(i+1)]
+		switch e[4] {
+		case mbrTypeGPT:
+			parts, err := esp.ReadPartitions(dev)
+			if err != nil {
+				return nil, err
+			}
+			for _, p := range parts {
+				if p.IsESP() || p.TypeGUID.String() == basicDataTypeGUID {
+					return openPartition(dev, p.Offset(), p.Size())
+				}
+			}
+			return nil, errors.New("no boot partition found in GPT")
+		case mbrTypeFAT32, mbrTypeFAT32LBA, mbrTypeEFI:
+			first := int64(binary.LittleEndian.Uint32(e[8:12]))
+			count := int64(binary.LittleEndian.Uint32(e[12:16]))
+			return openPartition(dev, first*fat.SectorSize, count*fat.SectorSize)
+		}
+	}
+	return nil, errors.New("no boot partition found in MBR")
+}
+
+func openPartition(dev fat.Device, off, n int64) (*fat.Volume, error) {
+	vol, err := fat.Open(fat.NewSection(dev, off, n))
+	if err != nil {
+		return nil, fmt.Errorf("boot partition at %d: %w", off, err)
+	}
+	return vol, nil
+}
+
+// writeMBR writes an MBR with a single partition. A GPT has a protective
+// MBR whose partition covers the whole disk.
+func writeMBR(img []byte, diskID uint32, typ byte, first, count int64) {
+	mbr := img[:fat.SectorSize]
+	binary.LittleEndian.PutUint32(mbr[440:444], diskID)
+	e := mbr[446:462]
+	// CHS addresses are unused with LBA and set to their maximum.
+	copy(e[1:4], []byte{0xfe, 0xff, 0xff})
+	e[4] = typ
+	copy(e[5:8], []byte{0xfe, 0xff, 0xff})
+	if typ == mbrTypeGPT {
+		copy(e[1:4], []byte{0x00, 0x02, 0x00})
+	}
+	binary.LittleEndian.PutUint32(e[8:12], uint32(first))
+	binary.LittleEndian.PutUint32(e[12:16], uint32(min(count, 0xffffffff)))
+	mbr[510], mbr[511] = 0x55, 0xaa
+}
+
+// writeGPT writes the primary and backup GPT of img with a single EFI
+// system partition from sector first to last.
+func writeGPT(img []byte, diskGUID, partGUID efi.GUID, first, last int64) {
+	sectors := int64(len(img) / fat.SectorSize)
+
+	entries := make([]byte, gptEntries*gptEntrySize)
+	e := entries[:gptEntrySize]
+	copy(e[0:16], efi.StringToGUID(esp.ESPTypeGUID).Bytes())
+	copy(e[16:32], partGUID.Bytes())
+	binary.LittleEndian.PutUint64(e[32:40], uint64(first))
+	binary.LittleEndian.PutUint64(e[40:48], uint64(last))
+	for i, c := range utf16.Encode([]rune("EFI system partition")) {
+		binary.LittleEndian.PutUint16(e[56+2*i:], c)
+	}
+	entriesCRC := crc32.ChecksumIEEE(entries)
+
+	header := func(lba, alternate, entriesLBA int64) []byte {
+		hdr := make([]byte, 92)
+		copy(hdr[0:8], "EFI PART")
+		binary.LittleEndian.PutUint32(hdr[8:12], 0x00010000)
+		binary.LittleEndian.PutUint32(hdr[12:16], 92)
+		binary.LittleEndian.PutUint64(hdr[24:32], uint64(lba))
+		binary.LittleEndian.PutUint64(hdr[32:40], uint64(alternate))
+		binary.LittleEndian.PutUint64(hdr[40:48], gptSectors+1)
+		binary.LittleEndian.PutUint64(hdr[48:56], uint64(sectors-gptSectors-1))
+		copy(hdr[56:72], diskGUID.Bytes())
+		binary.LittleEndian.PutUint64(hdr[72:80], uint64(entriesLBA))
+		binary.LittleEndian.PutUint32(hdr[80:84], gptEntries)
+		binary.LittleEndian.PutUint32(hdr[84:88], gptEntrySize)
+		binary.LittleEndian.PutUint32(hdr[88:92], entriesCRC)
+		binary.LittleEndian.PutUint32(hdr[16:20], crc32.ChecksumIEEE(hdr))
+		return hdr
+	}
+
+	copy(img[fat.SectorSize:], header(1, sectors-1, 2))
+	copy(img[2*fat.SectorSize:], entries)
+	backupEntries := sectors - gptSectors
+	copy(img[backupEntries*fat.SectorSize:], entries)
+	copy(img[(sectors-1)*fat.SectorSize:], header(sectors-1, 1, backupEntries))
+}
+
+// randomGUID returns the version 4 GUID made of 16 random bytes.
+func randomGUID(b []byte) efi.GUID {
+	g := efi.ParseBinGUID(b, 0)
+	g.Data3 = g.Data3&0x0fff | 0x4000
+	g.Data4[0] = g.Data4[0]&0x3f | 0x80
+	return g
+}
+
+// memory is a fat.Device backed by a byte slice.
+type memory []byte
+
+func (m memory) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 || off+int64(len(p)) > int64(len(m)) {
+		return 0, fmt.Errorf("read of %d bytes at %d is out of range", len(p), off)
+	}
+	return copy(p, m[off:]), nil
+}
+
+func (m memory) WriteAt(p []byte, off int64) (int, error) {
+	if off < 0 || off+int64(len(p)) > int64(len(m)) {
+		return 0, fmt.Errorf("write of %d bytes at %d is out of range", len(p), off)
+	}
+	return copy(m[off:], p), nil
+}
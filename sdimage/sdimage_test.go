@@ -0,0 +1,202 @@
+package sdimage
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io/fs"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/metal3-community/uefi-firmware-manager/edk2"
+	"github.com/metal3-community/uefi-firmware-manager/esp"
+	"github.com/metal3-community/uefi-firmware-manager/fat"
+)
+
+// openVolume opens the boot partition of img.
+func openVolume(t *testing.T, img []byte) *fat.Volume {
+	t.Helper()
+	vol, err := OpenBootPartition(memory(img))
+	if err != nil {
+		t.Fatalf("OpenBootPartition() error = %v", err)
+	}
+	return vol
+}
+
+// readFile returns the contents of the file at path.
+func readFile(t *testing.T, vol *fat.Volume, path string) []byte {
+	t.Helper()
+	data, err := vol.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestBuild_MBR(t *testing.T) {
+	firmware := []byte("patched firmware")
+	img, err := Build(firmware, Options{
+		Files: map[string][]byte{
+			"cmdline.txt":            nil,
+			"overlays/extra.dtbo":    []byte("overlay"),
+			"/EFI/BOOT/BOOTAA64.EFI": []byte("loader"),
+		},
+	})
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+	if len(img) != DefaultSize {
+		t.Fatalf("Build() image is %d bytes, want %d", len(img), DefaultSize)
+	}
+
+	if img[510] != 0x55 || img[511] != 0xaa {
+		t.Fatal("MBR has no signature")
+	}
+	e := img[446:462]
+	if e[4] != mbrTypeFAT32LBA {
+		t.Errorf("partition type = 0x%02x, want 0x%02x", e[4], mbrTypeFAT32LBA)
+	}
+	start := binary.LittleEndian.Uint32(e[8:12])
+	count := binary.LittleEndian.Uint32(e[12:16])
+	if start != partitionStart/fat.SectorSize || int64(start+count)*fat.SectorSize != DefaultSize {
+		t.Errorf("partition covers sectors %d+%d", start, count)
+	}
+
+	v := openVolume(t, img)
+	for name, want := range edk2.BootFiles(firmware) {
+		if name == "cmdline.txt" {
+			continue
+		}
+		if got := readFile(t, v, name); !bytes.Equal(got, want) {
+			t.Errorf("%s has %d bytes, want %d", name, len(got), len(want))
+		}
+	}
+	if got := readFile(t, v, "overlays/extra.dtbo"); string(got) != "overlay" {
+		t.Errorf("overlays/extra.dtbo = %q", got)
+	}
+	if got := readFile(t, v, "efi/boot/bootaa64.efi"); string(got) != "loader" {
+		t.Errorf("EFI/BOOT/BOOTAA64.EFI = %q", got)
+	}
+	if _, err := v.ReadFile("cmdline.txt"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("ReadFile(cmdline.txt) error = %v, want it removed", err)
+	}
+}
+
+func TestBuild_GPT(t *testing.T) {
+	img, err := Build(edk2.RpiEfi, Options{
+		Size:           40 << 20,
+		PartitionTable: GPT,
+		Files:          map[string][]byte{"EFI/BOOT/BOOTAA64.EFI": []byte("loader")},
+	})
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+	if img[450] != mbrTypeGPT {
+		t.Errorf("protective MBR type = 0x%02x", img[450])
+	}
+
+	loaders, err := esp.FindLoaders(bytes.NewReader(img))
+	if err != nil {
+		t.Fatalf("FindLoaders() error = %v", err)
+	}
+	if len(loaders) != 1 || loaders[0].Path != `\EFI\BOOT\BOOTAA64.EFI` {
+		t.Fatalf("FindLoaders() = %+v", loaders)
+	}
+	p := loaders[0].Partition
+	if p.Offset() != partitionStart || p.Offset()+p.Size() > int64(len(img))-gptSectors*fat.SectorSize {
+		t.Errorf("partition at %d of %d bytes", p.Offset(), p.Size())
+	}
+
+	backup := img[len(img)-fat.SectorSize:]
+	if string(backup[0:8]) != "EFI PART" || binary.LittleEndian.Uint64(backup[24:32]) != uint64(len(img)/fat.SectorSize-1) {
+		t.Error("backup GPT header missing")
+	}
+	if !bytes.Equal(backup[88:92], img[fat.SectorSize+88:fat.SectorSize+92]) {
+		t.Error("backup GPT entries checksum differs")
+	}
+}
+
+func TestBuildForMAC(t *testing.T) {
+	mac, _ := net.ParseMAC("d8:3a:dd:01:02:03")
+	img, err := BuildForMAC(mac, Options{})
+	if err != nil {
+		t.Fatalf("BuildForMAC() error = %v", err)
+	}
+	want, err := edk2.Read(mac)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := readFile(t, openVolume(t, img), edk2.FirmwareFileName)
+	if !bytes.Equal(got, want) {
+		t.Error("image does not hold the patched firmware")
+	}
+
+	again, err := BuildForMAC(mac, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(img, again) {
+		t.Error("BuildForMAC() is not reproducible")
+	}
+}
+
+func TestBuild_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		opts Options
+		want string
+	}{
+		{"too small for FAT32", Options{Size: 16 << 20}, "too few"},
+		{"smaller than the alignment", Options{Size: 512 << 10}, "too small"},
+		{"long label", Options{Label: "RASPBERRY-PI4"}, "longer than 11"},
+		{"bad name", Options{Files: map[string][]byte{"a:b": {1}}}, "invalid character"},
+		{"file over directory", Options{Files: map[string][]byte{"overlays": {1}}}, "is not a directory"},
+		{"unknown table", Options{PartitionTable: 7}, "unknown partition table"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Build(edk2.RpiEfi, tt.opts)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Build() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestOpenBootPartition(t *testing.T) {
+	for _, table := range []PartitionTable{MBR, GPT} {
+		img, err := Build(edk2.RpiEfi, Options{PartitionTable: table})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		vol := openVolume(t, img)
+		firmware := bytes.Repeat([]byte("new firmware "), 400000)
+		if err := vol.WriteFile(edk2.FirmwareFileName, firmware); err != nil {
+			t.Fatalf("WriteFile() error = %v", err)
+		}
+		if err := vol.Remove("overlays/miniuart-bt.dtbo"); err != nil {
+			t.Fatalf("Remove() error = %v", err)
+		}
+		if err := vol.Flush(); err != nil {
+			t.Fatal(err)
+		}
+
+		vol = openVolume(t, img)
+		if got := readFile(t, vol, edk2.FirmwareFileName); !bytes.Equal(got, firmware) {
+			t.Errorf("table %d: RPI_EFI.fd has %d bytes, want %d", table, len(got), len(firmware))
+		}
+		overlays, err := vol.ReadDir("overlays")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(overlays) != 2 {
+			t.Errorf("table %d: overlays = %+v", table, overlays)
+		}
+	}
+
+	if _, err := OpenBootPartition(memory(make([]byte, 1<<20))); err == nil {
+		t.Error("OpenBootPartition() of a blank disk succeeded")
+	}
+}
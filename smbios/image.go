@@ -0,0 +1,60 @@
+package smbios
+
+import (
+	"bytes"
+	"encoding/binary"
+	"unicode/utf16"
+)
+
+// FirmwareVersionPrefix starts the PcdFirmwareVersionString of EDK2
+// platform builds, which is what their SMBIOS type 0 version reports.
+const FirmwareVersionPrefix = "UEFI Firmware "
+
+// FirmwareInfo returns the BIOS information of a firmware image. Images
+// that carry an SMBIOS table report its type 0 structure. EDK2 images
+// generate their tables at boot, so for those the version is taken from
+// the firmware version string baked into the image and the other fields
+// are left empty.
+func FirmwareInfo(image []byte) (*BIOSInfo, error) {
+	if table, err := FindTable(image); err == nil {
+		structs, err := ParseTable(table)
+		if err == nil {
+			if info, err := BIOSInformation(structs); err == nil {
+				return info, nil
+			}
+		}
+	}
+
+	if version := firmwareVersionString(image); version != "" {
+		return &BIOSInfo{Version: version}, nil
+	}
+	return nil, ErrNotFound
+}
+
+// firmwareVersionString finds the UCS-2 firmware version string in image.
+func firmwareVersionString(image []byte) string {
+	var prefix []byte
+	for _, c := range utf16.Encode([]rune(FirmwareVersionPrefix)) {
+		prefix = binary.LittleEndian.AppendUint16(prefix, c)
+	}
+	for start := 0; ; {
+		i := bytes.Index(image[start:], prefix)
+		if i < 0 {
+			return ""
+		}
+		off := start + i
+		var u []uint16
+		for j := off; j+1 < len(image); j += 2 {
+			c := binary.LittleEndian.Uint16(image[j:])
+			if c == 0 {
+				break
+			}
+			u = append(u, c)
+		}
+		// Strings must be aligned and longer than the bare prefix.
+		if off%2 == 0 && len(u) > len(FirmwareVersionPrefix) {
+			return string(utf16.Decode(u))
+		}
+		start = off + 1
+	}
+}
@@ -0,0 +1,143 @@
+// Package smbios parses SMBIOS structure tables and extracts the BIOS
+// information of firmware images.
+package smbios
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+const (
+	// TypeBIOSInformation is the SMBIOS type 0 structure.
+	TypeBIOSInformation = 0
+	// TypeEndOfTable is the SMBIOS type 127 structure.
+	TypeEndOfTable = 127
+
+	anchor21 = "_SM_"
+	anchor30 = "_SM3_"
+)
+
+// ErrNotFound is returned when an image carries no SMBIOS data.
+var ErrNotFound = errors.New("no SMBIOS data found")
+
+// Structure is an SMBIOS structure with its string set.
+type Structure struct {
+	Type   uint8
+	Handle uint16
+	// Formatted is the formatted area, including the 4-byte header.
+	Formatted []byte
+	Strings   []string
+}
+
+// String returns the string referenced by the byte at offset in the
+// formatted area, or "" if there is none.
+func (s *Structure) String(offset int) string {
+	if offset >= len(s.Formatted) {
+		return ""
+	}
+	idx := int(s.Formatted[offset])
+	if idx == 0 || idx > len(s.Strings) {
+		return ""
+	}
+	return s.Strings[idx-1]
+}
+
+// BIOSInfo is the firmware identification of an SMBIOS type 0 structure.
+type BIOSInfo struct {
+	Vendor      string
+	Version     string
+	ReleaseDate string
+}
+
+// ParseTable parses an SMBIOS structure table, such as
+// /sys/firmware/dmi/tables/DMI. Parsing stops at the end-of-table
+// structure.
+func ParseTable(data []byte) ([]Structure, error) {
+	var structs []Structure
+	off := 0
+	for off+4 <= len(data) {
+		length := int(data[off+1])
+		if length < 4 || off+length > len(data) {
+			return nil, fmt.Errorf("invalid structure length %d at offset 0x%x", length, off)
+		}
+		s := Structure{
+			Type:      data[off],
+			Handle:    binary.LittleEndian.Uint16(data[off+2:]),
+			Formatted: data[off : off+length],
+		}
+
+		// The string set ends with a double NUL.
+		end := bytes.Index(data[off+length:], []byte{0, 0})
+		if end < 0 {
+			return nil, fmt.Errorf("unterminated string set at offset 0x%x", off+length)
+		}
+		if end > 0 {
+			for _, str := range bytes.Split(data[off+length:off+length+end], []byte{0}) {
+				s.Strings = append(s.Strings, string(str))
+			}
+		}
+		structs = append(structs, s)
+		off += length + end + 2
+
+		if s.Type == TypeEndOfTable {
+			break
+		}
+	}
+	return structs, nil
+}
+
+// BIOSInformation returns the type 0 structure of a table.
+func BIOSInformation(structs []Structure) (*BIOSInfo, error) {
+	for _, s := range structs {
+		if s.Type == TypeBIOSInformation {
+			return &BIOSInfo{
+				Vendor:      s.String(0x04),
+				Version:     s.String(0x05),
+				ReleaseDate: s.String(0x08),
+			}, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: no BIOS information structure", ErrNotFound)
+}
+
+// FindTable locates an SMBIOS entry point in data and returns the structure
+// table it points at. The table address is taken as an offset into data, as
+// in memory dumps.
+func FindTable(data []byte) ([]byte, error) {
+	for off := 0; off+16 <= len(data); off += 16 {
+		var addr, size uint64
+		switch {
+		case bytes.HasPrefix(data[off:], []byte(anchor30)) && off+24 <= len(data):
+			length := int(data[off+6])
+			if length < 24 || off+length > len(data) || !checksum(data[off:off+length]) {
+				continue
+			}
+			size = uint64(binary.LittleEndian.Uint32(data[off+12:]))
+			addr = binary.LittleEndian.Uint64(data[off+16:])
+		case bytes.HasPrefix(data[off:], []byte(anchor21)) && off+31 <= len(data):
+			length := int(data[off+5])
+			if length < 31 || off+length > len(data) || !checksum(data[off:off+length]) {
+				continue
+			}
+			size = uint64(binary.LittleEndian.Uint16(data[off+22:]))
+			addr = uint64(binary.LittleEndian.Uint32(data[off+24:]))
+		default:
+			continue
+		}
+		if addr > uint64(len(data)) || size > uint64(len(data))-addr {
+			continue
+		}
+		return data[addr : addr+size], nil
+	}
+	return nil, ErrNotFound
+}
+
+func checksum(data []byte) bool {
+	var sum byte
+	for _, b := range data {
+		sum += b
+	}
+	return sum == 0
+}
@@ -0,0 +1,121 @@
+package smbios
+
+import (
+	"encoding/binary"
+	"errors"
+	"os"
+	"reflect"
+	"testing"
+)
+
+// biosTable returns a type 0 structure followed by the end-of-table
+// structure.
+func biosTable() []byte {
+	t0 := make([]byte, 0x18)
+	t0[0] = TypeBIOSInformation
+	t0[1] = 0x18
+	t0[4], t0[5], t0[8] = 1, 2, 3
+	table := append(t0, "https://github.com/pftf/RPi4\x00UEFI Firmware v1.38\x0012/20/2024\x00\x00"...)
+	return append(table, TypeEndOfTable, 4, 0xfe, 0xff, 0, 0)
+}
+
+// entryPoint30 returns an SMBIOS 3.0 entry point for a table at addr.
+func entryPoint30(addr uint64, size uint32) []byte {
+	ep := make([]byte, 24)
+	copy(ep, anchor30)
+	ep[6] = 24
+	ep[7], ep[8], ep[10] = 3, 6, 1
+	binary.LittleEndian.PutUint32(ep[12:], size)
+	binary.LittleEndian.PutUint64(ep[16:], addr)
+	var sum byte
+	for _, b := range ep {
+		sum += b
+	}
+	ep[5] = -sum
+	return ep
+}
+
+func TestParseTable(t *testing.T) {
+	structs, err := ParseTable(biosTable())
+	if err != nil {
+		t.Fatalf("ParseTable() error = %v", err)
+	}
+	if len(structs) != 2 || structs[1].Type != TypeEndOfTable || structs[1].Strings != nil {
+		t.Fatalf("ParseTable() = %+v", structs)
+	}
+	got, err := BIOSInformation(structs)
+	if err != nil {
+		t.Fatalf("BIOSInformation() error = %v", err)
+	}
+	want := &BIOSInfo{
+		Vendor:      "https://github.com/pftf/RPi4",
+		Version:     "UEFI Firmware v1.38",
+		ReleaseDate: "12/20/2024",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("BIOSInformation() = %+v, want %+v", got, want)
+	}
+
+	for _, data := range [][]byte{
+		{0, 3, 0, 0},       // length below the header size
+		{0, 4, 0, 0, 'x'},  // unterminated string set
+		{0, 0x18, 0, 0, 0}, // truncated formatted area
+	} {
+		if _, err := ParseTable(data); err == nil {
+			t.Errorf("ParseTable(%v) expected an error", data)
+		}
+	}
+}
+
+func TestFirmwareInfo(t *testing.T) {
+	table := biosTable()
+	image := make([]byte, 0x100)
+	copy(image[0x40:], entryPoint30(0x80, uint32(len(table))))
+	copy(image[0x80:], table)
+
+	info, err := FirmwareInfo(image)
+	if err != nil {
+		t.Fatalf("FirmwareInfo() error = %v", err)
+	}
+	if info.Version != "UEFI Firmware v1.38" || info.ReleaseDate != "12/20/2024" {
+		t.Errorf("FirmwareInfo() = %+v", info)
+	}
+
+	// A bad checksum hides the entry point.
+	image[0x45]++
+	if _, err := FindTable(image); !errors.Is(err, ErrNotFound) {
+		t.Errorf("FindTable() error = %v, want ErrNotFound", err)
+	}
+
+	if _, err := FirmwareInfo(make([]byte, 0x100)); !errors.Is(err, ErrNotFound) {
+		t.Errorf("FirmwareInfo() error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestFirmwareInfo_EDK2Image(t *testing.T) {
+	image, err := os.ReadFile("../edk2/RPI_EFI.fd")
+	if err != nil {
+		t.Fatalf("failed to read firmware: %v", err)
+	}
+	info, err := FirmwareInfo(image)
+	if err != nil {
+		t.Fatalf("FirmwareInfo() error = %v", err)
+	}
+	if want := "UEFI Firmware v0.0.2-62-gc1c9118"; info.Version != want {
+		t.Errorf("Version = %q, want %q", info.Version, want)
+	}
+}
+
+func FuzzParseTable(f *testing.F) {
+	f.Add(biosTable())
+	f.Add([]byte{})
+	// An entry point whose table address and size overflow.
+	f.Add(append(entryPoint30(1<<64-8, 16), make([]byte, 8)...))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if structs, err := ParseTable(data); err == nil {
+			_, _ = BIOSInformation(structs)
+		}
+		_, _ = FirmwareInfo(data)
+	})
+}
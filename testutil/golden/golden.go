@@ -0,0 +1,116 @@
+// Package golden compares serialized bytes against golden files in the
+// testdata directory of the package under test.
+//
+// Run the tests of a package with -update, e.g. go test ./varstore -update,
+// to rewrite its golden files from the current output, then review the
+// change with git diff.
+package golden
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite golden files from the test output")
+
+// maxRows is the number of differing rows Diff shows.
+const maxRows = 32
+
+// Path returns the golden file of name.
+func Path(name string) string {
+	return filepath.Join("testdata", name+".golden")
+}
+
+// Assert fails tb unless got matches the golden file of name. With -update
+// the golden file is rewritten from got instead.
+func Assert(tb testing.TB, name string, got []byte) {
+	tb.Helper()
+	path := Path(name)
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			tb.Fatal(err)
+		}
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			tb.Fatal(err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		tb.Fatalf("failed to read golden file (run with -update to create it): %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		tb.Errorf("%s: output differs from golden file (-want +got):\n%s", path, Diff(want, got))
+	}
+}
+
+// Diff returns a hex dump of the 16 byte rows that differ between want and
+// got, or "" if they are equal.
+func Diff(want, got []byte) string {
+	var b strings.Builder
+	if len(want) != len(got) {
+		fmt.Fprintf(&b, "length %d, want %d\n", len(got), len(want))
+	}
+	rows := 0
+	for off := 0; off < max(len(want), len(got)); off += 16 {
+		w, g := row(want, off), row(got, off)
+		if bytes.Equal(w, g) && len(w) == len(g) {
+			continue
+		}
+		if rows == maxRows {
+			b.WriteString("...\n")
+			break
+		}
+		rows++
+		if w != nil {
+			b.WriteString("-" + dumpRow(off, w, g))
+		}
+		if g != nil {
+			b.WriteString("+" + dumpRow(off, g, w))
+		}
+	}
+	return b.String()
+}
+
+// row returns the 16 bytes of data at off, or nil past the end.
+func row(data []byte, off int) []byte {
+	if off >= len(data) {
+		return nil
+	}
+	return data[off:min(off+16, len(data))]
+}
+
+// dumpRow formats data at off as hex and ASCII, marking the bytes that
+// differ from other with a caret line.
+func dumpRow(off int, data, other []byte) string {
+	var hex, ascii, marks strings.Builder
+	for i := range 16 {
+		if i == 8 {
+			hex.WriteByte(' ')
+			marks.WriteByte(' ')
+		}
+		if i >= len(data) {
+			hex.WriteString("   ")
+			marks.WriteString("   ")
+			continue
+		}
+		fmt.Fprintf(&hex, " %02x", data[i])
+		if i >= len(other) || other[i] != data[i] {
+			marks.WriteString(" ^^")
+		} else {
+			marks.WriteString("   ")
+		}
+		if c := data[i]; c >= 0x20 && c < 0x7f {
+			ascii.WriteByte(c)
+		} else {
+			ascii.WriteByte('.')
+		}
+	}
+	return fmt.Sprintf("%08x %s  |%s|\n          %s\n", off, hex.String(), ascii.String(), strings.TrimRight(marks.String(), " "))
+}
@@ -0,0 +1,30 @@
+package golden
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	want := []byte("0123456789abcdef0123456789abcdef")
+	if got := Diff(want, want); got != "" {
+		t.Errorf("Diff() of equal data = %q, want empty", got)
+	}
+
+	got := []byte("0123456789abcdef01234X6789abcdef!")
+	diff := Diff(want, got)
+	for _, s := range []string{
+		"length 33, want 32",
+		"-00000010  30 31 32 33 34 35 36 37",
+		"+00000010  30 31 32 33 34 58 36 37",
+		"+00000020  21",
+		"|01234X6789abcdef|",
+	} {
+		if !strings.Contains(diff, s) {
+			t.Errorf("Diff() does not contain %q", s)
+		}
+	}
+	if strings.Contains(diff, "00000000") {
+		t.Error("Diff() shows an equal row")
+	}
+}
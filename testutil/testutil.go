@@ -0,0 +1,109 @@
+// Package testutil builds synthetic firmware images for tests, so they do
+// not depend on real firmware dumps.
+package testutil
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/metal3-community/uefi-firmware-manager/efi"
+	"github.com/metal3-community/uefi-firmware-manager/varstore"
+)
+
+// Layout of the images built by Firmware, matching an OVMF_VARS.fd: a
+// firmware volume with an authenticated varstore followed by the fault
+// tolerant write areas.
+const (
+	VolumeSize   = 0x84000
+	BlockSize    = 0x1000
+	headerSize   = 0x48
+	varstoreSize = 0x40000 - headerSize
+)
+
+// EmptyVolume returns a firmware volume holding an empty varstore.
+func EmptyVolume() []byte {
+	data := bytes.Repeat([]byte{0xff}, VolumeSize)
+	header := data[:headerSize]
+	clear(header)
+	copy(header[16:], efi.StringToGUID(efi.NvData).Bytes())
+	binary.LittleEndian.PutUint64(header[32:], VolumeSize)
+	binary.LittleEndian.PutUint32(header[40:], 0x4856465f) // _FVH
+	binary.LittleEndian.PutUint32(header[44:], 0x4feff)
+	binary.LittleEndian.PutUint16(header[48:], headerSize)
+	header[55] = 2
+	binary.LittleEndian.PutUint32(header[56:], VolumeSize/BlockSize)
+	binary.LittleEndian.PutUint32(header[60:], BlockSize)
+
+	store := data[headerSize : headerSize+28]
+	clear(store)
+	copy(store, efi.StringToGUID(efi.AuthVars).Bytes())
+	binary.LittleEndian.PutUint32(store[16:], varstoreSize)
+	store[20], store[21] = 0x5a, 0xfe // formatted, healthy
+	return data
+}
+
+// Firmware returns a firmware volume whose varstore holds varlist.
+func Firmware(varlist efi.EfiVarList) ([]byte, error) {
+	vs, err := varstore.New(EmptyVolume())
+	if err != nil {
+		return nil, err
+	}
+	return vs.ReadAll(varlist)
+}
+
+// WriteFirmware writes a firmware volume holding varlist to a temporary
+// file and returns its path. The file is removed when the test ends.
+func WriteFirmware(tb testing.TB, varlist efi.EfiVarList) string {
+	tb.Helper()
+	data, err := Firmware(varlist)
+	if err != nil {
+		tb.Fatalf("failed to build firmware: %v", err)
+	}
+	path := filepath.Join(tb.TempDir(), "VARS.fd")
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		tb.Fatal(err)
+	}
+	return path
+}
+
+// BootFile is the file booted by the entries VarList creates.
+const BootFile = `\EFI\BOOT\BOOTAA64.EFI`
+
+// VarList returns a variable list with an active boot entry for each of
+// titles, booting BootFile, a BootOrder listing them in order and the
+// Timeout and PlatformLang variables.
+func VarList(tb testing.TB, titles ...string) efi.EfiVarList {
+	tb.Helper()
+	l := efi.NewEfiVarList()
+	order := make([]uint16, len(titles))
+	for i, title := range titles {
+		v, err := l.Create(fmt.Sprintf("Boot%04X", i))
+		if err != nil {
+			tb.Fatal(err)
+		}
+		path := (&efi.DevicePath{}).FilePath(BootFile)
+		if err := v.SetBootEntryDevicePath(efi.LOAD_OPTION_ACTIVE, title, path, nil); err != nil {
+			tb.Fatalf("failed to set boot entry %q: %v", title, err)
+		}
+		order[i] = uint16(i)
+	}
+	if err := l.SetBootOrder(order); err != nil {
+		tb.Fatal(err)
+	}
+
+	timeout, err := l.Create("Timeout")
+	if err != nil {
+		tb.Fatal(err)
+	}
+	timeout.SetUint16(5)
+	lang, err := l.Create("PlatformLang")
+	if err != nil {
+		tb.Fatal(err)
+	}
+	lang.SetString("en-US")
+	return l
+}
@@ -0,0 +1,45 @@
+package testutil
+
+import (
+	"os"
+	"testing"
+
+	"github.com/metal3-community/uefi-firmware-manager/varstore"
+)
+
+func TestFirmware(t *testing.T) {
+	path := WriteFirmware(t, VarList(t, "Shell", "PXE"))
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) != VolumeSize {
+		t.Fatalf("len(data) = %#x, want %#x", len(data), VolumeSize)
+	}
+
+	vs, err := varstore.New(data)
+	if err != nil {
+		t.Fatalf("varstore.New() error = %v", err)
+	}
+	l, err := vs.GetVarList()
+	if err != nil {
+		t.Fatalf("GetVarList() error = %v", err)
+	}
+	order, err := l.GetBootOrder()
+	if err != nil {
+		t.Fatalf("GetBootOrder() error = %v", err)
+	}
+	if len(order) != 2 || order[0] != 0 || order[1] != 1 {
+		t.Errorf("BootOrder = %v, want [0 1]", order)
+	}
+	entry, err := l.GetBootEntry(1)
+	if err != nil {
+		t.Fatalf("GetBootEntry() error = %v", err)
+	}
+	if got := entry.Title.String(); got != "PXE" {
+		t.Errorf("Boot0001 title = %q, want %q", got, "PXE")
+	}
+	if _, ok := l.Lookup("PlatformLang"); !ok {
+		t.Error("PlatformLang missing")
+	}
+}
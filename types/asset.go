@@ -0,0 +1,14 @@
+package types
+
+// FirmwareAsset describes a file of the firmware served to hosts, as
+// listed by edk2.Manifest.
+type FirmwareAsset struct {
+	// Name is the slash separated path of the file on the boot partition.
+	Name string `json:"name" yaml:"name"`
+	Size int64  `json:"size" yaml:"size"`
+	// SHA256 is the hex encoded SHA-256 digest of the file.
+	SHA256 string `json:"sha256" yaml:"sha256"`
+	// Version is the upstream version of the file: the firmware version
+	// of RPI_EFI.fd and the release the other files were taken from.
+	Version string `json:"version" yaml:"version"`
+}
@@ -0,0 +1,13 @@
+package types
+
+import "time"
+
+// FirmwareBackup is a backup of a firmware image, taken before the image
+// was replaced.
+type FirmwareBackup struct {
+	// ID identifies the backup to restore, e.g. "20260102T150405.000Z".
+	ID      string    `json:"id" yaml:"id"`
+	Path    string    `json:"path" yaml:"path"`
+	Created time.Time `json:"created" yaml:"created"`
+	Size    int64     `json:"size" yaml:"size"`
+}
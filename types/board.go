@@ -0,0 +1,63 @@
+package types
+
+import (
+	"fmt"
+	"slices"
+)
+
+// BoardModel names a Raspberry Pi board model that firmware is built for.
+type BoardModel string
+
+// Board models with firmware bundles.
+const (
+	BoardRPi4   BoardModel = "rpi4"
+	BoardRPi400 BoardModel = "rpi400"
+	BoardCM4    BoardModel = "cm4"
+	BoardRPi5   BoardModel = "rpi5"
+)
+
+// BoardModels lists the known board models.
+var BoardModels = []BoardModel{BoardRPi4, BoardRPi400, BoardCM4, BoardRPi5}
+
+// boardCompatible maps the board models to the device tree compatible
+// string of the board.
+var boardCompatible = map[BoardModel]string{
+	BoardRPi4:   "raspberrypi,4-model-b",
+	BoardRPi400: "raspberrypi,400",
+	BoardCM4:    "raspberrypi,4-compute-module",
+	BoardRPi5:   "raspberrypi,5-model-b",
+}
+
+// ParseBoardModel returns the board model named s.
+func ParseBoardModel(s string) (BoardModel, error) {
+	if m := BoardModel(s); slices.Contains(BoardModels, m) {
+		return m, nil
+	}
+	return "", fmt.Errorf("unknown board model %q", s)
+}
+
+// BoardModelOf returns the board model of a device tree root compatible
+// list, as in dtb.Info.
+func BoardModelOf(compatible []string) (BoardModel, error) {
+	for _, m := range BoardModels {
+		if slices.Contains(compatible, boardCompatible[m]) {
+			return m, nil
+		}
+	}
+	return "", fmt.Errorf("no known board model is compatible with %q", compatible)
+}
+
+// Compatible returns the device tree compatible string of the board.
+func (m BoardModel) Compatible() string {
+	return boardCompatible[m]
+}
+
+// Fallback returns the model whose firmware also boots m, or "" if there
+// is none: the Pi 400 and CM4 share the BCM2711 firmware of the Pi 4.
+func (m BoardModel) Fallback() BoardModel {
+	switch m {
+	case BoardRPi400, BoardCM4:
+		return BoardRPi4
+	}
+	return ""
+}
@@ -0,0 +1,24 @@
+package types
+
+import "testing"
+
+func TestBoardModelOf(t *testing.T) {
+	for _, m := range BoardModels {
+		got, err := BoardModelOf([]string{m.Compatible(), "brcm,bcm2711"})
+		if err != nil || got != m {
+			t.Errorf("BoardModelOf(%s) = %s, %v", m.Compatible(), got, err)
+		}
+		if parsed, err := ParseBoardModel(string(m)); err != nil || parsed != m {
+			t.Errorf("ParseBoardModel(%s) = %s, %v", m, parsed, err)
+		}
+	}
+	if _, err := BoardModelOf([]string{"brcm,bcm2711"}); err == nil {
+		t.Error("BoardModelOf() of an unknown board succeeded")
+	}
+	if _, err := ParseBoardModel("rpi3"); err == nil {
+		t.Error("ParseBoardModel(rpi3) succeeded")
+	}
+	if BoardCM4.Fallback() != BoardRPi4 || BoardRPi5.Fallback() != "" {
+		t.Errorf("Fallback() of cm4 = %q, of rpi5 = %q", BoardCM4.Fallback(), BoardRPi5.Fallback())
+	}
+}
@@ -0,0 +1,88 @@
+package types
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+)
+
+// ErrBundleMismatch is returned when the files of a bundle do not match
+// its manifest.
+var ErrBundleMismatch = errors.New("bundle does not match its manifest")
+
+// BundleManifest lists the files of a firmware bundle, so that an
+// extracted bundle can be checked for missing, extra and altered files.
+type BundleManifest struct {
+	Files []BundleFile `json:"files" yaml:"files"`
+}
+
+// BundleFile is a file of a BundleManifest.
+type BundleFile struct {
+	// Name is the slash separated path of the file in the bundle.
+	Name string `json:"name" yaml:"name"`
+	Size int64  `json:"size" yaml:"size"`
+	// Checksum is the digest of the file as "<algorithm>:<hex>", as for
+	// FirmwareSource.
+	Checksum string `json:"checksum" yaml:"checksum"`
+}
+
+// NewBundleManifest returns the manifest of files, ordered by name, with
+// SHA-256 checksums.
+func NewBundleManifest(files map[string][]byte) BundleManifest {
+	m := BundleManifest{Files: make([]BundleFile, 0, len(files))}
+	for name, data := range files {
+		sum := sha256.Sum256(data)
+		m.Files = append(m.Files, BundleFile{
+			Name:     name,
+			Size:     int64(len(data)),
+			Checksum: "sha256:" + hex.EncodeToString(sum[:]),
+		})
+	}
+	sort.Slice(m.Files, func(i, j int) bool { return m.Files[i].Name < m.Files[j].Name })
+	return m
+}
+
+// Verify checks that files holds exactly the files of m, with their sizes
+// and checksums. Files named in ignore, such as the manifest itself, are
+// not reported as extra. Every difference is reported; each wraps
+// ErrBundleMismatch.
+func (m BundleManifest) Verify(files map[string][]byte, ignore ...string) error {
+	var errs []error
+	listed := map[string]bool{}
+	for _, f := range m.Files {
+		if listed[f.Name] {
+			errs = append(errs, fmt.Errorf("%w: %s is listed twice", ErrBundleMismatch, f.Name))
+			continue
+		}
+		listed[f.Name] = true
+
+		data, ok := files[f.Name]
+		switch {
+		case !ok:
+			errs = append(errs, fmt.Errorf("%w: %s is missing", ErrBundleMismatch, f.Name))
+		case int64(len(data)) != f.Size:
+			errs = append(errs, fmt.Errorf("%w: %s has %d bytes, want %d", ErrBundleMismatch, f.Name, len(data), f.Size))
+		case f.Checksum == "":
+			errs = append(errs, fmt.Errorf("%w: %s has no checksum", ErrBundleMismatch, f.Name))
+		default:
+			if err := (FirmwareSource{Checksum: f.Checksum}).VerifyChecksum(data); err != nil {
+				errs = append(errs, fmt.Errorf("%w: %s: %w", ErrBundleMismatch, f.Name, err))
+			}
+		}
+	}
+
+	var extra []string
+	for name := range files {
+		if !listed[name] && !slices.Contains(ignore, name) {
+			extra = append(extra, name)
+		}
+	}
+	sort.Strings(extra)
+	for _, name := range extra {
+		errs = append(errs, fmt.Errorf("%w: %s is not listed", ErrBundleMismatch, name))
+	}
+	return errors.Join(errs...)
+}
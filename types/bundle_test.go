@@ -0,0 +1,54 @@
+package types
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestBundleManifest_Verify(t *testing.T) {
+	files := map[string][]byte{
+		"RPI_EFI.fd":         []byte("firmware"),
+		"config.txt":         []byte("arm_64bit=1\n"),
+		"overlays/uart.dtbo": []byte("dtbo"),
+	}
+	m := NewBundleManifest(files)
+	if len(m.Files) != 3 || m.Files[0].Name != "RPI_EFI.fd" || m.Files[2].Name != "overlays/uart.dtbo" {
+		t.Fatalf("NewBundleManifest() = %+v", m)
+	}
+	if err := m.Verify(files); err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+	if err := m.Verify(map[string][]byte{
+		"RPI_EFI.fd":         []byte("firmware"),
+		"config.txt":         []byte("arm_64bit=1\n"),
+		"overlays/uart.dtbo": []byte("dtbo"),
+		"manifest.json":      []byte("{}"),
+	}, "manifest.json"); err != nil {
+		t.Errorf("Verify() with an ignored file error = %v", err)
+	}
+
+	m.Files = append(m.Files, BundleFile{Name: "start4.elf", Size: 1}, m.Files[0])
+	m.Files[1].Checksum = ""
+	err := m.Verify(map[string][]byte{
+		"RPI_EFI.fd":         []byte("tampered"),
+		"config.txt":         []byte("arm_64bit=1\n"),
+		"overlays/uart.dtbo": []byte("dtbo!"),
+		"extra.bin":          nil,
+	})
+	if !errors.Is(err, ErrBundleMismatch) {
+		t.Fatalf("Verify() error = %v, want ErrBundleMismatch", err)
+	}
+	for _, want := range []string{
+		"RPI_EFI.fd: checksum mismatch",
+		"config.txt has no checksum",
+		"overlays/uart.dtbo has 5 bytes, want 4",
+		"start4.elf is missing",
+		"RPI_EFI.fd is listed twice",
+		"extra.bin is not listed",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Verify() error = %v, want %q reported", err, want)
+		}
+	}
+}
@@ -0,0 +1,20 @@
+package types
+
+// ConfigConflict is a hunk of config.txt that was changed both locally and
+// upstream. The merge keeps the local lines.
+type ConfigConflict struct {
+	// Line is the 1-based line of the merged file the hunk starts at.
+	Line     int      `json:"line" yaml:"line"`
+	Base     []string `json:"base" yaml:"base"`
+	Local    []string `json:"local" yaml:"local"`
+	Upstream []string `json:"upstream" yaml:"upstream"`
+}
+
+// ConfigMerge is the result of merging an upstream config.txt into the
+// config.txt of a firmware directory.
+type ConfigMerge struct {
+	Path      string           `json:"path" yaml:"path"`
+	Conflicts []ConfigConflict `json:"conflicts,omitempty" yaml:"conflicts,omitempty"`
+	// Error is set when the file could not be merged; it is left as is.
+	Error string `json:"error,omitempty" yaml:"error,omitempty"`
+}
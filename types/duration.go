@@ -0,0 +1,26 @@
+package types
+
+import (
+	"fmt"
+	"time"
+)
+
+// Duration is a time.Duration written in JSON and YAML as a string
+// understood by time.ParseDuration, such as "30s" or "5m", so that
+// configuration files do not give it in nanoseconds.
+type Duration time.Duration
+
+// MarshalText returns the duration as a string, such as "1m30s".
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+// UnmarshalText parses a duration string.
+func (d *Duration) UnmarshalText(text []byte) error {
+	v, err := time.ParseDuration(string(text))
+	if err != nil {
+		return fmt.Errorf("invalid duration %q: %w", text, err)
+	}
+	*d = Duration(v)
+	return nil
+}
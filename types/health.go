@@ -0,0 +1,15 @@
+package types
+
+// HealthCheck is the result of one check of a health probe.
+type HealthCheck struct {
+	Name string `json:"name" yaml:"name"`
+	// Error is why the check failed, or empty if it passed.
+	Error string `json:"error,omitempty" yaml:"error,omitempty"`
+}
+
+// HealthStatus is the result of a health probe.
+type HealthStatus struct {
+	// OK reports whether every check passed.
+	OK     bool          `json:"ok" yaml:"ok"`
+	Checks []HealthCheck `json:"checks" yaml:"checks"`
+}
@@ -0,0 +1,86 @@
+package types
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// ImageSignatureExt is appended to the path of a firmware image to name
+// its detached signature.
+const ImageSignatureExt = ".sig"
+
+// ErrInvalidSignature is returned when an image signature does not verify.
+var ErrInvalidSignature = errors.New("invalid image signature")
+
+// SignImage returns a detached signature over the SHA-256 hash of the
+// image read from r. Ed25519 signatures are over the hash itself, ECDSA
+// signatures are ASN.1 encoded.
+func SignImage(r io.Reader, signer crypto.Signer) ([]byte, error) {
+	digest, err := imageDigest(r)
+	if err != nil {
+		return nil, err
+	}
+	switch signer.Public().(type) {
+	case ed25519.PublicKey:
+		return signer.Sign(rand.Reader, digest, crypto.Hash(0))
+	case *ecdsa.PublicKey:
+		return signer.Sign(rand.Reader, digest, crypto.SHA256)
+	default:
+		return nil, fmt.Errorf("unsupported signing key %T", signer.Public())
+	}
+}
+
+// VerifyImage checks a signature made by SignImage over the image read
+// from r.
+func VerifyImage(r io.Reader, sig []byte, pub crypto.PublicKey) error {
+	digest, err := imageDigest(r)
+	if err != nil {
+		return err
+	}
+	var ok bool
+	switch pub := pub.(type) {
+	case ed25519.PublicKey:
+		ok = ed25519.Verify(pub, digest, sig)
+	case *ecdsa.PublicKey:
+		ok = ecdsa.VerifyASN1(pub, digest, sig)
+	default:
+		return fmt.Errorf("unsupported public key %T", pub)
+	}
+	if !ok {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// VerifyImageFile checks path against the detached signature stored next
+// to it.
+func VerifyImageFile(path string, pub crypto.PublicKey) error {
+	sig, err := os.ReadFile(path + ImageSignatureExt)
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := VerifyImage(f, sig, pub); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+func imageDigest(r io.Reader) ([]byte, error) {
+	h := sha256.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return nil, fmt.Errorf("failed to hash image: %w", err)
+	}
+	return h.Sum(nil), nil
+}
@@ -0,0 +1,48 @@
+package types
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"errors"
+	"testing"
+)
+
+func TestSignImage(t *testing.T) {
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	image := []byte("firmware image")
+	for name, signer := range map[string]crypto.Signer{"ed25519": edKey, "ecdsa": ecKey} {
+		t.Run(name, func(t *testing.T) {
+			sig, err := SignImage(bytes.NewReader(image), signer)
+			if err != nil {
+				t.Fatalf("SignImage() error = %v", err)
+			}
+			if err := VerifyImage(bytes.NewReader(image), sig, signer.Public()); err != nil {
+				t.Errorf("VerifyImage() error = %v", err)
+			}
+			err = VerifyImage(bytes.NewReader([]byte("tampered image")), sig, signer.Public())
+			if !errors.Is(err, ErrInvalidSignature) {
+				t.Errorf("VerifyImage(tampered) error = %v, want %v", err, ErrInvalidSignature)
+			}
+		})
+	}
+
+	sig, err := SignImage(bytes.NewReader(image), edKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyImage(bytes.NewReader(image), sig, ecKey.Public()); err == nil {
+		t.Error("VerifyImage() accepted a signature made with another key")
+	}
+}
@@ -0,0 +1,92 @@
+package types
+
+import "time"
+
+// FirmwareInventory summarizes the firmware of every host in a data
+// directory.
+type FirmwareInventory struct {
+	Generated time.Time      `json:"generated" yaml:"generated"`
+	Hosts     []HostFirmware `json:"hosts" yaml:"hosts"`
+}
+
+// HostFirmware is the inventory entry of a single host.
+type HostFirmware struct {
+	MacAddress string `json:"macAddress" yaml:"macAddress"`
+	// Path is the variable file or firmware image the entry was read from.
+	Path            string `json:"path" yaml:"path"`
+	FirmwareVersion string `json:"firmwareVersion,omitempty" yaml:"firmwareVersion,omitempty"`
+	// BootOrder lists the names of the entries in the boot order.
+	BootOrder []string `json:"bootOrder,omitempty" yaml:"bootOrder,omitempty"`
+	// PXEEnabled and HTTPBootEnabled report whether an active entry of the
+	// category exists.
+	PXEEnabled      bool      `json:"pxeEnabled" yaml:"pxeEnabled"`
+	HTTPBootEnabled bool      `json:"httpBootEnabled" yaml:"httpBootEnabled"`
+	Modified        time.Time `json:"modified" yaml:"modified"`
+	// Error is set instead of the other fields when the host could not be
+	// read.
+	Error string `json:"error,omitempty" yaml:"error,omitempty"`
+}
+
+// BootOptionRecord is a Boot#### entry in a boot option report.
+type BootOptionRecord struct {
+	// MacAddress and Path identify the host when reporting on a data
+	// directory.
+	MacAddress string `json:"macAddress,omitempty" yaml:"macAddress,omitempty"`
+	Path       string `json:"path,omitempty" yaml:"path,omitempty"`
+	ID         string `json:"id,omitempty" yaml:"id,omitempty"`
+	Name       string `json:"name,omitempty" yaml:"name,omitempty"`
+	// Category is the device category of the entry, e.g. BootDevicePXE.
+	Category   string `json:"category,omitempty" yaml:"category,omitempty"`
+	DevicePath string `json:"devicePath,omitempty" yaml:"devicePath,omitempty"`
+	Active     bool   `json:"active" yaml:"active"`
+	// Position is the index of the entry in the boot order, or -1 if the
+	// entry is not in it.
+	Position int `json:"position" yaml:"position"`
+	// OptionalDataSize is the length of the optional data of the entry.
+	OptionalDataSize int `json:"optionalDataSize" yaml:"optionalDataSize"`
+	// Error is set instead of the entry fields for a host that could not
+	// be read.
+	Error string `json:"error,omitempty" yaml:"error,omitempty"`
+}
+
+// DHCPBootOptions sets the boot options of the DHCP reservations made for
+// an inventory.
+type DHCPBootOptions struct {
+	// NextServer is the IPv4 address of the TFTP server hosts boot from.
+	NextServer string `json:"nextServer" yaml:"nextServer"`
+	// TFTPBootFile is the boot file of hosts booting over TFTP. Empty
+	// leaves it to the DHCP server defaults.
+	TFTPBootFile string `json:"tftpBootFile,omitempty" yaml:"tftpBootFile,omitempty"`
+	// HTTPBootURL is the boot file of hosts with HTTP boot enabled, e.g.
+	// http://10.0.0.1/{mac}/RPI_EFI.fd. {mac} is replaced by the MAC
+	// address of the host in data directory notation, d8-3a-dd-01-02-03.
+	// Empty makes every host boot over TFTP.
+	HTTPBootURL string `json:"httpBootUrl,omitempty" yaml:"httpBootUrl,omitempty"`
+}
+
+// DHCPReservation is the DHCP host reservation of an inventory host.
+type DHCPReservation struct {
+	MacAddress string `json:"macAddress" yaml:"macAddress"`
+	// Name identifies the reservation in the DHCP server configuration,
+	// e.g. rpi-d83add010203.
+	Name       string `json:"name" yaml:"name"`
+	NextServer string `json:"nextServer,omitempty" yaml:"nextServer,omitempty"`
+	// BootFile is the boot file name, a URL for HTTP boot.
+	BootFile string `json:"bootFile,omitempty" yaml:"bootFile,omitempty"`
+	// HTTPBoot marks the reservation of an HTTP boot client, which only
+	// accepts offers with the HTTPClient vendor class.
+	HTTPBoot bool `json:"httpBoot" yaml:"httpBoot"`
+}
+
+// StaleHost is a MAC directory collected because it was not accessed
+// within the garbage collection TTL.
+type StaleHost struct {
+	MacAddress   string    `json:"macAddress" yaml:"macAddress"`
+	Path         string    `json:"path" yaml:"path"`
+	LastAccessed time.Time `json:"lastAccessed" yaml:"lastAccessed"`
+	// Archive is the tar.gz archive of the directory, empty for a dry run.
+	Archive string `json:"archive,omitempty" yaml:"archive,omitempty"`
+	// Error is set if the directory could not be archived or removed; it
+	// is kept then.
+	Error string `json:"error,omitempty" yaml:"error,omitempty"`
+}
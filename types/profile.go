@@ -0,0 +1,47 @@
+package types
+
+// Profile is a named set of firmware settings that can be applied to many
+// hosts. Nil and empty sections are left unchanged when it is applied.
+type Profile struct {
+	Name string `json:"name" yaml:"name"`
+	// Boot orders and disables boot entries by device category.
+	Boot *BootPolicy `json:"boot,omitempty" yaml:"boot,omitempty"`
+	// TimeoutSeconds is the boot menu timeout.
+	TimeoutSeconds *int             `json:"timeoutSeconds,omitempty" yaml:"timeoutSeconds,omitempty"`
+	Console        *ConsoleSettings `json:"console,omitempty" yaml:"console,omitempty"`
+	// Network is applied with SetNetworkSettings. An empty MacAddress
+	// applies it to whichever interface the manager targets.
+	Network *NetworkSettings `json:"network,omitempty" yaml:"network,omitempty"`
+	// Variables holds extra variables as hex encoded data, keyed by name or,
+	// for variables that may not exist yet, by "Name-GUID".
+	Variables map[string]string `json:"variables,omitempty" yaml:"variables,omitempty"`
+	// FirmwareVersion is the firmware version hosts of the profile must
+	// run. ApplyProfile does not update firmware; it is checked when
+	// firmware is served.
+	FirmwareVersion string `json:"firmwareVersion,omitempty" yaml:"firmwareVersion,omitempty"`
+}
+
+// BootPolicy describes the boot order in terms of device categories
+// (BootDevice*) rather than host specific entry IDs.
+type BootPolicy struct {
+	// Order lists device categories by priority. Entries of other
+	// categories follow in their current order.
+	Order []string `json:"order,omitempty" yaml:"order,omitempty"`
+	// Disabled lists device categories whose entries are deactivated.
+	// Entries of other categories keep their current state.
+	Disabled []string `json:"disabled,omitempty" yaml:"disabled,omitempty"`
+}
+
+// Console names accepted by ConsoleSettings.
+const (
+	ConsoleAuto     = "auto"
+	ConsoleSerial   = "serial"
+	ConsoleGraphics = "graphics"
+)
+
+// ConsoleSettings selects the firmware console.
+type ConsoleSettings struct {
+	Name string `json:"name" yaml:"name"`
+	// BaudRate is only used by the serial console.
+	BaudRate int `json:"baudRate,omitempty" yaml:"baudRate,omitempty"`
+}
@@ -0,0 +1,118 @@
+package types
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// Provisioning assigns profiles to hosts by MAC address, as read from a
+// provisioning file.
+type Provisioning struct {
+	Profiles []Profile `json:"profiles" yaml:"profiles"`
+	// Hosts assigns the profiles. A rule naming the MAC address of a host
+	// wins; otherwise the first matching pattern applies.
+	Hosts []HostAssignment `json:"hosts" yaml:"hosts"`
+}
+
+// HostAssignment assigns a profile to the hosts matching MacAddress.
+type HostAssignment struct {
+	// MacAddress is a MAC address, or the first bytes of one followed by
+	// "*" to match every host of a vendor, e.g. "d8:3a:dd:*".
+	MacAddress string `json:"macAddress" yaml:"macAddress"`
+	// Profile is the name of the profile.
+	Profile string `json:"profile" yaml:"profile"`
+}
+
+// Validate checks the profiles, that their names are unique and that every
+// host refers to one of them with a valid MAC address or pattern.
+func (p Provisioning) Validate() error {
+	v := &validator{}
+
+	names := make(map[string]bool, len(p.Profiles))
+	for i, profile := range p.Profiles {
+		field := fmt.Sprintf("profiles[%d]", i)
+		v.nested(field, profile.Validate())
+		if names[profile.Name] {
+			v.add(field+".name", "duplicate profile %q", profile.Name)
+		}
+		names[profile.Name] = true
+	}
+
+	for i, host := range p.Hosts {
+		field := fmt.Sprintf("hosts[%d]", i)
+		if _, _, err := parseMACPattern(host.MacAddress); err != nil {
+			v.add(field+".macAddress", "%w", err)
+		}
+		if !names[host.Profile] {
+			v.add(field+".profile", "unknown profile %q", host.Profile)
+		}
+	}
+
+	return v.err()
+}
+
+// ProfileFor returns the profile assigned to mac.
+func (p Provisioning) ProfileFor(mac net.HardwareAddr) (Profile, bool) {
+	name, found := "", false
+	for _, host := range p.Hosts {
+		prefix, exact, err := parseMACPattern(host.MacAddress)
+		if err != nil || !bytes.HasPrefix(mac, prefix) || exact && len(mac) != len(prefix) {
+			continue
+		}
+		if exact {
+			name, found = host.Profile, true
+			break
+		}
+		if !found {
+			name, found = host.Profile, true
+		}
+	}
+	if !found {
+		return Profile{}, false
+	}
+	for _, profile := range p.Profiles {
+		if profile.Name == name {
+			return profile, true
+		}
+	}
+	return Profile{}, false
+}
+
+// parseMACPattern parses a HostAssignment MAC address. exact is false for
+// patterns, which match every address starting with prefix.
+func parseMACPattern(s string) (prefix []byte, exact bool, err error) {
+	if pattern, ok := strings.CutSuffix(s, "*"); ok {
+		pattern = strings.TrimRight(pattern, ":-")
+		if pattern == "" {
+			return nil, false, fmt.Errorf("pattern %q matches every host", s)
+		}
+		for _, octet := range strings.Split(strings.ReplaceAll(pattern, "-", ":"), ":") {
+			b, err := hex.DecodeString(octet)
+			if err != nil || len(b) != 1 {
+				return nil, false, fmt.Errorf("invalid MAC address pattern %q", s)
+			}
+			prefix = append(prefix, b[0])
+		}
+		return prefix, false, nil
+	}
+	mac, err := net.ParseMAC(s)
+	if err != nil {
+		return nil, false, err
+	}
+	return mac, true, nil
+}
+
+// ReconcileResult is the outcome of reconciling the stored configuration
+// of one host with its profile.
+type ReconcileResult struct {
+	MacAddress string `json:"macAddress" yaml:"macAddress"`
+	Profile    string `json:"profile" yaml:"profile"`
+	// Changed lists the variables that differed from the profile, which
+	// were updated unless reconciling was a dry run.
+	Changed []string `json:"changed,omitempty" yaml:"changed,omitempty"`
+	// Error is set if the host could not be reconciled.
+	Error string `json:"error,omitempty" yaml:"error,omitempty"`
+}
@@ -0,0 +1,52 @@
+package types_test
+
+import (
+	"net"
+	"testing"
+
+	"github.com/metal3-community/uefi-firmware-manager/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProvisioning(t *testing.T) {
+	p := types.Provisioning{
+		Profiles: []types.Profile{{Name: "pi"}, {Name: "lab"}, {Name: "other"}},
+		Hosts: []types.HostAssignment{
+			{MacAddress: "d8:3a:dd:*", Profile: "pi"},
+			{MacAddress: "d8-3a-*", Profile: "other"},
+			{MacAddress: "d8:3a:dd:5a:44:36", Profile: "lab"},
+		},
+	}
+	assert.NoError(t, p.Validate())
+
+	for mac, want := range map[string]string{
+		"d8:3a:dd:5a:44:36": "lab",
+		"d8:3a:dd:00:00:01": "pi",
+		"d8:3a:00:00:00:01": "other",
+		"aa:bb:cc:dd:ee:ff": "",
+	} {
+		hw, err := net.ParseMAC(mac)
+		assert.NoError(t, err)
+		profile, found := p.ProfileFor(hw)
+		assert.Equal(t, want != "", found, mac)
+		assert.Equal(t, want, profile.Name, mac)
+	}
+
+	invalid := types.Provisioning{
+		Profiles: []types.Profile{{Name: "pi"}, {Name: "pi", TimeoutSeconds: new(int)}, {}},
+		Hosts: []types.HostAssignment{
+			{MacAddress: "*", Profile: "pi"},
+			{MacAddress: "d8::3a:*", Profile: "pi"},
+			{MacAddress: "d8:3a:dd:5a:44", Profile: "pi"},
+			{MacAddress: "d8:3a:dd:5a:44:36", Profile: "missing"},
+		},
+	}
+	assert.Equal(t, []string{
+		"profiles[1].name",
+		"profiles[2].name",
+		"hosts[0].macAddress",
+		"hosts[1].macAddress",
+		"hosts[2].macAddress",
+		"hosts[3].profile",
+	}, fields(invalid.Validate()))
+}
@@ -0,0 +1,22 @@
+package types
+
+// SaveReport describes what saving a firmware manager wrote.
+type SaveReport struct {
+	// Written reports whether the firmware was written. Saves without
+	// changes since the last save are skipped.
+	Written bool `json:"written" yaml:"written"`
+	// Added, Modified and Deleted name the variables changed since the
+	// last save, sorted.
+	Added    []string `json:"added,omitempty" yaml:"added,omitempty"`
+	Modified []string `json:"modified,omitempty" yaml:"modified,omitempty"`
+	Deleted  []string `json:"deleted,omitempty" yaml:"deleted,omitempty"`
+	// BytesUsed and Capacity are the space the variables occupy in the
+	// variable store and its size, or zero where not applicable.
+	BytesUsed int `json:"bytesUsed,omitempty" yaml:"bytesUsed,omitempty"`
+	Capacity  int `json:"capacity,omitempty" yaml:"capacity,omitempty"`
+}
+
+// Changed reports whether any variable changed.
+func (r SaveReport) Changed() bool {
+	return len(r.Added)+len(r.Modified)+len(r.Deleted) > 0
+}
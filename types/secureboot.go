@@ -0,0 +1,289 @@
+package types
+
+import (
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/metal3-community/uefi-firmware-manager/efi"
+)
+
+// SecureBootKey is a certificate or hash in a secure boot variable.
+type SecureBootKey struct {
+	// Type is the signature type GUID, e.g. efi.EfiCertX509 or
+	// efi.EfiCertSha256.
+	Type string `json:"type" yaml:"type"`
+	// Owner is the GUID of the key owner.
+	Owner string `json:"owner" yaml:"owner"`
+	// Data is the DER encoded certificate or the hash.
+	Data []byte `json:"data" yaml:"data"`
+}
+
+// SecureBootKeySet is the key material of the PK, KEK, db and dbx
+// variables. Empty lists leave the variable unchanged when enrolled.
+type SecureBootKeySet struct {
+	PK  []SecureBootKey `json:"pk,omitempty" yaml:"pk,omitempty"`
+	KEK []SecureBootKey `json:"kek,omitempty" yaml:"kek,omitempty"`
+	DB  []SecureBootKey `json:"db,omitempty" yaml:"db,omitempty"`
+	DBX []SecureBootKey `json:"dbx,omitempty" yaml:"dbx,omitempty"`
+}
+
+// ErrNoKeys is returned when a key file holds no certificates or hashes.
+var ErrNoKeys = errors.New("no keys found")
+
+// LoadSecureBootKeys reads keys from PEM certificates, a DER certificate
+// or EFI signature lists (ESL). owner is the owner GUID of PEM and DER
+// certificates; signature lists carry their own owners.
+func LoadSecureBootKeys(data []byte, owner string) ([]SecureBootKey, error) {
+	if block, _ := pem.Decode(data); block != nil {
+		return loadPEM(data, owner)
+	}
+	if cert, err := x509.ParseCertificate(data); err == nil {
+		return certKeys(owner, cert.Raw)
+	}
+	if len(data) > 0 {
+		if keys, err := ParseSignatureDatabase(data); err == nil {
+			return keys, nil
+		}
+	}
+	return nil, fmt.Errorf("not a PEM or DER certificate or an EFI signature list")
+}
+
+// LoadSecureBootKeyFile reads keys from a PEM, DER or ESL file.
+func LoadSecureBootKeyFile(path string, owner string) ([]SecureBootKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	keys, err := LoadSecureBootKeys(data, owner)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return keys, nil
+}
+
+func loadPEM(data []byte, owner string) ([]SecureBootKey, error) {
+	var certs [][]byte
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		if _, err := x509.ParseCertificate(block.Bytes); err != nil {
+			return nil, fmt.Errorf("invalid certificate: %w", err)
+		}
+		certs = append(certs, block.Bytes)
+	}
+	return certKeys(owner, certs...)
+}
+
+func certKeys(owner string, certs ...[]byte) ([]SecureBootKey, error) {
+	if len(certs) == 0 {
+		return nil, ErrNoKeys
+	}
+	if _, err := efi.ParseGUID(owner); err != nil {
+		return nil, fmt.Errorf("invalid owner GUID %q: %w", owner, err)
+	}
+	keys := make([]SecureBootKey, len(certs))
+	for i, cert := range certs {
+		keys[i] = SecureBootKey{Type: efi.EfiCertX509, Owner: owner, Data: cert}
+	}
+	return keys, nil
+}
+
+// ParseSignatureDatabase decodes the EFI signature lists of a secure boot
+// variable. It is the inverse of SignatureDatabase.
+func ParseSignatureDatabase(data []byte) ([]SecureBootKey, error) {
+	lists, err := efi.ParseSignatureLists(data)
+	if err != nil {
+		return nil, err
+	}
+	var keys []SecureBootKey
+	for _, list := range lists {
+		for _, sig := range list.Signatures {
+			keys = append(keys, SecureBootKey{
+				Type:  list.Type.String(),
+				Owner: sig.Owner.String(),
+				Data:  sig.Data,
+			})
+		}
+	}
+	if len(keys) == 0 {
+		return nil, ErrNoKeys
+	}
+	return keys, nil
+}
+
+// SignatureDatabase encodes keys as the EFI signature lists stored in a
+// secure boot variable. Certificates get one list each; hashes of the
+// same type share a list.
+func SignatureDatabase(keys []SecureBootKey) ([]byte, error) {
+	var lists []efi.SignatureList
+	hashLists := map[string]int{}
+	for i, key := range keys {
+		sigType, err := efi.ParseGUID(key.Type)
+		if err != nil {
+			return nil, fmt.Errorf("key %d: invalid type GUID %q: %w", i, key.Type, err)
+		}
+		owner, err := efi.ParseGUID(key.Owner)
+		if err != nil {
+			return nil, fmt.Errorf("key %d: invalid owner GUID %q: %w", i, key.Owner, err)
+		}
+		sig := efi.SignatureData{Owner: owner, Data: key.Data}
+
+		if sigType.String() == efi.EfiCertX509 {
+			lists = append(lists, efi.SignatureList{Type: sigType, Signatures: []efi.SignatureData{sig}})
+			continue
+		}
+		if idx, found := hashLists[sigType.String()]; found {
+			lists[idx].Signatures = append(lists[idx].Signatures, sig)
+			continue
+		}
+		hashLists[sigType.String()] = len(lists)
+		lists = append(lists, efi.SignatureList{Type: sigType, Signatures: []efi.SignatureData{sig}})
+	}
+	return efi.SerializeSignatureLists(lists)
+}
+
+// Validate checks that there is at most one platform key and that every
+// key has valid GUIDs and, for certificates, parses.
+func (s SecureBootKeySet) Validate() error {
+	v := &validator{}
+	if len(s.PK) > 1 {
+		v.add("pk", "has %d keys, at most one is allowed", len(s.PK))
+	}
+	sections := []struct {
+		field string
+		keys  []SecureBootKey
+	}{{"pk", s.PK}, {"kek", s.KEK}, {"db", s.DB}, {"dbx", s.DBX}}
+	for _, section := range sections {
+		for i, key := range section.keys {
+			name := fmt.Sprintf("%s[%d]", section.field, i)
+			sigType, err := efi.ParseGUID(key.Type)
+			if err != nil {
+				v.add(name+".type", "%w", err)
+			}
+			if _, err := efi.ParseGUID(key.Owner); err != nil {
+				v.add(name+".owner", "%w", err)
+			}
+			if sigType.String() == efi.EfiCertX509 {
+				if _, err := x509.ParseCertificate(key.Data); err != nil {
+					v.add(name+".data", "%w", err)
+				}
+			} else if len(key.Data) == 0 {
+				v.add(name+".data", "must not be empty")
+			}
+		}
+	}
+	return v.err()
+}
+
+// Weaknesses reported for enrolled certificates.
+const (
+	WeakExpired     = "expired"
+	WeakHash        = "weak-hash"
+	WeakKeySize     = "weak-key-size"
+	WeakTestKey     = "test-key"
+	WeakUnparseable = "unparseable"
+)
+
+// testKeyMarkers are subject fragments of the vendor test keys shipped in
+// production firmware (PKfail).
+var testKeyMarkers = []string{"DO NOT TRUST", "DO NOT SHIP"}
+
+// SecureBootReport summarizes the secure boot posture of a firmware.
+type SecureBootReport struct {
+	// SetupMode is true while no platform key is enrolled.
+	SetupMode bool `json:"setupMode" yaml:"setupMode"`
+	// SecureBoot is true if the firmware enforces secure boot: a platform
+	// key is enrolled and secure boot is enabled.
+	SecureBoot bool `json:"secureBoot" yaml:"secureBoot"`
+	// VendorKeys is true while the enrolled keys are the platform
+	// defaults.
+	VendorKeys   bool              `json:"vendorKeys" yaml:"vendorKeys"`
+	Certificates []CertificateInfo `json:"certificates,omitempty" yaml:"certificates,omitempty"`
+	// DBXHashCount is the number of revoked image hashes.
+	DBXHashCount int `json:"dbxHashCount" yaml:"dbxHashCount"`
+}
+
+// CertificateInfo describes an enrolled certificate.
+type CertificateInfo struct {
+	// Variable is the key variable holding the certificate: pk, kek, db
+	// or dbx.
+	Variable string    `json:"variable" yaml:"variable"`
+	Owner    string    `json:"owner" yaml:"owner"`
+	Subject  string    `json:"subject,omitempty" yaml:"subject,omitempty"`
+	Issuer   string    `json:"issuer,omitempty" yaml:"issuer,omitempty"`
+	NotAfter time.Time `json:"notAfter,omitzero" yaml:"notAfter,omitempty"`
+	// Weak lists the weaknesses (Weak*) of the certificate.
+	Weak []string `json:"weak,omitempty" yaml:"weak,omitempty"`
+}
+
+// NewSecureBootReport describes the key set ks. secureBootEnable and
+// vendorKeys are the values of the SecureBootEnable and VendorKeysNv
+// variables; certificates are checked for expiry at now.
+func NewSecureBootReport(ks SecureBootKeySet, secureBootEnable, vendorKeys bool, now time.Time) SecureBootReport {
+	r := SecureBootReport{
+		SetupMode:  len(ks.PK) == 0,
+		SecureBoot: len(ks.PK) > 0 && secureBootEnable,
+		VendorKeys: vendorKeys,
+	}
+	for _, section := range []struct {
+		name string
+		keys []SecureBootKey
+	}{{"pk", ks.PK}, {"kek", ks.KEK}, {"db", ks.DB}, {"dbx", ks.DBX}} {
+		for _, key := range section.keys {
+			if key.Type != efi.EfiCertX509 {
+				if section.name == "dbx" {
+					r.DBXHashCount++
+				}
+				continue
+			}
+			r.Certificates = append(r.Certificates, certificateInfo(section.name, key, now))
+		}
+	}
+	return r
+}
+
+func certificateInfo(variable string, key SecureBootKey, now time.Time) CertificateInfo {
+	info := CertificateInfo{Variable: variable, Owner: key.Owner}
+	cert, err := x509.ParseCertificate(key.Data)
+	if err != nil {
+		info.Weak = []string{WeakUnparseable}
+		return info
+	}
+	info.Subject = cert.Subject.String()
+	info.Issuer = cert.Issuer.String()
+	info.NotAfter = cert.NotAfter
+
+	// Revoked certificates are expected to be weak.
+	if variable == "dbx" {
+		return info
+	}
+	if now.After(cert.NotAfter) {
+		info.Weak = append(info.Weak, WeakExpired)
+	}
+	switch cert.SignatureAlgorithm {
+	case x509.MD5WithRSA, x509.SHA1WithRSA, x509.ECDSAWithSHA1, x509.DSAWithSHA1:
+		info.Weak = append(info.Weak, WeakHash)
+	}
+	if pub, ok := cert.PublicKey.(*rsa.PublicKey); ok && pub.N.BitLen() < 2048 {
+		info.Weak = append(info.Weak, WeakKeySize)
+	}
+	for _, marker := range testKeyMarkers {
+		if strings.Contains(strings.ToUpper(info.Subject), marker) {
+			info.Weak = append(info.Weak, WeakTestKey)
+			break
+		}
+	}
+	return info
+}
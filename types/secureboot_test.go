@@ -0,0 +1,139 @@
+package types_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/metal3-community/uefi-firmware-manager/efi"
+	"github.com/metal3-community/uefi-firmware-manager/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testOwner = "605dab50-e046-4300-abb6-3dd810dd8b23"
+
+// testCertificate returns a self-signed DER certificate.
+func testCertificate(t *testing.T, name string) []byte {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	return der
+}
+
+func TestLoadSecureBootKeys(t *testing.T) {
+	pk := testCertificate(t, "PK")
+	kek := testCertificate(t, "KEK")
+	pemData := append(
+		pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: pk}),
+		pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: kek})...)
+
+	// PEM, with several certificates
+	keys, err := types.LoadSecureBootKeys(pemData, testOwner)
+	require.NoError(t, err)
+	require.Len(t, keys, 2)
+	assert.Equal(t, types.SecureBootKey{Type: efi.EfiCertX509, Owner: testOwner, Data: pk}, keys[0])
+	assert.Equal(t, kek, keys[1].Data)
+
+	// DER
+	keys, err = types.LoadSecureBootKeys(pk, testOwner)
+	require.NoError(t, err)
+	assert.Equal(t, []types.SecureBootKey{{Type: efi.EfiCertX509, Owner: testOwner, Data: pk}}, keys)
+
+	// ESL, with a certificate and two hashes in one list
+	hash1, hash2 := sha256.Sum256([]byte("one")), sha256.Sum256([]byte("two"))
+	want := []types.SecureBootKey{
+		{Type: efi.EfiCertX509, Owner: testOwner, Data: pk},
+		{Type: efi.EfiCertSha256, Owner: efi.MicrosoftVendor, Data: hash1[:]},
+		{Type: efi.EfiCertSha256, Owner: efi.MicrosoftVendor, Data: hash2[:]},
+	}
+	esl, err := types.SignatureDatabase(want)
+	require.NoError(t, err)
+	lists, err := efi.ParseSignatureLists(esl)
+	require.NoError(t, err)
+	assert.Len(t, lists, 2)
+
+	path := filepath.Join(t.TempDir(), "db.esl")
+	require.NoError(t, os.WriteFile(path, esl, 0o644))
+	keys, err = types.LoadSecureBootKeyFile(path, "")
+	require.NoError(t, err)
+	assert.Equal(t, want, keys)
+
+	// Errors
+	_, err = types.LoadSecureBootKeys(pk, "not-a-guid")
+	assert.Error(t, err)
+	_, err = types.LoadSecureBootKeys(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: []byte{1}}), testOwner)
+	assert.ErrorIs(t, err, types.ErrNoKeys)
+	_, err = types.LoadSecureBootKeys([]byte("garbage"), testOwner)
+	assert.Error(t, err)
+}
+
+func TestSecureBootKeySet_Validate(t *testing.T) {
+	cert := testCertificate(t, "PK")
+	key := types.SecureBootKey{Type: efi.EfiCertX509, Owner: testOwner, Data: cert}
+
+	valid := types.SecureBootKeySet{PK: []types.SecureBootKey{key}, KEK: []types.SecureBootKey{key}}
+	assert.NoError(t, valid.Validate())
+
+	invalid := types.SecureBootKeySet{
+		PK:  []types.SecureBootKey{key, key},
+		DB:  []types.SecureBootKey{{Type: efi.EfiCertX509, Owner: "owner", Data: []byte{1}}},
+		DBX: []types.SecureBootKey{{Type: efi.EfiCertSha256, Owner: testOwner}},
+	}
+	assert.Equal(t,
+		[]string{"pk", "db[0].owner", "db[0].data", "dbx[0].data"},
+		fields(invalid.Validate()))
+}
+
+func TestNewSecureBootReport(t *testing.T) {
+	cert := func(der []byte) types.SecureBootKey {
+		return types.SecureBootKey{Type: efi.EfiCertX509, Owner: testOwner, Data: der}
+	}
+	hash := types.SecureBootKey{Type: efi.EfiCertSha256, Owner: testOwner, Data: make([]byte, 32)}
+	ks := types.SecureBootKeySet{
+		PK:  []types.SecureBootKey{cert(testCertificate(t, "DO NOT TRUST - AMI Test PK"))},
+		KEK: []types.SecureBootKey{cert(testCertificate(t, "KEK"))},
+		DB:  []types.SecureBootKey{cert(testCertificate(t, "db")), {Type: efi.EfiCertX509, Owner: testOwner, Data: []byte{1}}},
+		DBX: []types.SecureBootKey{hash, hash, cert(testCertificate(t, "revoked"))},
+	}
+
+	r := types.NewSecureBootReport(ks, true, false, time.Now())
+	assert.False(t, r.SetupMode)
+	assert.True(t, r.SecureBoot)
+	assert.False(t, r.VendorKeys)
+	assert.Equal(t, 2, r.DBXHashCount)
+	require.Len(t, r.Certificates, 5)
+	assert.Equal(t, "pk", r.Certificates[0].Variable)
+	assert.Equal(t, []string{types.WeakTestKey}, r.Certificates[0].Weak)
+	assert.Equal(t, "CN=KEK", r.Certificates[1].Subject)
+	assert.Empty(t, r.Certificates[1].Weak)
+	assert.Equal(t, []string{types.WeakUnparseable}, r.Certificates[3].Weak)
+	assert.Equal(t, "dbx", r.Certificates[4].Variable)
+
+	// Certificates expire.
+	r = types.NewSecureBootReport(ks, true, false, time.Now().Add(2*time.Hour))
+	assert.Contains(t, r.Certificates[1].Weak, types.WeakExpired)
+	assert.Empty(t, r.Certificates[4].Weak)
+
+	r = types.NewSecureBootReport(types.SecureBootKeySet{DB: ks.DB}, true, true, time.Now())
+	assert.True(t, r.SetupMode)
+	assert.False(t, r.SecureBoot)
+	assert.True(t, r.VendorKeys)
+}
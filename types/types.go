@@ -1,28 +1,272 @@
 // Package types contains common firmware related types and structures.
 package types
 
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"sort"
+	"strconv"
+	"strings"
+)
+
 // NetworkSettings contains network-related UEFI settings.
 type NetworkSettings struct {
-	MacAddress  string
-	IPAddress   string
-	SubnetMask  string
-	Gateway     string
-	DNSServers  []string
-	EnableIPv6  bool
-	EnableDHCP  bool
-	VLANEnabled bool
-	VLANID      string
+	MacAddress string `json:"macAddress" yaml:"macAddress"`
+	IPAddress  string `json:"ipAddress,omitempty" yaml:"ipAddress,omitempty"`
+	SubnetMask string `json:"subnetMask,omitempty" yaml:"subnetMask,omitempty"`
+	Gateway    string `json:"gateway,omitempty" yaml:"gateway,omitempty"`
+	// DNSServers are the IPv4 DNS servers.
+	DNSServers  []string `json:"dnsServers,omitempty" yaml:"dnsServers,omitempty"`
+	EnableIPv6  bool     `json:"enableIPv6" yaml:"enableIPv6"`
+	EnableDHCP  bool     `json:"enableDHCP" yaml:"enableDHCP"`
+	VLANEnabled bool     `json:"vlanEnabled" yaml:"vlanEnabled"`
+	VLANID      string   `json:"vlanId,omitempty" yaml:"vlanId,omitempty"`
+	// IPv6Address, IPv6PrefixLength and IPv6Gateway describe a static IPv6
+	// configuration. IPv6 autoconfiguration is used when IPv6Address is empty.
+	IPv6Address      string   `json:"ipv6Address,omitempty" yaml:"ipv6Address,omitempty"`
+	IPv6PrefixLength int      `json:"ipv6PrefixLength,omitempty" yaml:"ipv6PrefixLength,omitempty"`
+	IPv6Gateway      string   `json:"ipv6Gateway,omitempty" yaml:"ipv6Gateway,omitempty"`
+	IPv6DNSServers   []string `json:"ipv6DnsServers,omitempty" yaml:"ipv6DnsServers,omitempty"`
+	// HTTPBootURL is the URI of the HTTP boot entry.
+	HTTPBootURL string `json:"httpBootUrl,omitempty" yaml:"httpBootUrl,omitempty"`
+}
+
+// Boot entry categories, from the category bits of the load option
+// attributes.
+const (
+	BootCategoryBoot = "boot"
+	BootCategoryApp  = "app"
+)
+
+// Boot device categories, from the device path of a boot entry.
+const (
+	BootDevicePXE     = "pxe"
+	BootDeviceHTTP    = "httpboot"
+	BootDeviceDisk    = "disk"
+	BootDeviceUSB     = "usb"
+	BootDeviceShell   = "shell"
+	BootDeviceUiApp   = "uiapp"
+	BootDeviceUnknown = "unknown"
+)
+
+// DevicePathNode is a single node of a UEFI device path.
+type DevicePathNode struct {
+	Type    uint8 `json:"type" yaml:"type"`
+	SubType uint8 `json:"subType" yaml:"subType"`
+	// Text is the node in device path text notation, e.g. "IPv4()".
+	Text string `json:"text" yaml:"text"`
+	// Data is the hex encoded node data following the node header.
+	Data string `json:"data,omitempty" yaml:"data,omitempty"`
 }
 
 // BootEntry represents a single UEFI boot entry.
 type BootEntry struct {
-	ID       string
-	Name     string
-	DevPath  string
-	Enabled  bool
-	OptData  string
-	Position int
+	ID       string `json:"id" yaml:"id"`
+	Name     string `json:"name" yaml:"name"`
+	DevPath  string `json:"devPath" yaml:"devPath"`
+	Enabled  bool   `json:"enabled" yaml:"enabled"`
+	OptData  string `json:"optData,omitempty" yaml:"optData,omitempty"`
+	Position int    `json:"position" yaml:"position"`
+	// Category is BootCategoryBoot or BootCategoryApp. Empty keeps the
+	// current category when writing.
+	Category string `json:"category,omitempty" yaml:"category,omitempty"`
+	// DeviceCategory classifies the boot device, e.g. BootDeviceUSB. It is
+	// derived from the device path and ignored when writing.
+	DeviceCategory string `json:"deviceCategory,omitempty" yaml:"deviceCategory,omitempty"`
+	// DevicePath is DevPath split into nodes. When writing, it is only
+	// used if DevPath is empty.
+	DevicePath []DevicePathNode `json:"devicePath,omitempty" yaml:"devicePath,omitempty"`
+}
+
+// RAMLimit is the memory limit configured in the firmware.
+type RAMLimit uint8
+
+const (
+	// RAMLimitUnknown means the firmware has no RAM limit setting.
+	RAMLimitUnknown RAMLimit = iota
+	// RAMLimit3GB limits usable RAM to 3GB.
+	RAMLimit3GB
+	// RAMLimitNone allows RAM above 3GB.
+	RAMLimitNone
+)
+
+// ramLimitText are the RAMLimit values used in JSON and YAML.
+var ramLimitText = map[RAMLimit]string{
+	RAMLimit3GB:  "3GB",
+	RAMLimitNone: "none",
+}
+
+// MarshalText encodes the limit as "3GB" or "none".
+func (l RAMLimit) MarshalText() ([]byte, error) {
+	return []byte(ramLimitText[l]), nil
+}
+
+// UnmarshalText decodes a limit encoded by MarshalText.
+func (l *RAMLimit) UnmarshalText(text []byte) error {
+	if len(text) == 0 {
+		*l = RAMLimitUnknown
+		return nil
+	}
+	for limit, name := range ramLimitText {
+		if strings.EqualFold(string(text), name) {
+			*l = limit
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid RAM limit %q", text)
+}
+
+// String returns the description used by SystemInfo.ToMap.
+func (l RAMLimit) String() string {
+	switch l {
+	case RAMLimit3GB:
+		return "3GB or less"
+	case RAMLimitNone:
+		return "More than 3GB"
+	default:
+		return ""
+	}
+}
+
+// VariableNamespace summarizes the variables of one vendor GUID.
+type VariableNamespace struct {
+	Guid string `json:"guid" yaml:"guid"`
+	// Name is the well-known name of the GUID, if it has one.
+	Name  string `json:"name,omitempty" yaml:"name,omitempty"`
+	Count int    `json:"count" yaml:"count"`
+	// Bytes is the total size of the names and data of the variables,
+	// without the headers of the store holding them.
+	Bytes int `json:"bytes" yaml:"bytes"`
 }
 
 // SystemInfo contains firmware and system information.
-type SystemInfo map[string]string
+type SystemInfo struct {
+	FirmwareVersion string `json:"firmwareVersion,omitempty" yaml:"firmwareVersion,omitempty"`
+	AssetTag        string `json:"assetTag,omitempty" yaml:"assetTag,omitempty"`
+	// TimeoutSeconds is the boot menu timeout, or nil if the firmware has
+	// no Timeout variable.
+	TimeoutSeconds *int `json:"timeoutSeconds,omitempty" yaml:"timeoutSeconds,omitempty"`
+	// CPUClock is the CpuClock setting (0 low, 1 default, 2 max, 3 custom),
+	// or nil if the firmware has none.
+	CPUClock *uint32 `json:"cpuClock,omitempty" yaml:"cpuClock,omitempty"`
+	// CPUClockMHz is the custom CPU clock. It is 0 unless CPUClock is custom.
+	CPUClockMHz uint32   `json:"cpuClockMHz,omitempty" yaml:"cpuClockMHz,omitempty"`
+	RAMLimit    RAMLimit `json:"ramLimit,omitempty" yaml:"ramLimit,omitempty"`
+	// SystemTableMode is the SystemTableMode setting (0 ACPI, 1 ACPI and
+	// device tree, 2 device tree), or nil if the firmware has none.
+	SystemTableMode *uint32 `json:"systemTableMode,omitempty" yaml:"systemTableMode,omitempty"`
+	// BoardModel, Compatible and MemorySize come from the device tree when
+	// exactly one is installed next to the firmware.
+	BoardModel string   `json:"boardModel,omitempty" yaml:"boardModel,omitempty"`
+	Compatible []string `json:"compatible,omitempty" yaml:"compatible,omitempty"`
+	MemorySize uint64   `json:"memorySize,omitempty" yaml:"memorySize,omitempty"`
+	// DeviceTrees maps each installed device tree file to its model when
+	// there are several.
+	DeviceTrees map[string]string `json:"deviceTrees,omitempty" yaml:"deviceTrees,omitempty"`
+}
+
+// ToMap returns the information as string values, keyed as they were
+// before SystemInfo was a struct. Unset fields are omitted.
+func (s SystemInfo) ToMap() map[string]string {
+	m := map[string]string{}
+	if s.FirmwareVersion != "" {
+		m["FirmwareVersion"] = s.FirmwareVersion
+	}
+	if s.AssetTag != "" {
+		m["AssetTag"] = s.AssetTag
+	}
+	if s.TimeoutSeconds != nil {
+		m["Timeout"] = strconv.Itoa(*s.TimeoutSeconds)
+	}
+	if s.CPUClock != nil {
+		m["CpuClock"] = strconv.FormatUint(uint64(*s.CPUClock), 10)
+	}
+	if s.CPUClockMHz != 0 {
+		m["CustomCpuClock"] = strconv.FormatUint(uint64(s.CPUClockMHz), 10)
+	}
+	if s.RAMLimit != RAMLimitUnknown {
+		m["RAM"] = s.RAMLimit.String()
+	}
+	if s.SystemTableMode != nil {
+		m["SystemTableMode"] = strconv.FormatUint(uint64(*s.SystemTableMode), 10)
+	}
+	if s.BoardModel != "" {
+		m["Model"] = s.BoardModel
+	}
+	if len(s.Compatible) > 0 {
+		m["Compatible"] = strings.Join(s.Compatible, ",")
+	}
+	if s.MemorySize != 0 {
+		m["MemorySize"] = strconv.FormatUint(s.MemorySize, 10)
+	}
+	if len(s.DeviceTrees) > 0 {
+		files := make([]string, 0, len(s.DeviceTrees))
+		for file := range s.DeviceTrees {
+			files = append(files, file)
+		}
+		sort.Strings(files)
+		trees := make([]string, len(files))
+		for i, file := range files {
+			trees[i] = file + "=" + s.DeviceTrees[file]
+		}
+		m["DeviceTrees"] = strings.Join(trees, ";")
+	}
+	return m
+}
+
+// FirmwareSource describes where to download a firmware release from.
+type FirmwareSource struct {
+	Name string `json:"name,omitempty" yaml:"name,omitempty"`
+	URL  string `json:"url" yaml:"url"`
+	// Checksum is the expected digest of the downloaded file as
+	// "<algorithm>:<hex>", with algorithm sha256 or sha512. Empty skips
+	// verification.
+	Checksum string `json:"checksum,omitempty" yaml:"checksum,omitempty"`
+	// SignatureURL points at a detached signature for the downloaded file,
+	// checked with VerifyImage against the key of the fetcher.
+	SignatureURL string `json:"signatureUrl,omitempty" yaml:"signatureUrl,omitempty"`
+	// Headers are added to every request, e.g. for authentication.
+	Headers map[string]string `json:"headers,omitempty" yaml:"headers,omitempty"`
+	// Timeout bounds the whole download, e.g. "5m". Zero means no
+	// timeout.
+	Timeout Duration `json:"timeout,omitempty" yaml:"timeout,omitempty"`
+	// ExtractSubdir selects the directory of an archive to install from.
+	// Empty uses the archive root.
+	ExtractSubdir string `json:"extractSubdir,omitempty" yaml:"extractSubdir,omitempty"`
+}
+
+// ErrChecksumMismatch is returned when downloaded data does not match the
+// source checksum.
+var ErrChecksumMismatch = errors.New("checksum mismatch")
+
+// VerifyChecksum checks data against the source checksum.
+func (s FirmwareSource) VerifyChecksum(data []byte) error {
+	if s.Checksum == "" {
+		return nil
+	}
+
+	algorithm, want, ok := strings.Cut(s.Checksum, ":")
+	if !ok {
+		return fmt.Errorf("invalid checksum %q: expected <algorithm>:<hex>", s.Checksum)
+	}
+
+	var h hash.Hash
+	switch strings.ToLower(algorithm) {
+	case "sha256":
+		h = sha256.New()
+	case "sha512":
+		h = sha512.New()
+	default:
+		return fmt.Errorf("unsupported checksum algorithm %q", algorithm)
+	}
+	h.Write(data)
+
+	if got := hex.EncodeToString(h.Sum(nil)); !strings.EqualFold(got, want) {
+		return fmt.Errorf("%w: got %s:%s, want %s", ErrChecksumMismatch, algorithm, got, want)
+	}
+	return nil
+}
@@ -1,30 +1,48 @@
 package types_test
 
 import (
+	"encoding/json"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/metal3-community/uefi-firmware-manager/types"
 	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v3"
 )
 
 func TestSystemInfo(t *testing.T) {
-	// Test basic map operations
-	info := types.SystemInfo{}
-
-	// Test setting and getting values
-	info["FirmwareVersion"] = "1.0.0"
-	info["PlatformName"] = "RaspberryPi"
-
-	assert.Equal(t, "1.0.0", info["FirmwareVersion"])
-	assert.Equal(t, "RaspberryPi", info["PlatformName"])
+	clock, mode, timeout := uint32(3), uint32(0), 5
+	info := types.SystemInfo{
+		FirmwareVersion: "1.0.0",
+		TimeoutSeconds:  &timeout,
+		CPUClock:        &clock,
+		CPUClockMHz:     1800,
+		RAMLimit:        types.RAMLimitNone,
+		SystemTableMode: &mode,
+		BoardModel:      "Raspberry Pi 4 Model B",
+		Compatible:      []string{"raspberrypi,4-model-b", "brcm,bcm2711"},
+		DeviceTrees: map[string]string{
+			"b.dtb": "Board B",
+			"a.dtb": "Board A",
+		},
+	}
 
-	// Test map length
-	assert.Len(t, info, 2)
+	assert.Equal(t, map[string]string{
+		"FirmwareVersion": "1.0.0",
+		"Timeout":         "5",
+		"CpuClock":        "3",
+		"CustomCpuClock":  "1800",
+		"RAM":             "More than 3GB",
+		"SystemTableMode": "0",
+		"Model":           "Raspberry Pi 4 Model B",
+		"Compatible":      "raspberrypi,4-model-b,brcm,bcm2711",
+		"DeviceTrees":     "a.dtb=Board A;b.dtb=Board B",
+	}, info.ToMap())
 
-	// Test deleting an entry
-	delete(info, "FirmwareVersion")
-	assert.Len(t, info, 1)
-	assert.Empty(t, info["FirmwareVersion"])
+	// Unset fields are omitted
+	assert.Empty(t, types.SystemInfo{}.ToMap())
+	assert.Equal(t, "3GB or less", types.RAMLimit3GB.String())
 }
 
 func TestNetworkSettings(t *testing.T) {
@@ -59,6 +77,10 @@ func TestBootEntry(t *testing.T) {
 		Enabled:  true,
 		OptData:  "0102030405",
 		Position: 0,
+		Category: types.BootCategoryBoot,
+		DevicePath: []types.DevicePathNode{
+			{Type: 0x03, SubType: 0x0c, Text: "IPv4()", Data: ""},
+		},
 	}
 
 	assert.Equal(t, "0001", entry.ID)
@@ -67,4 +89,102 @@ func TestBootEntry(t *testing.T) {
 	assert.True(t, entry.Enabled)
 	assert.Equal(t, "0102030405", entry.OptData)
 	assert.Equal(t, 0, entry.Position)
+	assert.Equal(t, "boot", entry.Category)
+	assert.Equal(t, "IPv4()", entry.DevicePath[0].Text)
+}
+
+func TestFirmwareSource_VerifyChecksum(t *testing.T) {
+	data := []byte("firmware")
+	// sha256 of "firmware"
+	sum := "sha256:c3bf47ea1f4a4a605470313cacb3a44f4a461f68c6faeab07e737610cb5ac835"
+
+	tests := []struct {
+		name     string
+		checksum string
+		wantErr  bool
+	}{
+		{name: "no checksum", checksum: ""},
+		{name: "match", checksum: sum},
+		{name: "match uppercase", checksum: strings.ToUpper(sum)},
+		{name: "mismatch", checksum: "sha256:" + strings.Repeat("0", 64), wantErr: true},
+		{name: "unsupported algorithm", checksum: "md5:00", wantErr: true},
+		{name: "malformed", checksum: "deadbeef", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src := types.FirmwareSource{URL: "https://example.com/fw.zip", Checksum: tt.checksum}
+			err := src.VerifyChecksum(data)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestNetworkSettings_JSON(t *testing.T) {
+	settings := types.NetworkSettings{
+		MacAddress: "01:02:03:04:05:06",
+		EnableDHCP: true,
+		DNSServers: []string{"8.8.8.8"},
+	}
+
+	data, err := json.Marshal(settings)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{
+		"macAddress": "01:02:03:04:05:06",
+		"dnsServers": ["8.8.8.8"],
+		"enableIPv6": false,
+		"enableDHCP": true,
+		"vlanEnabled": false
+	}`, string(data))
+}
+
+func TestSystemInfo_Marshal(t *testing.T) {
+	clock := uint32(1)
+	info := types.SystemInfo{
+		FirmwareVersion: "1.0.0",
+		CPUClock:        &clock,
+		RAMLimit:        types.RAMLimit3GB,
+	}
+
+	data, err := json.Marshal(info)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"firmwareVersion":"1.0.0","cpuClock":1,"ramLimit":"3GB"}`, string(data))
+
+	var decoded types.SystemInfo
+	assert.NoError(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, info, decoded)
+
+	data, err = yaml.Marshal(info)
+	assert.NoError(t, err)
+	assert.Equal(t, "firmwareVersion: 1.0.0\ncpuClock: 1\nramLimit: 3GB\n", string(data))
+
+	decoded = types.SystemInfo{}
+	assert.NoError(t, yaml.Unmarshal(data, &decoded))
+	assert.Equal(t, info, decoded)
+
+	assert.Error(t, json.Unmarshal([]byte(`{"ramLimit":"4GB"}`), &decoded))
+}
+
+func TestFirmwareSource_Timeout(t *testing.T) {
+	var src types.FirmwareSource
+	assert.NoError(t, json.Unmarshal([]byte(`{"url":"https://example.com/fw.zip","timeout":"30s"}`), &src))
+	assert.Equal(t, types.Duration(30*time.Second), src.Timeout)
+	data, err := json.Marshal(src)
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), `"timeout":"30s"`)
+
+	src = types.FirmwareSource{}
+	assert.NoError(t, yaml.Unmarshal([]byte("url: https://example.com/fw.zip\ntimeout: 5m\n"), &src))
+	assert.Equal(t, types.Duration(5*time.Minute), src.Timeout)
+	data, err = yaml.Marshal(src)
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), "timeout: 5m0s")
+
+	// Bare numbers would be nanoseconds; they are refused.
+	assert.Error(t, json.Unmarshal([]byte(`{"timeout":30}`), &src))
+	assert.Error(t, yaml.Unmarshal([]byte("timeout: 30\n"), &src))
+	assert.Error(t, json.Unmarshal([]byte(`{"timeout":"soon"}`), &src))
 }
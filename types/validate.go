@@ -0,0 +1,224 @@
+package types
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/metal3-community/uefi-firmware-manager/efi"
+)
+
+// FieldError reports an invalid field. Field is the JSON name of the
+// field, with nested fields separated by dots.
+type FieldError struct {
+	Field string
+	Err   error
+}
+
+func (e *FieldError) Error() string {
+	return e.Field + ": " + e.Err.Error()
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// validator collects field errors.
+type validator struct {
+	errs []error
+}
+
+func (v *validator) add(field string, format string, args ...any) {
+	v.errs = append(v.errs, &FieldError{Field: field, Err: fmt.Errorf(format, args...)})
+}
+
+// nested adds the errors of a nested structure under field.
+func (v *validator) nested(field string, err error) {
+	if err == nil {
+		return
+	}
+	for _, e := range unjoin(err) {
+		var fe *FieldError
+		if errors.As(e, &fe) {
+			v.errs = append(v.errs, &FieldError{Field: field + "." + fe.Field, Err: fe.Err})
+		} else {
+			v.errs = append(v.errs, &FieldError{Field: field, Err: e})
+		}
+	}
+}
+
+func (v *validator) err() error {
+	return errors.Join(v.errs...)
+}
+
+func (v *validator) hex(field, value string) {
+	if _, err := hex.DecodeString(value); err != nil {
+		v.add(field, "invalid hex data: %w", err)
+	}
+}
+
+// ip checks that value is empty or an address of the given family.
+func (v *validator) ip(field, value string, ipv4 bool) {
+	if value == "" {
+		return
+	}
+	ip := net.ParseIP(value)
+	switch {
+	case ip == nil:
+		v.add(field, "invalid IP address %q", value)
+	case ipv4 && ip.To4() == nil:
+		v.add(field, "%q is not an IPv4 address", value)
+	case !ipv4 && ip.To4() != nil:
+		v.add(field, "%q is not an IPv6 address", value)
+	}
+}
+
+func unjoin(err error) []error {
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		return joined.Unwrap()
+	}
+	return []error{err}
+}
+
+// Validate checks that the entry can be written to a boot variable. An
+// empty ID lets the manager choose one.
+func (e BootEntry) Validate() error {
+	v := &validator{}
+
+	if e.ID != "" {
+		if len(e.ID) > 4 {
+			v.add("id", "%q is longer than 4 hex digits", e.ID)
+		} else if _, err := strconv.ParseUint(e.ID, 16, 16); err != nil {
+			v.add("id", "%q is not a hex number", e.ID)
+		}
+	}
+
+	if e.DevPath != "" {
+		if _, err := efi.ParseDevicePathFromString(e.DevPath); err != nil {
+			v.add("devPath", "%w", err)
+		}
+	}
+	for i, node := range e.DevicePath {
+		v.hex(fmt.Sprintf("devicePath[%d].data", i), node.Data)
+	}
+
+	v.hex("optData", e.OptData)
+
+	if e.Category != "" && e.Category != BootCategoryBoot && e.Category != BootCategoryApp {
+		v.add("category", "unknown category %q", e.Category)
+	}
+
+	return v.err()
+}
+
+// Validate checks addresses, VLAN and URL fields. Empty fields are valid.
+func (s NetworkSettings) Validate() error {
+	v := &validator{}
+
+	if s.MacAddress != "" {
+		if _, err := net.ParseMAC(s.MacAddress); err != nil {
+			v.add("macAddress", "%w", err)
+		}
+	}
+
+	v.ip("ipAddress", s.IPAddress, true)
+	v.ip("subnetMask", s.SubnetMask, true)
+	v.ip("gateway", s.Gateway, true)
+	for i, dns := range s.DNSServers {
+		v.ip(fmt.Sprintf("dnsServers[%d]", i), dns, true)
+	}
+
+	// VLAN 0 is what the firmware reports while VLANs are disabled.
+	if s.VLANID != "" || s.VLANEnabled {
+		minID := 0
+		if s.VLANEnabled {
+			minID = 1
+		}
+		id, err := strconv.Atoi(s.VLANID)
+		if err != nil || id < minID || id > 4094 {
+			v.add("vlanId", "%q is not a VLAN ID between %d and 4094", s.VLANID, minID)
+		}
+	}
+
+	v.ip("ipv6Address", s.IPv6Address, false)
+	if s.IPv6PrefixLength < 0 || s.IPv6PrefixLength > 128 {
+		v.add("ipv6PrefixLength", "%d is not between 0 and 128", s.IPv6PrefixLength)
+	}
+	v.ip("ipv6Gateway", s.IPv6Gateway, false)
+	for i, dns := range s.IPv6DNSServers {
+		v.ip(fmt.Sprintf("ipv6DnsServers[%d]", i), dns, false)
+	}
+
+	if s.HTTPBootURL != "" {
+		u, err := url.Parse(s.HTTPBootURL)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			v.add("httpBootUrl", "%q is not an absolute URL", s.HTTPBootURL)
+		}
+	}
+
+	return v.err()
+}
+
+// bootDeviceCategories are the categories a BootPolicy may name.
+var bootDeviceCategories = []string{
+	BootDevicePXE, BootDeviceHTTP, BootDeviceDisk, BootDeviceUSB,
+	BootDeviceShell, BootDeviceUiApp, BootDeviceUnknown,
+}
+
+// Validate checks every section of the profile that is set.
+func (p Profile) Validate() error {
+	v := &validator{}
+
+	if strings.TrimSpace(p.Name) == "" {
+		v.add("name", "must not be empty")
+	}
+
+	if p.Boot != nil {
+		for i, category := range p.Boot.Order {
+			if !slices.Contains(bootDeviceCategories, category) {
+				v.add(fmt.Sprintf("boot.order[%d]", i), "unknown device category %q", category)
+			}
+		}
+		for i, category := range p.Boot.Disabled {
+			if !slices.Contains(bootDeviceCategories, category) {
+				v.add(fmt.Sprintf("boot.disabled[%d]", i), "unknown device category %q", category)
+			}
+		}
+	}
+
+	if p.TimeoutSeconds != nil && (*p.TimeoutSeconds < 0 || *p.TimeoutSeconds > 0xffff) {
+		v.add("timeoutSeconds", "%d is not between 0 and 65535", *p.TimeoutSeconds)
+	}
+
+	if p.Console != nil {
+		switch p.Console.Name {
+		case ConsoleAuto, ConsoleSerial, ConsoleGraphics:
+		default:
+			v.add("console.name", "unknown console %q", p.Console.Name)
+		}
+		if p.Console.BaudRate < 0 {
+			v.add("console.baudRate", "must not be negative")
+		}
+	}
+
+	if p.Network != nil {
+		v.nested("network", p.Network.Validate())
+	}
+
+	names := make([]string, 0, len(p.Variables))
+	for name := range p.Variables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		v.hex("variables."+name, p.Variables[name])
+	}
+
+	return v.err()
+}
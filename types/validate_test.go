@@ -0,0 +1,113 @@
+package types_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/metal3-community/uefi-firmware-manager/types"
+	"github.com/stretchr/testify/assert"
+)
+
+// fields returns the field names of the errors returned by Validate.
+func fields(err error) []string {
+	if err == nil {
+		return nil
+	}
+	var names []string
+	for _, e := range err.(interface{ Unwrap() []error }).Unwrap() {
+		var fe *types.FieldError
+		if errors.As(e, &fe) {
+			names = append(names, fe.Field)
+		}
+	}
+	return names
+}
+
+func TestBootEntry_Validate(t *testing.T) {
+	valid := types.BootEntry{
+		ID:       "000A",
+		Name:     "Network",
+		DevPath:  "MAC()/IPv4()",
+		OptData:  "4eac",
+		Category: types.BootCategoryApp,
+	}
+	assert.NoError(t, valid.Validate())
+	assert.NoError(t, types.BootEntry{Name: "no ID"}.Validate())
+
+	invalid := types.BootEntry{
+		ID:         "Boot0001",
+		DevPath:    "NotANode(",
+		OptData:    "xyz",
+		Category:   "firmware",
+		DevicePath: []types.DevicePathNode{{Type: 3, SubType: 0x0b, Data: "0"}},
+	}
+	assert.Equal(t,
+		[]string{"id", "devPath", "devicePath[0].data", "optData", "category"},
+		fields(invalid.Validate()))
+}
+
+func TestNetworkSettings_Validate(t *testing.T) {
+	valid := types.NetworkSettings{
+		MacAddress:       "d8:3a:dd:5a:44:36",
+		IPAddress:        "192.168.1.50",
+		SubnetMask:       "255.255.255.0",
+		Gateway:          "192.168.1.1",
+		DNSServers:       []string{"192.168.1.53"},
+		VLANEnabled:      true,
+		VLANID:           "100",
+		IPv6Address:      "2001:db8::50",
+		IPv6PrefixLength: 64,
+		IPv6DNSServers:   []string{"2001:db8::53"},
+		HTTPBootURL:      "http://192.168.1.10/boot.efi",
+	}
+	assert.NoError(t, valid.Validate())
+	// The firmware reports VLAN 0 while VLANs are disabled.
+	assert.NoError(t, types.NetworkSettings{VLANID: "0"}.Validate())
+
+	invalid := types.NetworkSettings{
+		MacAddress:       "d8:3a:dd",
+		IPAddress:        "2001:db8::50",
+		DNSServers:       []string{"8.8.8.8", "dns"},
+		VLANEnabled:      true,
+		VLANID:           "0",
+		IPv6Address:      "192.168.1.50",
+		IPv6PrefixLength: 129,
+		HTTPBootURL:      "boot.efi",
+	}
+	assert.Equal(t,
+		[]string{
+			"macAddress", "ipAddress", "dnsServers[1]", "vlanId",
+			"ipv6Address", "ipv6PrefixLength", "httpBootUrl",
+		},
+		fields(invalid.Validate()))
+}
+
+func TestProfile_Validate(t *testing.T) {
+	timeout := 5
+	valid := types.Profile{
+		Name:           "netboot",
+		Boot:           &types.BootPolicy{Order: []string{types.BootDeviceHTTP}},
+		TimeoutSeconds: &timeout,
+		Console:        &types.ConsoleSettings{Name: types.ConsoleSerial, BaudRate: 115200},
+		Network:        &types.NetworkSettings{EnableDHCP: true},
+		Variables:      map[string]string{"CpuClock": "01000000"},
+	}
+	assert.NoError(t, valid.Validate())
+
+	timeout = 70000
+	invalid := types.Profile{
+		Boot:           &types.BootPolicy{Disabled: []string{"floppy"}},
+		TimeoutSeconds: &timeout,
+		Console:        &types.ConsoleSettings{Name: "vga"},
+		Network:        &types.NetworkSettings{Gateway: "gw"},
+		Variables:      map[string]string{"CpuClock": "1"},
+	}
+	err := invalid.Validate()
+	assert.Equal(t,
+		[]string{
+			"name", "boot.disabled[0]", "timeoutSeconds", "console.name",
+			"network.gateway", "variables.CpuClock",
+		},
+		fields(err))
+	assert.ErrorContains(t, err, `network.gateway: invalid IP address "gw"`)
+}
@@ -0,0 +1,20 @@
+package types
+
+// FirmwareBuild is the version information found in a firmware image.
+type FirmwareBuild struct {
+	// Version is the firmware version string, as reported through SMBIOS,
+	// e.g. "UEFI Firmware v1.38".
+	Version string `json:"version,omitempty" yaml:"version,omitempty"`
+	// BuildID is the git description of the build in Version, e.g.
+	// "v1.38" or "v0.0.2-62-gc1c9118".
+	BuildID string `json:"buildId,omitempty" yaml:"buildId,omitempty"`
+	// Release is the release tag the build is based on, e.g. "v1.38".
+	Release string `json:"release,omitempty" yaml:"release,omitempty"`
+	// Commit is the abbreviated commit of builds made after Release.
+	Commit string `json:"commit,omitempty" yaml:"commit,omitempty"`
+	// Dirty is set for builds of a modified source tree.
+	Dirty bool `json:"dirty,omitempty" yaml:"dirty,omitempty"`
+	// TrustedFirmware is the version of the bundled Trusted Firmware-A,
+	// e.g. "v2.9(release):v2.9".
+	TrustedFirmware string `json:"trustedFirmware,omitempty" yaml:"trustedFirmware,omitempty"`
+}
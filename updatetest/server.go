@@ -0,0 +1,263 @@
+// Package updatetest serves synthetic firmware releases over HTTP, so
+// firmware download and update flows can be tested against realistic
+// server behavior without network access.
+package updatetest
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/metal3-community/uefi-firmware-manager/testutil"
+	"github.com/metal3-community/uefi-firmware-manager/types"
+)
+
+// Behavior controls how Server serves a file.
+type Behavior struct {
+	// Status, if set, is returned instead of the file, e.g.
+	// http.StatusNotFound or http.StatusServiceUnavailable.
+	Status int
+	// Corrupt flips a byte of the served data. The returned source keeps
+	// the checksum of the original data, so verification fails.
+	Corrupt bool
+	// ChunkSize and Delay throttle the body: ChunkSize bytes are written
+	// every Delay. A zero ChunkSize writes the body at once.
+	ChunkSize int
+	Delay     time.Duration
+	// FailAfter aborts the first response after FailAfter bytes of the
+	// body, as a dropped connection would. Later requests succeed, so
+	// clients can resume with a Range request. Zero never fails.
+	FailAfter int
+	// NoRanges ignores Range requests and always serves the whole file.
+	NoRanges bool
+	// Signature, if set, is served as the detached signature of the file
+	// at the name with ".sig" appended.
+	Signature []byte
+}
+
+// Server is an HTTP server of firmware releases. Files are served at
+// their name, with range requests and an ETag.
+type Server struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	files    map[string]*servedFile
+	requests map[string][]*http.Request
+}
+
+type servedFile struct {
+	data     []byte
+	behavior Behavior
+	etag     string
+	failed   bool
+}
+
+// NewServer starts a server that is closed when the test ends.
+func NewServer(tb testing.TB) *Server {
+	s := &Server{
+		files:    map[string]*servedFile{},
+		requests: map[string][]*http.Request{},
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	tb.Cleanup(s.Close)
+	return s
+}
+
+// Serve serves data at name with behavior b and returns the source of the
+// file, including its checksum.
+func (s *Server) Serve(name string, data []byte, b Behavior) types.FirmwareSource {
+	name = strings.TrimPrefix(name, "/")
+	sum := sha256.Sum256(data)
+	served := bytes.Clone(data)
+	if b.Corrupt && len(served) > 0 {
+		served[len(served)/2] ^= 0xff
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.files[name] = &servedFile{data: served, behavior: b, etag: `"` + hex.EncodeToString(sum[:8]) + `"`}
+	src := types.FirmwareSource{
+		Name:     name,
+		URL:      s.URL + "/" + name,
+		Checksum: "sha256:" + hex.EncodeToString(sum[:]),
+	}
+	if b.Signature != nil {
+		s.files[name+".sig"] = &servedFile{data: bytes.Clone(b.Signature)}
+		src.SignatureURL = src.URL + ".sig"
+	}
+	return src
+}
+
+// File serves data at name.
+func (s *Server) File(name string, data []byte) types.FirmwareSource {
+	return s.Serve(name, data, Behavior{})
+}
+
+// Zip serves files as a zip archive at name.
+func (s *Server) Zip(name string, files map[string][]byte) types.FirmwareSource {
+	return s.Serve(name, ZipArchive(files), Behavior{})
+}
+
+// TarGz serves files as a gzip compressed tar archive at name.
+func (s *Server) TarGz(name string, files map[string][]byte) types.FirmwareSource {
+	return s.Serve(name, TarGzArchive(files), Behavior{})
+}
+
+// Requests returns the requests received for name, oldest first.
+func (s *Server) Requests(name string) []*http.Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*http.Request(nil), s.requests[strings.TrimPrefix(name, "/")]...)
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, "/")
+	s.mu.Lock()
+	s.requests[name] = append(s.requests[name], r.Clone(r.Context()))
+	f, ok := s.files[name]
+	var failAfter int
+	if ok && f.behavior.FailAfter > 0 && !f.failed {
+		f.failed = true
+		failAfter = f.behavior.FailAfter
+	}
+	s.mu.Unlock()
+
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	b := f.behavior
+	if b.Status != 0 {
+		http.Error(w, http.StatusText(b.Status), b.Status)
+		return
+	}
+	if b.NoRanges {
+		r.Header.Del("Range")
+		r.Header.Del("If-Range")
+	} else {
+		w.Header().Set("ETag", f.etag)
+	}
+	if b.ChunkSize > 0 || failAfter > 0 {
+		w = &throttledWriter{ResponseWriter: w, chunk: b.ChunkSize, delay: b.Delay, failAfter: failAfter}
+	}
+	http.ServeContent(w, r, path.Base(name), time.Time{}, bytes.NewReader(f.data))
+}
+
+// throttledWriter writes the body in chunks and optionally aborts it.
+type throttledWriter struct {
+	http.ResponseWriter
+	chunk     int
+	delay     time.Duration
+	failAfter int
+	written   int
+}
+
+func (w *throttledWriter) Write(p []byte) (int, error) {
+	n := 0
+	for len(p) > 0 {
+		size := len(p)
+		if w.chunk > 0 {
+			size = min(size, w.chunk)
+		}
+		if w.failAfter > 0 && w.written+size >= w.failAfter {
+			size = w.failAfter - w.written
+			_, _ = w.ResponseWriter.Write(p[:size])
+			if f, ok := w.ResponseWriter.(http.Flusher); ok {
+				f.Flush()
+			}
+			// Drop the connection without logging.
+			panic(http.ErrAbortHandler)
+		}
+		m, err := w.ResponseWriter.Write(p[:size])
+		n += m
+		w.written += m
+		if err != nil {
+			return n, err
+		}
+		p = p[size:]
+		if w.chunk > 0 && w.delay > 0 {
+			if f, ok := w.ResponseWriter.(http.Flusher); ok {
+				f.Flush()
+			}
+			time.Sleep(w.delay)
+		}
+	}
+	return n, nil
+}
+
+// sortedNames returns the names of files in order, so archives are
+// reproducible.
+func sortedNames(files map[string][]byte) []string {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ZipArchive returns files as a zip archive. Names may contain slashes
+// for subdirectories.
+func ZipArchive(files map[string][]byte) []byte {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, name := range sortedNames(files) {
+		w, err := zw.Create(name)
+		if err != nil {
+			panic(err)
+		}
+		_, _ = w.Write(files[name])
+	}
+	if err := zw.Close(); err != nil {
+		panic(err)
+	}
+	return buf.Bytes()
+}
+
+// TarGzArchive returns files as a gzip compressed tar archive. Names may
+// contain slashes for subdirectories.
+func TarGzArchive(files map[string][]byte) []byte {
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gw)
+	for _, name := range sortedNames(files) {
+		hdr := &tar.Header{Name: name, Mode: 0o644, Size: int64(len(files[name])), Typeflag: tar.TypeReg}
+		if err := tw.WriteHeader(hdr); err != nil {
+			panic(err)
+		}
+		_, _ = tw.Write(files[name])
+	}
+	if err := tw.Close(); err != nil {
+		panic(err)
+	}
+	if err := gw.Close(); err != nil {
+		panic(err)
+	}
+	return buf.Bytes()
+}
+
+// Release returns the files of a minimal Raspberry Pi UEFI release: an
+// RPI_EFI.fd whose varstore holds a UEFI Shell boot entry, and a
+// config.txt loading it.
+func Release(tb testing.TB) map[string][]byte {
+	tb.Helper()
+	firmware, err := testutil.Firmware(testutil.VarList(tb, "UEFI Shell"))
+	if err != nil {
+		tb.Fatalf("failed to build firmware: %v", err)
+	}
+	return map[string][]byte{
+		"RPI_EFI.fd": firmware,
+		"config.txt": []byte("arm_64bit=1\nenable_uart=1\narmstub=RPI_EFI.fd\n"),
+	}
+}
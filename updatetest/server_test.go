@@ -0,0 +1,128 @@
+package updatetest
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/metal3-community/uefi-firmware-manager/types"
+)
+
+func get(t *testing.T, url string, header http.Header) (*http.Response, []byte, error) {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	return resp, body, err
+}
+
+func TestServer_File(t *testing.T) {
+	s := NewServer(t)
+	release := Release(t)
+	src := s.Serve("RPI_EFI.fd", release["RPI_EFI.fd"], Behavior{Signature: []byte("sig")})
+
+	resp, body, err := get(t, src.URL, nil)
+	if err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("GET %s = %v, %v", src.URL, resp, err)
+	}
+	if err := src.VerifyChecksum(body); err != nil {
+		t.Errorf("VerifyChecksum() error = %v", err)
+	}
+	if _, sig, err := get(t, src.SignatureURL, nil); err != nil || string(sig) != "sig" {
+		t.Errorf("GET %s = %q, %v", src.SignatureURL, sig, err)
+	}
+
+	resp, body, err = get(t, src.URL, http.Header{"Range": {"bytes=16-31"}})
+	if err != nil || resp.StatusCode != http.StatusPartialContent {
+		t.Fatalf("ranged GET = %v, %v", resp, err)
+	}
+	if !bytes.Equal(body, release["RPI_EFI.fd"][16:32]) {
+		t.Errorf("ranged GET = %x", body)
+	}
+	if got := len(s.Requests("RPI_EFI.fd")); got != 2 {
+		t.Errorf("Requests() = %d, want 2", got)
+	}
+}
+
+func TestServer_Archives(t *testing.T) {
+	s := NewServer(t)
+	files := map[string][]byte{"RPI_EFI.fd": []byte("firmware"), "overlays/a.dtbo": []byte("overlay")}
+
+	_, body, err := get(t, s.Zip("release.zip", files).URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		t.Fatalf("zip.NewReader() error = %v", err)
+	}
+	if len(zr.File) != 2 || zr.File[0].Name != "RPI_EFI.fd" || zr.File[1].Name != "overlays/a.dtbo" {
+		t.Errorf("zip files = %v", zr.File)
+	}
+
+	_, body, err = get(t, s.TarGz("release.tar.gz", files).URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gr, err := gzip.NewReader(bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("gzip.NewReader() error = %v", err)
+	}
+	tr := tar.NewReader(gr)
+	hdr, err := tr.Next()
+	if err != nil || hdr.Name != "RPI_EFI.fd" {
+		t.Fatalf("tar first entry = %v, %v", hdr, err)
+	}
+	if data, _ := io.ReadAll(tr); string(data) != "firmware" {
+		t.Errorf("tar RPI_EFI.fd = %q", data)
+	}
+}
+
+func TestServer_Behaviors(t *testing.T) {
+	s := NewServer(t)
+	data := bytes.Repeat([]byte("firmware"), 1024)
+
+	src := s.Serve("corrupt.fd", data, Behavior{Corrupt: true})
+	if _, body, _ := get(t, src.URL, nil); !errors.Is(src.VerifyChecksum(body), types.ErrChecksumMismatch) {
+		t.Error("corrupted file passed verification")
+	}
+
+	src = s.Serve("missing.fd", data, Behavior{Status: http.StatusServiceUnavailable})
+	if resp, _, _ := get(t, src.URL, nil); resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("status = %d, want 503", resp.StatusCode)
+	}
+
+	src = s.Serve("flaky.fd", data, Behavior{FailAfter: 1000, ChunkSize: 256})
+	_, partial, err := get(t, src.URL, nil)
+	if err == nil || len(partial) != 1000 {
+		t.Fatalf("first GET = %d bytes, %v; want 1000 bytes and an error", len(partial), err)
+	}
+	resp, rest, err := get(t, src.URL, http.Header{"Range": {"bytes=1000-"}})
+	if err != nil || resp.StatusCode != http.StatusPartialContent {
+		t.Fatalf("resumed GET = %v, %v", resp, err)
+	}
+	if err := src.VerifyChecksum(append(partial, rest...)); err != nil {
+		t.Errorf("resumed download: %v", err)
+	}
+
+	src = s.Serve("noranges.fd", data, Behavior{NoRanges: true})
+	resp, body, err := get(t, src.URL, http.Header{"Range": {"bytes=1000-"}})
+	if err != nil || resp.StatusCode != http.StatusOK || len(body) != len(data) {
+		t.Errorf("GET with ignored range = %v, %d bytes, %v", resp, len(body), err)
+	}
+}
@@ -0,0 +1,38 @@
+package util
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/metal3-community/uefi-firmware-manager/internal/archive"
+)
+
+// ArchiveFormat is the format of an archive made by CreateArchive.
+type ArchiveFormat string
+
+const (
+	// ArchiveTarGz is a gzip compressed tar archive.
+	ArchiveTarGz = ArchiveFormat(archive.TarGz)
+	// ArchiveZip is a zip archive.
+	ArchiveZip = ArchiveFormat(archive.Zip)
+)
+
+// CreateArchive packs the files below dir, such as the firmware payload of
+// a host (RPI_EFI.fd, device trees, config.txt and metadata), into the
+// archive dest, e.g. for hand-off to field technicians. Names in the
+// archive are relative to dir, so it extracts onto the root of a boot
+// partition. An empty format is inferred from the extension of dest
+// (.tar.gz, .tgz or .zip). dest is replaced atomically.
+func CreateArchive(dir, dest string, format ArchiveFormat) error {
+	if format == "" {
+		switch lower := strings.ToLower(dest); {
+		case strings.HasSuffix(lower, ".tar.gz"), strings.HasSuffix(lower, ".tgz"):
+			format = ArchiveTarGz
+		case strings.HasSuffix(lower, ".zip"):
+			format = ArchiveZip
+		default:
+			return fmt.Errorf("cannot infer the archive format of %s", dest)
+		}
+	}
+	return archive.Create(dir, dest, archive.Format(format), "")
+}
@@ -0,0 +1,108 @@
+package util_test
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/metal3-community/uefi-firmware-manager/util"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writePayload(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, data := range map[string]string{
+		"RPI_EFI.fd":                      "firmware",
+		"config.txt":                      "arm_64bit=1\n",
+		"bcm2711-rpi-4-b.dtb":             "dtb",
+		"overlays/miniuart-bt.dtbo":       "dtbo",
+		"metadata/d8-3a-dd-5a-44-0c.json": `{"role":"worker"}`,
+	} {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, []byte(data), 0o644))
+	}
+	return dir
+}
+
+var wantPayload = map[string]string{
+	"RPI_EFI.fd":                      "firmware",
+	"bcm2711-rpi-4-b.dtb":             "dtb",
+	"config.txt":                      "arm_64bit=1\n",
+	"metadata/":                       "",
+	"metadata/d8-3a-dd-5a-44-0c.json": `{"role":"worker"}`,
+	"overlays/":                       "",
+	"overlays/miniuart-bt.dtbo":       "dtbo",
+}
+
+func TestCreateArchive_TarGz(t *testing.T) {
+	dir := writePayload(t)
+	dest := filepath.Join(t.TempDir(), "host.tgz")
+	require.NoError(t, util.CreateArchive(dir, dest, ""))
+
+	f, err := os.Open(dest)
+	require.NoError(t, err)
+	defer f.Close()
+	gr, err := gzip.NewReader(f)
+	require.NoError(t, err)
+	tr := tar.NewReader(gr)
+	got := map[string]string{}
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(t, err)
+		data, err := io.ReadAll(tr)
+		require.NoError(t, err)
+		got[hdr.Name] = string(data)
+	}
+	assert.Equal(t, wantPayload, got)
+}
+
+func TestCreateArchive_Zip(t *testing.T) {
+	dir := writePayload(t)
+	// An archive below the directory does not include itself.
+	dest := filepath.Join(dir, "host.bundle")
+	require.NoError(t, util.CreateArchive(dir, dest, util.ArchiveZip))
+	require.NoError(t, util.CreateArchive(dir, dest, util.ArchiveZip))
+
+	zr, err := zip.OpenReader(dest)
+	require.NoError(t, err)
+	defer zr.Close()
+	got := map[string]string{}
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		require.NoError(t, err)
+		data, err := io.ReadAll(rc)
+		rc.Close()
+		require.NoError(t, err)
+		got[f.Name] = string(data)
+	}
+	assert.Equal(t, wantPayload, got)
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 6, "temporary archive left behind")
+}
+
+func TestCreateArchive_Errors(t *testing.T) {
+	dir := writePayload(t)
+	out := t.TempDir()
+	assert.Error(t, util.CreateArchive(dir, filepath.Join(out, "host.rar"), ""))
+	assert.Error(t, util.CreateArchive(dir, filepath.Join(out, "host"), "7z"))
+	assert.Error(t, util.CreateArchive(filepath.Join(dir, "missing"), filepath.Join(out, "host.zip"), ""))
+	assert.Error(t, util.CreateArchive(filepath.Join(dir, "config.txt"), filepath.Join(out, "host.zip"), ""))
+	assert.Error(t, util.CreateArchive(dir, filepath.Join(out, "missing", "host.zip"), ""))
+
+	entries, err := os.ReadDir(out)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
@@ -7,6 +7,7 @@ import (
 	"os"
 
 	"github.com/go-logr/logr"
+	"github.com/metal3-community/uefi-firmware-manager/atomicfile"
 	"github.com/metal3-community/uefi-firmware-manager/manager"
 )
 
@@ -65,11 +66,12 @@ func FileExists(path string) bool {
 	return err == nil
 }
 
-// CopyFile copies a firmware file to the specified destination.
+// CopyFile copies a firmware file to the specified destination, replacing
+// it atomically.
 func CopyFile(src, dst string) error {
 	data, err := os.ReadFile(src)
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(dst, data, 0o644)
+	return atomicfile.WriteFile(dst, data, 0o644)
 }
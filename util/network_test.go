@@ -8,235 +8,12 @@ import (
 	"testing"
 
 	"github.com/go-logr/logr"
-	"github.com/metal3-community/uefi-firmware-manager/efi"
-	"github.com/metal3-community/uefi-firmware-manager/types"
+	"github.com/metal3-community/uefi-firmware-manager/manager/managertest"
 	"github.com/metal3-community/uefi-firmware-manager/util"
 	"github.com/stretchr/testify/assert"
-	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 )
 
-// MockFirmwareManager is a mock implementation of the FirmwareManager interface.
-type MockFirmwareManager struct {
-	mock.Mock
-}
-
-// Implement all the required methods of the FirmwareManager interface.
-func (m *MockFirmwareManager) GetBootOrder() ([]string, error) {
-	args := m.Called()
-	v, ok := args.Get(0).([]string)
-	if !ok {
-		return nil, args.Error(1)
-	}
-	return v, args.Error(1)
-}
-
-func (m *MockFirmwareManager) GetBootLast() (*types.BootEntry, error) {
-	args := m.Called()
-	v, ok := args.Get(0).(*types.BootEntry)
-	if !ok {
-		return nil, args.Error(1)
-	}
-	return v, args.Error(1)
-}
-
-func (m *MockFirmwareManager) SetBootLast(entry types.BootEntry) error {
-	args := m.Called(entry)
-	return args.Error(0)
-}
-
-func (m *MockFirmwareManager) SetBootOrder(order []string) error {
-	args := m.Called(order)
-	return args.Error(0)
-}
-
-func (m *MockFirmwareManager) GetBootEntries() ([]types.BootEntry, error) {
-	args := m.Called()
-	v, ok := args.Get(0).([]types.BootEntry)
-	if !ok {
-		return nil, args.Error(1)
-	}
-	return v, args.Error(1)
-}
-
-func (m *MockFirmwareManager) AddBootEntry(entry types.BootEntry) error {
-	args := m.Called(entry)
-	return args.Error(0)
-}
-
-func (m *MockFirmwareManager) UpdateBootEntry(id string, entry types.BootEntry) error {
-	args := m.Called(id, entry)
-	return args.Error(0)
-}
-
-func (m *MockFirmwareManager) DeleteBootEntry(id string) error {
-	args := m.Called(id)
-	return args.Error(0)
-}
-
-func (m *MockFirmwareManager) SetBootNext(index uint16) error {
-	args := m.Called(index)
-	return args.Error(0)
-}
-
-func (m *MockFirmwareManager) DeleteBootNext() error {
-	args := m.Called()
-	return args.Error(0)
-}
-
-func (m *MockFirmwareManager) GetBootNext() (uint16, error) {
-	args := m.Called()
-	v, ok := args.Get(0).(uint16)
-	if !ok {
-		return 0, args.Error(1)
-	}
-	return v, args.Error(1)
-}
-
-func (m *MockFirmwareManager) GetNetworkSettings() (types.NetworkSettings, error) {
-	args := m.Called()
-	v, ok := args.Get(0).(types.NetworkSettings)
-	if !ok {
-		var zero types.NetworkSettings
-		return zero, args.Error(1)
-	}
-	return v, args.Error(1)
-}
-
-func (m *MockFirmwareManager) SetNetworkSettings(settings types.NetworkSettings) error {
-	args := m.Called(settings)
-	return args.Error(0)
-}
-
-func (m *MockFirmwareManager) GetMacAddress() (net.HardwareAddr, error) {
-	args := m.Called()
-	v, ok := args.Get(0).(net.HardwareAddr)
-	if !ok {
-		return nil, args.Error(1)
-	}
-	return v, args.Error(1)
-}
-
-func (m *MockFirmwareManager) SetMacAddress(mac net.HardwareAddr) error {
-	args := m.Called(mac)
-	return args.Error(0)
-}
-
-func (m *MockFirmwareManager) GetVariable(name string) (*efi.EfiVar, error) {
-	args := m.Called(name)
-	if args.Get(0) == nil {
-		return nil, args.Error(1)
-	}
-	v, ok := args.Get(0).(*efi.EfiVar)
-	if !ok {
-		return nil, args.Error(1)
-	}
-	return v, args.Error(1)
-}
-
-func (m *MockFirmwareManager) SetVariable(name string, value *efi.EfiVar) error {
-	args := m.Called(name, value)
-	return args.Error(0)
-}
-
-func (m *MockFirmwareManager) DeleteVariable(name string) error {
-	args := m.Called(name)
-	return args.Error(0)
-}
-
-func (m *MockFirmwareManager) ListVariables() (map[string]*efi.EfiVar, error) {
-	args := m.Called()
-	v, ok := args.Get(0).(map[string]*efi.EfiVar)
-	if !ok {
-		return nil, args.Error(1)
-	}
-	return v, args.Error(1)
-}
-
-func (m *MockFirmwareManager) EnablePXEBoot(enable bool) error {
-	args := m.Called(enable)
-	return args.Error(0)
-}
-
-func (m *MockFirmwareManager) GetVarList() (efi.EfiVarList, error) {
-	args := m.Called()
-	v, ok := args.Get(0).(efi.EfiVarList)
-	if !ok {
-		return nil, args.Error(1)
-	}
-	return v, args.Error(1)
-}
-
-func (m *MockFirmwareManager) EnableHTTPBoot(enable bool) error {
-	args := m.Called(enable)
-	return args.Error(0)
-}
-
-func (m *MockFirmwareManager) SetFirmwareTimeoutSeconds(seconds int) error {
-	args := m.Called(seconds)
-	return args.Error(0)
-}
-
-func (m *MockFirmwareManager) SetConsoleConfig(consoleName string, baudRate int) error {
-	args := m.Called(consoleName, baudRate)
-	return args.Error(0)
-}
-
-func (m *MockFirmwareManager) GetSystemInfo() (types.SystemInfo, error) {
-	args := m.Called()
-	v, ok := args.Get(0).(types.SystemInfo)
-	if !ok {
-		var zero types.SystemInfo
-		return zero, args.Error(1)
-	}
-	return v, args.Error(1)
-}
-
-func (m *MockFirmwareManager) UpdateFirmware(firmwareData []byte) error {
-	args := m.Called(firmwareData)
-	return args.Error(0)
-}
-
-func (m *MockFirmwareManager) GetFirmwareVersion() (string, error) {
-	args := m.Called()
-	return args.String(0), args.Error(1)
-}
-
-func (m *MockFirmwareManager) SaveChanges() error {
-	args := m.Called()
-	return args.Error(0)
-}
-
-func (m *MockFirmwareManager) RevertChanges() error {
-	args := m.Called()
-	return args.Error(0)
-}
-
-func (m *MockFirmwareManager) ResetToDefaults() error {
-	args := m.Called()
-	return args.Error(0)
-}
-
-// Enhanced Variable Management with Type Conversion methods.
-func (m *MockFirmwareManager) GetVariableAsType(name string) (any, error) {
-	args := m.Called(name)
-	return args.Get(0), args.Error(1)
-}
-
-func (m *MockFirmwareManager) ListVariablesWithTypes() (map[string]any, error) {
-	args := m.Called()
-	v, ok := args.Get(0).(map[string]any)
-	if !ok {
-		return nil, args.Error(1)
-	}
-	return v, args.Error(1)
-}
-
-func (m *MockFirmwareManager) SetVariableFromType(name string, value any) error {
-	args := m.Called(name, value)
-	return args.Error(0)
-}
-
 func TestCreateBootNetworkManager(t *testing.T) {
 	// Create a temporary file for the test
 	tmpFile, err := os.CreateTemp("", "firmware-*.bin")
@@ -262,7 +39,7 @@ func TestCreateBootNetworkManager(t *testing.T) {
 }
 
 func TestConfigureNetworkBoot(t *testing.T) {
-	mockManager := new(MockFirmwareManager)
+	mockManager := new(managertest.Mock)
 	mac, _ := net.ParseMAC("00:11:22:33:44:55")
 
 	t.Run("SuccessfulConfiguration", func(t *testing.T) {
@@ -280,7 +57,7 @@ func TestConfigureNetworkBoot(t *testing.T) {
 
 	t.Run("ErrorInSetMacAddress", func(t *testing.T) {
 		// Clear previous calls
-		mockManager = new(MockFirmwareManager)
+		mockManager = new(managertest.Mock)
 
 		// Setup expectations for failure
 		mockManager.On("SetMacAddress", mac).Return(errors.New("mac error"))
@@ -293,7 +70,7 @@ func TestConfigureNetworkBoot(t *testing.T) {
 
 	t.Run("ErrorInEnablePXEBoot", func(t *testing.T) {
 		// Clear previous calls
-		mockManager = new(MockFirmwareManager)
+		mockManager = new(managertest.Mock)
 
 		// Setup expectations for failure
 		mockManager.On("SetMacAddress", mac).Return(nil)
@@ -307,7 +84,7 @@ func TestConfigureNetworkBoot(t *testing.T) {
 
 	t.Run("ErrorInEnableHTTPBoot", func(t *testing.T) {
 		// Clear previous calls
-		mockManager = new(MockFirmwareManager)
+		mockManager = new(managertest.Mock)
 
 		// Setup expectations for failure
 		mockManager.On("SetMacAddress", mac).Return(nil)
@@ -322,7 +99,7 @@ func TestConfigureNetworkBoot(t *testing.T) {
 
 	t.Run("ErrorInSetFirmwareTimeoutSeconds", func(t *testing.T) {
 		// Clear previous calls
-		mockManager = new(MockFirmwareManager)
+		mockManager = new(managertest.Mock)
 
 		// Setup expectations for failure
 		mockManager.On("SetMacAddress", mac).Return(nil)
@@ -338,7 +115,7 @@ func TestConfigureNetworkBoot(t *testing.T) {
 
 	t.Run("ErrorInSaveChanges", func(t *testing.T) {
 		// Clear previous calls
-		mockManager = new(MockFirmwareManager)
+		mockManager = new(managertest.Mock)
 
 		// Setup expectations for failure
 		mockManager.On("SetMacAddress", mac).Return(nil)
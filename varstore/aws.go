@@ -0,0 +1,197 @@
+package varstore
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math"
+	"sort"
+	"unicode/utf16"
+
+	"github.com/metal3-community/uefi-firmware-manager/efi"
+)
+
+// AWS uefi-data blob, as accepted by ec2 register-image --uefi-data.
+//
+// The decoded blob starts with a 16 byte header: the "AMZNUEFI" magic, the
+// CRC32C of the remaining bytes and a zero version. The remainder is a zlib
+// stream holding a 64-bit variable count followed by the variables. Each
+// variable is its length prefixed UTF-16LE name, its length prefixed data,
+// the vendor GUID and the 32-bit attributes. Time based authenticated
+// variables additionally carry a 16 byte EFI_TIME and a 32 byte digest.
+const (
+	awsUefiMagic      = "AMZNUEFI"
+	awsUefiVersion    = 0
+	awsUefiHeaderSize = 8 + 4 + 4
+	awsUefiDigestSize = 32
+)
+
+// ErrAWSUefiData is returned for malformed AWS uefi-data blobs.
+var ErrAWSUefiData = errors.New("invalid aws uefi-data blob")
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// EncodeAWSUefiData returns varlist as a base64 AWS uefi-data blob.
+// Variables are written sorted by name and GUID. The digest of time based
+// authenticated variables is not tracked and is written as zeros.
+func EncodeAWSUefiData(varlist efi.EfiVarList) (string, error) {
+	keys := make([]string, 0, len(varlist))
+	for k := range varlist {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var payload bytes.Buffer
+	_ = binary.Write(&payload, binary.LittleEndian, uint64(len(keys)))
+	for _, key := range keys {
+		v := varlist[key]
+		if v.Name == nil {
+			return "", fmt.Errorf("variable %s has no name", key)
+		}
+		var name []byte
+		for _, u := range utf16.Encode([]rune(v.Name.String())) {
+			name = binary.LittleEndian.AppendUint16(name, u)
+		}
+		_ = binary.Write(&payload, binary.LittleEndian, uint64(len(name)))
+		payload.Write(name)
+		_ = binary.Write(&payload, binary.LittleEndian, uint64(len(v.Data)))
+		payload.Write(v.Data)
+		payload.Write(v.Guid.Bytes())
+		_ = binary.Write(&payload, binary.LittleEndian, v.Attr)
+		if v.Attr&efi.EfiVariableTimeBasedAuthenticatedWriteAccess != 0 {
+			payload.Write(v.BytesTime())
+			payload.Write(make([]byte, awsUefiDigestSize))
+		}
+	}
+
+	var compressed bytes.Buffer
+	zw := zlib.NewWriter(&compressed)
+	if _, err := zw.Write(payload.Bytes()); err != nil {
+		return "", err
+	}
+	if err := zw.Close(); err != nil {
+		return "", err
+	}
+
+	blob := []byte(awsUefiMagic)
+	blob = binary.LittleEndian.AppendUint32(blob, crc32.Checksum(compressed.Bytes(), castagnoli))
+	blob = binary.LittleEndian.AppendUint32(blob, awsUefiVersion)
+	blob = append(blob, compressed.Bytes()...)
+	return base64.StdEncoding.EncodeToString(blob), nil
+}
+
+// DecodeAWSUefiData parses a base64 AWS uefi-data blob.
+func DecodeAWSUefiData(data string) (efi.EfiVarList, error) {
+	blob, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrAWSUefiData, err)
+	}
+	if len(blob) < awsUefiHeaderSize || string(blob[:8]) != awsUefiMagic {
+		return nil, fmt.Errorf("%w: missing AMZNUEFI header", ErrAWSUefiData)
+	}
+	if version := binary.LittleEndian.Uint32(blob[12:16]); version != awsUefiVersion {
+		return nil, fmt.Errorf("%w: unsupported version %d", ErrAWSUefiData, version)
+	}
+	compressed := blob[awsUefiHeaderSize:]
+	if crc := crc32.Checksum(compressed, castagnoli); crc != binary.LittleEndian.Uint32(blob[8:12]) {
+		return nil, fmt.Errorf("%w: checksum mismatch", ErrAWSUefiData)
+	}
+
+	zr, err := zlib.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrAWSUefiData, err)
+	}
+	payload, err := io.ReadAll(zr)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrAWSUefiData, err)
+	}
+
+	r := &awsReader{data: payload}
+	count := r.uint64()
+	varlist := efi.NewEfiVarList()
+	for i := uint64(0); i < count && r.err == nil; i++ {
+		v, err := r.variable()
+		if err != nil {
+			return nil, fmt.Errorf("%w: variable %d: %w", ErrAWSUefiData, i, err)
+		}
+		varlist.Set(v)
+	}
+	if r.err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrAWSUefiData, r.err)
+	}
+	return varlist, nil
+}
+
+// awsReader decodes the uefi-data payload, remembering the first error.
+type awsReader struct {
+	data []byte
+	pos  int
+	err  error
+}
+
+func (r *awsReader) bytes(n uint64) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n > uint64(len(r.data)-r.pos) || n > math.MaxInt {
+		r.err = fmt.Errorf("truncated payload at offset %d", r.pos)
+		return nil
+	}
+	b := r.data[r.pos : r.pos+int(n)]
+	r.pos += int(n)
+	return b
+}
+
+func (r *awsReader) uint64() uint64 {
+	if b := r.bytes(8); b != nil {
+		return binary.LittleEndian.Uint64(b)
+	}
+	return 0
+}
+
+func (r *awsReader) variable() (*efi.EfiVar, error) {
+	rawName := r.bytes(r.uint64())
+	data := r.bytes(r.uint64())
+	guid := r.bytes(16)
+	attr := r.bytes(4)
+	if r.err != nil {
+		return nil, r.err
+	}
+	if len(rawName)%2 != 0 {
+		return nil, fmt.Errorf("odd name length %d", len(rawName))
+	}
+	units := make([]uint16, 0, len(rawName)/2)
+	for i := 0; i < len(rawName); i += 2 {
+		units = append(units, binary.LittleEndian.Uint16(rawName[i:]))
+	}
+	if n := len(units); n > 0 && units[n-1] == 0 {
+		units = units[:n-1]
+	}
+	name, err := efi.UCS16FromString(string(utf16.Decode(units)))
+	if err != nil {
+		return nil, err
+	}
+
+	v := &efi.EfiVar{
+		Name: name,
+		Guid: efi.ParseBinGUID(guid, 0),
+		Attr: binary.LittleEndian.Uint32(attr),
+		Data: data,
+	}
+	if v.Attr&efi.EfiVariableTimeBasedAuthenticatedWriteAccess != 0 {
+		timestamp := r.bytes(16)
+		r.bytes(awsUefiDigestSize)
+		if r.err != nil {
+			return nil, r.err
+		}
+		if err := v.ParseTime(timestamp, 0); err != nil {
+			return nil, err
+		}
+	}
+	return v, nil
+}
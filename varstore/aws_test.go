@@ -0,0 +1,120 @@
+package varstore
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/metal3-community/uefi-firmware-manager/efi"
+)
+
+func TestAWSUefiDataRoundTrip(t *testing.T) {
+	data, err := os.ReadFile("../efi/test/fw-test.json")
+	if err != nil {
+		t.Fatalf("os.ReadFile() error = %v", err)
+	}
+	varlist := efi.EfiVarList{}
+	if err := varlist.UnmarshalJSON(data); err != nil {
+		t.Fatalf("EfiVarList.UnmarshalJSON() error = %v", err)
+	}
+	signed := time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC)
+	varlist.Set(&efi.EfiVar{
+		Name: efi.FromString("db"),
+		Guid: efi.StringToGUID(efi.EfiImageSecurityDatabase),
+		Attr: efi.EfiVariableDefault | efi.EfiVariableRuntimeAccess |
+			efi.EfiVariableTimeBasedAuthenticatedWriteAccess,
+		Data: []byte{0x01, 0x02, 0x03},
+		Time: &signed,
+	})
+
+	blob, err := EncodeAWSUefiData(varlist)
+	if err != nil {
+		t.Fatalf("EncodeAWSUefiData() error = %v", err)
+	}
+	got, err := DecodeAWSUefiData(blob)
+	if err != nil {
+		t.Fatalf("DecodeAWSUefiData() error = %v", err)
+	}
+	if len(got) != len(varlist) {
+		t.Fatalf("DecodeAWSUefiData() = %d variables, want %d", len(got), len(varlist))
+	}
+	for key, want := range varlist {
+		v, found := got[key]
+		if !found {
+			t.Errorf("DecodeAWSUefiData() lost %s", key)
+			continue
+		}
+		if v.Attr != want.Attr || !bytes.Equal(v.Data, want.Data) {
+			t.Errorf("DecodeAWSUefiData() %s = attr 0x%x data %x, want attr 0x%x data %x",
+				key, v.Attr, v.Data, want.Attr, want.Data)
+		}
+		if (want.Time == nil) != (v.Time == nil) || (want.Time != nil && !want.Time.Equal(*v.Time)) {
+			t.Errorf("DecodeAWSUefiData() %s time = %v, want %v", key, v.Time, want.Time)
+		}
+	}
+}
+
+func TestDecodeAWSUefiDataErrors(t *testing.T) {
+	varlist := efi.NewEfiVarList()
+	varlist.Set(&efi.EfiVar{
+		Name: efi.FromString("Timeout"),
+		Guid: efi.EFI_GLOBAL_VARIABLE_GUID,
+		Attr: efi.EfiVariableDefault,
+		Data: []byte{0x05, 0x00},
+	})
+	encoded, err := EncodeAWSUefiData(varlist)
+	if err != nil {
+		t.Fatalf("EncodeAWSUefiData() error = %v", err)
+	}
+	raw, _ := base64.StdEncoding.DecodeString(encoded)
+
+	corrupt := bytes.Clone(raw)
+	corrupt[len(corrupt)-1] ^= 0xff
+	badMagic := bytes.Clone(raw)
+	badMagic[0] = 'X'
+
+	tests := []struct {
+		name string
+		data string
+	}{
+		{name: "not base64", data: "%%%"},
+		{name: "short", data: base64.StdEncoding.EncodeToString(raw[:10])},
+		{name: "bad magic", data: base64.StdEncoding.EncodeToString(badMagic)},
+		{name: "checksum mismatch", data: base64.StdEncoding.EncodeToString(corrupt)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := DecodeAWSUefiData(tt.data); !errors.Is(err, ErrAWSUefiData) {
+				t.Errorf("DecodeAWSUefiData() error = %v, want ErrAWSUefiData", err)
+			}
+		})
+	}
+}
+
+func FuzzDecodeAWSUefiData(f *testing.F) {
+	data, err := os.ReadFile("../efi/test/fw-test.json")
+	if err != nil {
+		f.Fatal(err)
+	}
+	varlist := efi.EfiVarList{}
+	if err := varlist.UnmarshalJSON(data); err != nil {
+		f.Fatal(err)
+	}
+	encoded, err := EncodeAWSUefiData(varlist)
+	if err != nil {
+		f.Fatal(err)
+	}
+	blob, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		f.Fatal(err)
+	}
+	f.Add(blob)
+	f.Add([]byte{})
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		_, _ = DecodeAWSUefiData(base64.StdEncoding.EncodeToString(data))
+	})
+}
@@ -0,0 +1,86 @@
+package varstore
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"sort"
+
+	"github.com/metal3-community/uefi-firmware-manager/efi"
+)
+
+// UEFI Shell "dmpstore -s" file.
+//
+// The file is a sequence of records, one per variable: the 32-bit name
+// size and data size, the null terminated UCS-16 name, the vendor GUID,
+// the 32-bit attributes, the data and finally the CRC32 of all preceding
+// bytes of the record.
+const dmpstoreFixedSize = 4 + 4 + 16 + 4 + 4
+
+// ErrDmpstore is returned for malformed dmpstore files.
+var ErrDmpstore = errors.New("invalid dmpstore file")
+
+// EncodeDmpstore returns varlist in the format written by dmpstore -s.
+// Variables are written sorted by name and GUID.
+func EncodeDmpstore(varlist efi.EfiVarList) ([]byte, error) {
+	keys := make([]string, 0, len(varlist))
+	for k := range varlist {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var blob []byte
+	for _, key := range keys {
+		v := varlist[key]
+		if v.Name == nil {
+			return nil, fmt.Errorf("variable %s has no name", key)
+		}
+		name := v.Name.Bytes()
+		record := binary.LittleEndian.AppendUint32(nil, uint32(len(name)))
+		record = binary.LittleEndian.AppendUint32(record, uint32(len(v.Data)))
+		record = append(record, name...)
+		record = append(record, v.Guid.Bytes()...)
+		record = binary.LittleEndian.AppendUint32(record, v.Attr)
+		record = append(record, v.Data...)
+		record = binary.LittleEndian.AppendUint32(record, crc32.ChecksumIEEE(record))
+		blob = append(blob, record...)
+	}
+	return blob, nil
+}
+
+// DecodeDmpstore parses a file written by dmpstore -s.
+func DecodeDmpstore(data []byte) (efi.EfiVarList, error) {
+	varlist := efi.NewEfiVarList()
+	pos := 0
+	for pos < len(data) {
+		if len(data)-pos < dmpstoreFixedSize {
+			return nil, fmt.Errorf("%w: truncated record at offset %d", ErrDmpstore, pos)
+		}
+		nsize := binary.LittleEndian.Uint32(data[pos:])
+		dsize := binary.LittleEndian.Uint32(data[pos+4:])
+		size := uint64(dmpstoreFixedSize) + uint64(nsize) + uint64(dsize)
+		if size > uint64(len(data)-pos) {
+			return nil, fmt.Errorf("%w: record at offset %d overruns file", ErrDmpstore, pos)
+		}
+		record := data[pos : pos+int(size)]
+		crcOffset := len(record) - 4
+		if crc32.ChecksumIEEE(record[:crcOffset]) != binary.LittleEndian.Uint32(record[crcOffset:]) {
+			return nil, fmt.Errorf("%w: checksum mismatch at offset %d", ErrDmpstore, pos)
+		}
+
+		nameEnd := 8 + int(nsize)
+		name, err := efi.ParseUCS16Exact(record[8:nameEnd])
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid name at offset %d: %w", ErrDmpstore, pos, err)
+		}
+		varlist.Set(&efi.EfiVar{
+			Name: name,
+			Guid: efi.ParseBinGUID(record, nameEnd),
+			Attr: binary.LittleEndian.Uint32(record[nameEnd+16:]),
+			Data: record[nameEnd+20 : crcOffset],
+		})
+		pos += int(size)
+	}
+	return varlist, nil
+}
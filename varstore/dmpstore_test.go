@@ -0,0 +1,121 @@
+package varstore
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/metal3-community/uefi-firmware-manager/efi"
+)
+
+func TestDmpstoreRoundTrip(t *testing.T) {
+	data, err := os.ReadFile("../efi/test/fw-test.json")
+	if err != nil {
+		t.Fatalf("os.ReadFile() error = %v", err)
+	}
+	varlist := efi.EfiVarList{}
+	if err := varlist.UnmarshalJSON(data); err != nil {
+		t.Fatalf("EfiVarList.UnmarshalJSON() error = %v", err)
+	}
+
+	blob, err := EncodeDmpstore(varlist)
+	if err != nil {
+		t.Fatalf("EncodeDmpstore() error = %v", err)
+	}
+	got, err := DecodeDmpstore(blob)
+	if err != nil {
+		t.Fatalf("DecodeDmpstore() error = %v", err)
+	}
+	if len(got) != len(varlist) {
+		t.Fatalf("DecodeDmpstore() = %d variables, want %d", len(got), len(varlist))
+	}
+	for key, want := range varlist {
+		v, found := got[key]
+		if !found || v.Attr != want.Attr || !bytes.Equal(v.Data, want.Data) {
+			t.Errorf("DecodeDmpstore() %s = %v, want %v", key, v, want)
+		}
+	}
+
+	again, err := EncodeDmpstore(got)
+	if err != nil || !bytes.Equal(again, blob) {
+		t.Errorf("EncodeDmpstore(DecodeDmpstore()) differs from input, error = %v", err)
+	}
+}
+
+func TestDecodeDmpstore(t *testing.T) {
+	varlist := efi.NewEfiVarList()
+	varlist.Set(&efi.EfiVar{
+		Name: efi.FromString("Timeout"),
+		Guid: efi.EFI_GLOBAL_VARIABLE_GUID,
+		Attr: efi.EfiVariableDefault | efi.EfiVariableRuntimeAccess,
+		Data: []byte{0x05, 0x00},
+	})
+	valid, err := EncodeDmpstore(varlist)
+	if err != nil {
+		t.Fatalf("EncodeDmpstore() error = %v", err)
+	}
+	// NameSize, DataSize, "Timeout", GUID, attributes, data, CRC32.
+	if want := 4 + 4 + 16 + 16 + 4 + 2 + 4; len(valid) != want {
+		t.Fatalf("EncodeDmpstore() = %d bytes, want %d", len(valid), want)
+	}
+
+	corrupt := bytes.Clone(valid)
+	corrupt[len(corrupt)-5] ^= 0xff
+
+	tests := []struct {
+		name    string
+		data    []byte
+		want    int
+		wantErr bool
+	}{
+		{name: "empty", data: []byte{}, want: 0},
+		{name: "single variable", data: valid, want: 1},
+		{name: "truncated", data: valid[:len(valid)-1], wantErr: true},
+		{name: "checksum mismatch", data: corrupt, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DecodeDmpstore(tt.data)
+			if tt.wantErr {
+				if !errors.Is(err, ErrDmpstore) {
+					t.Errorf("DecodeDmpstore() error = %v, want ErrDmpstore", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("DecodeDmpstore() error = %v", err)
+			}
+			if len(got) != tt.want {
+				t.Errorf("DecodeDmpstore() = %d variables, want %d", len(got), tt.want)
+			}
+		})
+	}
+}
+
+func FuzzDecodeDmpstore(f *testing.F) {
+	data, err := os.ReadFile("../efi/test/fw-test.json")
+	if err != nil {
+		f.Fatal(err)
+	}
+	varlist := efi.EfiVarList{}
+	if err := varlist.UnmarshalJSON(data); err != nil {
+		f.Fatal(err)
+	}
+	blob, err := EncodeDmpstore(varlist)
+	if err != nil {
+		f.Fatal(err)
+	}
+	f.Add(blob)
+	f.Add([]byte{})
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		varlist, err := DecodeDmpstore(data)
+		if err != nil {
+			return
+		}
+		if _, err := EncodeDmpstore(varlist); err != nil {
+			t.Errorf("EncodeDmpstore() of decoded variables error = %v", err)
+		}
+	})
+}
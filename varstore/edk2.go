@@ -3,21 +3,110 @@ package varstore
 import (
 	"bytes"
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"slices"
 	"sort"
+	"time"
 
 	"github.com/go-logr/logr"
+	"github.com/metal3-community/uefi-firmware-manager/atomicfile"
 	"github.com/metal3-community/uefi-firmware-manager/efi"
+	"github.com/metal3-community/uefi-firmware-manager/filelock"
 )
 
+const (
+	varMagic      = efi.VarHeaderMagic
+	varAdded      = efi.VarStateAdded
+	varHeaderSize = efi.VarHeaderSize
+
+	// varStoreHeaderSize is the size of the authenticated varstore header.
+	varStoreHeaderSize = 16 + 12
+
+	// DefaultMaxVariableSize is the EDK2 PcdMaxVariableSize default.
+	DefaultMaxVariableSize = 0x2000
+	// DefaultMaxAuthVariableSize is the EDK2 PcdMaxAuthVariableSize default.
+	DefaultMaxAuthVariableSize = 0x2800
+	// DefaultMaxHwErrVariableSize is the EDK2 PcdMaxHardwareErrorVariableSize default.
+	DefaultMaxHwErrVariableSize = 0x8000
+)
+
+var (
+	// ErrQuotaExceeded is returned when a variable list does not fit in the varstore.
+	ErrQuotaExceeded = errors.New("quota exceeded")
+	// ErrVariableTooLarge is returned when a single variable exceeds its size limit.
+	ErrVariableTooLarge = errors.New("variable too large")
+	// ErrCorrupt is returned when the variable area contains a damaged record.
+	ErrCorrupt = errors.New("corrupt varstore")
+
+	// DeterministicTime is the timestamp Deterministic writes for time
+	// based authenticated variables, which require one. It is older than
+	// any real timestamp, so later authenticated writes are accepted.
+	DeterministicTime = time.Date(1970, time.January, 1, 0, 0, 0, 0, time.UTC)
+)
+
+// Region is a range of the varstore that was skipped while recovering
+// from a damaged record.
+type Region struct {
+	Offset int
+	Size   int
+	Reason string
+}
+
+func (r Region) String() string {
+	return fmt.Sprintf("0x%x+0x%x: %s", r.Offset, r.Size, r.Reason)
+}
+
 type Edk2VarStore struct {
+	// path is the file the image was read from, if any.
+	path  string
 	data  []byte
 	start int
 	end   int
-
+	// volEnd is the end of the firmware volume holding the varstore.
+	volEnd int
+	// volumes are the NVDATA volumes of the image and selected the index
+	// of the one holding the varstore.
+	volumes  []Volume
+	selected int
+
+	// Options controls how strictly GetVarList checks the variables it
+	// decodes. Structural damage is handled by Recover.
+	Options efi.ParseOptions
+	// Recover makes GetVarList skip damaged records instead of failing.
+	// The skipped regions are reported by Skipped.
+	Recover bool
+	skipped []Region
+	// Wipe makes the written image also zero the data of stale variable
+	// records after the varstore, such as the copy kept in the fault
+	// tolerant write spare area, unless it matches a variable being
+	// written. The varstore itself is always rebuilt from the written
+	// list, so deleted records never survive there.
+	Wipe bool
+	// Deterministic makes the written varstore depend only on the
+	// variables, so two runs over the same input produce identical images
+	// for reproducible builds. Variables are written in name and GUID
+	// order however the list is keyed, and timestamps are zeroed, except
+	// for time based authenticated variables, which get DeterministicTime.
+	// Padding is always written as erased flash.
+	Deterministic bool
+	// Mirror makes the written image also hold the variables in every
+	// other usable volume listed by Volumes, so that standby copies stay
+	// in sync with the selected volume. Each volume must fit them.
+	Mirror bool
+	// LockTimeout bounds how long WriteVarStore waits for other writers of
+	// the file that hold its lock. Zero uses filelock.DefaultTimeout.
+	LockTimeout time.Duration
+
+	// Metrics, if set, receives the Metrics of every parse and
+	// serialization. Nil uses the MetricsFunc set with SetMetrics.
+	Metrics MetricsFunc
+
+	// Logger receives events worth attention, such as skipped records, at
+	// level 0 and details of reading and writing at level 1. The zero
+	// Logger discards everything.
 	Logger logr.Logger
 }
 
@@ -38,110 +127,357 @@ func New(data []byte) (*Edk2VarStore, error) {
 	return vs, nil
 }
 
+// NewWithOptions is like New but parses variables using opts.
+func NewWithOptions(data []byte, opts efi.ParseOptions) (*Edk2VarStore, error) {
+	vs, err := New(data)
+	if err != nil {
+		return nil, err
+	}
+	vs.Options = opts
+	return vs, nil
+}
+
 func (vs *Edk2VarStore) GetVarList() (efi.EfiVarList, error) {
+	fn := vs.metricsFunc()
+	if fn == nil {
+		return vs.getVarList()
+	}
+	start := time.Now()
+	varlist, err := vs.getVarList()
+	vs.report(fn, OpParse, vs.path, varlist, start, err)
+	return varlist, err
+}
+
+func (vs *Edk2VarStore) getVarList() (efi.EfiVarList, error) {
+	vs.skipped = nil
+	if vs.start < 0 || vs.start > vs.end || vs.end > len(vs.data) {
+		return nil, fmt.Errorf("%w: variable area 0x%x-0x%x is outside the image",
+			ErrCorrupt, vs.start, vs.end)
+	}
 	pos := vs.start
 	varlist := efi.EfiVarList{}
 	for pos < vs.end {
-		magic := binary.LittleEndian.Uint16(vs.data[pos:])
-		if magic != 0x55aa {
-			break
-		}
-		state := vs.data[pos+2]
-		attr := binary.LittleEndian.Uint32(vs.data[pos+4:])
-		count := binary.LittleEndian.Uint64(vs.data[pos+8:])
-
-		pk := binary.LittleEndian.Uint32(vs.data[pos+32:])
-		nsize := binary.LittleEndian.Uint32(vs.data[pos+36:])
-		dsize := binary.LittleEndian.Uint32(vs.data[pos+40:])
-
-		if state == 0x3f {
-			varName := efi.FromUCS16(vs.data[pos+44+16:])
-			varData := vs.data[uint32(pos)+44+16+nsize : uint32(pos)+44+16+nsize+dsize]
-			varItem := efi.EfiVar{
-				Name:  varName,
-				Guid:  efi.ParseBinGUID(vs.data, pos+44),
-				Attr:  attr,
-				Data:  varData,
-				Count: int(count),
-				PkIdx: int(pk),
+		if pos+2 > vs.end || binary.LittleEndian.Uint16(vs.data[pos:]) != varMagic {
+			if vs.erased(pos, vs.end) {
+				break
 			}
-			_ = varItem.ParseTime(vs.data, pos+16)
-			varlist[varItem.Name.String()] = &varItem
 		}
 
-		pos += 44 + 16 + int(nsize) + int(dsize)
-		pos = (pos + 3) & ^3 // align
+		varItem, next, err := vs.parseVar(pos)
+		if err != nil {
+			if !vs.Recover {
+				return nil, fmt.Errorf("%w: %w", ErrCorrupt, vs.varError(pos, err))
+			}
+			if next < 0 {
+				next = vs.nextHeader(pos)
+			}
+			vs.skip(pos, next, err)
+			pos = next
+			continue
+		}
+		if varItem != nil {
+			varlist.Set(varItem)
+			if err := vs.Options.CheckCount(len(varlist)); err != nil {
+				return nil, err
+			}
+		}
+		pos = next
 	}
 	return varlist, nil
 }
 
+// Skipped returns the damaged regions the last GetVarList call skipped in
+// recovery mode.
+func (vs *Edk2VarStore) Skipped() []Region {
+	return vs.skipped
+}
+
+// parseVar decodes the variable record at pos and returns it together with
+// the offset of the next record. Records that are not in the added state
+// yield a nil variable. If the header itself is unusable the returned
+// offset is -1.
+func (vs *Edk2VarStore) parseVar(pos int) (*efi.EfiVar, int, error) {
+	if pos+varHeaderSize > vs.end {
+		return nil, -1, fmt.Errorf("truncated variable header")
+	}
+	magic := binary.LittleEndian.Uint16(vs.data[pos:])
+	if magic != varMagic {
+		return nil, -1, fmt.Errorf("bad variable magic 0x%04x", magic)
+	}
+	state := vs.data[pos+2]
+	nsize := binary.LittleEndian.Uint32(vs.data[pos+36:])
+	dsize := binary.LittleEndian.Uint32(vs.data[pos+40:])
+
+	nameStart := pos + varHeaderSize
+	if uint64(nsize)+uint64(dsize) > uint64(vs.end-nameStart) {
+		return nil, -1, fmt.Errorf("variable size 0x%x+0x%x overruns varstore", nsize, dsize)
+	}
+	recordEnd := nameStart + int(nsize) + int(dsize)
+	next := min((recordEnd+3) & ^3, vs.end) // align
+
+	if state != varAdded {
+		return nil, next, nil
+	}
+
+	varItem, err := efi.ParseEfiVarWithOptions(vs.data[pos:recordEnd], vs.Options)
+	if err != nil {
+		return nil, next, err
+	}
+	return varItem, next, nil
+}
+
+// varError returns err of the record at pos with the name and GUID of the
+// variable, as far as the header tells, and the file of the image.
+func (vs *Edk2VarStore) varError(pos int, err error) error {
+	e := &efi.VarError{Offset: pos, Path: vs.path, Err: err}
+	if pos+varHeaderSize > vs.end || binary.LittleEndian.Uint16(vs.data[pos:]) != varMagic {
+		return e
+	}
+	e.Guid = efi.ParseBinGUID(vs.data, pos+44)
+	nameStart := pos + varHeaderSize
+	if nsize := binary.LittleEndian.Uint32(vs.data[pos+36:]); uint64(nsize) <= uint64(vs.end-nameStart) {
+		if name, err := efi.ParseUCS16Exact(vs.data[nameStart : nameStart+int(nsize)]); err == nil {
+			e.Name = name.String()
+		}
+	}
+	return e
+}
+
+// nextHeader scans forward from a damaged record at pos for the next
+// plausible variable header. It returns vs.end if there is none.
+func (vs *Edk2VarStore) nextHeader(pos int) int {
+	for next := (pos + 4) & ^3; next+varHeaderSize <= vs.end; next += 4 {
+		if binary.LittleEndian.Uint16(vs.data[next:]) != varMagic {
+			continue
+		}
+		if _, end, _ := vs.parseVar(next); end >= 0 {
+			return next
+		}
+	}
+	return vs.end
+}
+
+// skip records the damaged region [pos, next), ignoring trailing free space.
+func (vs *Edk2VarStore) skip(pos, next int, reason error) {
+	end := next
+	for end > pos && vs.data[end-1] == 0xff {
+		end--
+	}
+	region := Region{Offset: pos, Size: max(end-pos, 1), Reason: reason.Error()}
+	vs.Logger.Info("skipping damaged varstore region",
+		"offset", region.Offset, "size", region.Size, "reason", region.Reason)
+	vs.skipped = append(vs.skipped, region)
+}
+
+// erased reports whether the bytes in [start, end) are all in the erased state.
+func (vs *Edk2VarStore) erased(start, end int) bool {
+	for _, b := range vs.data[start:end] {
+		if b != 0xff {
+			return false
+		}
+	}
+	return true
+}
+
+// Capacity returns the number of bytes available for variables in the varstore.
+func (vs *Edk2VarStore) Capacity() int {
+	return vs.end - vs.start
+}
+
+// FreeSpace returns the number of bytes left in the varstore once the
+// variables it currently holds are written back.
+func (vs *Edk2VarStore) FreeSpace() (int, error) {
+	varlist, err := vs.GetVarList()
+	if err != nil {
+		return 0, err
+	}
+	return vs.FreeSpaceFor(varlist), nil
+}
+
+// FreeSpaceFor returns the number of bytes left in the varstore after
+// writing varlist. The result is negative if varlist does not fit.
+func (vs *Edk2VarStore) FreeSpaceFor(varlist efi.EfiVarList) int {
+	return vs.Capacity() - UsedSpace(varlist)
+}
+
+// VariableSize returns the number of bytes v occupies in the varstore,
+// including its header and alignment padding.
+func VariableSize(v *efi.EfiVar) int {
+	return (recordSize(v) + 3) & ^3
+}
+
+// UsedSpace returns the number of bytes varlist occupies in the varstore.
+func UsedSpace(varlist efi.EfiVarList) int {
+	used := 0
+	for _, v := range varlist {
+		used += VariableSize(v)
+	}
+	return used
+}
+
+// MaxVariableSize returns the largest record EDK2 accepts for a variable
+// with the attributes of v.
+func MaxVariableSize(v *efi.EfiVar) int {
+	switch {
+	case v.Attr&efi.EfiVariableHardwareErrorRecord != 0:
+		return DefaultMaxHwErrVariableSize
+	case v.Attr&(efi.EfiVariableAuthenticatedWriteAccess|
+		efi.EfiVariableTimeBasedAuthenticatedWriteAccess) != 0:
+		return DefaultMaxAuthVariableSize
+	default:
+		return DefaultMaxVariableSize
+	}
+}
+
+// CheckVariable reports whether v is within its per-variable size limit.
+func CheckVariable(v *efi.EfiVar) error {
+	size, limit := recordSize(v), MaxVariableSize(v)
+	if size > limit {
+		return fmt.Errorf("%w: %s is %d bytes, limit is %d", ErrVariableTooLarge, v.Name, size, limit)
+	}
+	return nil
+}
+
+// CheckQuota reports whether varlist can be written to the varstore.
+func (vs *Edk2VarStore) CheckQuota(varlist efi.EfiVarList) error {
+	keys := make([]string, 0, len(varlist))
+	for k := range varlist {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if err := CheckVariable(varlist[key]); err != nil {
+			return err
+		}
+	}
+	return vs.checkFree(vs.FreeSpaceFor(varlist))
+}
+
+// CheckSet reports whether v can be stored in varlist, replacing any
+// variable with the same name and vendor GUID, and still be written to
+// the varstore.
+func (vs *Edk2VarStore) CheckSet(varlist efi.EfiVarList, v *efi.EfiVar) error {
+	if err := CheckVariable(v); err != nil {
+		return err
+	}
+	free := vs.FreeSpaceFor(varlist) - VariableSize(v)
+	if old, found := varlist[v.Key()]; found {
+		free += VariableSize(old)
+	}
+	return vs.checkFree(free)
+}
+
+func (vs *Edk2VarStore) checkFree(free int) error {
+	if free < 0 {
+		return fmt.Errorf("%w, need %d more bytes", ErrQuotaExceeded, -free)
+	}
+	return nil
+}
+
+// recordSize returns the unpadded size of the varstore record for v.
+func recordSize(v *efi.EfiVar) int {
+	return varHeaderSize + v.Name.Size() + len(v.Data)
+}
+
 func (vs *Edk2VarStore) ReadBytes(varlist efi.EfiVarList) (io.Reader, error) {
-	blob, err := vs.bytesVarStore(varlist)
+	blob, err := vs.serialize(vs.path, varlist)
 	if err != nil {
-		vs.Logger.Error(err, "failed to convert varlist to bytes")
 		return nil, err
 	}
 	return bytes.NewReader(blob), nil
 }
 
 func (vs *Edk2VarStore) ReadAll(varlist efi.EfiVarList) ([]byte, error) {
-	blob, err := vs.bytesVarStore(varlist)
-	if err != nil {
-		vs.Logger.Error(err, "failed to convert varlist to bytes")
-		return nil, err
-	}
-	return blob, nil
+	return vs.serialize(vs.path, varlist)
 }
 
 func (vs *Edk2VarStore) WriteVarStore(filename string, varlist efi.EfiVarList) error {
-	vs.Logger.Info("writing raw edk2 varstore to %s", filename)
-	blob, err := vs.bytesVarStore(varlist)
+	vs.Logger.V(1).Info("writing edk2 varstore", "filename", filename, "count", len(varlist))
+	blob, err := vs.serialize(filename, varlist)
 	if err != nil {
-		vs.Logger.Error(err, "failed to convert varlist to bytes")
 		return err
 	}
-
-	if err := os.WriteFile(filename, blob, 0o644); err != nil {
-		vs.Logger.Error(err, "failed to write file", "filename", filename)
+	timeout := vs.LockTimeout
+	if timeout == 0 {
+		timeout = filelock.DefaultTimeout
+	}
+	unlock, err := filelock.Lock(filename, timeout)
+	if err != nil {
 		return err
 	}
-	return nil
+	defer unlock()
+	return atomicfile.WriteFile(filename, blob, 0o644)
 }
 
 func (vs *Edk2VarStore) findNvData(data []byte) int {
+	if offsets := vs.findNvDataAll(data); len(offsets) > 0 {
+		return offsets[0]
+	}
+	return -1
+}
+
+// findNvDataAll returns the offsets of all NVDATA volumes of data.
+func (vs *Edk2VarStore) findNvDataAll(data []byte) []int {
+	var offsets []int
 	offset := 0
 	for offset+64 < len(data) {
 		guid := efi.ParseBinGUID(data, offset+16)
-		if guid.String() == efi.NvData {
-			return offset
-		}
-		if guid.String() == efi.Ffs {
+		if guid.String() == efi.NvData || guid.String() == efi.Ffs {
+			if guid.String() == efi.NvData {
+				offsets = append(offsets, offset)
+			}
 			tlen := binary.LittleEndian.Uint64(data[offset+32 : offset+40])
-			offset += int(tlen)
-			continue
+			if tlen > 0 && tlen <= uint64(len(data)-offset) {
+				offset += int(tlen)
+				continue
+			}
 		}
 		offset += 1024
 	}
-	return -1
+	return offsets
 }
 
 func (vs *Edk2VarStore) readFile(filename string) error {
-	vs.Logger.Info("reading raw edk2 varstore from %s", filename)
+	vs.Logger.V(1).Info("reading edk2 varstore", "filename", filename)
 	data, err := os.ReadFile(filename)
 	if err != nil {
-		vs.Logger.Error(err, "failed to read file", "filename", filename)
 		return err
 	}
+	vs.path = filename
 	vs.data = data
 	return nil
 }
 
+// parseVolume finds the NVDATA volumes of the image and selects the first
+// one holding a valid varstore. Volumes that do not are logged and listed
+// by Volumes with their error.
 func (e *Edk2VarStore) parseVolume() error {
-	offset := e.findNvData(e.data)
-	if offset < 1 {
+	offsets := e.findNvDataAll(e.data)
+	if len(offsets) == 0 {
 		return fmt.Errorf("varstore not found")
 	}
 
+	e.volumes = make([]Volume, 0, len(offsets))
+	for _, offset := range offsets {
+		v, err := e.parseVolumeAt(offset)
+		if err != nil {
+			v.Err = err
+			e.Logger.Info("skipping invalid varstore volume", "offset", offset, "error", err.Error())
+		}
+		e.volumes = append(e.volumes, v)
+	}
+	for i, v := range e.volumes {
+		if v.Err == nil {
+			e.selectVolume(i)
+			return nil
+		}
+	}
+	return e.volumes[0].Err
+}
+
+// parseVolumeAt parses the NVDATA volume at offset.
+func (e *Edk2VarStore) parseVolumeAt(offset int) (Volume, error) {
+	vol := Volume{Offset: offset}
 	guid := efi.ParseBinGUID(e.data, offset+16)
 
 	// Equivalent to struct.unpack_from("=QLLHHHxBLL", self.filedata, offset + 32)
@@ -155,114 +491,97 @@ func (e *Edk2VarStore) parseVolume() error {
 
 	// Read in same order as Python struct unpacking
 	if err := binary.Read(r, binary.LittleEndian, &vlen); err != nil {
-		return fmt.Errorf("failed to read vlen: %w", err)
+		return vol, fmt.Errorf("failed to read vlen: %w", err)
 	}
 	if err := binary.Read(r, binary.LittleEndian, &sig); err != nil {
-		return fmt.Errorf("failed to read sig: %w", err)
+		return vol, fmt.Errorf("failed to read sig: %w", err)
 	}
 	if err := binary.Read(r, binary.LittleEndian, &attr); err != nil {
-		return fmt.Errorf("failed to read attr: %w", err)
+		return vol, fmt.Errorf("failed to read attr: %w", err)
 	}
 	if err := binary.Read(r, binary.LittleEndian, &hlen); err != nil {
-		return fmt.Errorf("failed to read hlen: %w", err)
+		return vol, fmt.Errorf("failed to read hlen: %w", err)
 	}
 	if err := binary.Read(r, binary.LittleEndian, &csum); err != nil {
-		return fmt.Errorf("failed to read csum: %w", err)
+		return vol, fmt.Errorf("failed to read csum: %w", err)
 	}
 	if err := binary.Read(r, binary.LittleEndian, &xoff); err != nil {
-		return fmt.Errorf("failed to read xoff: %w", err)
+		return vol, fmt.Errorf("failed to read xoff: %w", err)
 	}
 
 	// Skip the pad byte (equivalent to 'x' in struct format)
 	if _, err := r.Seek(1, io.SeekCurrent); err != nil {
-		return fmt.Errorf("failed to skip pad byte: %w", err)
+		return vol, fmt.Errorf("failed to skip pad byte: %w", err)
 	}
 
 	if err := binary.Read(r, binary.LittleEndian, &rev); err != nil {
-		return fmt.Errorf("failed to read rev: %w", err)
+		return vol, fmt.Errorf("failed to read rev: %w", err)
 	}
 	if err := binary.Read(r, binary.LittleEndian, &blocks); err != nil {
-		return fmt.Errorf("failed to read blocks: %w", err)
+		return vol, fmt.Errorf("failed to read blocks: %w", err)
 	}
 	if err := binary.Read(r, binary.LittleEndian, &blksize); err != nil {
-		return fmt.Errorf("failed to read blksize: %w", err)
+		return vol, fmt.Errorf("failed to read blksize: %w", err)
 	}
 
-	e.Logger.Info("vol=%s vlen=0x%x rev=%d blocks=%d*%d (0x%x)",
-		efi.GuidName(guid), vlen, rev, blocks, blksize, blocks*blksize)
+	e.Logger.V(1).Info("found firmware volume", "offset", offset, "guid", efi.GuidName(guid),
+		"length", vlen, "revision", rev, "blocks", blocks, "blockSize", blksize)
 
 	if sig != 0x4856465f {
-		err := fmt.Errorf("invalid signature: 0x%x", sig)
-		e.Logger.Error(err, "sig", sig)
-		return err
+		return vol, fmt.Errorf("invalid signature: 0x%x", sig)
 	}
 
 	if guid.String() != efi.NvData {
-		err := fmt.Errorf("not a volume: %s", guid)
-		e.Logger.Error(err, "guid", guid)
-		return err
+		return vol, fmt.Errorf("not a volume: %s", guid)
 	}
 
-	return e.parseVarstore(offset + int(hlen))
+	if vlen <= uint64(len(e.data)-offset) {
+		vol.Size = int(vlen)
+	}
+	start, end, err := e.parseVarstore(offset + int(hlen))
+	if err != nil {
+		return vol, err
+	}
+	vol.Start, vol.End = start, end
+	return vol, nil
 }
 
-func (vs *Edk2VarStore) parseVarstore(start int) error {
+// parseVarstore parses the varstore header at start and returns the
+// bounds of its variable area.
+func (vs *Edk2VarStore) parseVarstore(start int) (int, int, error) {
+	if start < 0 || len(vs.data)-start < varStoreHeaderSize {
+		return 0, 0, fmt.Errorf("varstore header at 0x%x is outside the image", start)
+	}
 	guid := efi.ParseBinGUID(vs.data, start)
 	size := binary.LittleEndian.Uint32(vs.data[start+16 : start+20])
 	storefmt := vs.data[start+20]
 	state := vs.data[start+21]
 
-	vs.Logger.Info("varstore=%s size=0x%x format=0x%x state=0x%x",
-		efi.GuidName(guid), size, storefmt, state)
+	vs.Logger.V(1).Info("found varstore", "offset", start, "guid", efi.GuidName(guid),
+		"size", size, "format", storefmt, "state", state)
 
 	if guid.String() != efi.AuthVars {
-		return fmt.Errorf("unknown varstore guid: %s", guid)
+		return 0, 0, fmt.Errorf("unknown varstore guid: %s", guid)
 	}
 	if storefmt != 0x5a {
-		return fmt.Errorf("unknown varstore format: 0x%x", storefmt)
+		return 0, 0, fmt.Errorf("unknown varstore format: 0x%x", storefmt)
 	}
 	if state != 0xfe {
-		return fmt.Errorf("unknown varstore state: 0x%x", state)
+		return 0, 0, fmt.Errorf("unknown varstore state: 0x%x", state)
 	}
 
-	vs.start = start + 16 + 12
-	vs.end = start + int(size)
-	vs.Logger.Info("var store range: 0x%x -> 0x%x", vs.start, vs.end)
-	return nil
+	if size < varStoreHeaderSize || uint64(size) > uint64(len(vs.data)-start) {
+		return 0, 0, fmt.Errorf("invalid varstore size: 0x%x", size)
+	}
+	return start + varStoreHeaderSize, start + int(size), nil
 }
 
 // BytesVar converts an EFI variable to its binary representation.
+// The variable must have been validated beforehand.
 func (vs *Edk2VarStore) bytesVar(v *efi.EfiVar) []byte {
-	// Allocate a buffer for the binary data
-	buf := new(bytes.Buffer)
-
-	// Equivalent to struct.pack("=HBxLQ", 0x55aa, 0x3f, var.attr, var.count)
-	_ = binary.Write(buf, binary.LittleEndian, uint16(0x55aa))
-	_ = binary.Write(buf, binary.LittleEndian, uint8(0x3f))
-	_ = binary.Write(buf, binary.LittleEndian, uint8(0)) // padding byte (x)
-	_ = binary.Write(buf, binary.LittleEndian, v.Attr)
-	_ = binary.Write(buf, binary.LittleEndian, uint64(v.Count))
-
-	// Append time bytes
-	timeBytes := v.BytesTime()
-	buf.Write(timeBytes)
-
-	// Equivalent to struct.pack("=LLL", var.pkidx, var.name.size(), len(var.data))
-	_ = binary.Write(buf, binary.LittleEndian, uint32(v.PkIdx))
-	_ = binary.Write(buf, binary.LittleEndian, uint32(v.Name.Size()))
-	_ = binary.Write(buf, binary.LittleEndian, uint32(len(v.Data)))
-
-	// Append GUID bytes in little-endian format
-	buf.Write(v.Guid.Bytes())
-
-	// Append name bytes
-	buf.Write(v.Name.Bytes())
-
-	// Append data bytes
-	buf.Write(v.Data)
+	blob, _ := v.Serialize()
 
 	// Pad to 4-byte boundary with 0xFF bytes
-	blob := buf.Bytes()
 	padding := (4 - len(blob)%4) % 4
 	for range padding {
 		blob = append(blob, 0xFF)
@@ -272,6 +591,17 @@ func (vs *Edk2VarStore) bytesVar(v *efi.EfiVar) []byte {
 }
 
 func (vs *Edk2VarStore) bytesVarList(varlist efi.EfiVarList) ([]byte, error) {
+	if err := varlist.Validate(); err != nil {
+		return nil, err
+	}
+	if err := vs.CheckQuota(varlist); err != nil {
+		return nil, err
+	}
+
+	if vs.Deterministic {
+		varlist = canonicalVarList(varlist)
+	}
+
 	blob := []byte{}
 	keys := make([]string, 0, len(varlist))
 	for k := range varlist {
@@ -281,28 +611,102 @@ func (vs *Edk2VarStore) bytesVarList(varlist efi.EfiVarList) ([]byte, error) {
 	for _, key := range keys {
 		blob = append(blob, vs.bytesVar(varlist[key])...)
 	}
-	if len(blob) > vs.end-vs.start {
-		err := fmt.Errorf("varstore is too small: %d > %d", len(blob), vs.end-vs.start)
-		vs.Logger.Error(err, "size", len(blob), "max", vs.end-vs.start)
-		return nil, err
-	}
 	return blob, nil
 }
 
-func (vs *Edk2VarStore) bytesVarStore(varlist efi.EfiVarList) ([]byte, error) {
-	blob := slices.Clone(vs.data[:vs.start])
+// canonicalVarList returns copies of the variables of varlist keyed by
+// name and GUID, with the timestamps Deterministic writes.
+func canonicalVarList(varlist efi.EfiVarList) efi.EfiVarList {
+	canonical := make(efi.EfiVarList, len(varlist))
+	for _, v := range varlist {
+		c := *v
+		c.Time, c.TimeZone, c.Daylight = nil, 0, 0
+		if v.Attr&efi.EfiVariableTimeBasedAuthenticatedWriteAccess != 0 {
+			ts := DeterministicTime
+			c.Time = &ts
+		}
+		canonical[c.Key()] = &c
+	}
+	return canonical
+}
+
+// serialize returns the image holding varlist, reporting its Metrics for
+// the file path.
+func (vs *Edk2VarStore) serialize(path string, varlist efi.EfiVarList) ([]byte, error) {
+	fn := vs.metricsFunc()
+	if fn == nil {
+		return vs.bytesVarStore(varlist)
+	}
+	start := time.Now()
+	blob, err := vs.bytesVarStore(varlist)
+	vs.report(fn, OpSerialize, path, varlist, start, err)
+	return blob, err
+}
 
-	// Append the variable list
+func (vs *Edk2VarStore) bytesVarStore(varlist efi.EfiVarList) ([]byte, error) {
 	newVarList, err := vs.bytesVarList(varlist)
 	if err != nil {
-		vs.Logger.Error(err, "failed to convert varlist to bytes")
 		return nil, err
 	}
+	blob := slices.Clone(vs.data)
+	fillVarArea(blob[vs.start:vs.end], newVarList)
+	if vs.Wipe {
+		tailEnd := vs.volEnd
+		if tailEnd <= vs.end {
+			tailEnd = len(blob)
+		}
+		vs.wipeStale(blob[vs.end:tailEnd], varlist)
+	}
+	if !vs.Mirror {
+		return blob, nil
+	}
 
-	blob = append(blob, newVarList...)
-	for len(blob) < vs.end {
-		blob = append(blob, 0xff)
+	for _, v := range vs.mirrors() {
+		if len(newVarList) > v.Capacity() {
+			return nil, fmt.Errorf("%w: standby varstore at 0x%x holds %d bytes, need %d",
+				ErrQuotaExceeded, v.Offset, v.Capacity(), len(newVarList))
+		}
+		fillVarArea(blob[v.Start:v.End], newVarList)
+		if vs.Wipe && v.Size > 0 && v.Offset+v.Size > v.End {
+			vs.wipeStale(blob[v.End:v.Offset+v.Size], varlist)
+		}
 	}
-	blob = append(blob, vs.data[vs.end:]...)
 	return blob, nil
 }
+
+// fillVarArea writes records to the variable area area, padding it with
+// erased flash.
+func fillVarArea(area, records []byte) {
+	n := copy(area, records)
+	for i := n; i < len(area); i++ {
+		area[i] = 0xff
+	}
+}
+
+// wipeStale zeroes the data of the variable records in region that are not
+// in varlist with the same data.
+func (vs *Edk2VarStore) wipeStale(region []byte, varlist efi.EfiVarList) {
+	for pos := 0; pos+varHeaderSize <= len(region); pos += 4 {
+		if binary.LittleEndian.Uint16(region[pos:]) != varMagic {
+			continue
+		}
+		nsize := binary.LittleEndian.Uint32(region[pos+36:])
+		dsize := binary.LittleEndian.Uint32(region[pos+40:])
+		nameStart := pos + varHeaderSize
+		if uint64(nsize)+uint64(dsize) > uint64(len(region)-nameStart) {
+			continue
+		}
+		dataStart := nameStart + int(nsize)
+		recordEnd := dataStart + int(dsize)
+
+		v, err := efi.ParseEfiVar(region[pos:recordEnd])
+		if err != nil {
+			continue
+		}
+		if live, found := varlist.Get(v.Name.String(), v.Guid); !found || !bytes.Equal(live.Data, v.Data) {
+			vs.Logger.V(1).Info("wiping stale variable data", "name", v.Name.String(), "size", dsize)
+			clear(region[dataStart:recordEnd])
+		}
+		pos = ((recordEnd + 3) & ^3) - 4
+	}
+}
@@ -1,11 +1,18 @@
 package varstore
 
 import (
+	"bytes"
+	"errors"
+	"os"
 	"reflect"
+	"slices"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/go-logr/logr"
 	"github.com/metal3-community/uefi-firmware-manager/efi"
+	"github.com/metal3-community/uefi-firmware-manager/testutil/golden"
 )
 
 func TestNewEdk2VarStore(t *testing.T) {
@@ -215,7 +222,7 @@ func TestEdk2VarStore_parseVarstore(t *testing.T) {
 				end:    tt.fields.end,
 				Logger: tt.fields.Logger,
 			}
-			if err := vs.parseVarstore(tt.args.start); (err != nil) != tt.wantErr {
+			if _, _, err := vs.parseVarstore(tt.args.start); (err != nil) != tt.wantErr {
 				t.Errorf("Edk2VarStore.parseVarstore() error = %v, wantErr %v", err, tt.wantErr)
 			}
 		})
@@ -332,3 +339,367 @@ func TestEdk2VarStore_bytesVarStore(t *testing.T) {
 		})
 	}
 }
+
+func TestEdk2VarStore_CheckSet(t *testing.T) {
+	newVar := func(name string, size int) *efi.EfiVar {
+		return &efi.EfiVar{
+			Name: efi.FromString(name),
+			Guid: efi.EFI_GLOBAL_VARIABLE_GUID,
+			Attr: efi.EfiVariableDefault,
+			Data: make([]byte, size),
+		}
+	}
+	// "Timeout" encodes to 16 bytes, so each record is 76 bytes plus data.
+	vs := &Edk2VarStore{start: 0, end: 200}
+	varlist := efi.NewEfiVarList()
+	varlist.Set(newVar("Timeout", 4))
+
+	tests := []struct {
+		name    string
+		v       *efi.EfiVar
+		wantErr error
+	}{
+		{
+			name: "replaces existing variable",
+			v:    newVar("Timeout", 100),
+		},
+		{
+			name: "adds variable that fits",
+			v:    newVar("Timeoux", 44),
+		},
+		{
+			name:    "adds variable that does not fit",
+			v:       newVar("Timeoux", 48),
+			wantErr: ErrQuotaExceeded,
+		},
+		{
+			name:    "exceeds per-variable limit",
+			v:       newVar("Timeout", DefaultMaxVariableSize),
+			wantErr: ErrVariableTooLarge,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := vs.CheckSet(varlist, tt.v)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Edk2VarStore.CheckSet() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	if got := vs.FreeSpaceFor(varlist); got != 200-80 {
+		t.Errorf("Edk2VarStore.FreeSpaceFor() = %v, want %v", got, 200-80)
+	}
+	varlist.Set(newVar("Timeoux", 48))
+	err := vs.CheckQuota(varlist)
+	if !errors.Is(err, ErrQuotaExceeded) || !strings.Contains(err.Error(), "need 4 more bytes") {
+		t.Errorf("Edk2VarStore.CheckQuota() error = %v, want quota exceeded by 4 bytes", err)
+	}
+}
+
+func TestEdk2VarStore_GetVarListRecover(t *testing.T) {
+	vs := &Edk2VarStore{Logger: logr.Discard()}
+	first := vs.bytesVar(&efi.EfiVar{
+		Name: efi.FromString("Timeout"),
+		Guid: efi.EFI_GLOBAL_VARIABLE_GUID,
+		Attr: efi.EfiVariableDefault,
+		Data: []byte{0x05, 0x00},
+	})
+	second := vs.bytesVar(&efi.EfiVar{
+		Name: efi.FromString("BootNext"),
+		Guid: efi.EFI_GLOBAL_VARIABLE_GUID,
+		Attr: efi.EfiVariableDefault,
+		Data: []byte{0x01, 0x00},
+	})
+	garbage := []byte{0xde, 0xad, 0xbe, 0xef, 0x00, 0x00, 0x00, 0x00}
+
+	data := append(append(append([]byte{}, first...), garbage...), second...)
+	data = append(data, bytes.Repeat([]byte{0xff}, 64)...)
+	vs.data, vs.start, vs.end = data, 0, len(data)
+
+	if _, err := vs.GetVarList(); !errors.Is(err, ErrCorrupt) {
+		t.Fatalf("Edk2VarStore.GetVarList() error = %v, want ErrCorrupt", err)
+	}
+
+	vs.Recover = true
+	got, err := vs.GetVarList()
+	if err != nil {
+		t.Fatalf("Edk2VarStore.GetVarList() error = %v", err)
+	}
+	for _, name := range []string{"Timeout", "BootNext"} {
+		if _, found := got.Lookup(name); !found {
+			t.Errorf("Edk2VarStore.GetVarList() lost %s", name)
+		}
+	}
+	want := []Region{{Offset: len(first), Size: len(garbage), Reason: "bad variable magic 0xadde"}}
+	if !reflect.DeepEqual(vs.Skipped(), want) {
+		t.Errorf("Edk2VarStore.Skipped() = %v, want %v", vs.Skipped(), want)
+	}
+}
+
+func TestEdk2VarStore_GetVarListError(t *testing.T) {
+	vs := &Edk2VarStore{Logger: logr.Discard(), path: "VARS.fd"}
+	pad := bytes.Repeat([]byte{0xff}, 64)
+	record := vs.bytesVar(&efi.EfiVar{
+		Name: efi.FromString("Timeout"),
+		Guid: efi.EFI_GLOBAL_VARIABLE_GUID,
+		Attr: efi.EfiVariableDefault,
+		Data: []byte{0x05, 0x00},
+	})
+	// Claim more data than the varstore holds.
+	record[40] = 0xff
+	vs.data = append(append(slices.Clone(pad), record...), pad...)
+	vs.start, vs.end = len(pad), len(vs.data)
+
+	_, err := vs.GetVarList()
+	if !errors.Is(err, ErrCorrupt) {
+		t.Fatalf("Edk2VarStore.GetVarList() error = %v, want ErrCorrupt", err)
+	}
+	var ve *efi.VarError
+	if !errors.As(err, &ve) {
+		t.Fatalf("Edk2VarStore.GetVarList() error = %v, want a VarError", err)
+	}
+	want := efi.VarError{Name: "Timeout", Guid: efi.EFI_GLOBAL_VARIABLE_GUID, Offset: len(pad), Path: "VARS.fd", Err: ve.Err}
+	if *ve != want {
+		t.Errorf("VarError = %+v, want %+v", *ve, want)
+	}
+	if !strings.Contains(err.Error(), "VARS.fd: variable Timeout") {
+		t.Errorf("Edk2VarStore.GetVarList() error = %q lacks context", err)
+	}
+}
+
+func TestEdk2VarStore_Wipe(t *testing.T) {
+	vs := &Edk2VarStore{Logger: logr.Discard()}
+	timeout := &efi.EfiVar{
+		Name: efi.FromString("Timeout"),
+		Guid: efi.EFI_GLOBAL_VARIABLE_GUID,
+		Attr: efi.EfiVariableDefault,
+		Data: []byte{0x05, 0x00},
+	}
+	secret := &efi.EfiVar{
+		Name: efi.FromString("ISCSIChapSecret"),
+		Guid: efi.EFI_GLOBAL_VARIABLE_GUID,
+		Attr: efi.EfiVariableDefault,
+		Data: []byte("hunter2hunter2"),
+	}
+
+	// The store holds both variables, the tail an older copy of them as
+	// left in the fault tolerant write spare area.
+	store := append(vs.bytesVar(timeout), vs.bytesVar(secret)...)
+	store = append(store, bytes.Repeat([]byte{0xff}, 64)...)
+	tail := append(vs.bytesVar(timeout), vs.bytesVar(secret)...)
+	vs.data = append(slices.Clone(store), tail...)
+	vs.start, vs.end = 0, len(store)
+
+	varlist := efi.NewEfiVarList()
+	varlist.Set(timeout)
+
+	blob, err := vs.bytesVarStore(varlist)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(blob[vs.end:], secret.Data) {
+		t.Fatal("bytesVarStore() changed the tail without Wipe")
+	}
+
+	vs.Wipe = true
+	blob, err = vs.bytesVarStore(varlist)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(blob, secret.Data) {
+		t.Error("bytesVarStore() kept the data of a deleted variable")
+	}
+	if !bytes.Equal(blob[vs.end:vs.end+len(vs.bytesVar(timeout))], vs.bytesVar(timeout)) {
+		t.Error("bytesVarStore() wiped a copy of a live variable")
+	}
+	if len(blob) != len(vs.data) {
+		t.Errorf("bytesVarStore() size = %d, want %d", len(blob), len(vs.data))
+	}
+}
+
+func TestEdk2VarStore_Deterministic(t *testing.T) {
+	vs := &Edk2VarStore{Logger: logr.Discard()}
+	vs.data = bytes.Repeat([]byte{0xff}, 512)
+	vs.start, vs.end = 0, len(vs.data)
+
+	// Two runs setting the same variables at different times, one keying
+	// them by name as hand built lists do.
+	build := func(ts time.Time, byName bool) efi.EfiVarList {
+		timeout := &efi.EfiVar{
+			Name: efi.FromString("Timeout"),
+			Guid: efi.EFI_GLOBAL_VARIABLE_GUID,
+			Attr: efi.EfiVariableDefault,
+			Data: []byte{0x05, 0x00},
+			Time: &ts,
+		}
+		db := &efi.EfiVar{
+			Name: efi.FromString("db"),
+			Guid: efi.EFI_IMAGE_SECURITY_DATABASE,
+			Attr: efi.EfiVariableDefault | efi.EfiVariableTimeBasedAuthenticatedWriteAccess,
+			Data: []byte("signature list"),
+			Time: &ts,
+		}
+		varlist := efi.NewEfiVarList()
+		if byName {
+			varlist["Timeout"], varlist["db"] = timeout, db
+		} else {
+			varlist.Set(timeout)
+			varlist.Set(db)
+		}
+		return varlist
+	}
+	first := build(time.Date(2024, 5, 6, 7, 8, 9, 10, time.UTC), false)
+	second := build(time.Date(2025, 1, 2, 3, 4, 5, 6, time.UTC), true)
+
+	a, err := vs.bytesVarStore(first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := vs.bytesVarStore(second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(a, b) {
+		t.Fatal("bytesVarStore() ignored the timestamps without Deterministic")
+	}
+
+	vs.Deterministic = true
+	a, err = vs.bytesVarStore(first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err = vs.bytesVarStore(second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(a, b) {
+		t.Errorf("bytesVarStore() differs between runs:\n%s", golden.Diff(a, b))
+	}
+
+	vs.data = a
+	varlist, err := vs.GetVarList()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := varlist.Lookup("Timeout"); v.Time != nil {
+		t.Errorf("Timeout time = %v, want none", v.Time)
+	}
+	if v, _ := varlist.Lookup("db"); v.Time == nil || !v.Time.Equal(DeterministicTime) {
+		t.Errorf("db time = %v, want %v", v.Time, DeterministicTime)
+	}
+	if v, _ := first.Get("Timeout", efi.EFI_GLOBAL_VARIABLE_GUID); v.Time == nil || v.Time.Year() != 2024 {
+		t.Error("bytesVarStore() modified the written list")
+	}
+}
+
+func TestEdk2VarStore_Golden(t *testing.T) {
+	vs := &Edk2VarStore{Logger: logr.Discard()}
+	// A volume header, a 512 byte varstore and a tail standing in for the
+	// fault tolerant write areas.
+	vs.data = append(bytes.Repeat([]byte{0x48}, 64), bytes.Repeat([]byte{0xff}, 512)...)
+	vs.data = append(vs.data, bytes.Repeat([]byte{0x54}, 32)...)
+	vs.start, vs.end = 64, 64+512
+
+	timeout := &efi.EfiVar{
+		Name: efi.FromString("Timeout"),
+		Guid: efi.EFI_GLOBAL_VARIABLE_GUID,
+		Attr: efi.EfiVariableDefault,
+		Data: []byte{0x05, 0x00},
+	}
+	ts := time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC)
+	db := &efi.EfiVar{
+		Name: efi.FromString("db"),
+		Guid: efi.EFI_IMAGE_SECURITY_DATABASE,
+		Attr: efi.EfiVariableDefault | efi.EfiVariableTimeBasedAuthenticatedWriteAccess,
+		Data: []byte("signature list"),
+		Time: &ts,
+	}
+	golden.Assert(t, "timeout-var", vs.bytesVar(timeout))
+	golden.Assert(t, "db-var", vs.bytesVar(db))
+
+	varlist := efi.NewEfiVarList()
+	varlist.Set(timeout)
+	varlist.Set(db)
+	blob, err := vs.bytesVarStore(varlist)
+	if err != nil {
+		t.Fatal(err)
+	}
+	golden.Assert(t, "varstore", blob)
+}
+
+func FuzzGetVarList(f *testing.F) {
+	vs := &Edk2VarStore{Logger: logr.Discard()}
+	record := vs.bytesVar(&efi.EfiVar{
+		Name: efi.FromString("Timeout"),
+		Guid: efi.EFI_GLOBAL_VARIABLE_GUID,
+		Attr: efi.EfiVariableDefault,
+		Data: []byte{0x05, 0x00},
+	})
+	f.Add(record)
+	f.Add(append(slices.Clone(record), record...))
+	f.Add(append(slices.Clone(record), bytes.Repeat([]byte{0xff}, 16)...))
+	f.Add(record[:varHeaderSize])
+	f.Add([]byte{})
+
+	// The varstore of the shipped firmware, without the free space.
+	image, err := os.ReadFile("../edk2/RPI_EFI.fd")
+	if err != nil {
+		f.Fatal(err)
+	}
+	shipped, err := New(image)
+	if err != nil {
+		f.Fatal(err)
+	}
+	f.Add(bytes.TrimRight(shipped.data[shipped.start:shipped.end], "\xff"))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		for _, recover := range []bool{false, true} {
+			vs := &Edk2VarStore{data: data, end: len(data), Recover: recover, Logger: logr.Discard()}
+			if _, err := vs.GetVarList(); err != nil && recover {
+				t.Errorf("Edk2VarStore.GetVarList() in recovery mode error = %v", err)
+			}
+		}
+		_, _ = New(data)
+	})
+}
+
+func TestEdk2VarStore_GetVarListOptions(t *testing.T) {
+	vs := &Edk2VarStore{Logger: logr.Discard()}
+	var data []byte
+	for _, v := range []*efi.EfiVar{
+		{Name: efi.FromString("Timeout"), Guid: efi.EFI_GLOBAL_VARIABLE_GUID, Attr: efi.EfiVariableDefault},
+		{Name: efi.FromString("Lang"), Guid: efi.EFI_GLOBAL_VARIABLE_GUID, Attr: efi.EfiVariableRuntimeAccess},
+	} {
+		data = append(data, vs.bytesVar(v)...)
+	}
+	vs.data, vs.start, vs.end = data, 0, len(data)
+
+	tests := []struct {
+		name    string
+		opts    efi.ParseOptions
+		want    int
+		wantErr bool
+	}{
+		{name: "lenient", opts: efi.ParseOptions{}, want: 2},
+		{name: "strict", opts: efi.StrictParseOptions, wantErr: true},
+		{
+			name:    "strict with unknown guids",
+			opts:    efi.ParseOptions{Strict: true, AllowUnknownGUIDs: true},
+			wantErr: true,
+		},
+		{name: "max vars", opts: efi.ParseOptions{MaxVars: 1}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vs.Options = tt.opts
+			got, err := vs.GetVarList()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Edk2VarStore.GetVarList() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(got) != tt.want {
+				t.Errorf("Edk2VarStore.GetVarList() = %d variables, want %d", len(got), tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,186 @@
+package varstore
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/go-logr/logr"
+	"github.com/metal3-community/uefi-firmware-manager/efi"
+)
+
+// EfivarfsDir is where Linux mounts efivarfs.
+const EfivarfsDir = "/sys/firmware/efi/efivars"
+
+// EfivarfsVarStore reads and writes variables in the Linux efivarfs layout:
+// one <Name>-<GUID> file per variable, holding the 32-bit little endian
+// attributes followed by the variable data.
+type EfivarfsVarStore struct {
+	dir string
+
+	// Logger logs at the same levels as Edk2VarStore.Logger.
+	Logger logr.Logger
+}
+
+// NewEfivarfsVarStore returns a store for the efivarfs style directory dir.
+func NewEfivarfsVarStore(dir string) *EfivarfsVarStore {
+	return &EfivarfsVarStore{dir: dir}
+}
+
+// EfivarfsName returns the efivarfs file name of v.
+func EfivarfsName(v *efi.EfiVar) (string, error) {
+	if v.Name == nil || v.Name.String() == "" {
+		return "", fmt.Errorf("variable has no name")
+	}
+	name := v.Name.String()
+	if strings.ContainsRune(name, '/') {
+		return "", fmt.Errorf("variable name %q cannot be used as a file name", name)
+	}
+	return name + "-" + v.Guid.String(), nil
+}
+
+// GetVarList reads every <Name>-<GUID> file in the store's directory.
+// Other files and subdirectories are ignored.
+func (vs *EfivarfsVarStore) GetVarList() (efi.EfiVarList, error) {
+	vs.Logger.V(1).Info("reading efivarfs variables", "dir", vs.dir)
+	entries, err := os.ReadDir(vs.dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read efivarfs directory: %w", err)
+	}
+
+	varlist := efi.NewEfiVarList()
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		name, guid, ok := efi.ParseVarKey(entry.Name())
+		if !ok {
+			vs.Logger.V(1).Info("skipping file without variable guid", "filename", entry.Name())
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(vs.dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		v, err := parseEfivarfsFile(name, guid, data)
+		if err != nil {
+			return nil, &efi.VarError{
+				Name:   name,
+				Guid:   guid,
+				Offset: -1,
+				Path:   filepath.Join(vs.dir, entry.Name()),
+				Err:    err,
+			}
+		}
+		varlist.Set(v)
+	}
+	return varlist, nil
+}
+
+// parseEfivarfsFile decodes the contents of an efivarfs file.
+func parseEfivarfsFile(name string, guid efi.GUID, data []byte) (*efi.EfiVar, error) {
+	if len(data) < 4 {
+		return nil, fmt.Errorf("file too short for attributes: %d bytes", len(data))
+	}
+	ucsName, err := efi.UCS16FromString(name)
+	if err != nil {
+		return nil, fmt.Errorf("invalid variable name: %w", err)
+	}
+	return &efi.EfiVar{
+		Name: ucsName,
+		Guid: guid,
+		Attr: binary.LittleEndian.Uint32(data[0:4]),
+		Data: data[4:],
+	}, nil
+}
+
+// WriteVarStore writes every variable in varlist to dir, or to the store's
+// own directory if dir is empty. Existing files for other variables are left
+// alone.
+//
+// Time based authenticated variables are written as efivarfs reads them;
+// applying them on a running system additionally needs a signed
+// EFI_VARIABLE_AUTHENTICATION_2 payload.
+func (vs *EfivarfsVarStore) WriteVarStore(dir string, varlist efi.EfiVarList) error {
+	if dir == "" {
+		dir = vs.dir
+	}
+	vs.Logger.V(1).Info("writing efivarfs variables", "dir", dir, "count", len(varlist))
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create efivarfs directory: %w", err)
+	}
+
+	keys := make([]string, 0, len(varlist))
+	for k := range varlist {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		v := varlist[key]
+		name, err := EfivarfsName(v)
+		if err != nil {
+			return fmt.Errorf("failed to export %s: %w", key, err)
+		}
+		if v.Attr&efi.EfiVariableTimeBasedAuthenticatedWriteAccess != 0 {
+			vs.Logger.Info("exporting authenticated variable without signature", "name", name)
+		}
+
+		if err := os.WriteFile(filepath.Join(dir, name), efivarfsFile(v), 0o644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// efivarfsFile encodes v as an efivarfs file: the attributes followed by
+// the data.
+func efivarfsFile(v *efi.EfiVar) []byte {
+	blob := binary.LittleEndian.AppendUint32(make([]byte, 0, 4+len(v.Data)), v.Attr)
+	return append(blob, v.Data...)
+}
+
+// SetVar writes a single variable to the store's directory. On a mounted
+// efivarfs the attributes and data go to the firmware in one write, and
+// the immutable flag the kernel sets on most variables is cleared for the
+// write and restored after it.
+func (vs *EfivarfsVarStore) SetVar(v *efi.EfiVar) error {
+	name, err := EfivarfsName(v)
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(vs.dir, name)
+	if !isEfivarfs(vs.dir) {
+		return os.WriteFile(path, efivarfsFile(v), 0o644)
+	}
+
+	restore, err := clearImmutable(path)
+	if err != nil {
+		return err
+	}
+	defer restore()
+
+	// efivarfs replaces the variable on every write and cannot truncate.
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(efivarfsFile(v)); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	return f.Close()
+}
+
+// DeleteVar removes the variable name with vendor guid from the store's
+// directory, clearing its immutable flag first.
+func (vs *EfivarfsVarStore) DeleteVar(name string, guid efi.GUID) error {
+	path := filepath.Join(vs.dir, efi.VarKey(name, guid))
+	if _, err := clearImmutable(path); err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
@@ -0,0 +1,64 @@
+package varstore
+
+import (
+	"errors"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	// efivarfsMagic is the file system type of efivarfs.
+	efivarfsMagic = 0xde5e81e4
+
+	// FS_IOC_GETFLAGS and FS_IOC_SETFLAGS as encoded on x86 and arm.
+	fsIocGetFlags = 2<<30 | unsafe.Sizeof(int(0))<<16 | 'f'<<8 | 1
+	fsIocSetFlags = 1<<30 | unsafe.Sizeof(int(0))<<16 | 'f'<<8 | 2
+	fsImmutableFl = 0x00000010
+)
+
+// isEfivarfs reports whether dir is on a mounted efivarfs.
+func isEfivarfs(dir string) bool {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(dir, &st); err != nil {
+		return false
+	}
+	return uint32(st.Type) == efivarfsMagic
+}
+
+// clearImmutable clears the immutable flag of the file at path, if it
+// exists and has the flag set. The returned function sets it again.
+func clearImmutable(path string) (func(), error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return func() {}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var flags int
+	if err := ioctlFlags(f, fsIocGetFlags, &flags); err != nil || flags&fsImmutableFl == 0 {
+		// File systems without flags have nothing to clear.
+		return func() {}, nil
+	}
+	cleared := flags &^ fsImmutableFl
+	if err := ioctlFlags(f, fsIocSetFlags, &cleared); err != nil {
+		return nil, &os.PathError{Op: "clear immutable flag", Path: path, Err: err}
+	}
+	return func() {
+		if f, err := os.Open(path); err == nil {
+			_ = ioctlFlags(f, fsIocSetFlags, &flags)
+			_ = f.Close()
+		}
+	}, nil
+}
+
+func ioctlFlags(f *os.File, req uintptr, flags *int) error {
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), req, uintptr(unsafe.Pointer(flags)))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
@@ -0,0 +1,14 @@
+//go:build !linux
+
+package varstore
+
+// isEfivarfs reports whether dir is on a mounted efivarfs, which only
+// exists on Linux.
+func isEfivarfs(dir string) bool {
+	return false
+}
+
+// clearImmutable does nothing outside Linux.
+func clearImmutable(path string) (func(), error) {
+	return func() {}, nil
+}
@@ -0,0 +1,179 @@
+package varstore
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/go-logr/logr"
+	"github.com/metal3-community/uefi-firmware-manager/efi"
+)
+
+func TestEfivarfsVarStore_WriteVarStore(t *testing.T) {
+	varlist := efi.NewEfiVarList()
+	varlist.Set(&efi.EfiVar{
+		Name: efi.FromString("Timeout"),
+		Guid: efi.EFI_GLOBAL_VARIABLE_GUID,
+		Attr: efi.EfiVariableDefault | efi.EfiVariableRuntimeAccess,
+		Data: []byte{0x05, 0x00},
+	})
+	varlist.Set(&efi.EfiVar{
+		Name: efi.FromString("ClientId"),
+		Guid: efi.StringToGUID("9fb9a8a1-2f4a-43a6-889c-d0f7b6c47ad5"),
+		Attr: efi.EfiVariableDefault,
+		Data: []byte{0x00, 0x04, 0xaa, 0xbb},
+	})
+
+	dir := filepath.Join(t.TempDir(), "efivars")
+	vs := NewEfivarfsVarStore(dir)
+	vs.Logger = logr.Discard()
+	if err := vs.WriteVarStore("", varlist); err != nil {
+		t.Fatalf("EfivarfsVarStore.WriteVarStore() error = %v", err)
+	}
+
+	want := map[string][]byte{
+		"Timeout-8be4df61-93ca-11d2-aa0d-00e098032b8c":  {0x07, 0x00, 0x00, 0x00, 0x05, 0x00},
+		"ClientId-9fb9a8a1-2f4a-43a6-889c-d0f7b6c47ad5": {0x03, 0x00, 0x00, 0x00, 0x00, 0x04, 0xaa, 0xbb},
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("os.ReadDir() error = %v", err)
+	}
+	got := map[string][]byte{}
+	for _, entry := range entries {
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			t.Fatalf("os.ReadFile() error = %v", err)
+		}
+		got[entry.Name()] = data
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("EfivarfsVarStore.WriteVarStore() wrote %v, want %v", got, want)
+	}
+}
+
+func TestEfivarfsName(t *testing.T) {
+	tests := []struct {
+		name    string
+		v       *efi.EfiVar
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "global variable",
+			v:    &efi.EfiVar{Name: efi.FromString("BootOrder"), Guid: efi.EFI_GLOBAL_VARIABLE_GUID},
+			want: "BootOrder-8be4df61-93ca-11d2-aa0d-00e098032b8c",
+		},
+		{
+			name:    "missing name",
+			v:       &efi.EfiVar{Guid: efi.EFI_GLOBAL_VARIABLE_GUID},
+			wantErr: true,
+		},
+		{
+			name:    "path separator",
+			v:       &efi.EfiVar{Name: efi.FromString("a/b"), Guid: efi.EFI_GLOBAL_VARIABLE_GUID},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := EfivarfsName(tt.v)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("EfivarfsName() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("EfivarfsName() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEfivarfsVarStore_GetVarList(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string][]byte{
+		"Timeout-8be4df61-93ca-11d2-aa0d-00e098032b8c":  {0x07, 0x00, 0x00, 0x00, 0x05, 0x00},
+		"ClientId-9fb9a8a1-2f4a-43a6-889c-d0f7b6c47ad5": {0x03, 0x00, 0x00, 0x00, 0x00, 0x04, 0xaa, 0xbb},
+		"README": []byte("not a variable"),
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0o644); err != nil {
+			t.Fatalf("os.WriteFile() error = %v", err)
+		}
+	}
+
+	vs := NewEfivarfsVarStore(dir)
+	vs.Logger = logr.Discard()
+	got, err := vs.GetVarList()
+	if err != nil {
+		t.Fatalf("EfivarfsVarStore.GetVarList() error = %v", err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("EfivarfsVarStore.GetVarList() = %d variables, want 2", len(got))
+	}
+	timeout, found := got.Get("Timeout", efi.EFI_GLOBAL_VARIABLE_GUID)
+	if !found {
+		t.Fatal("EfivarfsVarStore.GetVarList() lost Timeout")
+	}
+	if timeout.Attr != 0x07 || !reflect.DeepEqual(timeout.Data, []byte{0x05, 0x00}) {
+		t.Errorf("Timeout = attr 0x%x data %v, want attr 0x7 data [5 0]", timeout.Attr, timeout.Data)
+	}
+
+	// Exporting the imported list reproduces the files.
+	out := t.TempDir()
+	if err := vs.WriteVarStore(out, got); err != nil {
+		t.Fatalf("EfivarfsVarStore.WriteVarStore() error = %v", err)
+	}
+	for name, data := range files {
+		if name == "README" {
+			continue
+		}
+		written, err := os.ReadFile(filepath.Join(out, name))
+		if err != nil || !reflect.DeepEqual(written, data) {
+			t.Errorf("round trip %s = %v (%v), want %v", name, written, err, data)
+		}
+	}
+}
+
+func TestEfivarfsVarStore_GetVarListShortFile(t *testing.T) {
+	dir := t.TempDir()
+	name := "Timeout-8be4df61-93ca-11d2-aa0d-00e098032b8c"
+	if err := os.WriteFile(filepath.Join(dir, name), []byte{0x07, 0x00}, 0o644); err != nil {
+		t.Fatalf("os.WriteFile() error = %v", err)
+	}
+	vs := NewEfivarfsVarStore(dir)
+	vs.Logger = logr.Discard()
+	if _, err := vs.GetVarList(); err == nil {
+		t.Error("EfivarfsVarStore.GetVarList() error = nil, want short file error")
+	}
+}
+
+func TestEfivarfsVarStore_SetVarDeleteVar(t *testing.T) {
+	dir := t.TempDir()
+	vs := NewEfivarfsVarStore(dir)
+	v := &efi.EfiVar{
+		Name: efi.FromString("Timeout"),
+		Guid: efi.EFI_GLOBAL_VARIABLE_GUID,
+		Attr: efi.EfiVariableDefault | efi.EfiVariableRuntimeAccess,
+		Data: []byte{0x05, 0x00},
+	}
+	if err := vs.SetVar(v); err != nil {
+		t.Fatalf("EfivarfsVarStore.SetVar() error = %v", err)
+	}
+	path := filepath.Join(dir, "Timeout-8be4df61-93ca-11d2-aa0d-00e098032b8c")
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []byte{0x07, 0x00, 0x00, 0x00, 0x05, 0x00}; !reflect.DeepEqual(got, want) {
+		t.Errorf("EfivarfsVarStore.SetVar() wrote %x, want %x", got, want)
+	}
+
+	if err := vs.DeleteVar("Timeout", efi.EFI_GLOBAL_VARIABLE_GUID); err != nil {
+		t.Fatalf("EfivarfsVarStore.DeleteVar() error = %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("EfivarfsVarStore.DeleteVar() left %s: %v", path, err)
+	}
+}
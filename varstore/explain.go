@@ -0,0 +1,148 @@
+package varstore
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/metal3-community/uefi-firmware-manager/efi"
+)
+
+// explainMaxRows is the number of hexdump rows Explain shows per field.
+const explainMaxRows = 16
+
+// Explain writes an annotated hexdump of the varstore to w, for diagnosing
+// images the firmware rejects. It shows the firmware volume and varstore
+// headers, then every variable record with its state, name, GUID and
+// attributes, dumping the header, name and data of the record separately.
+// Damaged records are marked with the reason GetVarList would fail on them
+// and the dump continues with the next plausible record. Offsets are from
+// the start of the image.
+func (vs *Edk2VarStore) Explain(w io.Writer) error {
+	if vs.start < 0 || vs.start > vs.end || vs.end > len(vs.data) {
+		return fmt.Errorf("%w: variable area 0x%x-0x%x is outside the image",
+			ErrCorrupt, vs.start, vs.end)
+	}
+
+	var b strings.Builder
+	off := vs.findNvData(vs.data)
+	if len(vs.volumes) > 0 {
+		off = vs.volumes[vs.selected].Offset
+	}
+	if off >= 0 && off+0x38 <= vs.start {
+		hlen := int(binary.LittleEndian.Uint16(vs.data[off+48:]))
+		fmt.Fprintf(&b, "%08x  firmware volume %s, length 0x%x\n", off,
+			efi.GuidName(efi.ParseBinGUID(vs.data, off+16)), binary.LittleEndian.Uint64(vs.data[off+32:]))
+		dumpHex(&b, "header", vs.data[off:min(off+hlen, vs.start)], off)
+	}
+	if hdr := vs.start - varStoreHeaderSize; hdr >= 0 {
+		fmt.Fprintf(&b, "%08x  varstore %s, size 0x%x, format 0x%02x, state 0x%02x\n", hdr,
+			efi.GuidName(efi.ParseBinGUID(vs.data, hdr)), binary.LittleEndian.Uint32(vs.data[hdr+16:]),
+			vs.data[hdr+20], vs.data[hdr+21])
+		dumpHex(&b, "header", vs.data[hdr:vs.start], hdr)
+	}
+
+	for pos := vs.start; pos < vs.end; {
+		if vs.erased(pos, vs.end) {
+			fmt.Fprintf(&b, "%08x  free space, 0x%x bytes\n", pos, vs.end-pos)
+			break
+		}
+		next, err := vs.explainVar(&b, pos)
+		if err != nil {
+			next = vs.nextHeader(pos)
+			fmt.Fprintf(&b, "%08x  damaged record, 0x%x bytes: %v\n", pos, next-pos, err)
+			dumpHex(&b, "raw", vs.data[pos:next], pos)
+		}
+		pos = next
+	}
+	fmt.Fprintf(&b, "%08x  end of varstore\n", vs.end)
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// explainVar writes the variable record at pos to b and returns the offset
+// of the next record. It writes nothing if the header is unusable.
+func (vs *Edk2VarStore) explainVar(b *strings.Builder, pos int) (int, error) {
+	_, next, err := vs.parseVar(pos)
+	if next < 0 {
+		return 0, err
+	}
+
+	state := vs.data[pos+2]
+	attr := binary.LittleEndian.Uint32(vs.data[pos+4:])
+	nsize := int(binary.LittleEndian.Uint32(vs.data[pos+36:]))
+	dsize := int(binary.LittleEndian.Uint32(vs.data[pos+40:]))
+	guid := efi.ParseBinGUID(vs.data, pos+44)
+	nameStart := pos + varHeaderSize
+	dataStart := nameStart + nsize
+
+	name := "<invalid name>"
+	if s, err := efi.ParseUCS16Exact(vs.data[nameStart:dataStart]); err == nil {
+		name = s.String()
+	}
+	fmt.Fprintf(b, "%08x  variable %s %s, %s, attr 0x%08x, name 0x%x bytes, data 0x%x bytes\n",
+		pos, name, efi.GuidName(guid), varStateName(state), attr, nsize, dsize)
+	if err != nil {
+		fmt.Fprintf(b, "          invalid: %v\n", err)
+	}
+	dumpHex(b, "header", vs.data[pos:nameStart], pos)
+	dumpHex(b, "name", vs.data[nameStart:dataStart], nameStart)
+	dumpHex(b, "data", vs.data[dataStart:dataStart+dsize], dataStart)
+	return next, nil
+}
+
+// varStateName describes the state byte of a variable record. EDK2 moves a
+// record through these states by clearing bits.
+func varStateName(state byte) string {
+	const (
+		headerValid       = 0x7f
+		deletedTransition = 0xfe
+		deleted           = 0xfd
+	)
+	switch {
+	case state == headerValid:
+		return "header only"
+	case state == varAdded:
+		return "added"
+	case state == varAdded&deletedTransition:
+		return "in deleted transition"
+	case state == varAdded&deleted, state == varAdded&deleted&deletedTransition:
+		return "deleted"
+	default:
+		return fmt.Sprintf("unknown state 0x%02x", state)
+	}
+}
+
+// dumpHex writes data, found at off in the image, as rows of 16 bytes
+// labeled on the first row. Rows past explainMaxRows are elided.
+func dumpHex(b *strings.Builder, label string, data []byte, off int) {
+	for row := 0; row*16 < len(data); row++ {
+		if row == explainMaxRows {
+			fmt.Fprintf(b, "          %-7s ... 0x%x more bytes\n", "", len(data)-row*16)
+			return
+		}
+		chunk := data[row*16 : min(row*16+16, len(data))]
+		var hex, ascii strings.Builder
+		for i := range 16 {
+			if i == 8 {
+				hex.WriteByte(' ')
+			}
+			if i >= len(chunk) {
+				hex.WriteString("   ")
+				continue
+			}
+			fmt.Fprintf(&hex, " %02x", chunk[i])
+			if c := chunk[i]; c >= 0x20 && c < 0x7f {
+				ascii.WriteByte(c)
+			} else {
+				ascii.WriteByte('.')
+			}
+		}
+		if row > 0 {
+			label = ""
+		}
+		fmt.Fprintf(b, "          %-7s %08x %s  |%s|\n", label, off+row*16, hex.String(), ascii.String())
+	}
+}
@@ -0,0 +1,54 @@
+package varstore_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/metal3-community/uefi-firmware-manager/testutil"
+	"github.com/metal3-community/uefi-firmware-manager/testutil/golden"
+	"github.com/metal3-community/uefi-firmware-manager/varstore"
+)
+
+func explain(t *testing.T, image []byte) string {
+	t.Helper()
+	vs, err := varstore.New(image)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	if err := vs.Explain(&out); err != nil {
+		t.Fatalf("Explain() error = %v", err)
+	}
+	return out.String()
+}
+
+func TestEdk2VarStore_Explain(t *testing.T) {
+	image, err := testutil.Firmware(testutil.VarList(t, "UEFI Shell"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	golden.Assert(t, "explain", []byte(explain(t, image)))
+}
+
+func TestEdk2VarStore_ExplainDamaged(t *testing.T) {
+	image, err := testutil.Firmware(testutil.VarList(t, "UEFI Shell"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Break the magic of the first record, right after the varstore header.
+	first := 0x48 + 28
+	image[first] = 0
+
+	out := explain(t, image)
+	for _, want := range []string{
+		"damaged record",
+		"bad variable magic 0x5500",
+		"variable BootOrder",
+		"free space",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Explain() output lacks %q:\n%s", want, out)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package varstore
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/metal3-community/uefi-firmware-manager/efi"
+)
+
+// Operations reported in Metrics.
+const (
+	// OpParse is a GetVarList call.
+	OpParse = "parse"
+	// OpSerialize is a ReadBytes, ReadAll or WriteVarStore call.
+	OpSerialize = "serialize"
+)
+
+// Metrics describes one parse or serialization of an EDK2 varstore, so
+// that fleet operators can spot boards whose variable store is nearly full
+// before writes start failing.
+type Metrics struct {
+	Op string
+	// Path is the file the varstore was read from or is written to, if
+	// any.
+	Path     string
+	Duration time.Duration
+	// Variables is the number of variables parsed or serialized and
+	// UsedBytes the space they occupy of the Capacity of the varstore.
+	Variables int
+	UsedBytes int
+	Capacity  int
+	// Skipped is the number of damaged regions skipped in recovery mode.
+	Skipped int
+	// Err is the error the operation failed with, for instance
+	// ErrQuotaExceeded.
+	Err error
+}
+
+// FreePercent returns the share of the capacity left free, in percent. It
+// is negative if the variables do not fit.
+func (m Metrics) FreePercent() float64 {
+	if m.Capacity == 0 {
+		return 0
+	}
+	return float64(m.Capacity-m.UsedBytes) / float64(m.Capacity) * 100
+}
+
+// MetricsFunc receives the Metrics of varstore operations. It is called
+// synchronously by the operation, so it must not block.
+type MetricsFunc func(Metrics)
+
+// defaultMetrics is the MetricsFunc set with SetMetrics.
+var defaultMetrics atomic.Pointer[MetricsFunc]
+
+// SetMetrics makes every Edk2VarStore without its own Metrics report to
+// fn, for instance to export them as gauges and counters. A nil fn stops
+// reporting.
+func SetMetrics(fn MetricsFunc) {
+	if fn == nil {
+		defaultMetrics.Store(nil)
+		return
+	}
+	defaultMetrics.Store(&fn)
+}
+
+// metricsFunc returns the MetricsFunc of vs, or nil if none is set.
+func (vs *Edk2VarStore) metricsFunc() MetricsFunc {
+	if vs.Metrics != nil {
+		return vs.Metrics
+	}
+	if p := defaultMetrics.Load(); p != nil {
+		return *p
+	}
+	return nil
+}
+
+// report sends the Metrics of the operation op on varlist, started at
+// start, to fn.
+func (vs *Edk2VarStore) report(fn MetricsFunc, op, path string, varlist efi.EfiVarList, start time.Time, err error) {
+	m := Metrics{
+		Op:        op,
+		Path:      path,
+		Duration:  time.Since(start),
+		Variables: len(varlist),
+		UsedBytes: UsedSpace(varlist),
+		Capacity:  vs.Capacity(),
+		Err:       err,
+	}
+	if op == OpParse {
+		m.Skipped = len(vs.skipped)
+	}
+	fn(m)
+}
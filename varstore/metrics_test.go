@@ -0,0 +1,71 @@
+package varstore
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-logr/logr"
+	"github.com/metal3-community/uefi-firmware-manager/efi"
+)
+
+func TestEdk2VarStore_Metrics(t *testing.T) {
+	timeout := &efi.EfiVar{
+		Name: efi.FromString("Timeout"),
+		Guid: efi.EFI_GLOBAL_VARIABLE_GUID,
+		Attr: efi.EfiVariableDefault,
+		Data: []byte{0x05, 0x00},
+	}
+	vs := &Edk2VarStore{Logger: logr.Discard(), path: "VARS.fd"}
+	record := vs.bytesVar(timeout)
+	vs.data = append(record, bytes.Repeat([]byte{0xff}, 200-len(record))...)
+	vs.start, vs.end = 0, 200
+
+	var got []Metrics
+	vs.Metrics = func(m Metrics) { got = append(got, m) }
+
+	varlist, err := vs.GetVarList()
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "out.fd")
+	if err := vs.WriteVarStore(path, varlist); err != nil {
+		t.Fatal(err)
+	}
+	varlist.Set(&efi.EfiVar{Name: efi.FromString("Big"), Guid: efi.EFI_GLOBAL_VARIABLE_GUID,
+		Attr: efi.EfiVariableDefault, Data: make([]byte, 200)})
+	if _, err := vs.ReadAll(varlist); !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("ReadAll() error = %v, want ErrQuotaExceeded", err)
+	}
+
+	if len(got) != 3 {
+		t.Fatalf("reported %+v", got)
+	}
+	used := VariableSize(timeout)
+	if m := got[0]; m.Op != OpParse || m.Path != "VARS.fd" || m.Variables != 1 || m.UsedBytes != used ||
+		m.Capacity != 200 || m.Err != nil {
+		t.Errorf("parse metrics = %+v", m)
+	}
+	if m := got[1]; m.Op != OpSerialize || m.Path != path || m.Variables != 1 || m.Err != nil {
+		t.Errorf("write metrics = %+v", m)
+	}
+	if m := got[2]; m.Op != OpSerialize || m.Variables != 2 || !errors.Is(m.Err, ErrQuotaExceeded) || m.FreePercent() >= 0 {
+		t.Errorf("failed serialization metrics = %+v, free %.1f%%", m, m.FreePercent())
+	}
+	if free := got[0].FreePercent(); free != float64(200-used)/2 {
+		t.Errorf("FreePercent() = %v", free)
+	}
+
+	// Without its own MetricsFunc the varstore reports to the default.
+	vs.Metrics = nil
+	var defaults int
+	SetMetrics(func(Metrics) { defaults++ })
+	defer SetMetrics(nil)
+	if _, err := vs.GetVarList(); err != nil {
+		t.Fatal(err)
+	}
+	if defaults != 1 || len(got) != 3 {
+		t.Errorf("default metrics reported %d times, own %d", defaults, len(got)-3)
+	}
+}
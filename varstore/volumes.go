@@ -0,0 +1,80 @@
+package varstore
+
+import (
+	"fmt"
+)
+
+// Volume is an NVDATA firmware volume of an image. Besides the primary
+// varstore, some images carry a standby copy in a second volume.
+type Volume struct {
+	// Offset and Size are the position and length of the firmware volume.
+	// Size is zero if the volume header claims more than the image holds.
+	Offset int
+	Size   int
+	// Start and End delimit the variable area of its varstore.
+	Start int
+	End   int
+	// Err is why the volume holds no usable varstore, if it does not.
+	Err error
+}
+
+// Capacity returns the number of bytes available for variables in the
+// volume.
+func (v Volume) Capacity() int {
+	return v.End - v.Start
+}
+
+func (v Volume) String() string {
+	if v.Err != nil {
+		return fmt.Sprintf("volume 0x%x: %v", v.Offset, v.Err)
+	}
+	return fmt.Sprintf("volume 0x%x+0x%x: variables 0x%x-0x%x", v.Offset, v.Size, v.Start, v.End)
+}
+
+// Volumes returns the NVDATA volumes of the image, in image order,
+// including those without a usable varstore.
+func (vs *Edk2VarStore) Volumes() []Volume {
+	return vs.volumes
+}
+
+// SelectedVolume returns the index in Volumes of the volume the varstore
+// reads and writes. The first usable volume is selected initially.
+func (vs *Edk2VarStore) SelectedVolume() int {
+	return vs.selected
+}
+
+// SelectVolume makes the varstore read and write the variables of the
+// volume with index i in Volumes, for instance to recover from a damaged
+// primary volume using its standby copy.
+func (vs *Edk2VarStore) SelectVolume(i int) error {
+	if i < 0 || i >= len(vs.volumes) {
+		return fmt.Errorf("no varstore volume %d; the image has %d", i, len(vs.volumes))
+	}
+	if err := vs.volumes[i].Err; err != nil {
+		return fmt.Errorf("varstore volume %d is unusable: %w", i, err)
+	}
+	vs.selectVolume(i)
+	return nil
+}
+
+// selectVolume selects the volume with index i.
+func (vs *Edk2VarStore) selectVolume(i int) {
+	v := vs.volumes[i]
+	vs.selected = i
+	vs.start, vs.end = v.Start, v.End
+	vs.volEnd = 0
+	if v.Size > 0 {
+		vs.volEnd = v.Offset + v.Size
+	}
+}
+
+// mirrors returns the usable volumes other than the selected one.
+func (vs *Edk2VarStore) mirrors() []Volume {
+	var mirrors []Volume
+	for i, v := range vs.volumes {
+		if i != vs.selected && v.Err == nil {
+			mirrors = append(mirrors, v)
+		}
+	}
+	return mirrors
+}
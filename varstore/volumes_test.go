@@ -0,0 +1,108 @@
+package varstore_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/metal3-community/uefi-firmware-manager/testutil"
+	"github.com/metal3-community/uefi-firmware-manager/varstore"
+)
+
+func TestEdk2VarStore_Volumes(t *testing.T) {
+	primary, err := testutil.Firmware(testutil.VarList(t, "SD/MMC"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	standby, err := testutil.Firmware(testutil.VarList(t, "USB", "PXE"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	image := append(slices.Clone(primary), standby...)
+
+	vs, err := varstore.New(image)
+	if err != nil {
+		t.Fatal(err)
+	}
+	volumes := vs.Volumes()
+	if len(volumes) != 2 || volumes[1].Offset != testutil.VolumeSize || volumes[1].Err != nil ||
+		volumes[0].Capacity() != vs.Capacity() {
+		t.Fatalf("Volumes() = %v", volumes)
+	}
+	if vs.SelectedVolume() != 0 {
+		t.Errorf("SelectedVolume() = %d, want 0", vs.SelectedVolume())
+	}
+	count := func() int {
+		t.Helper()
+		l, err := vs.GetVarList()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return len(l)
+	}
+	if got := count(); got != 4 {
+		t.Errorf("primary holds %d variables, want 4", got)
+	}
+	if err := vs.SelectVolume(1); err != nil {
+		t.Fatal(err)
+	}
+	if got := count(); got != 5 {
+		t.Errorf("standby holds %d variables, want 5", got)
+	}
+	if err := vs.SelectVolume(2); err == nil {
+		t.Error("SelectVolume(2) succeeded")
+	}
+
+	// Writing without Mirror leaves the other volume alone; with Mirror
+	// both hold the selected variables.
+	if err := vs.SelectVolume(0); err != nil {
+		t.Fatal(err)
+	}
+	varlist, err := vs.GetVarList()
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := vs.ReadAll(varlist)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out[testutil.VolumeSize:], standby) {
+		t.Error("write without Mirror changed the standby volume")
+	}
+	vs.Mirror = true
+	out, err = vs.ReadAll(varlist)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out[testutil.VolumeSize:], primary) {
+		t.Error("write with Mirror did not copy the primary volume")
+	}
+
+	// A damaged primary volume is skipped in favour of the standby one.
+	damaged := slices.Clone(image)
+	damaged[0x48+20] = 0
+	vs, err = varstore.New(damaged)
+	if err != nil {
+		t.Fatalf("New() with a damaged primary error = %v", err)
+	}
+	if vs.SelectedVolume() != 1 || vs.Volumes()[0].Err == nil {
+		t.Errorf("selected volume %d of %v", vs.SelectedVolume(), vs.Volumes())
+	}
+	if err := vs.SelectVolume(0); err == nil {
+		t.Error("SelectVolume() selected the damaged volume")
+	}
+
+	// A standby volume too small for the variables fails a mirrored write.
+	small := slices.Clone(image)
+	binary.LittleEndian.PutUint32(small[testutil.VolumeSize+0x48+16:], 0x100)
+	vs, err = varstore.New(small)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vs.Mirror = true
+	if _, err := vs.ReadAll(varlist); !errors.Is(err, varstore.ErrQuotaExceeded) {
+		t.Errorf("mirrored write to a small standby error = %v, want ErrQuotaExceeded", err)
+	}
+}